@@ -2,15 +2,27 @@ package v1
 
 import (
 	"harnsplatform/internal/biz"
-	"harnsplatform/internal/common"
 )
 
-var AgentTypeMap = map[string]func() Agents{
-	"modbus": func() Agents { return &ModbusAgent{} },
+// agentTypes agent类型 => 空的agents, 由collector.Register在驱动的init中注册
+var agentTypes = make(map[string]func() Agents)
+
+// RegisterAgentType 注册agent类型对应的请求体
+func RegisterAgentType(agentType string, newAgents func() Agents) {
+	agentTypes[agentType] = newAgents
+}
+
+// NewAgents 返回agent类型对应的空agents, 未注册时返回false
+func NewAgents(agentType string) (Agents, bool) {
+	newAgents, ok := agentTypes[agentType]
+	if !ok {
+		return nil, false
+	}
+	return newAgents(), true
 }
 
 type Agents interface {
-	GetAgentType() common.AgentType
+	GetAgentType() string
 	biz.ObjectMeta
 }
 
@@ -26,42 +38,72 @@ type ModbusAgent struct {
 	*biz.Meta        `json:",inline"`
 }
 
-func (m *ModbusAgent) GetAgentType() common.AgentType {
-	return common.StringToAgentType[m.AgentType]
+func (m *ModbusAgent) GetAgentType() string {
+	return m.AgentType
 }
 
 type MQTTAgent struct {
-	Name             string `json:"name,omitempty"`
-	Description      string `json:"description,omitempty"`
-	AgentType        string `json:"agentType,omitempty"`
-	CollectorCycle   uint   `json:"collectorCycle,omitempty"`   // 采集周期毫秒
-	VariableInterval uint   `json:"variableInterval,omitempty"` // 变量间隔
-	// AgentDetails     JSONMap `json:"agentDetails,omitempty"`
-	// Address          JSONMap `json:"address,omitempty"`
-	Broker    string `json:"broker,omitempty"`
-	*biz.Meta `json:",inline"`
+	Name             string               `json:"name,omitempty"`
+	Description      string               `json:"description,omitempty"`
+	AgentType        string               `json:"agentType,omitempty"`
+	CollectorCycle   uint                 `json:"collectorCycle,omitempty"`   // 采集周期毫秒, mqtt按消息到达推送
+	VariableInterval uint                 `json:"variableInterval,omitempty"` // 变量间隔
+	AgentDetails     biz.MQTTAgentDetails `json:"agentDetails,omitempty"`
+	Address          biz.MQTTAgentAddress `json:"address,omitempty"`
+	Broker           string               `json:"broker,omitempty"`
+	*biz.Meta        `json:",inline"`
+}
+
+func (m *MQTTAgent) GetAgentType() string {
+	return m.AgentType
 }
 
-// todo
 type OpcUaAgent struct {
-	Name            string                              `json:"name,omitempty"`
-	ThingTypeId     *string                             `json:"parentTypeId,omitempty"`
-	Description     string                              `json:"description,omitempty"`
-	Characteristics map[string]*biz.Characteristics     `json:"characteristics,omitempty"`
-	PropertySets    map[string]map[string]*biz.Property `json:"propertySets,omitempty"`
-	Combination     []string                            `json:"combination,omitempty"`
-	*biz.Meta       `json:",inline"`
+	Name             string                `json:"name,omitempty"`
+	Description      string                `json:"description,omitempty"`
+	AgentType        string                `json:"agentType,omitempty"`
+	CollectorCycle   uint                  `json:"collectorCycle,omitempty"`   // 采集周期毫秒, 订阅模式下为默认发布周期
+	VariableInterval uint                  `json:"variableInterval,omitempty"` // 变量间隔
+	AgentDetails     biz.OpcUaAgentDetails `json:"agentDetails,omitempty"`
+	Address          biz.OpcUaAgentAddress `json:"address,omitempty"`
+	Broker           string                `json:"broker,omitempty"`
+	*biz.Meta        `json:",inline"`
+}
+
+func (m *OpcUaAgent) GetAgentType() string {
+	return m.AgentType
+}
+
+type S7Agent struct {
+	Name             string             `json:"name,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	AgentType        string             `json:"agentType,omitempty"`
+	CollectorCycle   uint               `json:"collectorCycle,omitempty"`   // 采集周期
+	VariableInterval uint               `json:"variableInterval,omitempty"` // 变量间隔
+	AgentDetails     biz.S7AgentDetails `json:"agentDetails,omitempty"`
+	Address          biz.S7AgentAddress `json:"address,omitempty"`
+	Broker           string             `json:"broker,omitempty"`
+	*biz.Meta        `json:",inline"`
+}
+
+func (m *S7Agent) GetAgentType() string {
+	return m.AgentType
 }
 
-// todo
 type HttpAgent struct {
-	Name            string                              `json:"name,omitempty"`
-	ThingTypeId     *string                             `json:"parentTypeId,omitempty"`
-	Description     string                              `json:"description,omitempty"`
-	Characteristics map[string]*biz.Characteristics     `json:"characteristics,omitempty"`
-	PropertySets    map[string]map[string]*biz.Property `json:"propertySets,omitempty"`
-	Combination     []string                            `json:"combination,omitempty"`
-	*biz.Meta       `json:",inline"`
+	Name             string               `json:"name,omitempty"`
+	Description      string               `json:"description,omitempty"`
+	AgentType        string               `json:"agentType,omitempty"`
+	CollectorCycle   uint                 `json:"collectorCycle,omitempty"`   // 轮询周期毫秒
+	VariableInterval uint                 `json:"variableInterval,omitempty"` // 变量间隔
+	AgentDetails     biz.HttpAgentDetails `json:"agentDetails,omitempty"`
+	Address          biz.HttpAgentAddress `json:"address,omitempty"`
+	Broker           string               `json:"broker,omitempty"`
+	*biz.Meta        `json:",inline"`
+}
+
+func (m *HttpAgent) GetAgentType() string {
+	return m.AgentType
 }
 
 // todo
@@ -35,6 +35,8 @@ type AgentsHTTPServer interface {
 	DeleteAgentsMappingsById(context.Context, *biz.Meta) (*biz.Mapping, error)
 	DeleteAgentsMappings(context.Context, *BatchIds) (*BatchIds, error)
 	GetMappingsByAgentsId(context.Context, *biz.MappingsQuery) (*biz.PaginationResponse, error)
+	GetAgentsFramePlan(context.Context, *biz.Meta) (interface{}, error)
+	ListAgentTypes(context.Context) (interface{}, error)
 }
 
 func RegisterAgentsHTTPServer(s *http.Server, srv AgentsHTTPServer) {
@@ -47,6 +49,8 @@ func RegisterAgentsHTTPServer(s *http.Server, srv AgentsHTTPServer) {
 	r.GET("/model-manager/v1/agents", GetAgents(srv))
 	r.POST("/model-manager/v1/agents/{id}/mappings", CreateAgentsMappings(srv))
 	r.GET("/model-manager/v1/agents/{id}/mappings", GetMappingsByAgentsId(srv))
+	r.GET("/model-manager/v1/agents/{id}/framePlan", GetAgentsFramePlan(srv))
+	r.GET("/model-manager/v1/agentTypes", ListAgentTypes(srv))
 	r.DELETE("/model-manager/v1/agents/mappings/{id}", DeleteAgentsMappingsById(srv))
 	r.POST("/model-manager/v1/deleteMappingsBatch", DeleteAgentsMappings(srv))
 }
@@ -59,7 +63,10 @@ func CreateAgents(srv AgentsHTTPServer) func(ctx http.Context) error {
 		if err := ctx.Bind(&target); err != nil {
 			return err
 		}
-		in := AgentTypeMap[target.AgentType]()
+		in, ok := NewAgents(target.AgentType)
+		if !ok {
+			return errors.GenerateAgentsUnsupportedError(target.AgentType)
+		}
 		http.SetOperation(ctx, OperationAgentsCreateAgents)
 		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
 			return srv.CreateAgents(ctx, req.(Agents))
@@ -93,7 +100,10 @@ func UpdateAgentsById(srv AgentsHTTPServer) func(ctx http.Context) error {
 			return err
 		}
 
-		in := AgentTypeMap[target.AgentType]()
+		in, ok := NewAgents(target.AgentType)
+		if !ok {
+			return errors.GenerateAgentsUnsupportedError(target.AgentType)
+		}
 
 		if err := ctx.Bind(in); err != nil {
 			return err
@@ -291,6 +301,38 @@ func GetMappingsByAgentsId(srv AgentsHTTPServer) func(ctx http.Context) error {
 	}
 }
 
+func GetAgentsFramePlan(srv AgentsHTTPServer) func(ctx http.Context) error {
+	return func(ctx http.Context) error {
+		var in biz.Meta
+		if err := ctx.BindVars(&in); err != nil {
+			return err
+		}
+		http.SetOperation(ctx, OperationAgentsCreateAgents)
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return srv.GetAgentsFramePlan(ctx, req.(*biz.Meta))
+		})
+		out, err := h(ctx, &in)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(200, out)
+	}
+}
+
+func ListAgentTypes(srv AgentsHTTPServer) func(ctx http.Context) error {
+	return func(ctx http.Context) error {
+		http.SetOperation(ctx, OperationAgentsCreateAgents)
+		h := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return srv.ListAgentTypes(ctx)
+		})
+		out, err := h(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(200, out)
+	}
+}
+
 type AgentsHTTPClient interface {
 	// CreateAgents(ctx context.Context, req *biz.Agents, opts ...http.CallOption) (rsp *biz.Agents, err error)
 	GetAgentsByBrokerId(ctx context.Context, req *biz.AgentsQuery, opts ...http.CallOption) ([]*biz.Agents, error)
@@ -10,9 +10,11 @@ import (
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/middleware/tracing"
+	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/go-kratos/kratos/v2/transport/http"
 
 	_ "go.uber.org/automaxprocs"
+	_ "harnsplatform/internal/collector/drivers"
 )
 
 // go build -ldflags "-X main.Version=x.y.z"
@@ -31,7 +33,8 @@ func init() {
 	flag.StringVar(&flagconf, "conf", "../../configs", "config path, eg: -conf config.yaml")
 }
 
-func newApp(logger log.Logger, hs *http.Server) *kratos.App {
+// servers 随应用启停的其他服务, 例如北向modbus网关
+func newApp(logger log.Logger, hs *http.Server, servers ...transport.Server) *kratos.App {
 	return kratos.New(
 		kratos.ID(id),
 		kratos.Name(Name),
@@ -39,7 +42,7 @@ func newApp(logger log.Logger, hs *http.Server) *kratos.App {
 		kratos.Metadata(map[string]string{}),
 		kratos.Logger(logger),
 		kratos.Server(
-			hs,
+			append([]transport.Server{hs}, servers...)...,
 		),
 	)
 }
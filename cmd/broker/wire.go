@@ -7,10 +7,17 @@
 package main
 
 import (
+	"context"
 	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/gateway"
 	"harnsplatform/internal/conf"
-	"harnsplatform/internal/server/modelmanager"
+	brokermanager "harnsplatform/internal/server/brokermanager"
+	"harnsplatform/internal/service"
 )
 
 import (
@@ -21,40 +28,49 @@ import (
 
 // wireApp init kratos application.
 func wireApp(confServer *conf.Server, conf *conf.TimeSeriesData, data *conf.BrokerConfig, log *log.Helper, logger log.Logger) (*kratos.App, func(), error) {
-	cleanup := func() error {
-		return nil
+	client, err := http.NewClient(context.Background(),
+		http.WithEndpoint(data.ModelManager.GetEndpoint()),
+		http.WithTimeout(data.ModelManager.GetTimeout()),
+	)
+	if err != nil {
+		return nil, nil, err
 	}
+	modelManager := collector.NewModelManager(pb.NewAgentsHTTPClient(client), pb.NewThingTypesHTTPClient(client), pb.NewThingsHTTPClient(client))
 
+	var timeSeriesManager *collector.TimeSeriesManager
 	if data.TimeSeriesStore.GetFlag() {
 		// 初始化influxdb 数据库
-
+		timeSeriesManager = collector.NewTimeSeriesManager(conf.Influxdb.GetUrl(), conf.Influxdb.GetToken())
 	}
 
 	if data.Sink.GetFlag() {
 
 	}
 
+	stopCh := make(chan struct{})
+	collectorManager := collector.NewManager(modelManager, timeSeriesManager, data.TimeSeriesStore.GetFlag(), stopCh)
+	collectorManager.Init()
+
+	servers := make([]transport.Server, 0)
+	if data.Gateway.GetFlag() {
+		// 网关按物模型属性解析点位, 需在模型加载之后创建
+		modbusGateway, err := gateway.NewGateway(data.Gateway, collectorManager)
+		if err != nil {
+			_ = client.Close()
+			return nil, nil, err
+		}
+		collectorManager.AddVariableSink(modbusGateway)
+		servers = append(servers, modbusGateway)
+	}
+	collectorManager.Run()
 
-	// dataData, cleanup, err := data.NewData(confData, log)
-	// if err != nil {
-	// 	return nil, nil, err
-	// }
-	//
-	// thingTypesRepo := data.NewThingTypesRepo(dataData, log)
-	// thingTypesUsecase := biz.NewThingTypesUsecase(thingTypesRepo, log)
-	// thingTypesService := service.NewThingTypesService(thingTypesUsecase, log)
-	//
-	// thingsRepo := data.NewThingsRepo(dataData, log)
-	// thingsUsecase := biz.NewThingsUsecase(thingsRepo, log)
-	// thingsService := service.NewThingsService(thingsUsecase, thingTypesUsecase, log)
-	//
-	// agentsRepo := data.NewAgentsRepo(dataData, log)
-	// agentsUsecase := biz.NewAgentsUsecase(agentsRepo, log)
-	// agentService := service.NewAgentsService(agentsUsecase, log)
-
-	httpServer := modelmanager.NewHTTPServer(confServer, log)
-	app := newApp(logger, httpServer)
+	discoveryService := service.NewDiscoveryService(log)
+	diagnosticsService := service.NewDiagnosticsService(collectorManager, log)
+	httpServer := brokermanager.NewHTTPServer(confServer, discoveryService, diagnosticsService, log)
+	app := newApp(logger, httpServer, servers...)
 	return app, func() {
-		cleanup()
+		close(stopCh)
+		_ = collectorManager.Shutdown(context.Background())
+		_ = client.Close()
 	}, nil
 }
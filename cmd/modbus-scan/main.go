@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/go-kratos/kratos/v2/log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus"
+)
+
+var (
+	protocol       string
+	location       string
+	port           int
+	baudRate       int
+	dataBits       int
+	parity         string
+	stopBits       string
+	echoCancel     bool
+	slave          uint
+	ranges         string
+	maxRegisters   uint
+	maxCoils       uint
+	samples        uint
+	sampleInterval uint
+	maxRequests    uint
+	agentId        string
+	output         string
+)
+
+func init() {
+	flag.StringVar(&protocol, "protocol", "modbusTcp", "modbusTcp modbusRtu modbusRtuOverTcp modbusAscii modbusAsciiOverTcp modbusUdp")
+	flag.StringVar(&location, "location", "127.0.0.1", "ip address or serial port, eg: -location /dev/ttyUSB0")
+	flag.IntVar(&port, "port", 502, "tcp or udp port")
+	flag.IntVar(&baudRate, "baudRate", 9600, "serial baud rate")
+	flag.IntVar(&dataBits, "dataBits", 8, "serial data bits")
+	flag.StringVar(&parity, "parity", "", "serial parity, eg: -parity E")
+	flag.StringVar(&stopBits, "stopBits", "", "serial stop bits, eg: -stopBits 1")
+	flag.BoolVar(&echoCancel, "echoCancel", false, "discard the echo of half duplex adapters")
+	flag.UintVar(&slave, "slave", 1, "slave id")
+	flag.StringVar(&ranges, "ranges", "", "address ranges separated by comma, eg: -ranges 40000-40999,30000-30099")
+	flag.UintVar(&maxRegisters, "maxRegisters", 0, "max registers per request")
+	flag.UintVar(&maxCoils, "maxCoils", 0, "max coils per request")
+	flag.UintVar(&samples, "samples", 0, "samples per responsive address")
+	flag.UintVar(&sampleInterval, "sampleInterval", 0, "sample interval in milliseconds")
+	flag.UintVar(&maxRequests, "maxRequests", 0, "max requests in total")
+	flag.StringVar(&agentId, "agentId", "", "agent id of the draft mappings")
+	flag.StringVar(&output, "o", "", "write the result to a file instead of stdout")
+}
+
+func main() {
+	flag.Parse()
+	logger := log.With(log.NewStdLogger(os.Stderr), "ts", log.DefaultTimestamp, "caller", log.DefaultCaller)
+	log := log.NewHelper(logger)
+
+	option := biz.JSONMap{"port": port, "baudRate": baudRate, "dataBits": dataBits, "echoCancel": echoCancel}
+	if parity != "" {
+		option["parity"] = parity
+	}
+	if stopBits != "" {
+		option["stopBits"] = stopBits
+	}
+	opts := &biz.ModbusDiscoveryOptions{
+		Protocol:       protocol,
+		Slave:          slave,
+		Address:        biz.JSONMap{"location": location, "option": option},
+		MaxRegisters:   maxRegisters,
+		MaxCoils:       maxCoils,
+		Samples:        samples,
+		SampleInterval: sampleInterval,
+		MaxRequests:    maxRequests,
+		AgentId:        agentId,
+	}
+	if ranges != "" {
+		opts.Ranges = strings.Split(ranges, ",")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		<-c
+		cancel()
+	}()
+
+	result, err := modbus.Discover(ctx, opts)
+	if err != nil {
+		log.Fatalf("Failed to discover modbus slave. err description:%s", err)
+	}
+	bytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal result. err description:%s", err)
+	}
+	if output == "" {
+		_, _ = os.Stdout.Write(append(bytes, '\n'))
+		return
+	}
+	if err := os.WriteFile(output, bytes, 0644); err != nil {
+		log.Fatalf("Failed to write result. err description:%s", err)
+	}
+}
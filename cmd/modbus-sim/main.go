@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/spf13/viper"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"harnsplatform/internal/collector/modbus/slave"
+)
+
+var (
+	// flagconf is the config flag.
+	flagconf   string
+	tcp        string
+	rtuOverTcp string
+	rtu        bool
+	link       string
+)
+
+func init() {
+	flag.StringVar(&flagconf, "conf", "", "config path, eg: -conf config.yaml")
+	flag.StringVar(&tcp, "tcp", "", "modbus tcp listen address, eg: -tcp 0.0.0.0:5020")
+	flag.StringVar(&rtuOverTcp, "rtuOverTcp", "", "modbus rtu over tcp listen address, eg: -rtuOverTcp 0.0.0.0:5021")
+	flag.BoolVar(&rtu, "rtu", false, "serve modbus rtu on a pseudo terminal")
+	flag.StringVar(&link, "link", "", "symlink to the pseudo terminal, eg: -link /tmp/ttyModbus")
+}
+
+func main() {
+	flag.Parse()
+	logger := log.With(log.NewStdLogger(os.Stdout), "ts", log.DefaultTimestamp, "caller", log.DefaultCaller)
+	log := log.NewHelper(logger)
+
+	cfg := &slave.Config{
+		Slaves: []*slave.SlaveConfig{{Id: 1, Coils: 1000, DiscreteInputs: 1000, HoldingRegisters: 1000, InputRegisters: 1000}},
+	}
+	if flagconf != "" {
+		viper.SetConfigFile(flagconf)
+		if err := viper.ReadInConfig(); err != nil {
+			log.Fatalf("Failed to read config yaml. err description:%s", err)
+		}
+		if err := viper.Unmarshal(cfg); err != nil {
+			log.Fatalf("Failed to unmarshal config yaml. err description:%s", err)
+		}
+	}
+	// 命令行参数优先
+	if tcp != "" {
+		cfg.Tcp = tcp
+	}
+	if rtuOverTcp != "" {
+		cfg.RtuOverTcp = rtuOverTcp
+	}
+	if rtu {
+		cfg.Rtu = true
+	}
+	if link != "" {
+		cfg.Link = link
+	}
+	if cfg.Tcp == "" && cfg.RtuOverTcp == "" && !cfg.Rtu {
+		cfg.Tcp = "0.0.0.0:5020"
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slaves := make([]*slave.Slave, 0, len(cfg.Slaves))
+	for _, sc := range cfg.Slaves {
+		s, bindings, err := sc.Slave()
+		if err != nil {
+			log.Fatalf("Failed to create slave %d. err description:%s", sc.Id, err)
+		}
+		go s.Run(ctx, cfg.Interval, bindings)
+		slaves = append(slaves, s)
+	}
+	server := slave.NewServer(cfg.Faults.Faults(), slaves...)
+
+	if cfg.Tcp != "" {
+		listener, err := net.Listen("tcp", cfg.Tcp)
+		if err != nil {
+			log.Fatalf("Failed to listen modbus tcp. err description:%s", err)
+		}
+		log.Infof("Serving modbus tcp on %s", listener.Addr())
+		go func() {
+			_ = server.ServeTcp(listener)
+		}()
+	}
+	if cfg.RtuOverTcp != "" {
+		listener, err := net.Listen("tcp", cfg.RtuOverTcp)
+		if err != nil {
+			log.Fatalf("Failed to listen modbus rtu over tcp. err description:%s", err)
+		}
+		log.Infof("Serving modbus rtu over tcp on %s", listener.Addr())
+		go func() {
+			_ = server.ServeRtuOverTcp(listener)
+		}()
+	}
+	if cfg.Rtu {
+		pty, err := slave.OpenPty()
+		if err != nil {
+			log.Fatalf("Failed to open pseudo terminal. err description:%s", err)
+		}
+		defer pty.Close()
+		if cfg.Link != "" {
+			_ = os.Remove(cfg.Link)
+			if err := os.Symlink(pty.Name, cfg.Link); err != nil {
+				log.Fatalf("Failed to link pseudo terminal. err description:%s", err)
+			}
+			defer os.Remove(cfg.Link)
+		}
+		log.Infof("Serving modbus rtu on %s", pty.Name)
+		go func() {
+			if err := server.ServeRtu(pty.Master); err != nil {
+				log.Errorf("Stopped to serve modbus rtu. err description:%s", err)
+			}
+		}()
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	<-c
+}
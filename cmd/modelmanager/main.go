@@ -13,6 +13,7 @@ import (
 	"github.com/go-kratos/kratos/v2/transport/http"
 
 	_ "go.uber.org/automaxprocs"
+	_ "harnsplatform/internal/collector/drivers"
 )
 
 // go build -ldflags "-X main.Version=x.y.z"
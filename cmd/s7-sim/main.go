@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/spf13/viper"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"harnsplatform/internal/collector/s7/plc"
+)
+
+var (
+	// flagconf is the config flag.
+	flagconf string
+	listen   string
+	pduSize  uint
+)
+
+func init() {
+	flag.StringVar(&flagconf, "conf", "", "config path, eg: -conf config.yaml")
+	flag.StringVar(&listen, "listen", "", "s7 listen address, eg: -listen 0.0.0.0:1102")
+	flag.UintVar(&pduSize, "pduSize", 0, "max pdu size, eg: -pduSize 240")
+}
+
+func main() {
+	flag.Parse()
+	logger := log.With(log.NewStdLogger(os.Stdout), "ts", log.DefaultTimestamp, "caller", log.DefaultCaller)
+	log := log.NewHelper(logger)
+
+	cfg := &plc.Config{
+		Inputs:     1024,
+		Outputs:    1024,
+		Merkers:    1024,
+		DataBlocks: []*plc.DataBlockConfig{{Number: 1, Size: 1024}},
+	}
+	if flagconf != "" {
+		viper.SetConfigFile(flagconf)
+		if err := viper.ReadInConfig(); err != nil {
+			log.Fatalf("Failed to read config yaml. err description:%s", err)
+		}
+		if err := viper.Unmarshal(cfg); err != nil {
+			log.Fatalf("Failed to unmarshal config yaml. err description:%s", err)
+		}
+	}
+	// 命令行参数优先
+	if listen != "" {
+		cfg.Listen = listen
+	}
+	if pduSize != 0 {
+		cfg.PduSize = pduSize
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = "0.0.0.0:1102"
+	}
+	if cfg.PduSize == 0 {
+		cfg.PduSize = 480
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := cfg.PLC()
+	if err != nil {
+		log.Fatalf("Failed to create s7 plc. err description:%s", err)
+	}
+	go p.Run(ctx, cfg.Interval)
+
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatalf("Failed to listen s7. err description:%s", err)
+	}
+	log.Infof("Serving s7 on %s, pdu size %d", listener.Addr(), cfg.PduSize)
+	go func() {
+		_ = cfg.Server(p).Serve(listener)
+	}()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	<-c
+}
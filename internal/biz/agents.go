@@ -15,7 +15,7 @@ type Agents struct {
 	AgentType        string     `gorm:"column:agent_type;type:varchar(32)" json:"agentType"`
 	Description      string     `gorm:"column:description;type:varchar(256)" json:"dataType"`
 	CollectorCycle   uint       `gorm:"column:collector_cycle;type:int" json:"collectorCycle"`     // 采集周期毫秒
-	VariableInterval uint       `gorm:"column:variable_interval;type:int" json:"variableInterval"` // 变量间隔
+	VariableInterval uint       `gorm:"column:variable_interval;type:int" json:"variableInterval"` // 变量间隔 相邻两帧的最小间隔毫秒
 	AgentDetails     JSONMap    `gorm:"column:agent_details;type:json" json:"agentDetails"`
 	Address          JSONMap    `gorm:"column:address;type:json" json:"address"`
 	Mappings         []*Mapping `gorm:"foreignKey:agent_id;references:id" json:"mappings"`
@@ -26,14 +26,24 @@ type Agents struct {
 type Mapping struct {
 	Meta         `gorm:"embedded"`
 	AgentId      string      `gorm:"column:agent_id;type:varchar(32);index:idx_agent_id" json:"agentId"`
-	DataType     string      `gorm:"column:data_type;type:varchar(32)" json:"dataType"`                     // bool、int16、float32、float64、int32、int64、uint16
+	DataType     string      `gorm:"column:data_type;type:varchar(32)" json:"dataType"`                     // bool、int8、uint8、int16、uint16、int32、uint32、int64、uint64、float32、float64、bcd16、bcd32、bcd64、string
 	Name         string      `gorm:"column:name;type:varchar(32)"  json:"name"`                             // 变量名称
-	Variable     string      `gorm:"column:variable;type:varchar(32)"  json:"variable"`                     // 变量地址 4655536 functionCode = 4
+	Variable     string      `gorm:"column:variable;type:varchar(32)"  json:"variable"`                     // 变量地址 4655536 functionCode = 4, s7 例如 DB10.DBD4
 	Rate         string      `gorm:"column:rate;type:varchar(32)"  json:"rate"`                             // 比率
 	Offset       string      `gorm:"column:offset;type:varchar(32)"  json:"offset"`                         // 数量
+	Min          string      `gorm:"column:min;type:varchar(32)"  json:"min,omitempty"`                     // 工程值下限
+	Max          string      `gorm:"column:max;type:varchar(32)"  json:"max,omitempty"`                     // 工程值上限
+	Precision    string      `gorm:"column:precision;type:varchar(2)"  json:"precision,omitempty"`          // 工程值保留的小数位
+	Amount       uint        `gorm:"column:amount"  json:"amount,omitempty"`                                // 字符串占用的寄存器数量
 	DefaultValue string      `gorm:"column:default_value;type:varchar(256)"  json:"defaultValue,omitempty"` // 默认值
 	Value        interface{} `gorm:"-" json:"value,omitempty"`                                              // 值
 	AccessMode   string      `gorm:"column:access_mode;type:varchar(2)"  json:"accessMode"`                 // 读写属性
+	MemoryLayout string      `gorm:"column:memory_layout;type:varchar(4)"  json:"memoryLayout,omitempty"`   // 内存布局, 为空时使用设备配置
+	ScanClass    string      `gorm:"column:scan_class;type:varchar(32)"  json:"scanClass,omitempty"`        // 扫描类别, 为空时按设备采集周期采集
+	NodeId       string      `gorm:"column:node_id;type:varchar(256)"  json:"nodeId,omitempty"`             // opcUa节点编号 例如 ns=2;s=Line1.Speed, 为空时使用variable
+	Topic        string      `gorm:"column:topic;type:varchar(256)"  json:"topic,omitempty"`                // mqtt主题过滤器 例如 factory/+/telemetry, 为空时使用variable
+	JsonPath     string      `gorm:"column:json_path;type:varchar(256)"  json:"jsonPath,omitempty"`         // 从JSON负载中取值 例如 $.data.temperature, $topic[1]取主题层级, 为空时使用整个负载
+	Endpoint     string      `gorm:"column:endpoint;type:varchar(1024)"  json:"endpoint,omitempty"`         // http相对请求地址 例如 alarms?level=2&page=1, 为空时使用variable
 	Target       `gorm:"embedded"`
 }
 
@@ -59,10 +69,29 @@ type MappingsQuery struct {
 }
 
 type ModbusAgentDetails struct {
-	Protocol        string `json:"protocol" binding:"required,oneof=modbusTcp modbusRtu modbusRtuOverTcp"`
-	Slave           uint   `json:"slave" binding:"required"`                                  // 下位机号
-	MemoryLayout    string `json:"memoryLayout" binding:"required,oneof=ABCD BADC CDAB DCBA"` // 内存布局 DCBA CDAB BADC ABCD
-	PositionAddress uint   `json:"positionAddress,omitempty"`                                 // 起始地址
+	Protocol        string             `json:"protocol" binding:"required,oneof=modbusTcp modbusRtu modbusRtuOverTcp modbusAscii modbusAsciiOverTcp modbusUdp"`
+	Slave           uint               `json:"slave" binding:"required"`                                       // 下位机号
+	MemoryLayout    string             `json:"memoryLayout" binding:"required,oneof=ABCD BADC CDAB DCBA"`      // 内存布局 DCBA CDAB BADC ABCD
+	PositionAddress uint               `json:"positionAddress,omitempty"`                                      // 起始地址
+	WriteMode       string             `json:"writeMode,omitempty" binding:"omitempty,oneof=batch readWrite"`  // 写入方式 batch:05/06/0F/10 readWrite:17
+	MaskWrite       bool               `json:"maskWrite,omitempty"`                                            // 支持22功能码时位写入使用掩码写, 否则先读后写
+	VerifyWrite     bool               `json:"verifyWrite,omitempty"`                                          // 写入后校验响应回显的地址与数量, 并读回确认值已写入
+	VerifyDelay     uint               `json:"verifyDelay,omitempty"`                                          // 读回前等待的毫秒数
+	WriteRetries    uint               `json:"writeRetries,omitempty"`                                         // 写入超时或校验不一致时的重试次数
+	MaxRegisters    uint               `json:"maxRegisters,omitempty"`                                         // 单次读取的最大寄存器数量, 默认123
+	MaxCoils        uint               `json:"maxCoils,omitempty"`                                             // 单次读取的最大线圈数量, 默认1983
+	MaxGap          *uint              `json:"maxGap,omitempty"`                                               // 合并报文时允许跨越的最大未映射地址数, 为空不限制
+	MaxInFlight     uint               `json:"maxInFlight,omitempty"`                                          // modbusTcp单个连接同时保持的未完成请求数, 大于1时启用流水线
+	MaxConnections  uint               `json:"maxConnections,omitempty"`                                       // 启用流水线时的连接数, 默认1
+	ForbiddenRanges []string           `json:"forbiddenRanges,omitempty"`                                      // 禁止跨越读取的地址段 例如 40100-40120
+	OverrunPolicy   string             `json:"overrunPolicy,omitempty" binding:"omitempty,oneof=skip catchUp"` // 采集超过周期时 skip:丢弃错过的周期 catchUp:立即补采
+	ScanClasses     []*ModbusScanClass `json:"scanClasses,omitempty"`                                          // 扫描类别, 变量按所属类别的周期独立采集
+}
+
+type ModbusScanClass struct {
+	Name           string `json:"name" binding:"required"`                                        // 类别名称
+	CollectorCycle uint   `json:"collectorCycle" binding:"required"`                              // 采集周期毫秒
+	OverrunPolicy  string `json:"overrunPolicy,omitempty" binding:"omitempty,oneof=skip catchUp"` // 为空时使用设备配置
 }
 
 type ModbusAgentAddress struct {
@@ -76,6 +105,115 @@ type ModbusAgentAddressOption struct {
 	DataBits int    `json:"dataBits,omitempty"` // 数据位
 	Parity   string `json:"parity,omitempty"`   // 校验位
 	StopBits string `json:"stopBits,omitempty"` // 停止位
+	// EchoCancel 半双工485适配器会回显发送的字节
+	EchoCancel bool `json:"echoCancel,omitempty"`
+}
+
+// ModbusDiscoveryOptions modbus寄存器扫描参数, 地址与agent的address格式一致
+type ModbusDiscoveryOptions struct {
+	Protocol         string   `json:"protocol" binding:"required,oneof=modbusTcp modbusRtu modbusRtuOverTcp modbusAscii modbusAsciiOverTcp modbusUdp"`
+	Slave            uint     `json:"slave" binding:"required"`
+	Address          JSONMap  `json:"address" binding:"required"`
+	VariableInterval uint     `json:"variableInterval,omitempty"` // 请求间隔毫秒
+	Ranges           []string `json:"ranges,omitempty"`           // 扫描的地址段, 例如 40000-40999
+	MaxRegisters     uint     `json:"maxRegisters,omitempty"`     // 单次读取的最大寄存器数量, 默认123
+	MaxCoils         uint     `json:"maxCoils,omitempty"`         // 单次读取的最大线圈数量, 默认1983
+	Samples          uint     `json:"samples,omitempty"`          // 采样次数, 默认3
+	SampleInterval   uint     `json:"sampleInterval,omitempty"`   // 采样间隔毫秒, 默认500
+	MaxRequests      uint     `json:"maxRequests,omitempty"`      // 请求总数上限, 默认2000
+	MaxTimeouts      uint     `json:"maxTimeouts,omitempty"`      // 地址段连续无响应次数上限, 默认3
+	AgentId          string   `json:"agentId,omitempty"`          // 草稿点位所属的agent
+}
+
+type OpcUaAgentDetails struct {
+	SecurityPolicy     string `json:"securityPolicy,omitempty" binding:"omitempty,oneof=None Basic256Sha256"`      // 安全策略, 默认None
+	SecurityMode       string `json:"securityMode,omitempty" binding:"omitempty,oneof=None Sign SignAndEncrypt"`   // 消息安全模式, 默认与安全策略对应
+	AuthMode           string `json:"authMode,omitempty" binding:"omitempty,oneof=anonymous username certificate"` // 用户认证方式, 默认anonymous
+	Username           string `json:"username,omitempty"`                                                          // 用户名
+	Password           string `json:"password,omitempty"`                                                          // 密码
+	CertificateFile    string `json:"certificateFile,omitempty"`                                                   // 应用实例证书文件 PEM或DER
+	PrivateKeyFile     string `json:"privateKeyFile,omitempty"`                                                    // 证书私钥文件 PEM或DER
+	ServerCertificate  string `json:"serverCertificate,omitempty"`                                                 // 信任的服务器证书或CA证书文件 PEM或DER
+	ServerThumbprint   string `json:"serverThumbprint,omitempty"`                                                  // 信任的服务器证书SHA1指纹 十六进制, 安全策略非None时与serverCertificate至少配置一项
+	CollectMode        string `json:"collectMode,omitempty" binding:"omitempty,oneof=subscription poll"`           // 采集方式 subscription:订阅监控项 poll:按采集周期读取, 默认subscription
+	PublishingInterval uint   `json:"publishingInterval,omitempty"`                                                // 订阅发布周期毫秒, 默认与采集周期相同
+	SamplingInterval   uint   `json:"samplingInterval,omitempty"`                                                  // 监控项采样周期毫秒, 默认与发布周期相同
+	Timeout            uint   `json:"timeout,omitempty"`                                                           // 请求超时毫秒, 默认5000
+	OverrunPolicy      string `json:"overrunPolicy,omitempty" binding:"omitempty,oneof=skip catchUp"`              // 轮询超过周期时 skip:丢弃错过的周期 catchUp:立即补采
+}
+
+type OpcUaAgentAddress struct {
+	Endpoint string `json:"endpoint" binding:"required"` // 服务器端点 opc.tcp://host:port/path
+}
+
+// OpcUaBrowseOptions opcUa地址空间浏览参数, 地址与连接参数与agent的格式一致
+type OpcUaBrowseOptions struct {
+	Address      JSONMap `json:"address" binding:"required"`
+	AgentDetails JSONMap `json:"agentDetails,omitempty"`
+	RootNodeId   string  `json:"rootNodeId,omitempty"` // 浏览的起始节点, 默认Objects文件夹 i=85
+	MaxDepth     uint    `json:"maxDepth,omitempty"`   // 最大浏览深度, 默认5
+	MaxNodes     uint    `json:"maxNodes,omitempty"`   // 最多生成的点位数量, 默认1000
+	AgentId      string  `json:"agentId,omitempty"`    // 草稿点位所属的agent
+}
+
+type HttpAgentDetails struct {
+	Method             string            `json:"method,omitempty" binding:"omitempty,oneof=GET POST PUT PATCH"`  // 轮询请求方法, 默认GET
+	Headers            map[string]string `json:"headers,omitempty"`                                              // 请求头
+	AuthMode           string            `json:"authMode,omitempty" binding:"omitempty,oneof=none basic bearer"` // 认证方式, 默认none
+	Username           string            `json:"username,omitempty"`                                             // basic认证用户名
+	Password           string            `json:"password,omitempty"`                                             // basic认证密码
+	Token              string            `json:"token,omitempty"`                                                // bearer认证令牌
+	Body               string            `json:"body,omitempty"`                                                 // 轮询请求体模板 text/template, 可使用 .Timestamp .Now
+	ContentType        string            `json:"contentType,omitempty"`                                          // 请求体类型, 默认application/json
+	WriteUrl           string            `json:"writeUrl,omitempty"`                                             // 写入地址, 可为相对地址, 包含{name}时每个变量单独请求, 默认与轮询地址相同
+	WriteMethod        string            `json:"writeMethod,omitempty" binding:"omitempty,oneof=POST PUT PATCH"` // 写入请求方法, 默认POST
+	WriteBody          string            `json:"writeBody,omitempty"`                                            // 写入请求体模板, 可使用 .Name .Value .Values, 默认JSON编码的值
+	CaFile             string            `json:"caFile,omitempty"`                                               // 校验服务端证书的CA文件 PEM
+	InsecureSkipVerify bool              `json:"insecureSkipVerify,omitempty"`                                   // 不校验服务端证书
+	Timeout            uint              `json:"timeout,omitempty"`                                              // 请求超时毫秒, 默认5000
+	OverrunPolicy      string            `json:"overrunPolicy,omitempty" binding:"omitempty,oneof=skip catchUp"` // 采集超过周期时 skip:丢弃错过的周期 catchUp:立即补采
+}
+
+type HttpAgentAddress struct {
+	Url string `json:"url" binding:"required"` // 轮询地址 http://host:port/path, 点位的variable为相对地址时以此为基准
+}
+
+type S7AgentDetails struct {
+	Rack           uint   `json:"rack"`                                                           // 机架号, S7-300/1200/1500通常为0
+	Slot           uint   `json:"slot"`                                                           // 槽号, S7-300通常为2, S7-1200/1500通常为1
+	ConnectionType string `json:"connectionType,omitempty" binding:"omitempty,oneof=pg op basic"` // 连接资源类型, 默认pg
+	LocalTSAP      string `json:"localTsap,omitempty"`                                            // 本地TSAP 4位十六进制, 默认0100
+	RemoteTSAP     string `json:"remoteTsap,omitempty"`                                           // 远端TSAP 4位十六进制, 配置后忽略机架号与槽号
+	PduSize        uint   `json:"pduSize,omitempty"`                                              // 请求的PDU长度240-960, 默认960, 实际长度由PLC协商
+	MaxGap         *uint  `json:"maxGap,omitempty"`                                               // 合并读取时允许跨越的最大未映射字节数, 默认32
+	Timeout        uint   `json:"timeout,omitempty"`                                              // 请求超时毫秒, 默认3000
+	OverrunPolicy  string `json:"overrunPolicy,omitempty" binding:"omitempty,oneof=skip catchUp"` // 采集超过周期时 skip:丢弃错过的周期 catchUp:立即补采
+}
+
+type MQTTAgentDetails struct {
+	ClientId           string   `json:"clientId,omitempty"`                            // 客户端标识, 默认harns-agentId
+	Username           string   `json:"username,omitempty"`                            // 用户名
+	Password           string   `json:"password,omitempty"`                            // 密码
+	CleanSession       *bool    `json:"cleanSession,omitempty"`                        // 断开后清除会话, 默认true
+	KeepAlive          uint     `json:"keepAlive,omitempty"`                           // 保持连接秒, 默认60
+	Qos                uint8    `json:"qos,omitempty" binding:"omitempty,oneof=0 1 2"` // 订阅与发布命令的QoS, 默认0
+	Topics             []string `json:"topics,omitempty"`                              // 订阅的主题过滤器, 默认订阅点位的主题
+	CommandTopic       string   `json:"commandTopic,omitempty"`                        // 写入时发布的主题, 包含{name}时每个变量单独发布
+	CaFile             string   `json:"caFile,omitempty"`                              // 校验broker证书的CA文件 PEM
+	CertificateFile    string   `json:"certificateFile,omitempty"`                     // 双向认证的客户端证书文件 PEM
+	PrivateKeyFile     string   `json:"privateKeyFile,omitempty"`                      // 客户端证书私钥文件 PEM
+	InsecureSkipVerify bool     `json:"insecureSkipVerify,omitempty"`                  // 不校验broker证书
+	Timeout            uint     `json:"timeout,omitempty"`                             // 请求超时毫秒, 默认5000
+	ReconnectInterval  uint     `json:"reconnectInterval,omitempty"`                   // 断开后的重连间隔毫秒, 默认5000
+}
+
+type MQTTAgentAddress struct {
+	Url string `json:"url" binding:"required"` // broker地址 tcp://host:1883, ssl://host:8883使用TLS
+}
+
+type S7AgentAddress struct {
+	Host string `json:"host" binding:"required"` // PLC地址
+	Port int    `json:"port,omitempty"`          // 端口号, 默认102
 }
 
 func (t *Agents) BeforeSave(db *gorm.DB) error {
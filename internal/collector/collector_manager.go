@@ -24,9 +24,22 @@ type Manager struct {
 	brokerReturnCh   map[string]chan *ParseVariableResult
 	stopCh           <-chan struct{}
 	deviceStatusCh   chan string
+	sinks            []VariableSink
 	mux              *sync.Mutex
 }
 
+// WithVariableSink 采集结果同时推送给sink
+func WithVariableSink(sink VariableSink) Option {
+	return func(m *Manager) {
+		m.sinks = append(m.sinks, sink)
+	}
+}
+
+// AddVariableSink 注册sink, 采集协程读取sinks时不加锁, 须在Run之前调用
+func (m *Manager) AddVariableSink(sink VariableSink) {
+	WithVariableSink(sink)(m)
+}
+
 func NewManager(mm *ModelManager, ts *TimeSeriesManager, tsStore bool, stop <-chan struct{}, opts ...Option) *Manager {
 	m := &Manager{
 		devices:          &sync.Map{},
@@ -46,18 +59,31 @@ func NewManager(mm *ModelManager, ts *TimeSeriesManager, tsStore bool, stop <-ch
 	return m
 }
 
+// Init 加载模型, 北向网关等依赖物模型的sink在Init之后、Run之前创建
 func (m *Manager) Init() {
 	// devices, _ := m.store.LoadResource()
 	m.mm.Init()
 	if m.tsStore {
 		m.ts.Init()
 	}
+}
 
+// Run 开始采集全部设备
+func (m *Manager) Run() {
 	// m.agents = m.mm.GetAgents()
 
 	m.mm.GetAgents().Range(func(key, value any) bool {
 		agents := value.(*biz.Agents)
-		device := ConvertDeviceMap[agents.AgentType](agents)
+		driver, ok := GetDriver(agents.AgentType)
+		if !ok {
+			klog.V(2).InfoS("Unsupported agent type", "agentsId", agents.Id, "agentType", agents.AgentType)
+			return true
+		}
+		device, err := driver.ConvertDevice(agents, agents.Mappings)
+		if err != nil {
+			klog.V(2).InfoS("Failed to convert agents to device", "agentsId", agents.Id, "error", err)
+			return true
+		}
 		device.IndexDevice()
 		m.devices.Store(device.GetID(), device)
 
@@ -133,47 +159,88 @@ func (m *Manager) SwitchDeviceStatus(id string, status string) error {
 	return nil
 }
 
-// func (m *Manager) DeliverAction(id string, actions []map[string]interface{}) error {
-// 	device, err := m.GetDeviceById(id, true)
-// 	if err != nil {
-// 		klog.V(2).InfoS("Failed to find device", "deviceId", id)
-// 		return response.NewMultiError(response.ErrDeviceNotFound(id))
-// 	}
-//
-// 	errs := &response.MultiError{}
-// 	legalActions := make(map[string]interface{}, 0)
-// 	for _, item := range actions {
-// 		for k, v := range item {
-// 			if _, exist := legalActions[k]; exist {
-// 				errs.Add(response.ErrResourceExists(k))
-// 				continue
-// 			}
-// 			if v, ok := device.GetVariable(k); !ok {
-// 				errs.Add(response.ErrResourceNotFound(k))
-// 				continue
-// 			} else if v.GetVariableAccessMode() != constant.AccessModeReadWrite {
-// 				errs.Add(response.ErrResourceNotFound(k))
-// 				continue
-// 			}
-// 			legalActions[k] = v
-// 		}
-// 	}
-//
-// 	if errs.Len() > 0 {
-// 		return errs
-// 	}
-//
-// 	if len(legalActions) == 0 {
-// 		return response.NewMultiError(response.ErrLegalActionNotFound)
-// 	}
-//
-// 	if device.GetCollectStatus() ==  CollectStatusToString[ Unconnected] {
-// 		klog.V(2).InfoS("Failed to connect device", "deviceId", id)
-// 		return response.NewMultiError(response.ErrDeviceNotConnect(id))
-// 	}
-//
-// 	return m.brokers[id].DeliverAction(context.Background(), legalActions)
-// }
+func (m *Manager) DeliverAction(id string, actions map[string]interface{}) ([]*ActionResult, error) {
+	device, err := m.GetDeviceById(id, true)
+	if err != nil {
+		klog.V(2).InfoS("Failed to find device", "deviceId", id)
+		return nil, err
+	}
+	if len(actions) == 0 {
+		return nil, ErrLegalActionNotFound
+	}
+	if device.GetCollectStatus() == CollectStatusToString[Unconnected] {
+		klog.V(2).InfoS("Failed to connect device", "deviceId", id)
+		return nil, ErrConnectDevice
+	}
+
+	m.mux.Lock()
+	broker, ok := m.brokers[id]
+	m.mux.Unlock()
+	if !ok {
+		return nil, ErrDeviceNotCollecting
+	}
+	return broker.DeliverAction(context.Background(), actions)
+}
+
+func (m *Manager) GetStatistics(id string) (interface{}, error) {
+	diagnoser, err := m.getDiagnoser(id)
+	if err != nil {
+		return nil, err
+	}
+	return diagnoser.GetStatistics(), nil
+}
+
+func (m *Manager) ResetStatistics(id string) error {
+	diagnoser, err := m.getDiagnoser(id)
+	if err != nil {
+		return err
+	}
+	diagnoser.ResetStatistics()
+	return nil
+}
+
+// Diagnose 向设备发送诊断请求, 与采集共用连接池
+func (m *Manager) Diagnose(ctx context.Context, id string) (interface{}, error) {
+	diagnoser, err := m.getDiagnoser(id)
+	if err != nil {
+		return nil, err
+	}
+	return diagnoser.Diagnose(ctx)
+}
+
+func (m *Manager) getDiagnoser(id string) (Diagnoser, error) {
+	if _, err := m.GetDeviceById(id, true); err != nil {
+		klog.V(2).InfoS("Failed to find device", "deviceId", id)
+		return nil, err
+	}
+	m.mux.Lock()
+	broker, ok := m.brokers[id]
+	m.mux.Unlock()
+	if !ok {
+		return nil, ErrDeviceNotCollecting
+	}
+	diagnoser, ok := broker.(Diagnoser)
+	if !ok {
+		return nil, ErrDiagnosticsUnsupported
+	}
+	return diagnoser, nil
+}
+
+// ResolveProperty 按物模型属性查找映射的设备与变量
+func (m *Manager) ResolveProperty(thingId, property string) (string, string, bool) {
+	var deviceId, variable string
+	m.mm.GetAgents().Range(func(key, value any) bool {
+		agents := value.(*biz.Agents)
+		for _, mapping := range agents.Mappings {
+			if mapping.ThingId == thingId && mapping.Property == property {
+				deviceId, variable = agents.Id, mapping.Name
+				return false
+			}
+		}
+		return true
+	})
+	return deviceId, variable, len(deviceId) > 0
+}
 
 func (m Manager) cancelCollect(obj Device) error {
 	m.mux.Lock()
@@ -193,7 +260,11 @@ func (m Manager) cancelCollect(obj Device) error {
 }
 
 func (m *Manager) readyCollect(obj Device) error {
-	broker, results, err := DeviceTypeBrokerMap[obj.GetDeviceType()](obj)
+	driver, ok := GetDriver(obj.GetDeviceType())
+	if !ok {
+		return ErrDeviceType
+	}
+	broker, results, err := driver.NewBroker(obj)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrConnectDevice):
@@ -229,6 +300,11 @@ func (m *Manager) readyCollect(obj Device) error {
 				}
 			case pvr, ok := <-results:
 				if ok {
+					if len(pvr.VariableSlice) > 0 {
+						for _, sink := range m.sinks {
+							sink.Publish(deviceId, pvr.VariableSlice)
+						}
+					}
 					if v, ok := m.devices.Load(deviceId); ok {
 						if len(pvr.Err) == 0 {
 							if v.(Device).GetCollectStatus() !=  CollectStatusToString[ Collecting] {
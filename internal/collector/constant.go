@@ -6,10 +6,13 @@ import (
 )
 
 var (
-	ErrDeviceType          = errors.New("unsupported device type")
-	ErrConnectDevice       = errors.New("unable to connect to device")
-	ErrDeviceServerClosed  = errors.New("device server closed")
-	ErrDeviceEmptyVariable = errors.New("device variable emptied")
+	ErrDeviceType             = errors.New("unsupported device type")
+	ErrConnectDevice          = errors.New("unable to connect to device")
+	ErrDeviceServerClosed     = errors.New("device server closed")
+	ErrDeviceEmptyVariable    = errors.New("device variable emptied")
+	ErrDeviceNotCollecting    = errors.New("device not collecting")
+	ErrLegalActionNotFound    = errors.New("legal action not found")
+	ErrDiagnosticsUnsupported = errors.New("device diagnostics unsupported")
 )
 
 var heartBeatTimeInterval = 15 * time.Second
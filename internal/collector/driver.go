@@ -0,0 +1,115 @@
+package collector
+
+import (
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/biz"
+	"sort"
+	"sync"
+)
+
+/**
+协议驱动
+每种agent类型对应一个驱动, 负责agents解码与校验、mappings校验、agents转换为设备以及创建Broker
+驱动在自身包的init中调用Register注册, 二进制通过引入harnsplatform/internal/collector/drivers加载全部内置驱动
+*/
+
+// Driver 协议驱动
+type Driver interface {
+	AgentsManager
+	// AgentType agents的类型, 例如modbus
+	AgentType() string
+	Capabilities() DriverCapabilities
+	// NewAgents 返回空的agents, 用于解码创建与更新请求
+	NewAgents() pb.Agents
+	ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (Device, error)
+	NewBroker(device Device) (Broker, chan *ParseVariableResult, error)
+}
+
+// DriverBase 由协议包声明的agent类型、能力与构造函数组成的驱动
+type DriverBase struct {
+	AgentsManager
+	Type              string
+	Caps              DriverCapabilities
+	NewAgentsFunc     func() pb.Agents
+	ConvertDeviceFunc func(agents *biz.Agents, mappings []*biz.Mapping) (Device, error)
+	NewBrokerFunc     func(device Device) (Broker, chan *ParseVariableResult, error)
+}
+
+var _ Driver = (*DriverBase)(nil)
+
+func (d *DriverBase) AgentType() string {
+	return d.Type
+}
+
+func (d *DriverBase) Capabilities() DriverCapabilities {
+	return d.Caps
+}
+
+func (d *DriverBase) NewAgents() pb.Agents {
+	return d.NewAgentsFunc()
+}
+
+func (d *DriverBase) ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (Device, error) {
+	return d.ConvertDeviceFunc(agents, mappings)
+}
+
+func (d *DriverBase) NewBroker(device Device) (Broker, chan *ParseVariableResult, error) {
+	return d.NewBrokerFunc(device)
+}
+
+// DriverCapabilities 驱动支持的能力
+type DriverCapabilities struct {
+	Write       bool `json:"write"`       // 下发写入
+	Discovery   bool `json:"discovery"`   // 扫描下位机生成草稿点位
+	Diagnostics bool `json:"diagnostics"` // 通讯统计与诊断
+	FramePlan   bool `json:"framePlan"`   // 采集报文规划
+}
+
+type DriverInfo struct {
+	AgentType    string             `json:"agentType"`
+	Capabilities DriverCapabilities `json:"capabilities"`
+}
+
+var (
+	driversMux sync.RWMutex
+	drivers    = make(map[string]Driver)
+)
+
+// Register 注册驱动, agent类型为空或重复注册时panic
+func Register(driver Driver) {
+	driversMux.Lock()
+	defer driversMux.Unlock()
+	if driver == nil {
+		panic("collector: Register driver is nil")
+	}
+	agentType := driver.AgentType()
+	if len(agentType) == 0 {
+		panic("collector: Register driver with empty agent type")
+	}
+	if _, dup := drivers[agentType]; dup {
+		panic("collector: Register called twice for driver " + agentType)
+	}
+	drivers[agentType] = driver
+	pb.RegisterAgentType(agentType, driver.NewAgents)
+}
+
+func GetDriver(agentType string) (Driver, bool) {
+	driversMux.RLock()
+	defer driversMux.RUnlock()
+	driver, ok := drivers[agentType]
+	return driver, ok
+}
+
+// ListDrivers 按agent类型排序返回已注册的驱动
+func ListDrivers() []*DriverInfo {
+	driversMux.RLock()
+	defer driversMux.RUnlock()
+	infos := make([]*DriverInfo, 0, len(drivers))
+	for agentType, driver := range drivers {
+		infos = append(infos, &DriverInfo{AgentType: agentType, Capabilities: driver.Capabilities()})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].AgentType < infos[j].AgentType
+	})
+	return infos
+}
@@ -0,0 +1,10 @@
+// Package drivers 引入全部内置协议驱动, 驱动在init中向collector注册
+package drivers
+
+import (
+	_ "harnsplatform/internal/collector/modbus"
+	_ "harnsplatform/internal/collector/mqtt"
+	_ "harnsplatform/internal/collector/opcua"
+	_ "harnsplatform/internal/collector/rest"
+	_ "harnsplatform/internal/collector/s7"
+)
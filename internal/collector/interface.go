@@ -8,5 +8,20 @@ import (
 
 type AgentsManager interface {
 	CreateAgents(ctx context.Context, agents pb.Agents) (*biz.Agents, error)
-	ValidateMappings(ctx context.Context, mappings []*biz.Mapping) error
+	// ValidateMappings 校验agents的全部mappings, 包括已保存与新增的mappings
+	ValidateMappings(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) error
+	// GetFramePlan 返回agents按当前配置计算出的采集报文规划, 用于调试
+	GetFramePlan(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) (interface{}, error)
+}
+
+// VariableSink 接收设备的采集结果, 例如北向网关
+type VariableSink interface {
+	Publish(deviceId string, values []VariableValue)
+}
+
+// Diagnoser 支持通讯统计与诊断的Broker
+type Diagnoser interface {
+	GetStatistics() interface{}
+	ResetStatistics()
+	Diagnose(ctx context.Context) (interface{}, error)
 }
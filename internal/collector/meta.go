@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"harnsplatform/internal/biz"
 	"harnsplatform/internal/common"
@@ -61,7 +62,35 @@ var StringToDeviceStatusCh = map[string]DeviceStatusCh{
 type Broker interface {
 	Collect(ctx context.Context)
 	Destroy(ctx context.Context)
-	DeliverAction(ctx context.Context, obj map[string]interface{}) error
+	DeliverAction(ctx context.Context, obj map[string]interface{}) ([]*ActionResult, error)
+}
+
+// 下发结果状态
+const (
+	ActionSuccess  = "success"
+	ActionMismatch = "mismatch" // 响应回显或读回的值与写入值不一致
+	ActionTimeout  = "timeout"
+	ActionFailed   = "failed"
+)
+
+// ActionResult 单个变量的下发结果,Err为空表示写入成功
+type ActionResult struct {
+	Name   string      `json:"name"`
+	Value  interface{} `json:"value,omitempty"`
+	Status string      `json:"status,omitempty"`
+	Err    error       `json:"-"`
+}
+
+func (ar *ActionResult) MarshalJSON() ([]byte, error) {
+	type result ActionResult
+	v := struct {
+		*result
+		Error string `json:"error,omitempty"`
+	}{result: (*result)(ar)}
+	if ar.Err != nil {
+		v.Error = ar.Err.Error()
+	}
+	return json.Marshal(v)
 }
 
 type VariableValue interface {
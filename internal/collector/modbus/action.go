@@ -0,0 +1,839 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/model"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils"
+	"harnsplatform/internal/utils/binutils"
+	"k8s.io/klog/v2"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/**
+写报文pdu
+05 单个线圈     功能码(1) + 地址(2) + FF00/0000(2)
+06 单个寄存器   功能码(1) + 地址(2) + 值(2)
+0F 多个线圈     功能码(1) + 地址(2) + 数量(2) + 字节数(1) + 值(N)
+10 多个寄存器   功能码(1) + 地址(2) + 数量(2) + 字节数(1) + 值(2N)
+16 掩码写寄存器 功能码(1) + 地址(2) + AND掩码(2) + OR掩码(2)
+17 读写多个寄存器 功能码(1) + 读地址(2) + 读数量(2) + 写地址(2) + 写数量(2) + 字节数(1) + 值(2N)
+05 06 0F 10 响应pdu固定5个字节, 16 响应pdu为请求回显7个字节, 17 响应pdu = 功能码(1) + 字节数(1) + 值(2N)
+开启写入校验时, 校验响应回显的地址、数量与值, 再以01/03读回写入的地址逐个变量比较, 17直接比较响应中读回的值
+超时、校验不一致或从站忙时按writeRetries重试整个报文
+*/
+
+// DeliverAction 返回每个变量的下发结果,存在失败时error为按变量名汇总的collector.MultiError
+func (broker *ModbusBroker) DeliverAction(ctx context.Context, obj map[string]interface{}) ([]*collector.ActionResult, error) {
+	results := make([]*collector.ActionResult, 0, len(obj))
+	resultMap := make(map[string]*collector.ActionResult, len(obj))
+	action := make([]*runtime.Variable, 0, len(obj))
+
+	for name, value := range obj {
+		result := &collector.ActionResult{Name: name, Value: value}
+		results = append(results, result)
+		resultMap[name] = result
+
+		vv, exist := broker.Device.GetVariable(name)
+		if !exist {
+			result.Err = runtime.ErrVariableNotFound
+			continue
+		}
+		variableValue := vv.(*runtime.Variable)
+		if variableValue.AccessMode != common.AccessModeReadWrite {
+			result.Err = runtime.ErrVariableReadOnly
+			continue
+		}
+
+		v := &runtime.Variable{
+			DataType:     variableValue.DataType,
+			Name:         variableValue.Name,
+			Address:      variableValue.Address,
+			Bits:         variableValue.Bits,
+			BitAddress:   variableValue.BitAddress,
+			FunctionCode: variableValue.FunctionCode,
+			Rate:         variableValue.Rate,
+			OffSet:       variableValue.OffSet,
+			Min:          variableValue.Min,
+			Max:          variableValue.Max,
+			Precision:    variableValue.Precision,
+			Amount:       variableValue.Amount,
+			AccessMode:   variableValue.AccessMode,
+			MemoryLayout: variableValue.MemoryLayout,
+		}
+		actionValue, err := convertActionValue(variableValue, value)
+		if err != nil {
+			klog.V(3).InfoS("Failed to convert action value", "variableName", name, "dataType", variableValue.DataType, "error", err)
+			result.Err = err
+			continue
+		}
+		v.Value = actionValue
+		action = append(action, v)
+	}
+
+	frames := broker.generateActionFrames(broker.Device.MemoryLayout, action, resultMap)
+	if len(frames) == 0 {
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	messenger, err := broker.Clients.GetMessenger(ctx)
+	if err != nil {
+		klog.V(2).InfoS("Failed to get Modbus messenger", "error", err)
+		if messenger, err = broker.Clients.NewMessenger(); err != nil {
+			klog.V(2).InfoS("Failed to create Modbus messenger", "error", err)
+			// 待下发的变量均以连接错误返回, 已校验失败的变量保留原错误
+			for _, frame := range frames {
+				setActionFrameResult(resultMap, frame, err)
+			}
+			setActionResultStatus(results)
+			return results, collector.NewActionMultiError(results)
+		}
+	}
+	defer broker.Clients.ReleaseMessenger(messenger)
+
+	var transactionId uint16
+	for _, frame := range frames {
+		var mismatched map[string]error
+		for attempt := uint(0); ; attempt++ {
+			mismatched, err = broker.deliverFrame(messenger, frame, resultMap, &transactionId)
+			if attempt >= broker.Device.WriteRetries || !retryableAction(err, mismatched) {
+				break
+			}
+			klog.V(3).InfoS("Retry to deliver Modbus action", "functionCode", frame.FunctionCode, "startAddress", frame.StartAddress, "attempt", attempt+1, "error", err)
+			var me *runtime.ModbusException
+			if err != nil && !errors.As(err, &me) {
+				// 超时后迟到的响应会与重试的请求错位, 重建连接
+				if err := broker.reconnect(messenger); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			setActionFrameResult(resultMap, frame, err)
+			continue
+		}
+		for name, e := range mismatched {
+			resultMap[name].Err = e
+		}
+	}
+
+	setActionResultStatus(results)
+	return results, collector.NewActionMultiError(results)
+}
+
+// deliverFrame 下发单个写报文, 开启写入校验时校验响应回显并读回寄存器, 返回读回值不一致的变量
+func (broker *ModbusBroker) deliverFrame(messenger runtime.Messenger, frame *runtime.ModBusActionFrame, resultMap map[string]*collector.ActionResult, transactionId *uint16) (map[string]error, error) {
+	if frame.ReadModifyWrite {
+		// 先读取寄存器当前值, 仅修改变量占用的位后写回
+		*transactionId++
+		readPdu := make([]byte, 5)
+		readPdu[0] = byte(runtime.ReadHoldRegister)
+		binutils.WriteUint16BigEndian(readPdu[1:], uint16(frame.StartAddress))
+		binutils.WriteUint16BigEndian(readPdu[3:], 1)
+		pdu, err := broker.askAction(messenger, readPdu, 4, *transactionId)
+		if err != nil {
+			return nil, err
+		}
+		register := runtime.ParseRegisterUint16(frame.MemoryLayout, pdu[2:])
+		value := (register & frame.AndMask) | (frame.OrMask &^ frame.AndMask)
+		copy(frame.Pdu[3:], runtime.RegisterUint16ToBytes(frame.MemoryLayout, value))
+	}
+
+	*transactionId++
+	pdu, err := broker.askAction(messenger, frame.Pdu, frame.ResponsePduLength, *transactionId)
+	if err != nil {
+		return nil, err
+	}
+	if broker.Device.VerifyWrite && !matchActionEcho(frame, pdu) {
+		klog.V(2).InfoS("Failed to match Modbus action echo", "request", frame.Pdu, "response", pdu)
+		return nil, runtime.ErrWriteEchoMismatch
+	}
+
+	var readBack []byte
+	if runtime.FunctionCode(frame.FunctionCode) == runtime.ReadWriteMultipleRegister {
+		// 17 写后读回的寄存器值作为结果
+		readBack = pdu[2:]
+		df := &runtime.ModBusDataFrame{
+			MemoryLayout: broker.Device.MemoryLayout,
+			FunctionCode: uint8(runtime.ReadHoldRegister),
+			Variables:    make([]*runtime.VariableParse, 0, len(frame.Variables)),
+		}
+		for _, variable := range frame.Variables {
+			df.Variables = append(df.Variables, &runtime.VariableParse{
+				Variable: variable,
+				Start:    (variable.Address - frame.Variables[0].Address) * 2,
+			})
+		}
+		vvs, errs := df.ParseVariableValue(binutils.Dup(pdu[2:]))
+		for _, vv := range vvs {
+			resultMap[vv.GetVariableName()].Value = vv.GetValue()
+		}
+		for _, err := range errs {
+			var ve *runtime.VariableError
+			if errors.As(err, &ve) {
+				resultMap[ve.Name].Err = ve.Err
+			}
+		}
+	}
+	if !broker.Device.VerifyWrite {
+		return nil, nil
+	}
+
+	if readBack == nil {
+		if broker.Device.VerifyDelay > 0 {
+			time.Sleep(time.Duration(broker.Device.VerifyDelay) * time.Millisecond)
+		}
+		functionCode, byteCount := runtime.ReadHoldRegister, frame.Quantity*2
+		switch runtime.FunctionCode(frame.FunctionCode) {
+		case runtime.WriteSingleCoil, runtime.WriteMultipleCoil:
+			functionCode, byteCount = runtime.ReadCoilStatus, (frame.Quantity+7)/8
+		}
+		readPdu := make([]byte, 5)
+		readPdu[0] = byte(functionCode)
+		binutils.WriteUint16BigEndian(readPdu[1:], uint16(frame.StartAddress))
+		binutils.WriteUint16BigEndian(readPdu[3:], uint16(frame.Quantity))
+		*transactionId++
+		pdu, err := broker.askAction(messenger, readPdu, 2+int(byteCount), *transactionId)
+		if err != nil {
+			return nil, err
+		}
+		readBack = pdu[2:]
+	}
+	return compareReadBack(frame, readBack), nil
+}
+
+// matchActionEcho 05 06 16 响应为请求的回显, 0F 10 响应回显地址与数量, 17 响应字节数为读数量的两倍
+func matchActionEcho(frame *runtime.ModBusActionFrame, pdu []byte) bool {
+	switch runtime.FunctionCode(frame.FunctionCode) {
+	case runtime.WriteSingleCoil, runtime.WriteSingleRegister, runtime.MaskWriteRegister:
+		return bytes.Equal(pdu, frame.Pdu)
+	case runtime.WriteMultipleCoil, runtime.WriteMultipleRegister:
+		return bytes.Equal(pdu[1:5], frame.Pdu[1:5])
+	case runtime.ReadWriteMultipleRegister:
+		return uint(pdu[1]) == frame.Quantity*2
+	}
+	return true
+}
+
+// compareReadBack 按变量比较读回的值与写入的值
+func compareReadBack(frame *runtime.ModBusActionFrame, readBack []byte) map[string]error {
+	mismatched := make(map[string]error, 0)
+	switch runtime.FunctionCode(frame.FunctionCode) {
+	case runtime.WriteSingleCoil, runtime.WriteMultipleCoil:
+		bits := binutils.ExpandBool(readBack, len(readBack))
+		for i, variable := range frame.Variables {
+			if (bits[i] == 1) != coilValue(variable.Value) {
+				mismatched[variable.Name] = runtime.ErrWriteReadBackMismatch
+			}
+		}
+		return mismatched
+	}
+
+	if frame.ReadModifyWrite || runtime.FunctionCode(frame.FunctionCode) == runtime.MaskWriteRegister {
+		register := runtime.ParseRegisterUint16(frame.MemoryLayout, readBack)
+		for _, variable := range frame.Variables {
+			if register&variable.BitMask() != frame.OrMask&variable.BitMask() {
+				mismatched[variable.Name] = runtime.ErrWriteReadBackMismatch
+			}
+		}
+		return mismatched
+	}
+
+	// 06 10 17 写入的寄存器字节位于pdu末尾
+	written := frame.Pdu[len(frame.Pdu)-int(frame.Quantity)*2:]
+	for _, variable := range frame.Variables {
+		start := (variable.Address - frame.Variables[0].Address) * 2
+		end := min(start+variable.Words()*2, uint(len(written)))
+		if !bytes.Equal(readBack[start:end], written[start:end]) {
+			mismatched[variable.Name] = runtime.ErrWriteReadBackMismatch
+		}
+	}
+	return mismatched
+}
+
+// retryableAction 超时、连接异常、响应错误、校验不一致以及从站忙时可重试
+func retryableAction(err error, mismatched map[string]error) bool {
+	if err == nil {
+		return len(mismatched) > 0
+	}
+	var me *runtime.ModbusException
+	if errors.As(err, &me) {
+		return me.ExceptionCode == runtime.SlaveDeviceBusy || me.ExceptionCode == runtime.Acknowledge
+	}
+	return true
+}
+
+// setActionResultStatus 根据错误设置每个变量的下发状态
+func setActionResultStatus(results []*collector.ActionResult) {
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			result.Status = collector.ActionSuccess
+		case errors.Is(result.Err, runtime.ErrWriteEchoMismatch), errors.Is(result.Err, runtime.ErrWriteReadBackMismatch):
+			result.Status = collector.ActionMismatch
+		case errors.Is(result.Err, runtime.ErrModbusTimeout):
+			result.Status = collector.ActionTimeout
+		default:
+			result.Status = collector.ActionFailed
+		}
+	}
+}
+
+func (broker *ModbusBroker) reconnect(messenger runtime.Messenger) error {
+	messenger.Close()
+	newMessenger, err := broker.Clients.NewMessenger()
+	if err != nil {
+		klog.V(2).InfoS("Failed to reconnect Modbus device", "error", err)
+		return err
+	}
+	messenger.Reset(newMessenger)
+	broker.Statistics.Reconnect(nil)
+	return nil
+}
+
+// askAction 发送单个写相关请求并校验响应的功能码与长度,返回响应pdu
+func (broker *ModbusBroker) askAction(messenger runtime.Messenger, pdu []byte, responsePduLength int, transactionId uint16) ([]byte, error) {
+	responsePdu, err := broker.exchange(messenger, pdu, responsePduLength, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	if len(responsePdu) != responsePduLength {
+		klog.V(2).InfoS("Failed to match Modbus action response", "request functionCode", pdu[0], "response functionCode", responsePdu[0])
+		return nil, runtime.ErrModbusServerBadResp
+	}
+	return responsePdu, nil
+}
+
+// exchange 发送一个pdu并返回校验后的响应pdu, responsePduLength为响应pdu的最大长度
+// 等待响应超时返回ErrModbusTimeout, 其余收发错误返回ErrModbusBadConn, 不校验响应pdu长度
+func (broker *ModbusBroker) exchange(messenger runtime.Messenger, pdu []byte, responsePduLength int, transactionId uint16) ([]byte, error) {
+	request := broker.generateActionMessage(pdu, transactionId)
+	response := make([]byte, broker.actionResponseLength(responsePduLength))
+	n, latency, err := broker.FrameGap.Ask(messenger, request, response, runtime.RtuExceptionLength)
+	if err != nil {
+		klog.V(2).InfoS("Failed to ask Modbus message", "error", err, "functionCode", pdu[0])
+		err = runtime.AskError(err)
+		broker.Statistics.Observe(nil, latency, err)
+		return nil, err
+	}
+	responsePdu, err := broker.ValidateActionResponse(transactionId, response[:n])
+	if err == nil && responsePdu[0] != pdu[0] {
+		klog.V(2).InfoS("Failed to match Modbus response", "request functionCode", pdu[0], "response functionCode", responsePdu[0])
+		err = runtime.ErrModbusServerBadResp
+	}
+	broker.Statistics.Observe(nil, latency, err)
+	if err != nil {
+		return nil, err
+	}
+	return responsePdu, nil
+}
+
+func setActionFrameResult(resultMap map[string]*collector.ActionResult, frame *runtime.ModBusActionFrame, err error) {
+	for _, variable := range frame.Variables {
+		resultMap[variable.Name].Err = err
+	}
+}
+
+// generateActionMessage pdu => adu
+func (broker *ModbusBroker) generateActionMessage(pdu []byte, transactionId uint16) []byte {
+	var bytes []byte
+	if broker.NeedCheckTransaction {
+		bytes = append(bytes, make([]byte, 6)...)
+		binutils.WriteUint16BigEndian(bytes[0:], transactionId)
+		binutils.WriteUint16BigEndian(bytes[2:], 0)
+		binutils.WriteUint16BigEndian(bytes[4:], uint16(1+len(pdu)))
+	}
+	bytes = append(bytes, byte(broker.Device.Slave))
+	bytes = append(bytes, pdu...)
+	if broker.NeedCheckCrc16Sum {
+		crc16 := make([]byte, 2)
+		binutils.WriteUint16BigEndian(crc16, utils.CheckCrc16sum(bytes))
+		bytes = append(bytes, crc16...)
+	}
+	if broker.NeedCheckLrcSum {
+		bytes = model.EncodeAsciiFrame(bytes)
+	}
+	return bytes
+}
+
+// actionResponseLength 根据响应pdu长度计算完整响应报文长度,串口需按精确长度读取
+func (broker *ModbusBroker) actionResponseLength(pduLength int) int {
+	switch {
+	case broker.NeedCheckTransaction:
+		return 7 + pduLength
+	case broker.NeedCheckCrc16Sum:
+		return 3 + pduLength
+	case broker.NeedCheckLrcSum:
+		return 1 + (pduLength+2)*2 + 2
+	default:
+		return 1 + pduLength
+	}
+}
+
+// ValidateActionResponse 校验写响应报文,返回pdu
+func (broker *ModbusBroker) ValidateActionResponse(transactionId uint16, buf []byte) ([]byte, error) {
+	if broker.NeedCheckLrcSum {
+		adu, err := model.DecodeAsciiFrame(buf)
+		if err != nil {
+			klog.V(2).InfoS("Failed to decode Modbus ascii message", "error", err)
+			return nil, err
+		}
+		buf = adu
+	}
+
+	if broker.NeedCheckTransaction {
+		if len(buf) < 6 {
+			return nil, runtime.ErrMessageDataLengthNotEnough
+		}
+		responseTransactionId := binutils.ParseUint16(buf)
+		if responseTransactionId != transactionId {
+			klog.V(2).InfoS("Failed to match Modbus message transaction id", "request transactionId", transactionId, "response transactionId", responseTransactionId)
+			return nil, runtime.ErrMessageTransaction
+		}
+		buf = buf[6:]
+	}
+
+	if broker.NeedCheckCrc16Sum {
+		if len(buf) < 4 {
+			return nil, runtime.ErrMessageDataLengthNotEnough
+		}
+		sum := utils.CheckCrc16sum(buf[:len(buf)-2])
+		crc := binutils.ParseUint16BigEndian(buf[len(buf)-2:])
+		if sum != crc {
+			klog.V(2).InfoS("Failed to check CRC16")
+			return nil, runtime.ErrCRC16Error
+		}
+		buf = buf[:len(buf)-2]
+	}
+
+	if len(buf) < 2 {
+		return nil, runtime.ErrMessageDataLengthNotEnough
+	}
+	slave := buf[0]
+	if uint(slave) != broker.Device.Slave {
+		klog.V(2).InfoS("Failed to match Modbus slave", "request slave", broker.Device.Slave, "response slave", slave)
+		return nil, runtime.ErrMessageSlave
+	}
+	functionCode := buf[1]
+	if functionCode&0x80 > 0 {
+		me := runtime.NewModbusException(buf[1:])
+		klog.V(2).InfoS("Failed to parse Modbus message", "error", me)
+		return nil, me
+	}
+	return buf[1:], nil
+}
+
+// generateActionFrames 按地址排序后合并连续的线圈(0F)与寄存器(10/17),单个点位使用05/06
+func (broker *ModbusBroker) generateActionFrames(memoryLayout common.MemoryLayout, action []*runtime.Variable, resultMap map[string]*collector.ActionResult) []*runtime.ModBusActionFrame {
+	coils := make([]*runtime.Variable, 0)
+	registers := make([]*runtime.Variable, 0)
+	registerBytes := make(map[*runtime.Variable][]byte, len(action))
+	bitRegisters := make(map[uint][]*runtime.Variable)
+	for _, variable := range action {
+		switch runtime.FunctionCode(variable.FunctionCode) {
+		case runtime.ReadCoilStatus:
+			coils = append(coils, variable)
+		case runtime.ReadHoldRegister:
+			if variable.BitAddress {
+				if _, err := bitFieldValue(variable); err != nil {
+					resultMap[variable.Name].Err = err
+					continue
+				}
+				bitRegisters[variable.Address] = append(bitRegisters[variable.Address], variable)
+				continue
+			}
+			dataByte, err := encodeRegisterValue(memoryLayout, variable)
+			if err != nil {
+				klog.V(2).InfoS("Failed to encode register value", "variableName", variable.Name, "error", err)
+				resultMap[variable.Name].Err = err
+				continue
+			}
+			registerBytes[variable] = dataByte
+			registers = append(registers, variable)
+		default:
+			resultMap[variable.Name].Err = runtime.ErrVariableReadOnly
+		}
+	}
+	sort.Stable(runtime.VariableSlice(coils))
+	sort.Stable(runtime.VariableSlice(registers))
+
+	frames := make([]*runtime.ModBusActionFrame, 0)
+	for i := 0; i < len(coils); {
+		j := i + 1
+		for j < len(coils) && j-i < runtime.PerRequestMaxWriteCoil && coils[j].Address == coils[j-1].Address+1 {
+			j++
+		}
+		frames = append(frames, broker.generateCoilActionFrame(coils[i:j]))
+		i = j
+	}
+
+	maxRegister := runtime.PerRequestMaxWriteRegister
+	if broker.Device.WriteMode == runtime.WriteModeReadWrite {
+		maxRegister = runtime.PerRequestMaxReadWriteRegister
+	}
+	for i := 0; i < len(registers); {
+		quantity := len(registerBytes[registers[i]]) / 2
+		j := i + 1
+		for j < len(registers) {
+			previous := registers[j-1]
+			words := len(registerBytes[registers[j]]) / 2
+			if registers[j].Address != previous.Address+uint(len(registerBytes[previous])/2) || quantity+words > maxRegister {
+				break
+			}
+			quantity += words
+			j++
+		}
+		frames = append(frames, broker.generateRegisterActionFrame(registers[i:j], registerBytes))
+		i = j
+	}
+
+	addresses := make([]uint, 0, len(bitRegisters))
+	for address := range bitRegisters {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+	for _, address := range addresses {
+		frames = append(frames, broker.generateBitActionFrame(memoryLayout, bitRegisters[address]))
+	}
+	return frames
+}
+
+// generateBitActionFrame 同一寄存器的位写入合并为一帧, 设备支持时使用22掩码写, 否则先读后写06
+// 寄存器按第一个变量的内存布局编码
+func (broker *ModbusBroker) generateBitActionFrame(memoryLayout common.MemoryLayout, variables []*runtime.Variable) *runtime.ModBusActionFrame {
+	startAddress := variables[0].Address - broker.Device.PositionAddress
+	memoryLayout = variables[0].GetMemoryLayout(memoryLayout)
+	frame := &runtime.ModBusActionFrame{
+		MemoryLayout: memoryLayout,
+		StartAddress: startAddress,
+		Quantity:     1,
+		AndMask:      0xFFFF,
+		Variables:    append(make([]*runtime.Variable, 0, len(variables)), variables...),
+	}
+	for _, variable := range variables {
+		value, _ := bitFieldValue(variable)
+		frame.AndMask &^= variable.BitMask()
+		frame.OrMask |= (value << variable.Bits) & variable.BitMask()
+	}
+
+	if broker.Device.MaskWrite {
+		// 22 设备计算 (当前值 AND andMask) OR (orMask AND NOT andMask), 掩码按内存布局编码
+		frame.FunctionCode = byte(runtime.MaskWriteRegister)
+		frame.ResponsePduLength = 7
+		pdu := make([]byte, 3, 7)
+		pdu[0] = frame.FunctionCode
+		binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+		pdu = append(pdu, runtime.RegisterUint16ToBytes(memoryLayout, frame.AndMask)...)
+		frame.Pdu = append(pdu, runtime.RegisterUint16ToBytes(memoryLayout, frame.OrMask)...)
+		return frame
+	}
+
+	// 寄存器值在读取后填充
+	frame.FunctionCode = byte(runtime.WriteSingleRegister)
+	frame.ResponsePduLength = 5
+	frame.ReadModifyWrite = true
+	pdu := make([]byte, 5)
+	pdu[0] = frame.FunctionCode
+	binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+	frame.Pdu = pdu
+	return frame
+}
+
+func (broker *ModbusBroker) generateCoilActionFrame(variables []*runtime.Variable) *runtime.ModBusActionFrame {
+	startAddress := variables[0].Address - broker.Device.PositionAddress
+	frame := &runtime.ModBusActionFrame{
+		StartAddress:      startAddress,
+		Quantity:          uint(len(variables)),
+		ResponsePduLength: 5,
+		Variables:         append(make([]*runtime.Variable, 0, len(variables)), variables...),
+	}
+	if len(variables) == 1 {
+		// 65280
+		frame.FunctionCode = byte(runtime.WriteSingleCoil)
+		pdu := make([]byte, 5)
+		pdu[0] = frame.FunctionCode
+		binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+		if coilValue(variables[0].Value) {
+			binutils.WriteUint16BigEndian(pdu[3:], uint16(65280))
+		}
+		frame.Pdu = pdu
+		return frame
+	}
+
+	frame.FunctionCode = byte(runtime.WriteMultipleCoil)
+	values := make([]byte, len(variables))
+	for i, variable := range variables {
+		if coilValue(variable.Value) {
+			values[i] = 1
+		}
+	}
+	dataByte := binutils.ShrinkBool(values)
+	pdu := make([]byte, 6, 6+len(dataByte))
+	pdu[0] = frame.FunctionCode
+	binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+	binutils.WriteUint16BigEndian(pdu[3:], uint16(len(variables)))
+	pdu[5] = byte(len(dataByte))
+	frame.Pdu = append(pdu, dataByte...)
+	return frame
+}
+
+func (broker *ModbusBroker) generateRegisterActionFrame(variables []*runtime.Variable, registerBytes map[*runtime.Variable][]byte) *runtime.ModBusActionFrame {
+	startAddress := variables[0].Address - broker.Device.PositionAddress
+	dataByte := make([]byte, 0)
+	for _, variable := range variables {
+		dataByte = append(dataByte, registerBytes[variable]...)
+	}
+	quantity := len(dataByte) / 2
+	frame := &runtime.ModBusActionFrame{
+		StartAddress:      startAddress,
+		Quantity:          uint(quantity),
+		ResponsePduLength: 5,
+		Variables:         append(make([]*runtime.Variable, 0, len(variables)), variables...),
+	}
+
+	switch {
+	case broker.Device.WriteMode == runtime.WriteModeReadWrite:
+		// 写入后读回同一段寄存器
+		frame.FunctionCode = byte(runtime.ReadWriteMultipleRegister)
+		frame.ResponsePduLength = 2 + len(dataByte)
+		pdu := make([]byte, 10, 10+len(dataByte))
+		pdu[0] = frame.FunctionCode
+		binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+		binutils.WriteUint16BigEndian(pdu[3:], uint16(quantity))
+		binutils.WriteUint16BigEndian(pdu[5:], uint16(startAddress))
+		binutils.WriteUint16BigEndian(pdu[7:], uint16(quantity))
+		pdu[9] = byte(len(dataByte))
+		frame.Pdu = append(pdu, dataByte...)
+	case quantity == 1:
+		frame.FunctionCode = byte(runtime.WriteSingleRegister)
+		pdu := make([]byte, 3, 5)
+		pdu[0] = frame.FunctionCode
+		binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+		frame.Pdu = append(pdu, dataByte...)
+	default:
+		frame.FunctionCode = byte(runtime.WriteMultipleRegister)
+		pdu := make([]byte, 6, 6+len(dataByte))
+		pdu[0] = frame.FunctionCode
+		binutils.WriteUint16BigEndian(pdu[1:], uint16(startAddress))
+		binutils.WriteUint16BigEndian(pdu[3:], uint16(quantity))
+		pdu[5] = byte(len(dataByte))
+		frame.Pdu = append(pdu, dataByte...)
+	}
+	return frame
+}
+
+// convertActionValue 将下发的json工程值按换算逆运算转换为变量数据类型的原始值
+func convertActionValue(variable *runtime.Variable, value interface{}) (interface{}, error) {
+	switch variable.DataType {
+	case common.BOOL:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, runtime.ErrActionValueInvalid
+			}
+			return b, nil
+		}
+		return nil, runtime.ErrActionValueInvalid
+	case common.STRING:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, runtime.ErrActionValueInvalid
+	case common.NUMBER:
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+
+	var engineering float64
+	switch v := value.(type) {
+	case float64:
+		engineering = v
+	case string:
+		// 超出float64精度的整数可使用字符串下发
+		if u, err := strconv.ParseUint(v, 10, 64); err == nil && !variable.Scaled() {
+			switch variable.DataType {
+			case common.UINT64, common.BCD16, common.BCD32, common.BCD64:
+				return u, nil
+			}
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, runtime.ErrActionValueInvalid
+		}
+		engineering = f
+	default:
+		return nil, runtime.ErrActionValueInvalid
+	}
+
+	raw := variable.ToRaw(engineering)
+	switch variable.DataType {
+	case common.FLOAT32:
+		return float32(raw), nil
+	case common.FLOAT64:
+		return raw, nil
+	}
+
+	raw = math.Round(raw)
+	switch variable.DataType {
+	case common.INT8:
+		if raw >= math.MinInt8 && raw <= math.MaxInt8 {
+			return int8(raw), nil
+		}
+	case common.UINT8:
+		if raw >= 0 && raw <= math.MaxUint8 {
+			return uint8(raw), nil
+		}
+	case common.INT16:
+		if raw >= math.MinInt16 && raw <= math.MaxInt16 {
+			return int16(raw), nil
+		}
+	case common.UINT16:
+		if raw >= 0 && raw <= math.MaxUint16 {
+			return uint16(raw), nil
+		}
+	case common.INT32:
+		if raw >= math.MinInt32 && raw <= math.MaxInt32 {
+			return int32(raw), nil
+		}
+	case common.UINT32:
+		if raw >= 0 && raw <= math.MaxUint32 {
+			return uint32(raw), nil
+		}
+	case common.INT64:
+		if raw >= math.MinInt64 && raw < math.MaxInt64 {
+			return int64(raw), nil
+		}
+	case common.UINT64, common.BCD16, common.BCD32, common.BCD64:
+		if raw >= 0 && raw < math.MaxUint64 {
+			return uint64(raw), nil
+		}
+	default:
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+	return nil, runtime.ErrActionValueInvalid
+}
+
+// bitFieldValue 位寻址变量的值, 超出位宽时返回错误
+func bitFieldValue(variable *runtime.Variable) (uint16, error) {
+	width := variable.Amount
+	if width == 0 {
+		width = 1
+	}
+	switch v := variable.Value.(type) {
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case int16:
+		if width < 16 && (int(v) < -(1<<(width-1)) || int(v) >= 1<<(width-1)) {
+			return 0, runtime.ErrActionValueInvalid
+		}
+		return uint16(v) & (variable.BitMask() >> variable.Bits), nil
+	case uint16:
+		if width < 16 && int(v) >= 1<<width {
+			return 0, runtime.ErrActionValueInvalid
+		}
+		return v, nil
+	case int8:
+		if int(v) < -(1<<(width-1)) || int(v) >= 1<<(width-1) {
+			return 0, runtime.ErrActionValueInvalid
+		}
+		return uint16(v) & (variable.BitMask() >> variable.Bits), nil
+	case uint8:
+		if int(v) >= 1<<width {
+			return 0, runtime.ErrActionValueInvalid
+		}
+		return uint16(v), nil
+	}
+	return 0, runtime.ErrActionValueInvalid
+}
+
+func coilValue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int8:
+		return v > 0
+	case uint8:
+		return v > 0
+	case int16:
+		return v > 0
+	case uint16:
+		return v > 0
+	case uint32:
+		return v > 0
+	case uint64:
+		return v > 0
+	case int32:
+		return v > 0
+	case int64:
+		return v > 0
+	case float32:
+		return v > 0
+	case float64:
+		return v > 0
+	}
+	return false
+}
+
+// encodeRegisterValue 变量原始值按内存布局编码为寄存器字节, 变量配置的内存布局优先于设备
+func encodeRegisterValue(memoryLayout common.MemoryLayout, variable *runtime.Variable) ([]byte, error) {
+	memoryLayout = variable.GetMemoryLayout(memoryLayout)
+	switch variable.DataType {
+	case common.BOOL:
+		// 非位寻址的bool占用整个寄存器
+		var value uint16
+		if coilValue(variable.Value) {
+			value = 1
+		}
+		return runtime.RegisterUint16ToBytes(memoryLayout, value), nil
+	case common.INT8:
+		return runtime.RegisterUint16ToBytes(memoryLayout, uint16(variable.Value.(int8))), nil
+	case common.UINT8:
+		return runtime.RegisterUint16ToBytes(memoryLayout, uint16(variable.Value.(uint8))), nil
+	case common.INT16:
+		return runtime.RegisterUint16ToBytes(memoryLayout, uint16(variable.Value.(int16))), nil
+	case common.UINT16:
+		return runtime.RegisterUint16ToBytes(memoryLayout, variable.Value.(uint16)), nil
+	case common.INT32:
+		return runtime.RegisterUint32ToBytes(memoryLayout, uint32(variable.Value.(int32))), nil
+	case common.UINT32:
+		return runtime.RegisterUint32ToBytes(memoryLayout, variable.Value.(uint32)), nil
+	case common.INT64:
+		return runtime.RegisterUint64ToBytes(memoryLayout, uint64(variable.Value.(int64))), nil
+	case common.UINT64:
+		return runtime.RegisterUint64ToBytes(memoryLayout, variable.Value.(uint64)), nil
+	case common.FLOAT32:
+		return runtime.RegisterUint32ToBytes(memoryLayout, math.Float32bits(variable.Value.(float32))), nil
+	case common.FLOAT64:
+		return runtime.RegisterUint64ToBytes(memoryLayout, math.Float64bits(variable.Value.(float64))), nil
+	case common.BCD16, common.BCD32, common.BCD64:
+		words := common.DataTypeWord[variable.DataType]
+		bcd, ok := runtime.EncodeBCD(variable.Value.(uint64), int(words*4))
+		if !ok {
+			return nil, runtime.ErrActionValueInvalid
+		}
+		switch words {
+		case 1:
+			return runtime.RegisterUint16ToBytes(memoryLayout, uint16(bcd)), nil
+		case 2:
+			return runtime.RegisterUint32ToBytes(memoryLayout, uint32(bcd)), nil
+		default:
+			return runtime.RegisterUint64ToBytes(memoryLayout, bcd), nil
+		}
+	case common.STRING:
+		return runtime.RegisterStringToBytes(memoryLayout, variable.Value.(string), variable.Amount)
+	}
+	return nil, runtime.ErrDataTypeUnsupported
+}
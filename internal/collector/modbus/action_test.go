@@ -0,0 +1,92 @@
+package modbus
+
+import (
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"testing"
+)
+
+// 写入的位段值按位寻址变量解析后与原值一致
+func TestBitFieldValue(t *testing.T) {
+	tests := []struct {
+		variable string
+		dataType string
+		value    interface{}
+		field    uint16
+		err      error
+	}{
+		{"40010.3", "bool", true, 1, nil},
+		{"40010.3", "bool", false, 0, nil},
+		{"40010.2-5", "uint16", uint16(15), 15, nil},
+		{"40010.2-5", "uint16", uint16(16), 0, runtime.ErrActionValueInvalid},
+		{"40010.2-5", "int16", int16(-1), 15, nil},
+		{"40010.2-5", "int16", int16(-8), 8, nil},
+		{"40010.2-5", "int16", int16(7), 7, nil},
+		{"40010.2-5", "int16", int16(8), 0, runtime.ErrActionValueInvalid},
+		{"40010.2-5", "int16", int16(-9), 0, runtime.ErrActionValueInvalid},
+		{"40010.0-15", "int16", int16(-32768), 0x8000, nil},
+		{"40010.8-15", "int8", int8(-128), 0x80, nil},
+		{"40010.4-6", "int8", int8(-4), 4, nil},
+		{"40010.4-6", "int8", int8(4), 0, runtime.ErrActionValueInvalid},
+		{"40010.8-15", "uint8", uint8(0xAB), 0xAB, nil},
+		{"40010.4-6", "uint8", uint8(8), 0, runtime.ErrActionValueInvalid},
+	}
+	for _, tt := range tests {
+		v, err := ConvertVariable(&biz.Mapping{Name: "bit", Variable: tt.variable, DataType: tt.dataType, AccessMode: "rw"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.Value = tt.value
+		field, err := bitFieldValue(v)
+		if err != tt.err || field != tt.field {
+			t.Errorf("%s %v: got %d, %v, want %d, %v", tt.variable, tt.value, field, err, tt.field, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := v.ParseBitValue(field << v.Bits); got != tt.value {
+			t.Errorf("%s %v: parsed %v", tt.variable, tt.value, got)
+		}
+	}
+}
+
+// 下发的工程值先限幅再换算为原始值, 超出数据类型范围时返回错误
+func TestConvertActionValue(t *testing.T) {
+	tests := []struct {
+		mapping *biz.Mapping
+		value   interface{}
+		want    interface{}
+		err     error
+	}{
+		{&biz.Mapping{DataType: "int16"}, -3.0, int16(-3), nil},
+		{&biz.Mapping{DataType: "int16", Rate: "0.1"}, 12.3, int16(123), nil},
+		{&biz.Mapping{DataType: "int16", Rate: "0.1"}, "12.3", int16(123), nil},
+		{&biz.Mapping{DataType: "uint16", Rate: "0.1", Offset: "-20"}, 0.0, uint16(200), nil},
+		{&biz.Mapping{DataType: "uint16", Rate: "2", Max: "100"}, 150.0, uint16(50), nil},
+		{&biz.Mapping{DataType: "int16", Rate: "0.01"}, 400.0, nil, runtime.ErrActionValueInvalid},
+		{&biz.Mapping{DataType: "uint16", Offset: "10"}, 5.0, nil, runtime.ErrActionValueInvalid},
+		{&biz.Mapping{DataType: "float32", Rate: "2"}, 3.0, float32(1.5), nil},
+		{&biz.Mapping{DataType: "float64", Offset: "1"}, 3.5, 2.5, nil},
+		// 超出float64精度的整数使用字符串下发
+		{&biz.Mapping{DataType: "uint64"}, "18446744073709551615", uint64(18446744073709551615), nil},
+		{&biz.Mapping{DataType: "bcd16"}, "1234", uint64(1234), nil},
+		{&biz.Mapping{DataType: "int32"}, "abc", nil, runtime.ErrActionValueInvalid},
+		{&biz.Mapping{DataType: "bool"}, "true", true, nil},
+		{&biz.Mapping{DataType: "bool"}, 1.0, nil, runtime.ErrActionValueInvalid},
+	}
+	for _, tt := range tests {
+		tt.mapping.Name = "value"
+		tt.mapping.Variable = "40001"
+		tt.mapping.AccessMode = "rw"
+		v, err := ConvertVariable(tt.mapping)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := convertActionValue(v, tt.value)
+		if err != tt.err || got != tt.want {
+			t.Errorf("%s rate %q offset %q %v: got %v(%T), %v, want %v(%T), %v", tt.mapping.DataType, tt.mapping.Rate, tt.mapping.Offset,
+				tt.value, got, got, err, tt.want, tt.want, tt.err)
+		}
+	}
+}
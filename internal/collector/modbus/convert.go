@@ -0,0 +1,526 @@
+package modbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/model"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"strconv"
+	"strings"
+)
+
+// AddressAreaFunctionCode 变量地址首位区号对应的读功能码
+// 0xxxx 线圈 1xxxx 离散输入 3xxxx 输入寄存器 4xxxx 保持寄存器
+var AddressAreaFunctionCode = map[byte]runtime.FunctionCode{
+	'0': runtime.ReadCoilStatus,
+	'1': runtime.ReadInputStatus,
+	'3': runtime.ReadInputRegister,
+	'4': runtime.ReadHoldRegister,
+}
+
+// VariableAddress 变量地址解析结果
+type VariableAddress struct {
+	FunctionCode runtime.FunctionCode
+	Address      uint
+	BitAddress   bool  // 是否为寄存器位寻址
+	Bits         uint8 // 起始位
+	BitWidth     uint  // 位宽
+}
+
+// MappingError 单个点位映射的校验错误
+type MappingError struct {
+	Name     string
+	Variable string
+	Err      error
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("mapping %s(%s): %v", e.Name, e.Variable, e.Err)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertDevice 将持久化的agents及其mappings转换为运行时设备
+func ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (collector.Device, error) {
+	details, err := DecodeAgentDetails(agents.AgentDetails)
+	if err != nil {
+		return nil, err
+	}
+	address, err := DecodeAgentAddress(agents.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	forbiddenRanges, err := ParseAddressRanges(details.ForbiddenRanges)
+	if err != nil {
+		return nil, err
+	}
+
+	variables, err := ConvertVariables(mappings)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPositionAddress(variables, details.PositionAddress); err != nil {
+		return nil, err
+	}
+	if err := checkForbiddenRanges(variables, forbiddenRanges); err != nil {
+		return nil, err
+	}
+	if err := checkFrameLimit(variables, details.MaxRegisters); err != nil {
+		return nil, err
+	}
+	scanClasses := ConvertScanClasses(details)
+	if err := checkScanClasses(variables, scanClasses); err != nil {
+		return nil, err
+	}
+
+	device := &runtime.ModBusDevice{
+		DeviceMeta: collector.DeviceMeta{
+			ObjectMeta: collector.ObjectMeta{
+				Name:    agents.Name,
+				ID:      agents.Id,
+				Version: agents.Version,
+				ModTime: agents.UpdatedTime,
+			},
+			DeviceType:  agents.AgentType,
+			DeviceModel: details.Protocol,
+		},
+		CollectorCycle:   agents.CollectorCycle,
+		VariableInterval: agents.VariableInterval,
+		Address:          address,
+		Slave:            details.Slave,
+		MemoryLayout:     common.StringToMemoryLayout[details.MemoryLayout],
+		WriteMode:        runtime.StringToWriteMode[details.WriteMode],
+		MaskWrite:        details.MaskWrite,
+		VerifyWrite:      details.VerifyWrite,
+		VerifyDelay:      details.VerifyDelay,
+		WriteRetries:     details.WriteRetries,
+		MaxRegisters:     details.MaxRegisters,
+		MaxCoils:         details.MaxCoils,
+		MaxGap:           details.MaxGap,
+		ForbiddenRanges:  forbiddenRanges,
+		ScanClasses:      scanClasses,
+		MaxInFlight:      details.MaxInFlight,
+		MaxConnections:   details.MaxConnections,
+		OverrunPolicy:    collector.StringToOverrunPolicy[details.OverrunPolicy],
+		PositionAddress:  details.PositionAddress,
+		Variables:        variables,
+	}
+	return device, nil
+}
+
+// ConvertVariables 转换全部mappings,返回每个非法mapping的错误
+func ConvertVariables(mappings []*biz.Mapping) ([]*runtime.Variable, error) {
+	variables := make([]*runtime.Variable, 0, len(mappings))
+	errs := make([]error, 0)
+	names := make(map[string]struct{}, len(mappings))
+	for _, mapping := range mappings {
+		if _, exist := names[mapping.Name]; exist {
+			errs = append(errs, &MappingError{Name: mapping.Name, Variable: mapping.Variable, Err: runtime.ErrVariableNameDuplicate})
+			continue
+		}
+		names[mapping.Name] = struct{}{}
+
+		variable, err := ConvertVariable(mapping)
+		if err != nil {
+			errs = append(errs, &MappingError{Name: mapping.Name, Variable: mapping.Variable, Err: err})
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return variables, nil
+}
+
+// ConvertVariable 将单个mapping转换为运行时变量
+func ConvertVariable(mapping *biz.Mapping) (*runtime.Variable, error) {
+	if len(mapping.Name) == 0 {
+		return nil, runtime.ErrVariableNameEmpty
+	}
+
+	va, err := ParseVariableAddress(mapping.Variable)
+	if err != nil {
+		return nil, err
+	}
+	functionCode := va.FunctionCode
+
+	dataType, ok := common.StringToDataType[mapping.DataType]
+	if !ok || dataType == common.NUMBER {
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+	if va.BitAddress {
+		// 位寻址仅支持bool(单个位)与16位以内的整数
+		switch dataType {
+		case common.BOOL:
+			if va.BitWidth != 1 {
+				return nil, runtime.ErrVariableAddressInvalid
+			}
+		case common.INT8, common.UINT8:
+			if va.BitWidth > 8 {
+				return nil, runtime.ErrVariableAddressInvalid
+			}
+		case common.INT16, common.UINT16:
+		default:
+			return nil, runtime.ErrDataTypeUnsupported
+		}
+	}
+	amount := uint(1)
+	if dataType == common.STRING && !va.BitAddress {
+		// 字符串按寄存器块读取,仅支持寄存器区
+		if functionCode != runtime.ReadHoldRegister && functionCode != runtime.ReadInputRegister {
+			return nil, runtime.ErrDataTypeUnsupported
+		}
+		if mapping.Amount == 0 || mapping.Amount > runtime.PerRequestMaxRegister {
+			return nil, runtime.ErrAmountInvalid
+		}
+		amount = mapping.Amount
+	}
+
+	accessMode := common.AccessModeReadOnly
+	if len(mapping.AccessMode) > 0 {
+		if accessMode, ok = common.StringToReadWriteProperty[mapping.AccessMode]; !ok {
+			return nil, runtime.ErrAccessModeInvalid
+		}
+	}
+	if accessMode == common.AccessModeReadWrite && (functionCode == runtime.ReadInputStatus || functionCode == runtime.ReadInputRegister) {
+		return nil, runtime.ErrAccessModeInvalid
+	}
+
+	rate := 1.0
+	if len(mapping.Rate) > 0 {
+		if rate, err = strconv.ParseFloat(mapping.Rate, 64); err != nil || rate == 0 {
+			return nil, runtime.ErrRateInvalid
+		}
+	}
+
+	var offset float64
+	if len(mapping.Offset) > 0 {
+		if offset, err = strconv.ParseFloat(mapping.Offset, 64); err != nil {
+			return nil, runtime.ErrOffsetInvalid
+		}
+	}
+
+	var minValue, maxValue *float64
+	if len(mapping.Min) > 0 {
+		v, err := strconv.ParseFloat(mapping.Min, 64)
+		if err != nil {
+			return nil, runtime.ErrClampInvalid
+		}
+		minValue = &v
+	}
+	if len(mapping.Max) > 0 {
+		v, err := strconv.ParseFloat(mapping.Max, 64)
+		if err != nil || (minValue != nil && v < *minValue) {
+			return nil, runtime.ErrClampInvalid
+		}
+		maxValue = &v
+	}
+
+	var precision *int
+	if len(mapping.Precision) > 0 {
+		v, err := strconv.Atoi(mapping.Precision)
+		if err != nil || v < 0 || v > 15 {
+			return nil, runtime.ErrPrecisionInvalid
+		}
+		precision = &v
+	}
+
+	variable := &runtime.Variable{
+		DataType:     dataType,
+		Name:         mapping.Name,
+		Address:      va.Address,
+		FunctionCode: uint8(functionCode),
+		Amount:       amount,
+		Rate:         rate,
+		OffSet:       offset,
+		Min:          minValue,
+		Max:          maxValue,
+		Precision:    precision,
+		AccessMode:   accessMode,
+	}
+	if len(mapping.MemoryLayout) > 0 {
+		memoryLayout, ok := common.StringToMemoryLayout[mapping.MemoryLayout]
+		if !ok {
+			return nil, runtime.ErrMemoryLayoutInvalid
+		}
+		variable.MemoryLayout = &memoryLayout
+	}
+	if va.BitAddress {
+		variable.BitAddress = true
+		variable.Bits = va.Bits
+		variable.Amount = va.BitWidth
+	}
+	if len(mapping.DefaultValue) > 0 {
+		variable.DefaultValue = mapping.DefaultValue
+	}
+	variable.ScanClass = mapping.ScanClass
+	return variable, nil
+}
+
+// ParseVariableAddress 解析变量地址,例如 40001 => 功能码3 地址1
+// 寄存器支持位寻址: 40010.3 => 第3位, 40010.4-7 => 第4至7位
+func ParseVariableAddress(variable string) (*VariableAddress, error) {
+	variable = strings.TrimSpace(variable)
+	if len(variable) < 2 {
+		return nil, runtime.ErrVariableAddressInvalid
+	}
+	functionCode, ok := AddressAreaFunctionCode[variable[0]]
+	if !ok {
+		return nil, runtime.ErrVariableAddressInvalid
+	}
+	register, bitRange, hasBit := strings.Cut(variable[1:], ".")
+	address, err := strconv.ParseUint(register, 10, 16)
+	if err != nil {
+		return nil, runtime.ErrVariableAddressInvalid
+	}
+	va := &VariableAddress{FunctionCode: functionCode, Address: uint(address)}
+	if !hasBit {
+		return va, nil
+	}
+	if functionCode != runtime.ReadHoldRegister && functionCode != runtime.ReadInputRegister {
+		return nil, runtime.ErrVariableAddressInvalid
+	}
+
+	startBit, endBit, isRange := strings.Cut(bitRange, "-")
+	start, err := strconv.ParseUint(startBit, 10, 8)
+	if err != nil || start > 15 {
+		return nil, runtime.ErrVariableAddressInvalid
+	}
+	end := start
+	if isRange {
+		if end, err = strconv.ParseUint(endBit, 10, 8); err != nil || end > 15 || end < start {
+			return nil, runtime.ErrVariableAddressInvalid
+		}
+	}
+	va.BitAddress = true
+	va.Bits = uint8(start)
+	va.BitWidth = uint(end - start + 1)
+	return va, nil
+}
+
+// ParseAddressRanges 解析禁止读取的地址段, 例如 40100-40120 或 40100
+func ParseAddressRanges(ranges []string) ([]*runtime.AddressRange, error) {
+	ars := make([]*runtime.AddressRange, 0, len(ranges))
+	for _, r := range ranges {
+		first, last, isRange := strings.Cut(r, "-")
+		start, err := ParseVariableAddress(first)
+		if err != nil || start.BitAddress {
+			return nil, runtime.ErrForbiddenRangeInvalid
+		}
+		end := start
+		if isRange {
+			end, err = ParseVariableAddress(last)
+			if err != nil || end.BitAddress || end.FunctionCode != start.FunctionCode || end.Address < start.Address {
+				return nil, runtime.ErrForbiddenRangeInvalid
+			}
+		}
+		ars = append(ars, &runtime.AddressRange{
+			FunctionCode: uint8(start.FunctionCode),
+			Start:        start.Address,
+			End:          end.Address,
+		})
+	}
+	return ars, nil
+}
+
+// checkPositionAddress 报文地址为变量地址减去起始地址, 变量地址不能小于起始地址
+func checkPositionAddress(variables []*runtime.Variable, positionAddress uint) error {
+	errs := make([]error, 0)
+	for _, variable := range variables {
+		if variable.Address < positionAddress {
+			errs = append(errs, &MappingError{Name: variable.Name, Err: runtime.ErrVariableBelowPositionAddress})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkDuplicateAddresses 同一功能码下地址与起始位均相同的变量视为重复
+func checkDuplicateAddresses(variables []*runtime.Variable) error {
+	type key struct {
+		functionCode uint8
+		address      uint
+		bitAddress   bool
+		bits         uint8
+	}
+	errs := make([]error, 0)
+	addresses := make(map[key]string, len(variables))
+	for _, variable := range variables {
+		k := key{functionCode: variable.FunctionCode, address: variable.Address, bitAddress: variable.BitAddress, bits: variable.Bits}
+		if name, exist := addresses[k]; exist {
+			errs = append(errs, &MappingError{Name: variable.Name, Variable: name, Err: runtime.ErrVariableAddressDuplicate})
+			continue
+		}
+		addresses[k] = variable.Name
+	}
+	return errors.Join(errs...)
+}
+
+// checkForbiddenRanges 变量本身不能位于禁止读取的地址段内
+func checkForbiddenRanges(variables []*runtime.Variable, ranges []*runtime.AddressRange) error {
+	errs := make([]error, 0)
+	for _, variable := range variables {
+		words := uint(1)
+		if variable.FunctionCode == uint8(runtime.ReadHoldRegister) || variable.FunctionCode == uint8(runtime.ReadInputRegister) {
+			words = variable.Words()
+		}
+		for _, r := range ranges {
+			if r.Overlaps(variable.FunctionCode, variable.Address, variable.Address+words) {
+				errs = append(errs, &MappingError{Name: variable.Name, Err: runtime.ErrVariableInForbiddenRange})
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkFrameLimit 单个变量占用的寄存器数不能超过单次读取的最大寄存器数量, 否则规划出的读报文必然被从站拒绝
+func checkFrameLimit(variables []*runtime.Variable, maxRegisters uint) error {
+	if maxRegisters == 0 {
+		maxRegisters = runtime.PerRequestMaxRegister
+	}
+	errs := make([]error, 0)
+	for _, variable := range variables {
+		if variable.FunctionCode != uint8(runtime.ReadHoldRegister) && variable.FunctionCode != uint8(runtime.ReadInputRegister) {
+			continue
+		}
+		if variable.Words() > maxRegisters {
+			errs = append(errs, &MappingError{Name: variable.Name, Err: runtime.ErrVariableExceedsFrameLimit})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ConvertScanClasses 扫描类别未配置超时策略时使用设备配置
+func ConvertScanClasses(details *biz.ModbusAgentDetails) []*runtime.ScanClass {
+	scanClasses := make([]*runtime.ScanClass, 0, len(details.ScanClasses))
+	for _, sc := range details.ScanClasses {
+		policy := details.OverrunPolicy
+		if len(sc.OverrunPolicy) > 0 {
+			policy = sc.OverrunPolicy
+		}
+		scanClasses = append(scanClasses, &runtime.ScanClass{
+			Name:           sc.Name,
+			CollectorCycle: sc.CollectorCycle,
+			OverrunPolicy:  collector.StringToOverrunPolicy[policy],
+		})
+	}
+	return scanClasses
+}
+
+// checkScanClasses 变量引用的扫描类别必须已配置
+func checkScanClasses(variables []*runtime.Variable, scanClasses []*runtime.ScanClass) error {
+	names := make(map[string]struct{}, len(scanClasses))
+	for _, sc := range scanClasses {
+		names[sc.Name] = struct{}{}
+	}
+	errs := make([]error, 0)
+	for _, variable := range variables {
+		if len(variable.ScanClass) == 0 {
+			continue
+		}
+		if _, ok := names[variable.ScanClass]; !ok {
+			errs = append(errs, &MappingError{Name: variable.Name, Err: runtime.ErrScanClassNotFound})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DecodeAgentDetails agentDetails JSONMap => ModbusAgentDetails
+func DecodeAgentDetails(jm biz.JSONMap) (*biz.ModbusAgentDetails, error) {
+	details := &biz.ModbusAgentDetails{}
+	if err := decodeJSONMap(jm, details); err != nil {
+		return nil, runtime.ErrAgentDetailsInvalid
+	}
+	if _, ok := model.ModbusModelers[details.Protocol]; !ok {
+		return nil, runtime.ErrProtocolUnsupported
+	}
+	if _, ok := common.StringToMemoryLayout[details.MemoryLayout]; !ok {
+		return nil, runtime.ErrMemoryLayoutInvalid
+	}
+	if details.Slave > 255 {
+		return nil, runtime.ErrAgentDetailsInvalid
+	}
+	if _, ok := runtime.StringToWriteMode[details.WriteMode]; len(details.WriteMode) > 0 && !ok {
+		return nil, runtime.ErrWriteModeInvalid
+	}
+	if _, ok := collector.StringToOverrunPolicy[details.OverrunPolicy]; len(details.OverrunPolicy) > 0 && !ok {
+		return nil, runtime.ErrOverrunPolicyInvalid
+	}
+	if details.MaxRegisters > runtime.PerRequestMaxRegister || details.MaxCoils > runtime.PerRequestMaxCoil {
+		return nil, runtime.ErrFrameLimitInvalid
+	}
+	if details.MaxInFlight > 1 && runtime.StringToModbusModel[details.Protocol] != runtime.Tcp {
+		return nil, runtime.ErrPipelineUnsupported
+	}
+	if _, err := ParseAddressRanges(details.ForbiddenRanges); err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(details.ScanClasses))
+	for _, sc := range details.ScanClasses {
+		if sc == nil || len(sc.Name) == 0 || sc.CollectorCycle == 0 {
+			return nil, runtime.ErrScanClassInvalid
+		}
+		if _, exist := names[sc.Name]; exist {
+			return nil, runtime.ErrScanClassInvalid
+		}
+		names[sc.Name] = struct{}{}
+		if _, ok := collector.StringToOverrunPolicy[sc.OverrunPolicy]; len(sc.OverrunPolicy) > 0 && !ok {
+			return nil, runtime.ErrOverrunPolicyInvalid
+		}
+	}
+	return details, nil
+}
+
+// DecodeAgentAddress address JSONMap => runtime.Address
+func DecodeAgentAddress(jm biz.JSONMap) (*runtime.Address, error) {
+	aa := &biz.ModbusAgentAddress{}
+	if err := decodeJSONMap(jm, aa); err != nil {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	if len(aa.Location) == 0 {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+
+	address := &runtime.Address{
+		Location: aa.Location,
+		Option:   &runtime.Option{},
+	}
+	if aa.Option != nil {
+		address.Option.Port = aa.Option.Port
+		address.Option.BaudRate = aa.Option.BaudRate
+		address.Option.DataBits = aa.Option.DataBits
+		address.Option.EchoCancel = aa.Option.EchoCancel
+		if len(aa.Option.Parity) > 0 {
+			parity, ok := common.StringToParity[aa.Option.Parity]
+			if !ok {
+				return nil, runtime.ErrAgentAddressInvalid
+			}
+			address.Option.Parity = parity
+		}
+		if len(aa.Option.StopBits) > 0 {
+			stopBits, ok := common.StringToStopBits[aa.Option.StopBits]
+			if !ok {
+				return nil, runtime.ErrAgentAddressInvalid
+			}
+			address.Option.StopBits = stopBits
+		}
+	}
+	return address, nil
+}
+
+// decodeJSONMap JSONMap从数据库读出后嵌套对象为map,通过json往返转换为结构体
+func decodeJSONMap(jm biz.JSONMap, v interface{}) error {
+	bytes, err := json.Marshal(jm)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
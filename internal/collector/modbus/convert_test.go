@@ -0,0 +1,79 @@
+package modbus
+
+import (
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"reflect"
+	"testing"
+)
+
+func TestParseVariableAddress(t *testing.T) {
+	tests := []struct {
+		variable string
+		want     *VariableAddress
+	}{
+		{"00001", &VariableAddress{FunctionCode: runtime.ReadCoilStatus, Address: 1}},
+		{"10002", &VariableAddress{FunctionCode: runtime.ReadInputStatus, Address: 2}},
+		{"30003", &VariableAddress{FunctionCode: runtime.ReadInputRegister, Address: 3}},
+		{" 465535 ", &VariableAddress{FunctionCode: runtime.ReadHoldRegister, Address: 65535}},
+		{"40010.0", &VariableAddress{FunctionCode: runtime.ReadHoldRegister, Address: 10, BitAddress: true, Bits: 0, BitWidth: 1}},
+		{"30010.15", &VariableAddress{FunctionCode: runtime.ReadInputRegister, Address: 10, BitAddress: true, Bits: 15, BitWidth: 1}},
+		{"40010.2-5", &VariableAddress{FunctionCode: runtime.ReadHoldRegister, Address: 10, BitAddress: true, Bits: 2, BitWidth: 4}},
+		{"40010.0-15", &VariableAddress{FunctionCode: runtime.ReadHoldRegister, Address: 10, BitAddress: true, Bits: 0, BitWidth: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.variable, func(t *testing.T) {
+			got, err := ParseVariableAddress(tt.variable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, variable := range []string{
+		"",
+		"4",
+		"20001",      // 不支持的区号
+		"465536",     // 地址超出范围
+		"00001.1",    // 线圈不支持位寻址
+		"40010.16",   // 位号超出范围
+		"40010.5-2",  // 结束位小于起始位
+		"40010.2-16", // 结束位超出范围
+		"40010.",
+		"4001a",
+	} {
+		if _, err := ParseVariableAddress(variable); err != runtime.ErrVariableAddressInvalid {
+			t.Errorf("ParseVariableAddress(%q) = %v, want %v", variable, err, runtime.ErrVariableAddressInvalid)
+		}
+	}
+}
+
+func TestConvertBitVariable(t *testing.T) {
+	tests := []struct {
+		variable string
+		dataType string
+		err      error
+	}{
+		{"40010.3", "bool", nil},
+		{"40010.2-5", "bool", runtime.ErrVariableAddressInvalid},
+		{"40010.0-7", "int8", nil},
+		{"40010.0-8", "uint8", runtime.ErrVariableAddressInvalid},
+		{"40010.0-15", "int16", nil},
+		{"40010.4-7", "uint16", nil},
+		{"40010.0-15", "int32", runtime.ErrDataTypeUnsupported},
+		{"40010.0", "float32", runtime.ErrDataTypeUnsupported},
+	}
+	for _, tt := range tests {
+		v, err := ConvertVariable(&biz.Mapping{Name: "bit", Variable: tt.variable, DataType: tt.dataType})
+		if err != tt.err {
+			t.Errorf("%s %s: got %v, want %v", tt.variable, tt.dataType, err, tt.err)
+			continue
+		}
+		if err == nil && (!v.BitAddress || v.Address != 10) {
+			t.Errorf("%s %s: got %+v", tt.variable, tt.dataType, v)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/utils/binutils"
+	"k8s.io/klog/v2"
+)
+
+/**
+诊断
+08 诊断         功能码(1) + 子功能码(2) + 数据(2), 回送查询的响应与请求相同, 计数类子功能码响应数据为计数值
+0B 通讯事件计数 功能码(1), 响应 功能码(1) + 状态(2) + 事件计数(2), 状态0xFFFF表示从站忙
+多数TCP设备与网关不实现08/0B, 返回非法功能码时记为不支持
+*/
+
+const (
+	DiagnosticReturnQueryData uint16 = 0x00
+	DiagnosticEchoData        uint16 = 0x1234
+)
+
+// DiagnosticCounters 08诊断计数子功能码
+var DiagnosticCounters = []struct {
+	Name        string
+	SubFunction uint16
+}{
+	{"busMessage", 0x0B},
+	{"busCommunicationError", 0x0C},
+	{"busExceptionError", 0x0D},
+	{"slaveMessage", 0x0E},
+	{"slaveNoResponse", 0x0F},
+	{"slaveNAK", 0x10},
+	{"slaveBusy", 0x11},
+	{"busCharacterOverrun", 0x12},
+}
+
+type DiagnosticCounter struct {
+	Name        string  `json:"name"`
+	SubFunction uint16  `json:"subFunction"`
+	Supported   bool    `json:"supported"`
+	Value       *uint16 `json:"value,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+type CommEventCounter struct {
+	Supported  bool   `json:"supported"`
+	Busy       bool   `json:"busy"`
+	EventCount uint16 `json:"eventCount"`
+	Error      string `json:"error,omitempty"`
+}
+
+type DiagnosticsResult struct {
+	Echo             bool                 `json:"echo"` // 回送查询是否成功
+	EchoError        string               `json:"echoError,omitempty"`
+	Counters         []*DiagnosticCounter `json:"counters"`
+	CommEventCounter *CommEventCounter    `json:"commEventCounter"`
+}
+
+// Diagnose 依次发送08回送查询、08计数与0B通讯事件计数, 单项失败记录在结果中, 连接失败返回error
+func (broker *ModbusBroker) Diagnose(ctx context.Context) (interface{}, error) {
+	messenger, err := broker.Clients.GetMessenger(ctx)
+	if err != nil {
+		klog.V(2).InfoS("Failed to get Modbus messenger", "error", err)
+		if messenger, err = broker.Clients.NewMessenger(); err != nil {
+			return nil, err
+		}
+	}
+	defer broker.Clients.ReleaseMessenger(messenger)
+
+	var transactionId uint16
+	ask := func(pdu []byte, responsePduLength int) ([]byte, error) {
+		transactionId++
+		response, err := broker.askAction(messenger, pdu, responsePduLength, transactionId)
+		if errors.Is(err, runtime.ErrModbusTimeout) {
+			// 迟到的响应会与下一个请求错位, 重建连接
+			if e := broker.reconnect(messenger); e != nil {
+				return nil, runtime.ErrModbusBadConn
+			}
+		}
+		return response, err
+	}
+
+	result := &DiagnosticsResult{
+		Counters:         make([]*DiagnosticCounter, 0, len(DiagnosticCounters)),
+		CommEventCounter: &CommEventCounter{},
+	}
+
+	diagnosticSupported := true
+	pdu := diagnosticPdu(DiagnosticReturnQueryData, DiagnosticEchoData)
+	response, err := ask(pdu, len(pdu))
+	switch {
+	case err == nil && bytes.Equal(response, pdu):
+		result.Echo = true
+	case err == nil:
+		result.EchoError = runtime.ErrDiagnosticEchoMismatch.Error()
+	case isIllegalFunction(err):
+		diagnosticSupported = false
+		result.EchoError = err.Error()
+	case errors.Is(err, runtime.ErrModbusBadConn):
+		return nil, err
+	default:
+		result.EchoError = err.Error()
+	}
+
+	for _, dc := range DiagnosticCounters {
+		counter := &DiagnosticCounter{Name: dc.Name, SubFunction: dc.SubFunction}
+		result.Counters = append(result.Counters, counter)
+		if !diagnosticSupported {
+			continue
+		}
+		response, err := ask(diagnosticPdu(dc.SubFunction, 0), 5)
+		if err == nil && binutils.ParseUint16BigEndian(response[1:]) != dc.SubFunction {
+			err = runtime.ErrModbusServerBadResp
+		}
+		if err != nil {
+			counter.Supported = !isIllegalFunction(err) && !isIllegalDataValue(err)
+			counter.Error = err.Error()
+			continue
+		}
+		value := binutils.ParseUint16BigEndian(response[3:])
+		counter.Supported = true
+		counter.Value = &value
+	}
+
+	response, err = ask([]byte{uint8(runtime.GetCommEventCounter)}, 5)
+	if err != nil {
+		result.CommEventCounter.Supported = !isIllegalFunction(err)
+		result.CommEventCounter.Error = err.Error()
+	} else {
+		result.CommEventCounter.Supported = true
+		result.CommEventCounter.Busy = binutils.ParseUint16BigEndian(response[1:]) == 0xFFFF
+		result.CommEventCounter.EventCount = binutils.ParseUint16BigEndian(response[3:])
+	}
+	return result, nil
+}
+
+func (broker *ModbusBroker) GetStatistics() interface{} {
+	return broker.Statistics.Snapshot()
+}
+
+func (broker *ModbusBroker) ResetStatistics() {
+	broker.Statistics.Reset()
+}
+
+func diagnosticPdu(subFunction uint16, data uint16) []byte {
+	pdu := make([]byte, 5)
+	pdu[0] = uint8(runtime.Diagnostics)
+	binutils.WriteUint16BigEndian(pdu[1:], subFunction)
+	binutils.WriteUint16BigEndian(pdu[3:], data)
+	return pdu
+}
+
+func isIllegalFunction(err error) bool {
+	var me *runtime.ModbusException
+	return errors.As(err, &me) && me.ExceptionCode == runtime.IllegalFunction
+}
+
+func isIllegalDataValue(err error) bool {
+	var me *runtime.ModbusException
+	return errors.As(err, &me) && me.ExceptionCode == runtime.IllegalDataValue
+}
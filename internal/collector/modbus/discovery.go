@@ -0,0 +1,549 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/model"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/binutils"
+	"k8s.io/klog/v2"
+	"math"
+	"strconv"
+	"time"
+)
+
+/**
+寄存器扫描
+1. 读取设备标识(43/14), 不支持时忽略
+2. 按地址段自适应分块读取: 成功后块大小翻倍, 非法地址等异常时块大小减半, 块大小为1仍失败时记录该地址
+3. 对响应的地址多次采样, 根据采样值推测数据类型
+4. 生成可直接导入agent的草稿点位
+*/
+
+const (
+	RangeResponsive  = "responsive"  // 正常响应
+	RangeIllegal     = "illegal"     // 返回异常, 通常为非法地址
+	RangeNoResponse  = "noResponse"  // 无响应或响应错误
+	RangeUnsupported = "unsupported" // 功能码不支持
+	RangeUnscanned   = "unscanned"   // 达到请求上限未扫描
+)
+
+const (
+	DefaultDiscoverySamples        = 3
+	DefaultDiscoverySampleInterval = 500 // 毫秒
+	DefaultDiscoveryMaxRequests    = 2000
+	DefaultDiscoveryMaxTimeouts    = 3
+)
+
+// DefaultDiscoveryRanges 默认扫描各区前1000个地址
+var DefaultDiscoveryRanges = []string{"00000-00999", "10000-10999", "30000-30999", "40000-40999"}
+
+// DeviceIdentificationObjects 43/14 对象编号对应的名称
+var DeviceIdentificationObjects = map[uint8]string{
+	0x00: "vendorName",
+	0x01: "productCode",
+	0x02: "majorMinorRevision",
+	0x03: "vendorUrl",
+	0x04: "productName",
+	0x05: "modelName",
+	0x06: "userApplicationName",
+}
+
+// DiscoveredRange 扫描得到的地址段, 包含首尾
+type DiscoveredRange struct {
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	functionCode runtime.FunctionCode
+	start        uint
+	end          uint
+}
+
+type DiscoveryResult struct {
+	Protocol       string             `json:"protocol"`
+	Slave          uint               `json:"slave"`
+	Identification map[string]string  `json:"identification,omitempty"`
+	Ranges         []*DiscoveredRange `json:"ranges"`
+	Requests       uint               `json:"requests"`
+	Truncated      bool               `json:"truncated"` // 达到请求上限, 结果不完整
+	Mappings       []*biz.Mapping     `json:"mappings"`
+}
+
+type discoverer struct {
+	opts          *biz.ModbusDiscoveryOptions
+	broker        *ModbusBroker
+	messenger     runtime.Messenger
+	transactionId uint16
+	broken        error // 重建连接失败, 终止扫描
+	result        *DiscoveryResult
+	samples       map[runtime.FunctionCode]map[uint][]uint16
+}
+
+// Discover 扫描从站的地址空间
+func Discover(ctx context.Context, opts *biz.ModbusDiscoveryOptions) (*DiscoveryResult, error) {
+	if _, ok := model.ModbusModelers[opts.Protocol]; !ok {
+		return nil, runtime.ErrProtocolUnsupported
+	}
+	if opts.Slave == 0 || opts.Slave > 247 {
+		// 广播地址没有响应
+		return nil, runtime.ErrDiscoverySlaveInvalid
+	}
+	if len(opts.Ranges) == 0 {
+		opts.Ranges = DefaultDiscoveryRanges
+	}
+	ranges, err := ParseAddressRanges(opts.Ranges)
+	if err != nil {
+		return nil, runtime.ErrDiscoveryRangeInvalid
+	}
+	if opts.MaxRegisters == 0 || opts.MaxRegisters > runtime.PerRequestMaxRegister {
+		opts.MaxRegisters = runtime.PerRequestMaxRegister
+	}
+	if opts.MaxCoils == 0 || opts.MaxCoils > runtime.PerRequestMaxCoil {
+		opts.MaxCoils = runtime.PerRequestMaxCoil
+	}
+	if opts.Samples == 0 {
+		opts.Samples = DefaultDiscoverySamples
+	}
+	if opts.SampleInterval == 0 {
+		opts.SampleInterval = DefaultDiscoverySampleInterval
+	}
+	if opts.MaxRequests == 0 {
+		opts.MaxRequests = DefaultDiscoveryMaxRequests
+	}
+	if opts.MaxTimeouts == 0 {
+		opts.MaxTimeouts = DefaultDiscoveryMaxTimeouts
+	}
+	address, err := DecodeAgentAddress(opts.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := model.ModbusModelers[opts.Protocol].NewClients(address, 1)
+	if err != nil {
+		klog.V(2).InfoS("Failed to connect Modbus device", "error", err, "location", address.Location)
+		return nil, collector.ErrConnectDevice
+	}
+	defer clients.Destroy(ctx)
+	messenger, err := clients.GetMessenger(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer clients.ReleaseMessenger(messenger)
+
+	needCheckTransaction, needCheckCrc16Sum, needCheckLrcSum := frameChecks(opts.Protocol)
+	d := &discoverer{
+		opts: opts,
+		broker: &ModbusBroker{
+			Device: &runtime.ModBusDevice{
+				DeviceMeta: collector.DeviceMeta{DeviceModel: opts.Protocol},
+				Address:    address,
+				Slave:      opts.Slave,
+			},
+			Clients:              clients,
+			FrameGap:             &runtime.FrameGap{Interval: time.Duration(opts.VariableInterval) * time.Millisecond},
+			NeedCheckTransaction: needCheckTransaction,
+			NeedCheckCrc16Sum:    needCheckCrc16Sum,
+			NeedCheckLrcSum:      needCheckLrcSum,
+		},
+		messenger: messenger,
+		result: &DiscoveryResult{
+			Protocol: opts.Protocol,
+			Slave:    opts.Slave,
+			Ranges:   make([]*DiscoveredRange, 0),
+			Mappings: make([]*biz.Mapping, 0),
+		},
+		samples: make(map[runtime.FunctionCode]map[uint][]uint16, 4),
+	}
+
+	d.identify()
+	if d.broken != nil {
+		return nil, d.broken
+	}
+	for _, ar := range ranges {
+		if err := d.sweep(ctx, ar); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.sample(ctx); err != nil {
+		return nil, err
+	}
+	d.draft()
+	klog.V(4).InfoS("Succeed to discover Modbus slave", "slave", opts.Slave, "requests", d.result.Requests, "mappings", len(d.result.Mappings), "truncated", d.result.Truncated)
+	return d.result, nil
+}
+
+// identify 读取基本与常规设备标识, 从站不支持时返回异常, 忽略即可
+func (d *discoverer) identify() {
+	objects := make(map[string]string, 0)
+	for _, code := range []uint8{0x02, 0x01} {
+		objectId := uint8(0)
+		for i := 0; i < 8; i++ {
+			d.transactionId++
+			d.result.Requests++
+			pdu, err := d.exchange([]byte{uint8(runtime.EncapsulatedInterface), runtime.ReadDeviceIdentification, code, objectId}, 253)
+			if err != nil || len(pdu) < 7 || pdu[1] != runtime.ReadDeviceIdentification {
+				klog.V(4).InfoS("Failed to read Modbus device identification", "readDeviceIdCode", code, "error", err)
+				break
+			}
+			moreFollows, nextObjectId, count := pdu[4], pdu[5], int(pdu[6])
+			offset := 7
+			for j := 0; j < count && offset+2 <= len(pdu); j++ {
+				id, length := pdu[offset], int(pdu[offset+1])
+				if offset+2+length > len(pdu) {
+					break
+				}
+				name, ok := DeviceIdentificationObjects[id]
+				if !ok {
+					name = "object" + strconv.Itoa(int(id))
+				}
+				objects[name] = string(pdu[offset+2 : offset+2+length])
+				offset += 2 + length
+			}
+			if moreFollows != 0xFF || nextObjectId <= objectId {
+				break
+			}
+			objectId = nextObjectId
+		}
+		if len(objects) > 0 {
+			d.result.Identification = objects
+			return
+		}
+	}
+}
+
+// sweep 自适应分块扫描一个地址段
+func (d *discoverer) sweep(ctx context.Context, ar *runtime.AddressRange) error {
+	functionCode := runtime.FunctionCode(ar.FunctionCode)
+	maxBlock := d.opts.MaxRegisters
+	if isBitFunctionCode(functionCode) {
+		maxBlock = d.opts.MaxCoils
+	}
+	block := maxBlock
+	timeouts := uint(0)
+	for address := ar.Start; address <= ar.End; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.result.Requests >= d.opts.MaxRequests {
+			d.addRange(functionCode, address, ar.End, RangeUnscanned, nil)
+			d.result.Truncated = true
+			return nil
+		}
+		quantity := min(block, ar.End-address+1)
+		values, err := d.read(functionCode, address, quantity)
+		if d.broken != nil {
+			return d.broken
+		}
+		var me *runtime.ModbusException
+		switch {
+		case err == nil:
+			timeouts = 0
+			d.addRange(functionCode, address, address+quantity-1, RangeResponsive, nil)
+			d.record(functionCode, address, values)
+			address += quantity
+			block = min(quantity*2, maxBlock)
+			continue
+		case errors.As(err, &me) && me.ExceptionCode == runtime.IllegalFunction:
+			d.addRange(functionCode, address, ar.End, RangeUnsupported, err)
+			return nil
+		case errors.As(err, &me) && me.ExceptionCode != runtime.GatewayPathUnavailable && me.ExceptionCode != runtime.GatewayTargetFailed:
+			timeouts = 0
+			if quantity == 1 {
+				d.addRange(functionCode, address, address, RangeIllegal, err)
+				address++
+			}
+		default:
+			// 无响应、响应错误或网关无法到达从站
+			timeouts++
+			if timeouts >= d.opts.MaxTimeouts {
+				d.addRange(functionCode, address, ar.End, RangeNoResponse, err)
+				return nil
+			}
+			if quantity == 1 {
+				d.addRange(functionCode, address, address, RangeNoResponse, err)
+				address++
+			}
+		}
+		block = max(quantity/2, 1)
+	}
+	return nil
+}
+
+// sample 按最大块大小重读响应的地址段, 共采样Samples次
+func (d *discoverer) sample(ctx context.Context) error {
+	for i := uint(1); i < d.opts.Samples; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(d.opts.SampleInterval) * time.Millisecond):
+		}
+		for _, r := range d.result.Ranges {
+			if r.Status != RangeResponsive {
+				continue
+			}
+			maxBlock := d.opts.MaxRegisters
+			if isBitFunctionCode(r.functionCode) {
+				maxBlock = d.opts.MaxCoils
+			}
+			for address := r.start; address <= r.end; address += maxBlock {
+				if d.result.Requests >= d.opts.MaxRequests {
+					d.result.Truncated = true
+					return nil
+				}
+				values, err := d.read(r.functionCode, address, min(maxBlock, r.end-address+1))
+				if d.broken != nil {
+					return d.broken
+				} else if err != nil {
+					continue
+				}
+				d.record(r.functionCode, address, values)
+			}
+		}
+	}
+	return nil
+}
+
+// read 读取线圈或寄存器, 线圈按0/1返回
+func (d *discoverer) read(functionCode runtime.FunctionCode, address, quantity uint) ([]uint16, error) {
+	byteCount := quantity * 2
+	if isBitFunctionCode(functionCode) {
+		byteCount = (quantity + 7) / 8
+	}
+	request := make([]byte, 5)
+	request[0] = uint8(functionCode)
+	binutils.WriteUint16BigEndian(request[1:], uint16(address))
+	binutils.WriteUint16BigEndian(request[3:], uint16(quantity))
+
+	d.transactionId++
+	d.result.Requests++
+	pdu, err := d.exchange(request, int(byteCount)+2)
+	if err != nil {
+		return nil, err
+	}
+	if len(pdu) != int(byteCount)+2 || uint(pdu[1]) != byteCount {
+		return nil, runtime.ErrModbusServerBadResp
+	}
+	values := make([]uint16, quantity)
+	for i := range values {
+		if isBitFunctionCode(functionCode) {
+			values[i] = uint16(pdu[2+i/8]>>(i%8)) & 1
+		} else {
+			values[i] = binutils.ParseUint16BigEndian(pdu[2+i*2:])
+		}
+	}
+	return values, nil
+}
+
+// exchange 非异常的失败后重建连接, 避免迟到的响应与后续请求错位
+func (d *discoverer) exchange(pdu []byte, responsePduLength int) ([]byte, error) {
+	response, err := d.broker.exchange(d.messenger, pdu, responsePduLength, d.transactionId)
+	var me *runtime.ModbusException
+	if err != nil && !errors.As(err, &me) {
+		d.messenger.Close()
+		messenger, nerr := d.broker.Clients.NewMessenger()
+		if nerr != nil {
+			klog.V(2).InfoS("Failed to reconnect Modbus device", "error", nerr)
+			d.broken = collector.ErrConnectDevice
+			return nil, err
+		}
+		d.messenger.Reset(messenger)
+	}
+	return response, err
+}
+
+// addRange 与上一个相邻且状态相同的地址段合并
+func (d *discoverer) addRange(functionCode runtime.FunctionCode, start, end uint, status string, err error) {
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	if n := len(d.result.Ranges); n > 0 {
+		last := d.result.Ranges[n-1]
+		if last.functionCode == functionCode && last.end+1 == start && last.Status == status && last.Error == reason {
+			last.end = end
+			last.End = formatVariableAddress(functionCode, end)
+			return
+		}
+	}
+	d.result.Ranges = append(d.result.Ranges, &DiscoveredRange{
+		Start:        formatVariableAddress(functionCode, start),
+		End:          formatVariableAddress(functionCode, end),
+		Status:       status,
+		Error:        reason,
+		functionCode: functionCode,
+		start:        start,
+		end:          end,
+	})
+}
+
+func (d *discoverer) record(functionCode runtime.FunctionCode, address uint, values []uint16) {
+	samples, ok := d.samples[functionCode]
+	if !ok {
+		samples = make(map[uint][]uint16, len(values))
+		d.samples[functionCode] = samples
+	}
+	for i, value := range values {
+		samples[address+uint(i)] = append(samples[address+uint(i)], value)
+	}
+}
+
+// draft 根据采样值为响应的地址生成草稿点位
+func (d *discoverer) draft() {
+	for _, r := range d.result.Ranges {
+		if r.Status != RangeResponsive {
+			continue
+		}
+		samples := d.samples[r.functionCode]
+		if isBitFunctionCode(r.functionCode) {
+			for address := r.start; address <= r.end; address++ {
+				values := samples[address]
+				d.addMapping(r.functionCode, address, common.BOOL, common.ABCD, 0, values[len(values)-1] == 1)
+			}
+			continue
+		}
+		for address := r.start; address <= r.end; {
+			if n := stringRun(samples, address, r.end); n >= 2 {
+				d.addMapping(r.functionCode, address, common.STRING, common.ABCD, n, decodeString(samples, address, n))
+				address += n
+				continue
+			}
+			if address < r.end {
+				if layout, value, ok := guessFloat32(samples[address], samples[address+1]); ok {
+					d.addMapping(r.functionCode, address, common.FLOAT32, layout, 0, value)
+					address += 2
+					continue
+				}
+			}
+			values := samples[address]
+			dataType := common.UINT16
+			var value interface{} = values[len(values)-1]
+			for _, v := range values {
+				if v&0x8000 > 0 {
+					dataType = common.INT16
+					value = int16(values[len(values)-1])
+					break
+				}
+			}
+			d.addMapping(r.functionCode, address, dataType, common.ABCD, 0, value)
+			address++
+		}
+	}
+}
+
+func (d *discoverer) addMapping(functionCode runtime.FunctionCode, address uint, dataType common.DataType, memoryLayout common.MemoryLayout, amount uint, value interface{}) {
+	accessMode := common.AccessModeReadOnly
+	if functionCode == runtime.ReadCoilStatus || functionCode == runtime.ReadHoldRegister {
+		accessMode = common.AccessModeReadWrite
+	}
+	mapping := &biz.Mapping{
+		AgentId:    d.opts.AgentId,
+		DataType:   common.DataTypeToString[dataType],
+		Name:       fmt.Sprintf("%s_%d", functionCodeNamePrefix[functionCode], address),
+		Variable:   formatVariableAddress(functionCode, address),
+		Amount:     amount,
+		Value:      value,
+		AccessMode: common.ReadWritePropertyToString[accessMode],
+	}
+	if dataType != common.BOOL {
+		mapping.MemoryLayout = common.MemoryLayoutToString[memoryLayout]
+	}
+	d.result.Mappings = append(d.result.Mappings, mapping)
+}
+
+var functionCodeNamePrefix = map[runtime.FunctionCode]string{
+	runtime.ReadCoilStatus:    "coil",
+	runtime.ReadInputStatus:   "di",
+	runtime.ReadInputRegister: "ir",
+	runtime.ReadHoldRegister:  "hr",
+}
+
+// stringRun 每次采样的高低字节均为可打印字符的连续寄存器数量, 末尾寄存器的低字节允许为0
+func stringRun(samples map[uint][]uint16, start, end uint) uint {
+	n := uint(0)
+	for address := start; address <= end; address++ {
+		terminated := false
+		for _, v := range samples[address] {
+			hi, lo := uint8(v>>8), uint8(v)
+			if !isPrintable(hi) || (!isPrintable(lo) && lo != 0) {
+				return n
+			}
+			terminated = terminated || lo == 0
+		}
+		n++
+		if terminated {
+			return n
+		}
+	}
+	return n
+}
+
+func isPrintable(b uint8) bool {
+	return b >= 0x20 && b <= 0x7E
+}
+
+func decodeString(samples map[uint][]uint16, start, amount uint) string {
+	bytes := make([]byte, 0, amount*2)
+	for address := start; address < start+amount; address++ {
+		values := samples[address]
+		v := values[len(values)-1]
+		bytes = append(bytes, uint8(v>>8), uint8(v))
+	}
+	for len(bytes) > 0 && bytes[len(bytes)-1] == 0 {
+		bytes = bytes[:len(bytes)-1]
+	}
+	return string(bytes)
+}
+
+// guessFloat32 两个寄存器的每次采样按ABCD或CDAB解释均为合理的浮点数时视为float32
+// 首个寄存器始终为0时不作为浮点数的起始, 避免与后一个浮点数的高位错位组合
+func guessFloat32(first, second []uint16) (common.MemoryLayout, float32, bool) {
+	n := min(len(first), len(second))
+	zero := true
+	for _, v := range first[:n] {
+		zero = zero && v == 0
+	}
+	if zero {
+		return common.ABCD, 0, false
+	}
+	for _, layout := range []common.MemoryLayout{common.ABCD, common.CDAB} {
+		plausible, nonzero := true, false
+		var value float32
+		for i := 0; i < n && plausible; i++ {
+			bits := uint32(first[i])<<16 | uint32(second[i])
+			if layout == common.CDAB {
+				bits = uint32(second[i])<<16 | uint32(first[i])
+			}
+			value = math.Float32frombits(bits)
+			if bits == 0 {
+				continue
+			}
+			nonzero = true
+			exponent := (bits >> 23) & 0xFF
+			abs := math.Abs(float64(value))
+			plausible = exponent != 0 && exponent != 0xFF && abs >= 1e-4 && abs <= 1e7
+		}
+		if plausible && nonzero {
+			return layout, value, true
+		}
+	}
+	return common.ABCD, 0, false
+}
+
+// formatVariableAddress 功能码与地址 => 变量地址, 例如 3 100 => 40100
+func formatVariableAddress(functionCode runtime.FunctionCode, address uint) string {
+	for area, fc := range AddressAreaFunctionCode {
+		if fc == functionCode {
+			return fmt.Sprintf("%c%04d", area, address)
+		}
+	}
+	return strconv.Itoa(int(address))
+}
+
+func isBitFunctionCode(functionCode runtime.FunctionCode) bool {
+	return functionCode == runtime.ReadCoilStatus || functionCode == runtime.ReadInputStatus
+}
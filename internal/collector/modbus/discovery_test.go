@@ -0,0 +1,150 @@
+package modbus
+
+import (
+	"context"
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/collector/modbus/slave"
+	"harnsplatform/internal/errors"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func newTestDiscoveryOptions(port int, ranges ...string) *biz.ModbusDiscoveryOptions {
+	return &biz.ModbusDiscoveryOptions{
+		Protocol:       "modbusTcp",
+		Slave:          1,
+		Address:        biz.JSONMap{"location": "127.0.0.1", "option": map[string]interface{}{"port": port}},
+		Ranges:         ranges,
+		Samples:        2,
+		SampleInterval: 1,
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	s, port := startTestSlave(t, false)
+	speed := math.Float32bits(1.5)
+	if err := s.SetRegisters(slave.HoldingRegister, 0, uint16(speed>>16), uint16(speed), 0x4142, 0x4344, 0x4500, 0xFFFE); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBits(slave.Coil, 3, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// 从站每张表64个地址, 超出部分返回非法地址
+	result, err := Discover(context.Background(), newTestDiscoveryOptions(port, "40000-40099", "00000-00009"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := make([]string, 0, len(result.Ranges))
+	for _, r := range result.Ranges {
+		ranges = append(ranges, r.Start+"-"+r.End+" "+r.Status)
+	}
+	if want := []string{"40000-40063 responsive", "40064-40099 illegal", "00000-00009 responsive"}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("got ranges %v, want %v", ranges, want)
+	}
+	if result.Truncated {
+		t.Fatal("result truncated")
+	}
+
+	mappings := make(map[string]*biz.Mapping, len(result.Mappings))
+	for _, mapping := range result.Mappings {
+		mappings[mapping.Variable] = mapping
+	}
+	tests := []struct {
+		variable string
+		name     string
+		dataType string
+		amount   uint
+		value    interface{}
+	}{
+		{"40000", "hr_0", "float32", 0, float32(1.5)},
+		{"40002", "hr_2", "string", 3, "ABCDE"},
+		{"40005", "hr_5", "int16", 0, int16(-2)},
+		{"40006", "hr_6", "uint16", 0, uint16(0)},
+		{"00003", "coil_3", "bool", 0, true},
+		{"00004", "coil_4", "bool", 0, false},
+	}
+	for _, tt := range tests {
+		mapping, ok := mappings[tt.variable]
+		if !ok {
+			t.Errorf("%s: mapping not found", tt.variable)
+			continue
+		}
+		if mapping.Name != tt.name || mapping.DataType != tt.dataType || mapping.Amount != tt.amount || mapping.Value != tt.value {
+			t.Errorf("%s: got %+v", tt.variable, mapping)
+		}
+	}
+	// 浮点数与字符串占用的后续寄存器不单独生成点位
+	for _, variable := range []string{"40001", "40003", "40004"} {
+		if _, ok := mappings[variable]; ok {
+			t.Errorf("%s: unexpected mapping", variable)
+		}
+	}
+	// 草稿点位可以直接导入
+	if _, err = ConvertDevice(newTestAgents(nil), result.Mappings); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 达到请求上限时剩余地址标记为未扫描
+func TestDiscoverTruncated(t *testing.T) {
+	_, port := startTestSlave(t, false)
+	opts := newTestDiscoveryOptions(port, "40000-40099")
+	opts.MaxRequests = 4
+	opts.MaxRegisters = 10
+	result, err := Discover(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := result.Ranges[len(result.Ranges)-1]
+	if !result.Truncated || last.Status != RangeUnscanned || last.End != "40099" || result.Requests != opts.MaxRequests {
+		t.Fatalf("got %+v, last range %+v", result, last)
+	}
+}
+
+func TestDiscoverOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *biz.ModbusDiscoveryOptions
+		err  error
+	}{
+		{"protocol", &biz.ModbusDiscoveryOptions{Protocol: "modbusFoo", Slave: 1}, runtime.ErrProtocolUnsupported},
+		{"broadcast slave", &biz.ModbusDiscoveryOptions{Protocol: "modbusTcp", Slave: 0}, runtime.ErrDiscoverySlaveInvalid},
+		{"slave", &biz.ModbusDiscoveryOptions{Protocol: "modbusTcp", Slave: 248}, runtime.ErrDiscoverySlaveInvalid},
+		{"range", &biz.ModbusDiscoveryOptions{Protocol: "modbusTcp", Slave: 1, Ranges: []string{"4000x"}}, runtime.ErrDiscoveryRangeInvalid},
+	}
+	for _, tt := range tests {
+		if _, err := Discover(context.Background(), tt.opts); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// 参数错误与通讯失败返回不同的错误码
+func TestAgentsManagerDiscover(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	tests := []struct {
+		name   string
+		opts   *biz.ModbusDiscoveryOptions
+		reason string
+	}{
+		{"slave invalid", &biz.ModbusDiscoveryOptions{Protocol: "modbusTcp", Slave: 0}, errors.ErrorReason_AGENTS_INVALID.String()},
+		{"connect failed", newTestDiscoveryOptions(closedPort, "40000-40009"), errors.ErrorReason_DISCOVERY_FAILED.String()},
+	}
+	for _, tt := range tests {
+		_, err := (&AgentsManager{}).Discover(context.Background(), tt.opts)
+		if reason := kerrors.Reason(err); reason != tt.reason {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package modbus
+
+import (
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+)
+
+// init 注册modbus协议驱动
+func init() {
+	collector.Register(&collector.DriverBase{
+		AgentsManager: &AgentsManager{},
+		Type:          common.MODBUS,
+		Caps: collector.DriverCapabilities{
+			Write:       true,
+			Discovery:   true,
+			Diagnostics: true,
+			FramePlan:   true,
+		},
+		NewAgentsFunc: func() pb.Agents {
+			return &pb.ModbusAgent{}
+		},
+		ConvertDeviceFunc: ConvertDevice,
+		NewBrokerFunc:     NewBroker,
+	})
+}
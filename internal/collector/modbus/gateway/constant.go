@@ -0,0 +1,7 @@
+package gateway
+
+import "errors"
+
+var ErrPointInvalid = errors.New("modbus gateway point invalid")
+var ErrPointOverlap = errors.New("modbus gateway point overlap")
+var ErrPropertyNotFound = errors.New("modbus gateway thing property not found")
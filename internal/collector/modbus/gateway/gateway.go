@@ -0,0 +1,277 @@
+package gateway
+
+import (
+	"context"
+	"github.com/go-kratos/kratos/v2/transport"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/collector/modbus/slave"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/conf"
+	"k8s.io/klog/v2"
+	"net"
+	"strconv"
+	"sync"
+)
+
+var _ collector.VariableSink = (*Gateway)(nil)
+var _ transport.Server = (*Gateway)(nil)
+
+/**
+北向网关
+将采集到的变量值按点位配置编码到虚拟寄存器表, 由modbus tcp服务对外提供读取
+客户端写入可写点位时, 按点位解析出的值通过DeliverAction下发到源设备, 下发成功后才更新寄存器表
+*/
+
+// Collector 网关依赖的采集管理能力, 由collector.Manager实现
+type Collector interface {
+	DeliverAction(id string, actions map[string]interface{}) ([]*collector.ActionResult, error)
+	ResolveProperty(thingId, property string) (string, string, bool)
+}
+
+type Point struct {
+	DeviceId string
+	Variable string
+	Writable bool
+	Binding  *slave.Binding
+}
+
+type Gateway struct {
+	Addr       string
+	collector  Collector
+	slave      *slave.Slave
+	server     *slave.Server
+	points     []*Point
+	byVariable map[string][]*Point // deviceId/variable => 点位
+	listener   net.Listener
+	mux        sync.Mutex
+}
+
+func NewGateway(cfg *conf.ModbusGateway, c Collector) (*Gateway, error) {
+	g := &Gateway{
+		Addr:       cfg.Addr,
+		collector:  c,
+		points:     make([]*Point, 0, len(cfg.Points)),
+		byVariable: make(map[string][]*Point, len(cfg.Points)),
+	}
+	// 各表按点位的最大地址确定大小
+	sizes := make(map[slave.Table]int, 4)
+	for _, pc := range cfg.Points {
+		point, err := g.newPoint(pc)
+		if err != nil {
+			klog.V(2).InfoS("Failed to create gateway point", "table", pc.Table, "address", pc.Address, "error", err)
+			return nil, err
+		}
+		for _, exist := range g.points {
+			if overlaps(exist, point) {
+				return nil, ErrPointOverlap
+			}
+		}
+		g.points = append(g.points, point)
+		key := point.DeviceId + "/" + point.Variable
+		g.byVariable[key] = append(g.byVariable[key], point)
+		if end := point.Binding.Address + point.Binding.Words(); end > sizes[point.Binding.Table] {
+			sizes[point.Binding.Table] = end
+		}
+	}
+
+	g.slave = slave.NewSlave(cfg.Slave, sizes[slave.Coil], sizes[slave.DiscreteInput], sizes[slave.HoldingRegister], sizes[slave.InputRegister])
+	g.slave.WriteHook = g.forward
+	g.server = slave.NewServer(nil, g.slave)
+	return g, nil
+}
+
+func (g *Gateway) newPoint(pc *conf.GatewayPoint) (*Point, error) {
+	point := &Point{
+		DeviceId: pc.DeviceId,
+		Variable: pc.Variable,
+		Writable: pc.Writable,
+	}
+	if len(pc.ThingId) > 0 {
+		deviceId, variable, ok := g.collector.ResolveProperty(pc.ThingId, pc.Property)
+		if !ok {
+			return nil, ErrPropertyNotFound
+		}
+		point.DeviceId, point.Variable = deviceId, variable
+	}
+	if len(point.DeviceId) == 0 || len(point.Variable) == 0 || pc.Address < 0 {
+		return nil, ErrPointInvalid
+	}
+
+	table, ok := slave.StringToTable[pc.Table]
+	if !ok {
+		return nil, ErrPointInvalid
+	}
+	if point.Writable && table != slave.Coil && table != slave.HoldingRegister {
+		return nil, ErrPointInvalid
+	}
+	binding := &slave.Binding{
+		Table:        table,
+		Address:      pc.Address,
+		DataType:     common.FLOAT32,
+		MemoryLayout: common.ABCD,
+	}
+	if len(pc.DataType) > 0 {
+		dataType, ok := common.StringToDataType[pc.DataType]
+		if !ok {
+			return nil, ErrPointInvalid
+		}
+		binding.DataType = dataType
+	}
+	switch binding.DataType {
+	case common.STRING, common.NUMBER, common.INT8, common.UINT8, common.BCD16, common.BCD32, common.BCD64:
+		return nil, slave.ErrDataTypeUnsupported
+	}
+	if len(pc.MemoryLayout) > 0 {
+		memoryLayout, ok := common.StringToMemoryLayout[pc.MemoryLayout]
+		if !ok {
+			return nil, ErrPointInvalid
+		}
+		binding.MemoryLayout = memoryLayout
+	}
+	point.Binding = binding
+	return point, nil
+}
+
+func overlaps(a, b *Point) bool {
+	if a.Binding.Table != b.Binding.Table {
+		return false
+	}
+	return a.Binding.Address < b.Binding.Address+b.Binding.Words() && b.Binding.Address < a.Binding.Address+a.Binding.Words()
+}
+
+// Start 开始监听modbus tcp, 随应用启动
+func (g *Gateway) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", g.Addr)
+	if err != nil {
+		klog.V(2).InfoS("Failed to listen modbus gateway", "addr", g.Addr, "error", err)
+		return err
+	}
+	g.mux.Lock()
+	g.listener = listener
+	g.mux.Unlock()
+	klog.V(2).InfoS("Succeed to start modbus gateway", "addr", listener.Addr())
+	go func() {
+		_ = g.server.ServeTcp(listener)
+	}()
+	return nil
+}
+
+// Stop 关闭监听, 随应用停止
+func (g *Gateway) Stop(ctx context.Context) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.listener != nil {
+		_ = g.listener.Close()
+		g.listener = nil
+	}
+	return nil
+}
+
+// Publish 用最新的采集值刷新寄存器表
+func (g *Gateway) Publish(deviceId string, values []collector.VariableValue) {
+	for _, vv := range values {
+		points, ok := g.byVariable[deviceId+"/"+vv.GetVariableName()]
+		if !ok {
+			continue
+		}
+		value, ok := toFloat64(vv.GetValue())
+		if !ok {
+			klog.V(5).InfoS("Unsupported gateway value", "deviceId", deviceId, "variable", vv.GetVariableName())
+			continue
+		}
+		for _, point := range points {
+			if err := g.slave.Apply(point.Binding, value); err != nil {
+				klog.V(2).InfoS("Failed to update gateway point", "deviceId", deviceId, "variable", point.Variable, "error", err)
+			}
+		}
+	}
+}
+
+// forward 将写入范围内的可写点位合并后下发, 写入只覆盖点位部分寄存器时其余寄存器取当前值
+// 一次写入只能涉及一个源设备, 跨设备的下发无法整体成功或整体失败
+func (g *Gateway) forward(table slave.Table, address int, values []uint16) error {
+	end := address + len(values)
+	deviceId := ""
+	actions := make(map[string]interface{}, 0)
+	for _, point := range g.points {
+		b := point.Binding
+		if b.Table != table || b.Address >= end || address >= b.Address+b.Words() {
+			continue
+		}
+		if !point.Writable || (len(deviceId) > 0 && deviceId != point.DeviceId) {
+			return &runtime.ModbusException{ExceptionCode: runtime.IllegalDataAddress}
+		}
+		deviceId = point.DeviceId
+		registers := make([]uint16, b.Words())
+		if table == slave.HoldingRegister {
+			current, err := g.slave.Registers(table, b.Address, b.Words())
+			if err != nil {
+				return err
+			}
+			copy(registers, current)
+		}
+		for i := range registers {
+			if at := b.Address + i; at >= address && at < end {
+				registers[i] = values[at-address]
+			}
+		}
+		value, err := slave.Decode(b, registers)
+		if err != nil {
+			return err
+		}
+		actions[point.Variable] = value
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	results, err := g.collector.DeliverAction(deviceId, actions)
+	if err != nil {
+		klog.V(2).InfoS("Failed to forward gateway write", "deviceId", deviceId, "error", err)
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			klog.V(2).InfoS("Failed to forward gateway write", "deviceId", deviceId, "variable", result.Name, "error", result.Err)
+			return result.Err
+		}
+	}
+	return nil
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
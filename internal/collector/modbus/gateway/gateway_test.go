@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/collector/modbus/slave"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/conf"
+	"reflect"
+	"testing"
+)
+
+// fakeCollector 记录下发的设备与变量值
+type fakeCollector struct {
+	deviceId string
+	actions  map[string]interface{}
+	err      error
+}
+
+func (c *fakeCollector) DeliverAction(id string, actions map[string]interface{}) ([]*collector.ActionResult, error) {
+	c.deviceId, c.actions = id, actions
+	results := make([]*collector.ActionResult, 0, len(actions))
+	for name, value := range actions {
+		results = append(results, &collector.ActionResult{Name: name, Value: value, Err: c.err})
+	}
+	return results, nil
+}
+
+func (c *fakeCollector) ResolveProperty(thingId, property string) (string, string, bool) {
+	return "", "", false
+}
+
+func newTestGateway(t *testing.T, c Collector) *Gateway {
+	t.Helper()
+	g, err := NewGateway(&conf.ModbusGateway{Slave: 1, Points: []*conf.GatewayPoint{
+		{DeviceId: "dev1", Variable: "temp", Table: "holdingRegister", Address: 0, Writable: true},
+		{DeviceId: "dev1", Variable: "count", Table: "holdingRegister", Address: 2, DataType: "int16", Writable: true},
+		{DeviceId: "dev2", Variable: "setpoint", Table: "holdingRegister", Address: 3, DataType: "uint16", Writable: true},
+		{DeviceId: "dev1", Variable: "running", Table: "holdingRegister", Address: 4, DataType: "bool", Writable: true},
+		{DeviceId: "dev1", Variable: "status", Table: "holdingRegister", Address: 5, DataType: "uint16"},
+		{DeviceId: "dev1", Variable: "pump", Table: "coil", Address: 0, DataType: "bool", Writable: true},
+	}}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// temp初始为2.0
+	if err = g.slave.SetRegisters(slave.HoldingRegister, 0, 0x4000, 0x0000); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestOverlaps(t *testing.T) {
+	point := func(table slave.Table, address int, dataType common.DataType) *Point {
+		return &Point{Binding: &slave.Binding{Table: table, Address: address, DataType: dataType}}
+	}
+	tests := []struct {
+		name string
+		a, b *Point
+		want bool
+	}{
+		{"same register", point(slave.HoldingRegister, 1, common.INT16), point(slave.HoldingRegister, 1, common.UINT16), true},
+		{"adjacent", point(slave.HoldingRegister, 0, common.FLOAT32), point(slave.HoldingRegister, 2, common.INT16), false},
+		{"inside wide value", point(slave.HoldingRegister, 0, common.FLOAT64), point(slave.HoldingRegister, 3, common.INT16), true},
+		{"wide value after", point(slave.HoldingRegister, 3, common.INT32), point(slave.HoldingRegister, 0, common.FLOAT64), true},
+		{"different table", point(slave.HoldingRegister, 0, common.INT16), point(slave.InputRegister, 0, common.INT16), false},
+		{"coils", point(slave.Coil, 5, common.BOOL), point(slave.Coil, 5, common.FLOAT64), true},
+		{"adjacent coils", point(slave.Coil, 5, common.BOOL), point(slave.Coil, 6, common.BOOL), false},
+	}
+	for _, tt := range tests {
+		if got := overlaps(tt.a, tt.b); got != tt.want || overlaps(tt.b, tt.a) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewGatewayOverlap(t *testing.T) {
+	_, err := NewGateway(&conf.ModbusGateway{Points: []*conf.GatewayPoint{
+		{DeviceId: "dev1", Variable: "a", Table: "holdingRegister", Address: 0},
+		{DeviceId: "dev1", Variable: "b", Table: "holdingRegister", Address: 1, DataType: "int16"},
+	}}, &fakeCollector{})
+	if err != ErrPointOverlap {
+		t.Fatalf("got %v, want %v", err, ErrPointOverlap)
+	}
+}
+
+func TestForward(t *testing.T) {
+	tests := []struct {
+		name      string
+		pdu       []byte
+		deviceId  string
+		actions   map[string]interface{}
+		exception runtime.ExceptionCode
+	}{
+		{
+			// 只写float32的高位寄存器, 低位取当前值
+			name:     "partial register",
+			pdu:      []byte{0x06, 0x00, 0x00, 0x40, 0x40},
+			deviceId: "dev1",
+			actions:  map[string]interface{}{"temp": float64(3)},
+		},
+		{
+			name:     "partial low register",
+			pdu:      []byte{0x06, 0x00, 0x01, 0x80, 0x00},
+			deviceId: "dev1",
+			actions:  map[string]interface{}{"temp": float64(2.0078125)},
+		},
+		{
+			name:     "merged points",
+			pdu:      []byte{0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0x00, 0x00, 0xFF, 0xFE},
+			deviceId: "dev1",
+			actions:  map[string]interface{}{"temp": float64(2), "count": float64(-2)},
+		},
+		{
+			// bool点位绑定保持寄存器时按bool下发
+			name:     "bool register",
+			pdu:      []byte{0x06, 0x00, 0x04, 0x00, 0x01},
+			deviceId: "dev1",
+			actions:  map[string]interface{}{"running": true},
+		},
+		{
+			name:     "coil",
+			pdu:      []byte{0x05, 0x00, 0x00, 0xFF, 0x00},
+			deviceId: "dev1",
+			actions:  map[string]interface{}{"pump": true},
+		},
+		{
+			name:      "multiple devices",
+			pdu:       []byte{0x10, 0x00, 0x02, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x02},
+			exception: runtime.IllegalDataAddress,
+		},
+		{
+			name:      "read only point",
+			pdu:       []byte{0x06, 0x00, 0x05, 0x00, 0x01},
+			exception: runtime.IllegalDataAddress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeCollector{}
+			g := newTestGateway(t, c)
+			response := g.slave.Handle(tt.pdu)
+			if tt.exception != 0 {
+				if response[0] != tt.pdu[0]|0x80 || runtime.ExceptionCode(response[1]) != tt.exception {
+					t.Fatalf("got response %x", response)
+				}
+				if c.actions != nil {
+					t.Fatalf("delivered %v", c.actions)
+				}
+				return
+			}
+			if response[0] != tt.pdu[0] {
+				t.Fatalf("got response %x", response)
+			}
+			if c.deviceId != tt.deviceId || !reflect.DeepEqual(c.actions, tt.actions) {
+				t.Fatalf("got %s %v, want %s %v", c.deviceId, c.actions, tt.deviceId, tt.actions)
+			}
+		})
+	}
+}
+
+// 下发失败时不更新寄存器表
+func TestForwardFailed(t *testing.T) {
+	g := newTestGateway(t, &fakeCollector{err: errors.New("device offline")})
+	response := g.slave.Handle([]byte{0x06, 0x00, 0x02, 0x00, 0x07})
+	if response[0] != 0x86 || runtime.ExceptionCode(response[1]) != runtime.SlaveDeviceFailure {
+		t.Fatalf("got response %x", response)
+	}
+	if registers, _ := g.slave.Registers(slave.HoldingRegister, 2, 1); registers[0] != 0 {
+		t.Fatalf("register updated to %04X", registers[0])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/imdario/mergo"
 	pb "harnsplatform/api/modelmanager/v1"
 	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus/runtime"
 	"harnsplatform/internal/common"
 	"harnsplatform/internal/errors"
 )
@@ -12,14 +13,43 @@ import (
 type AgentsManager struct {
 }
 
-func (m *AgentsManager) ValidateMappings(ctx context.Context, mappings []*biz.Mapping) error {
+// ValidateMappings 除转换设备时的校验外, 不允许两个mappings使用相同的地址
+func (m *AgentsManager) ValidateMappings(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) error {
+	device, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		return errors.GenerateMappingsInvalidError(err.Error())
+	}
+	if err := checkDuplicateAddresses(device.(*runtime.ModBusDevice).Variables); err != nil {
+		return errors.GenerateMappingsInvalidError(err.Error())
+	}
 	return nil
 }
 
+func (m *AgentsManager) GetFramePlan(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) (interface{}, error) {
+	device, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	return PlanReadFrames(device.(*runtime.ModBusDevice)), nil
+}
+
+// Discover 扫描从站并生成草稿点位, 参数错误与通讯失败分别返回
+func (m *AgentsManager) Discover(ctx context.Context, opts *biz.ModbusDiscoveryOptions) (*DiscoveryResult, error) {
+	result, err := Discover(ctx, opts)
+	switch err {
+	case nil:
+		return result, nil
+	case runtime.ErrProtocolUnsupported, runtime.ErrDiscoverySlaveInvalid, runtime.ErrDiscoveryRangeInvalid, runtime.ErrAgentAddressInvalid:
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	default:
+		return nil, errors.GenerateDiscoveryFailedError(err.Error())
+	}
+}
+
 func (m *AgentsManager) CreateAgents(ctx context.Context, agents pb.Agents) (*biz.Agents, error) {
 	modbusAgents, ok := agents.(*pb.ModbusAgent)
 	if !ok {
-		return nil, errors.GenerateAgentsUnsupportedError(common.AgentTypeToString[agents.GetAgentType()])
+		return nil, errors.GenerateAgentsUnsupportedError(agents.GetAgentType())
 	}
 	bz := &biz.Agents{
 		Name:             modbusAgents.Name,
@@ -42,5 +72,12 @@ func (m *AgentsManager) CreateAgents(ctx context.Context, agents pb.Agents) (*bi
 	}
 	bz.Address = av
 
+	if _, err := DecodeAgentDetails(bz.AgentDetails); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	if _, err := DecodeAgentAddress(bz.Address); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+
 	return bz, nil
 }
@@ -0,0 +1,85 @@
+package modbus
+
+import (
+	"context"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"strings"
+	"testing"
+)
+
+func newTestAgents(details biz.JSONMap) *biz.Agents {
+	agentDetails := biz.JSONMap{"protocol": "modbusTcp", "memoryLayout": "ABCD", "slave": 1}
+	for key, value := range details {
+		agentDetails[key] = value
+	}
+	return &biz.Agents{
+		Name:           "plc",
+		AgentType:      "modbus",
+		CollectorCycle: 1000,
+		AgentDetails:   agentDetails,
+		Address:        biz.JSONMap{"location": "127.0.0.1", "option": map[string]interface{}{"port": 502}},
+	}
+}
+
+func TestValidateMappings(t *testing.T) {
+	stored := []*biz.Mapping{
+		{Name: "temperature", Variable: "40001", DataType: "float32"},
+		{Name: "running", Variable: "40010.0", DataType: "bool"},
+	}
+	tests := []struct {
+		name     string
+		details  biz.JSONMap
+		mappings []*biz.Mapping
+		err      error
+	}{
+		{
+			name:     "new mappings",
+			mappings: []*biz.Mapping{{Name: "pressure", Variable: "40003", DataType: "float32"}, {Name: "alarm", Variable: "40010.1", DataType: "bool"}},
+		},
+		{
+			name:     "name duplicates stored mapping",
+			mappings: []*biz.Mapping{{Name: "temperature", Variable: "40020", DataType: "int16"}},
+			err:      runtime.ErrVariableNameDuplicate,
+		},
+		{
+			name:     "address duplicates stored mapping",
+			mappings: []*biz.Mapping{{Name: "raw", Variable: "40001", DataType: "uint16"}},
+			err:      runtime.ErrVariableAddressDuplicate,
+		},
+		{
+			name:     "bit duplicates stored mapping",
+			mappings: []*biz.Mapping{{Name: "started", Variable: "40010.0", DataType: "bool"}},
+			err:      runtime.ErrVariableAddressDuplicate,
+		},
+		{
+			name:     "address below position address",
+			details:  biz.JSONMap{"positionAddress": 1},
+			mappings: []*biz.Mapping{{Name: "first", Variable: "40000", DataType: "int16"}},
+			err:      runtime.ErrVariableBelowPositionAddress,
+		},
+		{
+			name:     "address in forbidden range",
+			details:  biz.JSONMap{"forbiddenRanges": []string{"40100-40110"}},
+			mappings: []*biz.Mapping{{Name: "forbidden", Variable: "40105", DataType: "int16"}},
+			err:      runtime.ErrVariableInForbiddenRange,
+		},
+	}
+
+	m := &AgentsManager{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings := append(append([]*biz.Mapping{}, stored...), tt.mappings...)
+			err := m.ValidateMappings(context.Background(), newTestAgents(tt.details), mappings)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err.Error()) {
+				t.Fatalf("got %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package modbus
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/collector/modbus/slave"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTestSlave 下位机号1, 每张表64个地址
+func startTestSlave(t *testing.T, rtuOverTcp bool) (*slave.Slave, int) {
+	t.Helper()
+	s := slave.NewSlave(1, 64, 64, 64, 64)
+	server := slave.NewServer(nil, s)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rtuOverTcp {
+		go server.ServeRtuOverTcp(l)
+	} else {
+		go server.ServeTcp(l)
+	}
+	t.Cleanup(func() { l.Close() })
+	return s, l.Addr().(*net.TCPAddr).Port
+}
+
+func newTestBroker(t *testing.T, details biz.JSONMap, port int, mappings []*biz.Mapping) *ModbusBroker {
+	t.Helper()
+	agents := newTestAgents(details)
+	agents.CollectorCycle = 20
+	agents.Address = biz.JSONMap{"location": "127.0.0.1", "option": map[string]interface{}{"port": port}}
+	device, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.IndexDevice()
+	broker, _, err := NewBroker(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return broker.(*ModbusBroker)
+}
+
+// waitValues 接收采集结果直到done返回true
+func waitValues(t *testing.T, ch chan *collector.ParseVariableResult, done func(values map[string]interface{}, errs []error) bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case pvr := <-ch:
+			values := make(map[string]interface{})
+			for _, v := range pvr.VariableSlice {
+				values[v.GetVariableName()] = v.GetValue()
+			}
+			if done(values, pvr.Err) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for values")
+		}
+	}
+}
+
+func newTestMappings() []*biz.Mapping {
+	return []*biz.Mapping{
+		{Name: "running", Variable: "00001", DataType: "bool", AccessMode: "rw"},
+		{Name: "pump", Variable: "00002", DataType: "bool", AccessMode: "rw"},
+		{Name: "door", Variable: "10001", DataType: "bool"},
+		{Name: "count", Variable: "40001", DataType: "int16", AccessMode: "rw"},
+		{Name: "speed", Variable: "40002", DataType: "float32", AccessMode: "rw"},
+		{Name: "total", Variable: "40004", DataType: "uint32", AccessMode: "rw", MemoryLayout: "CDAB"},
+		{Name: "name", Variable: "40006", DataType: "string", Amount: 3, AccessMode: "rw"},
+		{Name: "alarm", Variable: "40010.0", DataType: "bool", AccessMode: "rw"},
+		{Name: "mode", Variable: "40010.4-7", DataType: "int16", AccessMode: "rw"},
+		{Name: "bcd", Variable: "40011", DataType: "bcd16", AccessMode: "rw"},
+		{Name: "temperature", Variable: "40012", DataType: "int16", Rate: "0.1", Precision: "1", AccessMode: "rw"},
+		{Name: "level", Variable: "30001", DataType: "uint16"},
+	}
+}
+
+// setTestValues 按newTestMappings的地址写入从站
+func setTestValues(t *testing.T, s *slave.Slave) {
+	t.Helper()
+	speed := math.Float32bits(12.5)
+	for _, err := range []error{
+		s.SetBits(slave.Coil, 1, true, false),
+		s.SetBits(slave.DiscreteInput, 1, true),
+		s.SetRegisters(slave.HoldingRegister, 1, 0xFFFE, uint16(speed>>16), uint16(speed)),
+		// 70000 = 0x00011170, CDAB低位寄存器在前
+		s.SetRegisters(slave.HoldingRegister, 4, 0x1170, 0x0001),
+		s.SetRegisters(slave.HoldingRegister, 6, 0x6162, 0x6364, 0x6500),
+		s.SetRegisters(slave.HoldingRegister, 10, 0x80A1, 0x1234, 235),
+		s.SetRegisters(slave.InputRegister, 1, 500),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var brokerTests = []struct {
+	name       string
+	details    biz.JSONMap
+	rtuOverTcp bool
+}{
+	{name: "tcp"},
+	{name: "tcp verify write", details: biz.JSONMap{"verifyWrite": true, "maskWrite": true}},
+	{name: "tcp read write", details: biz.JSONMap{"writeMode": "readWrite", "maxGap": 0}},
+	{name: "tcp pipeline", details: biz.JSONMap{"maxInFlight": 4, "maxGap": 0}},
+	{name: "rtu over tcp", details: biz.JSONMap{"protocol": "modbusRtuOverTcp"}, rtuOverTcp: true},
+}
+
+func TestBrokerCollect(t *testing.T) {
+	for _, tt := range brokerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, port := startTestSlave(t, tt.rtuOverTcp)
+			setTestValues(t, s)
+			broker := newTestBroker(t, tt.details, port, newTestMappings())
+			broker.Collect(context.Background())
+			defer broker.Destroy(context.Background())
+
+			want := map[string]interface{}{
+				"running":     true,
+				"pump":        false,
+				"door":        true,
+				"count":       int16(-2),
+				"speed":       float32(12.5),
+				"total":       uint32(70000),
+				"name":        "abcde",
+				"alarm":       true,
+				"mode":        int16(-6),
+				"bcd":         uint16(1234),
+				"temperature": 23.5,
+				"level":       uint16(500),
+			}
+			waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+				if len(errs) > 0 {
+					t.Fatalf("got errors %v", errs)
+				}
+				for key, value := range want {
+					if values[key] != value {
+						t.Errorf("%s: got %v(%T), want %v(%T)", key, values[key], values[key], value, value)
+					}
+				}
+				return true
+			})
+
+			// 从站数据变化后下一个周期采集到新值
+			if err := s.SetRegisters(slave.HoldingRegister, 1, 300); err != nil {
+				t.Fatal(err)
+			}
+			waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+				return values["count"] == int16(300)
+			})
+		})
+	}
+}
+
+func TestBrokerDeliverAction(t *testing.T) {
+	for _, tt := range brokerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, port := startTestSlave(t, tt.rtuOverTcp)
+			setTestValues(t, s)
+			broker := newTestBroker(t, tt.details, port, newTestMappings())
+			broker.Collect(context.Background())
+			defer broker.Destroy(context.Background())
+
+			results, err := broker.DeliverAction(context.Background(), map[string]interface{}{
+				"running":     false,
+				"pump":        "true",
+				"count":       -100.0,
+				"speed":       1.5,
+				"total":       123456.0,
+				"name":        "xy",
+				"alarm":       false,
+				"mode":        3.0,
+				"bcd":         "4321",
+				"temperature": 30.2,
+				"level":       1.0,
+				"unknown":     1.0,
+			})
+			var multiErr *collector.MultiError
+			if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+				t.Fatalf("got %v", err)
+			}
+			for _, result := range results {
+				var want error
+				switch result.Name {
+				case "level":
+					want = runtime.ErrVariableReadOnly
+				case "unknown":
+					want = runtime.ErrVariableNotFound
+				}
+				if result.Err != want {
+					t.Errorf("%s: got %v, want %v", result.Name, result.Err, want)
+				}
+			}
+
+			speed := math.Float32bits(1.5)
+			registers, err := s.Registers(slave.HoldingRegister, 1, 12)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 位写入保留寄存器中的其它位
+			want := []uint16{0xFF9C, uint16(speed >> 16), uint16(speed), 0xE240, 0x0001, 0x7879, 0, 0, 0, 0x8030, 0x4321, 302}
+			for i := range want {
+				if registers[i] != want[i] {
+					t.Errorf("register %d: got %04X, want %04X", i+1, registers[i], want[i])
+				}
+			}
+			waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+				return values["running"] == false && values["pump"] == true && values["count"] == int16(-100) &&
+					values["name"] == "xy" && values["mode"] == int16(3) && values["alarm"] == false && values["temperature"] == 30.2
+			})
+
+			// 超出数据类型或位宽范围的值不下发
+			results, err = broker.DeliverAction(context.Background(), map[string]interface{}{
+				"count": 40000.0,
+				"mode":  8.0,
+				"name":  "abcdefg",
+			})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, result := range results {
+				if !errors.Is(result.Err, runtime.ErrActionValueInvalid) || result.Status != collector.ActionFailed {
+					t.Errorf("%s: got %v, status %s", result.Name, result.Err, result.Status)
+				}
+			}
+		})
+	}
+}
+
+// 无法获取连接时每个待下发变量返回连接错误, 校验失败的变量保留原错误
+func TestBrokerDeliverActionConnectFailed(t *testing.T) {
+	_, port := startTestSlave(t, false)
+	broker := newTestBroker(t, nil, port, newTestMappings())
+	errDial := errors.New("dial failed")
+	broker.Clients = &runtime.Clients{
+		Messengers:   list.New(),
+		Mux:          &sync.Mutex{},
+		ConnRequests: make(map[uint64]chan runtime.Messenger, 0),
+		NewMessenger: func() (runtime.Messenger, error) {
+			return nil, errDial
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := broker.DeliverAction(ctx, map[string]interface{}{
+		"count": -100.0,
+		"speed": 1.5,
+		"level": 1.0,
+	})
+	var multiErr *collector.MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 3 || len(results) != 3 {
+		t.Fatalf("got %v, %v", results, err)
+	}
+	for _, result := range results {
+		want := errDial
+		if result.Name == "level" {
+			want = runtime.ErrVariableReadOnly
+		}
+		if result.Err != want || result.Status != collector.ActionFailed {
+			t.Errorf("%s: got %v, status %s", result.Name, result.Err, result.Status)
+		}
+	}
+}
+
+// 从站异常只影响所在的报文
+func TestBrokerException(t *testing.T) {
+	_, port := startTestSlave(t, false)
+	broker := newTestBroker(t, biz.JSONMap{"maxGap": 0}, port, []*biz.Mapping{
+		{Name: "inside", Variable: "40001", DataType: "int16", AccessMode: "rw"},
+		{Name: "outside", Variable: "40100", DataType: "int16", AccessMode: "rw"},
+	})
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		var me *runtime.ModbusException
+		if len(errs) != 1 || !errors.As(errs[0], &me) || me.ExceptionCode != runtime.IllegalDataAddress {
+			t.Fatalf("got errors %v", errs)
+		}
+		if _, ok := values["inside"]; !ok || len(values) != 1 {
+			t.Fatalf("got values %v", values)
+		}
+		return true
+	})
+
+	results, _ := broker.DeliverAction(context.Background(), map[string]interface{}{"inside": 1.0, "outside": 1.0})
+	for _, result := range results {
+		var me *runtime.ModbusException
+		switch result.Name {
+		case "inside":
+			if result.Err != nil || result.Status != collector.ActionSuccess {
+				t.Errorf("inside: got %v, status %s", result.Err, result.Status)
+			}
+		case "outside":
+			if !errors.As(result.Err, &me) || me.ExceptionCode != runtime.IllegalDataAddress {
+				t.Errorf("outside: got %v", result.Err)
+			}
+		}
+	}
+}
+
+func TestNewBrokerConnectFailed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	agents := newTestAgents(nil)
+	agents.Address = biz.JSONMap{"location": "127.0.0.1", "option": map[string]interface{}{"port": port}}
+	device, err := ConvertDevice(agents, []*biz.Mapping{{Name: "count", Variable: "40001", DataType: "int16"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = NewBroker(device); err != collector.ErrConnectDevice {
+		t.Fatalf("got %v, want %v", err, collector.ErrConnectDevice)
+	}
+}
+
+// 各扫描类别按自身周期独立采集, 结果分别发送
+func TestBrokerScanClasses(t *testing.T) {
+	_, port := startTestSlave(t, false)
+	broker := newTestBroker(t, biz.JSONMap{"scanClasses": []map[string]interface{}{
+		{"name": "slow", "collectorCycle": 10000},
+		{"name": "fast", "collectorCycle": 20},
+	}}, port, []*biz.Mapping{
+		{Name: "default", Variable: "40001", DataType: "int16"},
+		{Name: "slow", Variable: "40002", DataType: "int16", ScanClass: "slow"},
+		{Name: "fast", Variable: "40003", DataType: "int16", ScanClass: "fast"},
+	})
+	if len(broker.ScanGroups) != 3 {
+		t.Fatalf("got %d scan groups", len(broker.ScanGroups))
+	}
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+
+	counts := make(map[string]int)
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		if len(values) != 1 {
+			t.Fatalf("got values %v from one scan group", values)
+		}
+		for name := range values {
+			counts[name]++
+		}
+		return counts["fast"] >= 5
+	})
+	if counts["slow"] != 1 {
+		t.Fatalf("slow class collected %d times", counts["slow"])
+	}
+}
+
+// 采集结果无人接收时Destroy不阻塞, 关闭VariableCh后不再发送
+func TestBrokerDestroy(t *testing.T) {
+	s, port := startTestSlave(t, false)
+	setTestValues(t, s)
+	broker := newTestBroker(t, biz.JSONMap{"scanClasses": []map[string]interface{}{{"name": "fast", "collectorCycle": 5}}},
+		port, append(newTestMappings(), &biz.Mapping{Name: "fast", Variable: "40020", DataType: "int16", ScanClass: "fast"}))
+	broker.Collect(context.Background())
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		broker.Destroy(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Destroy blocked")
+	}
+	for range broker.VariableCh {
+	}
+}
@@ -8,11 +8,17 @@ import (
 var _ ModbusModeler = (*ModbusTcp)(nil)
 var _ ModbusModeler = (*ModbusRtu)(nil)
 var _ ModbusModeler = (*ModbusRtuOverTcp)(nil)
+var _ ModbusModeler = (*ModbusAscii)(nil)
+var _ ModbusModeler = (*ModbusAsciiOverTcp)(nil)
+var _ ModbusModeler = (*ModbusUdp)(nil)
 
 var ModbusModelers = map[string]ModbusModeler{
-	"modbusTcp":        &ModbusTcp{},
-	"modbusRtu":        &ModbusRtu{},
-	"modbusRtuOverTcp": &ModbusRtuOverTcp{},
+	"modbusTcp":          &ModbusTcp{},
+	"modbusRtu":          &ModbusRtu{},
+	"modbusRtuOverTcp":   &ModbusRtuOverTcp{},
+	"modbusAscii":        &ModbusAscii{},
+	"modbusAsciiOverTcp": &ModbusAsciiOverTcp{},
+	"modbusUdp":          &ModbusUdp{},
 }
 
 type ModbusModeler interface {
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"container/list"
+	"encoding/hex"
+	"go.bug.st/serial"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils"
+	"harnsplatform/internal/utils/binutils"
+	"strings"
+	"sync"
+)
+
+// AsciiNonDataLength ':' + 地址(2) + 功能码(2) + 字节数(2) + LRC(2) + CRLF(2)
+const AsciiNonDataLength = 11
+
+const (
+	AsciiStart = ':'
+	AsciiCR    = '\r'
+	AsciiLF    = '\n'
+)
+
+type ModbusAscii struct {
+}
+
+func (m *ModbusAscii) NewClients(address *runtime.Address, dataFrameCount int) (*runtime.Clients, error) {
+	mode := &serial.Mode{
+		BaudRate: address.Option.BaudRate,
+		Parity:   runtime.ParityToParity[address.Option.Parity],
+		DataBits: address.Option.DataBits,
+		StopBits: runtime.StopBitsToStopBits[address.Option.StopBits],
+	}
+	// 同一串口上的设备共享总线
+	bus, ownerId, err := runtime.SerialBuses.Acquire(address.Location, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := list.New()
+	cs.PushBack(&runtime.SerialBusClient{
+		Timeout:    1,
+		Ascii:      true,
+		Bus:        bus,
+		OwnerId:    ownerId,
+		EchoCancel: address.Option.EchoCancel,
+	})
+
+	clients := &runtime.Clients{
+		Messengers:   cs,
+		Max:          1,
+		Idle:         1,
+		Mux:          &sync.Mutex{},
+		NextRequest:  1,
+		ConnRequests: make(map[uint64]chan runtime.Messenger, 0),
+		NewMessenger: func() (runtime.Messenger, error) {
+			newBus, newOwnerId, err := runtime.SerialBuses.Acquire(address.Location, mode)
+			if err != nil {
+				return nil, err
+			}
+			return &runtime.SerialBusClient{
+				Timeout:    1,
+				Ascii:      true,
+				Bus:        newBus,
+				OwnerId:    newOwnerId,
+				EchoCancel: address.Option.EchoCancel,
+			}, nil
+		},
+	}
+	return clients, nil
+}
+
+func (m *ModbusAscii) GenerateReadMessage(slave uint, functionCode uint8, startAddress uint, maxDataSize uint, variables []*runtime.VariableParse, memoryLayout common.MemoryLayout) *runtime.ModBusDataFrame {
+	return generateAsciiReadMessage(slave, functionCode, startAddress, maxDataSize, variables, memoryLayout)
+}
+
+func generateAsciiReadMessage(slave uint, functionCode uint8, startAddress uint, maxDataSize uint, variables []*runtime.VariableParse, memoryLayout common.MemoryLayout) *runtime.ModBusDataFrame {
+	// :01030000000AF2\r\n
+	// :  起始符
+	// 01  设备地址
+	// 03  功能码
+	// 0000  起始地址
+	// 000A  寄存器数量(word数量)/线圈数量
+	// F2  LRC检验码
+	// \r\n  结束符
+	adu := make([]byte, 6)
+	adu[0] = byte(slave)
+	adu[1] = functionCode
+	binutils.WriteUint16BigEndian(adu[2:], uint16(startAddress))
+	binutils.WriteUint16BigEndian(adu[4:], uint16(maxDataSize))
+	message := EncodeAsciiFrame(adu)
+
+	bytesLength := 0
+	switch runtime.FunctionCode(functionCode) {
+	case runtime.ReadCoilStatus, runtime.ReadInputStatus:
+		if maxDataSize%8 == 0 {
+			bytesLength = int(maxDataSize/8*2 + AsciiNonDataLength)
+		} else {
+			bytesLength = int((maxDataSize/8+1)*2 + AsciiNonDataLength)
+		}
+	case runtime.ReadHoldRegister, runtime.ReadInputRegister:
+		bytesLength = int(maxDataSize*4 + AsciiNonDataLength)
+	}
+
+	df := &runtime.ModBusDataFrame{
+		Slave:             slave,
+		MemoryLayout:      memoryLayout,
+		StartAddress:      startAddress,
+		FunctionCode:      functionCode,
+		MaxDataSize:       maxDataSize,
+		TransactionId:     0,
+		DataFrame:         message,
+		ResponseDataFrame: make([]byte, bytesLength),
+		Variables:         make([]*runtime.VariableParse, 0, len(variables)),
+	}
+	df.Variables = append(df.Variables, variables...)
+
+	return df
+}
+
+// EncodeAsciiFrame 地址+pdu 追加LRC后编码为 ':' + HEX + CRLF
+func EncodeAsciiFrame(adu []byte) []byte {
+	data := make([]byte, 0, len(adu)+1)
+	data = append(data, adu...)
+	data = append(data, utils.CheckLrcsum(adu))
+
+	frame := make([]byte, 0, len(data)*2+3)
+	frame = append(frame, AsciiStart)
+	frame = append(frame, strings.ToUpper(hex.EncodeToString(data))...)
+	frame = append(frame, AsciiCR, AsciiLF)
+	return frame
+}
+
+// DecodeAsciiFrame 校验起始符、结束符及LRC,返回地址+pdu
+func DecodeAsciiFrame(frame []byte) ([]byte, error) {
+	n := len(frame)
+	if n < AsciiNonDataLength-2 || frame[0] != AsciiStart || frame[n-2] != AsciiCR || frame[n-1] != AsciiLF {
+		return nil, runtime.ErrAsciiFrameInvalid
+	}
+	data := make([]byte, (n-3)/2)
+	if _, err := hex.Decode(data, frame[1:n-2]); err != nil {
+		return nil, runtime.ErrAsciiFrameInvalid
+	}
+	if !utils.ValidateLrcsum(data) {
+		return nil, runtime.ErrLRCError
+	}
+	return data[:len(data)-1], nil
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"bytes"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/utils"
+	"testing"
+)
+
+func TestCheckLrcsum(t *testing.T) {
+	tests := []struct {
+		data []byte
+		lrc  uint8
+	}{
+		{[]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x01}, 0xFB},
+		{[]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03}, 0x7E},
+		{[]byte{0x80}, 0x80},
+		{[]byte{0xFF, 0x01}, 0x00},
+	}
+	for _, tt := range tests {
+		if lrc := utils.CheckLrcsum(tt.data); lrc != tt.lrc {
+			t.Errorf("% X: got %02X, want %02X", tt.data, lrc, tt.lrc)
+		}
+		if !utils.ValidateLrcsum(append(tt.data, tt.lrc)) {
+			t.Errorf("% X: lrc %02X not valid", tt.data, tt.lrc)
+		}
+	}
+}
+
+func TestEncodeAsciiFrame(t *testing.T) {
+	frame := EncodeAsciiFrame([]byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03})
+	if want := ":1103006B00037E\r\n"; string(frame) != want {
+		t.Fatalf("got %q, want %q", frame, want)
+	}
+}
+
+func TestDecodeAsciiFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		adu   []byte
+		err   error
+	}{
+		{"read response", ":010302002AD0\r\n", []byte{0x01, 0x03, 0x02, 0x00, 0x2A}, nil},
+		{"lower case", ":1103006b00037e\r\n", []byte{0x11, 0x03, 0x00, 0x6B, 0x00, 0x03}, nil},
+		{"lrc error", ":1103006B00037F\r\n", nil, runtime.ErrLRCError},
+		{"missing start", "1103006B00037E\r\n", nil, runtime.ErrAsciiFrameInvalid},
+		{"missing crlf", ":1103006B00037E\n", nil, runtime.ErrAsciiFrameInvalid},
+		{"odd hex", ":1103006B00037\r\n", nil, runtime.ErrAsciiFrameInvalid},
+		{"not hex", ":1103006G00037E\r\n", nil, runtime.ErrAsciiFrameInvalid},
+		{"too short", ":01\r\n", nil, runtime.ErrAsciiFrameInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adu, err := DecodeAsciiFrame([]byte(tt.frame))
+			if err != tt.err || !bytes.Equal(adu, tt.adu) {
+				t.Fatalf("got % X, %v, want % X, %v", adu, err, tt.adu, tt.err)
+			}
+		})
+	}
+
+	// 编码后可以解码回原报文
+	adu := []byte{0x01, 0x10, 0x00, 0x01, 0x00, 0x02, 0x04, 0xFF, 0x9C, 0x3F, 0xC0}
+	if decoded, err := DecodeAsciiFrame(EncodeAsciiFrame(adu)); err != nil || !bytes.Equal(decoded, adu) {
+		t.Fatalf("got % X, %v", decoded, err)
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"container/list"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+	"net"
+	"strconv"
+	"sync"
+)
+
+type ModbusAsciiOverTcp struct {
+}
+
+func (m *ModbusAsciiOverTcp) NewClients(address *runtime.Address, dataFrameCount int) (*runtime.Clients, error) {
+	tcpChannel := dataFrameCount/5 + 1
+	addr := net.JoinHostPort(address.Location, strconv.Itoa(address.Option.Port))
+	cs := list.New()
+	for i := 0; i < tcpChannel; i++ {
+		tunnel, err := net.Dial("tcp", addr)
+		if err != nil {
+			klog.V(2).InfoS("Failed to connect modbus server", "error", err)
+			return nil, err
+		}
+		c := &runtime.TcpClient{
+			Tunnel:  tunnel,
+			Timeout: 1,
+			Ascii:   true,
+		}
+		cs.PushBack(c)
+	}
+
+	clients := &runtime.Clients{
+		Messengers:   cs,
+		Max:          tcpChannel,
+		Idle:         tcpChannel,
+		Mux:          &sync.Mutex{},
+		NextRequest:  1,
+		ConnRequests: make(map[uint64]chan runtime.Messenger, 0),
+		NewMessenger: func() (runtime.Messenger, error) {
+			tunnel, err := net.Dial("tcp", addr)
+			if err != nil {
+				klog.V(2).InfoS("Failed to connect modbus server", "error", err)
+				return nil, err
+			}
+			return &runtime.TcpClient{
+				Tunnel:  tunnel,
+				Timeout: 1,
+				Ascii:   true,
+			}, nil
+		},
+	}
+	return clients, nil
+}
+
+func (m *ModbusAsciiOverTcp) GenerateReadMessage(slave uint, functionCode uint8, startAddress uint, maxDataSize uint, variables []*runtime.VariableParse, memoryLayout common.MemoryLayout) *runtime.ModBusDataFrame {
+	return generateAsciiReadMessage(slave, functionCode, startAddress, maxDataSize, variables, memoryLayout)
+}
@@ -7,7 +7,6 @@ import (
 	"harnsplatform/internal/common"
 	"harnsplatform/internal/utils"
 	"harnsplatform/internal/utils/binutils"
-	"k8s.io/klog/v2"
 	"sync"
 )
 
@@ -23,16 +22,18 @@ func (m *ModbusRtu) NewClients(address *runtime.Address, dataFrameCount int) (*r
 		DataBits: address.Option.DataBits,
 		StopBits: runtime.StopBitsToStopBits[address.Option.StopBits],
 	}
-	port, err := serial.Open(address.Location, mode)
+	// 同一串口上的设备共享总线
+	bus, ownerId, err := runtime.SerialBuses.Acquire(address.Location, mode)
 	if err != nil {
-		klog.V(2).InfoS("Failed to connect serial port", "address", address.Location)
 		return nil, err
 	}
 
 	cs := list.New()
-	cs.PushBack(&runtime.SerialClient{
-		Timeout: 1,
-		Port:    port,
+	cs.PushBack(&runtime.SerialBusClient{
+		Timeout:    1,
+		Bus:        bus,
+		OwnerId:    ownerId,
+		EchoCancel: address.Option.EchoCancel,
 	})
 
 	clients := &runtime.Clients{
@@ -43,14 +44,15 @@ func (m *ModbusRtu) NewClients(address *runtime.Address, dataFrameCount int) (*r
 		NextRequest:  1,
 		ConnRequests: make(map[uint64]chan runtime.Messenger, 0),
 		NewMessenger: func() (runtime.Messenger, error) {
-			newPort, err := serial.Open(address.Location, mode)
+			newBus, newOwnerId, err := runtime.SerialBuses.Acquire(address.Location, mode)
 			if err != nil {
-				klog.V(2).InfoS("Failed to connect serial port", "address", address.Location)
 				return nil, err
 			}
-			return &runtime.SerialClient{
-				Timeout: 1,
-				Port:    newPort,
+			return &runtime.SerialBusClient{
+				Timeout:    1,
+				Bus:        newBus,
+				OwnerId:    newOwnerId,
+				EchoCancel: address.Option.EchoCancel,
 			}, nil
 		},
 	}
@@ -8,7 +8,9 @@ import (
 	"harnsplatform/internal/utils/binutils"
 	"k8s.io/klog/v2"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 const TcpNonDataLength = 9
@@ -55,6 +57,45 @@ func (m *ModbusTcp) NewClients(address *runtime.Address, dataFrameCount int) (*r
 	return clients, nil
 }
 
+// NewPipelineClients maxConnections个连接, 每个连接同时保持maxInFlight个未完成的请求
+func (m *ModbusTcp) NewPipelineClients(address *runtime.Address, maxConnections int, maxInFlight int) (*runtime.Clients, error) {
+	addr := net.JoinHostPort(address.Location, strconv.Itoa(address.Option.Port))
+	transports := make([]*runtime.PipelineTransport, 0, maxConnections)
+	cs := list.New()
+	var next uint64
+	for i := 0; i < maxConnections; i++ {
+		transport := &runtime.PipelineTransport{
+			Dial: func() (net.Conn, error) {
+				return net.Dial("tcp", addr)
+			},
+		}
+		transports = append(transports, transport)
+		for j := 0; j < maxInFlight; j++ {
+			cs.PushBack(&runtime.PipelineClient{
+				Timeout:   1,
+				Transport: transport,
+			})
+		}
+	}
+
+	return &runtime.Clients{
+		Messengers:   cs,
+		Max:          maxConnections * maxInFlight,
+		Idle:         maxConnections * maxInFlight,
+		Mux:          &sync.Mutex{},
+		NextRequest:  1,
+		ConnRequests: make(map[uint64]chan runtime.Messenger, 0),
+		// 临时槽位轮流分配到各个连接上, 避免请求集中在第一个连接
+		NewMessenger: func() (runtime.Messenger, error) {
+			i := atomic.AddUint64(&next, 1)
+			return &runtime.PipelineClient{
+				Timeout:   1,
+				Transport: transports[i%uint64(len(transports))],
+			}, nil
+		},
+	}, nil
+}
+
 func (m *ModbusTcp) GenerateReadMessage(slave uint, functionCode uint8, startAddress uint, maxDataSize uint, variables []*runtime.VariableParse, memoryLayout common.MemoryLayout) *runtime.ModBusDataFrame {
 	// 00 01 00 00 00 06 18 03 00 02 00 02
 	// 00 01  此次通信事务处理标识符，一般每次通信之后将被要求加1以区别不同的通信数据报文
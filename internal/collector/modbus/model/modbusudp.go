@@ -0,0 +1,63 @@
+package model
+
+import (
+	"container/list"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"k8s.io/klog/v2"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// UdpRetransmit 单次请求超时后的重发次数
+const UdpRetransmit = 2
+
+// ModbusUdp Modbus/TCP(MBAP)报文通过UDP传输,报文规划与ModbusTcp一致
+type ModbusUdp struct {
+	ModbusTcp
+}
+
+func (m *ModbusUdp) NewClients(address *runtime.Address, dataFrameCount int) (*runtime.Clients, error) {
+	udpChannel := dataFrameCount/5 + 1
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(address.Location, strconv.Itoa(address.Option.Port)))
+	if err != nil {
+		klog.V(2).InfoS("Failed to resolve Modbus server address", "error", err)
+		return nil, err
+	}
+	cs := list.New()
+	for i := 0; i < udpChannel; i++ {
+		conn, err := net.DialUDP("udp", nil, addr)
+		if err != nil {
+			klog.V(2).InfoS("Failed to connect Modbus server", "error", err)
+			return nil, err
+		}
+		c := &runtime.UdpClient{
+			Conn:       conn,
+			Timeout:    1,
+			Retransmit: UdpRetransmit,
+		}
+		cs.PushBack(c)
+	}
+
+	clients := &runtime.Clients{
+		Messengers:   cs,
+		Max:          udpChannel,
+		Idle:         udpChannel,
+		Mux:          &sync.Mutex{},
+		NextRequest:  1,
+		ConnRequests: make(map[uint64]chan runtime.Messenger, 0),
+		NewMessenger: func() (runtime.Messenger, error) {
+			conn, err := net.DialUDP("udp", nil, addr)
+			if err != nil {
+				klog.V(2).InfoS("Failed to connect modbus server", "error", err)
+				return nil, err
+			}
+			return &runtime.UdpClient{
+				Conn:       conn,
+				Timeout:    1,
+				Retransmit: UdpRetransmit,
+			}, nil
+		},
+	}
+	return clients, nil
+}
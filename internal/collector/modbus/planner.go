@@ -0,0 +1,121 @@
+package modbus
+
+import (
+	"harnsplatform/internal/collector/modbus/runtime"
+	"sort"
+)
+
+// ReadFramePlan 一个读报文覆盖的地址段及其变量
+type ReadFramePlan struct {
+	ScanClass     string                   `json:"scanClass,omitempty"` // 扫描类别, 为空时为默认类别
+	FunctionCode  uint8                    `json:"functionCode"`
+	StartAddress  uint                     `json:"startAddress"` // 报文起始地址, 已减去设备起始地址
+	Quantity      uint                     `json:"quantity"`     // 线圈数量或寄存器数量
+	VariableNames []string                 `json:"variables"`
+	Variables     []*runtime.VariableParse `json:"-"`
+}
+
+// PlanReadFrames 按扫描类别分别规划读报文, 默认类别在前, 其余按配置顺序
+func PlanReadFrames(device *runtime.ModBusDevice) []*ReadFramePlan {
+	scanClassVariableMap := make(map[string][]*runtime.Variable, 0)
+	for _, variable := range device.Variables {
+		scanClassVariableMap[variable.ScanClass] = append(scanClassVariableMap[variable.ScanClass], variable)
+	}
+	plans := planScanClass(device, "", scanClassVariableMap[""])
+	for _, sc := range device.ScanClasses {
+		plans = append(plans, planScanClass(device, sc.Name, scanClassVariableMap[sc.Name])...)
+	}
+	return plans
+}
+
+// planScanClass 按功能码分组并按地址排序, 在不超过单次读取上限、不超过最大间隔、
+// 且不跨越禁止地址段时将相邻变量合并为同一个读报文
+func planScanClass(device *runtime.ModBusDevice, scanClass string, scanVariables []*runtime.Variable) []*ReadFramePlan {
+	functionCodeVariableMap := make(map[uint8][]*runtime.Variable, 0)
+	for _, variable := range scanVariables {
+		functionCodeVariableMap[variable.FunctionCode] = append(functionCodeVariableMap[variable.FunctionCode], variable)
+	}
+	codes := make([]int, 0, len(functionCodeVariableMap))
+	for code := range functionCodeVariableMap {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	plans := make([]*ReadFramePlan, 0)
+	for _, code := range codes {
+		variables := functionCodeVariableMap[uint8(code)]
+		sort.Stable(runtime.VariableSlice(variables))
+
+		var limit, unit uint
+		switch runtime.FunctionCode(code) {
+		case runtime.ReadCoilStatus, runtime.ReadInputStatus:
+			limit, unit = device.GetMaxCoils(), 1
+		case runtime.ReadHoldRegister, runtime.ReadInputRegister:
+			limit, unit = device.GetMaxRegisters(), 2
+		default:
+			continue
+		}
+
+		var start, end uint // 当前报文覆盖的变量地址[start, end)
+		group := make([]*runtime.Variable, 0)
+		flush := func() {
+			if len(group) == 0 {
+				return
+			}
+			plan := &ReadFramePlan{
+				ScanClass:     scanClass,
+				FunctionCode:  uint8(code),
+				StartAddress:  start - device.PositionAddress,
+				Quantity:      end - start,
+				VariableNames: make([]string, 0, len(group)),
+				Variables:     make([]*runtime.VariableParse, 0, len(group)),
+			}
+			for _, variable := range group {
+				plan.VariableNames = append(plan.VariableNames, variable.Name)
+				plan.Variables = append(plan.Variables, &runtime.VariableParse{
+					Variable: variable,
+					Start:    (variable.Address - start) * unit,
+				})
+			}
+			plans = append(plans, plan)
+			group = make([]*runtime.Variable, 0)
+		}
+
+		for _, variable := range variables {
+			variableEnd := variable.Address + 1
+			if unit == 2 {
+				variableEnd = variable.Address + variable.Words()
+			}
+			if len(group) > 0 && !canMerge(device, uint8(code), limit, start, end, variable.Address, variableEnd) {
+				flush()
+			}
+			if len(group) == 0 {
+				start, end = variable.Address, variableEnd
+			} else if variableEnd > end {
+				end = variableEnd
+			}
+			group = append(group, variable)
+		}
+		flush()
+	}
+	return plans
+}
+
+// canMerge 判断地址段[variableStart, variableEnd)能否并入当前报文[start, end)
+func canMerge(device *runtime.ModBusDevice, functionCode uint8, limit, start, end, variableStart, variableEnd uint) bool {
+	if variableEnd > end && variableEnd-start > limit {
+		return false
+	}
+	if variableStart <= end {
+		return true
+	}
+	if device.MaxGap != nil && variableStart-end > *device.MaxGap {
+		return false
+	}
+	for _, forbidden := range device.ForbiddenRanges {
+		if forbidden.Overlaps(functionCode, end, variableStart) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planString 功能码[起始地址+数量]变量@数据偏移
+func planString(plan *ReadFramePlan) string {
+	variables := make([]string, 0, len(plan.Variables))
+	for _, vp := range plan.Variables {
+		variables = append(variables, fmt.Sprintf("%s@%d", vp.Variable.Name, vp.Start))
+	}
+	s := fmt.Sprintf("fc%d[%d+%d]%s", plan.FunctionCode, plan.StartAddress, plan.Quantity, strings.Join(variables, ","))
+	if len(plan.ScanClass) > 0 {
+		s = plan.ScanClass + ":" + s
+	}
+	return s
+}
+
+func TestPlanReadFrames(t *testing.T) {
+	tests := []struct {
+		name     string
+		details  biz.JSONMap
+		mappings []*biz.Mapping
+		plans    []string
+	}{
+		{
+			name: "contiguous",
+			mappings: []*biz.Mapping{
+				{Name: "b", Variable: "40002", DataType: "float32"},
+				{Name: "a", Variable: "40001", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+3]a@0,b@2"},
+		},
+		{
+			name: "unlimited gap",
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "int16"},
+				{Name: "b", Variable: "40100", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+100]a@0,b@198"},
+		},
+		{
+			name:    "max gap",
+			details: biz.JSONMap{"maxGap": 2},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "int16"},
+				{Name: "b", Variable: "40004", DataType: "int16"},
+				{Name: "c", Variable: "40008", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+4]a@0,b@6", "fc3[8+1]c@0"},
+		},
+		{
+			name:    "zero max gap",
+			details: biz.JSONMap{"maxGap": 0},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "int16"},
+				{Name: "b", Variable: "40002", DataType: "int16"},
+				{Name: "c", Variable: "40004", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+2]a@0,b@2", "fc3[4+1]c@0"},
+		},
+		{
+			name:    "max registers",
+			details: biz.JSONMap{"maxRegisters": 10},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "float64"},
+				{Name: "b", Variable: "40008", DataType: "int32"},
+				{Name: "c", Variable: "40010", DataType: "int16"},
+				{Name: "d", Variable: "40011", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+10]a@0,b@14,c@18", "fc3[11+1]d@0"},
+		},
+		{
+			name:    "max coils",
+			details: biz.JSONMap{"maxCoils": 8},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "00001", DataType: "bool"},
+				{Name: "b", Variable: "00008", DataType: "bool"},
+				{Name: "c", Variable: "00009", DataType: "bool"},
+			},
+			plans: []string{"fc1[1+8]a@0,b@7", "fc1[9+1]c@0"},
+		},
+		{
+			// 禁止地址段只影响同一功能码
+			name:    "forbidden range",
+			details: biz.JSONMap{"forbiddenRanges": []string{"40005-40006", "40012"}},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "int16"},
+				{Name: "b", Variable: "40010", DataType: "int16"},
+				{Name: "c", Variable: "40013", DataType: "int16"},
+				{Name: "d", Variable: "30001", DataType: "int16"},
+				{Name: "e", Variable: "30010", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+1]a@0", "fc3[10+1]b@0", "fc3[13+1]c@0", "fc4[1+10]d@0,e@18"},
+		},
+		{
+			name: "overlapping variables",
+			mappings: []*biz.Mapping{
+				{Name: "whole", Variable: "40001", DataType: "float32"},
+				{Name: "low", Variable: "40002", DataType: "uint16"},
+			},
+			plans: []string{"fc3[1+2]whole@0,low@2"},
+		},
+		{
+			name: "bits in one register",
+			mappings: []*biz.Mapping{
+				{Name: "running", Variable: "40010.0", DataType: "bool"},
+				{Name: "mode", Variable: "40010.1-4", DataType: "uint16"},
+			},
+			plans: []string{"fc3[10+1]running@0,mode@0"},
+		},
+		{
+			name: "string words",
+			mappings: []*biz.Mapping{
+				{Name: "name", Variable: "40001", DataType: "string", Amount: 4},
+				{Name: "count", Variable: "40005", DataType: "int16"},
+			},
+			plans: []string{"fc3[1+5]name@0,count@8"},
+		},
+		{
+			name: "function codes",
+			mappings: []*biz.Mapping{
+				{Name: "hr", Variable: "40001", DataType: "int16"},
+				{Name: "ir", Variable: "30001", DataType: "int16"},
+				{Name: "di", Variable: "10002", DataType: "bool"},
+				{Name: "coil", Variable: "00003", DataType: "bool"},
+			},
+			plans: []string{"fc1[3+1]coil@0", "fc2[2+1]di@0", "fc3[1+1]hr@0", "fc4[1+1]ir@0"},
+		},
+		{
+			name:    "position address",
+			details: biz.JSONMap{"positionAddress": 1},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "int16"},
+				{Name: "b", Variable: "40002", DataType: "int16"},
+			},
+			plans: []string{"fc3[0+2]a@0,b@2"},
+		},
+		{
+			// 默认类别在前, 其余按配置顺序
+			name: "scan classes",
+			details: biz.JSONMap{"scanClasses": []map[string]interface{}{
+				{"name": "slow", "collectorCycle": 5000},
+				{"name": "fast", "collectorCycle": 100},
+			}},
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "40001", DataType: "int16", ScanClass: "fast"},
+				{Name: "b", Variable: "40002", DataType: "int16"},
+				{Name: "c", Variable: "40003", DataType: "int16", ScanClass: "slow"},
+			},
+			plans: []string{"fc3[2+1]b@0", "slow:fc3[3+1]c@0", "fast:fc3[1+1]a@0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device, err := ConvertDevice(newTestAgents(tt.details), tt.mappings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plans := make([]string, 0)
+			for _, plan := range PlanReadFrames(device.(*runtime.ModBusDevice)) {
+				plans = append(plans, planString(plan))
+			}
+			if !reflect.DeepEqual(plans, tt.plans) {
+				t.Fatalf("got %v, want %v", plans, tt.plans)
+			}
+		})
+	}
+}
+
+// 单个变量超过设备单次读取的最大寄存器数量时无法规划读报文
+func TestPlanReadFramesVariableExceedsFrameLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		details biz.JSONMap
+		amount  uint
+		wantErr bool
+	}{
+		{"device limit", biz.JSONMap{"maxRegisters": 32}, 32, false},
+		{"exceeds device limit", biz.JSONMap{"maxRegisters": 32}, 100, true},
+		{"default limit", nil, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings := []*biz.Mapping{{Name: "name", Variable: "40001", DataType: "string", Amount: tt.amount}}
+			_, err := ConvertDevice(newTestAgents(tt.details), mappings)
+			if got := errors.Is(err, runtime.ErrVariableExceedsFrameLimit); got != tt.wantErr {
+				t.Fatalf("got %v", err)
+			}
+		})
+	}
+}
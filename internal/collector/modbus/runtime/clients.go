@@ -1,10 +1,13 @@
 package runtime
 
 import (
+	"bytes"
 	"container/list"
 	"context"
+	"errors"
 	"go.bug.st/serial"
 	"harnsplatform/internal/collector"
+	"harnsplatform/internal/utils/binutils"
 	"io"
 	"k8s.io/klog/v2"
 	"net"
@@ -105,6 +108,7 @@ func (t *Clients) nextRequestKey() uint64 {
 
 var _ Messenger = (*TcpClient)(nil)
 var _ Messenger = (*SerialClient)(nil)
+var _ Messenger = (*UdpClient)(nil)
 
 type Messenger interface {
 	AskAtLeast(request []byte, response []byte, min int) (int, error)
@@ -116,6 +120,7 @@ type Messenger interface {
 type TcpClient struct {
 	Timeout int
 	Tunnel  net.Conn
+	Ascii   bool // ascii帧以CRLF结束
 }
 
 func (tc *TcpClient) Reset(messenger Messenger) {
@@ -145,12 +150,74 @@ func (tc *TcpClient) AskAtLeast(request []byte, response []byte, min int) (int,
 		klog.V(2).InfoS("Tcp connect timeout", "error", err)
 		return 0, err
 	}
+	if tc.Ascii {
+		return readAsciiFrame(tc.Tunnel, response)
+	}
 	return io.ReadAtLeast(tc.Tunnel, response, min)
 }
 
+type UdpClient struct {
+	Timeout    int
+	Retransmit int // 超时重发次数
+	Conn       *net.UDPConn
+}
+
+func (uc *UdpClient) Reset(messenger Messenger) {
+	nuc := (messenger).(*UdpClient)
+	uc.Conn = nuc.Conn
+}
+
+func (uc *UdpClient) Available() bool {
+	return uc.Conn != nil
+}
+
+func (uc *UdpClient) Close() {
+	_ = uc.Conn.Close()
+}
+
+// AskAtLeast 每个数据报独立成帧,按事务标识符匹配响应,丢弃迟到的旧响应,超时后重发
+func (uc *UdpClient) AskAtLeast(request []byte, response []byte, min int) (int, error) {
+	transactionId := binutils.ParseUint16(request)
+	buf := make([]byte, 512)
+	for i := 0; i <= uc.Retransmit; i++ {
+		if _, err := uc.Conn.Write(request); err != nil {
+			klog.V(2).InfoS("Failed to ask message", "error", err)
+			return 0, ErrModbusBadConn
+		}
+		deadLineTime := time.Now().Add(time.Duration(uc.Timeout) * time.Second)
+		if err := uc.Conn.SetReadDeadline(deadLineTime); err != nil {
+			klog.V(2).InfoS("Udp connect timeout", "error", err)
+			return 0, err
+		}
+		for {
+			n, err := uc.Conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					klog.V(5).InfoS("Udp datagram timeout, retransmit", "transactionId", transactionId, "times", i+1)
+					break
+				}
+				return 0, err
+			}
+			if n < min {
+				continue
+			}
+			if responseTransactionId := binutils.ParseUint16(buf); responseTransactionId != transactionId {
+				klog.V(5).InfoS("Discard unmatched udp datagram", "request transactionId", transactionId, "response transactionId", responseTransactionId)
+				continue
+			}
+			return copy(response, buf[:n]), nil
+		}
+	}
+	return 0, ErrModbusTimeout
+}
+
 type SerialClient struct {
-	Timeout int
-	Port    serial.Port
+	Timeout    int
+	Port       serial.Port
+	Ascii      bool          // ascii帧以CRLF结束
+	CharTime   time.Duration // 单个字符的传输时间, 用于rtu帧间静默判定
+	EchoCancel bool          // 半双工适配器会回显发送的字节, 需先读出丢弃
 }
 
 func (sc *SerialClient) Reset(messenger Messenger) {
@@ -167,6 +234,11 @@ func (sc *SerialClient) Close() {
 }
 
 func (sc *SerialClient) AskAtLeast(request []byte, response []byte, min int) (int, error) {
+	// 丢弃上一次事务残留或线路干扰产生的字节
+	if err := sc.Port.ResetInputBuffer(); err != nil {
+		klog.V(2).InfoS("Failed to flush series port", "error", err)
+		return 0, ErrModbusBadConn
+	}
 	rql, err := sc.Port.Write(request)
 	if err != nil {
 		klog.V(2).InfoS("Failed to write byte to series port", "error", err)
@@ -181,35 +253,186 @@ func (sc *SerialClient) AskAtLeast(request []byte, response []byte, min int) (in
 		return 0, err
 	}
 
-	buf := make([]byte, 256)
-	responseBytesLength := len(response)
-	bytesLength := 0
-	currentIndex := 0
+	if sc.EchoCancel {
+		if err := sc.cancelEcho(request); err != nil {
+			return 0, err
+		}
+	}
 
+	if sc.Ascii {
+		return readAsciiFrame(sc.Port, response)
+	}
+	return sc.readRtuFrame(response)
+}
+
+// cancelEcho 读出并校验适配器回显的请求
+func (sc *SerialClient) cancelEcho(request []byte) error {
+	echo := make([]byte, len(request))
+	if _, err := io.ReadFull(sc.Port, echo); err != nil {
+		klog.V(2).InfoS("Failed to read echo from series port", "error", err)
+		return ErrMessageDataLengthNotEnough
+	}
+	if !bytes.Equal(echo, request) {
+		klog.V(2).InfoS("Failed to match echo from series port", "request", request, "echo", echo)
+		_ = sc.Port.ResetInputBuffer()
+		return ErrRtuFrameInvalid
+	}
+	return nil
+}
+
+// readRtuFrame 首字节等待响应超时, 之后以t1.5为间隔轮询, 静默达到t3.5视为帧结束
+// 异常响应(功能码最高位为1)固定5个字节, 正常响应达到response长度时提前结束
+func (sc *SerialClient) readRtuFrame(response []byte) (int, error) {
+	t15, t35 := RtuSilence(sc.CharTime)
+	poll := t15
+	if poll < time.Millisecond {
+		// 串口读超时的精度为毫秒
+		poll = time.Millisecond
+	}
+
+	buf := make([]byte, 256)
+	length := 0
+	var silence time.Duration
 	for {
 		n, err := sc.Port.Read(buf)
 		if err != nil {
-			// klog.V(2).InfoS("Failed to read byte from series port", "error", err)
-			return 0, err
+			klog.V(2).InfoS("Failed to read byte from series port", "error", err)
+			return 0, ErrModbusBadConn
 		}
 		if n == 0 {
+			if length == 0 {
+				// 超时无响应
+				return 0, ErrMessageDataLengthNotEnough
+			}
+			silence += poll
+			if silence >= t35 {
+				break
+			}
+			continue
+		}
+		if silence >= t15 {
+			klog.V(5).InfoS("Rtu inter-character silence exceeds t1.5", "silence", silence)
+		}
+		silence = 0
+
+		if length+n > len(response) {
+			klog.V(2).InfoS("Failed to delimit rtu frame, too many bytes", "expected", len(response), "received", length+n)
+			_ = sc.Port.ResetInputBuffer()
+			return 0, ErrRtuFrameInvalid
+		}
+		if length == 0 {
+			if err := sc.Port.SetReadTimeout(poll); err != nil {
+				return 0, err
+			}
+		}
+		copy(response[length:], buf[:n])
+		length += n
+
+		if length >= 2 && response[1]&0x80 > 0 && length >= RtuExceptionLength {
+			break
+		}
+		if length == len(response) {
 			break
 		}
-		bytesLength += n
+	}
+	return length, nil
+}
 
+// RtuCharTime 按串口参数计算单个字符的传输时间 起始位 + 数据位 + 校验位 + 停止位
+func RtuCharTime(mode *serial.Mode) time.Duration {
+	if mode.BaudRate <= 0 {
+		return 0
+	}
+	bits := 1.0 + float64(mode.DataBits)
+	if mode.DataBits == 0 {
+		bits += 8
+	}
+	if mode.Parity != serial.NoParity {
+		bits++
+	}
+	switch mode.StopBits {
+	case serial.OnePointFiveStopBits:
+		bits += 1.5
+	case serial.TwoStopBits:
+		bits += 2
+	default:
+		bits++
+	}
+	return time.Duration(bits * float64(time.Second) / float64(mode.BaudRate))
+}
+
+// RtuSilence t1.5 t3.5, 波特率大于19200时固定为750us与1750us
+func RtuSilence(charTime time.Duration) (time.Duration, time.Duration) {
+	if charTime <= 0 || charTime < RtuCharTime(&serial.Mode{BaudRate: 19200, DataBits: 8}) {
+		return 750 * time.Microsecond, 1750 * time.Microsecond
+	}
+	return charTime * 3 / 2, charTime * 7 / 2
+}
+
+// readAsciiFrame 丢弃':'之前的字节,读取到LF为止,异常响应短于response时可提前结束
+func readAsciiFrame(reader io.Reader, response []byte) (int, error) {
+	buf := make([]byte, 256)
+	currentIndex := 0
+	for {
+		n, err := reader.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
 		for i := 0; i < n; i++ {
+			if currentIndex == 0 && buf[i] != ':' {
+				continue
+			}
+			if currentIndex == len(response) {
+				return 0, ErrAsciiFrameInvalid
+			}
 			response[currentIndex] = buf[i]
 			currentIndex++
-		}
-
-		if bytesLength == responseBytesLength {
-			break
+			if buf[i] == '\n' {
+				return currentIndex, nil
+			}
 		}
 	}
-	if responseBytesLength != bytesLength {
-		// klog.V(2).InfoS("Modbus rtu data length no enough", "bytesLength", bytesLength)
-		return 0, ErrMessageDataLengthNotEnough
+	return 0, ErrMessageDataLengthNotEnough
+}
+
+// AskError 区分等待响应超时与连接异常, 串口超时无响应时返回ErrMessageDataLengthNotEnough
+func AskError(err error) error {
+	var ne net.Error
+	if errors.Is(err, ErrModbusTimeout) || errors.Is(err, ErrMessageDataLengthNotEnough) || (errors.As(err, &ne) && ne.Timeout()) {
+		return ErrModbusTimeout
 	}
+	return ErrModbusBadConn
+}
+
+// FrameGap 同一设备相邻两帧之间的最小间隔, 上一帧收到响应后至少间隔Interval才发送下一帧
+type FrameGap struct {
+	Interval time.Duration
+	mux      sync.Mutex
+	last     time.Time
+}
+
+func (g *FrameGap) AskAtLeast(messenger Messenger, request []byte, response []byte, min int) (int, error) {
+	n, _, err := g.Ask(messenger, request, response, min)
+	return n, err
+}
 
-	return bytesLength, nil
+// Ask 与AskAtLeast相同, 另返回不含帧间隔等待的往返时延
+func (g *FrameGap) Ask(messenger Messenger, request []byte, response []byte, min int) (int, time.Duration, error) {
+	if g == nil || g.Interval <= 0 {
+		start := time.Now()
+		n, err := messenger.AskAtLeast(request, response, min)
+		return n, time.Since(start), err
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if wait := g.Interval - time.Since(g.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	start := time.Now()
+	n, err := messenger.AskAtLeast(request, response, min)
+	g.last = time.Now()
+	return n, g.last.Sub(start), err
 }
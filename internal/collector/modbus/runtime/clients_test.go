@@ -0,0 +1,112 @@
+package runtime
+
+import (
+	"bytes"
+	"harnsplatform/internal/utils/binutils"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestUdpClient serve按收到的第几个请求返回要发送的数据报
+func newTestUdpClient(t *testing.T, retransmit int, serve func(i int, request []byte) [][]byte) (*UdpClient, *int32) {
+	t.Helper()
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := server.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			// 先计数再响应, 客户端收到响应时计数已更新
+			i := atomic.AddInt32(&requests, 1) - 1
+			for _, datagram := range serve(int(i), binutils.Dup(buf[:n])) {
+				_, _ = server.WriteToUDP(datagram, addr)
+			}
+		}
+	}()
+	conn, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Close()
+		<-done
+	})
+	return &UdpClient{Conn: conn, Timeout: 1, Retransmit: retransmit}, &requests
+}
+
+func TestUdpClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		serve    func(i int, request []byte) [][]byte
+		requests int32
+	}{
+		{
+			name: "echo",
+			serve: func(i int, request []byte) [][]byte {
+				return [][]byte{request}
+			},
+			requests: 1,
+		},
+		{
+			// 事务标识符不一致或长度不足的数据报被丢弃
+			name: "discard unmatched",
+			serve: func(i int, request []byte) [][]byte {
+				stale := binutils.Dup(request)
+				binutils.WriteUint16BigEndian(stale, binutils.ParseUint16BigEndian(request)-1)
+				return [][]byte{stale, request[:4], request}
+			},
+			requests: 1,
+		},
+		{
+			// 首个请求丢失, 超时后重发
+			name: "retransmit",
+			serve: func(i int, request []byte) [][]byte {
+				if i == 0 {
+					return nil
+				}
+				return [][]byte{request}
+			},
+			requests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, requests := newTestUdpClient(t, 2, tt.serve)
+			request := []byte{0x12, 0x34, 0, 0, 0, 6, 1, 3, 0, 0, 0, 1}
+			response := make([]byte, 32)
+			n, err := uc.AskAtLeast(request, response, len(request))
+			if err != nil || !bytes.Equal(response[:n], request) {
+				t.Fatalf("got %x, %v", response[:n], err)
+			}
+			if n := atomic.LoadInt32(requests); n != tt.requests {
+				t.Fatalf("sent %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestUdpClientTimeout(t *testing.T) {
+	uc, requests := newTestUdpClient(t, 1, func(i int, request []byte) [][]byte {
+		// 只返回旧事务的响应
+		stale := binutils.Dup(request)
+		binutils.WriteUint16BigEndian(stale, 1)
+		return [][]byte{stale}
+	})
+	request := []byte{0, 2, 0, 0, 0, 6, 1, 3, 0, 0, 0, 1}
+	if _, err := uc.AskAtLeast(request, make([]byte, 32), len(request)); err != ErrModbusTimeout {
+		t.Fatalf("got %v, want %v", err, ErrModbusTimeout)
+	}
+	if n := atomic.LoadInt32(requests); n != 2 {
+		t.Fatalf("sent %d requests, want 2", n)
+	}
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"bytes"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/binutils"
+)
+
+// ParseRegisterUint16 按内存布局解析单个寄存器
+func ParseRegisterUint16(memoryLayout common.MemoryLayout, data []byte) uint16 {
+	switch memoryLayout {
+	case common.BADC, common.DCBA:
+		return binutils.ParseUint16LittleEndian(data)
+	default:
+		return binutils.ParseUint16BigEndian(data)
+	}
+}
+
+// RegisterUint16ToBytes 按内存布局编码单个寄存器
+func RegisterUint16ToBytes(memoryLayout common.MemoryLayout, value uint16) []byte {
+	switch memoryLayout {
+	case common.BADC, common.DCBA:
+		return binutils.Uint16ToBytesLittleEndian(value)
+	default:
+		return binutils.Uint16ToBytesBigEndian(value)
+	}
+}
+
+// ParseRegisterUint32 按内存布局解析两个寄存器
+func ParseRegisterUint32(memoryLayout common.MemoryLayout, data []byte) uint32 {
+	switch memoryLayout {
+	case common.BADC:
+		// 大端交换
+		return binutils.ParseUint32BigEndianByteSwap(data)
+	case common.CDAB:
+		return binutils.ParseUint32LittleEndianByteSwap(data)
+	case common.DCBA:
+		return binutils.ParseUint32LittleEndian(data)
+	default:
+		return binutils.ParseUint32BigEndian(data)
+	}
+}
+
+// RegisterUint32ToBytes 按内存布局编码两个寄存器
+func RegisterUint32ToBytes(memoryLayout common.MemoryLayout, value uint32) []byte {
+	switch memoryLayout {
+	case common.BADC:
+		// 大端交换
+		return binutils.Uint32ToBytesBigEndianByteSwap(value)
+	case common.CDAB:
+		return binutils.Uint32ToBytesLittleEndianByteSwap(value)
+	case common.DCBA:
+		return binutils.Uint32ToBytesLittleEndian(value)
+	default:
+		return binutils.Uint32ToBytesBigEndian(value)
+	}
+}
+
+// ParseRegisterUint64 按内存布局解析四个寄存器
+func ParseRegisterUint64(memoryLayout common.MemoryLayout, data []byte) uint64 {
+	switch memoryLayout {
+	case common.BADC:
+		return binutils.ParseUint64BigEndianByteSwap(data)
+	case common.CDAB:
+		return binutils.ParseUint64LittleEndianByteSwap(data)
+	case common.DCBA:
+		return binutils.ParseUint64LittleEndian(data)
+	default:
+		return binutils.ParseUint64BigEndian(data)
+	}
+}
+
+// RegisterUint64ToBytes 按内存布局编码四个寄存器
+func RegisterUint64ToBytes(memoryLayout common.MemoryLayout, value uint64) []byte {
+	switch memoryLayout {
+	case common.BADC:
+		return binutils.Uint64ToBytesBigEndianByteSwap(value)
+	case common.CDAB:
+		return binutils.Uint64ToBytesLittleEndianByteSwap(value)
+	case common.DCBA:
+		return binutils.Uint64ToBytesLittleEndian(value)
+	default:
+		return binutils.Uint64ToBytesBigEndian(value)
+	}
+}
+
+// ParseRegisterString 解析ASCII字符串寄存器块,每个寄存器两个字符
+// ABCD CDAB 高字节在前, BADC DCBA 低字节在前, 去除末尾的空字符与空格
+func ParseRegisterString(memoryLayout common.MemoryLayout, data []byte) string {
+	buf := binutils.Dup(data[:len(data)/2*2])
+	if memoryLayout == common.BADC || memoryLayout == common.DCBA {
+		swapRegisterBytes(buf)
+	}
+	return string(bytes.TrimRight(buf, "\x00 "))
+}
+
+// RegisterStringToBytes 将字符串编码为words个寄存器,不足补0
+func RegisterStringToBytes(memoryLayout common.MemoryLayout, value string, words uint) ([]byte, error) {
+	if uint(len(value)) > words*2 {
+		return nil, ErrActionValueInvalid
+	}
+	buf := make([]byte, words*2)
+	copy(buf, value)
+	if memoryLayout == common.BADC || memoryLayout == common.DCBA {
+		swapRegisterBytes(buf)
+	}
+	return buf, nil
+}
+
+func swapRegisterBytes(buf []byte) {
+	for i := 0; i+1 < len(buf); i += 2 {
+		buf[i], buf[i+1] = buf[i+1], buf[i]
+	}
+}
+
+// DecodeBCD BCD码 => 十进制, 存在大于9的半字节时返回false
+func DecodeBCD(bcd uint64) (uint64, bool) {
+	var value, weight uint64 = 0, 1
+	for ; bcd > 0; bcd >>= 4 {
+		digit := bcd & 0x0F
+		if digit > 9 {
+			return 0, false
+		}
+		value += digit * weight
+		weight *= 10
+	}
+	return value, true
+}
+
+// EncodeBCD 十进制 => BCD码, 超出digits位时返回false
+func EncodeBCD(value uint64, digits int) (uint64, bool) {
+	var bcd uint64
+	for i := 0; i < digits; i++ {
+		bcd |= (value % 10) << (4 * i)
+		value /= 10
+	}
+	return bcd, value == 0
+}
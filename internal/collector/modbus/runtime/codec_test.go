@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"bytes"
+	"harnsplatform/internal/common"
+	"testing"
+)
+
+func TestRegisterCodec(t *testing.T) {
+	// 0x1122 0x11223344 0x1122334455667788 在各内存布局下的寄存器字节
+	tests := []struct {
+		memoryLayout common.MemoryLayout
+		word         []byte
+		dword        []byte
+		qword        []byte
+	}{
+		{common.ABCD, []byte{0x11, 0x22}, []byte{0x11, 0x22, 0x33, 0x44}, []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}},
+		{common.BADC, []byte{0x22, 0x11}, []byte{0x22, 0x11, 0x44, 0x33}, []byte{0x22, 0x11, 0x44, 0x33, 0x66, 0x55, 0x88, 0x77}},
+		{common.CDAB, []byte{0x11, 0x22}, []byte{0x33, 0x44, 0x11, 0x22}, []byte{0x77, 0x88, 0x55, 0x66, 0x33, 0x44, 0x11, 0x22}},
+		{common.DCBA, []byte{0x22, 0x11}, []byte{0x44, 0x33, 0x22, 0x11}, []byte{0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11}},
+	}
+	for _, tt := range tests {
+		t.Run(common.MemoryLayoutToString[tt.memoryLayout], func(t *testing.T) {
+			if v := ParseRegisterUint16(tt.memoryLayout, tt.word); v != 0x1122 {
+				t.Errorf("ParseRegisterUint16 = %04X", v)
+			}
+			if b := RegisterUint16ToBytes(tt.memoryLayout, 0x1122); !bytes.Equal(b, tt.word) {
+				t.Errorf("RegisterUint16ToBytes = %X", b)
+			}
+			if v := ParseRegisterUint32(tt.memoryLayout, tt.dword); v != 0x11223344 {
+				t.Errorf("ParseRegisterUint32 = %08X", v)
+			}
+			if b := RegisterUint32ToBytes(tt.memoryLayout, 0x11223344); !bytes.Equal(b, tt.dword) {
+				t.Errorf("RegisterUint32ToBytes = %X", b)
+			}
+			if v := ParseRegisterUint64(tt.memoryLayout, tt.qword); v != 0x1122334455667788 {
+				t.Errorf("ParseRegisterUint64 = %016X", v)
+			}
+			if b := RegisterUint64ToBytes(tt.memoryLayout, 0x1122334455667788); !bytes.Equal(b, tt.qword) {
+				t.Errorf("RegisterUint64ToBytes = %X", b)
+			}
+		})
+	}
+}
+
+func TestRegisterString(t *testing.T) {
+	tests := []struct {
+		memoryLayout common.MemoryLayout
+		value        string
+		words        uint
+		data         []byte
+	}{
+		{common.ABCD, "ABC", 2, []byte{'A', 'B', 'C', 0}},
+		{common.CDAB, "ABCD", 2, []byte{'A', 'B', 'C', 'D'}},
+		{common.BADC, "ABC", 2, []byte{'B', 'A', 0, 'C'}},
+		{common.DCBA, "ABCD", 3, []byte{'B', 'A', 'D', 'C', 0, 0}},
+	}
+	for _, tt := range tests {
+		data, err := RegisterStringToBytes(tt.memoryLayout, tt.value, tt.words)
+		if err != nil || !bytes.Equal(data, tt.data) {
+			t.Errorf("%s %q: got %q, %v, want %q", common.MemoryLayoutToString[tt.memoryLayout], tt.value, data, err, tt.data)
+		}
+		if value := ParseRegisterString(tt.memoryLayout, tt.data); value != tt.value {
+			t.Errorf("%s %q: parsed %q", common.MemoryLayoutToString[tt.memoryLayout], tt.value, value)
+		}
+	}
+
+	// 去除末尾的空字符与空格, 奇数长度忽略最后一个字节
+	if value := ParseRegisterString(common.ABCD, []byte{'o', 'k', ' ', 0, 'x'}); value != "ok" {
+		t.Errorf("got %q", value)
+	}
+	if _, err := RegisterStringToBytes(common.ABCD, "ABCDE", 2); err != ErrActionValueInvalid {
+		t.Errorf("got %v, want %v", err, ErrActionValueInvalid)
+	}
+}
+
+func TestBCD(t *testing.T) {
+	tests := []struct {
+		bcd    uint64
+		value  uint64
+		digits int
+	}{
+		{0x0000, 0, 4},
+		{0x0009, 9, 4},
+		{0x1234, 1234, 4},
+		{0x9999, 9999, 4},
+		{0x12345678, 12345678, 8},
+		{0x9999999999999999, 9999999999999999, 16},
+	}
+	for _, tt := range tests {
+		if value, ok := DecodeBCD(tt.bcd); !ok || value != tt.value {
+			t.Errorf("DecodeBCD(%X) = %d, %v", tt.bcd, value, ok)
+		}
+		if bcd, ok := EncodeBCD(tt.value, tt.digits); !ok || bcd != tt.bcd {
+			t.Errorf("EncodeBCD(%d) = %X, %v", tt.value, bcd, ok)
+		}
+	}
+
+	// 半字节大于9不是合法的BCD码
+	for _, bcd := range []uint64{0x000A, 0x12F4, 0xA000000000000000} {
+		if _, ok := DecodeBCD(bcd); ok {
+			t.Errorf("DecodeBCD(%X) succeeded", bcd)
+		}
+	}
+	// 超出位数
+	if _, ok := EncodeBCD(10000, 4); ok {
+		t.Error("EncodeBCD(10000, 4) succeeded")
+	}
+}
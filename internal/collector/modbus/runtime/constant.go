@@ -12,9 +12,47 @@ var ErrMessageTransaction = errors.New("modbus message transaction not match")
 var ErrMessageSlave = errors.New("modbus message slave not match")
 var ErrMessageDataLengthNotEnough = errors.New("modbus message data length not enough")
 var ErrMessageFunctionCodeError = errors.New("modbus message function code error")
+var ErrModbusTimeout = errors.New("modbus request timeout")
 var ErrManyRetry = errors.New("connect Modbus server retry more than three times")
 var ErrCRC16Error = errors.New("validate crc16 error")
-
+var ErrLRCError = errors.New("validate lrc error")
+var ErrAsciiFrameInvalid = errors.New("modbus ascii frame invalid")
+var ErrVariableNameEmpty = errors.New("modbus variable name empty")
+var ErrVariableNameDuplicate = errors.New("modbus variable name duplicate")
+var ErrVariableAddressDuplicate = errors.New("modbus variable address duplicate")
+var ErrVariableAddressInvalid = errors.New("modbus variable address invalid")
+var ErrDataTypeUnsupported = errors.New("modbus variable data type unsupported")
+var ErrAccessModeInvalid = errors.New("modbus variable access mode invalid")
+var ErrRateInvalid = errors.New("modbus variable rate invalid")
+var ErrOffsetInvalid = errors.New("modbus variable offset invalid")
+var ErrAmountInvalid = errors.New("modbus variable amount invalid")
+var ErrClampInvalid = errors.New("modbus variable min max invalid")
+var ErrPrecisionInvalid = errors.New("modbus variable precision invalid")
+var ErrBcdInvalid = errors.New("modbus variable bcd value invalid")
+var ErrRtuFrameInvalid = errors.New("modbus rtu frame invalid")
+var ErrSerialBusModeConflict = errors.New("serial bus already opened with different baud rate, data bits, parity or stop bits")
+var ErrPipelineUnsupported = errors.New("modbus pipeline only supported by modbusTcp")
+var ErrFrameLimitInvalid = errors.New("modbus max registers or coils per request invalid")
+var ErrForbiddenRangeInvalid = errors.New("modbus forbidden range invalid")
+var ErrVariableInForbiddenRange = errors.New("modbus variable in forbidden range")
+var ErrVariableExceedsFrameLimit = errors.New("modbus variable registers exceed max registers per request")
+var ErrVariableBelowPositionAddress = errors.New("modbus variable address below position address")
+var ErrAgentDetailsInvalid = errors.New("modbus agent details invalid")
+var ErrAgentAddressInvalid = errors.New("modbus agent address invalid")
+var ErrProtocolUnsupported = errors.New("modbus protocol unsupported")
+var ErrMemoryLayoutInvalid = errors.New("modbus memory layout invalid")
+var ErrWriteModeInvalid = errors.New("modbus write mode invalid")
+var ErrOverrunPolicyInvalid = errors.New("modbus overrun policy invalid")
+var ErrScanClassInvalid = errors.New("modbus scan class invalid")
+var ErrScanClassNotFound = errors.New("modbus scan class not found")
+var ErrVariableNotFound = errors.New("modbus variable not found")
+var ErrVariableReadOnly = errors.New("modbus variable read only")
+var ErrActionValueInvalid = errors.New("modbus action value invalid")
+var ErrWriteEchoMismatch = errors.New("modbus write response echo mismatch")
+var ErrWriteReadBackMismatch = errors.New("modbus write read back value mismatch")
+var ErrDiscoverySlaveInvalid = errors.New("modbus discovery slave must be between 1 and 247")
+var ErrDiscoveryRangeInvalid = errors.New("modbus discovery range invalid")
+var ErrDiagnosticEchoMismatch = errors.New("modbus diagnostic echo mismatch")
 
 type ModbusModel byte
 
@@ -22,17 +60,26 @@ const (
 	Tcp ModbusModel = iota
 	Rtu
 	RtuOverTcp
+	Ascii
+	AsciiOverTcp
+	Udp
 )
 
 var ModbusModelToString = map[ModbusModel]string{
-	Tcp:        "modbusTcp",
-	Rtu:        "modbusRtu",
-	RtuOverTcp: "modbusRtuOverTcp",
+	Tcp:          "modbusTcp",
+	Rtu:          "modbusRtu",
+	RtuOverTcp:   "modbusRtuOverTcp",
+	Ascii:        "modbusAscii",
+	AsciiOverTcp: "modbusAsciiOverTcp",
+	Udp:          "modbusUdp",
 }
 var StringToModbusModel = map[string]ModbusModel{
-	"modbusTcp":        Tcp,
-	"modbusRtu":        Rtu,
-	"modbusRtuOverTcp": RtuOverTcp,
+	"modbusTcp":          Tcp,
+	"modbusRtu":          Rtu,
+	"modbusRtuOverTcp":   RtuOverTcp,
+	"modbusAscii":        Ascii,
+	"modbusAsciiOverTcp": AsciiOverTcp,
+	"modbusUdp":          Udp,
 }
 
 type FunctionCode uint8
@@ -45,15 +92,28 @@ const (
 	WriteSingleCoil
 	WriteSingleRegister
 	NOON7
-	NOON8
+	Diagnostics
 	NOON9
 	NOON10
-	NOON11
+	GetCommEventCounter
 	NOON12
 	NOON13
 	NOON14
 	WriteMultipleCoil
 	WriteMultipleRegister
+	NOON17
+	NOON18
+	NOON19
+	NOON20
+	NOON21
+	MaskWriteRegister
+	ReadWriteMultipleRegister
+)
+
+const (
+	// EncapsulatedInterface functionCode43 封装接口, MEI类型14为读设备标识
+	EncapsulatedInterface    FunctionCode = 0x2B
+	ReadDeviceIdentification uint8        = 0x0E
 )
 
 const (
@@ -61,8 +121,35 @@ const (
 	PerRequestMaxCoil = 1983
 	// PerRequestMaxRegister functionCode03 一次最多读取124个寄存器,248个字节
 	PerRequestMaxRegister = 123
+	// PerRequestMaxWriteCoil functionCode15 一次最多写入1968个线圈
+	PerRequestMaxWriteCoil = 1968
+	// PerRequestMaxWriteRegister functionCode16 一次最多写入123个寄存器
+	PerRequestMaxWriteRegister = 123
+	// PerRequestMaxReadWriteRegister functionCode23 一次最多写入121个寄存器
+	PerRequestMaxReadWriteRegister = 121
+)
+
+// RtuExceptionLength rtu异常响应 地址 + 功能码 + 异常码 + CRC
+const RtuExceptionLength = 5
+
+type WriteMode byte
+
+const (
+	// WriteModeBatch 连续地址合并为 05/06/0F/10
+	WriteModeBatch WriteMode = iota
+	// WriteModeReadWrite 寄存器使用 17 写入后读回
+	WriteModeReadWrite
 )
 
+var WriteModeToString = map[WriteMode]string{
+	WriteModeBatch:     "batch",
+	WriteModeReadWrite: "readWrite",
+}
+var StringToWriteMode = map[string]WriteMode{
+	"batch":     WriteModeBatch,
+	"readWrite": WriteModeReadWrite,
+}
+
 var StopBitsToStopBits = map[common.StopBits]serial.StopBits{
 	common.OneStopBit:           serial.OneStopBit,
 	common.OnePointFiveStopBits: serial.OnePointFiveStopBits,
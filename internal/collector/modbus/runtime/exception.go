@@ -0,0 +1,62 @@
+package runtime
+
+import "fmt"
+
+type ExceptionCode uint8
+
+const (
+	IllegalFunction ExceptionCode = iota + 1
+	IllegalDataAddress
+	IllegalDataValue
+	SlaveDeviceFailure
+	Acknowledge
+	SlaveDeviceBusy
+	NegativeAcknowledge
+	MemoryParityError
+	NOONException9
+	GatewayPathUnavailable
+	GatewayTargetFailed
+)
+
+var ExceptionCodeToString = map[ExceptionCode]string{
+	IllegalFunction:        "illegal function",
+	IllegalDataAddress:     "illegal data address",
+	IllegalDataValue:       "illegal data value",
+	SlaveDeviceFailure:     "slave device failure",
+	Acknowledge:            "acknowledge",
+	SlaveDeviceBusy:        "slave device busy",
+	NegativeAcknowledge:    "negative acknowledge",
+	MemoryParityError:      "memory parity error",
+	GatewayPathUnavailable: "gateway path unavailable",
+	GatewayTargetFailed:    "gateway target device failed to respond",
+}
+
+func (ec ExceptionCode) String() string {
+	if s, ok := ExceptionCodeToString[ec]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown exception %d", uint8(ec))
+}
+
+// ModbusException 从站异常响应 功能码|0x80 + 异常码
+type ModbusException struct {
+	FunctionCode  uint8
+	ExceptionCode ExceptionCode
+}
+
+func NewModbusException(pdu []byte) *ModbusException {
+	me := &ModbusException{FunctionCode: pdu[0] & 0x7F}
+	if len(pdu) > 1 {
+		me.ExceptionCode = ExceptionCode(pdu[1])
+	}
+	return me
+}
+
+func (me *ModbusException) Error() string {
+	return fmt.Sprintf("modbus exception %d (%s), function code %d", uint8(me.ExceptionCode), me.ExceptionCode, me.FunctionCode)
+}
+
+// Unwrap 兼容 errors.Is(err, ErrMessageFunctionCodeError)
+func (me *ModbusException) Unwrap() error {
+	return ErrMessageFunctionCodeError
+}
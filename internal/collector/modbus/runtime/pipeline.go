@@ -0,0 +1,197 @@
+package runtime
+
+import (
+	"harnsplatform/internal/utils/binutils"
+	"io"
+	"k8s.io/klog/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+var _ Messenger = (*PipelineClient)(nil)
+
+/**
+modbus tcp 流水线
+同一连接上同时保持多个未完成的请求, 由连接自行分配事务标识符, 读协程按事务标识符将响应分发给请求方
+Clients中每个连接放入MaxInFlight个PipelineClient, 借出的PipelineClient数量即为该连接的并发请求数
+*/
+
+// PipelineTransport 共享的tcp连接, 连接断开后在下一次请求时重连
+type PipelineTransport struct {
+	Dial    func() (net.Conn, error)
+	mux     sync.Mutex
+	current *pipeline
+}
+
+// PipelineClient 共享连接上的一个并发请求槽位
+type PipelineClient struct {
+	Timeout   int
+	Transport *PipelineTransport
+	used      *pipeline // 最近一次请求使用的连接
+}
+
+type pipeline struct {
+	conn     net.Conn
+	writeMux sync.Mutex
+	mux      sync.Mutex
+	nextId   uint16
+	pending  map[uint16]chan []byte
+	err      error
+}
+
+func (pc *PipelineClient) Reset(messenger Messenger) {
+	// 同一Transport的槽位共享连接, 由Transport在下一次请求时重连
+}
+
+func (pc *PipelineClient) Available() bool {
+	return pc.Transport != nil
+}
+
+// Close 仅关闭本槽位最近使用的连接, 其它槽位已重连的新连接不受影响
+func (pc *PipelineClient) Close() {
+	p := pc.used
+	if p == nil {
+		pc.Transport.mux.Lock()
+		p = pc.Transport.current
+		pc.Transport.mux.Unlock()
+	}
+	if p != nil {
+		p.fail(ErrModbusBadConn)
+	}
+}
+
+func (pc *PipelineClient) AskAtLeast(request []byte, response []byte, min int) (int, error) {
+	p, err := pc.Transport.get()
+	if err != nil {
+		return 0, ErrModbusBadConn
+	}
+	pc.used = p
+
+	frame, err := p.ask(request, time.Duration(pc.Timeout)*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if len(frame) < min || len(frame) > len(response) {
+		return 0, ErrMessageDataLengthNotEnough
+	}
+	n := copy(response, frame)
+	// 还原请求方的事务标识符
+	copy(response[:2], request[:2])
+	return n, nil
+}
+
+// get 返回可用连接, 连接不存在或已失败时重新建立
+func (pt *PipelineTransport) get() (*pipeline, error) {
+	pt.mux.Lock()
+	defer pt.mux.Unlock()
+	if pt.current != nil && !pt.current.failed() {
+		return pt.current, nil
+	}
+	conn, err := pt.Dial()
+	if err != nil {
+		klog.V(2).InfoS("Failed to connect Modbus server", "error", err)
+		return nil, err
+	}
+	p := &pipeline{
+		conn:    conn,
+		pending: make(map[uint16]chan []byte),
+	}
+	go p.read()
+	pt.current = p
+	return p, nil
+}
+
+func (p *pipeline) ask(request []byte, timeout time.Duration) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	p.mux.Lock()
+	if p.err != nil {
+		p.mux.Unlock()
+		return nil, ErrModbusBadConn
+	}
+	p.nextId++
+	transactionId := p.nextId
+	p.pending[transactionId] = ch
+	p.mux.Unlock()
+
+	buf := binutils.Dup(request)
+	binutils.WriteUint16BigEndian(buf, transactionId)
+	p.writeMux.Lock()
+	_, err := p.conn.Write(buf)
+	p.writeMux.Unlock()
+	if err != nil {
+		klog.V(2).InfoS("Failed to ask message", "error", err)
+		p.fail(err)
+		return nil, ErrModbusBadConn
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case frame, ok := <-ch:
+		if !ok {
+			return nil, ErrModbusBadConn
+		}
+		return frame, nil
+	case <-timer.C:
+		p.mux.Lock()
+		delete(p.pending, transactionId)
+		p.mux.Unlock()
+		return nil, ErrModbusTimeout
+	}
+}
+
+// read 按MBAP报文头读取完整响应, 按事务标识符分发, 未匹配的迟到响应直接丢弃
+func (p *pipeline) read() {
+	header := make([]byte, 6)
+	for {
+		if _, err := io.ReadFull(p.conn, header); err != nil {
+			p.fail(err)
+			return
+		}
+		length := int(binutils.ParseUint16BigEndian(header[4:]))
+		if length == 0 || length > 254 {
+			p.fail(ErrModbusServerBadResp)
+			return
+		}
+		frame := make([]byte, 6+length)
+		copy(frame, header)
+		if _, err := io.ReadFull(p.conn, frame[6:]); err != nil {
+			p.fail(err)
+			return
+		}
+
+		transactionId := binutils.ParseUint16(frame)
+		p.mux.Lock()
+		ch, ok := p.pending[transactionId]
+		delete(p.pending, transactionId)
+		p.mux.Unlock()
+		if !ok {
+			klog.V(5).InfoS("Discard unmatched Modbus response", "transactionId", transactionId)
+			continue
+		}
+		ch <- frame
+	}
+}
+
+func (p *pipeline) failed() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.err != nil
+}
+
+// fail 关闭连接并通知全部未完成的请求
+func (p *pipeline) fail(err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.err != nil {
+		return
+	}
+	klog.V(2).InfoS("Modbus pipeline connection closed", "error", err)
+	p.err = err
+	_ = p.conn.Close()
+	for transactionId, ch := range p.pending {
+		close(ch)
+		delete(p.pending, transactionId)
+	}
+}
@@ -0,0 +1,259 @@
+package runtime
+
+import (
+	"bytes"
+	"harnsplatform/internal/utils/binutils"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestPipeline 每次Dial通过net.Pipe建立连接, 服务端连接交给serve处理
+func newTestPipeline(t *testing.T, serve func(conn net.Conn)) (*PipelineTransport, *int) {
+	t.Helper()
+	var mux sync.Mutex
+	dials := 0
+	pt := &PipelineTransport{Dial: func() (net.Conn, error) {
+		client, server := net.Pipe()
+		mux.Lock()
+		dials++
+		mux.Unlock()
+		go serve(server)
+		t.Cleanup(func() { server.Close() })
+		return client, nil
+	}}
+	return pt, &dials
+}
+
+// pipelineRequest 读保持寄存器请求, payload放在功能码之后
+func pipelineRequest(transactionId uint16, payload ...byte) []byte {
+	frame := []byte{0, 0, 0, 0, 0, byte(2 + len(payload)), 1, 3}
+	binutils.WriteUint16BigEndian(frame, transactionId)
+	return append(frame, payload...)
+}
+
+func readPipelineRequest(conn net.Conn) ([]byte, error) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 6+int(binutils.ParseUint16BigEndian(header[4:])))
+	copy(frame, header)
+	_, err := io.ReadFull(conn, frame[6:])
+	return frame, err
+}
+
+// 响应乱序返回时按事务标识符分发给对应的请求方
+func TestPipelineDemux(t *testing.T) {
+	const n = 8
+	wireIds := make(chan uint16, n)
+	pt, dials := newTestPipeline(t, func(conn net.Conn) {
+		requests := make([][]byte, 0, n)
+		for len(requests) < n {
+			request, err := readPipelineRequest(conn)
+			if err != nil {
+				return
+			}
+			wireIds <- binutils.ParseUint16BigEndian(request)
+			requests = append(requests, request)
+		}
+		for i := len(requests) - 1; i >= 0; i-- {
+			if _, err := conn.Write(requests[i]); err != nil {
+				return
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pc := &PipelineClient{Timeout: 5, Transport: pt}
+			request := pipelineRequest(0x7F00, byte(i), byte(i*2))
+			response := make([]byte, 32)
+			count, err := pc.AskAtLeast(request, response, len(request))
+			if err != nil {
+				errs <- err
+				return
+			}
+			// 响应内容属于本请求, 事务标识符还原为请求方的值
+			if !bytes.Equal(response[:count], request) {
+				t.Errorf("client %d: got %x, want %x", i, response[:count], request)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	close(wireIds)
+	seen := make(map[uint16]bool)
+	for id := range wireIds {
+		if seen[id] {
+			t.Fatalf("transaction id %d reused on the wire", id)
+		}
+		seen[id] = true
+	}
+	if *dials != 1 {
+		t.Fatalf("dialed %d times, want 1", *dials)
+	}
+}
+
+func TestPipelineDiscardUnmatched(t *testing.T) {
+	pt, _ := newTestPipeline(t, func(conn net.Conn) {
+		for {
+			request, err := readPipelineRequest(conn)
+			if err != nil {
+				return
+			}
+			unmatched := binutils.Dup(request)
+			binutils.WriteUint16BigEndian(unmatched, binutils.ParseUint16BigEndian(request)+100)
+			unmatched[len(unmatched)-1] = 0xEE
+			if _, err = conn.Write(append(unmatched, request...)); err != nil {
+				return
+			}
+		}
+	})
+	pc := &PipelineClient{Timeout: 5, Transport: pt}
+	for i := 0; i < 3; i++ {
+		request := pipelineRequest(uint16(i), 0x11, byte(i))
+		response := make([]byte, 32)
+		count, err := pc.AskAtLeast(request, response, len(request))
+		if err != nil || !bytes.Equal(response[:count], request) {
+			t.Fatalf("request %d: got %x, %v", i, response[:count], err)
+		}
+	}
+}
+
+// 超时的请求不影响连接, 迟到的响应被丢弃
+func TestPipelineTimeout(t *testing.T) {
+	pt, dials := newTestPipeline(t, func(conn net.Conn) {
+		var late []byte
+		for {
+			request, err := readPipelineRequest(conn)
+			if err != nil {
+				return
+			}
+			if late == nil {
+				late = request
+				continue
+			}
+			if _, err = conn.Write(append(late, request...)); err != nil {
+				return
+			}
+		}
+	})
+	pc := &PipelineClient{Timeout: 1, Transport: pt}
+	response := make([]byte, 32)
+	if _, err := pc.AskAtLeast(pipelineRequest(1, 0xAA), response, 9); err != ErrModbusTimeout {
+		t.Fatalf("got %v, want %v", err, ErrModbusTimeout)
+	}
+	request := pipelineRequest(2, 0xBB)
+	count, err := pc.AskAtLeast(request, response, len(request))
+	if err != nil || !bytes.Equal(response[:count], request) {
+		t.Fatalf("got %x, %v", response[:count], err)
+	}
+	if *dials != 1 {
+		t.Fatalf("dialed %d times, want 1", *dials)
+	}
+}
+
+// 连接断开时未完成的请求返回错误, 下一次请求重新建立连接
+func TestPipelineReconnect(t *testing.T) {
+	var once sync.Once
+	pt, dials := newTestPipeline(t, func(conn net.Conn) {
+		for {
+			request, err := readPipelineRequest(conn)
+			if err != nil {
+				return
+			}
+			closed := false
+			once.Do(func() {
+				conn.Close()
+				closed = true
+			})
+			if closed {
+				return
+			}
+			if _, err = conn.Write(request); err != nil {
+				return
+			}
+		}
+	})
+	pc := &PipelineClient{Timeout: 5, Transport: pt}
+	response := make([]byte, 32)
+	request := pipelineRequest(1, 0xCC)
+	if _, err := pc.AskAtLeast(request, response, len(request)); err != ErrModbusBadConn {
+		t.Fatalf("got %v, want %v", err, ErrModbusBadConn)
+	}
+	count, err := pc.AskAtLeast(request, response, len(request))
+	if err != nil || !bytes.Equal(response[:count], request) {
+		t.Fatalf("got %x, %v", response[:count], err)
+	}
+	if *dials != 2 {
+		t.Fatalf("dialed %d times, want 2", *dials)
+	}
+
+	// Close关闭本槽位使用的连接
+	pc.Close()
+	if _, err = pc.AskAtLeast(request, response, len(request)); err != nil {
+		t.Fatal(err)
+	}
+	if *dials != 3 {
+		t.Fatalf("dialed %d times, want 3", *dials)
+	}
+}
+
+func TestPipelineResponseLength(t *testing.T) {
+	pt, _ := newTestPipeline(t, func(conn net.Conn) {
+		for {
+			request, err := readPipelineRequest(conn)
+			if err != nil {
+				return
+			}
+			if _, err = conn.Write(request); err != nil {
+				return
+			}
+		}
+	})
+	pc := &PipelineClient{Timeout: 5, Transport: pt}
+	request := pipelineRequest(1, 1, 2, 3, 4)
+	if _, err := pc.AskAtLeast(request, make([]byte, 32), len(request)+1); err != ErrMessageDataLengthNotEnough {
+		t.Fatalf("short response: got %v", err)
+	}
+	if _, err := pc.AskAtLeast(request, make([]byte, len(request)-1), 0); err != ErrMessageDataLengthNotEnough {
+		t.Fatalf("long response: got %v", err)
+	}
+}
+
+// 报文头长度非法时关闭连接
+func TestPipelineBadHeader(t *testing.T) {
+	pt, dials := newTestPipeline(t, func(conn net.Conn) {
+		for {
+			request, err := readPipelineRequest(conn)
+			if err != nil {
+				return
+			}
+			if _, err = conn.Write([]byte{0, 1, 0, 0, 0, 0}); err != nil {
+				return
+			}
+			_, _ = conn.Write(request)
+		}
+	})
+	pc := &PipelineClient{Timeout: 5, Transport: pt}
+	start := time.Now()
+	if _, err := pc.AskAtLeast(pipelineRequest(1), make([]byte, 32), 8); err != ErrModbusBadConn {
+		t.Fatalf("got %v, want %v", err, ErrModbusBadConn)
+	}
+	if time.Since(start) >= time.Second {
+		t.Fatal("request waited for timeout")
+	}
+	if _, err := pc.AskAtLeast(pipelineRequest(1), make([]byte, 32), 8); err != ErrModbusBadConn || *dials != 2 {
+		t.Fatalf("got %v after %d dials", err, *dials)
+	}
+}
@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"math"
+)
+
+/**
+线性换算
+读取: 工程值 = 原始值 × rate + offset, 再按min/max限幅, 按precision保留小数位
+写入: 工程值先限幅, 原始值 = (工程值 - offset) / rate
+*/
+
+// Scaled 变量是否配置了换算或限幅
+func (v *Variable) Scaled() bool {
+	return (v.Rate != 0 && v.Rate != 1) || v.OffSet != 0 || v.Min != nil || v.Max != nil
+}
+
+// ToEngineering 原始值 => 工程值, 未配置换算与精度时保持原始类型
+func (v *Variable) ToEngineering(raw interface{}) interface{} {
+	if !v.Scaled() && v.Precision == nil {
+		return raw
+	}
+	value, ok := toFloat64(raw)
+	if !ok {
+		return raw
+	}
+	if v.Rate != 0 {
+		value = value * v.Rate
+	}
+	value = v.clamp(value + v.OffSet)
+	if v.Precision != nil {
+		p := math.Pow10(*v.Precision)
+		value = math.Round(value*p) / p
+	}
+	return value
+}
+
+// ToRaw 工程值 => 原始值, 为ToEngineering的逆运算
+func (v *Variable) ToRaw(value float64) float64 {
+	value = v.clamp(value) - v.OffSet
+	if v.Rate != 0 {
+		value = value / v.Rate
+	}
+	return value
+}
+
+func (v *Variable) clamp(value float64) float64 {
+	if v.Min != nil && value < *v.Min {
+		value = *v.Min
+	}
+	if v.Max != nil && value > *v.Max {
+		value = *v.Max
+	}
+	return value
+}
+
+func toFloat64(raw interface{}) (float64, bool) {
+	switch r := raw.(type) {
+	case int8:
+		return float64(r), true
+	case uint8:
+		return float64(r), true
+	case int16:
+		return float64(r), true
+	case uint16:
+		return float64(r), true
+	case int32:
+		return float64(r), true
+	case uint32:
+		return float64(r), true
+	case int64:
+		return float64(r), true
+	case uint64:
+		return float64(r), true
+	case float32:
+		return float64(r), true
+	case float64:
+		return r, true
+	}
+	return 0, false
+}
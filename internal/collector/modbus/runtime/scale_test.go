@@ -0,0 +1,82 @@
+package runtime
+
+import (
+	"math"
+	"testing"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestToEngineering(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable *Variable
+		raw      interface{}
+		want     interface{}
+	}{
+		{"not scaled keeps type", &Variable{Rate: 1}, int16(-5), int16(-5)},
+		{"zero rate keeps type", &Variable{}, uint32(7), uint32(7)},
+		{"rate", &Variable{Rate: 0.1}, int16(123), 12.3},
+		{"rate and offset", &Variable{Rate: 2, OffSet: -10}, uint16(100), 190.0},
+		{"offset only", &Variable{Rate: 1, OffSet: 0.5}, int8(-1), -0.5},
+		{"clamp max", &Variable{Rate: 2, Max: float64Ptr(100)}, uint16(100), 100.0},
+		{"clamp min", &Variable{Rate: 1, OffSet: -50, Min: float64Ptr(0)}, uint16(20), 0.0},
+		{"clamp without rate", &Variable{Rate: 1, Min: float64Ptr(-1), Max: float64Ptr(1)}, int32(-3), -1.0},
+		{"precision", &Variable{Rate: 1, Precision: intPtr(2)}, float32(1.23456), 1.23},
+		{"precision rounds", &Variable{Rate: 1, Precision: intPtr(0)}, 2.5, 3.0},
+		{"rate with precision", &Variable{Rate: 0.001, Precision: intPtr(1)}, uint64(12345), 12.3},
+		// 非数值保持原值
+		{"bool", &Variable{Rate: 2}, true, true},
+		{"string", &Variable{Rate: 2}, "abc", "abc"},
+		{"nil", &Variable{Rate: 2}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.variable.ToEngineering(tt.raw)
+			if f, ok := tt.want.(float64); ok {
+				if v, isFloat := got.(float64); !isFloat || math.Abs(v-f) > 1e-9 {
+					t.Fatalf("got %v(%T), want %v", got, got, tt.want)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("got %v(%T), want %v(%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestToRaw(t *testing.T) {
+	tests := []struct {
+		name        string
+		variable    *Variable
+		engineering float64
+		raw         float64
+	}{
+		{"not scaled", &Variable{Rate: 1}, 42, 42},
+		{"rate and offset", &Variable{Rate: 0.1, OffSet: 5}, 17.3, 123},
+		{"negative rate", &Variable{Rate: -2}, 10, -5},
+		{"clamp before inverse", &Variable{Rate: 2, Max: float64Ptr(100)}, 150, 50},
+		{"clamp min", &Variable{Rate: 1, OffSet: -50, Min: float64Ptr(0)}, -10, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := tt.variable.ToRaw(tt.engineering)
+			if math.Abs(raw-tt.raw) > 1e-9 {
+				t.Fatalf("got %v, want %v", raw, tt.raw)
+			}
+			// 未限幅时为ToEngineering的逆运算
+			if tt.variable.Min == nil && tt.variable.Max == nil {
+				if engineering, _ := tt.variable.ToEngineering(raw).(float64); math.Abs(engineering-tt.engineering) > 1e-9 {
+					t.Fatalf("round trip %v", engineering)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package runtime
+
+import (
+	"errors"
+	"go.bug.st/serial"
+	"k8s.io/klog/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ Messenger = (*SerialBusClient)(nil)
+
+/**
+RS-485 总线共享
+同一串口上的多个设备(不同下位机号)共用一个SerialBus, 由SerialBus持有串口
+总线上同一时刻只有一个事务, 等待的设备按轮询顺序依次获得总线, 同一设备的多个请求按先后顺序排队
+*/
+
+// SerialBuses 进程内按串口路径索引的总线
+var SerialBuses = &SerialBusRegistry{buses: make(map[string]*SerialBus)}
+
+type SerialBusRegistry struct {
+	mux     sync.Mutex
+	buses   map[string]*SerialBus
+	ownerId uint64
+}
+
+type SerialBus struct {
+	Location string
+	Mode     *serial.Mode
+	port     serial.Port // 仅在持有总线时访问
+	released bool        // 全部设备已离开, 持有总线时访问
+	owners   []uint64    // 轮询顺序
+	waiters  map[uint64][]chan struct{}
+	busy     bool
+	last     uint64    // 最近获得总线的设备
+	idleAt   time.Time // 最近一帧结束的时间
+	mux      sync.Mutex
+	stats    serialBusCounter
+}
+
+type serialBusCounter struct {
+	requests      uint64
+	errors        uint64
+	timeouts      uint64
+	bytesSent     uint64
+	bytesReceived uint64
+	busyNanos     uint64
+	waitNanos     uint64
+}
+
+// SerialBusStats 总线统计
+type SerialBusStats struct {
+	Location      string `json:"location"`
+	BaudRate      int    `json:"baudRate"`
+	Devices       int    `json:"devices"`
+	Requests      uint64 `json:"requests"`
+	Errors        uint64 `json:"errors"`
+	Timeouts      uint64 `json:"timeouts"`
+	BytesSent     uint64 `json:"bytesSent"`
+	BytesReceived uint64 `json:"bytesReceived"`
+	BusyTime      int64  `json:"busyTime"` // 总线占用时长 毫秒
+	WaitTime      int64  `json:"waitTime"` // 设备等待总线的累计时长 毫秒
+}
+
+// SerialBusClient 一个设备在总线上的收发端
+type SerialBusClient struct {
+	Timeout    int
+	Ascii      bool // ascii帧以CRLF结束
+	EchoCancel bool // 半双工适配器回显消除
+	Bus        *SerialBus
+	OwnerId    uint64
+	closed     bool
+}
+
+// Acquire 加入串口总线, 串口未打开时打开, 已打开时串口参数必须一致
+func (r *SerialBusRegistry) Acquire(location string, mode *serial.Mode) (*SerialBus, uint64, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	bus, exist := r.buses[location]
+	if exist {
+		if !sameSerialMode(bus.Mode, mode) {
+			klog.V(2).InfoS("Failed to share serial bus, mode conflict", "location", location)
+			return nil, 0, ErrSerialBusModeConflict
+		}
+	} else {
+		port, err := serial.Open(location, mode)
+		if err != nil {
+			klog.V(2).InfoS("Failed to connect serial port", "address", location)
+			return nil, 0, err
+		}
+		bus = &SerialBus{
+			Location: location,
+			Mode:     mode,
+			port:     port,
+			waiters:  make(map[uint64][]chan struct{}),
+		}
+		r.buses[location] = bus
+	}
+	r.ownerId++
+	bus.mux.Lock()
+	bus.owners = append(bus.owners, r.ownerId)
+	bus.mux.Unlock()
+	return bus, r.ownerId, nil
+}
+
+func sameSerialMode(a, b *serial.Mode) bool {
+	return a.BaudRate == b.BaudRate && a.DataBits == b.DataBits && a.Parity == b.Parity && a.StopBits == b.StopBits
+}
+
+// Release 离开串口总线, 最后一个设备离开时关闭串口
+// 先获得总线再离开, 避免关闭其他设备事务正在使用的串口
+func (r *SerialBusRegistry) Release(bus *SerialBus, ownerId uint64) {
+	bus.lock(ownerId)
+	defer bus.unlock()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	bus.mux.Lock()
+	for i, id := range bus.owners {
+		if id == ownerId {
+			bus.owners = append(bus.owners[:i], bus.owners[i+1:]...)
+			break
+		}
+	}
+	remain := len(bus.owners)
+	bus.mux.Unlock()
+	if remain > 0 {
+		return
+	}
+	if bus.port != nil {
+		_ = bus.port.Close()
+		bus.port = nil
+	}
+	bus.released = true
+	delete(r.buses, bus.Location)
+}
+
+// Stats 全部总线的统计, 按串口路径排序
+func (r *SerialBusRegistry) Stats() []*SerialBusStats {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	stats := make([]*SerialBusStats, 0, len(r.buses))
+	for _, bus := range r.buses {
+		stats = append(stats, bus.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Location < stats[j].Location
+	})
+	return stats
+}
+
+func (b *SerialBus) Stats() *SerialBusStats {
+	b.mux.Lock()
+	devices := len(b.owners)
+	b.mux.Unlock()
+	return &SerialBusStats{
+		Location:      b.Location,
+		BaudRate:      b.Mode.BaudRate,
+		Devices:       devices,
+		Requests:      atomic.LoadUint64(&b.stats.requests),
+		Errors:        atomic.LoadUint64(&b.stats.errors),
+		Timeouts:      atomic.LoadUint64(&b.stats.timeouts),
+		BytesSent:     atomic.LoadUint64(&b.stats.bytesSent),
+		BytesReceived: atomic.LoadUint64(&b.stats.bytesReceived),
+		BusyTime:      time.Duration(atomic.LoadUint64(&b.stats.busyNanos)).Milliseconds(),
+		WaitTime:      time.Duration(atomic.LoadUint64(&b.stats.waitNanos)).Milliseconds(),
+	}
+}
+
+// lock 获得总线, 总线忙时进入该设备的等待队列
+func (b *SerialBus) lock(ownerId uint64) {
+	b.mux.Lock()
+	if !b.busy {
+		b.busy = true
+		b.last = ownerId
+		b.mux.Unlock()
+		return
+	}
+	ch := make(chan struct{})
+	b.waiters[ownerId] = append(b.waiters[ownerId], ch)
+	b.mux.Unlock()
+	<-ch
+}
+
+// unlock 从上一次获得总线的设备之后开始轮询, 交给下一个有等待请求的设备
+func (b *SerialBus) unlock() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	start := 0
+	for i, id := range b.owners {
+		if id == b.last {
+			start = i + 1
+			break
+		}
+	}
+	for i := 0; i < len(b.owners); i++ {
+		id := b.owners[(start+i)%len(b.owners)]
+		if ws := b.waiters[id]; len(ws) > 0 {
+			b.waiters[id] = ws[1:]
+			b.last = id
+			close(ws[0])
+			return
+		}
+	}
+	// 已离开总线的设备仍可能有等待的请求
+	for id, ws := range b.waiters {
+		if len(ws) > 0 {
+			b.waiters[id] = ws[1:]
+			b.last = id
+			close(ws[0])
+			return
+		}
+	}
+	b.busy = false
+}
+
+func (sbc *SerialBusClient) Reset(messenger Messenger) {
+	nsbc := (messenger).(*SerialBusClient)
+	sbc.Bus = nsbc.Bus
+	sbc.OwnerId = nsbc.OwnerId
+	sbc.closed = false
+}
+
+func (sbc *SerialBusClient) Available() bool {
+	return sbc.Bus != nil && !sbc.closed
+}
+
+func (sbc *SerialBusClient) Close() {
+	if sbc.closed {
+		return
+	}
+	sbc.closed = true
+	SerialBuses.Release(sbc.Bus, sbc.OwnerId)
+}
+
+func (sbc *SerialBusClient) AskAtLeast(request []byte, response []byte, min int) (int, error) {
+	bus := sbc.Bus
+	waitStart := time.Now()
+	bus.lock(sbc.OwnerId)
+	defer bus.unlock()
+	busyStart := time.Now()
+	atomic.AddUint64(&bus.stats.waitNanos, uint64(busyStart.Sub(waitStart)))
+	defer func() {
+		atomic.AddUint64(&bus.stats.busyNanos, uint64(time.Since(busyStart)))
+	}()
+
+	if bus.released {
+		// 离开总线前已在排队的请求
+		return 0, ErrModbusBadConn
+	}
+	if bus.port == nil {
+		// 串口异常关闭后由下一个事务重新打开
+		port, err := serial.Open(bus.Location, bus.Mode)
+		if err != nil {
+			klog.V(2).InfoS("Failed to connect serial port", "address", bus.Location)
+			atomic.AddUint64(&bus.stats.errors, 1)
+			return 0, ErrModbusBadConn
+		}
+		bus.port = port
+	}
+
+	sc := &SerialClient{
+		Timeout:    sbc.Timeout,
+		Port:       bus.port,
+		Ascii:      sbc.Ascii,
+		CharTime:   RtuCharTime(bus.Mode),
+		EchoCancel: sbc.EchoCancel,
+	}
+	if !sbc.Ascii {
+		// rtu帧之间至少保持t3.5的静默
+		_, t35 := RtuSilence(sc.CharTime)
+		if idle := time.Since(bus.idleAt); idle < t35 {
+			time.Sleep(t35 - idle)
+		}
+	}
+
+	atomic.AddUint64(&bus.stats.requests, 1)
+	n, err := sc.AskAtLeast(request, response, min)
+	bus.idleAt = time.Now()
+	if err != nil {
+		atomic.AddUint64(&bus.stats.errors, 1)
+		switch {
+		case errors.Is(err, ErrModbusBadConn):
+			_ = bus.port.Close()
+			bus.port = nil
+		case errors.Is(err, ErrMessageDataLengthNotEnough):
+			atomic.AddUint64(&bus.stats.timeouts, 1)
+		}
+		return 0, err
+	}
+	atomic.AddUint64(&bus.stats.bytesSent, uint64(len(request)))
+	atomic.AddUint64(&bus.stats.bytesReceived, uint64(n))
+	return n, nil
+}
@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitBus 设备ownerId请求总线, 进入等待队列后返回, 获得总线时写入granted
+func waitBus(t *testing.T, b *SerialBus, ownerId uint64, granted chan uint64) {
+	t.Helper()
+	b.mux.Lock()
+	queued := len(b.waiters[ownerId])
+	b.mux.Unlock()
+	go func() {
+		b.lock(ownerId)
+		granted <- ownerId
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		b.mux.Lock()
+		n := len(b.waiters[ownerId])
+		b.mux.Unlock()
+		if n > queued {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("owner %d not queued", ownerId)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSerialBusUnlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		owners  []uint64
+		holder  uint64
+		waiters []uint64 // 按排队顺序
+		granted []uint64
+	}{
+		{
+			// 从持有者之后开始轮询, 同一设备的请求按先后顺序
+			name:    "round robin",
+			owners:  []uint64{1, 2, 3},
+			holder:  1,
+			waiters: []uint64{1, 1, 2, 3},
+			granted: []uint64{2, 3, 1, 1},
+		},
+		{
+			name:    "wrap around",
+			owners:  []uint64{1, 2, 3},
+			holder:  3,
+			waiters: []uint64{2, 3, 1},
+			granted: []uint64{1, 2, 3},
+		},
+		{
+			name:    "two owners alternate",
+			owners:  []uint64{1, 2},
+			holder:  1,
+			waiters: []uint64{1, 1, 2, 2},
+			granted: []uint64{2, 1, 2, 1},
+		},
+		{
+			// 已离开总线的设备的等待请求排在在线设备之后
+			name:    "departed owner",
+			owners:  []uint64{1, 2},
+			holder:  1,
+			waiters: []uint64{5, 2, 5},
+			granted: []uint64{2, 5, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &SerialBus{owners: tt.owners, waiters: make(map[uint64][]chan struct{})}
+			b.lock(tt.holder)
+			granted := make(chan uint64, len(tt.waiters))
+			for _, id := range tt.waiters {
+				waitBus(t, b, id, granted)
+			}
+			got := make([]uint64, 0, len(tt.waiters))
+			for range tt.waiters {
+				b.unlock()
+				select {
+				case id := <-granted:
+					got = append(got, id)
+				case <-time.After(time.Second):
+					t.Fatalf("bus not granted after %v", got)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.granted) {
+				t.Fatalf("got %v, want %v", got, tt.granted)
+			}
+			b.unlock()
+			if b.busy {
+				t.Fatal("bus still busy without waiters")
+			}
+		})
+	}
+}
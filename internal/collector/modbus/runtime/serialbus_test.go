@@ -0,0 +1,113 @@
+package runtime_test
+
+import (
+	"errors"
+	"go.bug.st/serial"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/collector/modbus/slave"
+	"harnsplatform/internal/utils"
+	"harnsplatform/internal/utils/binutils"
+	"sync"
+	"testing"
+)
+
+func newSerialBusSlave(t *testing.T) *slave.Pty {
+	t.Helper()
+	pty, err := slave.OpenPty()
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	server := slave.NewServer(nil, slave.NewSlave(1, 8, 8, 8, 8))
+	go func() {
+		_ = server.ServeRtu(pty.Master)
+	}()
+	t.Cleanup(func() {
+		_ = pty.Close()
+	})
+	return pty
+}
+
+func readHoldingRegisterRequest() []byte {
+	request := []byte{1, 3, 0, 0, 0, 1, 0, 0}
+	binutils.WriteUint16BigEndian(request[6:], utils.CheckCrc16sum(request[:6]))
+	return request
+}
+
+// 一个设备离开总线时, 同一总线上其他设备的事务不受影响, 需以-race运行
+func TestSerialBusReleaseDuringTransaction(t *testing.T) {
+	pty := newSerialBusSlave(t)
+	mode := &serial.Mode{BaudRate: 115200, DataBits: 8, Parity: serial.NoParity, StopBits: serial.OneStopBit}
+
+	busy, busyOwner, err := runtime.SerialBuses.Acquire(pty.Name, mode)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	_, leavingOwner, err := runtime.SerialBuses.Acquire(pty.Name, mode)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	client := &runtime.SerialBusClient{Timeout: 500, Bus: busy, OwnerId: busyOwner}
+	leaving := &runtime.SerialBusClient{Timeout: 500, Bus: busy, OwnerId: leavingOwner}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		response := make([]byte, 7)
+		for i := 0; i < 20; i++ {
+			if _, err := client.AskAtLeast(readHoldingRegisterRequest(), response, 7); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	leaving.Close()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("transaction failed after another device left the bus: %v", err)
+	}
+
+	stats := runtime.SerialBuses.Stats()
+	if len(stats) != 1 || stats[0].Devices != 1 || stats[0].Requests != 20 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	client.Close()
+	if stats := runtime.SerialBuses.Stats(); len(stats) != 0 {
+		t.Errorf("bus not removed after last device left: %+v", stats)
+	}
+}
+
+// 最后一个设备在事务进行中离开时, 等待事务结束后再关闭串口, 之后的请求不再打开串口
+func TestSerialBusCloseDuringTransaction(t *testing.T) {
+	pty := newSerialBusSlave(t)
+	mode := &serial.Mode{BaudRate: 115200, DataBits: 8, Parity: serial.NoParity, StopBits: serial.OneStopBit}
+
+	bus, owner, err := runtime.SerialBuses.Acquire(pty.Name, mode)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	client := &runtime.SerialBusClient{Timeout: 500, Bus: bus, OwnerId: owner}
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		response := make([]byte, 7)
+		var last error
+		for i := 0; i < 20; i++ {
+			if i == 1 {
+				close(started)
+			}
+			_, last = client.AskAtLeast(readHoldingRegisterRequest(), response, 7)
+		}
+		done <- last
+	}()
+	<-started
+	client.Close()
+	if err := <-done; !errors.Is(err, runtime.ErrModbusBadConn) {
+		t.Errorf("request after release: got %v, want %v", err, runtime.ErrModbusBadConn)
+	}
+	if stats := runtime.SerialBuses.Stats(); len(stats) != 0 {
+		t.Errorf("bus not removed after last device left: %+v", stats)
+	}
+}
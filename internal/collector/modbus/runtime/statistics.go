@@ -0,0 +1,223 @@
+package runtime
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+/**
+通讯统计
+设备与每个采集报文各自累计请求、超时、校验错误、异常码、事务不匹配、重连次数以及往返时延
+写报文与诊断请求只计入设备统计
+*/
+
+// CommStatistics 通讯计数, 时延单位为毫秒
+type CommStatistics struct {
+	Requests              uint64           `json:"requests"`
+	Responses             uint64           `json:"responses"`             // 正常响应
+	Timeouts              uint64           `json:"timeouts"`              // 等待响应超时
+	ChecksumErrors        uint64           `json:"checksumErrors"`        // CRC或LRC校验失败
+	Exceptions            map[uint8]uint64 `json:"exceptions,omitempty"`  // 异常码 => 次数
+	TransactionMismatches uint64           `json:"transactionMismatches"` // 事务标识符不匹配
+	SlaveMismatches       uint64           `json:"slaveMismatches"`       // 响应的下位机号不匹配
+	BadResponses          uint64           `json:"badResponses"`          // 长度或功能码错误
+	ConnErrors            uint64           `json:"connErrors"`            // 连接读写失败
+	Reconnects            uint64           `json:"reconnects"`
+	MinLatency            float64          `json:"minLatency"`
+	AvgLatency            float64          `json:"avgLatency"`
+	MaxLatency            float64          `json:"maxLatency"`
+	LastError             string           `json:"lastError,omitempty"`
+	LastErrorTime         *time.Time       `json:"lastErrorTime,omitempty"`
+}
+
+type Counters struct {
+	mux          sync.Mutex
+	stats        CommStatistics
+	totalLatency time.Duration
+	latencyCount uint64
+}
+
+// Observe 记录一次请求, latency为发送到收到响应的时间, 收发错误需先经AskError区分超时与连接异常
+func (c *Counters) Observe(latency time.Duration, err error) {
+	if c == nil {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stats.Requests++
+
+	var me *ModbusException
+	responded := true
+	switch {
+	case err == nil:
+		c.stats.Responses++
+	case errors.As(err, &me):
+		if c.stats.Exceptions == nil {
+			c.stats.Exceptions = make(map[uint8]uint64, 0)
+		}
+		c.stats.Exceptions[uint8(me.ExceptionCode)]++
+	case errors.Is(err, ErrModbusTimeout):
+		c.stats.Timeouts++
+		responded = false
+	case errors.Is(err, ErrCRC16Error), errors.Is(err, ErrLRCError):
+		c.stats.ChecksumErrors++
+	case errors.Is(err, ErrMessageTransaction):
+		c.stats.TransactionMismatches++
+	case errors.Is(err, ErrMessageSlave):
+		c.stats.SlaveMismatches++
+	case errors.Is(err, ErrModbusBadConn):
+		c.stats.ConnErrors++
+		responded = false
+	default:
+		c.stats.BadResponses++
+	}
+	if err != nil {
+		now := time.Now()
+		c.stats.LastError = err.Error()
+		c.stats.LastErrorTime = &now
+	}
+	if !responded {
+		return
+	}
+
+	ms := float64(latency) / float64(time.Millisecond)
+	if c.latencyCount == 0 || ms < c.stats.MinLatency {
+		c.stats.MinLatency = ms
+	}
+	if ms > c.stats.MaxLatency {
+		c.stats.MaxLatency = ms
+	}
+	c.totalLatency += latency
+	c.latencyCount++
+	c.stats.AvgLatency = float64(c.totalLatency) / float64(c.latencyCount) / float64(time.Millisecond)
+}
+
+func (c *Counters) Reconnect() {
+	if c == nil {
+		return
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stats.Reconnects++
+}
+
+func (c *Counters) Snapshot() CommStatistics {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	stats := c.stats
+	if c.stats.Exceptions != nil {
+		stats.Exceptions = make(map[uint8]uint64, len(c.stats.Exceptions))
+		for code, count := range c.stats.Exceptions {
+			stats.Exceptions[code] = count
+		}
+	}
+	return stats
+}
+
+func (c *Counters) Reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stats = CommStatistics{}
+	c.totalLatency = 0
+	c.latencyCount = 0
+}
+
+// FrameCounters 单个采集报文的统计
+type FrameCounters struct {
+	ScanClass    string `json:"scanClass,omitempty"`
+	FunctionCode uint8  `json:"functionCode"`
+	StartAddress uint   `json:"startAddress"`
+	Quantity     uint   `json:"quantity"`
+	Counters
+}
+
+// Statistics 设备的通讯统计
+type Statistics struct {
+	Device *Counters
+	Frames []*FrameCounters
+	since  time.Time
+	mux    sync.Mutex
+}
+
+type FrameStatistics struct {
+	ScanClass    string `json:"scanClass,omitempty"`
+	FunctionCode uint8  `json:"functionCode"`
+	StartAddress uint   `json:"startAddress"`
+	Quantity     uint   `json:"quantity"`
+	CommStatistics
+}
+
+type StatisticsSnapshot struct {
+	Since  time.Time          `json:"since"`
+	Device CommStatistics     `json:"device"`
+	Frames []*FrameStatistics `json:"frames"`
+}
+
+func NewStatistics() *Statistics {
+	return &Statistics{
+		Device: &Counters{},
+		Frames: make([]*FrameCounters, 0),
+		since:  time.Now(),
+	}
+}
+
+// AddFrame 注册采集报文, 返回该报文的计数器
+func (s *Statistics) AddFrame(scanClass string, functionCode uint8, startAddress, quantity uint) *Counters {
+	fc := &FrameCounters{
+		ScanClass:    scanClass,
+		FunctionCode: functionCode,
+		StartAddress: startAddress,
+		Quantity:     quantity,
+	}
+	s.Frames = append(s.Frames, fc)
+	return &fc.Counters
+}
+
+// Observe 同时计入设备与报文统计, frame为空时只计入设备
+func (s *Statistics) Observe(frame *Counters, latency time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	s.Device.Observe(latency, err)
+	frame.Observe(latency, err)
+}
+
+func (s *Statistics) Reconnect(frame *Counters) {
+	if s == nil {
+		return
+	}
+	s.Device.Reconnect()
+	frame.Reconnect()
+}
+
+func (s *Statistics) Snapshot() *StatisticsSnapshot {
+	s.mux.Lock()
+	since := s.since
+	s.mux.Unlock()
+	snapshot := &StatisticsSnapshot{
+		Since:  since,
+		Device: s.Device.Snapshot(),
+		Frames: make([]*FrameStatistics, 0, len(s.Frames)),
+	}
+	for _, fc := range s.Frames {
+		snapshot.Frames = append(snapshot.Frames, &FrameStatistics{
+			ScanClass:      fc.ScanClass,
+			FunctionCode:   fc.FunctionCode,
+			StartAddress:   fc.StartAddress,
+			Quantity:       fc.Quantity,
+			CommStatistics: fc.Snapshot(),
+		})
+	}
+	return snapshot
+}
+
+func (s *Statistics) Reset() {
+	s.mux.Lock()
+	s.since = time.Now()
+	s.mux.Unlock()
+	s.Device.Reset()
+	for _, fc := range s.Frames {
+		fc.Reset()
+	}
+}
@@ -5,24 +5,77 @@ import (
 	"harnsplatform/internal/collector"
 	"harnsplatform/internal/common"
 	"harnsplatform/internal/utils/binutils"
-	"strconv"
+	"k8s.io/klog/v2"
+	"math"
+	"math/bits"
 )
 
 var _ collector.Device = (*ModBusDevice)(nil)
 var _ collector.VariableValue = (*Variable)(nil)
 
 type Variable struct {
-	DataType     common.DataType   `json:"dataType"`     // bool、int16、float32、float64、int32、int64、uint16
-	Name         string            `json:"name"`         // 变量名称
-	Address      uint              `json:"address"`      // 变量地址
-	FunctionCode uint8             `json:"functionCode"` // 功能码 1、2、3、4
-	Bits         uint8             `json:"bits"`
-	Amount       uint              `json:"amount"`                 // 数量
-	Rate         float64           `json:"rate"`                   // 比率
-	OffSet       float64           `json:"offset"`                 // 比率
-	DefaultValue interface{}       `json:"defaultValue,omitempty"` // 默认值
-	Value        interface{}       `json:"value,omitempty"`        // 值
-	AccessMode   common.AccessMode `json:"accessMode"`             // 读写属性
+	DataType     common.DataType      `json:"dataType"`               // bool、int16、float32、float64、int32、int64、uint16
+	Name         string               `json:"name"`                   // 变量名称
+	Address      uint                 `json:"address"`                // 变量地址
+	FunctionCode uint8                `json:"functionCode"`           // 功能码 1、2、3、4
+	Bits         uint8                `json:"bits"`                   // 位寻址时的起始位 0-15
+	BitAddress   bool                 `json:"bitAddress,omitempty"`   // 寄存器位寻址 例如40010.3
+	Amount       uint                 `json:"amount"`                 // 数量 位寻址时为位宽
+	Rate         float64              `json:"rate"`                   // 比率
+	OffSet       float64              `json:"offset"`                 // 偏移量 工程值 = 原始值 × 比率 + 偏移量
+	DefaultValue interface{}          `json:"defaultValue,omitempty"` // 默认值
+	Value        interface{}          `json:"value,omitempty"`        // 值
+	AccessMode   common.AccessMode    `json:"accessMode"`             // 读写属性
+	MemoryLayout *common.MemoryLayout `json:"memoryLayout,omitempty"` // 内存布局, 为空时使用设备配置
+	Min          *float64             `json:"min,omitempty"`          // 工程值下限
+	Max          *float64             `json:"max,omitempty"`          // 工程值上限
+	Precision    *int                 `json:"precision,omitempty"`    // 工程值保留的小数位
+	ScanClass    string               `json:"scanClass,omitempty"`    // 扫描类别, 为空时属于默认类别
+}
+
+// BitMask 位寻址变量在寄存器中占用的位掩码
+func (v *Variable) BitMask() uint16 {
+	width := v.Amount
+	if width == 0 {
+		width = 1
+	}
+	return uint16((1<<width)-1) << v.Bits
+}
+
+// GetMemoryLayout 变量的内存布局, 未配置时使用设备默认值
+func (v *Variable) GetMemoryLayout(deviceLayout common.MemoryLayout) common.MemoryLayout {
+	if v.MemoryLayout != nil {
+		return *v.MemoryLayout
+	}
+	return deviceLayout
+}
+
+// Words 变量占用的寄存器数量, 字符串为Amount
+func (v *Variable) Words() uint {
+	if v.DataType == common.STRING && !v.BitAddress {
+		return v.Amount
+	}
+	return common.DataTypeWord[v.DataType]
+}
+
+// ParseBitValue 从寄存器值中提取位寻址变量的值
+func (v *Variable) ParseBitValue(register uint16) interface{} {
+	field := (register & v.BitMask()) >> v.Bits
+	switch v.DataType {
+	case common.BOOL:
+		return field != 0
+	case common.INT16:
+		// 按位宽做符号扩展
+		shift := 16 - bits.OnesCount16(v.BitMask())
+		return int16(field<<shift) >> shift
+	case common.INT8:
+		shift := 16 - bits.OnesCount16(v.BitMask())
+		return int8(int16(field<<shift) >> shift)
+	case common.UINT8:
+		return uint8(field)
+	default:
+		return field
+	}
 }
 
 func (v *Variable) GetVariableAccessMode() common.AccessMode {
@@ -47,14 +100,43 @@ func (v *Variable) SetVariableName(name string) {
 
 type ModBusDevice struct {
 	collector.DeviceMeta
-	CollectorCycle   uint                 `json:"collectorCycle"`                    // 采集周期
-	VariableInterval uint                 `json:"variableInterval"`                  // 变量间隔
-	Address          *Address             `json:"address"`                           // IP地址\串口地址
-	Slave            uint                 `json:"slave"`                             // 下位机号
-	MemoryLayout     common.MemoryLayout  `json:"memoryLayout"`                      // 内存布局 DCBA CDAB BADC ABCD
-	PositionAddress  uint                 `json:"positionAddress"`                   // 起始地址
-	Variables        []*Variable          `json:"variables" binding:"required,dive"` // 自定义变量
-	VariablesMap     map[string]*Variable `json:"-"`                                 // 自定义变量Map
+	CollectorCycle   uint                    `json:"collectorCycle"`                    // 采集周期 毫秒
+	VariableInterval uint                    `json:"variableInterval"`                  // 相邻两帧的最小间隔 毫秒
+	OverrunPolicy    collector.OverrunPolicy `json:"overrunPolicy"`                     // 采集超过周期时的处理策略
+	Address          *Address                `json:"address"`                           // IP地址\串口地址
+	Slave            uint                    `json:"slave"`                             // 下位机号
+	MemoryLayout     common.MemoryLayout     `json:"memoryLayout"`                      // 内存布局 DCBA CDAB BADC ABCD
+	WriteMode        WriteMode               `json:"writeMode"`                         // 写入方式 batch readWrite
+	MaskWrite        bool                    `json:"maskWrite"`                         // 是否支持22功能码位写入
+	VerifyWrite      bool                    `json:"verifyWrite"`                       // 写入后校验回显并读回确认
+	VerifyDelay      uint                    `json:"verifyDelay"`                       // 读回前等待 毫秒
+	WriteRetries     uint                    `json:"writeRetries"`                      // 写入失败或校验不一致时的重试次数
+	MaxRegisters     uint                    `json:"maxRegisters"`                      // 单次读取的最大寄存器数量
+	MaxCoils         uint                    `json:"maxCoils"`                          // 单次读取的最大线圈数量
+	MaxGap           *uint                   `json:"maxGap,omitempty"`                  // 合并报文时允许跨越的最大未映射地址数
+	ForbiddenRanges  []*AddressRange         `json:"forbiddenRanges,omitempty"`         // 禁止跨越读取的地址段
+	ScanClasses      []*ScanClass            `json:"scanClasses,omitempty"`             // 扫描类别
+	MaxInFlight      uint                    `json:"maxInFlight"`                       // 单个连接同时保持的未完成请求数
+	MaxConnections   uint                    `json:"maxConnections"`                    // 流水线连接数
+	PositionAddress  uint                    `json:"positionAddress"`                   // 起始地址
+	Variables        []*Variable             `json:"variables" binding:"required,dive"` // 自定义变量
+	VariablesMap     map[string]*Variable    `json:"-"`                                 // 自定义变量Map
+}
+
+// GetMaxRegisters 单次读取的最大寄存器数量, 未配置时使用协议上限
+func (m *ModBusDevice) GetMaxRegisters() uint {
+	if m.MaxRegisters == 0 {
+		return PerRequestMaxRegister
+	}
+	return m.MaxRegisters
+}
+
+// GetMaxCoils 单次读取的最大线圈数量, 未配置时使用协议上限
+func (m *ModBusDevice) GetMaxCoils() uint {
+	if m.MaxCoils == 0 {
+		return PerRequestMaxCoil
+	}
+	return m.MaxCoils
 }
 
 func (m *ModBusDevice) IndexDevice() {
@@ -93,6 +175,27 @@ type Option struct {
 	DataBits int             `json:"dataBits,omitempty"` // 数据位
 	Parity   common.Parity   `json:"parity,omitempty"`   // 校验位
 	StopBits common.StopBits `json:"stopBits,omitempty"` // 停止位
+	// EchoCancel 半双工485适配器会回显发送的字节
+	EchoCancel bool `json:"echoCancel,omitempty"`
+}
+
+// ScanClass 扫描类别 同一类别的变量按相同周期采集
+type ScanClass struct {
+	Name           string                  `json:"name"`
+	CollectorCycle uint                    `json:"collectorCycle"` // 采集周期 毫秒
+	OverrunPolicy  collector.OverrunPolicy `json:"overrunPolicy"`
+}
+
+// AddressRange 同一功能码下的地址段, 包含首尾
+type AddressRange struct {
+	FunctionCode uint8 `json:"functionCode"`
+	Start        uint  `json:"start"`
+	End          uint  `json:"end"`
+}
+
+// Overlaps 地址段与[start, end)是否有交集
+func (ar *AddressRange) Overlaps(functionCode uint8, start, end uint) bool {
+	return ar.FunctionCode == functionCode && ar.Start < end && ar.End >= start
 }
 
 type VariableSlice []*Variable
@@ -109,6 +212,20 @@ func (vs VariableSlice) Swap(i, j int) {
 	vs[i], vs[j] = vs[j], vs[i]
 }
 
+// VariableError 单个变量的解析错误
+type VariableError struct {
+	Name string
+	Err  error
+}
+
+func (e *VariableError) Error() string {
+	return fmt.Sprintf("variable %s: %v", e.Name, e.Err)
+}
+
+func (e *VariableError) Unwrap() error {
+	return e.Err
+}
+
 type VariableParse struct {
 	Variable *Variable
 	Start    uint // 报文中数据[]byte开始位置
@@ -124,6 +241,21 @@ type ModBusDataFrame struct {
 	DataFrame         []byte
 	ResponseDataFrame []byte
 	Variables         []*VariableParse
+	Counters          *Counters // 报文通讯统计
+}
+
+// ModBusActionFrame 写报文对应的数据点位
+type ModBusActionFrame struct {
+	FunctionCode      uint8
+	MemoryLayout      common.MemoryLayout // 位写入时寄存器的内存布局
+	StartAddress      uint
+	Quantity          uint // 线圈数量或寄存器数量
+	Pdu               []byte
+	ReadModifyWrite   bool   // 位写入需先读取寄存器再写回
+	AndMask           uint16 // 位写入需清除的位取反, 按内存布局解析后的寄存器值
+	OrMask            uint16 // 位写入需置位的值
+	ResponsePduLength int
+	Variables         []*Variable
 }
 
 func (df *ModBusDataFrame) WriteTransactionId() {
@@ -132,10 +264,14 @@ func (df *ModBusDataFrame) WriteTransactionId() {
 	binutils.WriteUint16BigEndian(df.DataFrame, id)
 }
 
-func (df *ModBusDataFrame) ParseVariableValue(data []byte) []collector.VariableValue {
+// ParseVariableValue 解析报文数据中的变量值, 无法解析的变量不更新并返回VariableError
+func (df *ModBusDataFrame) ParseVariableValue(data []byte) ([]collector.VariableValue, []error) {
 	vvs := make([]collector.VariableValue, 0, len(df.Variables))
+	var errs []error
 	for _, vp := range df.Variables {
 		var value interface{}
+		var err error
+		memoryLayout := vp.Variable.GetMemoryLayout(df.MemoryLayout)
 		switch FunctionCode(df.FunctionCode) {
 		case ReadInputStatus, ReadCoilStatus:
 			switch vp.Variable.DataType {
@@ -146,6 +282,14 @@ func (df *ModBusDataFrame) ParseVariableValue(data []byte) []collector.VariableV
 				value = int16(data[vp.Start])
 			case common.UINT16:
 				value = uint16(data[vp.Start])
+			case common.INT8:
+				value = int8(data[vp.Start])
+			case common.UINT8:
+				value = data[vp.Start]
+			case common.UINT32:
+				value = uint32(data[vp.Start])
+			case common.UINT64:
+				value = uint64(data[vp.Start])
 			case common.INT32:
 				value = int32(data[vp.Start])
 			case common.INT64:
@@ -157,122 +301,62 @@ func (df *ModBusDataFrame) ParseVariableValue(data []byte) []collector.VariableV
 			}
 		case ReadInputRegister, ReadHoldRegister:
 			vpData := data[vp.Start:]
+			if vp.Variable.BitAddress {
+				value = vp.Variable.ParseBitValue(ParseRegisterUint16(memoryLayout, vpData))
+				break
+			}
 			switch vp.Variable.DataType {
 			case common.BOOL:
-				var v int16
-				switch df.MemoryLayout {
-				case common.ABCD, common.CDAB:
-					v = int16(binutils.ParseUint16BigEndian(vpData))
-				case common.BADC, common.DCBA:
-					v = int16(binutils.ParseUint16LittleEndian(vpData))
-				}
-				value = v != 0
+				value = ParseRegisterUint16(memoryLayout, vpData) != 0
+			case common.INT8:
+				// 取寄存器低字节
+				value = int8(ParseRegisterUint16(memoryLayout, vpData))
+			case common.UINT8:
+				value = uint8(ParseRegisterUint16(memoryLayout, vpData))
 			case common.INT16:
-				var v interface{}
-				switch df.MemoryLayout {
-				case common.ABCD, common.CDAB:
-					v = int16(binutils.ParseUint16BigEndian(vpData))
-				case common.BADC, common.DCBA:
-					v = int16(binutils.ParseUint16LittleEndian(vpData))
-				}
-				if vp.Variable.Rate != 0 && vp.Variable.Rate != 1 {
-					value = int16((v.(float64)) * vp.Variable.Rate)
-				} else {
-					value = v
-				}
+				value = int16(ParseRegisterUint16(memoryLayout, vpData))
 			case common.UINT16:
-				var v interface{}
-				switch df.MemoryLayout {
-				case common.ABCD, common.CDAB:
-					v = binutils.ParseUint16BigEndian(vpData)
-				case common.BADC, common.DCBA:
-					v = binutils.ParseUint16LittleEndian(vpData)
-				}
-				if vp.Variable.Rate != 0 && vp.Variable.Rate != 1 {
-					value = uint16((v.(float64)) * vp.Variable.Rate)
-				} else {
-					value = v
-				}
+				value = ParseRegisterUint16(memoryLayout, vpData)
 			case common.INT32:
-				var v interface{}
-				switch df.MemoryLayout {
-				case common.ABCD:
-					v = int32(binutils.ParseUint32BigEndian(vpData))
-				case common.BADC:
-					// 大端交换
-					v = int32(binutils.ParseUint32BigEndianByteSwap(vpData))
-				case common.CDAB:
-					v = int32(binutils.ParseUint32LittleEndianByteSwap(vpData))
-				case common.DCBA:
-					v = int32(binutils.ParseUint32LittleEndian(vpData))
-				}
-				if vp.Variable.Rate != 0 && vp.Variable.Rate != 1 {
-					value = int32((v.(float64)) * vp.Variable.Rate)
-				} else {
-					value = v
-				}
+				value = int32(ParseRegisterUint32(memoryLayout, vpData))
+			case common.UINT32:
+				value = ParseRegisterUint32(memoryLayout, vpData)
 			case common.INT64:
-				var v interface{}
-				switch df.MemoryLayout {
-				case common.ABCD:
-					v = int64(binutils.ParseUint64BigEndian(vpData))
-				case common.BADC:
-					v = int64(binutils.ParseUint64BigEndianByteSwap(vpData))
-				case common.CDAB:
-					v = int64(binutils.ParseUint64LittleEndianByteSwap(vpData))
-				case common.DCBA:
-					v = int64(binutils.ParseUint64LittleEndian(vpData))
-				}
-				if vp.Variable.Rate != 0 && vp.Variable.Rate != 1 {
-					value = int64((v.(float64)) * vp.Variable.Rate)
-				} else {
-					value = v
-				}
+				value = int64(ParseRegisterUint64(memoryLayout, vpData))
+			case common.UINT64:
+				value = ParseRegisterUint64(memoryLayout, vpData)
 			case common.FLOAT32:
-				var v interface{}
-				switch df.MemoryLayout {
-				case common.ABCD:
-					v = binutils.ParseFloat32BigEndian(vpData)
-				case common.BADC:
-					v = binutils.ParseFloat32BigEndianByteSwap(vpData)
-				case common.CDAB:
-					v = binutils.ParseFloat32LittleEndianByteSwap(vpData)
-				case common.DCBA:
-					v = binutils.ParseFloat32LittleEndian(vpData)
-				}
-				if vp.Variable.Rate != 0 && vp.Variable.Rate != 1 {
-					value = v.(float32) * float32(vp.Variable.Rate)
-				} else {
-					value = v
-				}
-
-				if value != float32(int(value.(float32))) {
-					value, _ = strconv.ParseFloat(fmt.Sprintf("%.2f", value), 64)
-				}
-
+				value = math.Float32frombits(ParseRegisterUint32(memoryLayout, vpData))
 			case common.FLOAT64:
-				var v interface{}
-				switch df.MemoryLayout {
-				case common.ABCD:
-					v = binutils.ParseFloat64BigEndian(vpData)
-				case common.BADC:
-					v = binutils.ParseFloat64BigEndianByteSwap(vpData)
-				case common.CDAB:
-					v = binutils.ParseFloat64LittleEndianByteSwap(vpData)
-				case common.DCBA:
-					v = binutils.ParseFloat64LittleEndian(vpData)
+				value = math.Float64frombits(ParseRegisterUint64(memoryLayout, vpData))
+			case common.BCD16:
+				if v, ok := DecodeBCD(uint64(ParseRegisterUint16(memoryLayout, vpData))); ok {
+					value = uint16(v)
+				} else {
+					err = ErrBcdInvalid
 				}
-				if vp.Variable.Rate != 0 && vp.Variable.Rate != 1 {
-					value = (v.(float64)) * vp.Variable.Rate
+			case common.BCD32:
+				if v, ok := DecodeBCD(uint64(ParseRegisterUint32(memoryLayout, vpData))); ok {
+					value = uint32(v)
 				} else {
-					value = v
+					err = ErrBcdInvalid
 				}
-
-				if value != float64(int(value.(float64))) {
-					value, _ = strconv.ParseFloat(fmt.Sprintf("%.2f", value), 64)
+			case common.BCD64:
+				if v, ok := DecodeBCD(ParseRegisterUint64(memoryLayout, vpData)); ok {
+					value = v
+				} else {
+					err = ErrBcdInvalid
 				}
+			case common.STRING:
+				value = ParseRegisterString(memoryLayout, vpData[:vp.Variable.Amount*2])
 			}
 		}
+		if err != nil {
+			klog.V(2).InfoS("Failed to parse Modbus variable value", "variableName", vp.Variable.Name, "address", vp.Variable.Address, "error", err)
+			errs = append(errs, &VariableError{Name: vp.Variable.Name, Err: err})
+			continue
+		}
+		value = vp.Variable.ToEngineering(value)
 
 		vp.Variable.SetValue(value)
 		vvs = append(vvs, &Variable{
@@ -285,5 +369,5 @@ func (df *ModBusDataFrame) ParseVariableValue(data []byte) []collector.VariableV
 			Value:        vp.Variable.Value,
 		})
 	}
-	return vvs
+	return vvs, errs
 }
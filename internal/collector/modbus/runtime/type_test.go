@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"errors"
+	"harnsplatform/internal/common"
+	"testing"
+)
+
+func TestBitMask(t *testing.T) {
+	tests := []struct {
+		bits   uint8
+		amount uint
+		mask   uint16
+	}{
+		{0, 0, 0x0001},
+		{3, 1, 0x0008},
+		{2, 4, 0x003C},
+		{8, 8, 0xFF00},
+		{15, 1, 0x8000},
+		{0, 16, 0xFFFF},
+	}
+	for _, tt := range tests {
+		v := &Variable{Bits: tt.bits, Amount: tt.amount}
+		if mask := v.BitMask(); mask != tt.mask {
+			t.Errorf("bits %d amount %d: got %04X, want %04X", tt.bits, tt.amount, mask, tt.mask)
+		}
+	}
+}
+
+func TestParseBitValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataType common.DataType
+		bits     uint8
+		amount   uint
+		register uint16
+		want     interface{}
+	}{
+		{"bool set", common.BOOL, 3, 1, 0x0008, true},
+		{"bool clear", common.BOOL, 3, 1, 0xFFF7, false},
+		{"uint16 range", common.UINT16, 2, 4, 0xFFFF, uint16(15)},
+		{"uint16 ignores other bits", common.UINT16, 2, 4, 0xC01D, uint16(7)},
+		// 位段最高位为1时按位宽做符号扩展
+		{"int16 negative range", common.INT16, 2, 4, 0x003C, int16(-1)},
+		{"int16 positive range", common.INT16, 2, 4, 0x001C, int16(7)},
+		{"int16 single bit", common.INT16, 15, 1, 0x8000, int16(-1)},
+		{"int16 whole register", common.INT16, 0, 16, 0x8000, int16(-32768)},
+		{"int8 high byte", common.INT8, 8, 8, 0x80FF, int8(-128)},
+		{"int8 narrow range", common.INT8, 4, 3, 0x0040, int8(-4)},
+		{"int8 narrow positive", common.INT8, 4, 3, 0x0030, int8(3)},
+		{"uint8 high byte", common.UINT8, 8, 8, 0xAB12, uint8(0xAB)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Variable{DataType: tt.dataType, Bits: tt.bits, Amount: tt.amount, BitAddress: true}
+			if got := v.ParseBitValue(tt.register); got != tt.want {
+				t.Fatalf("got %v(%T), want %v(%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+// BCD码中存在大于9的半字节时不更新该变量并返回错误, 同一报文的其它变量正常解析
+func TestParseVariableValueBcdInvalid(t *testing.T) {
+	corrupt := &Variable{Name: "corrupt", DataType: common.BCD16, Value: uint16(1111)}
+	valid := &Variable{Name: "valid", DataType: common.BCD32}
+	count := &Variable{Name: "count", DataType: common.INT16}
+	df := &ModBusDataFrame{
+		MemoryLayout: common.ABCD,
+		FunctionCode: uint8(ReadHoldRegister),
+		Variables: []*VariableParse{
+			{Variable: corrupt, Start: 0},
+			{Variable: valid, Start: 2},
+			{Variable: count, Start: 6},
+		},
+	}
+	vvs, errs := df.ParseVariableValue([]byte{0x12, 0xA4, 0x00, 0x12, 0x34, 0x56, 0xFF, 0xFE})
+	var ve *VariableError
+	if len(errs) != 1 || !errors.As(errs[0], &ve) || ve.Name != "corrupt" || !errors.Is(errs[0], ErrBcdInvalid) {
+		t.Fatalf("got errors %v", errs)
+	}
+	values := make(map[string]interface{})
+	for _, vv := range vvs {
+		values[vv.GetVariableName()] = vv.GetValue()
+	}
+	if len(values) != 2 || values["valid"] != uint32(123456) || values["count"] != int16(-2) {
+		t.Fatalf("got values %v", values)
+	}
+	if corrupt.Value != uint16(1111) {
+		t.Fatalf("corrupt value updated to %v", corrupt.Value)
+	}
+
+	for _, variable := range []*Variable{
+		{Name: "bcd32", DataType: common.BCD32},
+		{Name: "bcd64", DataType: common.BCD64},
+	} {
+		df.Variables = []*VariableParse{{Variable: variable}}
+		if vvs, errs = df.ParseVariableValue([]byte{0x12, 0xA4, 0, 0, 0, 0, 0, 0}); len(vvs) != 0 || len(errs) != 1 {
+			t.Errorf("%s: got %v, %v", variable.Name, vvs, errs)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package slave
+
+import (
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"time"
+)
+
+// Config 模拟器配置
+type Config struct {
+	Tcp        string         `mapstructure:"tcp,omitempty"`        // modbus tcp监听地址 例如 0.0.0.0:502
+	RtuOverTcp string         `mapstructure:"rtuOverTcp,omitempty"` // modbus rtu over tcp监听地址
+	Rtu        bool           `mapstructure:"rtu,omitempty"`        // 在伪终端上提供modbus rtu
+	Link       string         `mapstructure:"link,omitempty"`       // 伪终端从端的符号链接, 为空时不创建
+	Interval   time.Duration  `mapstructure:"interval,omitempty"`   // 生成器刷新间隔, 默认1s
+	Faults     *FaultConfig   `mapstructure:"faults,omitempty"`
+	Slaves     []*SlaveConfig `mapstructure:"slaves,omitempty"`
+}
+
+type FaultConfig struct {
+	Delay         time.Duration `mapstructure:"delay,omitempty"`
+	Jitter        time.Duration `mapstructure:"jitter,omitempty"`
+	ExceptionRate float64       `mapstructure:"exceptionRate,omitempty"`
+	ExceptionCode uint8         `mapstructure:"exceptionCode,omitempty"`
+	CrcErrorRate  float64       `mapstructure:"crcErrorRate,omitempty"`
+	DropRate      float64       `mapstructure:"dropRate,omitempty"`
+}
+
+type SlaveConfig struct {
+	Id               uint8                 `mapstructure:"id"`
+	Coils            int                   `mapstructure:"coils,omitempty"`            // 线圈数量
+	DiscreteInputs   int                   `mapstructure:"discreteInputs,omitempty"`   // 离散输入数量
+	HoldingRegisters int                   `mapstructure:"holdingRegisters,omitempty"` // 保持寄存器数量
+	InputRegisters   int                   `mapstructure:"inputRegisters,omitempty"`   // 输入寄存器数量
+	Generators       []*GeneratorConfig    `mapstructure:"generators,omitempty"`
+	Identification   *IdentificationConfig `mapstructure:"identification,omitempty"` // 43/14 设备标识
+	Diagnostics      bool                  `mapstructure:"diagnostics,omitempty"`    // 支持08诊断与0B通讯事件计数
+}
+
+type IdentificationConfig struct {
+	VendorName         string `mapstructure:"vendorName"`
+	ProductCode        string `mapstructure:"productCode"`
+	MajorMinorRevision string `mapstructure:"majorMinorRevision"`
+	ProductName        string `mapstructure:"productName,omitempty"`
+	ModelName          string `mapstructure:"modelName,omitempty"`
+}
+
+type GeneratorConfig struct {
+	Table        string        `mapstructure:"table"`                  // coil discreteInput holdingRegister inputRegister
+	Address      int           `mapstructure:"address"`                // 从0开始的地址
+	DataType     string        `mapstructure:"dataType,omitempty"`     // 寄存器的数据类型, 默认uint16
+	MemoryLayout string        `mapstructure:"memoryLayout,omitempty"` // 默认ABCD
+	Kind         string        `mapstructure:"kind"`                   // ramp sine randomWalk
+	Min          float64       `mapstructure:"min,omitempty"`
+	Max          float64       `mapstructure:"max,omitempty"`
+	Step         float64       `mapstructure:"step,omitempty"`
+	Offset       float64       `mapstructure:"offset,omitempty"`
+	Amplitude    float64       `mapstructure:"amplitude,omitempty"`
+	Period       time.Duration `mapstructure:"period,omitempty"`
+}
+
+func (fc *FaultConfig) Faults() *Faults {
+	if fc == nil {
+		return nil
+	}
+	return &Faults{
+		Delay:         fc.Delay,
+		Jitter:        fc.Jitter,
+		ExceptionRate: fc.ExceptionRate,
+		ExceptionCode: runtime.ExceptionCode(fc.ExceptionCode),
+		CrcErrorRate:  fc.CrcErrorRate,
+		DropRate:      fc.DropRate,
+	}
+}
+
+// Slave 按配置创建从站及其生成器
+func (sc *SlaveConfig) Slave() (*Slave, []*Binding, error) {
+	slave := NewSlave(sc.Id, sc.Coils, sc.DiscreteInputs, sc.HoldingRegisters, sc.InputRegisters)
+	slave.Diagnostics = sc.Diagnostics
+	if ic := sc.Identification; ic != nil {
+		slave.Identification = make(map[uint8]string, 5)
+		for id, value := range []string{ic.VendorName, ic.ProductCode, ic.MajorMinorRevision, "", ic.ProductName, ic.ModelName} {
+			if len(value) > 0 {
+				slave.Identification[uint8(id)] = value
+			}
+		}
+	}
+	bindings := make([]*Binding, 0, len(sc.Generators))
+	for _, gc := range sc.Generators {
+		binding, err := gc.Binding()
+		if err != nil {
+			return nil, nil, err
+		}
+		bindings = append(bindings, binding)
+	}
+	return slave, bindings, nil
+}
+
+func (gc *GeneratorConfig) Binding() (*Binding, error) {
+	table, ok := StringToTable[gc.Table]
+	if !ok {
+		return nil, ErrGeneratorInvalid
+	}
+	binding := &Binding{
+		Table:        table,
+		Address:      gc.Address,
+		DataType:     common.UINT16,
+		MemoryLayout: common.ABCD,
+	}
+	if len(gc.DataType) > 0 {
+		dataType, ok := common.StringToDataType[gc.DataType]
+		if !ok {
+			return nil, ErrDataTypeUnsupported
+		}
+		binding.DataType = dataType
+	}
+	if len(gc.MemoryLayout) > 0 {
+		memoryLayout, ok := common.StringToMemoryLayout[gc.MemoryLayout]
+		if !ok {
+			return nil, ErrGeneratorInvalid
+		}
+		binding.MemoryLayout = memoryLayout
+	}
+	switch gc.Kind {
+	case "ramp":
+		binding.Generator = &Ramp{Min: gc.Min, Max: gc.Max, Period: gc.Period}
+	case "sine":
+		binding.Generator = &Sine{Offset: gc.Offset, Amplitude: gc.Amplitude, Period: gc.Period}
+	case "randomWalk":
+		binding.Generator = NewRandomWalk(gc.Min, gc.Max, gc.Step)
+	default:
+		return nil, ErrGeneratorInvalid
+	}
+	return binding, nil
+}
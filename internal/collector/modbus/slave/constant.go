@@ -0,0 +1,10 @@
+package slave
+
+import "errors"
+
+var ErrAddressOutOfRange = errors.New("modbus slave address out of range")
+var ErrDataTypeUnsupported = errors.New("modbus slave data type unsupported")
+var ErrRtuRequestInvalid = errors.New("modbus slave rtu request invalid")
+var ErrGeneratorInvalid = errors.New("modbus slave generator invalid")
+var ErrPtyUnsupported = errors.New("modbus slave pseudo terminal unsupported")
+var ErrValueOutOfRange = errors.New("modbus slave value out of data type range")
@@ -0,0 +1,55 @@
+package slave
+
+import (
+	"harnsplatform/internal/collector/modbus/runtime"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Faults 注入的故障, 概率取值0-1
+type Faults struct {
+	Delay         time.Duration         // 固定响应延迟
+	Jitter        time.Duration         // 在延迟上随机增加[0, Jitter)
+	ExceptionRate float64               // 返回异常响应的概率
+	ExceptionCode runtime.ExceptionCode // 异常码, 默认06从站忙
+	CrcErrorRate  float64               // rtu响应校验码错误的概率
+	DropRate      float64               // 不响应的概率
+	mux           sync.Mutex
+	rand          *rand.Rand
+}
+
+// decision 一次请求命中的故障
+type decision struct {
+	delay     time.Duration
+	drop      bool
+	exception bool
+	crcError  bool
+}
+
+func (f *Faults) decide() *decision {
+	d := &decision{}
+	if f == nil {
+		return d
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	d.delay = f.Delay
+	if f.Jitter > 0 {
+		d.delay += time.Duration(f.rand.Int63n(int64(f.Jitter)))
+	}
+	d.drop = f.rand.Float64() < f.DropRate
+	d.exception = f.rand.Float64() < f.ExceptionRate
+	d.crcError = f.rand.Float64() < f.CrcErrorRate
+	return d
+}
+
+func (f *Faults) exceptionCode() runtime.ExceptionCode {
+	if f == nil || f.ExceptionCode == 0 {
+		return runtime.SlaveDeviceBusy
+	}
+	return f.ExceptionCode
+}
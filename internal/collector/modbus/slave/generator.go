@@ -0,0 +1,111 @@
+package slave
+
+import (
+	"context"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+	"math"
+	"math/rand"
+	"time"
+)
+
+/**
+数值生成器
+按固定间隔计算生成器的值, 按数据类型与内存布局编码后写入从站的表中
+*/
+
+type Generator interface {
+	// Next 返回从启动起经过elapsed时的值
+	Next(elapsed time.Duration) float64
+}
+
+// Ramp 在周期内由Min线性增长到Max后回到Min
+type Ramp struct {
+	Min    float64
+	Max    float64
+	Period time.Duration
+}
+
+func (r *Ramp) Next(elapsed time.Duration) float64 {
+	if r.Period <= 0 {
+		return r.Min
+	}
+	phase := float64(elapsed%r.Period) / float64(r.Period)
+	return r.Min + (r.Max-r.Min)*phase
+}
+
+// Sine Offset + Amplitude × sin(2π × t / Period)
+type Sine struct {
+	Offset    float64
+	Amplitude float64
+	Period    time.Duration
+}
+
+func (s *Sine) Next(elapsed time.Duration) float64 {
+	if s.Period <= 0 {
+		return s.Offset
+	}
+	return s.Offset + s.Amplitude*math.Sin(2*math.Pi*float64(elapsed)/float64(s.Period))
+}
+
+// RandomWalk 每次在[-Step, Step]内随机变化, 限制在[Min, Max]内
+type RandomWalk struct {
+	Min   float64
+	Max   float64
+	Step  float64
+	value float64
+	rand  *rand.Rand
+}
+
+func NewRandomWalk(min, max, step float64) *RandomWalk {
+	return &RandomWalk{
+		Min:   min,
+		Max:   max,
+		Step:  step,
+		value: (min + max) / 2,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (w *RandomWalk) Next(elapsed time.Duration) float64 {
+	w.value += (w.rand.Float64()*2 - 1) * w.Step
+	if w.value < w.Min {
+		w.value = w.Min
+	}
+	if w.value > w.Max {
+		w.value = w.Max
+	}
+	return w.value
+}
+
+// Binding 生成器写入的位置
+type Binding struct {
+	Table        Table
+	Address      int
+	DataType     common.DataType
+	MemoryLayout common.MemoryLayout
+	Generator    Generator
+}
+
+// Run 按interval刷新全部生成器, 直到ctx结束
+func (s *Slave) Run(ctx context.Context, interval time.Duration, bindings []*Binding) {
+	if len(bindings) == 0 {
+		return
+	}
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			elapsed := time.Since(start)
+			for _, binding := range bindings {
+				if err := s.Apply(binding, binding.Generator.Next(elapsed)); err != nil {
+					klog.V(2).InfoS("Failed to apply generator value", "slave", s.Id, "table", TableToString[binding.Table], "address", binding.Address, "error", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package slave
+
+import "os"
+
+type Pty struct {
+	Master *os.File
+	Slave  *os.File
+	Name   string
+}
+
+// OpenPty 仅支持linux
+func OpenPty() (*Pty, error) {
+	return nil, ErrPtyUnsupported
+}
+
+func (p *Pty) Close() error {
+	return nil
+}
@@ -0,0 +1,218 @@
+package slave
+
+import (
+	"bufio"
+	"errors"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/utils"
+	"harnsplatform/internal/utils/binutils"
+	"io"
+	"k8s.io/klog/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+/**
+从站服务
+modbus tcp         MBAP(7) + pdu
+modbus rtu over tcp 与串口rtu相同, 地址(1) + pdu + CRC(2), 按功能码确定请求长度
+下位机号为0的rtu广播请求只执行不响应, 不存在的下位机号rtu不响应, tcp返回0B异常
+*/
+
+type Server struct {
+	Faults *Faults
+	mux    sync.RWMutex
+	slaves map[uint8]*Slave
+}
+
+func NewServer(faults *Faults, slaves ...*Slave) *Server {
+	s := &Server{
+		Faults: faults,
+		slaves: make(map[uint8]*Slave, len(slaves)),
+	}
+	for _, slave := range slaves {
+		s.slaves[slave.Id] = slave
+	}
+	return s
+}
+
+func (s *Server) Slave(id uint8) (*Slave, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	slave, ok := s.slaves[id]
+	return slave, ok
+}
+
+// handle 返回响应pdu及本次故障, 响应pdu为空时不响应
+func (s *Server) handle(unitId uint8, pdu []byte, broadcast bool) ([]byte, *decision) {
+	d := s.Faults.decide()
+	if broadcast && unitId == 0 {
+		s.mux.RLock()
+		for _, slave := range s.slaves {
+			slave.Handle(pdu)
+		}
+		s.mux.RUnlock()
+		return nil, d
+	}
+
+	slave, ok := s.Slave(unitId)
+	if !ok {
+		if broadcast {
+			return nil, d
+		}
+		return Exception(pdu[0], runtime.GatewayTargetFailed), d
+	}
+	if d.drop {
+		klog.V(5).InfoS("Inject fault, drop response", "slave", unitId)
+		return nil, d
+	}
+	if d.exception {
+		klog.V(5).InfoS("Inject fault, exception response", "slave", unitId)
+		return Exception(pdu[0], s.Faults.exceptionCode()), d
+	}
+	return slave.Handle(pdu), d
+}
+
+// ServeTcp 在listener上提供modbus tcp服务
+func (s *Server) ServeTcp(listener net.Listener) error {
+	return s.serve(listener, s.serveTcpConn)
+}
+
+// ServeRtuOverTcp 在listener上提供modbus rtu over tcp服务
+func (s *Server) ServeRtuOverTcp(listener net.Listener) error {
+	return s.serve(listener, func(conn net.Conn) {
+		_ = s.ServeRtu(conn)
+	})
+}
+
+func (s *Server) serve(listener net.Listener, handle func(conn net.Conn)) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		klog.V(4).InfoS("Modbus slave accepted connection", "remote", conn.RemoteAddr())
+		go func() {
+			defer conn.Close()
+			handle(conn)
+		}()
+	}
+}
+
+func (s *Server) serveTcpConn(conn net.Conn) {
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binutils.ParseUint16BigEndian(header[4:]))
+		if length < 2 || length > 254 {
+			klog.V(2).InfoS("Modbus slave received invalid MBAP length", "length", length)
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		response, d := s.handle(header[6], pdu, false)
+		time.Sleep(d.delay)
+		if response == nil {
+			continue
+		}
+		adu := make([]byte, 7, 7+len(response))
+		copy(adu, header[:4])
+		binutils.WriteUint16BigEndian(adu[4:], uint16(len(response)+1))
+		adu[6] = header[6]
+		adu = append(adu, response...)
+		if _, err := conn.Write(adu); err != nil {
+			return
+		}
+	}
+}
+
+// ServeRtu 在字节流上提供modbus rtu服务, 用于伪终端与rtu over tcp
+func (s *Server) ServeRtu(rw io.ReadWriter) error {
+	reader := bufio.NewReaderSize(rw, 512)
+	for {
+		frame, err := readRtuRequest(reader)
+		if errors.Is(err, ErrRtuRequestInvalid) {
+			// 无法确定帧边界, 丢弃已缓存的字节重新同步
+			klog.V(4).InfoS("Modbus slave discard invalid rtu request", "error", err)
+			_, _ = reader.Discard(reader.Buffered())
+			continue
+		} else if err != nil {
+			return err
+		}
+		n := len(frame)
+		if utils.CheckCrc16sum(frame[:n-2]) != binutils.ParseUint16BigEndian(frame[n-2:]) {
+			klog.V(4).InfoS("Modbus slave discard rtu request, crc error")
+			continue
+		}
+
+		response, d := s.handle(frame[0], frame[1:n-2], true)
+		time.Sleep(d.delay)
+		if response == nil {
+			continue
+		}
+		adu := make([]byte, 0, len(response)+3)
+		adu = append(adu, frame[0])
+		adu = append(adu, response...)
+		crc := make([]byte, 2)
+		binutils.WriteUint16BigEndian(crc, utils.CheckCrc16sum(adu))
+		if d.crcError {
+			klog.V(5).InfoS("Inject fault, crc error", "slave", frame[0])
+			crc[0] ^= 0xFF
+		}
+		adu = append(adu, crc...)
+		if _, err := rw.Write(adu); err != nil {
+			return err
+		}
+	}
+}
+
+// readRtuRequest 按功能码读取一个完整的rtu请求
+func readRtuRequest(reader *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, 2)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	// 地址(1) + 功能码(1)之后的固定长度, 以及字节数所在的位置
+	var fixed, byteCountAt int
+	switch runtime.FunctionCode(frame[1]) {
+	case runtime.ReadCoilStatus, runtime.ReadInputStatus, runtime.ReadHoldRegister, runtime.ReadInputRegister,
+		runtime.WriteSingleCoil, runtime.WriteSingleRegister:
+		fixed = 4
+	case runtime.WriteMultipleCoil, runtime.WriteMultipleRegister:
+		fixed, byteCountAt = 5, 5
+	case runtime.MaskWriteRegister:
+		fixed = 6
+	case runtime.ReadWriteMultipleRegister:
+		fixed, byteCountAt = 9, 9
+	case runtime.EncapsulatedInterface:
+		fixed = 3
+	case runtime.Diagnostics:
+		fixed = 4
+	case runtime.GetCommEventCounter:
+	default:
+		return nil, ErrRtuRequestInvalid
+	}
+	body := make([]byte, fixed)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	frame = append(frame, body...)
+	if byteCountAt > 0 {
+		data := make([]byte, body[byteCountAt-1])
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		frame = append(frame, data...)
+	}
+	crc := make([]byte, 2)
+	if _, err := io.ReadFull(reader, crc); err != nil {
+		return nil, err
+	}
+	return append(frame, crc...), nil
+}
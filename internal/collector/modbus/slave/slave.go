@@ -0,0 +1,457 @@
+package slave
+
+import (
+	"errors"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/utils/binutils"
+	"sync"
+)
+
+/**
+modbus 从站模拟
+一个Slave对应一个下位机号, 持有线圈、离散输入、保持寄存器、输入寄存器四张表
+Handle 处理请求pdu并返回响应pdu, 地址越界返回02异常, 数量或取值非法返回03异常
+*/
+
+type Table byte
+
+const (
+	Coil Table = iota
+	DiscreteInput
+	HoldingRegister
+	InputRegister
+)
+
+var TableToString = map[Table]string{
+	Coil:            "coil",
+	DiscreteInput:   "discreteInput",
+	HoldingRegister: "holdingRegister",
+	InputRegister:   "inputRegister",
+}
+
+var StringToTable = map[string]Table{
+	"coil":            Coil,
+	"discreteInput":   DiscreteInput,
+	"holdingRegister": HoldingRegister,
+	"inputRegister":   InputRegister,
+}
+
+type Slave struct {
+	Id uint8
+	// WriteHook 写请求在写入表之前回调, 线圈的值为0或1, 返回错误时不写入并响应异常
+	WriteHook func(table Table, address int, values []uint16) error
+	// Identification 43/14 设备标识对象, 为空时不支持该功能码
+	Identification map[uint8]string
+	// Diagnostics 是否支持08诊断与0B通讯事件计数
+	Diagnostics      bool
+	mux              sync.RWMutex
+	counters         counters
+	coils            []bool
+	discreteInputs   []bool
+	holdingRegisters []uint16
+	inputRegisters   []uint16
+}
+
+func NewSlave(id uint8, coils, discreteInputs, holdingRegisters, inputRegisters int) *Slave {
+	return &Slave{
+		Id:               id,
+		coils:            make([]bool, coils),
+		discreteInputs:   make([]bool, discreteInputs),
+		holdingRegisters: make([]uint16, holdingRegisters),
+		inputRegisters:   make([]uint16, inputRegisters),
+	}
+}
+
+// SetBits 写入线圈或离散输入
+func (s *Slave) SetBits(table Table, address int, values ...bool) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	bits := s.bitTable(table)
+	if bits == nil || address < 0 || address+len(values) > len(bits) {
+		return ErrAddressOutOfRange
+	}
+	copy(bits[address:], values)
+	return nil
+}
+
+// SetRegisters 写入保持寄存器或输入寄存器
+func (s *Slave) SetRegisters(table Table, address int, values ...uint16) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	registers := s.registerTable(table)
+	if registers == nil || address < 0 || address+len(values) > len(registers) {
+		return ErrAddressOutOfRange
+	}
+	copy(registers[address:], values)
+	return nil
+}
+
+// Registers 读取保持寄存器或输入寄存器
+func (s *Slave) Registers(table Table, address, quantity int) ([]uint16, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	registers := s.registerTable(table)
+	if registers == nil || address < 0 || address+quantity > len(registers) {
+		return nil, ErrAddressOutOfRange
+	}
+	values := make([]uint16, quantity)
+	copy(values, registers[address:])
+	return values, nil
+}
+
+func (s *Slave) bitTable(table Table) []bool {
+	switch table {
+	case Coil:
+		return s.coils
+	case DiscreteInput:
+		return s.discreteInputs
+	}
+	return nil
+}
+
+func (s *Slave) registerTable(table Table) []uint16 {
+	switch table {
+	case HoldingRegister:
+		return s.holdingRegisters
+	case InputRegister:
+		return s.inputRegisters
+	}
+	return nil
+}
+
+// counters 08诊断计数与0B通讯事件计数
+type counters struct {
+	mux               sync.Mutex
+	busMessage        uint16
+	busExceptionError uint16
+	slaveMessage      uint16
+	eventCount        uint16
+}
+
+// Handle 处理请求pdu 功能码(1) + 数据, 返回响应pdu
+func (s *Slave) Handle(pdu []byte) []byte {
+	if len(pdu) == 0 {
+		return nil
+	}
+	response := s.handle(pdu)
+	s.counters.mux.Lock()
+	defer s.counters.mux.Unlock()
+	s.counters.busMessage++
+	s.counters.slaveMessage++
+	functionCode := runtime.FunctionCode(pdu[0])
+	if response[0]&0x80 != 0 {
+		s.counters.busExceptionError++
+	} else if functionCode != runtime.Diagnostics && functionCode != runtime.GetCommEventCounter {
+		s.counters.eventCount++
+	}
+	return response
+}
+
+func (s *Slave) handle(pdu []byte) []byte {
+	functionCode := runtime.FunctionCode(pdu[0])
+	switch functionCode {
+	case runtime.ReadCoilStatus:
+		return s.readBits(pdu, s.coils)
+	case runtime.ReadInputStatus:
+		return s.readBits(pdu, s.discreteInputs)
+	case runtime.ReadHoldRegister:
+		return s.readRegisters(pdu, s.holdingRegisters)
+	case runtime.ReadInputRegister:
+		return s.readRegisters(pdu, s.inputRegisters)
+	case runtime.WriteSingleCoil:
+		return s.writeSingleCoil(pdu)
+	case runtime.WriteSingleRegister:
+		return s.writeSingleRegister(pdu)
+	case runtime.WriteMultipleCoil:
+		return s.writeMultipleCoil(pdu)
+	case runtime.WriteMultipleRegister:
+		return s.writeMultipleRegister(pdu)
+	case runtime.MaskWriteRegister:
+		return s.maskWriteRegister(pdu)
+	case runtime.ReadWriteMultipleRegister:
+		return s.readWriteMultipleRegister(pdu)
+	case runtime.EncapsulatedInterface:
+		if len(s.Identification) > 0 {
+			return s.readDeviceIdentification(pdu)
+		}
+	case runtime.Diagnostics:
+		if s.Diagnostics {
+			return s.diagnostics(pdu)
+		}
+	case runtime.GetCommEventCounter:
+		if s.Diagnostics && len(pdu) == 1 {
+			s.counters.mux.Lock()
+			defer s.counters.mux.Unlock()
+			return []byte{pdu[0], 0x00, 0x00, byte(s.counters.eventCount >> 8), byte(s.counters.eventCount)}
+		}
+	}
+	return Exception(pdu[0], runtime.IllegalFunction)
+}
+
+// Exception 异常响应pdu
+func Exception(functionCode uint8, code runtime.ExceptionCode) []byte {
+	return []byte{functionCode | 0x80, byte(code)}
+}
+
+// readDeviceIdentification 43/14 流式读取时一次返回全部对象, 不分段
+// 请求 功能码(1) + MEI类型(1) + 读取类型(1) + 对象编号(1)
+// 响应 功能码(1) + MEI类型(1) + 读取类型(1) + 一致性等级(1) + 后续标志(1) + 下一对象(1) + 对象数量(1) + N×(编号(1) + 长度(1) + 值)
+func (s *Slave) readDeviceIdentification(pdu []byte) []byte {
+	if len(pdu) != 4 || pdu[1] != runtime.ReadDeviceIdentification {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	code, objectId := pdu[2], pdu[3]
+	first, last := uint8(0), uint8(0x02)
+	switch code {
+	case 0x01:
+	case 0x02, 0x03:
+		last = 0x06
+	case 0x04:
+		if _, ok := s.Identification[objectId]; !ok {
+			return Exception(pdu[0], runtime.IllegalDataAddress)
+		}
+		first, last = objectId, objectId
+	default:
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	response := []byte{pdu[0], pdu[1], code, 0x82, 0x00, 0x00, 0x00}
+	for id := first; id <= last; id++ {
+		value, ok := s.Identification[id]
+		if !ok || len(response)+2+len(value) > 253 {
+			continue
+		}
+		response = append(response, id, byte(len(value)))
+		response = append(response, value...)
+		response[6]++
+	}
+	return response
+}
+
+// diagnostics 08 支持回送查询00、清除计数0A以及计数0B-12, 模拟从站没有串口, 通讯错误与溢出计数恒为0
+// 请求与响应 功能码(1) + 子功能码(2) + 数据(2)
+func (s *Slave) diagnostics(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	subFunction := binutils.ParseUint16BigEndian(pdu[1:])
+	if subFunction == 0x00 {
+		return append([]byte{}, pdu...)
+	}
+	if binutils.ParseUint16BigEndian(pdu[3:]) != 0 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	s.counters.mux.Lock()
+	defer s.counters.mux.Unlock()
+	var value uint16
+	switch subFunction {
+	case 0x0A:
+		s.counters.busMessage, s.counters.busExceptionError, s.counters.slaveMessage, s.counters.eventCount = 0, 0, 0, 0
+	case 0x0B:
+		value = s.counters.busMessage
+	case 0x0D:
+		value = s.counters.busExceptionError
+	case 0x0E:
+		value = s.counters.slaveMessage
+	case 0x0C, 0x0F, 0x10, 0x11, 0x12:
+	default:
+		return Exception(pdu[0], runtime.IllegalFunction)
+	}
+	response := append([]byte{}, pdu[:3]...)
+	return append(response, byte(value>>8), byte(value))
+}
+
+func (s *Slave) readBits(pdu []byte, bits []bool) []byte {
+	if len(pdu) != 5 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	start := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	quantity := int(binutils.ParseUint16BigEndian(pdu[3:]))
+	if quantity == 0 || quantity > 2000 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if start+quantity > len(bits) {
+		return Exception(pdu[0], runtime.IllegalDataAddress)
+	}
+	byteCount := (quantity + 7) / 8
+	response := make([]byte, 2+byteCount)
+	response[0] = pdu[0]
+	response[1] = byte(byteCount)
+	for i := 0; i < quantity; i++ {
+		if bits[start+i] {
+			response[2+i/8] |= 1 << (i % 8)
+		}
+	}
+	return response
+}
+
+func (s *Slave) readRegisters(pdu []byte, registers []uint16) []byte {
+	if len(pdu) != 5 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	start := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	quantity := int(binutils.ParseUint16BigEndian(pdu[3:]))
+	if quantity == 0 || quantity > 125 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if start+quantity > len(registers) {
+		return Exception(pdu[0], runtime.IllegalDataAddress)
+	}
+	return registerResponse(pdu[0], registers[start:start+quantity])
+}
+
+func registerResponse(functionCode uint8, registers []uint16) []byte {
+	response := make([]byte, 2+len(registers)*2)
+	response[0] = functionCode
+	response[1] = byte(len(registers) * 2)
+	for i, register := range registers {
+		binutils.WriteUint16BigEndian(response[2+i*2:], register)
+	}
+	return response
+}
+
+// commit 校验地址后回调WriteHook, 成功后写入表
+func (s *Slave) commit(functionCode uint8, table Table, address int, values []uint16) []byte {
+	s.mux.RLock()
+	size := len(s.bitTable(table)) + len(s.registerTable(table))
+	s.mux.RUnlock()
+	if address+len(values) > size {
+		return Exception(functionCode, runtime.IllegalDataAddress)
+	}
+	if s.WriteHook != nil {
+		if err := s.WriteHook(table, address, values); err != nil {
+			var me *runtime.ModbusException
+			if errors.As(err, &me) {
+				return Exception(functionCode, me.ExceptionCode)
+			}
+			return Exception(functionCode, runtime.SlaveDeviceFailure)
+		}
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch table {
+	case Coil, DiscreteInput:
+		bits := s.bitTable(table)
+		for i, value := range values {
+			bits[address+i] = value > 0
+		}
+	default:
+		copy(s.registerTable(table)[address:], values)
+	}
+	return nil
+}
+
+func (s *Slave) writeSingleCoil(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	address := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	value := binutils.ParseUint16BigEndian(pdu[3:])
+	if value != 0xFF00 && value != 0x0000 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	if exception := s.commit(pdu[0], Coil, address, []uint16{value >> 15}); exception != nil {
+		return exception
+	}
+	return binutils.Dup(pdu)
+}
+
+func (s *Slave) writeSingleRegister(pdu []byte) []byte {
+	if len(pdu) != 5 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	address := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	if exception := s.commit(pdu[0], HoldingRegister, address, []uint16{binutils.ParseUint16BigEndian(pdu[3:])}); exception != nil {
+		return exception
+	}
+	return binutils.Dup(pdu)
+}
+
+func (s *Slave) writeMultipleCoil(pdu []byte) []byte {
+	if len(pdu) < 6 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	start := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	quantity := int(binutils.ParseUint16BigEndian(pdu[3:]))
+	byteCount := int(pdu[5])
+	if quantity == 0 || quantity > runtime.PerRequestMaxWriteCoil || byteCount != (quantity+7)/8 || len(pdu) != 6+byteCount {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = uint16(pdu[6+i/8]>>(i%8)) & 1
+	}
+	if exception := s.commit(pdu[0], Coil, start, values); exception != nil {
+		return exception
+	}
+	return binutils.Dup(pdu[:5])
+}
+
+func (s *Slave) writeMultipleRegister(pdu []byte) []byte {
+	if len(pdu) < 6 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	start := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	quantity := int(binutils.ParseUint16BigEndian(pdu[3:]))
+	byteCount := int(pdu[5])
+	if quantity == 0 || quantity > runtime.PerRequestMaxWriteRegister || byteCount != quantity*2 || len(pdu) != 6+byteCount {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	if exception := s.commit(pdu[0], HoldingRegister, start, parseRegisters(pdu[6:], quantity)); exception != nil {
+		return exception
+	}
+	return binutils.Dup(pdu[:5])
+}
+
+func parseRegisters(data []byte, quantity int) []uint16 {
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = binutils.ParseUint16BigEndian(data[i*2:])
+	}
+	return values
+}
+
+// maskWriteRegister 结果 = (当前值 & andMask) | (orMask & ^andMask)
+func (s *Slave) maskWriteRegister(pdu []byte) []byte {
+	if len(pdu) != 7 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	address := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	andMask := binutils.ParseUint16BigEndian(pdu[3:])
+	orMask := binutils.ParseUint16BigEndian(pdu[5:])
+	current, err := s.Registers(HoldingRegister, address, 1)
+	if err != nil {
+		return Exception(pdu[0], runtime.IllegalDataAddress)
+	}
+	if exception := s.commit(pdu[0], HoldingRegister, address, []uint16{(current[0] & andMask) | (orMask &^ andMask)}); exception != nil {
+		return exception
+	}
+	return binutils.Dup(pdu)
+}
+
+// readWriteMultipleRegister 先写后读
+func (s *Slave) readWriteMultipleRegister(pdu []byte) []byte {
+	if len(pdu) < 10 {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	readStart := int(binutils.ParseUint16BigEndian(pdu[1:]))
+	readQuantity := int(binutils.ParseUint16BigEndian(pdu[3:]))
+	writeStart := int(binutils.ParseUint16BigEndian(pdu[5:]))
+	writeQuantity := int(binutils.ParseUint16BigEndian(pdu[7:]))
+	byteCount := int(pdu[9])
+	if readQuantity == 0 || readQuantity > 125 || writeQuantity == 0 || writeQuantity > runtime.PerRequestMaxReadWriteRegister ||
+		byteCount != writeQuantity*2 || len(pdu) != 10+byteCount {
+		return Exception(pdu[0], runtime.IllegalDataValue)
+	}
+	if exception := s.commit(pdu[0], HoldingRegister, writeStart, parseRegisters(pdu[10:], writeQuantity)); exception != nil {
+		return exception
+	}
+	registers, err := s.Registers(HoldingRegister, readStart, readQuantity)
+	if err != nil {
+		return Exception(pdu[0], runtime.IllegalDataAddress)
+	}
+	return registerResponse(pdu[0], registers)
+}
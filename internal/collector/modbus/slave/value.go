@@ -0,0 +1,134 @@
+package slave
+
+import (
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/common"
+	"math"
+)
+
+// Apply 将value按数据类型编码后写入
+func (s *Slave) Apply(binding *Binding, value float64) error {
+	switch binding.Table {
+	case Coil, DiscreteInput:
+		return s.SetBits(binding.Table, binding.Address, value > 0)
+	}
+
+	var data []byte
+	layout := binding.MemoryLayout
+	switch binding.DataType {
+	case common.BOOL:
+		data = runtime.RegisterUint16ToBytes(layout, uint16(boolToUint(value != 0)))
+	case common.INT16:
+		v, err := roundInRange(value, math.MinInt16, math.MaxInt16+1)
+		if err != nil {
+			return err
+		}
+		data = runtime.RegisterUint16ToBytes(layout, uint16(int16(v)))
+	case common.UINT16:
+		v, err := roundInRange(value, 0, math.MaxUint16+1)
+		if err != nil {
+			return err
+		}
+		data = runtime.RegisterUint16ToBytes(layout, uint16(v))
+	case common.INT32:
+		v, err := roundInRange(value, math.MinInt32, math.MaxInt32+1)
+		if err != nil {
+			return err
+		}
+		data = runtime.RegisterUint32ToBytes(layout, uint32(int32(v)))
+	case common.UINT32:
+		v, err := roundInRange(value, 0, math.MaxUint32+1)
+		if err != nil {
+			return err
+		}
+		data = runtime.RegisterUint32ToBytes(layout, uint32(v))
+	case common.INT64:
+		v, err := roundInRange(value, math.MinInt64, math.MaxInt64+1)
+		if err != nil {
+			return err
+		}
+		data = runtime.RegisterUint64ToBytes(layout, uint64(int64(v)))
+	case common.UINT64:
+		v, err := roundInRange(value, 0, math.MaxUint64+1)
+		if err != nil {
+			return err
+		}
+		data = runtime.RegisterUint64ToBytes(layout, uint64(v))
+	case common.FLOAT32:
+		data = runtime.RegisterUint32ToBytes(layout, math.Float32bits(float32(value)))
+	case common.FLOAT64:
+		data = runtime.RegisterUint64ToBytes(layout, math.Float64bits(value))
+	default:
+		return ErrDataTypeUnsupported
+	}
+
+	registers := make([]uint16, len(data)/2)
+	for i := range registers {
+		registers[i] = uint16(data[i*2])<<8 | uint16(data[i*2+1])
+	}
+	return s.SetRegisters(binding.Table, binding.Address, registers...)
+}
+
+// Decode 按数据类型解析寄存器, 为Apply的逆运算, 线圈与离散输入返回bool
+func Decode(binding *Binding, registers []uint16) (interface{}, error) {
+	switch binding.Table {
+	case Coil, DiscreteInput:
+		return registers[0] > 0, nil
+	}
+	words := int(common.DataTypeWord[binding.DataType])
+	if words == 0 || len(registers) < words {
+		return nil, ErrDataTypeUnsupported
+	}
+	data := make([]byte, words*2)
+	for i := 0; i < words; i++ {
+		data[i*2] = byte(registers[i] >> 8)
+		data[i*2+1] = byte(registers[i])
+	}
+	layout := binding.MemoryLayout
+	switch binding.DataType {
+	case common.BOOL:
+		return runtime.ParseRegisterUint16(layout, data) > 0, nil
+	case common.INT16:
+		return float64(int16(runtime.ParseRegisterUint16(layout, data))), nil
+	case common.UINT16:
+		return float64(runtime.ParseRegisterUint16(layout, data)), nil
+	case common.INT32:
+		return float64(int32(runtime.ParseRegisterUint32(layout, data))), nil
+	case common.UINT32:
+		return float64(runtime.ParseRegisterUint32(layout, data)), nil
+	case common.INT64:
+		return float64(int64(runtime.ParseRegisterUint64(layout, data))), nil
+	case common.UINT64:
+		return float64(runtime.ParseRegisterUint64(layout, data)), nil
+	case common.FLOAT32:
+		return float64(math.Float32frombits(runtime.ParseRegisterUint32(layout, data))), nil
+	case common.FLOAT64:
+		return math.Float64frombits(runtime.ParseRegisterUint64(layout, data)), nil
+	}
+	return nil, ErrDataTypeUnsupported
+}
+
+// Words 位置占用的线圈或寄存器数量
+func (b *Binding) Words() int {
+	switch b.Table {
+	case Coil, DiscreteInput:
+		return 1
+	}
+	return int(common.DataTypeWord[b.DataType])
+}
+
+// roundInRange 取整后须在[min, upper)内, 上界取开区间以便精确表示2^63与2^64
+func roundInRange(value float64, min float64, upper float64) (float64, error) {
+	v := math.Round(value)
+	if math.IsNaN(v) || v < min || v >= upper {
+		return 0, ErrValueOutOfRange
+	}
+	return v, nil
+}
+
+func boolToUint(b bool) uint {
+	if b {
+		return 1
+	}
+	return 0
+}
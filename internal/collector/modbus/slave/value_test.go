@@ -0,0 +1,130 @@
+package slave
+
+import (
+	"harnsplatform/internal/common"
+	"math"
+	"testing"
+)
+
+func TestApplyDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    Table
+		dataType common.DataType
+		value    float64
+		want     interface{}
+	}{
+		{"coil", Coil, common.BOOL, 1, true},
+		{"discrete input", DiscreteInput, common.BOOL, 0, false},
+		{"bool register", HoldingRegister, common.BOOL, 1, true},
+		{"int16", HoldingRegister, common.INT16, -2, float64(-2)},
+		{"uint16", InputRegister, common.UINT16, 65535, float64(65535)},
+		{"int32", HoldingRegister, common.INT32, -100000, float64(-100000)},
+		{"uint32", HoldingRegister, common.UINT32, 4000000000, float64(4000000000)},
+		{"int64", HoldingRegister, common.INT64, -1 << 40, float64(-1 << 40)},
+		{"uint64", HoldingRegister, common.UINT64, 1 << 60, float64(1 << 60)},
+		{"float32", HoldingRegister, common.FLOAT32, 1.5, float64(1.5)},
+		{"float64", HoldingRegister, common.FLOAT64, -0.1, float64(-0.1)},
+		// 整数类型按四舍五入写入
+		{"int16 rounded", HoldingRegister, common.INT16, 2.6, float64(3)},
+	}
+	layouts := []common.MemoryLayout{common.ABCD, common.BADC, common.CDAB, common.DCBA}
+	for _, tt := range tests {
+		for _, layout := range layouts {
+			t.Run(tt.name+" "+common.MemoryLayoutToString[layout], func(t *testing.T) {
+				s := NewSlave(1, 4, 4, 8, 8)
+				binding := &Binding{Table: tt.table, Address: 2, DataType: tt.dataType, MemoryLayout: layout}
+				if err := s.Apply(binding, tt.value); err != nil {
+					t.Fatal(err)
+				}
+				var registers []uint16
+				switch tt.table {
+				case Coil, DiscreteInput:
+					if s.bitTable(tt.table)[2] {
+						registers = []uint16{1}
+					} else {
+						registers = []uint16{0}
+					}
+				default:
+					var err error
+					if registers, err = s.Registers(tt.table, binding.Address, binding.Words()); err != nil {
+						t.Fatal(err)
+					}
+				}
+				got, err := Decode(binding, registers)
+				if err != nil || got != tt.want {
+					t.Fatalf("got %v(%T), %v, want %v(%T)", got, got, err, tt.want, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestApplyRegisters(t *testing.T) {
+	tests := []struct {
+		dataType  common.DataType
+		layout    common.MemoryLayout
+		value     float64
+		registers []uint16
+	}{
+		{common.INT16, common.ABCD, -2, []uint16{0xFFFE}},
+		{common.INT32, common.ABCD, -2, []uint16{0xFFFF, 0xFFFE}},
+		{common.UINT32, common.ABCD, 0x12345678, []uint16{0x1234, 0x5678}},
+		{common.FLOAT32, common.ABCD, 1, []uint16{0x3F80, 0x0000}},
+	}
+	for _, tt := range tests {
+		s := NewSlave(1, 0, 0, 4, 0)
+		binding := &Binding{Table: HoldingRegister, DataType: tt.dataType, MemoryLayout: tt.layout}
+		if err := s.Apply(binding, tt.value); err != nil {
+			t.Fatal(err)
+		}
+		registers, _ := s.Registers(HoldingRegister, 0, len(tt.registers))
+		for i := range registers {
+			if registers[i] != tt.registers[i] {
+				t.Fatalf("%s %v: got %04X, want %04X", common.DataTypeToString[tt.dataType], tt.value, registers, tt.registers)
+			}
+		}
+	}
+}
+
+// 超出数据类型范围的值不写入寄存器
+func TestApplyOutOfRange(t *testing.T) {
+	tests := []struct {
+		dataType common.DataType
+		value    float64
+	}{
+		{common.INT16, 32768},
+		{common.INT16, -32769},
+		{common.UINT16, 65536},
+		{common.UINT16, -1},
+		{common.INT32, math.MaxInt32 + 1},
+		{common.UINT32, math.MaxUint32 + 1},
+		{common.UINT32, -0.6},
+		{common.INT64, math.MaxInt64},
+		{common.UINT64, math.MaxUint64},
+		{common.UINT64, -1},
+		{common.INT32, math.NaN()},
+	}
+	for _, tt := range tests {
+		s := NewSlave(1, 0, 0, 4, 0)
+		_ = s.SetRegisters(HoldingRegister, 0, 0x1111, 0x1111, 0x1111, 0x1111)
+		binding := &Binding{Table: HoldingRegister, DataType: tt.dataType, MemoryLayout: common.ABCD}
+		if err := s.Apply(binding, tt.value); err != ErrValueOutOfRange {
+			t.Errorf("%s %v: got %v", common.DataTypeToString[tt.dataType], tt.value, err)
+		}
+		if registers, _ := s.Registers(HoldingRegister, 0, 4); registers[0] != 0x1111 {
+			t.Errorf("%s %v: registers updated to %04X", common.DataTypeToString[tt.dataType], tt.value, registers)
+		}
+	}
+
+	// 边界值可以写入
+	s := NewSlave(1, 0, 0, 4, 0)
+	for _, binding := range []*Binding{
+		{Table: HoldingRegister, DataType: common.INT16},
+		{Table: HoldingRegister, DataType: common.UINT16},
+	} {
+		if err := s.Apply(binding, 32767); err != nil {
+			t.Errorf("%s: got %v", common.DataTypeToString[binding.DataType], err)
+		}
+	}
+}
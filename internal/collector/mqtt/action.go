@@ -0,0 +1,111 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/mqtt/protocol"
+	"harnsplatform/internal/collector/mqtt/runtime"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+	"strings"
+	"time"
+)
+
+/**
+写入
+命令主题包含{name}时, 每个变量向替换变量名后的主题发布, 负载为JSON编码的值 例如 23.5
+否则全部变量合并为一个JSON对象发布到命令主题 例如 {"setpoint":23.5,"enable":true}
+QoS0发布成功即视为下发成功, QoS1与QoS2等待broker确认
+*/
+
+// DeliverAction 返回每个变量的下发结果,存在失败时error为按变量名汇总的collector.MultiError
+func (broker *MQTTBroker) DeliverAction(ctx context.Context, obj map[string]interface{}) ([]*collector.ActionResult, error) {
+	results := make([]*collector.ActionResult, 0, len(obj))
+	values := make(map[string]interface{}, len(obj))
+	actionResults := make([]*collector.ActionResult, 0, len(obj))
+
+	for name, value := range obj {
+		result := &collector.ActionResult{Name: name, Value: value}
+		results = append(results, result)
+
+		vv, exist := broker.Device.GetVariable(name)
+		if !exist {
+			result.Err = runtime.ErrVariableNotFound
+			continue
+		}
+		variable := vv.(*runtime.Variable)
+		if variable.AccessMode != common.AccessModeReadWrite {
+			result.Err = runtime.ErrVariableReadOnly
+			continue
+		}
+		converted, err := runtime.ActionValue(variable.DataType, value)
+		if err != nil {
+			klog.V(3).InfoS("Failed to convert action value", "variableName", variable.Name, "dataType", variable.DataType, "error", err)
+			result.Err = err
+			continue
+		}
+		result.Value = converted
+		values[name] = converted
+		actionResults = append(actionResults, result)
+	}
+	if len(actionResults) == 0 {
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	broker.mu.Lock()
+	client := broker.client
+	broker.mu.Unlock()
+	if client == nil {
+		for _, result := range actionResults {
+			result.Err = runtime.ErrClientClosed
+		}
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	timeout := time.Duration(broker.Device.Timeout) * time.Millisecond
+	publish := func(topic string, value interface{}) error {
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return runtime.ErrActionValueInvalid
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err = client.Publish(ctx, topic, payload, broker.Device.Qos, false); err != nil {
+			klog.V(2).InfoS("Failed to publish MQTT command", "error", err, "deviceId", broker.Device.ID, "topic", topic)
+			return err
+		}
+		return nil
+	}
+
+	if strings.Contains(broker.Device.CommandTopic, runtime.CommandVariablePlaceholder) {
+		for _, result := range actionResults {
+			topic := strings.ReplaceAll(broker.Device.CommandTopic, runtime.CommandVariablePlaceholder, result.Name)
+			result.Err = publish(topic, result.Value)
+		}
+	} else {
+		err := publish(broker.Device.CommandTopic, values)
+		for _, result := range actionResults {
+			result.Err = err
+		}
+	}
+
+	setActionResultStatus(results)
+	return results, collector.NewActionMultiError(results)
+}
+
+func setActionResultStatus(results []*collector.ActionResult) {
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			result.Status = collector.ActionSuccess
+		case errors.Is(result.Err, protocol.ErrRequestTimeout):
+			result.Status = collector.ActionTimeout
+		default:
+			result.Status = collector.ActionFailed
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/mqtt/protocol"
+	"harnsplatform/internal/collector/mqtt/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/jsonpath"
+	"strconv"
+	"strings"
+)
+
+// MappingError 单个点位映射的校验错误
+type MappingError struct {
+	Name  string
+	Topic string
+	Err   error
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("mapping %s(%s): %v", e.Name, e.Topic, e.Err)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertDevice 将持久化的agents及其mappings转换为运行时设备
+func ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (collector.Device, error) {
+	details, err := DecodeAgentDetails(agents.AgentDetails)
+	if err != nil {
+		return nil, err
+	}
+	address, err := DecodeAgentAddress(agents.Address)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := ConvertVariables(mappings)
+	if err != nil {
+		return nil, err
+	}
+	if len(details.CommandTopic) == 0 {
+		for _, variable := range variables {
+			if variable.AccessMode == common.AccessModeReadWrite {
+				return nil, runtime.ErrCommandTopicRequired
+			}
+		}
+	}
+
+	device := &runtime.MQTTDevice{
+		DeviceMeta: collector.DeviceMeta{
+			ObjectMeta: collector.ObjectMeta{
+				Name:    agents.Name,
+				ID:      agents.Id,
+				Version: agents.Version,
+				ModTime: agents.UpdatedTime,
+			},
+			DeviceType:  agents.AgentType,
+			DeviceModel: common.MQTT,
+		},
+		Url:                address.Url,
+		ClientId:           details.ClientId,
+		Username:           details.Username,
+		Password:           details.Password,
+		CleanSession:       true,
+		KeepAlive:          details.KeepAlive,
+		Qos:                details.Qos,
+		Topics:             details.Topics,
+		CommandTopic:       details.CommandTopic,
+		CaFile:             details.CaFile,
+		CertificateFile:    details.CertificateFile,
+		PrivateKeyFile:     details.PrivateKeyFile,
+		InsecureSkipVerify: details.InsecureSkipVerify,
+		Timeout:            details.Timeout,
+		ReconnectInterval:  details.ReconnectInterval,
+		Variables:          variables,
+	}
+	if len(device.ClientId) == 0 {
+		device.ClientId = "harns-" + agents.Id
+	}
+	if details.CleanSession != nil {
+		device.CleanSession = *details.CleanSession
+	}
+	if device.KeepAlive == 0 {
+		device.KeepAlive = runtime.DefaultKeepAlive
+	}
+	if device.Timeout == 0 {
+		device.Timeout = runtime.DefaultTimeout
+	}
+	if device.ReconnectInterval == 0 {
+		device.ReconnectInterval = runtime.DefaultReconnectInterval
+	}
+	// 未配置订阅时订阅点位的主题过滤器
+	if len(device.Topics) == 0 {
+		device.Topics = variableTopics(variables)
+	}
+	return device, nil
+}
+
+func variableTopics(variables []*runtime.Variable) []string {
+	topics := make([]string, 0)
+	exists := make(map[string]struct{})
+	for _, variable := range variables {
+		if _, exist := exists[variable.Topic]; !exist {
+			exists[variable.Topic] = struct{}{}
+			topics = append(topics, variable.Topic)
+		}
+	}
+	return topics
+}
+
+// ConvertVariables 转换全部mappings,返回每个非法mapping的错误
+func ConvertVariables(mappings []*biz.Mapping) ([]*runtime.Variable, error) {
+	variables := make([]*runtime.Variable, 0, len(mappings))
+	errs := make([]error, 0)
+	names := make(map[string]struct{}, len(mappings))
+	for _, mapping := range mappings {
+		topic := mappingTopic(mapping)
+		if _, exist := names[mapping.Name]; exist {
+			errs = append(errs, &MappingError{Name: mapping.Name, Topic: topic, Err: runtime.ErrVariableNameDuplicate})
+			continue
+		}
+		names[mapping.Name] = struct{}{}
+
+		variable, err := ConvertVariable(mapping)
+		if err != nil {
+			errs = append(errs, &MappingError{Name: mapping.Name, Topic: topic, Err: err})
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return variables, nil
+}
+
+// mappingTopic 主题过滤器可能超过variable的长度, 优先使用topic
+func mappingTopic(mapping *biz.Mapping) string {
+	if len(mapping.Topic) > 0 {
+		return mapping.Topic
+	}
+	return mapping.Variable
+}
+
+// ConvertVariable 将单个mapping转换为运行时变量
+func ConvertVariable(mapping *biz.Mapping) (*runtime.Variable, error) {
+	if len(mapping.Name) == 0 {
+		return nil, runtime.ErrVariableNameEmpty
+	}
+	topic := strings.TrimSpace(mappingTopic(mapping))
+	if err := protocol.ValidateFilter(topic); err != nil {
+		return nil, runtime.ErrVariableTopicInvalid
+	}
+	dataType, ok := common.StringToDataType[mapping.DataType]
+	if !ok {
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+	switch dataType {
+	case common.BCD16, common.BCD32, common.BCD64:
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+
+	accessMode := common.AccessModeReadOnly
+	if len(mapping.AccessMode) > 0 {
+		if accessMode, ok = common.StringToReadWriteProperty[mapping.AccessMode]; !ok {
+			return nil, runtime.ErrAccessModeInvalid
+		}
+	}
+
+	variable := &runtime.Variable{
+		DataType:   dataType,
+		Name:       mapping.Name,
+		Topic:      topic,
+		JsonPath:   strings.TrimSpace(mapping.JsonPath),
+		TopicLevel: -1,
+		AccessMode: accessMode,
+	}
+	if strings.HasPrefix(variable.JsonPath, runtime.TopicLevelPrefix) {
+		level, err := parseTopicLevel(variable.JsonPath)
+		if err != nil {
+			return nil, err
+		}
+		variable.TopicLevel = level
+	} else if len(variable.JsonPath) > 0 {
+		path, err := jsonpath.Compile(variable.JsonPath)
+		if err != nil {
+			return nil, runtime.ErrVariableJsonPathInvalid
+		}
+		variable.Path = path
+	}
+	if len(mapping.DefaultValue) > 0 {
+		variable.DefaultValue = mapping.DefaultValue
+	}
+	return variable, nil
+}
+
+// parseTopicLevel $topic[n]
+func parseTopicLevel(s string) (int, error) {
+	if !strings.HasSuffix(s, "]") {
+		return 0, runtime.ErrVariableJsonPathInvalid
+	}
+	level, err := strconv.Atoi(s[len(runtime.TopicLevelPrefix) : len(s)-1])
+	if err != nil || level < 0 {
+		return 0, runtime.ErrVariableJsonPathInvalid
+	}
+	return level, nil
+}
+
+// DecodeAgentDetails agentDetails JSONMap => MQTTAgentDetails
+func DecodeAgentDetails(jm biz.JSONMap) (*biz.MQTTAgentDetails, error) {
+	details := &biz.MQTTAgentDetails{}
+	if err := decodeJSONMap(jm, details); err != nil {
+		return nil, runtime.ErrAgentDetailsInvalid
+	}
+	if details.Qos > 2 {
+		return nil, runtime.ErrQosInvalid
+	}
+	for _, topic := range details.Topics {
+		if err := protocol.ValidateFilter(topic); err != nil {
+			return nil, runtime.ErrTopicsInvalid
+		}
+	}
+	if len(details.CommandTopic) > 0 {
+		// 占位符替换为变量名后不能包含通配符
+		topic := strings.ReplaceAll(details.CommandTopic, runtime.CommandVariablePlaceholder, "name")
+		if err := protocol.ValidateTopic(topic); err != nil {
+			return nil, runtime.ErrCommandTopicInvalid
+		}
+	}
+	if (len(details.CertificateFile) > 0) != (len(details.PrivateKeyFile) > 0) {
+		return nil, runtime.ErrCertificateRequired
+	}
+	return details, nil
+}
+
+// DecodeAgentAddress address JSONMap => MQTTAgentAddress
+func DecodeAgentAddress(jm biz.JSONMap) (*biz.MQTTAgentAddress, error) {
+	address := &biz.MQTTAgentAddress{}
+	if err := decodeJSONMap(jm, address); err != nil {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	address.Url = strings.TrimSpace(address.Url)
+	if _, _, err := protocol.ParseUrl(address.Url); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// decodeJSONMap JSONMap从数据库读出后嵌套对象为map,通过json往返转换为结构体
+func decodeJSONMap(jm biz.JSONMap, v interface{}) error {
+	bytes, err := json.Marshal(jm)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
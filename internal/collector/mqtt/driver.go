@@ -0,0 +1,23 @@
+package mqtt
+
+import (
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+)
+
+// init 注册mqtt协议驱动
+func init() {
+	collector.Register(&collector.DriverBase{
+		AgentsManager: &AgentsManager{},
+		Type:          common.MQTT,
+		Caps: collector.DriverCapabilities{
+			Write: true,
+		},
+		NewAgentsFunc: func() pb.Agents {
+			return &pb.MQTTAgent{}
+		},
+		ConvertDeviceFunc: ConvertDevice,
+		NewBrokerFunc:     NewBroker,
+	})
+}
@@ -0,0 +1,79 @@
+package mqtt
+
+import (
+	"context"
+	"github.com/imdario/mergo"
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/mqtt/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/errors"
+)
+
+type AgentsManager struct {
+}
+
+// SubscribePlan mqtt没有报文规划, 返回订阅的主题过滤器与每个变量的取值方式
+type SubscribePlan struct {
+	Qos          byte                `json:"qos"`
+	Topics       []string            `json:"topics"`
+	CommandTopic string              `json:"commandTopic,omitempty"`
+	Variables    []*runtime.Variable `json:"variables"`
+}
+
+func (m *AgentsManager) ValidateMappings(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) error {
+	if _, err := ConvertDevice(agents, mappings); err != nil {
+		return errors.GenerateMappingsInvalidError(err.Error())
+	}
+	return nil
+}
+
+func (m *AgentsManager) GetFramePlan(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) (interface{}, error) {
+	d, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	device := d.(*runtime.MQTTDevice)
+	return &SubscribePlan{
+		Qos:          device.Qos,
+		Topics:       device.Topics,
+		CommandTopic: device.CommandTopic,
+		Variables:    device.Variables,
+	}, nil
+}
+
+func (m *AgentsManager) CreateAgents(ctx context.Context, agents pb.Agents) (*biz.Agents, error) {
+	mqttAgents, ok := agents.(*pb.MQTTAgent)
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(agents.GetAgentType())
+	}
+	bz := &biz.Agents{
+		Name:             mqttAgents.Name,
+		AgentType:        common.MQTT,
+		Description:      mqttAgents.Description,
+		CollectorCycle:   mqttAgents.CollectorCycle,
+		VariableInterval: mqttAgents.VariableInterval,
+		Broker:           mqttAgents.Broker,
+	}
+
+	adv := map[string]interface{}{}
+	if err := mergo.Map(&adv, mqttAgents.AgentDetails); err != nil {
+		return nil, err
+	}
+	bz.AgentDetails = adv
+
+	av := map[string]interface{}{}
+	if err := mergo.Map(&av, mqttAgents.Address); err != nil {
+		return nil, err
+	}
+	bz.Address = av
+
+	if _, err := DecodeAgentDetails(bz.AgentDetails); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	if _, err := DecodeAgentAddress(bz.Address); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+
+	return bz, nil
+}
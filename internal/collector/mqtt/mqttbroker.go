@@ -0,0 +1,236 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/mqtt/protocol"
+	"harnsplatform/internal/collector/mqtt/runtime"
+	"k8s.io/klog/v2"
+	"os"
+	"sync"
+	"time"
+)
+
+/**
+mqtt 采集
+连接broker后订阅主题过滤器, 消息到达时按点位的主题过滤器匹配, 按JsonPath取值后推送, 不按采集周期轮询
+一条消息中取到的全部变量作为一个结果推送, 路径不存在的变量跳过, 值无法转换的变量返回错误
+连接断开后按重连间隔重新连接并订阅, 写入时向命令主题发布
+*/
+
+var _ collector.Broker = (*MQTTBroker)(nil)
+
+type MQTTBroker struct {
+	Device     *runtime.MQTTDevice
+	Options    *protocol.Options
+	ExitCh     chan struct{}
+	VariableCh chan *collector.ParseVariableResult
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	client *protocol.Client
+}
+
+func NewBroker(d collector.Device) (collector.Broker, chan *collector.ParseVariableResult, error) {
+	device, ok := d.(*runtime.MQTTDevice)
+	if !ok {
+		klog.V(2).InfoS("Unsupported device,type not MQTT")
+		return nil, nil, collector.ErrDeviceType
+	}
+	if len(device.Variables) == 0 {
+		klog.V(2).InfoS("Unnecessary to collect from MQTT device.Because of the variables is empty", "deviceId", device.ID)
+		return nil, nil, collector.ErrDeviceEmptyVariable
+	}
+	broker := &MQTTBroker{
+		Device:     device,
+		ExitCh:     make(chan struct{}, 0),
+		VariableCh: make(chan *collector.ParseVariableResult, 1),
+	}
+	options, err := NewClientOptions(device, broker.onMessage)
+	if err != nil {
+		klog.V(2).InfoS("Failed to load MQTT certificate", "error", err, "deviceId", device.ID)
+		return nil, nil, err
+	}
+	broker.Options = options
+
+	client, err := broker.connect(context.Background())
+	if err != nil {
+		klog.V(2).InfoS("Failed to connect MQTT broker", "error", err, "deviceId", device.ID, "url", device.Url)
+		return nil, nil, collector.ErrConnectDevice
+	}
+	broker.client = client
+	return broker, broker.VariableCh, nil
+}
+
+// NewClientOptions 设备的连接参数, 配置了CA或客户端证书时加载证书文件
+func NewClientOptions(device *runtime.MQTTDevice, onMessage func(msg *protocol.Message)) (*protocol.Options, error) {
+	options := &protocol.Options{
+		Url:          device.Url,
+		ClientId:     device.ClientId,
+		Username:     device.Username,
+		Password:     device.Password,
+		CleanSession: device.CleanSession,
+		KeepAlive:    time.Duration(device.KeepAlive) * time.Second,
+		Timeout:      time.Duration(device.Timeout) * time.Millisecond,
+		OnMessage:    onMessage,
+	}
+	if len(device.CaFile) == 0 && len(device.CertificateFile) == 0 && !device.InsecureSkipVerify {
+		return options, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: device.InsecureSkipVerify}
+	if len(device.CaFile) > 0 {
+		ca, err := os.ReadFile(device.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, runtime.ErrAgentDetailsInvalid
+		}
+	}
+	if len(device.CertificateFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(device.CertificateFile, device.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	options.TLSConfig = config
+	return options, nil
+}
+
+func (broker *MQTTBroker) connect(ctx context.Context) (*protocol.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, broker.Options.Timeout*2)
+	defer cancel()
+	return protocol.Dial(ctx, broker.Options)
+}
+
+func (broker *MQTTBroker) Collect(ctx context.Context) {
+	broker.wg.Add(1)
+	go broker.session(ctx)
+}
+
+func (broker *MQTTBroker) Destroy(ctx context.Context) {
+	close(broker.ExitCh)
+	broker.mu.Lock()
+	client := broker.client
+	broker.client = nil
+	broker.mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+	broker.wg.Wait()
+	close(broker.VariableCh)
+}
+
+func (broker *MQTTBroker) exited() bool {
+	select {
+	case <-broker.ExitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// send 退出后丢弃结果
+func (broker *MQTTBroker) send(pvr *collector.ParseVariableResult) {
+	select {
+	case broker.VariableCh <- pvr:
+	case <-broker.ExitCh:
+	}
+}
+
+// session 订阅后等待连接断开, 断开后按重连间隔重新连接
+func (broker *MQTTBroker) session(ctx context.Context) {
+	defer broker.wg.Done()
+	reconnectInterval := time.Duration(broker.Device.ReconnectInterval) * time.Millisecond
+	broker.mu.Lock()
+	client := broker.client
+	broker.mu.Unlock()
+	for {
+		if client == nil {
+			select {
+			case <-broker.ExitCh:
+				return
+			case <-time.After(reconnectInterval):
+			}
+			var err error
+			if client, err = broker.connect(ctx); err != nil {
+				klog.V(2).InfoS("Failed to reconnect MQTT broker", "error", err, "deviceId", broker.Device.ID)
+				broker.send(&collector.ParseVariableResult{Err: []error{err}})
+				continue
+			}
+			broker.mu.Lock()
+			if broker.exited() {
+				broker.mu.Unlock()
+				client.Close()
+				return
+			}
+			broker.client = client
+			broker.mu.Unlock()
+		}
+
+		err := broker.subscribe(ctx, client)
+		if err == nil {
+			select {
+			case <-broker.ExitCh:
+				return
+			case <-client.Done():
+				err = client.Err()
+			}
+		}
+		if broker.exited() {
+			return
+		}
+		klog.V(2).InfoS("MQTT session lost", "error", err, "deviceId", broker.Device.ID)
+		broker.send(&collector.ParseVariableResult{Err: []error{err}})
+		broker.mu.Lock()
+		broker.client = nil
+		broker.mu.Unlock()
+		client.Close()
+		client = nil
+	}
+}
+
+func (broker *MQTTBroker) subscribe(ctx context.Context, client *protocol.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, broker.Options.Timeout)
+	defer cancel()
+	_, err := client.Subscribe(ctx, broker.Device.Topics, broker.Device.Qos)
+	if err != nil {
+		return err
+	}
+	klog.V(4).InfoS("Subscribed MQTT topics", "deviceId", broker.Device.ID, "topics", broker.Device.Topics)
+	return nil
+}
+
+// onMessage 在连接的读循环中按消息到达顺序执行
+func (broker *MQTTBroker) onMessage(msg *protocol.Message) {
+	rvs := make([]collector.VariableValue, 0)
+	errs := make([]error, 0)
+	for _, v := range broker.Device.Variables {
+		if !v.Match(msg.Topic) {
+			continue
+		}
+		value, found, err := runtime.ExtractValue(v, msg.Topic, msg.Payload)
+		if err != nil {
+			errs = append(errs, &MappingError{Name: v.Name, Topic: msg.Topic, Err: err})
+			continue
+		}
+		if !found {
+			klog.V(5).InfoS("MQTT payload has no value for variable", "variableName", v.Name, "topic", msg.Topic, "jsonPath", v.JsonPath)
+			continue
+		}
+		variable := *v
+		variable.Value = value
+		rvs = append(rvs, &variable)
+	}
+	if len(rvs) == 0 && len(errs) == 0 {
+		return
+	}
+	if len(errs) == 0 {
+		errs = nil
+	}
+	broker.send(&collector.ParseVariableResult{VariableSlice: rvs, Err: errs})
+}
@@ -0,0 +1,163 @@
+package mqtt
+
+import (
+	"errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/mqtt/protocol"
+	"harnsplatform/internal/collector/mqtt/runtime"
+	"reflect"
+	"testing"
+)
+
+func newTestBroker(t *testing.T) *MQTTBroker {
+	t.Helper()
+	device, err := ConvertDevice(&biz.Agents{Address: biz.JSONMap{"url": "tcp://127.0.0.1:1883"}}, []*biz.Mapping{
+		{Name: "temperature", Topic: "sensors/+/data", JsonPath: "$.data.temperature", DataType: "float64"},
+		{Name: "humidity", Topic: "sensors/+/data", JsonPath: "data.humidity", DataType: "int16"},
+		{Name: "last", Topic: "sensors/+/data", JsonPath: "$.readings[-1]", DataType: "float32"},
+		{Name: "total", Topic: "sensors/+/data", JsonPath: "$['counter.total']", DataType: "uint64"},
+		{Name: "device", Topic: "sensors/+/data", JsonPath: "$topic[1]", DataType: "string"},
+		{Name: "state", Topic: "switch/state", DataType: "bool"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.IndexDevice()
+	return &MQTTBroker{
+		Device:     device.(*runtime.MQTTDevice),
+		ExitCh:     make(chan struct{}),
+		VariableCh: make(chan *collector.ParseVariableResult, 1),
+	}
+}
+
+func TestOnMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		values  map[string]interface{}
+		errs    map[string]error
+	}{
+		{
+			name:    "json paths",
+			topic:   "sensors/dev7/data",
+			payload: `{"data":{"temperature":23.5,"humidity":40},"readings":[1,2,3.5],"counter.total":18446744073709551615}`,
+			values: map[string]interface{}{
+				"temperature": 23.5,
+				"humidity":    int16(40),
+				"last":        float32(3.5),
+				"total":       uint64(18446744073709551615),
+				"device":      "dev7",
+			},
+		},
+		{
+			// 路径不存在的变量跳过
+			name:    "missing path",
+			topic:   "sensors/dev8/data",
+			payload: `{"data":{"temperature":20}}`,
+			values:  map[string]interface{}{"temperature": float64(20), "device": "dev8"},
+		},
+		{
+			name:    "value out of range",
+			topic:   "sensors/dev7/data",
+			payload: `{"data":{"humidity":40000}}`,
+			values:  map[string]interface{}{"device": "dev7"},
+			errs:    map[string]error{"humidity": runtime.ErrPayloadValueInvalid},
+		},
+		{
+			name:    "invalid json",
+			topic:   "sensors/dev7/data",
+			payload: `hello`,
+			values:  map[string]interface{}{"device": "dev7"},
+			errs: map[string]error{
+				"temperature": runtime.ErrPayloadInvalid,
+				"humidity":    runtime.ErrPayloadInvalid,
+				"last":        runtime.ErrPayloadInvalid,
+				"total":       runtime.ErrPayloadInvalid,
+			},
+		},
+		{
+			// 未配置JsonPath时使用整个负载
+			name:    "text payload",
+			topic:   "switch/state",
+			payload: "1",
+			values:  map[string]interface{}{"state": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newTestBroker(t)
+			broker.onMessage(&protocol.Message{Topic: tt.topic, Payload: []byte(tt.payload)})
+			var pvr *collector.ParseVariableResult
+			select {
+			case pvr = <-broker.VariableCh:
+			default:
+				t.Fatal("no result")
+			}
+			values := make(map[string]interface{})
+			for _, vv := range pvr.VariableSlice {
+				values[vv.GetVariableName()] = vv.GetValue()
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Fatalf("got values %v, want %v", values, tt.values)
+			}
+			errs := make(map[string]error)
+			for _, err := range pvr.Err {
+				var me *MappingError
+				if !errors.As(err, &me) {
+					t.Fatalf("got error %v", err)
+				}
+				errs[me.Name] = me.Err
+			}
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("got errors %v, want %v", errs, tt.errs)
+			}
+			for name, want := range tt.errs {
+				if errs[name] != want {
+					t.Errorf("%s: got %v, want %v", name, errs[name], want)
+				}
+			}
+		})
+	}
+
+	// 不匹配任何点位的消息不推送
+	broker := newTestBroker(t)
+	broker.onMessage(&protocol.Message{Topic: "other/topic", Payload: []byte("1")})
+	select {
+	case pvr := <-broker.VariableCh:
+		t.Fatalf("got %+v", pvr)
+	default:
+	}
+	// 推送的是变量的副本
+	broker.onMessage(&protocol.Message{Topic: "switch/state", Payload: []byte("true")})
+	<-broker.VariableCh
+	if v, _ := broker.Device.GetVariable("state"); v.GetValue() != nil {
+		t.Fatalf("device variable updated to %v", v.GetValue())
+	}
+}
+
+func TestConvertVariableJsonPath(t *testing.T) {
+	tests := []struct {
+		jsonPath string
+		level    int
+		err      error
+	}{
+		{"$.a.b[0]", -1, nil},
+		{"a['x.y']", -1, nil},
+		{"$topic[2]", 2, nil},
+		{"$topic[-1]", 0, runtime.ErrVariableJsonPathInvalid},
+		{"$topic[x]", 0, runtime.ErrVariableJsonPathInvalid},
+		{"$.a[", 0, runtime.ErrVariableJsonPathInvalid},
+	}
+	for _, tt := range tests {
+		variable, err := ConvertVariable(&biz.Mapping{Name: "v", Topic: "a/b/c", JsonPath: tt.jsonPath, DataType: "string"})
+		if err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.jsonPath, err, tt.err)
+			continue
+		}
+		if err == nil && variable.TopicLevel != tt.level {
+			t.Errorf("%s: got level %d, want %d", tt.jsonPath, variable.TopicLevel, tt.level)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package protocol
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"k8s.io/klog/v2"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/**
+MQTT 3.1.1 客户端
+连接建立后由读循环接收全部报文, PUBLISH通过OnMessage按到达顺序回调, 应答报文按报文标识符交给等待的请求
+QoS1 收到后回复PUBACK, QoS2 首次收到时回调并回复PUBREC, 收到PUBREL后回复PUBCOMP
+保持连接周期内发送PINGREQ, 超时未收到PINGRESP时关闭连接
+连接关闭后Done返回的通道关闭, Err返回关闭原因, 由调用方重新建立连接
+*/
+
+type Options struct {
+	Url          string // tcp://host:1883 mqtt://host:1883 ssl://host:8883 tls://host:8883 mqtts://host:8883
+	ClientId     string
+	Username     string
+	Password     string
+	CleanSession bool
+	KeepAlive    time.Duration
+	Timeout      time.Duration
+	TLSConfig    *tls.Config
+	OnMessage    func(msg *Message)
+}
+
+type Client struct {
+	opts *Options
+	conn net.Conn
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextId  uint16
+	pending map[uint16]chan *Packet
+	// inbound 已回调但尚未收到PUBREL的QoS2报文标识符
+	inbound map[uint16]struct{}
+	pingCh  chan struct{}
+
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+	// loopDone 读循环退出后关闭, 此后不再回调OnMessage
+	loopDone chan struct{}
+}
+
+// ParseUrl 返回连接地址与是否使用TLS
+func ParseUrl(rawUrl string) (string, bool, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || len(u.Hostname()) == 0 {
+		return "", false, ErrUrlInvalid
+	}
+	var useTls bool
+	port := DefaultPort
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTls, port = true, DefaultTlsPort
+	default:
+		return "", false, ErrUrlInvalid
+	}
+	if len(u.Port()) > 0 {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTls, nil
+}
+
+// Dial 建立连接并完成CONNECT
+func Dial(ctx context.Context, opts *Options) (*Client, error) {
+	address, useTls, err := ParseUrl(opts.Url)
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: opts.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if useTls {
+		config := &tls.Config{}
+		if opts.TLSConfig != nil {
+			config = opts.TLSConfig.Clone()
+		}
+		if len(config.ServerName) == 0 {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		tlsConn := tls.Client(conn, config)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c := &Client{
+		opts:     opts,
+		conn:     conn,
+		pending:  make(map[uint16]chan *Packet),
+		inbound:  make(map[uint16]struct{}),
+		pingCh:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		loopDone: make(chan struct{}),
+	}
+	reader := bufio.NewReader(conn)
+	if err = c.connect(reader); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go c.readLoop(reader)
+	if opts.KeepAlive > 0 {
+		go c.keepAlive()
+	}
+	klog.V(4).InfoS("Connected mqtt broker", "url", opts.Url, "clientId", opts.ClientId)
+	return c, nil
+}
+
+func (c *Client) connect(reader *bufio.Reader) error {
+	packet, err := connectPacket(c.opts)
+	if err != nil {
+		return err
+	}
+	_ = c.conn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err = c.conn.Write(packet); err != nil {
+		return err
+	}
+	ack, err := readPacket(reader)
+	if err != nil {
+		return err
+	}
+	if ack.Type != Connack || len(ack.Body) != 2 {
+		return ErrPacketInvalid
+	}
+	if code := ConnackCode(ack.Body[1]); code != ConnackAccepted {
+		return code
+	}
+	return nil
+}
+
+// Done 连接关闭后关闭的通道
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 连接关闭的原因
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close 发送DISCONNECT后关闭连接, 等待正在执行的OnMessage返回, 不能在OnMessage中调用
+func (c *Client) Close() {
+	if packet, err := encodePacket(Disconnect, 0, nil); err == nil {
+		_ = c.write(packet)
+	}
+	c.fail(ErrClientClosed)
+	<-c.loopDone
+}
+
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		_ = c.conn.Close()
+		close(c.done)
+		if !errors.Is(err, ErrClientClosed) {
+			klog.V(3).InfoS("Mqtt connection lost", "url", c.opts.Url, "error", err)
+		}
+	})
+}
+
+func (c *Client) write(packet []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) readLoop(reader *bufio.Reader) {
+	defer close(c.loopDone)
+	for {
+		packet, err := readPacket(reader)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch packet.Type {
+		case Publish:
+			msg, err := parsePublish(packet)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+			c.receive(msg)
+		case Pubrel:
+			if id, ok := packetId(packet); ok {
+				c.mu.Lock()
+				delete(c.inbound, id)
+				c.mu.Unlock()
+				_ = c.write(ackPacket(Pubcomp, id))
+			}
+		case Puback, Pubrec, Pubcomp, Suback, Unsuback:
+			if id, ok := packetId(packet); ok {
+				c.mu.Lock()
+				ch, exist := c.pending[id]
+				c.mu.Unlock()
+				if exist {
+					select {
+					case ch <- packet:
+					default:
+					}
+				}
+			}
+		case Pingresp:
+			select {
+			case c.pingCh <- struct{}{}:
+			default:
+			}
+		default:
+			klog.V(4).InfoS("Ignore unexpected mqtt packet", "type", packet.Type)
+		}
+	}
+}
+
+// receive 按QoS回调并应答
+func (c *Client) receive(msg *Message) {
+	switch msg.Qos {
+	case 0:
+		c.deliver(msg)
+	case 1:
+		c.deliver(msg)
+		_ = c.write(ackPacket(Puback, msg.PacketId))
+	case 2:
+		c.mu.Lock()
+		_, duplicate := c.inbound[msg.PacketId]
+		c.inbound[msg.PacketId] = struct{}{}
+		c.mu.Unlock()
+		if !duplicate {
+			c.deliver(msg)
+		}
+		_ = c.write(ackPacket(Pubrec, msg.PacketId))
+	}
+}
+
+func (c *Client) deliver(msg *Message) {
+	if c.opts.OnMessage != nil {
+		c.opts.OnMessage(msg)
+	}
+}
+
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(c.opts.KeepAlive)
+	defer ticker.Stop()
+	ping, _ := encodePacket(Pingreq, 0, nil)
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		if err := c.write(ping); err != nil {
+			return
+		}
+		timer := time.NewTimer(c.opts.Timeout)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return
+		case <-c.pingCh:
+			timer.Stop()
+		case <-timer.C:
+			c.fail(ErrPingTimeout)
+			return
+		}
+	}
+}
+
+// register 分配报文标识符并登记等待应答
+func (c *Client) register() (uint16, chan *Packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.nextId++
+		if c.nextId == 0 {
+			continue
+		}
+		if _, exist := c.pending[c.nextId]; !exist {
+			break
+		}
+	}
+	ch := make(chan *Packet, 1)
+	c.pending[c.nextId] = ch
+	return c.nextId, ch
+}
+
+func (c *Client) unregister(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// wait 等待指定类型的应答
+func (c *Client) wait(ctx context.Context, ch chan *Packet, packetType PacketType) (*Packet, error) {
+	for {
+		select {
+		case packet := <-ch:
+			if packet.Type == packetType {
+				return packet, nil
+			}
+		case <-ctx.Done():
+			return nil, ErrRequestTimeout
+		case <-c.done:
+			return nil, c.Err()
+		}
+	}
+}
+
+// Subscribe 订阅主题过滤器, 返回每个过滤器被授予的QoS, 任一过滤器被拒绝时返回ErrSubscribeRejected
+func (c *Client) Subscribe(ctx context.Context, filters []string, qos byte) ([]byte, error) {
+	if qos > 2 {
+		return nil, ErrQosInvalid
+	}
+	for _, filter := range filters {
+		if err := ValidateFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	id, ch := c.register()
+	defer c.unregister(id)
+	packet, err := subscribePacket(filters, qos, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.write(packet); err != nil {
+		return nil, err
+	}
+	ack, err := c.wait(ctx, ch, Suback)
+	if err != nil {
+		return nil, err
+	}
+	granted := ack.Body[2:]
+	if len(granted) != len(filters) {
+		return nil, ErrPacketInvalid
+	}
+	for _, code := range granted {
+		if code == SubackFailure {
+			return granted, ErrSubscribeRejected
+		}
+	}
+	return granted, nil
+}
+
+// Publish 发布消息, QoS1等待PUBACK, QoS2完成PUBREC PUBREL PUBCOMP
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 2 {
+		return ErrQosInvalid
+	}
+	if err := ValidateTopic(topic); err != nil {
+		return err
+	}
+	if qos == 0 {
+		packet, err := publishPacket(topic, payload, qos, retain, 0)
+		if err != nil {
+			return err
+		}
+		return c.write(packet)
+	}
+
+	id, ch := c.register()
+	defer c.unregister(id)
+	packet, err := publishPacket(topic, payload, qos, retain, id)
+	if err != nil {
+		return err
+	}
+	if err = c.write(packet); err != nil {
+		return err
+	}
+	if qos == 1 {
+		_, err = c.wait(ctx, ch, Puback)
+		return err
+	}
+	if _, err = c.wait(ctx, ch, Pubrec); err != nil {
+		return err
+	}
+	if err = c.write(ackPacket(Pubrel, id)); err != nil {
+		return err
+	}
+	_, err = c.wait(ctx, ch, Pubcomp)
+	return err
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUrlInvalid = errors.New("mqtt broker url invalid, eg: tcp://host:1883 ssl://host:8883")
+var ErrPacketInvalid = errors.New("mqtt packet invalid")
+var ErrPacketTooLarge = errors.New("mqtt packet too large")
+var ErrTopicInvalid = errors.New("mqtt topic invalid")
+var ErrTopicFilterInvalid = errors.New("mqtt topic filter invalid")
+var ErrQosInvalid = errors.New("mqtt qos must be 0, 1 or 2")
+var ErrSubscribeRejected = errors.New("mqtt subscription rejected by broker")
+var ErrRequestTimeout = errors.New("mqtt request timeout")
+var ErrPingTimeout = errors.New("mqtt ping response timeout")
+var ErrClientClosed = errors.New("mqtt client closed")
+
+// PacketType 控制报文类型, 固定头第一个字节的高4位
+type PacketType byte
+
+const (
+	Connect     PacketType = 1
+	Connack     PacketType = 2
+	Publish     PacketType = 3
+	Puback      PacketType = 4
+	Pubrec      PacketType = 5
+	Pubrel      PacketType = 6
+	Pubcomp     PacketType = 7
+	Subscribe   PacketType = 8
+	Suback      PacketType = 9
+	Unsubscribe PacketType = 10
+	Unsuback    PacketType = 11
+	Pingreq     PacketType = 12
+	Pingresp    PacketType = 13
+	Disconnect  PacketType = 14
+)
+
+const (
+	// ProtocolLevel MQTT 3.1.1
+	ProtocolLevel byte = 4
+	// MaxRemainingLength 剩余长度最多4个字节
+	MaxRemainingLength = 268435455
+	// SubackFailure 订阅失败的返回码
+	SubackFailure byte = 0x80
+
+	DefaultPort    = "1883"
+	DefaultTlsPort = "8883"
+)
+
+// CONNECT 报文的连接标志
+const (
+	flagCleanSession byte = 0x02
+	flagPassword     byte = 0x40
+	flagUsername     byte = 0x80
+)
+
+// ConnackCode CONNACK 返回码
+type ConnackCode byte
+
+const (
+	ConnackAccepted                    ConnackCode = 0x00
+	ConnackUnacceptableProtocolVersion ConnackCode = 0x01
+	ConnackIdentifierRejected          ConnackCode = 0x02
+	ConnackServerUnavailable           ConnackCode = 0x03
+	ConnackBadUsernameOrPassword       ConnackCode = 0x04
+	ConnackNotAuthorized               ConnackCode = 0x05
+)
+
+var ConnackCodeToString = map[ConnackCode]string{
+	ConnackAccepted:                    "accepted",
+	ConnackUnacceptableProtocolVersion: "unacceptable protocol version",
+	ConnackIdentifierRejected:          "identifier rejected",
+	ConnackServerUnavailable:           "server unavailable",
+	ConnackBadUsernameOrPassword:       "bad username or password",
+	ConnackNotAuthorized:               "not authorized",
+}
+
+func (c ConnackCode) Error() string {
+	if s, ok := ConnackCodeToString[c]; ok {
+		return "mqtt connection refused: " + s
+	}
+	return fmt.Sprintf("mqtt connection refused: 0x%02X", byte(c))
+}
@@ -0,0 +1,185 @@
+package protocol
+
+import (
+	"bufio"
+	"harnsplatform/internal/utils/binutils"
+	"io"
+)
+
+/**
+MQTT 3.1.1 控制报文
+固定头: 报文类型(高4位) + 标志(低4位) + 剩余长度(1-4字节, 每字节低7位, 最高位表示后续字节)
+字符串: 长度(2) + UTF-8
+CONNECT   "MQTT" + 协议级别(1) + 连接标志(1) + 保持连接(2) + 客户端标识 [+ 用户名] [+ 密码]
+PUBLISH   标志 DUP(1位) QoS(2位) RETAIN(1位), 主题 [+ 报文标识符(2), QoS>0] + 负载
+SUBSCRIBE 标志固定为0010, 报文标识符(2) + (主题过滤器 + QoS(1)) * n
+PUBACK PUBREC PUBREL PUBCOMP 报文标识符(2), PUBREL标志固定为0010
+*/
+
+type Packet struct {
+	Type  PacketType
+	Flags byte
+	Body  []byte
+}
+
+// Message 收到的PUBLISH
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Qos      byte
+	Retain   bool
+	Dup      bool
+	PacketId uint16
+}
+
+func encodePacket(packetType PacketType, flags byte, body []byte) ([]byte, error) {
+	if len(body) > MaxRemainingLength {
+		return nil, ErrPacketTooLarge
+	}
+	buf := make([]byte, 0, len(body)+5)
+	buf = append(buf, byte(packetType)<<4|flags&0x0F)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(buf, body...), nil
+}
+
+func readPacket(reader *bufio.Reader) (*Packet, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, ErrPacketInvalid
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	return &Packet{Type: PacketType(header >> 4), Flags: header & 0x0F, Body: body}, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, byte(len(s)>>8), byte(len(s)))
+	return append(buf, s...)
+}
+
+func readString(body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, ErrPacketInvalid
+	}
+	length := int(binutils.ParseUint16BigEndian(body))
+	if len(body) < 2+length {
+		return "", nil, ErrPacketInvalid
+	}
+	return string(body[2 : 2+length]), body[2+length:], nil
+}
+
+func connectPacket(opts *Options) ([]byte, error) {
+	var flags byte
+	if opts.CleanSession {
+		flags |= flagCleanSession
+	}
+	if len(opts.Username) > 0 {
+		flags |= flagUsername
+		if len(opts.Password) > 0 {
+			flags |= flagPassword
+		}
+	}
+	keepAlive := uint16(opts.KeepAlive.Seconds())
+	body := appendString(nil, "MQTT")
+	body = append(body, ProtocolLevel, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendString(body, opts.ClientId)
+	if flags&flagUsername != 0 {
+		body = appendString(body, opts.Username)
+	}
+	if flags&flagPassword != 0 {
+		body = appendString(body, opts.Password)
+	}
+	return encodePacket(Connect, 0, body)
+}
+
+func publishPacket(topic string, payload []byte, qos byte, retain bool, packetId uint16) ([]byte, error) {
+	flags := qos << 1
+	if retain {
+		flags |= 0x01
+	}
+	body := appendString(make([]byte, 0, len(topic)+len(payload)+4), topic)
+	if qos > 0 {
+		body = append(body, byte(packetId>>8), byte(packetId))
+	}
+	body = append(body, payload...)
+	return encodePacket(Publish, flags, body)
+}
+
+func parsePublish(packet *Packet) (*Message, error) {
+	msg := &Message{
+		Qos:    packet.Flags >> 1 & 0x03,
+		Retain: packet.Flags&0x01 == 0x01,
+		Dup:    packet.Flags&0x08 == 0x08,
+	}
+	if msg.Qos > 2 {
+		return nil, ErrPacketInvalid
+	}
+	topic, rest, err := readString(packet.Body)
+	if err != nil {
+		return nil, err
+	}
+	msg.Topic = topic
+	if msg.Qos > 0 {
+		if len(rest) < 2 {
+			return nil, ErrPacketInvalid
+		}
+		msg.PacketId = binutils.ParseUint16BigEndian(rest)
+		rest = rest[2:]
+	}
+	msg.Payload = rest
+	return msg, nil
+}
+
+func subscribePacket(filters []string, qos byte, packetId uint16) ([]byte, error) {
+	body := []byte{byte(packetId >> 8), byte(packetId)}
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, qos)
+	}
+	return encodePacket(Subscribe, 0x02, body)
+}
+
+// ackPacket PUBACK PUBREC PUBREL PUBCOMP
+func ackPacket(packetType PacketType, packetId uint16) []byte {
+	var flags byte
+	if packetType == Pubrel {
+		flags = 0x02
+	}
+	return []byte{byte(packetType)<<4 | flags, 0x02, byte(packetId >> 8), byte(packetId)}
+}
+
+// packetId 应答报文的报文标识符
+func packetId(packet *Packet) (uint16, bool) {
+	if len(packet.Body) < 2 {
+		return 0, false
+	}
+	return binutils.ParseUint16BigEndian(packet.Body), true
+}
@@ -0,0 +1,58 @@
+package protocol
+
+import (
+	"strings"
+)
+
+/**
+主题以 / 分隔层级
+主题过滤器 + 匹配单个层级, 必须独占一个层级
+           # 匹配其后的任意层级(包括父层级本身), 必须独占最后一个层级
+以 $ 开头的主题(例如 $SYS)不被以通配符开头的过滤器匹配
+*/
+
+// ValidateTopic 发布使用的主题不能包含通配符
+func ValidateTopic(topic string) error {
+	if len(topic) == 0 || len(topic) > 65535 || strings.ContainsAny(topic, "+#\x00") {
+		return ErrTopicInvalid
+	}
+	return nil
+}
+
+// ValidateFilter 校验主题过滤器
+func ValidateFilter(filter string) error {
+	if len(filter) == 0 || len(filter) > 65535 || strings.ContainsRune(filter, 0) {
+		return ErrTopicFilterInvalid
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.ContainsAny(level, "+#") && len(level) > 1 {
+			return ErrTopicFilterInvalid
+		}
+		if level == "#" && i != len(levels)-1 {
+			return ErrTopicFilterInvalid
+		}
+	}
+	return nil
+}
+
+// MatchTopic 主题是否匹配过滤器, 过滤器已校验
+func MatchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
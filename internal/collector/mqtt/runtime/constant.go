@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"errors"
+)
+
+var ErrVariableNameEmpty = errors.New("mqtt variable name empty")
+var ErrVariableNameDuplicate = errors.New("mqtt variable name duplicate")
+var ErrVariableTopicInvalid = errors.New("mqtt variable topic filter invalid")
+var ErrVariableJsonPathInvalid = errors.New("mqtt variable json path invalid")
+var ErrDataTypeUnsupported = errors.New("mqtt variable data type unsupported")
+var ErrAccessModeInvalid = errors.New("mqtt variable access mode invalid")
+var ErrAgentDetailsInvalid = errors.New("mqtt agent details invalid")
+var ErrAgentAddressInvalid = errors.New("mqtt agent address invalid")
+var ErrTopicsInvalid = errors.New("mqtt subscription topic filter invalid")
+var ErrCommandTopicInvalid = errors.New("mqtt command topic invalid")
+var ErrCommandTopicRequired = errors.New("mqtt command topic required for writable variables")
+var ErrQosInvalid = errors.New("mqtt qos must be 0, 1 or 2")
+var ErrCertificateRequired = errors.New("mqtt certificate file and private key file must be configured together")
+var ErrVariableNotFound = errors.New("mqtt variable not found")
+var ErrVariableReadOnly = errors.New("mqtt variable read only")
+var ErrActionValueInvalid = errors.New("mqtt action value invalid")
+var ErrPayloadInvalid = errors.New("mqtt payload is not valid json")
+var ErrPayloadValueInvalid = errors.New("mqtt payload value can not convert to data type")
+var ErrClientClosed = errors.New("mqtt client not connected")
+
+const (
+	// TopicLevelPrefix JsonPath以$topic[n]取主题的第n个层级(从0开始), 用于通配符匹配的层级, 例如设备编号
+	TopicLevelPrefix = "$topic["
+	// CommandVariablePlaceholder 命令主题中的变量名占位符, 存在时每个变量单独发布
+	CommandVariablePlaceholder = "{name}"
+
+	DefaultKeepAlive         = 60
+	DefaultTimeout           = 5000
+	DefaultReconnectInterval = 5000
+)
@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/mqtt/protocol"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/jsonpath"
+)
+
+var _ collector.Device = (*MQTTDevice)(nil)
+var _ collector.VariableValue = (*Variable)(nil)
+
+type Variable struct {
+	DataType     common.DataType   `json:"dataType"`               // bool、int8、uint8、int16、uint16、int32、uint32、int64、uint64、float32、float64、number、string
+	Name         string            `json:"name"`                   // 变量名称
+	Topic        string            `json:"topic"`                  // 主题过滤器, 支持+与#通配符
+	JsonPath     string            `json:"jsonPath,omitempty"`     // 从负载中取值的路径, 为空时使用整个负载
+	Path         *jsonpath.Path    `json:"-"`                      // 解析后的路径
+	TopicLevel   int               `json:"-"`                      // 取主题层级时的下标, 否则为-1
+	DefaultValue interface{}       `json:"defaultValue,omitempty"` // 默认值
+	Value        interface{}       `json:"value,omitempty"`        // 值
+	AccessMode   common.AccessMode `json:"accessMode"`             // 读写属性
+}
+
+// Match 主题是否匹配变量的主题过滤器
+func (v *Variable) Match(topic string) bool {
+	return protocol.MatchTopic(v.Topic, topic)
+}
+
+func (v *Variable) GetVariableAccessMode() common.AccessMode {
+	return v.AccessMode
+}
+
+func (v *Variable) SetValue(value interface{}) {
+	v.Value = value
+}
+
+func (v *Variable) GetValue() interface{} {
+	return v.Value
+}
+
+func (v *Variable) GetVariableName() string {
+	return v.Name
+}
+
+func (v *Variable) SetVariableName(name string) {
+	v.Name = name
+}
+
+type MQTTDevice struct {
+	collector.DeviceMeta
+	Url                string               `json:"url"`                               // broker地址
+	ClientId           string               `json:"clientId"`                          // 客户端标识
+	Username           string               `json:"username,omitempty"`                // 用户名
+	Password           string               `json:"-"`                                 // 密码
+	CleanSession       bool                 `json:"cleanSession"`                      // 断开后清除会话
+	KeepAlive          uint                 `json:"keepAlive"`                         // 保持连接 秒
+	Qos                byte                 `json:"qos"`                               // 订阅与发布命令的QoS
+	Topics             []string             `json:"topics"`                            // 订阅的主题过滤器
+	CommandTopic       string               `json:"commandTopic,omitempty"`            // 写入时发布的主题
+	CaFile             string               `json:"caFile,omitempty"`                  // 校验broker证书的CA文件
+	CertificateFile    string               `json:"certificateFile,omitempty"`         // 客户端证书文件
+	PrivateKeyFile     string               `json:"privateKeyFile,omitempty"`          // 客户端私钥文件
+	InsecureSkipVerify bool                 `json:"insecureSkipVerify,omitempty"`      // 不校验broker证书
+	Timeout            uint                 `json:"timeout"`                           // 请求超时 毫秒
+	ReconnectInterval  uint                 `json:"reconnectInterval"`                 // 断开后的重连间隔 毫秒
+	Variables          []*Variable          `json:"variables" binding:"required,dive"` // 自定义变量
+	VariablesMap       map[string]*Variable `json:"-"`                                 // 自定义变量Map
+}
+
+func (m *MQTTDevice) IndexDevice() {
+	m.VariablesMap = make(map[string]*Variable)
+	for _, variable := range m.Variables {
+		m.VariablesMap[variable.Name] = variable
+	}
+}
+
+func (m *MQTTDevice) GetVariable(key string) (rv collector.VariableValue, exist bool) {
+	if v, isExist := m.VariablesMap[key]; isExist {
+		rv = v
+		exist = isExist
+	}
+	return
+}
+
+func (m *MQTTDevice) GetVariables() []collector.VariableValue {
+	rvs := make([]collector.VariableValue, 0)
+
+	for _, variable := range m.Variables {
+		rvs = append(rvs, variable)
+	}
+
+	return rvs
+}
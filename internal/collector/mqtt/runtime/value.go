@@ -0,0 +1,252 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"harnsplatform/internal/common"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/**
+值转换
+负载为JSON时按JsonPath取值, 未配置JsonPath时使用整个负载, 非JSON负载按文本处理
+取到的值按点位数据类型转换, 整数保留精度, string类型的对象与数组保留JSON文本
+*/
+
+// DecodePayload 解码JSON负载, 数字保留为json.Number
+func DecodePayload(payload []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, ErrPayloadInvalid
+	}
+	if decoder.More() {
+		return nil, ErrPayloadInvalid
+	}
+	return document, nil
+}
+
+// ExtractValue 从主题与负载中取出变量的值, 路径不存在时返回false
+func ExtractValue(variable *Variable, topic string, payload []byte) (interface{}, bool, error) {
+	if variable.TopicLevel >= 0 {
+		levels := strings.Split(topic, "/")
+		if variable.TopicLevel >= len(levels) {
+			return nil, false, nil
+		}
+		value, err := ConvertValue(variable.DataType, levels[variable.TopicLevel])
+		return value, true, err
+	}
+
+	document, err := DecodePayload(payload)
+	if variable.Path == nil {
+		if err != nil {
+			// 文本负载 例如 23.5 on
+			document = strings.TrimSpace(string(payload))
+		}
+		value, err := ConvertValue(variable.DataType, document)
+		return value, true, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	raw, ok := variable.Path.Get(document)
+	if !ok {
+		return nil, false, nil
+	}
+	value, err := ConvertValue(variable.DataType, raw)
+	return value, true, err
+}
+
+// ConvertValue 按点位数据类型转换JSON值
+func ConvertValue(dataType common.DataType, raw interface{}) (interface{}, error) {
+	if raw == nil {
+		return nil, ErrPayloadValueInvalid
+	}
+	switch dataType {
+	case common.STRING:
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, ErrPayloadValueInvalid
+		}
+		return string(b), nil
+	case common.BOOL:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err == nil {
+				return b, nil
+			}
+		}
+		if f, ok := toFloat64(raw); ok {
+			return f != 0, nil
+		}
+		return nil, ErrPayloadValueInvalid
+	}
+
+	if b, ok := raw.(bool); ok {
+		raw = boolNumber(b)
+	}
+	value, err := convertNumber(dataType, raw)
+	if err == ErrActionValueInvalid {
+		return nil, ErrPayloadValueInvalid
+	}
+	return value, err
+}
+
+// ActionValue 按点位数据类型转换下发值, 转换后的值用于编码命令负载
+func ActionValue(dataType common.DataType, value interface{}) (interface{}, error) {
+	switch dataType {
+	case common.STRING:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		return nil, ErrActionValueInvalid
+	case common.BOOL:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, ErrActionValueInvalid
+			}
+			return b, nil
+		}
+		if f, ok := toFloat64(value); ok {
+			return f != 0, nil
+		}
+		return nil, ErrActionValueInvalid
+	}
+	return convertNumber(dataType, value)
+}
+
+// convertNumber 整数类型要求为整数且在取值范围内, 64位整数优先按文本解析保留精度
+func convertNumber(dataType common.DataType, value interface{}) (interface{}, error) {
+	text := ""
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = strings.TrimSpace(v)
+	}
+	if len(text) > 0 {
+		switch dataType {
+		case common.INT64:
+			if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return i, nil
+			}
+		case common.UINT64:
+			if u, err := strconv.ParseUint(text, 10, 64); err == nil {
+				return u, nil
+			}
+		}
+	}
+
+	f, ok := toFloat64(value)
+	if !ok {
+		return nil, ErrActionValueInvalid
+	}
+	integer := f == math.Trunc(f)
+	inRange := func(min, max float64) bool {
+		return integer && f >= min && f <= max
+	}
+	switch dataType {
+	case common.NUMBER, common.FLOAT64:
+		return f, nil
+	case common.FLOAT32:
+		if math.Abs(f) <= math.MaxFloat32 {
+			return float32(f), nil
+		}
+	case common.INT8:
+		if inRange(math.MinInt8, math.MaxInt8) {
+			return int8(f), nil
+		}
+	case common.UINT8:
+		if inRange(0, math.MaxUint8) {
+			return uint8(f), nil
+		}
+	case common.INT16:
+		if inRange(math.MinInt16, math.MaxInt16) {
+			return int16(f), nil
+		}
+	case common.UINT16:
+		if inRange(0, math.MaxUint16) {
+			return uint16(f), nil
+		}
+	case common.INT32:
+		if inRange(math.MinInt32, math.MaxInt32) {
+			return int32(f), nil
+		}
+	case common.UINT32:
+		if inRange(0, math.MaxUint32) {
+			return uint32(f), nil
+		}
+	case common.INT64:
+		if inRange(math.MinInt64, math.MaxInt64) {
+			return int64(f), nil
+		}
+	case common.UINT64:
+		if inRange(0, math.MaxUint64) {
+			return uint64(f), nil
+		}
+	default:
+		return nil, ErrDataTypeUnsupported
+	}
+	return nil, ErrActionValueInvalid
+}
+
+func boolNumber(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
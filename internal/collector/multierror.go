@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+)
+
+// MultiError 按变量名汇总的错误
+type MultiError struct {
+	Errors map[string]error
+}
+
+func NewMultiError() *MultiError {
+	return &MultiError{Errors: make(map[string]error, 0)}
+}
+
+func (m *MultiError) Add(name string, err error) {
+	m.Errors[name] = err
+}
+
+func (m *MultiError) Len() int {
+	return len(m.Errors)
+}
+
+func (m *MultiError) Error() string {
+	names := make([]string, 0, len(m.Errors))
+	for name := range m.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sb := strings.Builder{}
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(m.Errors[name].Error())
+	}
+	return sb.String()
+}
+
+func (m *MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(m.Errors))
+	for _, err := range m.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// NewActionMultiError 汇总下发失败的变量,全部成功返回nil
+func NewActionMultiError(results []*ActionResult) error {
+	errs := NewMultiError()
+	for _, result := range results {
+		if result.Err != nil {
+			errs.Add(result.Name, result.Err)
+		}
+	}
+	if errs.Len() > 0 {
+		return errs
+	}
+	return nil
+}
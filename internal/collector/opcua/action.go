@@ -0,0 +1,161 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/collector/opcua/ua"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+)
+
+/**
+写入
+全部变量在一个Write请求中下发, 每个节点返回独立的状态码
+变量类型为number时按最近一次采集到的内置类型写入, 尚未采集到时先Read节点的当前值确定类型
+*/
+
+// DeliverAction 返回每个变量的下发结果,存在失败时error为按变量名汇总的collector.MultiError
+func (broker *OpcUaBroker) DeliverAction(ctx context.Context, obj map[string]interface{}) ([]*collector.ActionResult, error) {
+	results := make([]*collector.ActionResult, 0, len(obj))
+	variables := make([]*runtime.Variable, 0, len(obj))
+	actionResults := make([]*collector.ActionResult, 0, len(obj))
+
+	for name, value := range obj {
+		result := &collector.ActionResult{Name: name, Value: value}
+		results = append(results, result)
+
+		vv, exist := broker.Device.GetVariable(name)
+		if !exist {
+			result.Err = runtime.ErrVariableNotFound
+			continue
+		}
+		variable := vv.(*runtime.Variable)
+		if variable.AccessMode != common.AccessModeReadWrite {
+			result.Err = runtime.ErrVariableReadOnly
+			continue
+		}
+		variables = append(variables, variable)
+		actionResults = append(actionResults, result)
+	}
+	if len(variables) == 0 {
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	broker.mu.Lock()
+	client := broker.client
+	broker.mu.Unlock()
+	if client == nil {
+		for _, result := range actionResults {
+			result.Err = runtime.ErrSessionClosed
+		}
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	targets, err := broker.variantTargets(ctx, client, variables)
+	if err != nil {
+		klog.V(2).InfoS("Failed to read OpcUa node data type", "error", err, "deviceId", broker.Device.ID)
+		for _, result := range actionResults {
+			result.Err = err
+		}
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	writes := make([]*ua.WriteValue, 0, len(variables))
+	written := make([]*collector.ActionResult, 0, len(variables))
+	for i, variable := range variables {
+		result := actionResults[i]
+		if targets[i] == nil {
+			result.Err = runtime.ErrVariantTypeUnknown
+			continue
+		}
+		variant, err := runtime.ActionVariant(*targets[i], result.Value)
+		if err != nil {
+			klog.V(3).InfoS("Failed to convert action value", "variableName", variable.Name, "dataType", variable.DataType, "error", err)
+			result.Err = err
+			continue
+		}
+		result.Value = variant.Value
+		writes = append(writes, &ua.WriteValue{
+			NodeId:      variable.Node,
+			AttributeId: ua.AttributeValue,
+			Value:       &ua.DataValue{Value: variant},
+		})
+		written = append(written, result)
+	}
+
+	if len(writes) > 0 {
+		statuses, err := client.Write(ctx, writes)
+		for i, result := range written {
+			switch {
+			case err != nil:
+				result.Err = err
+			case statuses[i].IsBad():
+				result.Err = statuses[i]
+			}
+		}
+		if err != nil {
+			klog.V(2).InfoS("Failed to write OpcUa nodes", "error", err, "deviceId", broker.Device.ID)
+		}
+	}
+
+	setActionResultStatus(results)
+	return results, collector.NewActionMultiError(results)
+}
+
+// variantTargets 每个变量写入的内置类型, 无法确定类型的变量返回nil
+func (broker *OpcUaBroker) variantTargets(ctx context.Context, client *ua.Client, variables []*runtime.Variable) ([]*ua.TypeId, error) {
+	targets := make([]*ua.TypeId, len(variables))
+	unknown := make([]int, 0)
+	broker.mu.Lock()
+	for i, variable := range variables {
+		if t, ok := runtime.DataTypeToVariantType[variable.DataType]; ok {
+			targets[i] = &t
+		} else if t, ok := broker.variantTypes[variable.Name]; ok {
+			targets[i] = &t
+		} else {
+			unknown = append(unknown, i)
+		}
+	}
+	broker.mu.Unlock()
+	if len(unknown) == 0 {
+		return targets, nil
+	}
+
+	nodes := make([]*ua.ReadValueId, 0, len(unknown))
+	for _, i := range unknown {
+		nodes = append(nodes, &ua.ReadValueId{NodeId: variables[i].Node, AttributeId: ua.AttributeValue})
+	}
+	values, err := client.Read(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range unknown {
+		if values[j].Status.IsBad() || values[j].Value == nil || values[j].Value.Type == ua.TypeNull {
+			continue
+		}
+		t := values[j].Value.Type
+		targets[i] = &t
+		broker.mu.Lock()
+		broker.variantTypes[variables[i].Name] = t
+		broker.mu.Unlock()
+	}
+	return targets, nil
+}
+
+func setActionResultStatus(results []*collector.ActionResult) {
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			result.Status = collector.ActionSuccess
+		case errors.Is(result.Err, ua.ErrRequestTimeout), errors.Is(result.Err, ua.StatusBadTimeout):
+			result.Status = collector.ActionTimeout
+		default:
+			result.Status = collector.ActionFailed
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/collector/opcua/ua"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+/**
+地址空间浏览
+1. 从起始节点按层级引用逐层浏览对象与变量, 命名空间0中的子节点(例如Server对象)不展开
+2. 批量读取变量的DataType、AccessLevel与Value属性, 推断点位数据类型与读写属性
+3. 生成可直接导入agent的草稿点位, 点位名称为浏览路径
+*/
+
+const (
+	DefaultBrowseMaxDepth = 5
+	DefaultBrowseMaxNodes = 1000
+	// browseBatch 单次Browse请求的节点数, readBatch 单次Read请求的变量数
+	browseBatch = 100
+	readBatch   = 300
+	// mappingNameLength biz.Mapping.Name的最大长度
+	mappingNameLength = 32
+)
+
+// BrowsedNode 浏览得到的变量节点
+type BrowsedNode struct {
+	NodeId      string      `json:"nodeId"`
+	BrowsePath  string      `json:"browsePath"`
+	DisplayName string      `json:"displayName"`
+	VariantType string      `json:"variantType,omitempty"`
+	DataType    string      `json:"dataType,omitempty"`
+	AccessMode  string      `json:"accessMode,omitempty"`
+	Value       interface{} `json:"value,omitempty"`
+	Error       string      `json:"error,omitempty"` // 无法生成点位的原因
+}
+
+type BrowseResult struct {
+	Endpoint   string         `json:"endpoint"`
+	RootNodeId string         `json:"rootNodeId"`
+	Nodes      []*BrowsedNode `json:"nodes"`
+	Truncated  bool           `json:"truncated"` // 达到节点数量上限, 结果不完整
+	Mappings   []*biz.Mapping `json:"mappings"`
+}
+
+type browseNode struct {
+	node  ua.NodeId
+	path  string
+	depth uint
+}
+
+// Browse 浏览服务器的地址空间并生成草稿点位
+func Browse(ctx context.Context, opts *biz.OpcUaBrowseOptions) (*BrowseResult, error) {
+	details, err := DecodeAgentDetails(opts.AgentDetails)
+	if err != nil {
+		return nil, err
+	}
+	address, err := DecodeAgentAddress(opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	root := ua.NewNumericNodeId(0, ua.ObjectsFolder)
+	if len(opts.RootNodeId) > 0 {
+		if root, err = ua.ParseNodeId(opts.RootNodeId); err != nil {
+			return nil, runtime.ErrBrowseRootInvalid
+		}
+	}
+	maxDepth, maxNodes := opts.MaxDepth, opts.MaxNodes
+	if maxDepth == 0 {
+		maxDepth = DefaultBrowseMaxDepth
+	}
+	if maxNodes == 0 {
+		maxNodes = DefaultBrowseMaxNodes
+	}
+
+	device := &runtime.OpcUaDevice{Endpoint: address.Endpoint}
+	applyAgentDetails(device, details)
+	config, err := NewClientConfig(device)
+	if err != nil {
+		return nil, err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, config.Timeout*2)
+	client, err := ua.Dial(dialCtx, config)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close(ctx)
+
+	result := &BrowseResult{
+		Endpoint:   address.Endpoint,
+		RootNodeId: root.String(),
+		Nodes:      make([]*BrowsedNode, 0),
+		Mappings:   make([]*biz.Mapping, 0),
+	}
+	variables, truncated, err := browseVariables(ctx, client, root, maxDepth, maxNodes)
+	if err != nil {
+		return nil, err
+	}
+	result.Truncated = truncated
+	for start := 0; start < len(variables); start += readBatch {
+		end := start + readBatch
+		if end > len(variables) {
+			end = len(variables)
+		}
+		nodes, err := readVariables(ctx, client, variables[start:end])
+		if err != nil {
+			return nil, err
+		}
+		result.Nodes = append(result.Nodes, nodes...)
+	}
+	result.Mappings = generateMappings(result.Nodes, opts.AgentId)
+	klog.V(3).InfoS("Browsed OpcUa address space", "endpoint", address.Endpoint, "root", result.RootNodeId,
+		"variables", len(result.Nodes), "mappings", len(result.Mappings), "truncated", truncated)
+	return result, nil
+}
+
+// browseVariables 逐层浏览, 返回变量节点
+func browseVariables(ctx context.Context, client *ua.Client, root ua.NodeId, maxDepth, maxNodes uint) ([]*browseNode, bool, error) {
+	visited := map[string]struct{}{root.String(): {}}
+	variables := make([]*browseNode, 0)
+	level := []*browseNode{{node: root}}
+	for depth := uint(1); depth <= maxDepth && len(level) > 0; depth++ {
+		next := make([]*browseNode, 0)
+		for start := 0; start < len(level); start += browseBatch {
+			end := start + browseBatch
+			if end > len(level) {
+				end = len(level)
+			}
+			batch := level[start:end]
+			descriptions := make([]*ua.BrowseDescription, 0, len(batch))
+			for _, parent := range batch {
+				descriptions = append(descriptions, &ua.BrowseDescription{
+					NodeId:          parent.node,
+					BrowseDirection: ua.BrowseDirectionForward,
+					ReferenceTypeId: ua.NewNumericNodeId(0, ua.HierarchicalReferences),
+					IncludeSubtypes: true,
+					NodeClassMask:   uint32(ua.NodeClassObject | ua.NodeClassVariable),
+					ResultMask:      ua.BrowseResultMaskAll,
+				})
+			}
+			results, err := client.Browse(ctx, descriptions)
+			if err != nil {
+				return nil, false, err
+			}
+			for i, result := range results {
+				if result.StatusCode.IsBad() {
+					klog.V(3).InfoS("Failed to browse OpcUa node", "nodeId", batch[i].node.String(), "error", result.StatusCode)
+					continue
+				}
+				for _, ref := range result.References {
+					// 其它服务器上的节点无法访问
+					if ref.NodeId.ServerIndex != 0 || len(ref.NodeId.NamespaceUri) > 0 {
+						continue
+					}
+					id := ref.NodeId.NodeId.String()
+					if _, ok := visited[id]; ok {
+						continue
+					}
+					visited[id] = struct{}{}
+					child := &browseNode{node: ref.NodeId.NodeId, path: joinBrowsePath(batch[i].path, ref.BrowseName.Name), depth: depth}
+					switch ref.NodeClass {
+					case ua.NodeClassVariable:
+						if uint(len(variables)) >= maxNodes {
+							return variables, true, nil
+						}
+						variables = append(variables, child)
+					case ua.NodeClassObject:
+						if child.node.Namespace != 0 {
+							next = append(next, child)
+						}
+					}
+				}
+			}
+		}
+		level = next
+	}
+	return variables, false, nil
+}
+
+func joinBrowsePath(parent, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// readVariables 读取变量的DataType、AccessLevel与Value属性
+func readVariables(ctx context.Context, client *ua.Client, variables []*browseNode) ([]*BrowsedNode, error) {
+	attributes := []ua.AttributeId{ua.AttributeDisplayName, ua.AttributeDataType, ua.AttributeAccessLevel, ua.AttributeValue}
+	nodes := make([]*ua.ReadValueId, 0, len(variables)*len(attributes))
+	for _, variable := range variables {
+		for _, attribute := range attributes {
+			nodes = append(nodes, &ua.ReadValueId{NodeId: variable.node, AttributeId: attribute})
+		}
+	}
+	values, err := client.Read(ctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	browsed := make([]*BrowsedNode, 0, len(variables))
+	for i, variable := range variables {
+		displayName, dataTypeId, accessLevel, value := values[i*4], values[i*4+1], values[i*4+2], values[i*4+3]
+		bn := &BrowsedNode{NodeId: variable.node.String(), BrowsePath: variable.path}
+		if text, ok := variantValue(displayName).(ua.LocalizedText); ok {
+			bn.DisplayName = text.Text
+		}
+		variantType, ok := builtinType(dataTypeId, value)
+		if !ok {
+			bn.Error = "data type is not a builtin type"
+			browsed = append(browsed, bn)
+			continue
+		}
+		bn.VariantType = ua.TypeIdToString[variantType]
+		if value.Value != nil && value.Value.Array {
+			bn.Error = "array value unsupported"
+			browsed = append(browsed, bn)
+			continue
+		}
+		dataType, ok := runtime.VariantTypeToDataType[variantType]
+		if !ok {
+			bn.Error = "data type unsupported"
+			browsed = append(browsed, bn)
+			continue
+		}
+		bn.DataType = common.DataTypeToString[dataType]
+		bn.AccessMode = common.ReadWritePropertyToString[common.AccessModeReadOnly]
+		// 只有与内置类型一一对应的数据类型可以写入
+		level, _ := variantValue(accessLevel).(byte)
+		if level&ua.AccessLevelCurrentWrite != 0 && runtime.DataTypeToVariantType[dataType] == variantType {
+			bn.AccessMode = common.ReadWritePropertyToString[common.AccessModeReadWrite]
+		}
+		if !value.Status.IsBad() {
+			bn.Value = runtime.ParseValue(dataType, value.Value)
+		}
+		browsed = append(browsed, bn)
+	}
+	return browsed, nil
+}
+
+func variantValue(dv *ua.DataValue) interface{} {
+	if dv.Status.IsBad() || dv.Value == nil {
+		return nil
+	}
+	return dv.Value.Value
+}
+
+// builtinType DataType属性为命名空间0中的内置类型时直接使用, 否则按当前值的类型推断
+func builtinType(dataTypeId, value *ua.DataValue) (ua.TypeId, bool) {
+	if id, ok := variantValue(dataTypeId).(ua.NodeId); ok && id.Namespace == 0 && id.Type == ua.IdTypeNumeric &&
+		id.Numeric > uint32(ua.TypeNull) && id.Numeric <= uint32(ua.TypeDiagnosticInfo) {
+		return ua.TypeId(id.Numeric), true
+	}
+	if value.Value != nil && value.Value.Type != ua.TypeNull && !value.Status.IsBad() {
+		return value.Value.Type, true
+	}
+	return ua.TypeNull, false
+}
+
+// generateMappings 点位名称为浏览路径, 超长时保留末尾, 重名时追加序号
+func generateMappings(nodes []*BrowsedNode, agentId string) []*biz.Mapping {
+	mappings := make([]*biz.Mapping, 0, len(nodes))
+	names := make(map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		if len(node.Error) > 0 {
+			continue
+		}
+		name := mappingName(node.BrowsePath, "")
+		for i := 2; ; i++ {
+			if _, exist := names[name]; !exist {
+				break
+			}
+			name = mappingName(node.BrowsePath, fmt.Sprintf("_%d", i))
+		}
+		names[name] = struct{}{}
+		mappings = append(mappings, &biz.Mapping{
+			AgentId:    agentId,
+			DataType:   node.DataType,
+			Name:       name,
+			NodeId:     node.NodeId,
+			Value:      node.Value,
+			AccessMode: node.AccessMode,
+		})
+	}
+	return mappings
+}
+
+func mappingName(path, suffix string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', ' ', '.', ':', ';', '=':
+			return '_'
+		}
+		return r
+	}, path)
+	limit := mappingNameLength - len(suffix)
+	if len(name) > limit {
+		name = name[len(name)-limit:]
+		// 避免截断多字节字符
+		for len(name) > 0 && !isRuneStart(name[0]) {
+			name = name[1:]
+		}
+	}
+	return name + suffix
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
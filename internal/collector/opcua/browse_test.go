@@ -0,0 +1,134 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/collector/opcua/ua"
+	"strings"
+	"testing"
+)
+
+func TestBrowse(t *testing.T) {
+	s, endpoint := startTestServer(t, nil)
+	deep := ua.NewStringNodeId(2, "Line1.Motor")
+	if err := s.AddObject(testLine, deep, "Motor"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddVariable(deep, ua.NewStringNodeId(2, "Line1.Motor.Current"), "Current",
+		&ua.Variant{Type: ua.TypeFloat, Value: float32(1.5)}, ua.AccessLevelCurrentRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddVariable(testLine, ua.NewStringNodeId(2, "Line1.Samples"), "Samples",
+		&ua.Variant{Type: ua.TypeInt16, Array: true, Value: []interface{}{int16(1), int16(2)}}, ua.AccessLevelCurrentRead); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Browse(context.Background(), &biz.OpcUaBrowseOptions{Address: biz.JSONMap{"endpoint": endpoint}, AgentId: "agent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Truncated || result.RootNodeId != "i=85" {
+		t.Fatalf("truncated %v, root %s", result.Truncated, result.RootNodeId)
+	}
+	nodes := make(map[string]*BrowsedNode)
+	for _, node := range result.Nodes {
+		nodes[node.BrowsePath] = node
+	}
+	tests := []struct {
+		path       string
+		dataType   string
+		accessMode string
+		value      interface{}
+		err        bool
+	}{
+		{"Line1/Speed", "float64", "rw", 12.5, false},
+		{"Line1/Running", "bool", "rw", true, false},
+		{"Line1/Count", "int32", "rw", int32(7), false},
+		{"Line1/Name", "string", "r", "line", false},
+		{"Line1/Motor/Current", "float32", "r", float32(1.5), false},
+		{"Line1/Samples", "", "", nil, true},
+	}
+	for _, tt := range tests {
+		node, ok := nodes[tt.path]
+		if !ok {
+			t.Errorf("%s not browsed", tt.path)
+			continue
+		}
+		if node.DataType != tt.dataType || node.AccessMode != tt.accessMode || node.Value != tt.value || (len(node.Error) > 0) != tt.err {
+			t.Errorf("%s: got %+v", tt.path, node)
+		}
+	}
+	if len(result.Nodes) != len(tests) {
+		t.Errorf("got %d nodes, want %d", len(result.Nodes), len(tests))
+	}
+
+	// 生成的点位可直接转换为运行时变量
+	if len(result.Mappings) != len(tests)-1 {
+		t.Fatalf("got %d mappings", len(result.Mappings))
+	}
+	for _, mapping := range result.Mappings {
+		if mapping.AgentId != "agent" || strings.ContainsAny(mapping.Name, "/.") {
+			t.Errorf("mapping %+v", mapping)
+		}
+	}
+	if _, err = ConvertVariables(result.Mappings); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrowseLimits(t *testing.T) {
+	_, endpoint := startTestServer(t, nil)
+	tests := []struct {
+		name      string
+		opts      *biz.OpcUaBrowseOptions
+		nodes     int
+		truncated bool
+		err       error
+	}{
+		{name: "root", opts: &biz.OpcUaBrowseOptions{RootNodeId: testLine.String()}, nodes: 4},
+		{name: "max nodes", opts: &biz.OpcUaBrowseOptions{MaxNodes: 2}, nodes: 2, truncated: true},
+		{name: "max depth", opts: &biz.OpcUaBrowseOptions{MaxDepth: 1}, nodes: 0},
+		{name: "invalid root", opts: &biz.OpcUaBrowseOptions{RootNodeId: "line"}, err: runtime.ErrBrowseRootInvalid},
+		{name: "certificate required", opts: &biz.OpcUaBrowseOptions{AgentDetails: biz.JSONMap{"securityPolicy": "Basic256Sha256"}}, err: runtime.ErrCertificateRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Address = biz.JSONMap{"endpoint": endpoint}
+			result, err := Browse(context.Background(), tt.opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(result.Nodes) != tt.nodes || result.Truncated != tt.truncated {
+				t.Fatalf("got %d nodes, truncated %v", len(result.Nodes), result.Truncated)
+			}
+		})
+	}
+}
+
+func TestBrowseSecure(t *testing.T) {
+	serverCert := newTestCertificate(t, "server")
+	clientCert := newTestCertificate(t, "client")
+	other := newTestCertificate(t, "other")
+	_, endpoint := startTestServer(t, serverCert)
+	details := func(serverCertificate string) biz.JSONMap {
+		return biz.JSONMap{"securityPolicy": "Basic256Sha256", "certificateFile": clientCert.certFile,
+			"privateKeyFile": clientCert.keyFile, "serverCertificate": serverCertificate}
+	}
+
+	result, err := Browse(context.Background(), &biz.OpcUaBrowseOptions{Address: biz.JSONMap{"endpoint": endpoint}, AgentDetails: details(serverCert.certFile)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Nodes) != 4 {
+		t.Fatalf("got %d nodes", len(result.Nodes))
+	}
+	_, err = Browse(context.Background(), &biz.OpcUaBrowseOptions{Address: biz.JSONMap{"endpoint": endpoint}, AgentDetails: details(other.certFile)})
+	if !errors.Is(err, ua.ErrServerCertificateUntrusted) {
+		t.Fatalf("got %v, want %v", err, ua.ErrServerCertificateUntrusted)
+	}
+}
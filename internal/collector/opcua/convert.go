@@ -0,0 +1,260 @@
+package opcua
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/collector/opcua/ua"
+	"harnsplatform/internal/common"
+	"strings"
+)
+
+const (
+	// DefaultTimeout 请求超时 毫秒
+	DefaultTimeout = 5000
+)
+
+// MappingError 单个点位映射的校验错误
+type MappingError struct {
+	Name   string
+	NodeId string
+	Err    error
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("mapping %s(%s): %v", e.Name, e.NodeId, e.Err)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertDevice 将持久化的agents及其mappings转换为运行时设备
+func ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (collector.Device, error) {
+	details, err := DecodeAgentDetails(agents.AgentDetails)
+	if err != nil {
+		return nil, err
+	}
+	address, err := DecodeAgentAddress(agents.Address)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := ConvertVariables(mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	device := &runtime.OpcUaDevice{
+		DeviceMeta: collector.DeviceMeta{
+			ObjectMeta: collector.ObjectMeta{
+				Name:    agents.Name,
+				ID:      agents.Id,
+				Version: agents.Version,
+				ModTime: agents.UpdatedTime,
+			},
+			DeviceType:  agents.AgentType,
+			DeviceModel: common.OPCUA,
+		},
+		CollectorCycle: agents.CollectorCycle,
+		Endpoint:       address.Endpoint,
+		Variables:      variables,
+	}
+	applyAgentDetails(device, details)
+	if device.PublishingInterval == 0 {
+		device.PublishingInterval = device.CollectorCycle
+	}
+	if device.PublishingInterval == 0 {
+		device.PublishingInterval = collector.DefaultCollectorCycle
+	}
+	if device.SamplingInterval == 0 {
+		device.SamplingInterval = device.PublishingInterval
+	}
+	return device, nil
+}
+
+// applyAgentDetails 连接参数, 已通过DecodeAgentDetails校验
+func applyAgentDetails(device *runtime.OpcUaDevice, details *biz.OpcUaAgentDetails) {
+	device.SecurityPolicy = ua.SecurityPolicyNameToUri[securityPolicyName(details)]
+	device.SecurityMode = ua.StringToMessageSecurityMode[securityModeName(details)]
+	device.AuthMode = runtime.StringToAuthMode[authModeName(details)]
+	device.Username = details.Username
+	device.Password = details.Password
+	device.CertificateFile = details.CertificateFile
+	device.PrivateKeyFile = details.PrivateKeyFile
+	device.ServerCertificate = details.ServerCertificate
+	device.ServerThumbprint = details.ServerThumbprint
+	device.CollectMode = runtime.StringToCollectMode[details.CollectMode]
+	device.OverrunPolicy = collector.StringToOverrunPolicy[details.OverrunPolicy]
+	device.PublishingInterval = details.PublishingInterval
+	device.SamplingInterval = details.SamplingInterval
+	device.Timeout = details.Timeout
+	if device.Timeout == 0 {
+		device.Timeout = DefaultTimeout
+	}
+}
+
+func securityPolicyName(details *biz.OpcUaAgentDetails) string {
+	if len(details.SecurityPolicy) == 0 {
+		return "None"
+	}
+	return details.SecurityPolicy
+}
+
+// securityModeName 未配置时None策略为None, 其余策略为SignAndEncrypt
+func securityModeName(details *biz.OpcUaAgentDetails) string {
+	if len(details.SecurityMode) > 0 {
+		return details.SecurityMode
+	}
+	if securityPolicyName(details) == "None" {
+		return "None"
+	}
+	return "SignAndEncrypt"
+}
+
+func authModeName(details *biz.OpcUaAgentDetails) string {
+	if len(details.AuthMode) == 0 {
+		return "anonymous"
+	}
+	return details.AuthMode
+}
+
+// ConvertVariables 转换全部mappings,返回每个非法mapping的错误
+func ConvertVariables(mappings []*biz.Mapping) ([]*runtime.Variable, error) {
+	variables := make([]*runtime.Variable, 0, len(mappings))
+	errs := make([]error, 0)
+	names := make(map[string]struct{}, len(mappings))
+	for _, mapping := range mappings {
+		if _, exist := names[mapping.Name]; exist {
+			errs = append(errs, &MappingError{Name: mapping.Name, NodeId: mappingNodeId(mapping), Err: runtime.ErrVariableNameDuplicate})
+			continue
+		}
+		names[mapping.Name] = struct{}{}
+
+		variable, err := ConvertVariable(mapping)
+		if err != nil {
+			errs = append(errs, &MappingError{Name: mapping.Name, NodeId: mappingNodeId(mapping), Err: err})
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return variables, nil
+}
+
+// mappingNodeId 节点编号较长, 优先使用nodeId, 兼容写在variable中的短编号
+func mappingNodeId(mapping *biz.Mapping) string {
+	if len(mapping.NodeId) > 0 {
+		return strings.TrimSpace(mapping.NodeId)
+	}
+	return strings.TrimSpace(mapping.Variable)
+}
+
+// ConvertVariable 将单个mapping转换为运行时变量
+func ConvertVariable(mapping *biz.Mapping) (*runtime.Variable, error) {
+	if len(mapping.Name) == 0 {
+		return nil, runtime.ErrVariableNameEmpty
+	}
+	nodeId := mappingNodeId(mapping)
+	node, err := ua.ParseNodeId(nodeId)
+	if err != nil || node.IsNull() {
+		return nil, runtime.ErrNodeIdInvalid
+	}
+
+	dataType, ok := common.StringToDataType[mapping.DataType]
+	if !ok {
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+	if _, ok = runtime.DataTypeToVariantType[dataType]; !ok && dataType != common.NUMBER {
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+
+	accessMode := common.AccessModeReadOnly
+	if len(mapping.AccessMode) > 0 {
+		if accessMode, ok = common.StringToReadWriteProperty[mapping.AccessMode]; !ok {
+			return nil, runtime.ErrAccessModeInvalid
+		}
+	}
+
+	variable := &runtime.Variable{
+		DataType:   dataType,
+		Name:       mapping.Name,
+		NodeId:     node.String(),
+		Node:       node,
+		AccessMode: accessMode,
+	}
+	if len(mapping.DefaultValue) > 0 {
+		variable.DefaultValue = mapping.DefaultValue
+	}
+	return variable, nil
+}
+
+// DecodeAgentDetails agentDetails JSONMap => OpcUaAgentDetails
+func DecodeAgentDetails(jm biz.JSONMap) (*biz.OpcUaAgentDetails, error) {
+	details := &biz.OpcUaAgentDetails{}
+	if err := decodeJSONMap(jm, details); err != nil {
+		return nil, runtime.ErrAgentDetailsInvalid
+	}
+	if _, ok := ua.SecurityPolicyNameToUri[securityPolicyName(details)]; !ok {
+		return nil, runtime.ErrSecurityPolicyInvalid
+	}
+	mode, ok := ua.StringToMessageSecurityMode[securityModeName(details)]
+	if !ok {
+		return nil, runtime.ErrSecurityModeInvalid
+	}
+	// None策略只能使用None模式, 其余策略必须签名
+	if (securityPolicyName(details) == "None") != (mode == ua.MessageSecurityModeNone) {
+		return nil, runtime.ErrSecurityModeInvalid
+	}
+	authMode, ok := runtime.StringToAuthMode[authModeName(details)]
+	if !ok {
+		return nil, runtime.ErrAuthModeInvalid
+	}
+	if authMode == ua.UserTokenTypeUserName && len(details.Username) == 0 {
+		return nil, runtime.ErrUsernameRequired
+	}
+	if (mode != ua.MessageSecurityModeNone || authMode == ua.UserTokenTypeCertificate) &&
+		(len(details.CertificateFile) == 0 || len(details.PrivateKeyFile) == 0) {
+		return nil, runtime.ErrCertificateRequired
+	}
+	// 安全通道需校验服务器证书, 否则无法确认对端身份
+	if mode != ua.MessageSecurityModeNone && len(details.ServerCertificate) == 0 && len(details.ServerThumbprint) == 0 {
+		return nil, runtime.ErrServerCertificateRequired
+	}
+	if _, err := ua.ParseThumbprint(details.ServerThumbprint); len(details.ServerThumbprint) > 0 && err != nil {
+		return nil, runtime.ErrServerThumbprintInvalid
+	}
+	if _, ok = runtime.StringToCollectMode[details.CollectMode]; len(details.CollectMode) > 0 && !ok {
+		return nil, runtime.ErrCollectModeInvalid
+	}
+	if _, ok = collector.StringToOverrunPolicy[details.OverrunPolicy]; len(details.OverrunPolicy) > 0 && !ok {
+		return nil, runtime.ErrOverrunPolicyInvalid
+	}
+	return details, nil
+}
+
+// DecodeAgentAddress address JSONMap => OpcUaAgentAddress
+func DecodeAgentAddress(jm biz.JSONMap) (*biz.OpcUaAgentAddress, error) {
+	address := &biz.OpcUaAgentAddress{}
+	if err := decodeJSONMap(jm, address); err != nil {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	address.Endpoint = strings.TrimSpace(address.Endpoint)
+	if _, err := ua.ParseEndpoint(address.Endpoint); err != nil {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	return address, nil
+}
+
+// decodeJSONMap JSONMap从数据库读出后嵌套对象为map,通过json往返转换为结构体
+func decodeJSONMap(jm biz.JSONMap, v interface{}) error {
+	bytes, err := json.Marshal(jm)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
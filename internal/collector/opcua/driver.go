@@ -0,0 +1,24 @@
+package opcua
+
+import (
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+)
+
+// init 注册opcUa协议驱动
+func init() {
+	collector.Register(&collector.DriverBase{
+		AgentsManager: &AgentsManager{},
+		Type:          common.OPCUA,
+		Caps: collector.DriverCapabilities{
+			Write:     true,
+			Discovery: true,
+		},
+		NewAgentsFunc: func() pb.Agents {
+			return &pb.OpcUaAgent{}
+		},
+		ConvertDeviceFunc: ConvertDevice,
+		NewBrokerFunc:     NewBroker,
+	})
+}
@@ -0,0 +1,99 @@
+package opcua
+
+import (
+	"context"
+	"github.com/imdario/mergo"
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/errors"
+)
+
+type AgentsManager struct {
+}
+
+// CollectPlan opcUa没有报文规划, 返回订阅参数与监视的节点
+type CollectPlan struct {
+	CollectMode        string   `json:"collectMode"`
+	PublishingInterval uint     `json:"publishingInterval"`
+	SamplingInterval   uint     `json:"samplingInterval"`
+	Nodes              []string `json:"nodes"`
+}
+
+func (m *AgentsManager) ValidateMappings(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) error {
+	if _, err := ConvertDevice(agents, mappings); err != nil {
+		return errors.GenerateMappingsInvalidError(err.Error())
+	}
+	return nil
+}
+
+func (m *AgentsManager) GetFramePlan(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) (interface{}, error) {
+	d, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	device := d.(*runtime.OpcUaDevice)
+	plan := &CollectPlan{
+		CollectMode:        runtime.CollectModeToString[device.CollectMode],
+		PublishingInterval: device.PublishingInterval,
+		SamplingInterval:   device.SamplingInterval,
+		Nodes:              make([]string, 0, len(device.Variables)),
+	}
+	for _, variable := range device.Variables {
+		plan.Nodes = append(plan.Nodes, variable.NodeId)
+	}
+	return plan, nil
+}
+
+// Browse 浏览地址空间并生成草稿点位, 参数错误与通讯失败分别返回
+func (m *AgentsManager) Browse(ctx context.Context, opts *biz.OpcUaBrowseOptions) (*BrowseResult, error) {
+	result, err := Browse(ctx, opts)
+	switch err {
+	case nil:
+		return result, nil
+	case runtime.ErrAgentDetailsInvalid, runtime.ErrAgentAddressInvalid, runtime.ErrSecurityPolicyInvalid,
+		runtime.ErrSecurityModeInvalid, runtime.ErrAuthModeInvalid, runtime.ErrCollectModeInvalid,
+		runtime.ErrOverrunPolicyInvalid, runtime.ErrCertificateRequired, runtime.ErrUsernameRequired,
+		runtime.ErrServerCertificateRequired, runtime.ErrServerThumbprintInvalid, runtime.ErrBrowseRootInvalid:
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	default:
+		return nil, errors.GenerateDiscoveryFailedError(err.Error())
+	}
+}
+
+func (m *AgentsManager) CreateAgents(ctx context.Context, agents pb.Agents) (*biz.Agents, error) {
+	opcUaAgents, ok := agents.(*pb.OpcUaAgent)
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(agents.GetAgentType())
+	}
+	bz := &biz.Agents{
+		Name:             opcUaAgents.Name,
+		AgentType:        common.OPCUA,
+		Description:      opcUaAgents.Description,
+		CollectorCycle:   opcUaAgents.CollectorCycle,
+		VariableInterval: opcUaAgents.VariableInterval,
+		Broker:           opcUaAgents.Broker,
+	}
+
+	adv := map[string]interface{}{}
+	if err := mergo.Map(&adv, opcUaAgents.AgentDetails); err != nil {
+		return nil, err
+	}
+	bz.AgentDetails = adv
+
+	av := map[string]interface{}{}
+	if err := mergo.Map(&av, opcUaAgents.Address); err != nil {
+		return nil, err
+	}
+	bz.Address = av
+
+	if _, err := DecodeAgentDetails(bz.AgentDetails); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	if _, err := DecodeAgentAddress(bz.Address); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+
+	return bz, nil
+}
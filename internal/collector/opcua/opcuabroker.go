@@ -0,0 +1,373 @@
+package opcua
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/collector/opcua/ua"
+	"k8s.io/klog/v2"
+	"sync"
+	"time"
+)
+
+/**
+opcUa 采集
+subscription 创建一个订阅, 每个变量一个监控项, 服务器按值变化推送, 通过Publish循环接收
+             创建失败的监控项(例如服务器不支持采样该节点)改为按采集周期批量Read
+poll         按采集周期批量Read全部变量
+会话断开或订阅失效后按重连间隔重新建立会话与订阅
+写入使用Write服务, 变量类型为number时按服务器返回值的内置类型写入
+*/
+
+const (
+	// reconnectInterval 会话断开后的重连间隔
+	reconnectInterval = 5 * time.Second
+)
+
+var _ collector.Broker = (*OpcUaBroker)(nil)
+
+type OpcUaBroker struct {
+	Device     *runtime.OpcUaDevice
+	Config     *ua.ClientConfig
+	Scheduler  *collector.Scheduler
+	ExitCh     chan struct{}
+	VariableCh chan *collector.ParseVariableResult
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	client *ua.Client
+	// polled 需要轮询的变量, poll模式为全部变量, subscription模式为监控项创建失败的变量
+	polled []*runtime.Variable
+	// variantTypes 变量名 => 服务器返回值的内置类型
+	variantTypes map[string]ua.TypeId
+}
+
+func NewBroker(d collector.Device) (collector.Broker, chan *collector.ParseVariableResult, error) {
+	device, ok := d.(*runtime.OpcUaDevice)
+	if !ok {
+		klog.V(2).InfoS("Unsupported device,type not OpcUa")
+		return nil, nil, collector.ErrDeviceType
+	}
+	if len(device.Variables) == 0 {
+		klog.V(2).InfoS("Unnecessary to collect from OpcUa device.Because of the variables is empty", "deviceId", device.ID)
+		return nil, nil, collector.ErrDeviceEmptyVariable
+	}
+	config, err := NewClientConfig(device)
+	if err != nil {
+		klog.V(2).InfoS("Failed to load OpcUa certificate", "error", err, "deviceId", device.ID)
+		return nil, nil, err
+	}
+
+	broker := &OpcUaBroker{
+		Device:       device,
+		Config:       config,
+		Scheduler:    collector.NewScheduler(device.CollectorCycle, device.OverrunPolicy),
+		ExitCh:       make(chan struct{}, 0),
+		VariableCh:   make(chan *collector.ParseVariableResult, 1),
+		variantTypes: make(map[string]ua.TypeId),
+	}
+	client, err := broker.connect(context.Background())
+	if err != nil {
+		klog.V(2).InfoS("Failed to connect OpcUa server", "error", err, "deviceId", device.ID, "endpoint", device.Endpoint)
+		return nil, nil, collector.ErrConnectDevice
+	}
+	broker.client = client
+	return broker, broker.VariableCh, nil
+}
+
+// NewClientConfig 设备的连接参数, 配置了证书文件时加载证书与私钥, 以及信任的服务器证书
+func NewClientConfig(device *runtime.OpcUaDevice) (*ua.ClientConfig, error) {
+	config := &ua.ClientConfig{
+		Endpoint:       device.Endpoint,
+		SecurityPolicy: device.SecurityPolicy,
+		SecurityMode:   device.SecurityMode,
+		AuthMode:       device.AuthMode,
+		Username:       device.Username,
+		Password:       device.Password,
+		Timeout:        time.Duration(device.Timeout) * time.Millisecond,
+	}
+	if len(device.CertificateFile) > 0 && len(device.PrivateKeyFile) > 0 {
+		cert, key, err := ua.LoadCertificate(device.CertificateFile, device.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificate, config.PrivateKey = cert, key
+	}
+	if len(device.ServerCertificate) > 0 {
+		certs, err := ua.LoadTrustedCertificates(device.ServerCertificate)
+		if err != nil {
+			return nil, err
+		}
+		config.TrustedCertificates = certs
+	}
+	if len(device.ServerThumbprint) > 0 {
+		thumbprint, err := ua.ParseThumbprint(device.ServerThumbprint)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerThumbprint = thumbprint
+	}
+	return config, nil
+}
+
+func (broker *OpcUaBroker) connect(ctx context.Context) (*ua.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, broker.Config.Timeout*2)
+	defer cancel()
+	return ua.Dial(ctx, broker.Config)
+}
+
+func (broker *OpcUaBroker) Collect(ctx context.Context) {
+	broker.wg.Add(2)
+	go broker.session(ctx)
+	go func() {
+		defer broker.wg.Done()
+		broker.Scheduler.Run(ctx, broker.ExitCh, func() bool {
+			return broker.poll(ctx)
+		})
+	}()
+}
+
+func (broker *OpcUaBroker) Destroy(ctx context.Context) {
+	close(broker.ExitCh)
+	broker.mu.Lock()
+	client := broker.client
+	broker.client = nil
+	broker.mu.Unlock()
+	if client != nil {
+		ctx, cancel := context.WithTimeout(ctx, broker.Config.Timeout)
+		_ = client.Close(ctx)
+		cancel()
+	}
+	broker.wg.Wait()
+	close(broker.VariableCh)
+}
+
+func (broker *OpcUaBroker) exited() bool {
+	select {
+	case <-broker.ExitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// send 退出后丢弃结果
+func (broker *OpcUaBroker) send(pvr *collector.ParseVariableResult) {
+	select {
+	case broker.VariableCh <- pvr:
+	case <-broker.ExitCh:
+	}
+}
+
+// session 维持会话, 订阅模式下运行Publish循环, 会话失效后重连
+func (broker *OpcUaBroker) session(ctx context.Context) {
+	defer broker.wg.Done()
+	broker.mu.Lock()
+	client := broker.client
+	broker.mu.Unlock()
+	for {
+		if client == nil {
+			select {
+			case <-broker.ExitCh:
+				return
+			case <-time.After(reconnectInterval):
+			}
+			var err error
+			if client, err = broker.connect(ctx); err != nil {
+				klog.V(2).InfoS("Failed to reconnect OpcUa server", "error", err, "deviceId", broker.Device.ID)
+				broker.send(&collector.ParseVariableResult{Err: []error{err}})
+				continue
+			}
+			broker.mu.Lock()
+			if broker.exited() {
+				broker.mu.Unlock()
+				_ = client.Close(ctx)
+				return
+			}
+			broker.client = client
+			broker.mu.Unlock()
+		}
+
+		err := broker.subscribe(ctx, client)
+		if broker.exited() {
+			return
+		}
+		klog.V(2).InfoS("OpcUa session lost", "error", err, "deviceId", broker.Device.ID)
+		broker.send(&collector.ParseVariableResult{Err: []error{err}})
+		broker.mu.Lock()
+		broker.client = nil
+		broker.polled = nil
+		broker.mu.Unlock()
+		closeCtx, cancel := context.WithTimeout(ctx, broker.Config.Timeout)
+		_ = client.Close(closeCtx)
+		cancel()
+		client = nil
+	}
+}
+
+// subscribe 创建订阅与监控项后运行Publish循环, 返回时会话需要重建
+func (broker *OpcUaBroker) subscribe(ctx context.Context, client *ua.Client) error {
+	if broker.Device.CollectMode == runtime.CollectPoll {
+		return broker.pollOnly(client, broker.Device.Variables)
+	}
+	sub, err := client.CreateSubscription(ctx, time.Duration(broker.Device.PublishingInterval)*time.Millisecond)
+	if err != nil {
+		if errors.Is(err, ua.StatusBadServiceUnsupported) {
+			klog.V(2).InfoS("OpcUa server does not support subscriptions, fall back to poll", "deviceId", broker.Device.ID)
+			return broker.pollOnly(client, broker.Device.Variables)
+		}
+		return err
+	}
+	items := make([]*ua.MonitoredItemCreateRequest, 0, len(broker.Device.Variables))
+	for i, variable := range broker.Device.Variables {
+		items = append(items, &ua.MonitoredItemCreateRequest{
+			ItemToMonitor:    ua.ReadValueId{NodeId: variable.Node, AttributeId: ua.AttributeValue},
+			MonitoringMode:   ua.MonitoringModeReporting,
+			ClientHandle:     uint32(i),
+			SamplingInterval: float64(broker.Device.SamplingInterval),
+			QueueSize:        1,
+			DiscardOldest:    true,
+		})
+	}
+	results, err := client.CreateMonitoredItems(ctx, sub.SubscriptionId, items)
+	if err != nil {
+		return err
+	}
+	polled := make([]*runtime.Variable, 0)
+	for i, result := range results {
+		if result.StatusCode.IsBad() {
+			variable := broker.Device.Variables[i]
+			klog.V(2).InfoS("Failed to monitor OpcUa node, fall back to poll", "deviceId", broker.Device.ID,
+				"variableName", variable.Name, "nodeId", variable.NodeId, "error", result.StatusCode)
+			polled = append(polled, variable)
+		}
+	}
+	broker.mu.Lock()
+	broker.polled = polled
+	broker.mu.Unlock()
+	klog.V(3).InfoS("OpcUa subscription created", "deviceId", broker.Device.ID, "subscriptionId", sub.SubscriptionId,
+		"publishingInterval", sub.RevisedPublishingInterval, "monitoredItems", len(items)-len(polled), "polled", len(polled))
+	return broker.publish(ctx, client, sub)
+}
+
+// pollOnly 全部变量由调度轮询, 等待会话关闭
+func (broker *OpcUaBroker) pollOnly(client *ua.Client, variables []*runtime.Variable) error {
+	broker.mu.Lock()
+	broker.polled = variables
+	broker.mu.Unlock()
+	select {
+	case <-client.Done():
+		return runtime.ErrSessionClosed
+	case <-broker.ExitCh:
+		return nil
+	}
+}
+
+// publish 同时只保留一个Publish请求, 服务器在保活周期内无变化时返回空通知
+func (broker *OpcUaBroker) publish(ctx context.Context, client *ua.Client, sub *ua.CreateSubscriptionResponse) error {
+	interval := time.Duration(sub.RevisedPublishingInterval * float64(time.Millisecond))
+	keepAlive := interval * time.Duration(sub.RevisedMaxKeepAliveCount)
+	var acks []*ua.SubscriptionAcknowledgement
+	for !broker.exited() {
+		publishCtx, cancel := context.WithTimeout(ctx, keepAlive+broker.Config.Timeout)
+		resp, err := client.Publish(publishCtx, acks)
+		cancel()
+		if err != nil {
+			return err
+		}
+		acks = nil
+		if resp.Notifications > 0 {
+			acks = append(acks, &ua.SubscriptionAcknowledgement{SubscriptionId: resp.SubscriptionId, SequenceNumber: resp.SequenceNumber})
+		}
+		if resp.StatusChange != nil {
+			// 订阅超时等原因被服务器删除
+			return *resp.StatusChange
+		}
+		if len(resp.DataChanges) == 0 {
+			continue
+		}
+		rvs := make([]collector.VariableValue, 0, len(resp.DataChanges))
+		errs := make([]error, 0)
+		for _, change := range resp.DataChanges {
+			if int(change.ClientHandle) >= len(broker.Device.Variables) {
+				continue
+			}
+			variable := broker.Device.Variables[change.ClientHandle]
+			if rv, err := broker.parseDataValue(variable, change.Value); err != nil {
+				errs = append(errs, err)
+			} else {
+				rvs = append(rvs, rv)
+			}
+		}
+		broker.send(&collector.ParseVariableResult{VariableSlice: rvs, Err: collectErrors(errs)})
+	}
+	return nil
+}
+
+// poll 批量读取需要轮询的变量
+func (broker *OpcUaBroker) poll(ctx context.Context) bool {
+	if broker.exited() {
+		return false
+	}
+	broker.mu.Lock()
+	client, variables := broker.client, broker.polled
+	broker.mu.Unlock()
+	if len(variables) == 0 {
+		return true
+	}
+	if client == nil {
+		broker.send(&collector.ParseVariableResult{Err: []error{runtime.ErrSessionClosed}})
+		return true
+	}
+	nodes := make([]*ua.ReadValueId, 0, len(variables))
+	for _, variable := range variables {
+		nodes = append(nodes, &ua.ReadValueId{NodeId: variable.Node, AttributeId: ua.AttributeValue})
+	}
+	values, err := client.Read(ctx, nodes)
+	if err != nil {
+		klog.V(2).InfoS("Failed to read OpcUa nodes", "error", err, "deviceId", broker.Device.ID)
+		broker.send(&collector.ParseVariableResult{Err: []error{err}})
+		return true
+	}
+	rvs := make([]collector.VariableValue, 0, len(variables))
+	errs := make([]error, 0)
+	for i, variable := range variables {
+		if rv, err := broker.parseDataValue(variable, values[i]); err != nil {
+			errs = append(errs, err)
+		} else {
+			rvs = append(rvs, rv)
+		}
+	}
+	broker.send(&collector.ParseVariableResult{VariableSlice: rvs, Err: collectErrors(errs)})
+	return true
+}
+
+// parseDataValue 状态为Bad时返回错误, 记录服务器返回值的内置类型
+func (broker *OpcUaBroker) parseDataValue(variable *runtime.Variable, dv *ua.DataValue) (collector.VariableValue, error) {
+	if dv.Status.IsBad() {
+		return nil, &MappingError{Name: variable.Name, NodeId: variable.NodeId, Err: dv.Status}
+	}
+	if dv.Value != nil && dv.Value.Type != ua.TypeNull {
+		broker.mu.Lock()
+		broker.variantTypes[variable.Name] = dv.Value.Type
+		broker.mu.Unlock()
+	}
+	value := runtime.ParseValue(variable.DataType, dv.Value)
+	variable.SetValue(value)
+	return &runtime.Variable{
+		DataType:     variable.DataType,
+		Name:         variable.Name,
+		NodeId:       variable.NodeId,
+		DefaultValue: variable.DefaultValue,
+		Value:        value,
+		AccessMode:   variable.AccessMode,
+	}, nil
+}
+
+func collectErrors(errs []error) []error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,318 @@
+package opcua
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/opcua/runtime"
+	"harnsplatform/internal/collector/opcua/ua"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	testLine    = ua.NewStringNodeId(2, "Line1")
+	testSpeed   = ua.NewStringNodeId(2, "Line1.Speed")
+	testRunning = ua.NewStringNodeId(2, "Line1.Running")
+	testCount   = ua.NewStringNodeId(2, "Line1.Count")
+	testName    = ua.NewStringNodeId(2, "Line1.Name")
+)
+
+type testCertificate struct {
+	der      []byte
+	key      *rsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCertificate 自签名证书, 以PEM格式写入临时目录
+func newTestCertificate(t *testing.T, name string) *testCertificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, _ := url.Parse("urn:harnsplatform:test:" + name)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{uri},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cert := &testCertificate{der: der, key: key, certFile: filepath.Join(dir, name+".pem"), keyFile: filepath.Join(dir, name+".key")}
+	if err = os.WriteFile(cert.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err = os.WriteFile(cert.keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (c *testCertificate) thumbprint() string {
+	sum := sha1.Sum(c.der)
+	return hex.EncodeToString(sum[:])
+}
+
+// startTestServer Objects/Line1 下的变量, Name只读, cert为空时只有None端点
+func startTestServer(t *testing.T, cert *testCertificate) (*ua.Server, string) {
+	t.Helper()
+	var s *ua.Server
+	if cert != nil {
+		s = ua.NewServer(cert.der, cert.key)
+	} else {
+		s = ua.NewServer(nil, nil)
+	}
+	s.Users["operator"] = "secret"
+	objects := ua.NewNumericNodeId(0, ua.ObjectsFolder)
+	rw := ua.AccessLevelCurrentRead | ua.AccessLevelCurrentWrite
+	for _, err := range []error{
+		s.AddObject(objects, testLine, "Line1"),
+		s.AddVariable(testLine, testSpeed, "Speed", &ua.Variant{Type: ua.TypeDouble, Value: 12.5}, rw),
+		s.AddVariable(testLine, testRunning, "Running", &ua.Variant{Type: ua.TypeBoolean, Value: true}, rw),
+		s.AddVariable(testLine, testCount, "Count", &ua.Variant{Type: ua.TypeInt32, Value: int32(7)}, rw),
+		s.AddVariable(testLine, testName, "Name", &ua.Variant{Type: ua.TypeString, Value: "line"}, ua.AccessLevelCurrentRead),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return s, "opc.tcp://" + l.Addr().String()
+}
+
+func newTestMappings() []*biz.Mapping {
+	return []*biz.Mapping{
+		{Name: "speed", NodeId: testSpeed.String(), DataType: "float64", AccessMode: "rw"},
+		{Name: "running", NodeId: testRunning.String(), DataType: "bool", AccessMode: "rw"},
+		{Name: "count", NodeId: testCount.String(), DataType: "number", AccessMode: "rw"},
+		{Name: "name", NodeId: testName.String(), DataType: "string", AccessMode: "r"},
+	}
+}
+
+func newTestBroker(t *testing.T, endpoint string, details biz.JSONMap, mappings []*biz.Mapping) *OpcUaBroker {
+	t.Helper()
+	agents := &biz.Agents{
+		Name:           "line",
+		AgentType:      "opcUa",
+		CollectorCycle: 20,
+		AgentDetails:   details,
+		Address:        biz.JSONMap{"endpoint": endpoint},
+	}
+	device, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.IndexDevice()
+	broker, _, err := NewBroker(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return broker.(*OpcUaBroker)
+}
+
+// waitValues 接收采集结果直到done返回true, values为每个变量最近一次的值
+func waitValues(t *testing.T, ch chan *collector.ParseVariableResult, done func(values map[string]interface{}, errs []error) bool) {
+	t.Helper()
+	values := make(map[string]interface{})
+	errs := make([]error, 0)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case pvr := <-ch:
+			for _, v := range pvr.VariableSlice {
+				values[v.GetVariableName()] = v.GetValue()
+			}
+			errs = append(errs, pvr.Err...)
+			if done(values, errs) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout, values %v, errors %v", values, errs)
+		}
+	}
+}
+
+func TestBrokerSubscription(t *testing.T) {
+	s, endpoint := startTestServer(t, nil)
+	mappings := append(newTestMappings(), &biz.Mapping{Name: "missing", NodeId: "ns=2;s=Missing", DataType: "float64"})
+	broker := newTestBroker(t, endpoint, biz.JSONMap{"publishingInterval": 20}, mappings)
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+
+	// 监控项创建失败的变量按采集周期轮询, 返回节点的错误
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		missing := false
+		for _, err := range errs {
+			var mappingErr *MappingError
+			missing = missing || errors.As(err, &mappingErr) && mappingErr.Name == "missing" && errors.Is(err, ua.StatusBadNodeIdUnknown)
+		}
+		return missing && values["speed"] == 12.5 && values["running"] == true && values["count"] == 7.0 && values["name"] == "line"
+	})
+
+	if err := s.SetValue(testSpeed, &ua.Variant{Type: ua.TypeDouble, Value: 40.0}); err != nil {
+		t.Fatal(err)
+	}
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return values["speed"] == 40.0
+	})
+}
+
+func TestBrokerPoll(t *testing.T) {
+	s, endpoint := startTestServer(t, nil)
+	broker := newTestBroker(t, endpoint, biz.JSONMap{"collectMode": "poll"}, newTestMappings())
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return len(values) == 4 && values["speed"] == 12.5 && values["count"] == 7.0
+	})
+	if err := s.SetValue(testRunning, &ua.Variant{Type: ua.TypeBoolean, Value: false}); err != nil {
+		t.Fatal(err)
+	}
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return values["running"] == false
+	})
+}
+
+func TestBrokerDeliverAction(t *testing.T) {
+	s, endpoint := startTestServer(t, nil)
+	broker := newTestBroker(t, endpoint, biz.JSONMap{"collectMode": "poll"}, newTestMappings())
+	defer broker.Destroy(context.Background())
+
+	// count为number, 尚未采集时先读取节点确定内置类型
+	results, err := broker.DeliverAction(context.Background(), map[string]interface{}{
+		"speed":   55.5,
+		"running": "false",
+		"count":   9,
+		"name":    "x",
+		"unknown": 1,
+	})
+	var multiErr *collector.MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf("got %v", err)
+	}
+	want := map[string]error{
+		"speed":   nil,
+		"running": nil,
+		"count":   nil,
+		"name":    runtime.ErrVariableReadOnly,
+		"unknown": runtime.ErrVariableNotFound,
+	}
+	for _, result := range results {
+		if result.Err != want[result.Name] {
+			t.Errorf("%s: got %v, want %v", result.Name, result.Err, want[result.Name])
+		}
+		if (result.Err == nil) != (result.Status == collector.ActionSuccess) {
+			t.Errorf("%s: status %s", result.Name, result.Status)
+		}
+	}
+	if v := s.Value(testSpeed); v.Value != 55.5 {
+		t.Errorf("speed: got %v", v.Value)
+	}
+	if v := s.Value(testRunning); v.Value != false {
+		t.Errorf("running: got %v", v.Value)
+	}
+	if v := s.Value(testCount); v.Type != ua.TypeInt32 || v.Value != int32(9) {
+		t.Errorf("count: got %#v", v)
+	}
+
+	// 超出内置类型范围与无法转换的值
+	results, err = broker.DeliverAction(context.Background(), map[string]interface{}{"count": 1e10, "running": "on"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, result := range results {
+		if result.Err == nil || result.Status != collector.ActionFailed {
+			t.Errorf("%s: got %v, status %s", result.Name, result.Err, result.Status)
+		}
+	}
+	if v := s.Value(testCount); v.Value != int32(9) {
+		t.Errorf("count changed to %v", v.Value)
+	}
+}
+
+func TestBrokerSecure(t *testing.T) {
+	serverCert := newTestCertificate(t, "server")
+	clientCert := newTestCertificate(t, "client")
+	other := newTestCertificate(t, "other")
+	_, endpoint := startTestServer(t, serverCert)
+
+	tests := []struct {
+		name    string
+		details biz.JSONMap
+		err     error
+	}{
+		{
+			name: "trusted certificate",
+			details: biz.JSONMap{"securityPolicy": "Basic256Sha256", "serverCertificate": serverCert.certFile,
+				"authMode": "username", "username": "operator", "password": "secret"},
+		},
+		{
+			name:    "trusted thumbprint sign",
+			details: biz.JSONMap{"securityPolicy": "Basic256Sha256", "securityMode": "Sign", "serverThumbprint": serverCert.thumbprint()},
+		},
+		{
+			name:    "untrusted certificate",
+			details: biz.JSONMap{"securityPolicy": "Basic256Sha256", "serverCertificate": other.certFile},
+			err:     collector.ErrConnectDevice,
+		},
+		{
+			name:    "untrusted thumbprint",
+			details: biz.JSONMap{"securityPolicy": "Basic256Sha256", "serverThumbprint": other.thumbprint()},
+			err:     collector.ErrConnectDevice,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.details["certificateFile"] = clientCert.certFile
+			tt.details["privateKeyFile"] = clientCert.keyFile
+			tt.details["collectMode"] = "poll"
+			agents := &biz.Agents{Name: "line", CollectorCycle: 20, AgentDetails: tt.details, Address: biz.JSONMap{"endpoint": endpoint}}
+			device, err := ConvertDevice(agents, newTestMappings())
+			if err != nil {
+				t.Fatal(err)
+			}
+			device.IndexDevice()
+			b, _, err := NewBroker(device)
+			if err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			broker := b.(*OpcUaBroker)
+			broker.Collect(context.Background())
+			defer broker.Destroy(context.Background())
+			waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+				return values["name"] == "line"
+			})
+		})
+	}
+}
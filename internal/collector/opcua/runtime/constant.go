@@ -0,0 +1,97 @@
+package runtime
+
+import (
+	"errors"
+	"harnsplatform/internal/collector/opcua/ua"
+	"harnsplatform/internal/common"
+)
+
+var ErrVariableNameEmpty = errors.New("opcua variable name empty")
+var ErrVariableNameDuplicate = errors.New("opcua variable name duplicate")
+var ErrNodeIdInvalid = errors.New("opcua variable node id invalid")
+var ErrDataTypeUnsupported = errors.New("opcua variable data type unsupported")
+var ErrAccessModeInvalid = errors.New("opcua variable access mode invalid")
+var ErrAgentDetailsInvalid = errors.New("opcua agent details invalid")
+var ErrAgentAddressInvalid = errors.New("opcua agent address invalid")
+var ErrSecurityPolicyInvalid = errors.New("opcua security policy invalid")
+var ErrSecurityModeInvalid = errors.New("opcua security mode invalid")
+var ErrAuthModeInvalid = errors.New("opcua auth mode invalid")
+var ErrCollectModeInvalid = errors.New("opcua collect mode invalid")
+var ErrOverrunPolicyInvalid = errors.New("opcua overrun policy invalid")
+var ErrCertificateRequired = errors.New("opcua certificate file and private key file required")
+var ErrUsernameRequired = errors.New("opcua username required")
+var ErrServerCertificateRequired = errors.New("opcua trusted server certificate or thumbprint required")
+var ErrServerThumbprintInvalid = errors.New("opcua server certificate thumbprint invalid")
+var ErrVariableNotFound = errors.New("opcua variable not found")
+var ErrVariableReadOnly = errors.New("opcua variable read only")
+var ErrActionValueInvalid = errors.New("opcua action value invalid")
+var ErrVariantTypeUnknown = errors.New("opcua variable value type unknown, read the node first")
+var ErrSessionClosed = errors.New("opcua session closed")
+var ErrBrowseRootInvalid = errors.New("opcua browse root node id invalid")
+
+// CollectMode 采集方式
+type CollectMode byte
+
+const (
+	// CollectSubscription 订阅监控项, 服务器按值变化推送, 创建失败的监控项改为轮询
+	CollectSubscription CollectMode = iota
+	// CollectPoll 按采集周期批量读取
+	CollectPoll
+)
+
+var CollectModeToString = map[CollectMode]string{
+	CollectSubscription: "subscription",
+	CollectPoll:         "poll",
+}
+
+var StringToCollectMode = map[string]CollectMode{
+	"subscription": CollectSubscription,
+	"poll":         CollectPoll,
+}
+
+// 用户认证方式
+var StringToAuthMode = map[string]ua.UserTokenType{
+	"anonymous":   ua.UserTokenTypeAnonymous,
+	"username":    ua.UserTokenTypeUserName,
+	"certificate": ua.UserTokenTypeCertificate,
+}
+
+var AuthModeToString = map[ua.UserTokenType]string{
+	ua.UserTokenTypeAnonymous:   "anonymous",
+	ua.UserTokenTypeUserName:    "username",
+	ua.UserTokenTypeCertificate: "certificate",
+}
+
+// DataTypeToVariantType 点位数据类型对应的内置类型, number按服务器的实际类型读写
+var DataTypeToVariantType = map[common.DataType]ua.TypeId{
+	common.BOOL:    ua.TypeBoolean,
+	common.INT8:    ua.TypeSByte,
+	common.UINT8:   ua.TypeByte,
+	common.INT16:   ua.TypeInt16,
+	common.UINT16:  ua.TypeUInt16,
+	common.INT32:   ua.TypeInt32,
+	common.UINT32:  ua.TypeUInt32,
+	common.INT64:   ua.TypeInt64,
+	common.UINT64:  ua.TypeUInt64,
+	common.FLOAT32: ua.TypeFloat,
+	common.FLOAT64: ua.TypeDouble,
+	common.STRING:  ua.TypeString,
+}
+
+// VariantTypeToDataType 浏览时按节点的内置类型推断点位数据类型
+var VariantTypeToDataType = map[ua.TypeId]common.DataType{
+	ua.TypeBoolean:    common.BOOL,
+	ua.TypeSByte:      common.INT8,
+	ua.TypeByte:       common.UINT8,
+	ua.TypeInt16:      common.INT16,
+	ua.TypeUInt16:     common.UINT16,
+	ua.TypeInt32:      common.INT32,
+	ua.TypeUInt32:     common.UINT32,
+	ua.TypeInt64:      common.INT64,
+	ua.TypeUInt64:     common.UINT64,
+	ua.TypeFloat:      common.FLOAT32,
+	ua.TypeDouble:     common.FLOAT64,
+	ua.TypeString:     common.STRING,
+	ua.TypeDateTime:   common.STRING,
+	ua.TypeStatusCode: common.UINT32,
+}
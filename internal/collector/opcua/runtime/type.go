@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/opcua/ua"
+	"harnsplatform/internal/common"
+)
+
+var _ collector.Device = (*OpcUaDevice)(nil)
+var _ collector.VariableValue = (*Variable)(nil)
+
+type Variable struct {
+	DataType     common.DataType   `json:"dataType"`               // bool、int8 ~ uint64、float32、float64、string, number按服务器类型
+	Name         string            `json:"name"`                   // 变量名称
+	NodeId       string            `json:"nodeId"`                 // 节点编号 例如 ns=2;s=Line1.Speed
+	Node         ua.NodeId         `json:"-"`                      // 解析后的节点编号
+	DefaultValue interface{}       `json:"defaultValue,omitempty"` // 默认值
+	Value        interface{}       `json:"value,omitempty"`        // 值
+	AccessMode   common.AccessMode `json:"accessMode"`             // 读写属性
+}
+
+func (v *Variable) GetVariableAccessMode() common.AccessMode {
+	return v.AccessMode
+}
+
+func (v *Variable) SetValue(value interface{}) {
+	v.Value = value
+}
+
+func (v *Variable) GetValue() interface{} {
+	return v.Value
+}
+
+func (v *Variable) GetVariableName() string {
+	return v.Name
+}
+
+func (v *Variable) SetVariableName(name string) {
+	v.Name = name
+}
+
+type OpcUaDevice struct {
+	collector.DeviceMeta
+	CollectorCycle     uint                    `json:"collectorCycle"`                    // 轮询周期 毫秒
+	OverrunPolicy      collector.OverrunPolicy `json:"overrunPolicy"`                     // 轮询超过周期时的处理策略
+	Endpoint           string                  `json:"endpoint"`                          // opc.tcp://host:port/path
+	SecurityPolicy     string                  `json:"securityPolicy"`                    // 安全策略URI
+	SecurityMode       ua.MessageSecurityMode  `json:"securityMode"`                      // None Sign SignAndEncrypt
+	AuthMode           ua.UserTokenType        `json:"authMode"`                          // anonymous username certificate
+	Username           string                  `json:"username,omitempty"`                // 用户名
+	Password           string                  `json:"-"`                                 // 密码
+	CertificateFile    string                  `json:"certificateFile,omitempty"`         // 应用实例证书, 安全策略非None或证书认证时需要
+	PrivateKeyFile     string                  `json:"privateKeyFile,omitempty"`          // 证书私钥
+	ServerCertificate  string                  `json:"serverCertificate,omitempty"`       // 信任的服务器证书或CA证书
+	ServerThumbprint   string                  `json:"serverThumbprint,omitempty"`        // 信任的服务器证书SHA1指纹
+	CollectMode        CollectMode             `json:"collectMode"`                       // subscription poll
+	PublishingInterval uint                    `json:"publishingInterval"`                // 订阅发布周期 毫秒
+	SamplingInterval   uint                    `json:"samplingInterval"`                  // 监控项采样周期 毫秒
+	Timeout            uint                    `json:"timeout"`                           // 请求超时 毫秒
+	Variables          []*Variable             `json:"variables" binding:"required,dive"` // 自定义变量
+	VariablesMap       map[string]*Variable    `json:"-"`                                 // 自定义变量Map
+}
+
+func (m *OpcUaDevice) IndexDevice() {
+	m.VariablesMap = make(map[string]*Variable)
+	for _, variable := range m.Variables {
+		m.VariablesMap[variable.Name] = variable
+	}
+}
+
+func (m *OpcUaDevice) GetVariable(key string) (rv collector.VariableValue, exist bool) {
+	if v, isExist := m.VariablesMap[key]; isExist {
+		rv = v
+		exist = isExist
+	}
+	return
+}
+
+func (m *OpcUaDevice) GetVariables() []collector.VariableValue {
+	rvs := make([]collector.VariableValue, 0)
+
+	for _, variable := range m.Variables {
+		rvs = append(rvs, variable)
+	}
+
+	return rvs
+}
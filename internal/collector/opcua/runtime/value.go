@@ -0,0 +1,182 @@
+package runtime
+
+import (
+	"encoding/json"
+	"harnsplatform/internal/collector/opcua/ua"
+	"harnsplatform/internal/common"
+	"math"
+	"strconv"
+	"time"
+)
+
+/**
+值转换
+读取: 服务器的内置类型按点位数据类型转换, number转换为float64, 数组保持[]interface{}
+写入: 下发值按目标内置类型转换, number使用服务器的实际类型
+*/
+
+// ParseValue 按点位数据类型转换服务器返回的值, 无法转换时返回原值
+func ParseValue(dataType common.DataType, v *ua.Variant) interface{} {
+	if v == nil || v.Type == ua.TypeNull {
+		return nil
+	}
+	if v.Array {
+		return v.Value
+	}
+	switch value := v.Value.(type) {
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case ua.LocalizedText:
+		return value.Text
+	case ua.QualifiedName:
+		return value.String()
+	case ua.NodeId:
+		return value.String()
+	case ua.Guid:
+		return value.String()
+	case ua.StatusCode:
+		return uint32(value)
+	}
+	if dataType == common.STRING {
+		if s, ok := v.Value.(string); ok {
+			return s
+		}
+	}
+	f, ok := toFloat64(v.Value)
+	if !ok {
+		return v.Value
+	}
+	if dataType == common.NUMBER {
+		return f
+	}
+	target, ok := DataTypeToVariantType[dataType]
+	if !ok {
+		return v.Value
+	}
+	if converted, err := convertNumber(target, f); err == nil {
+		return converted
+	}
+	return v.Value
+}
+
+// ActionVariant 按目标内置类型转换下发值
+func ActionVariant(target ua.TypeId, value interface{}) (*ua.Variant, error) {
+	switch target {
+	case ua.TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return &ua.Variant{Type: target, Value: v}, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, ErrActionValueInvalid
+			}
+			return &ua.Variant{Type: target, Value: b}, nil
+		}
+		if f, ok := toFloat64(value); ok {
+			return &ua.Variant{Type: target, Value: f != 0}, nil
+		}
+		return nil, ErrActionValueInvalid
+	case ua.TypeString:
+		if v, ok := value.(string); ok {
+			return &ua.Variant{Type: target, Value: v}, nil
+		}
+		return nil, ErrActionValueInvalid
+	}
+	f, ok := toFloat64(value)
+	if !ok {
+		return nil, ErrActionValueInvalid
+	}
+	v, err := convertNumber(target, f)
+	if err != nil {
+		return nil, err
+	}
+	return &ua.Variant{Type: target, Value: v}, nil
+}
+
+// convertNumber 整数类型要求为整数且在取值范围内
+func convertNumber(target ua.TypeId, f float64) (interface{}, error) {
+	integer := f == math.Trunc(f)
+	inRange := func(min, max float64) bool {
+		return integer && f >= min && f <= max
+	}
+	switch target {
+	case ua.TypeSByte:
+		if inRange(math.MinInt8, math.MaxInt8) {
+			return int8(f), nil
+		}
+	case ua.TypeByte:
+		if inRange(0, math.MaxUint8) {
+			return uint8(f), nil
+		}
+	case ua.TypeInt16:
+		if inRange(math.MinInt16, math.MaxInt16) {
+			return int16(f), nil
+		}
+	case ua.TypeUInt16:
+		if inRange(0, math.MaxUint16) {
+			return uint16(f), nil
+		}
+	case ua.TypeInt32:
+		if inRange(math.MinInt32, math.MaxInt32) {
+			return int32(f), nil
+		}
+	case ua.TypeUInt32:
+		if inRange(0, math.MaxUint32) {
+			return uint32(f), nil
+		}
+	case ua.TypeInt64:
+		if inRange(math.MinInt64, math.MaxInt64) {
+			return int64(f), nil
+		}
+	case ua.TypeUInt64:
+		if inRange(0, math.MaxUint64) {
+			return uint64(f), nil
+		}
+	case ua.TypeFloat:
+		if math.Abs(f) <= math.MaxFloat32 {
+			return float32(f), nil
+		}
+	case ua.TypeDouble:
+		return f, nil
+	default:
+		return nil, ErrDataTypeUnsupported
+	}
+	return nil, ErrActionValueInvalid
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,666 @@
+package ua
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"k8s.io/klog/v2"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/**
+UA TCP 与安全通道 (Part 6 7.1, 6.7)
+报文头: 类型(3) + 分块标记(1) + 长度(4)
+HEL/ACK/ERR 之后为 OPN/MSG/CLO, 均带通道编号
+OPN 使用非对称安全头, MSG/CLO 使用令牌编号, 之后为序列号与请求编号
+分块标记 C 中间块, F 最后一块, A 中止
+*/
+
+const (
+	messageHeaderLength   = 8
+	secureHeaderLength    = 12
+	symmetricHeaderLength = 16
+	sequenceHeaderLength  = 8
+
+	defaultBufferSize      = 65536
+	defaultMaxMessageSize  = 16 * 1024 * 1024
+	defaultChannelLifetime = time.Hour
+)
+
+const (
+	chunkIntermediate byte = 'C'
+	chunkFinal        byte = 'F'
+	chunkAbort        byte = 'A'
+)
+
+type connectionLimits struct {
+	ReceiveBufferSize uint32
+	SendBufferSize    uint32
+	MaxMessageSize    uint32
+	MaxChunkCount     uint32
+}
+
+type response struct {
+	body []byte
+	err  error
+}
+
+type secureChannel struct {
+	conn     net.Conn
+	endpoint string
+	policy   *securityPolicy
+	timeout  time.Duration
+
+	// remote 服务器的缓冲区限制
+	remote connectionLimits
+
+	writeMu   sync.Mutex
+	channelId uint32
+	tokenId   uint32
+	seqNum    uint32
+	requestId uint32
+	// localKeys 发送使用的对称密钥, remoteKeys 按令牌编号保存服务器的对称密钥, 续订期间新旧令牌同时有效
+	localKeys  *symmetricKeys
+	remoteKeys map[uint32]*symmetricKeys
+
+	mu      sync.Mutex
+	pending map[uint32]chan *response
+	partial map[uint32][]byte
+	err     error
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// ParseEndpoint opc.tcp://host:port/path
+func ParseEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "opc.tcp" || u.Host == "" {
+		return "", ErrEndpointUrlInvalid
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "4840"), nil
+	}
+	return u.Host, nil
+}
+
+func dialChannel(ctx context.Context, endpoint string, policy *securityPolicy, timeout time.Duration) (*secureChannel, error) {
+	address, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &secureChannel{
+		conn:       conn,
+		endpoint:   endpoint,
+		policy:     policy,
+		timeout:    timeout,
+		seqNum:     1,
+		remoteKeys: make(map[uint32]*symmetricKeys),
+		pending:    make(map[uint32]chan *response),
+		partial:    make(map[uint32][]byte),
+		closed:     make(chan struct{}),
+	}
+	if err = c.hello(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go c.receive()
+	if err = c.open(ctx, SecurityTokenIssue); err != nil {
+		c.close(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *secureChannel) hello() error {
+	e := NewEncoder()
+	e.Write([]byte("HELF"))
+	e.UInt32(0)
+	e.UInt32(0) // ProtocolVersion
+	e.UInt32(defaultBufferSize)
+	e.UInt32(defaultBufferSize)
+	e.UInt32(defaultMaxMessageSize)
+	e.UInt32(0) // MaxChunkCount 不限制
+	e.String(c.endpoint)
+	b := e.Bytes()
+	putUint32(b, 4, uint32(len(b)))
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	defer func() {
+		_ = c.conn.SetDeadline(time.Time{})
+	}()
+	if _, err := c.conn.Write(b); err != nil {
+		return err
+	}
+	chunk, err := c.readChunk()
+	if err != nil {
+		return err
+	}
+	d := NewDecoder(chunk[messageHeaderLength:])
+	switch string(chunk[:3]) {
+	case "ACK":
+		d.UInt32() // ProtocolVersion
+		c.remote.ReceiveBufferSize = d.UInt32()
+		c.remote.SendBufferSize = d.UInt32()
+		c.remote.MaxMessageSize = d.UInt32()
+		c.remote.MaxChunkCount = d.UInt32()
+		if d.Err() != nil {
+			return d.Err()
+		}
+		if c.remote.ReceiveBufferSize < 8192 {
+			return ErrDecodeInvalid
+		}
+		return nil
+	case "ERR":
+		return decodeError(d)
+	}
+	return ErrMessageType
+}
+
+// decodeError ERR报文与中止块均为 错误码 + 原因
+func decodeError(d *Decoder) error {
+	status := StatusCode(d.UInt32())
+	reason := d.String()
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if reason != "" {
+		klog.V(2).InfoS("Opcua server reported error", "status", status.Error(), "reason", reason)
+	}
+	return status
+}
+
+func (c *secureChannel) readChunk() ([]byte, error) {
+	header := make([]byte, messageHeaderLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[4:])
+	if size < messageHeaderLength {
+		return nil, ErrDecodeInvalid
+	}
+	if size > defaultBufferSize {
+		return nil, ErrMessageTooLarge
+	}
+	chunk := make([]byte, size)
+	copy(chunk, header)
+	if _, err := io.ReadFull(c.conn, chunk[messageHeaderLength:]); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// open 建立或续订安全通道
+func (c *secureChannel) open(ctx context.Context, requestType SecurityTokenRequestType) error {
+	clientNonce, err := c.policy.nonce()
+	if err != nil {
+		return err
+	}
+	req := &OpenSecureChannelRequest{
+		RequestType:       requestType,
+		SecurityMode:      c.policy.mode,
+		ClientNonce:       clientNonce,
+		RequestedLifetime: uint32(defaultChannelLifetime / time.Millisecond),
+	}
+	resp := &OpenSecureChannelResponse{}
+	if err = c.send(ctx, "OPN", req, resp); err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	c.channelId = resp.SecurityToken.ChannelId
+	c.tokenId = resp.SecurityToken.TokenId
+	if c.policy.secure() {
+		c.localKeys = c.policy.deriveKeys(resp.ServerNonce, clientNonce)
+		remoteKeys := c.policy.deriveKeys(clientNonce, resp.ServerNonce)
+		c.mu.Lock()
+		// 只保留上一个令牌
+		for tokenId := range c.remoteKeys {
+			if tokenId+1 < resp.SecurityToken.TokenId {
+				delete(c.remoteKeys, tokenId)
+			}
+		}
+		c.remoteKeys[resp.SecurityToken.TokenId] = remoteKeys
+		c.mu.Unlock()
+	}
+	c.writeMu.Unlock()
+	lifetime := time.Duration(resp.SecurityToken.RevisedLifetime) * time.Millisecond
+	if lifetime <= 0 {
+		lifetime = defaultChannelLifetime
+	}
+	// 有效期的75%时续订
+	time.AfterFunc(lifetime*3/4, c.renew)
+	klog.V(4).InfoS("Opcua secure channel opened", "endpoint", c.endpoint, "channelId", resp.SecurityToken.ChannelId,
+		"tokenId", resp.SecurityToken.TokenId, "lifetime", lifetime)
+	return nil
+}
+
+func (c *secureChannel) renew() {
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if err := c.open(ctx, SecurityTokenRenew); err != nil {
+		klog.V(2).InfoS("Failed to renew opcua secure channel", "endpoint", c.endpoint, "err", err)
+		c.close(err)
+	}
+}
+
+// SendRequest 发送请求并等待响应, ServiceFault与服务结果错误均作为error返回
+func (c *secureChannel) SendRequest(ctx context.Context, req Request, resp Response) error {
+	return c.send(ctx, "MSG", req, resp)
+}
+
+func (c *secureChannel) send(ctx context.Context, messageType string, req Request, resp Response) error {
+	ch := make(chan *response, 1)
+	c.writeMu.Lock()
+	c.requestId++
+	requestId := c.requestId
+	header := req.Header()
+	header.RequestHandle = requestId
+	header.Timestamp = time.Now()
+	if deadline, ok := ctx.Deadline(); ok {
+		header.TimeoutHint = uint32(time.Until(deadline) / time.Millisecond)
+	}
+	e := NewEncoder()
+	e.NodeId(NewNumericNodeId(0, req.TypeId()))
+	req.Encode(e)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		c.writeMu.Unlock()
+		return err
+	}
+	c.pending[requestId] = ch
+	c.mu.Unlock()
+	err := c.write(messageType, requestId, e.Bytes())
+	c.writeMu.Unlock()
+	if err != nil {
+		c.close(err)
+		return err
+	}
+
+	var result *response
+	select {
+	case result = <-ch:
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, requestId)
+		c.mu.Unlock()
+		return ErrRequestTimeout
+	case <-c.closed:
+		return c.closeErr()
+	}
+	if result.err != nil {
+		return result.err
+	}
+	d := NewDecoder(result.body)
+	typeId := d.NodeId()
+	if typeId.Namespace != 0 || typeId.Type != IdTypeNumeric {
+		return ErrUnexpectedResponse
+	}
+	if typeId.Numeric == IdServiceFault {
+		fault := &ServiceFault{}
+		fault.Decode(d)
+		if d.Err() != nil {
+			return d.Err()
+		}
+		return fault.ServiceResult
+	}
+	if typeId.Numeric != resp.TypeId() {
+		return ErrUnexpectedResponse
+	}
+	resp.Decode(d)
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if resp.Header().ServiceResult.IsBad() {
+		return resp.Header().ServiceResult
+	}
+	return nil
+}
+
+// write 调用方持有writeMu
+func (c *secureChannel) write(messageType string, requestId uint32, body []byte) error {
+	var chunks [][]byte
+	var err error
+	if messageType == "OPN" {
+		chunks, err = c.asymmetricChunks(requestId, body)
+	} else {
+		chunks, err = c.symmetricChunks(messageType, requestId, body)
+	}
+	if err != nil {
+		return err
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	for _, chunk := range chunks {
+		if _, err = c.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *secureChannel) nextSeqNum() uint32 {
+	seqNum := c.seqNum
+	c.seqNum++
+	return seqNum
+}
+
+// asymmetricChunks OPN请求较小, 只使用一个分块
+func (c *secureChannel) asymmetricChunks(requestId uint32, body []byte) ([][]byte, error) {
+	p := c.policy
+	e := NewEncoder()
+	e.Write([]byte("OPN"))
+	e.Byte(chunkFinal)
+	e.UInt32(0)
+	e.UInt32(c.channelId)
+	e.String(p.uri)
+	if p.secure() {
+		e.ByteString(p.localCert)
+	} else {
+		e.ByteString(nil)
+	}
+	e.ByteString(p.remoteThumbprint())
+	headerLength := e.Len()
+	e.UInt32(c.nextSeqNum())
+	e.UInt32(requestId)
+	e.Write(body)
+	if !p.secure() {
+		b := e.Bytes()
+		putUint32(b, 4, uint32(len(b)))
+		return [][]byte{b}, nil
+	}
+
+	plainBlock, cipherBlock := p.asymmetricSizes()
+	signatureLength := p.localKey.Size()
+	extra := cipherBlock > 256
+	e.Write(padding(paddingSize(e.Len()-headerLength, signatureLength, plainBlock, extra), extra))
+	b := e.Bytes()
+	encryptedLength := (len(b) - headerLength + signatureLength) / plainBlock * cipherBlock
+	putUint32(b, 4, uint32(headerLength+encryptedLength))
+	signature, err := p.asymmetricSign(b)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := p.asymmetricEncrypt(append(append([]byte{}, b[headerLength:]...), signature...))
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{append(b[:headerLength:headerLength], encrypted...)}, nil
+}
+
+// maxChunkBody 服务器接收缓冲区内每个分块可容纳的消息体长度
+func (c *secureChannel) maxChunkBody() int {
+	size := int(c.remote.ReceiveBufferSize)
+	switch {
+	case !c.policy.secure():
+		return size - symmetricHeaderLength - sequenceHeaderLength
+	case !c.policy.encrypted():
+		return size - symmetricHeaderLength - sequenceHeaderLength - basic256Sha256SignatureLength
+	}
+	plain := (size - symmetricHeaderLength) / basic256Sha256BlockSize * basic256Sha256BlockSize
+	return plain - sequenceHeaderLength - basic256Sha256SignatureLength - 1
+}
+
+func (c *secureChannel) symmetricChunks(messageType string, requestId uint32, body []byte) ([][]byte, error) {
+	if c.remote.MaxMessageSize > 0 && len(body) > int(c.remote.MaxMessageSize) {
+		return nil, ErrMessageTooLarge
+	}
+	maxBody := c.maxChunkBody()
+	var chunks [][]byte
+	for offset := 0; offset == 0 || offset < len(body); offset += maxBody {
+		end := offset + maxBody
+		flag := chunkIntermediate
+		if end >= len(body) {
+			end = len(body)
+			flag = chunkFinal
+		}
+		chunk, err := c.symmetricChunk(messageType, flag, requestId, body[offset:end])
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	if c.remote.MaxChunkCount > 0 && len(chunks) > int(c.remote.MaxChunkCount) {
+		return nil, ErrMessageTooLarge
+	}
+	return chunks, nil
+}
+
+func (c *secureChannel) symmetricChunk(messageType string, flag byte, requestId uint32, body []byte) ([]byte, error) {
+	p := c.policy
+	e := NewEncoder()
+	e.Write([]byte(messageType))
+	e.Byte(flag)
+	e.UInt32(0)
+	e.UInt32(c.channelId)
+	e.UInt32(c.tokenId)
+	e.UInt32(c.nextSeqNum())
+	e.UInt32(requestId)
+	e.Write(body)
+	if !p.secure() {
+		b := e.Bytes()
+		putUint32(b, 4, uint32(len(b)))
+		return b, nil
+	}
+	if p.encrypted() {
+		size := paddingSize(e.Len()-symmetricHeaderLength, basic256Sha256SignatureLength, basic256Sha256BlockSize, false)
+		e.Write(padding(size, false))
+	}
+	b := e.Bytes()
+	putUint32(b, 4, uint32(len(b)+basic256Sha256SignatureLength))
+	b = append(b, symmetricSign(c.localKeys, b)...)
+	if !p.encrypted() {
+		return b, nil
+	}
+	encrypted, err := symmetricEncrypt(c.localKeys, b[symmetricHeaderLength:])
+	if err != nil {
+		return nil, err
+	}
+	return append(b[:symmetricHeaderLength], encrypted...), nil
+}
+
+// receive 读取分块, 按请求编号组装后交给等待的请求
+func (c *secureChannel) receive() {
+	for {
+		chunk, err := c.readChunk()
+		if err != nil {
+			c.close(err)
+			return
+		}
+		messageType := string(chunk[:3])
+		if messageType == "ERR" {
+			c.close(decodeError(NewDecoder(chunk[messageHeaderLength:])))
+			return
+		}
+		var requestId uint32
+		var body []byte
+		switch messageType {
+		case "OPN":
+			requestId, body, err = c.openAsymmetric(chunk)
+		case "MSG":
+			requestId, body, err = c.openSymmetric(chunk)
+		default:
+			err = ErrMessageType
+		}
+		if err != nil {
+			c.close(err)
+			return
+		}
+		c.dispatch(chunk[3], requestId, body)
+	}
+}
+
+func (c *secureChannel) dispatch(flag byte, requestId uint32, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result *response
+	switch flag {
+	case chunkIntermediate:
+		message := append(c.partial[requestId], body...)
+		if len(message) > defaultMaxMessageSize {
+			delete(c.partial, requestId)
+			result = &response{err: ErrMessageTooLarge}
+			break
+		}
+		c.partial[requestId] = message
+		return
+	case chunkFinal:
+		message := append(c.partial[requestId], body...)
+		delete(c.partial, requestId)
+		result = &response{body: message}
+	case chunkAbort:
+		delete(c.partial, requestId)
+		result = &response{err: decodeError(NewDecoder(body))}
+	default:
+		result = &response{err: ErrDecodeInvalid}
+	}
+	ch, ok := c.pending[requestId]
+	if !ok {
+		// 请求已超时
+		return
+	}
+	delete(c.pending, requestId)
+	ch <- result
+}
+
+func (c *secureChannel) openAsymmetric(chunk []byte) (uint32, []byte, error) {
+	p := c.policy
+	d := NewDecoder(chunk[secureHeaderLength:])
+	_ = d.String() // SecurityPolicyUri
+	d.ByteString() // SenderCertificate
+	d.ByteString() // ReceiverCertificateThumbprint
+	if d.Err() != nil {
+		return 0, nil, d.Err()
+	}
+	rest := d.Remaining()
+	headerLength := len(chunk) - len(rest)
+	if p.secure() {
+		plain, err := p.asymmetricDecrypt(rest)
+		if err != nil {
+			return 0, nil, err
+		}
+		signatureLength := p.remoteKey.Size()
+		if len(plain) < signatureLength+sequenceHeaderLength {
+			return 0, nil, ErrDecodeShort
+		}
+		signed := append(append([]byte{}, chunk[:headerLength]...), plain[:len(plain)-signatureLength]...)
+		if err = p.asymmetricVerify(signed, plain[len(plain)-signatureLength:]); err != nil {
+			return 0, nil, err
+		}
+		plain = plain[:len(plain)-signatureLength]
+		n, err := unpad(plain, p.localKey.Size() > 256)
+		if err != nil {
+			return 0, nil, err
+		}
+		rest = plain[:len(plain)-n]
+	}
+	return sequence(rest)
+}
+
+func (c *secureChannel) openSymmetric(chunk []byte) (uint32, []byte, error) {
+	p := c.policy
+	if len(chunk) < symmetricHeaderLength+sequenceHeaderLength {
+		return 0, nil, ErrDecodeShort
+	}
+	rest := chunk[symmetricHeaderLength:]
+	if !p.secure() {
+		return sequence(rest)
+	}
+	tokenId := binary.LittleEndian.Uint32(chunk[secureHeaderLength:])
+	c.mu.Lock()
+	keys, ok := c.remoteKeys[tokenId]
+	c.mu.Unlock()
+	if !ok {
+		return 0, nil, StatusBadSecureChannelTokenUnknown
+	}
+	if p.encrypted() {
+		plain, err := symmetricDecrypt(keys, rest)
+		if err != nil {
+			return 0, nil, err
+		}
+		rest = plain
+	}
+	if len(rest) < basic256Sha256SignatureLength+sequenceHeaderLength {
+		return 0, nil, ErrDecodeShort
+	}
+	end := len(rest) - basic256Sha256SignatureLength
+	signed := append(append([]byte{}, chunk[:symmetricHeaderLength]...), rest[:end]...)
+	if err := symmetricVerify(keys, signed, rest[end:]); err != nil {
+		return 0, nil, err
+	}
+	rest = rest[:end]
+	if p.encrypted() {
+		n, err := unpad(rest, false)
+		if err != nil {
+			return 0, nil, err
+		}
+		rest = rest[:len(rest)-n]
+	}
+	return sequence(rest)
+}
+
+// sequence 序列号头之后为消息体
+func sequence(b []byte) (uint32, []byte, error) {
+	if len(b) < sequenceHeaderLength {
+		return 0, nil, ErrDecodeShort
+	}
+	return binary.LittleEndian.Uint32(b[4:]), b[sequenceHeaderLength:], nil
+}
+
+func (c *secureChannel) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// close 关闭连接, 唤醒所有等待的请求
+func (c *secureChannel) close(err error) {
+	c.once.Do(func() {
+		if err == nil {
+			err = ErrChannelClosed
+		}
+		c.mu.Lock()
+		c.err = err
+		c.pending = make(map[uint32]chan *response)
+		c.mu.Unlock()
+		close(c.closed)
+		_ = c.conn.Close()
+	})
+}
+
+// Close 发送CLO后关闭连接
+func (c *secureChannel) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+	}
+	c.writeMu.Lock()
+	c.requestId++
+	e := NewEncoder()
+	e.NodeId(NewNumericNodeId(0, IdCloseSecureChannelRequest))
+	req := &CloseSecureChannelRequest{}
+	req.Timestamp = time.Now()
+	req.RequestHandle = c.requestId
+	req.Encode(e)
+	err := c.write("CLO", c.requestId, e.Bytes())
+	c.writeMu.Unlock()
+	c.close(ErrChannelClosed)
+	return err
+}
+
+// Done 通道关闭时关闭
+func (c *secureChannel) Done() <-chan struct{} {
+	return c.closed
+}
@@ -0,0 +1,497 @@
+package ua
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"k8s.io/klog/v2"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultApplicationUri = "urn:harnsplatform:broker"
+	defaultSessionTimeout = 10 * time.Minute
+)
+
+type ClientConfig struct {
+	// Endpoint opc.tcp://host:port/path
+	Endpoint       string
+	SecurityPolicy string
+	SecurityMode   MessageSecurityMode
+	// Certificate 应用实例证书(DER), 安全策略非None或证书认证时需要
+	Certificate []byte
+	PrivateKey  *rsa.PrivateKey
+	// TrustedCertificates 信任的服务器证书或CA证书(DER), ServerThumbprint 信任的服务器证书SHA1指纹
+	// 安全策略非None或用户密码需加密时, 服务器证书必须与其中之一匹配
+	TrustedCertificates [][]byte
+	ServerThumbprint    []byte
+	AuthMode            UserTokenType
+	Username            string
+	Password            string
+	Timeout             time.Duration
+	// SessionTimeout 会话在无请求时的保持时间
+	SessionTimeout time.Duration
+}
+
+// Client 一个安全通道上的一个会话, 不自动重连, 通道关闭后由调用方重新Dial
+type Client struct {
+	config    *ClientConfig
+	channel   *secureChannel
+	authToken NodeId
+}
+
+// LoadCertificate 读取PEM或DER格式的证书与RSA私钥
+func LoadCertificate(certFile, keyFile string) ([]byte, *rsa.PrivateKey, error) {
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if block, _ := pem.Decode(cert); block != nil {
+		cert = block.Bytes
+	}
+	if _, err = x509.ParseCertificate(cert); err != nil {
+		return nil, nil, err
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if block, _ := pem.Decode(key); block != nil {
+		key = block.Bytes
+	}
+	if privateKey, err := x509.ParsePKCS1PrivateKey(key); err == nil {
+		return cert, privateKey, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, ErrSecurityPolicyUnsupported
+	}
+	return cert, privateKey, nil
+}
+
+// LoadTrustedCertificates 读取PEM或DER格式的证书, PEM文件可包含多个证书
+func LoadTrustedCertificates(file string) ([][]byte, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var certs [][]byte
+	for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block.Bytes)
+		}
+	}
+	if len(certs) == 0 {
+		certs = [][]byte{b}
+	}
+	for _, cert := range certs {
+		if _, err = x509.ParseCertificate(cert); err != nil {
+			return nil, err
+		}
+	}
+	return certs, nil
+}
+
+// ParseThumbprint 十六进制的SHA1指纹, 忽略冒号与空格分隔
+func ParseThumbprint(s string) ([]byte, error) {
+	s = strings.NewReplacer(":", "", " ", "").Replace(s)
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != sha1.Size {
+		return nil, ErrThumbprintInvalid
+	}
+	return b, nil
+}
+
+// verifyServerCertificate 服务器证书与信任的证书或指纹一致, 或由信任的CA签发, 证书链取第一个证书
+func (config *ClientConfig) verifyServerCertificate(der []byte) error {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil || len(certs) == 0 {
+		return ErrServerCertificateUntrusted
+	}
+	leaf := certs[0]
+	if len(config.ServerThumbprint) > 0 {
+		sum := sha1.Sum(leaf.Raw)
+		if bytes.Equal(sum[:], config.ServerThumbprint) {
+			return nil
+		}
+	}
+	if len(config.TrustedCertificates) == 0 {
+		return ErrServerCertificateUntrusted
+	}
+	roots := x509.NewCertPool()
+	for _, trusted := range config.TrustedCertificates {
+		if bytes.Equal(trusted, leaf.Raw) {
+			return nil
+		}
+		if cert, err := x509.ParseCertificate(trusted); err == nil {
+			roots.AddCert(cert)
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err = leaf.Verify(opts); err != nil {
+		klog.V(2).InfoS("Opcua server certificate verification failed", "subject", leaf.Subject.String(), "err", err)
+		return ErrServerCertificateUntrusted
+	}
+	return nil
+}
+
+// sameCertificate 比较两个证书(链)的第一个证书
+func sameCertificate(a, b []byte) bool {
+	ca, err := x509.ParseCertificates(a)
+	if err != nil || len(ca) == 0 {
+		return false
+	}
+	cb, err := x509.ParseCertificates(b)
+	if err != nil || len(cb) == 0 {
+		return false
+	}
+	return bytes.Equal(ca[0].Raw, cb[0].Raw)
+}
+
+// verifyServerSignature 服务器以证书私钥对 客户端证书 + 客户端Nonce 的签名 (Part 4 5.6.2)
+func verifyServerSignature(serverCert, clientCert, clientNonce []byte, signature *SignatureData) error {
+	if signature.Algorithm != AlgorithmRsaSha256 {
+		return ErrSignatureInvalid
+	}
+	key, err := publicKey(serverCert)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(append(append([]byte{}, clientCert...), clientNonce...))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature.Signature); err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// GetEndpoints 通过无安全的通道查询服务器端点
+func GetEndpoints(ctx context.Context, endpoint string, timeout time.Duration) ([]*EndpointDescription, error) {
+	policy, _ := newSecurityPolicy(SecurityPolicyNone, MessageSecurityModeNone, nil, nil, nil)
+	channel, err := dialChannel(ctx, endpoint, policy, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer channel.Close()
+	return getEndpoints(ctx, channel, endpoint, timeout)
+}
+
+func getEndpoints(ctx context.Context, channel *secureChannel, endpoint string, timeout time.Duration) ([]*EndpointDescription, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp := &GetEndpointsResponse{}
+	if err := channel.SendRequest(ctx, &GetEndpointsRequest{EndpointUrl: endpoint}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Endpoints, nil
+}
+
+// selectEndpoint 按安全策略与模式选择端点, 同时要求端点接受配置的用户令牌类型
+func selectEndpoint(endpoints []*EndpointDescription, config *ClientConfig) (*EndpointDescription, *UserTokenPolicy, error) {
+	found := false
+	for _, ep := range endpoints {
+		if ep.SecurityPolicyUri != config.SecurityPolicy || ep.SecurityMode != config.SecurityMode {
+			continue
+		}
+		found = true
+		for _, token := range ep.UserIdentityTokens {
+			if token.TokenType == config.AuthMode {
+				return ep, token, nil
+			}
+		}
+	}
+	if found {
+		return nil, nil, ErrUserTokenUnsupported
+	}
+	return nil, nil, ErrEndpointNotFound
+}
+
+// Dial 查询端点, 建立安全通道, 创建并激活会话
+func Dial(ctx context.Context, config *ClientConfig) (*Client, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.SessionTimeout <= 0 {
+		config.SessionTimeout = defaultSessionTimeout
+	}
+	if config.SecurityPolicy == "" {
+		config.SecurityPolicy = SecurityPolicyNone
+	}
+	if config.SecurityMode == MessageSecurityModeInvalid {
+		config.SecurityMode = MessageSecurityModeNone
+	}
+	none, _ := newSecurityPolicy(SecurityPolicyNone, MessageSecurityModeNone, nil, nil, nil)
+	channel, err := dialChannel(ctx, config.Endpoint, none, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := getEndpoints(ctx, channel, config.Endpoint, config.Timeout)
+	if err != nil {
+		channel.Close()
+		return nil, err
+	}
+	ep, token, err := selectEndpoint(endpoints, config)
+	if err != nil {
+		channel.Close()
+		return nil, err
+	}
+	if config.SecurityPolicy != SecurityPolicyNone {
+		// 端点列表通过无安全的通道获取, 校验服务器证书后再按其重新建立安全通道
+		channel.Close()
+		if err = config.verifyServerCertificate(ep.ServerCertificate); err != nil {
+			return nil, err
+		}
+		policy, err := newSecurityPolicy(config.SecurityPolicy, config.SecurityMode, config.Certificate, config.PrivateKey, ep.ServerCertificate)
+		if err != nil {
+			return nil, err
+		}
+		if channel, err = dialChannel(ctx, config.Endpoint, policy, config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	c := &Client{config: config, channel: channel}
+	if err = c.activate(ctx, ep, token); err != nil {
+		channel.Close()
+		return nil, err
+	}
+	klog.V(2).InfoS("Opcua session activated", "endpoint", config.Endpoint, "securityPolicy", config.SecurityPolicy,
+		"securityMode", MessageSecurityModeToString[config.SecurityMode])
+	return c, nil
+}
+
+func (c *Client) applicationUri() string {
+	if len(c.config.Certificate) == 0 {
+		return defaultApplicationUri
+	}
+	cert, err := x509.ParseCertificate(c.config.Certificate)
+	if err != nil || len(cert.URIs) == 0 {
+		return defaultApplicationUri
+	}
+	return cert.URIs[0].String()
+}
+
+func (c *Client) activate(ctx context.Context, ep *EndpointDescription, token *UserTokenPolicy) error {
+	clientNonce, err := Nonce(basic256Sha256NonceLength)
+	if err != nil {
+		return err
+	}
+	create := &CreateSessionRequest{
+		ClientDescription: ApplicationDescription{
+			ApplicationUri:  c.applicationUri(),
+			ProductUri:      defaultApplicationUri,
+			ApplicationName: LocalizedText{Text: "harnsplatform broker"},
+			ApplicationType: 1,
+		},
+		EndpointUrl:             c.config.Endpoint,
+		SessionName:             "harnsplatform-" + c.config.Endpoint,
+		ClientNonce:             clientNonce,
+		RequestedSessionTimeout: float64(c.config.SessionTimeout / time.Millisecond),
+	}
+	if c.channel.policy.secure() || c.config.AuthMode == UserTokenTypeCertificate {
+		create.ClientCertificate = c.config.Certificate
+	}
+	session := &CreateSessionResponse{}
+	if err = c.call(ctx, create, session); err != nil {
+		return err
+	}
+	c.authToken = session.AuthenticationToken
+	serverCert := session.ServerCertificate
+	if len(serverCert) == 0 {
+		serverCert = ep.ServerCertificate
+	}
+
+	activate := &ActivateSessionRequest{}
+	if c.channel.policy.secure() {
+		// 会话的服务器证书须与安全通道一致, 并验证服务器对客户端Nonce的签名
+		if !sameCertificate(serverCert, c.channel.policy.remoteCert) {
+			return ErrServerCertificateUntrusted
+		}
+		if err = verifyServerSignature(serverCert, c.config.Certificate, clientNonce, &session.ServerSignature); err != nil {
+			return err
+		}
+		signature, err := RsaSha256Sign(c.config.PrivateKey, append(append([]byte{}, serverCert...), session.ServerNonce...))
+		if err != nil {
+			return err
+		}
+		activate.ClientSignature = SignatureData{Algorithm: AlgorithmRsaSha256, Signature: signature}
+	}
+	tokenPolicy := token.SecurityPolicyUri
+	if tokenPolicy == "" {
+		tokenPolicy = ep.SecurityPolicyUri
+	}
+	switch c.config.AuthMode {
+	case UserTokenTypeAnonymous:
+		activate.UserIdentityToken = NewExtensionObject(IdAnonymousIdentityToken, &AnonymousIdentityToken{PolicyId: token.PolicyId})
+	case UserTokenTypeUserName:
+		identity := &UserNameIdentityToken{PolicyId: token.PolicyId, UserName: c.config.Username, Password: []byte(c.config.Password)}
+		if tokenPolicy != SecurityPolicyNone {
+			// 长度(UInt32) + 密码 + 服务器Nonce, 使用服务器公钥加密
+			if tokenPolicy != SecurityPolicyBasic256Sha256 {
+				return ErrSecurityPolicyUnsupported
+			}
+			if err = c.config.verifyServerCertificate(serverCert); err != nil {
+				return err
+			}
+			key, err := publicKey(serverCert)
+			if err != nil {
+				return err
+			}
+			e := NewEncoder()
+			e.UInt32(uint32(len(c.config.Password) + len(session.ServerNonce)))
+			e.Write([]byte(c.config.Password))
+			e.Write(session.ServerNonce)
+			if identity.Password, err = RsaOaepEncrypt(key, e.Bytes()); err != nil {
+				return err
+			}
+			identity.EncryptionAlgorithm = AlgorithmRsaOaep
+		}
+		activate.UserIdentityToken = NewExtensionObject(IdUserNameIdentityToken, identity)
+	case UserTokenTypeCertificate:
+		if c.config.PrivateKey == nil || len(c.config.Certificate) == 0 {
+			return ErrCertificateRequired
+		}
+		signature, err := RsaSha256Sign(c.config.PrivateKey, append(append([]byte{}, serverCert...), session.ServerNonce...))
+		if err != nil {
+			return err
+		}
+		activate.UserIdentityToken = NewExtensionObject(IdX509IdentityToken, &X509IdentityToken{PolicyId: token.PolicyId, CertificateData: c.config.Certificate})
+		activate.UserTokenSignature = SignatureData{Algorithm: AlgorithmRsaSha256, Signature: signature}
+	default:
+		return ErrUserTokenUnsupported
+	}
+	return c.call(ctx, activate, &ActivateSessionResponse{})
+}
+
+// call 附加会话令牌, 未设置期限时使用配置的超时
+func (c *Client) call(ctx context.Context, req Request, resp Response) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+	req.Header().AuthenticationToken = c.authToken
+	return c.channel.SendRequest(ctx, req, resp)
+}
+
+func (c *Client) Read(ctx context.Context, nodes []*ReadValueId) ([]*DataValue, error) {
+	resp := &ReadResponse{}
+	if err := c.call(ctx, &ReadRequest{TimestampsToReturn: TimestampsBoth, NodesToRead: nodes}, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(nodes) {
+		return nil, ErrUnexpectedResponse
+	}
+	return resp.Results, nil
+}
+
+func (c *Client) Write(ctx context.Context, values []*WriteValue) ([]StatusCode, error) {
+	resp := &WriteResponse{}
+	if err := c.call(ctx, &WriteRequest{NodesToWrite: values}, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(values) {
+		return nil, ErrUnexpectedResponse
+	}
+	return resp.Results, nil
+}
+
+// Browse 浏览节点, 通过BrowseNext取完所有引用
+func (c *Client) Browse(ctx context.Context, nodes []*BrowseDescription) ([]*BrowseResult, error) {
+	resp := &BrowseResponse{}
+	req := &BrowseRequest{RequestedMaxReferencesPerNode: DefaultRequestedMaxRefCount, NodesToBrowse: nodes}
+	if err := c.call(ctx, req, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(nodes) {
+		return nil, ErrUnexpectedResponse
+	}
+	for _, result := range resp.Results {
+		for len(result.ContinuationPoint) > 0 {
+			next := &BrowseNextResponse{}
+			if err := c.call(ctx, &BrowseNextRequest{ContinuationPoints: [][]byte{result.ContinuationPoint}}, next); err != nil {
+				return nil, err
+			}
+			if len(next.Results) != 1 {
+				return nil, ErrUnexpectedResponse
+			}
+			result.StatusCode = next.Results[0].StatusCode
+			result.ContinuationPoint = next.Results[0].ContinuationPoint
+			result.References = append(result.References, next.Results[0].References...)
+		}
+	}
+	return resp.Results, nil
+}
+
+// CreateSubscription 生命周期与保活次数按会话超时推算
+func (c *Client) CreateSubscription(ctx context.Context, interval time.Duration) (*CreateSubscriptionResponse, error) {
+	keepAlive := uint32(10)
+	lifetime := uint32(c.config.SessionTimeout / interval)
+	if lifetime < keepAlive*3 {
+		lifetime = keepAlive * 3
+	}
+	resp := &CreateSubscriptionResponse{}
+	req := &CreateSubscriptionRequest{
+		RequestedPublishingInterval: float64(interval / time.Millisecond),
+		RequestedLifetimeCount:      lifetime,
+		RequestedMaxKeepAliveCount:  keepAlive,
+		PublishingEnabled:           true,
+	}
+	if err := c.call(ctx, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) CreateMonitoredItems(ctx context.Context, subscriptionId uint32, items []*MonitoredItemCreateRequest) ([]*MonitoredItemCreateResult, error) {
+	resp := &CreateMonitoredItemsResponse{}
+	req := &CreateMonitoredItemsRequest{SubscriptionId: subscriptionId, TimestampsToReturn: TimestampsBoth, ItemsToCreate: items}
+	if err := c.call(ctx, req, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(items) {
+		return nil, ErrUnexpectedResponse
+	}
+	return resp.Results, nil
+}
+
+// Publish 服务器在有通知或保活到期时才响应, 调用方的期限需大于保活周期
+func (c *Client) Publish(ctx context.Context, acks []*SubscriptionAcknowledgement) (*PublishResponse, error) {
+	resp := &PublishResponse{}
+	if err := c.call(ctx, &PublishRequest{SubscriptionAcknowledgements: acks}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) DeleteSubscriptions(ctx context.Context, subscriptionIds []uint32) error {
+	return c.call(ctx, &DeleteSubscriptionsRequest{SubscriptionIds: subscriptionIds}, &DeleteSubscriptionsResponse{})
+}
+
+// Close 关闭会话与安全通道
+func (c *Client) Close(ctx context.Context) error {
+	err := c.call(ctx, &CloseSessionRequest{DeleteSubscriptions: true}, &CloseSessionResponse{})
+	if closeErr := c.channel.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Done 安全通道关闭时关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.channel.Done()
+}
@@ -0,0 +1,322 @@
+package ua
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+/**
+OPC UA Binary编码 (Part 6 5.2)
+整数与浮点数均为小端, 字符串与字节串为Int32长度 + 内容, 长度-1表示空
+数组为Int32元素个数 + 元素, 个数-1表示空数组
+DateTime为1601-01-01起的100纳秒数
+*/
+
+// epochTicks 1601-01-01到1970-01-01的100纳秒数
+const epochTicks = 116444736000000000
+
+type Encoder struct {
+	buf []byte
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{buf: make([]byte, 0, 256)}
+}
+
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+func (e *Encoder) Len() int {
+	return len(e.buf)
+}
+
+func (e *Encoder) Write(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+func (e *Encoder) Boolean(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *Encoder) Byte(v byte) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *Encoder) SByte(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *Encoder) UInt16(v uint16) {
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, v)
+}
+
+func (e *Encoder) Int16(v int16) {
+	e.UInt16(uint16(v))
+}
+
+func (e *Encoder) UInt32(v uint32) {
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *Encoder) Int32(v int32) {
+	e.UInt32(uint32(v))
+}
+
+func (e *Encoder) UInt64(v uint64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+func (e *Encoder) Int64(v int64) {
+	e.UInt64(uint64(v))
+}
+
+func (e *Encoder) Float(v float32) {
+	e.UInt32(math.Float32bits(v))
+}
+
+func (e *Encoder) Double(v float64) {
+	e.UInt64(math.Float64bits(v))
+}
+
+func (e *Encoder) String(v string) {
+	if len(v) == 0 {
+		e.Int32(-1)
+		return
+	}
+	e.Int32(int32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *Encoder) ByteString(v []byte) {
+	if v == nil {
+		e.Int32(-1)
+		return
+	}
+	e.Int32(int32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *Encoder) DateTime(v time.Time) {
+	if v.IsZero() {
+		e.Int64(0)
+		return
+	}
+	// UnixNano只能表示1678年到2262年, 按秒与纳秒分别换算
+	e.Int64(v.Unix()*1e7 + int64(v.Nanosecond()/100) + epochTicks)
+}
+
+func (e *Encoder) Guid(v Guid) {
+	e.UInt32(v.Data1)
+	e.UInt16(v.Data2)
+	e.UInt16(v.Data3)
+	e.buf = append(e.buf, v.Data4[:]...)
+}
+
+func (e *Encoder) StringArray(v []string) {
+	if v == nil {
+		e.Int32(-1)
+		return
+	}
+	e.Int32(int32(len(v)))
+	for _, s := range v {
+		e.String(s)
+	}
+}
+
+func (e *Encoder) UInt32Array(v []uint32) {
+	if v == nil {
+		e.Int32(-1)
+		return
+	}
+	e.Int32(int32(len(v)))
+	for _, u := range v {
+		e.UInt32(u)
+	}
+}
+
+// SetUInt32 回填offset处的UInt32, 用于消息长度
+func (e *Encoder) SetUInt32(offset int, v uint32) {
+	binary.LittleEndian.PutUint32(e.buf[offset:], v)
+}
+
+// Decoder 出错后后续读取均返回零值, 由Err返回第一个错误
+type Decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b}
+}
+
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// Remaining 未读取的字节
+func (d *Decoder) Remaining() []byte {
+	return d.buf[d.pos:]
+}
+
+func (d *Decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = ErrDecodeShort
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *Decoder) Boolean() bool {
+	return d.Byte() != 0
+}
+
+func (d *Decoder) Byte() byte {
+	b := d.read(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *Decoder) SByte() int8 {
+	return int8(d.Byte())
+}
+
+func (d *Decoder) UInt16() uint16 {
+	b := d.read(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (d *Decoder) Int16() int16 {
+	return int16(d.UInt16())
+}
+
+func (d *Decoder) UInt32() uint32 {
+	b := d.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (d *Decoder) Int32() int32 {
+	return int32(d.UInt32())
+}
+
+func (d *Decoder) UInt64() uint64 {
+	b := d.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *Decoder) Int64() int64 {
+	return int64(d.UInt64())
+}
+
+func (d *Decoder) Float() float32 {
+	return math.Float32frombits(d.UInt32())
+}
+
+func (d *Decoder) Double() float64 {
+	return math.Float64frombits(d.UInt64())
+}
+
+func (d *Decoder) String() string {
+	n := d.Int32()
+	if n <= 0 {
+		return ""
+	}
+	return string(d.read(int(n)))
+}
+
+func (d *Decoder) ByteString() []byte {
+	n := d.Int32()
+	if n < 0 {
+		return nil
+	}
+	b := d.read(int(n))
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (d *Decoder) DateTime() time.Time {
+	ticks := d.Int64()
+	if ticks <= 0 || ticks == math.MaxInt64 {
+		return time.Time{}
+	}
+	ticks -= epochTicks
+	return time.Unix(ticks/1e7, ticks%1e7*100).UTC()
+}
+
+func (d *Decoder) Guid() Guid {
+	g := Guid{Data1: d.UInt32(), Data2: d.UInt16(), Data3: d.UInt16()}
+	copy(g.Data4[:], d.read(8))
+	return g
+}
+
+// ArrayLength 读取数组长度并检查剩余字节是否足够, 空数组返回0
+func (d *Decoder) ArrayLength() int {
+	n := d.Int32()
+	if n <= 0 {
+		return 0
+	}
+	if int(n) > len(d.buf)-d.pos {
+		d.err = ErrDecodeShort
+		return 0
+	}
+	return int(n)
+}
+
+func (d *Decoder) StringArray() []string {
+	n := d.ArrayLength()
+	v := make([]string, n)
+	for i := range v {
+		v[i] = d.String()
+	}
+	return v
+}
+
+func (d *Decoder) UInt32Array() []uint32 {
+	n := d.ArrayLength()
+	v := make([]uint32, n)
+	for i := range v {
+		v[i] = d.UInt32()
+	}
+	return v
+}
+
+func (d *Decoder) StatusCodeArray() []StatusCode {
+	n := d.ArrayLength()
+	v := make([]StatusCode, n)
+	for i := range v {
+		v[i] = StatusCode(d.UInt32())
+	}
+	return v
+}
+
+// DiagnosticInfoArray 诊断信息只解码不保留
+func (d *Decoder) DiagnosticInfoArray() {
+	n := d.ArrayLength()
+	for i := 0; i < n; i++ {
+		d.DiagnosticInfo()
+	}
+}
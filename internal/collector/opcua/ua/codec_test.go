@@ -0,0 +1,189 @@
+package ua
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVariantRoundTrip(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123456700, time.UTC)
+	guid, _ := ParseGuid("72962B91-FA75-4AE6-8D28-B404DC7DAF63")
+	tests := []struct {
+		name    string
+		variant *Variant
+	}{
+		{"boolean", &Variant{Type: TypeBoolean, Value: true}},
+		{"sbyte", &Variant{Type: TypeSByte, Value: int8(-8)}},
+		{"byte", &Variant{Type: TypeByte, Value: uint8(200)}},
+		{"int16", &Variant{Type: TypeInt16, Value: int16(-1234)}},
+		{"uint16", &Variant{Type: TypeUInt16, Value: uint16(65000)}},
+		{"int32", &Variant{Type: TypeInt32, Value: int32(-123456)}},
+		{"uint32", &Variant{Type: TypeUInt32, Value: uint32(4000000000)}},
+		{"int64", &Variant{Type: TypeInt64, Value: int64(-1) << 40}},
+		{"uint64", &Variant{Type: TypeUInt64, Value: uint64(1) << 63}},
+		{"float", &Variant{Type: TypeFloat, Value: float32(3.5)}},
+		{"double", &Variant{Type: TypeDouble, Value: -2.25}},
+		{"string", &Variant{Type: TypeString, Value: "温度"}},
+		{"dateTime", &Variant{Type: TypeDateTime, Value: now}},
+		{"guid", &Variant{Type: TypeGuid, Value: guid}},
+		{"byteString", &Variant{Type: TypeByteString, Value: []byte{1, 2, 3}}},
+		{"numeric nodeId", &Variant{Type: TypeNodeId, Value: NewNumericNodeId(3, 70000)}},
+		{"string nodeId", &Variant{Type: TypeNodeId, Value: NewStringNodeId(2, "Line1.Speed")}},
+		{"expandedNodeId", &Variant{Type: TypeExpandedNodeId, Value: ExpandedNodeId{NodeId: NewNumericNodeId(0, 85), NamespaceUri: "urn:test", ServerIndex: 2}}},
+		{"statusCode", &Variant{Type: TypeStatusCode, Value: StatusBadNodeIdUnknown}},
+		{"qualifiedName", &Variant{Type: TypeQualifiedName, Value: QualifiedName{Namespace: 2, Name: "Speed"}}},
+		{"localizedText", &Variant{Type: TypeLocalizedText, Value: LocalizedText{Locale: "zh-CN", Text: "速度"}}},
+		{"extensionObject", &Variant{Type: TypeExtensionObject, Value: ExtensionObject{TypeId: NewNumericNodeId(0, 321), Body: []byte{0xFF, 0xFF, 0xFF, 0xFF}}}},
+		{"int32 array", &Variant{Type: TypeInt32, Array: true, Value: []interface{}{int32(1), int32(-2), int32(3)}}},
+		{"string array", &Variant{Type: TypeString, Array: true, Value: []interface{}{"a", "b"}}},
+		{"empty array", &Variant{Type: TypeDouble, Array: true, Value: []interface{}{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEncoder()
+			e.Variant(tt.variant)
+			d := NewDecoder(e.Bytes())
+			got := d.Variant()
+			if d.Err() != nil {
+				t.Fatalf("decode: %v", d.Err())
+			}
+			if len(d.Remaining()) != 0 {
+				t.Fatalf("%d bytes left after decode", len(d.Remaining()))
+			}
+			if !reflect.DeepEqual(got, tt.variant) {
+				t.Fatalf("got %#v, want %#v", got, tt.variant)
+			}
+		})
+	}
+}
+
+func TestVariantNull(t *testing.T) {
+	e := NewEncoder()
+	e.Variant(nil)
+	if !bytes.Equal(e.Bytes(), []byte{0}) {
+		t.Fatalf("null variant encoded as %x", e.Bytes())
+	}
+	if v := NewDecoder(e.Bytes()).Variant(); v.Type != TypeNull || v.Value != nil {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+// 多维数组的维度被丢弃, 值按行展开
+func TestVariantMatrix(t *testing.T) {
+	e := NewEncoder()
+	e.Byte(byte(TypeInt16) | variantArray | variantDimensions)
+	e.Int32(4)
+	for _, v := range []int16{1, 2, 3, 4} {
+		e.Int16(v)
+	}
+	e.Int32(2)
+	e.Int32(2)
+	e.Int32(2)
+	d := NewDecoder(e.Bytes())
+	got := d.Variant()
+	want := &Variant{Type: TypeInt16, Array: true, Value: []interface{}{int16(1), int16(2), int16(3), int16(4)}}
+	if d.Err() != nil || len(d.Remaining()) != 0 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, err %v", got, d.Err())
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	tests := []struct {
+		name   string
+		b      []byte
+		decode func(d *Decoder)
+	}{
+		{"int32", []byte{1, 0, 0}, func(d *Decoder) { d.Int32() }},
+		{"string length", []byte{5, 0, 0, 0, 'a'}, func(d *Decoder) { _ = d.String() }},
+		{"array length", []byte{byte(TypeInt32) | variantArray, 100, 0, 0, 0, 1, 0, 0, 0}, func(d *Decoder) { d.Variant() }},
+		{"invalid variant type", []byte{0x3F}, func(d *Decoder) { d.Variant() }},
+		{"invalid nodeId encoding", []byte{0x0F, 0}, func(d *Decoder) { d.NodeId() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(tt.b)
+			tt.decode(d)
+			if d.Err() == nil {
+				t.Fatal("expected decode error")
+			}
+		})
+	}
+}
+
+func TestDataValueRoundTrip(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value *DataValue
+	}{
+		{"value only", &DataValue{Value: &Variant{Type: TypeDouble, Value: 1.5}}},
+		{"bad status", &DataValue{Status: StatusBadNodeIdUnknown}},
+		{"timestamps", &DataValue{Value: &Variant{Type: TypeBoolean, Value: false}, SourceTimestamp: now, ServerTimestamp: now.Add(time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEncoder()
+			e.DataValue(tt.value)
+			d := NewDecoder(e.Bytes())
+			got := d.DataValue()
+			if d.Err() != nil || !reflect.DeepEqual(got, tt.value) {
+				t.Fatalf("got %#v, err %v", got, d.Err())
+			}
+		})
+	}
+}
+
+func TestNodeIdEncoding(t *testing.T) {
+	tests := []struct {
+		id     string
+		length int
+	}{
+		{"i=85", 2},
+		{"ns=2;i=1000", 4},
+		{"ns=300;i=1", 7},
+		{"i=70000", 7},
+		{"ns=2;s=Line1.Speed", 18},
+		{"ns=1;g=72962B91-FA75-4AE6-8D28-B404DC7DAF63", 19},
+		{"ns=1;b=AQID", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			n, err := ParseNodeId(tt.id)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if n.String() != tt.id {
+				t.Fatalf("String() = %s", n.String())
+			}
+			e := NewEncoder()
+			e.NodeId(n)
+			if e.Len() != tt.length {
+				t.Fatalf("encoded length %d, want %d", e.Len(), tt.length)
+			}
+			d := NewDecoder(e.Bytes())
+			if got := d.NodeId(); d.Err() != nil || got.String() != tt.id {
+				t.Fatalf("decoded %s, err %v", got.String(), d.Err())
+			}
+		})
+	}
+}
+
+func TestParseNodeIdInvalid(t *testing.T) {
+	for _, id := range []string{"", "85", "ns=x;i=1", "ns=1", "i=abc", "x=1", "ns=1;g=1234", "ns=1;b=***"} {
+		if _, err := ParseNodeId(id); err == nil {
+			t.Errorf("ParseNodeId(%q) succeeded", id)
+		}
+	}
+}
+
+func TestDateTimeRoundTrip(t *testing.T) {
+	for _, v := range []time.Time{{}, time.Date(1601, 1, 1, 0, 0, 1, 0, time.UTC), time.Date(2038, 1, 19, 3, 14, 8, 100, time.UTC)} {
+		e := NewEncoder()
+		e.DateTime(v)
+		if got := NewDecoder(e.Bytes()).DateTime(); !got.Equal(v) {
+			t.Errorf("got %v, want %v", got, v)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package ua
+
+import "errors"
+
+var ErrDecodeShort = errors.New("opcua message too short")
+var ErrDecodeInvalid = errors.New("opcua message encoding invalid")
+var ErrNodeIdInvalid = errors.New("opcua node id invalid")
+var ErrVariantType = errors.New("opcua variant type unsupported")
+var ErrMessageType = errors.New("opcua unexpected message type")
+var ErrMessageTooLarge = errors.New("opcua message too large")
+var ErrUnexpectedResponse = errors.New("opcua unexpected response type")
+var ErrChannelClosed = errors.New("opcua secure channel closed")
+var ErrRequestTimeout = errors.New("opcua request timeout")
+var ErrSecurityPolicyUnsupported = errors.New("opcua security policy unsupported")
+var ErrSecurityModeInvalid = errors.New("opcua security mode invalid")
+var ErrSignatureInvalid = errors.New("opcua message signature invalid")
+var ErrCertificateRequired = errors.New("opcua certificate and private key required")
+var ErrEndpointNotFound = errors.New("opcua endpoint with requested security not found")
+var ErrUserTokenUnsupported = errors.New("opcua endpoint does not accept the user identity token")
+var ErrEndpointUrlInvalid = errors.New("opcua endpoint url invalid")
+var ErrServerCertificateUntrusted = errors.New("opcua server certificate untrusted")
+var ErrThumbprintInvalid = errors.New("opcua certificate thumbprint invalid")
+
+// 安全策略
+const (
+	SecurityPolicyNone           = "http://opcfoundation.org/UA/SecurityPolicy#None"
+	SecurityPolicyBasic256Sha256 = "http://opcfoundation.org/UA/SecurityPolicy#Basic256Sha256"
+)
+
+// SecurityPolicyNameToUri 配置中使用的策略名称
+var SecurityPolicyNameToUri = map[string]string{
+	"None":           SecurityPolicyNone,
+	"Basic256Sha256": SecurityPolicyBasic256Sha256,
+}
+
+type MessageSecurityMode int32
+
+const (
+	MessageSecurityModeInvalid MessageSecurityMode = iota
+	MessageSecurityModeNone
+	MessageSecurityModeSign
+	MessageSecurityModeSignAndEncrypt
+)
+
+var MessageSecurityModeToString = map[MessageSecurityMode]string{
+	MessageSecurityModeNone:           "None",
+	MessageSecurityModeSign:           "Sign",
+	MessageSecurityModeSignAndEncrypt: "SignAndEncrypt",
+}
+
+var StringToMessageSecurityMode = map[string]MessageSecurityMode{
+	"None":           MessageSecurityModeNone,
+	"Sign":           MessageSecurityModeSign,
+	"SignAndEncrypt": MessageSecurityModeSignAndEncrypt,
+}
+
+type UserTokenType int32
+
+const (
+	UserTokenTypeAnonymous UserTokenType = iota
+	UserTokenTypeUserName
+	UserTokenTypeCertificate
+	UserTokenTypeIssuedToken
+)
+
+type AttributeId uint32
+
+const (
+	AttributeNodeId      AttributeId = 1
+	AttributeNodeClass   AttributeId = 2
+	AttributeBrowseName  AttributeId = 3
+	AttributeDisplayName AttributeId = 4
+	AttributeDescription AttributeId = 5
+	AttributeValue       AttributeId = 13
+	AttributeDataType    AttributeId = 14
+	AttributeValueRank   AttributeId = 15
+	AttributeAccessLevel AttributeId = 17
+)
+
+// AccessLevel 属性的位
+const (
+	AccessLevelCurrentRead  byte = 0x01
+	AccessLevelCurrentWrite byte = 0x02
+)
+
+type NodeClass int32
+
+const (
+	NodeClassUnspecified   NodeClass = 0
+	NodeClassObject        NodeClass = 1
+	NodeClassVariable      NodeClass = 2
+	NodeClassMethod        NodeClass = 4
+	NodeClassObjectType    NodeClass = 8
+	NodeClassVariableType  NodeClass = 16
+	NodeClassReferenceType NodeClass = 32
+	NodeClassDataType      NodeClass = 64
+	NodeClassView          NodeClass = 128
+)
+
+var NodeClassToString = map[NodeClass]string{
+	NodeClassUnspecified:   "unspecified",
+	NodeClassObject:        "object",
+	NodeClassVariable:      "variable",
+	NodeClassMethod:        "method",
+	NodeClassObjectType:    "objectType",
+	NodeClassVariableType:  "variableType",
+	NodeClassReferenceType: "referenceType",
+	NodeClassDataType:      "dataType",
+	NodeClassView:          "view",
+}
+
+type TimestampsToReturn int32
+
+const (
+	TimestampsSource TimestampsToReturn = iota
+	TimestampsServer
+	TimestampsBoth
+	TimestampsNeither
+)
+
+type BrowseDirection int32
+
+const (
+	BrowseDirectionForward BrowseDirection = iota
+	BrowseDirectionInverse
+	BrowseDirectionBoth
+)
+
+type MonitoringMode int32
+
+const (
+	MonitoringModeDisabled MonitoringMode = iota
+	MonitoringModeSampling
+	MonitoringModeReporting
+)
+
+// 命名空间0中的常用节点
+const (
+	ObjectsFolder               uint32 = 85
+	HierarchicalReferences      uint32 = 33
+	ServerStatusCurrentTime     uint32 = 2258
+	BaseDataVariableType        uint32 = 63
+	BrowseResultMaskAll         uint32 = 0x3F
+	DefaultRequestedMaxRefCount uint32 = 1000
+)
+
+// 服务与结构体的Binary编码节点编号
+const (
+	IdAnonymousIdentityToken       uint32 = 321
+	IdUserNameIdentityToken        uint32 = 324
+	IdX509IdentityToken            uint32 = 327
+	IdServiceFault                 uint32 = 397
+	IdGetEndpointsRequest          uint32 = 428
+	IdGetEndpointsResponse         uint32 = 431
+	IdOpenSecureChannelRequest     uint32 = 446
+	IdOpenSecureChannelResponse    uint32 = 449
+	IdCloseSecureChannelRequest    uint32 = 452
+	IdCreateSessionRequest         uint32 = 461
+	IdCreateSessionResponse        uint32 = 464
+	IdActivateSessionRequest       uint32 = 467
+	IdActivateSessionResponse      uint32 = 470
+	IdCloseSessionRequest          uint32 = 473
+	IdCloseSessionResponse         uint32 = 476
+	IdBrowseRequest                uint32 = 527
+	IdBrowseResponse               uint32 = 530
+	IdBrowseNextRequest            uint32 = 533
+	IdBrowseNextResponse           uint32 = 536
+	IdReadRequest                  uint32 = 631
+	IdReadResponse                 uint32 = 634
+	IdWriteRequest                 uint32 = 673
+	IdWriteResponse                uint32 = 676
+	IdCreateMonitoredItemsRequest  uint32 = 751
+	IdCreateMonitoredItemsResponse uint32 = 754
+	IdCreateSubscriptionRequest    uint32 = 787
+	IdCreateSubscriptionResponse   uint32 = 790
+	IdDataChangeNotification       uint32 = 811
+	IdStatusChangeNotification     uint32 = 820
+	IdPublishRequest               uint32 = 826
+	IdPublishResponse              uint32 = 829
+	IdDeleteSubscriptionsRequest   uint32 = 847
+	IdDeleteSubscriptionsResponse  uint32 = 850
+)
+
+// 签名与加密算法
+const (
+	AlgorithmRsaSha256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgorithmRsaOaep   = "http://www.w3.org/2001/04/xmlenc#rsa-oaep"
+)
@@ -0,0 +1,315 @@
+package ua
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+)
+
+/**
+安全策略 (Part 6 6.7)
+只支持None与Basic256Sha256
+OPN报文使用非对称算法: 发送方私钥 RSA-PKCS1v15-SHA256 签名, 接收方公钥 RSA-OAEP-SHA1 加密
+MSG/CLO报文使用对称算法: HMAC-SHA256 签名, AES-256-CBC 加密, 密钥由双方Nonce经P_SHA256派生
+*/
+
+const (
+	basic256Sha256NonceLength      = 32
+	basic256Sha256SigningKeyLen    = 32
+	basic256Sha256EncryptionKeyLen = 32
+	basic256Sha256BlockSize        = aes.BlockSize
+	basic256Sha256SignatureLength  = sha256.Size
+	// rsaOaepSha1Overhead RSA-OAEP-SHA1每个明文块减少的字节数
+	rsaOaepSha1Overhead = 42
+)
+
+type symmetricKeys struct {
+	signingKey    []byte
+	encryptionKey []byte
+	iv            []byte
+}
+
+// securityPolicy 通道的安全参数, local为客户端, remote为服务器
+type securityPolicy struct {
+	uri        string
+	mode       MessageSecurityMode
+	localKey   *rsa.PrivateKey
+	localCert  []byte
+	remoteCert []byte
+	remoteKey  *rsa.PublicKey
+}
+
+func newSecurityPolicy(uri string, mode MessageSecurityMode, localCert []byte, localKey *rsa.PrivateKey, remoteCert []byte) (*securityPolicy, error) {
+	p := &securityPolicy{uri: uri, mode: mode}
+	switch uri {
+	case SecurityPolicyNone:
+		if mode != MessageSecurityModeNone {
+			return nil, ErrSecurityModeInvalid
+		}
+		return p, nil
+	case SecurityPolicyBasic256Sha256:
+		if mode != MessageSecurityModeSign && mode != MessageSecurityModeSignAndEncrypt {
+			return nil, ErrSecurityModeInvalid
+		}
+	default:
+		return nil, ErrSecurityPolicyUnsupported
+	}
+	if localKey == nil || len(localCert) == 0 {
+		return nil, ErrCertificateRequired
+	}
+	remoteKey, err := publicKey(remoteCert)
+	if err != nil {
+		return nil, err
+	}
+	p.localKey, p.localCert = localKey, localCert
+	p.remoteCert, p.remoteKey = remoteCert, remoteKey
+	return p, nil
+}
+
+// publicKey 服务器证书可能是证书链, 取第一个证书
+func publicKey(der []byte) (*rsa.PublicKey, error) {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, ErrCertificateRequired
+	}
+	key, ok := certs[0].PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrSecurityPolicyUnsupported
+	}
+	return key, nil
+}
+
+func (p *securityPolicy) secure() bool {
+	return p.uri != SecurityPolicyNone
+}
+
+func (p *securityPolicy) encrypted() bool {
+	return p.mode == MessageSecurityModeSignAndEncrypt
+}
+
+// remoteThumbprint 接收方证书的SHA1指纹
+func (p *securityPolicy) remoteThumbprint() []byte {
+	if !p.secure() {
+		return nil
+	}
+	certs, err := x509.ParseCertificates(p.remoteCert)
+	if err != nil || len(certs) == 0 {
+		return nil
+	}
+	sum := sha1.Sum(certs[0].Raw)
+	return sum[:]
+}
+
+func (p *securityPolicy) nonce() ([]byte, error) {
+	if !p.secure() {
+		return nil, nil
+	}
+	return Nonce(basic256Sha256NonceLength)
+}
+
+// Nonce 随机数
+func Nonce(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// deriveKeys 客户端密钥 P_SHA256(serverNonce, clientNonce), 服务器密钥 P_SHA256(clientNonce, serverNonce)
+func (p *securityPolicy) deriveKeys(secret, seed []byte) *symmetricKeys {
+	length := basic256Sha256SigningKeyLen + basic256Sha256EncryptionKeyLen + basic256Sha256BlockSize
+	b := pSha256(secret, seed, length)
+	return &symmetricKeys{
+		signingKey:    b[:basic256Sha256SigningKeyLen],
+		encryptionKey: b[basic256Sha256SigningKeyLen : basic256Sha256SigningKeyLen+basic256Sha256EncryptionKeyLen],
+		iv:            b[basic256Sha256SigningKeyLen+basic256Sha256EncryptionKeyLen:],
+	}
+}
+
+// pSha256 RFC 5246 P_hash
+func pSha256(secret, seed []byte, length int) []byte {
+	out := make([]byte, 0, length+sha256.Size)
+	a := seed
+	for len(out) < length {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(a)
+		a = mac.Sum(nil)
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(seed)
+		out = mac.Sum(out)
+	}
+	return out[:length]
+}
+
+// 非对称算法
+
+func (p *securityPolicy) asymmetricSign(b []byte) ([]byte, error) {
+	return RsaSha256Sign(p.localKey, b)
+}
+
+func (p *securityPolicy) asymmetricVerify(b, signature []byte) error {
+	sum := sha256.Sum256(b)
+	if err := rsa.VerifyPKCS1v15(p.remoteKey, crypto.SHA256, sum[:], signature); err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// asymmetricSizes 加密时的明文块与密文块大小, 使用接收方公钥
+func (p *securityPolicy) asymmetricSizes() (plain, cipher int) {
+	cipher = p.remoteKey.Size()
+	return cipher - rsaOaepSha1Overhead, cipher
+}
+
+func (p *securityPolicy) asymmetricEncrypt(b []byte) ([]byte, error) {
+	return RsaOaepEncrypt(p.remoteKey, b)
+}
+
+func (p *securityPolicy) asymmetricDecrypt(b []byte) ([]byte, error) {
+	return RsaOaepDecrypt(p.localKey, b)
+}
+
+// RsaSha256Sign 会话与用户令牌签名同样使用RSA-PKCS1v15-SHA256
+func RsaSha256Sign(key *rsa.PrivateKey, b []byte) ([]byte, error) {
+	sum := sha256.Sum256(b)
+	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+}
+
+// RsaOaepEncrypt 按明文块分段加密, 用户密码加密同样使用
+func RsaOaepEncrypt(key *rsa.PublicKey, b []byte) ([]byte, error) {
+	size := key.Size() - rsaOaepSha1Overhead
+	out := make([]byte, 0, (len(b)/size+1)*key.Size())
+	for i := 0; i < len(b); i += size {
+		end := i + size
+		if end > len(b) {
+			end = len(b)
+		}
+		block, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, b[i:end], nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, block...)
+	}
+	return out, nil
+}
+
+// RsaOaepDecrypt 按密文块分段解密
+func RsaOaepDecrypt(key *rsa.PrivateKey, b []byte) ([]byte, error) {
+	size := key.Size()
+	if len(b)%size != 0 {
+		return nil, ErrDecodeInvalid
+	}
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i += size {
+		block, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, b[i:i+size], nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, block...)
+	}
+	return out, nil
+}
+
+// 对称算法
+
+func symmetricSign(keys *symmetricKeys, b []byte) []byte {
+	mac := hmac.New(sha256.New, keys.signingKey)
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+func symmetricVerify(keys *symmetricKeys, b, signature []byte) error {
+	if subtle.ConstantTimeCompare(symmetricSign(keys, b), signature) != 1 {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+func symmetricEncrypt(keys *symmetricKeys, b []byte) ([]byte, error) {
+	block, err := aes.NewCipher(keys.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(b)%block.BlockSize() != 0 {
+		return nil, ErrDecodeInvalid
+	}
+	out := make([]byte, len(b))
+	cipher.NewCBCEncrypter(block, keys.iv).CryptBlocks(out, b)
+	return out, nil
+}
+
+func symmetricDecrypt(keys *symmetricKeys, b []byte) ([]byte, error) {
+	block, err := aes.NewCipher(keys.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(b)%block.BlockSize() != 0 {
+		return nil, ErrDecodeInvalid
+	}
+	out := make([]byte, len(b))
+	cipher.NewCBCDecrypter(block, keys.iv).CryptBlocks(out, b)
+	return out, nil
+}
+
+// padding 填充长度字节 + 填充, 每个填充字节等于填充长度的低字节, extra时追加高字节
+func padding(size int, extra bool) []byte {
+	b := make([]byte, 0, size+2)
+	b = append(b, byte(size))
+	for i := 0; i < size; i++ {
+		b = append(b, byte(size))
+	}
+	if extra {
+		b = append(b, byte(size>>8))
+	}
+	return b
+}
+
+// paddingSize 使 已有长度 + 填充 + 签名 为块大小的整数倍
+func paddingSize(length, signature, block int, extra bool) int {
+	overhead := 1
+	if extra {
+		overhead = 2
+	}
+	remainder := (length + overhead + signature) % block
+	if remainder == 0 {
+		return 0
+	}
+	return block - remainder
+}
+
+// unpad 去掉签名前的填充, 返回填充的总长度
+func unpad(b []byte, extra bool) (int, error) {
+	if len(b) == 0 {
+		return 0, ErrDecodeInvalid
+	}
+	size := int(b[len(b)-1])
+	overhead := 1
+	if extra {
+		if len(b) < 2 {
+			return 0, ErrDecodeInvalid
+		}
+		size = size<<8 | int(b[len(b)-2])
+		overhead = 2
+	}
+	if size+overhead > len(b) {
+		return 0, ErrDecodeInvalid
+	}
+	return size + overhead, nil
+}
+
+func putUint32(b []byte, offset int, v uint32) {
+	binary.LittleEndian.PutUint32(b[offset:], v)
+}
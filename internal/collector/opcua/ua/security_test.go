@@ -0,0 +1,292 @@
+package ua
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	der  []byte
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+// newTestCertificate parent为空时自签名, isCA时可签发其它证书
+func newTestCertificate(t *testing.T, name string, parent *testCertificate, isCA bool) *testCertificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, _ := url.Parse("urn:harnsplatform:test:" + name)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		URIs:                  []*url.URL{uri},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{der: der, cert: cert, key: key}
+}
+
+// RFC 5246 P_SHA256 测试向量
+func TestPSha256(t *testing.T) {
+	secret, _ := hex.DecodeString("9bbe436ba940f017b17652849a71db35")
+	seed, _ := hex.DecodeString("a0ba9f936cda311827a6f796ffd5198c")
+	want, _ := hex.DecodeString("e3f229ba727be17b8d122620557cd453c2aab21d07c3d495329b52d4e61edb5a" +
+		"6b301791e90d35c9c9a46b4e14baf9af0fa022f7077def17abfd3797c0564bab" +
+		"4fbc91666e9def9b97fce34f796789baa48082d122ee42c5a72e5a5110fff701" +
+		"87347b66")
+	seed = append([]byte("test label"), seed...)
+	for _, length := range []int{16, 32, 80, 100} {
+		if got := pSha256(secret, seed, length); !bytes.Equal(got, want[:length]) {
+			t.Errorf("length %d: got %x", length, got)
+		}
+	}
+}
+
+func TestDeriveKeys(t *testing.T) {
+	p := &securityPolicy{uri: SecurityPolicyBasic256Sha256}
+	clientNonce, serverNonce := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	client := p.deriveKeys(serverNonce, clientNonce)
+	server := p.deriveKeys(clientNonce, serverNonce)
+	if len(client.signingKey) != 32 || len(client.encryptionKey) != 32 || len(client.iv) != 16 {
+		t.Fatalf("unexpected key lengths %d %d %d", len(client.signingKey), len(client.encryptionKey), len(client.iv))
+	}
+	if bytes.Equal(client.signingKey, server.signingKey) {
+		t.Fatal("client and server keys must differ")
+	}
+	all := pSha256(serverNonce, clientNonce, 80)
+	if !bytes.Equal(append(append(append([]byte{}, client.signingKey...), client.encryptionKey...), client.iv...), all) {
+		t.Fatal("keys are not the P_SHA256 output split in order")
+	}
+}
+
+func TestPadding(t *testing.T) {
+	tests := []struct {
+		name      string
+		length    int
+		signature int
+		block     int
+		extra     bool
+	}{
+		{"aligned", 15, 0, 16, false},
+		{"symmetric", 100, 32, 16, false},
+		{"symmetric one short", 14, 0, 16, false},
+		{"asymmetric 2048", 300, 256, 214, false},
+		{"asymmetric 4096", 300, 512, 470, true},
+		{"asymmetric 4096 large padding", 1, 512, 470, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := paddingSize(tt.length, tt.signature, tt.block, tt.extra)
+			pad := padding(size, tt.extra)
+			overhead := 1
+			if tt.extra {
+				overhead = 2
+			}
+			if len(pad) != size+overhead {
+				t.Fatalf("padding length %d, want %d", len(pad), size+overhead)
+			}
+			if (tt.length+len(pad)+tt.signature)%tt.block != 0 {
+				t.Fatalf("%d + %d + %d is not a multiple of %d", tt.length, len(pad), tt.signature, tt.block)
+			}
+			for _, b := range pad[:size+1] {
+				if b != byte(size) {
+					t.Fatalf("padding byte %d, want %d", b, byte(size))
+				}
+			}
+			message := append(bytes.Repeat([]byte{0xAA}, tt.length), pad...)
+			n, err := unpad(message, tt.extra)
+			if err != nil || n != len(pad) {
+				t.Fatalf("unpad = %d, %v, want %d", n, err, len(pad))
+			}
+		})
+	}
+}
+
+func TestUnpadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		b     []byte
+		extra bool
+	}{
+		{"empty", nil, false},
+		{"size exceeds message", []byte{5, 5, 5}, false},
+		{"extra too short", []byte{0}, true},
+		{"extra size exceeds message", []byte{1, 1, 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := unpad(tt.b, tt.extra); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestSymmetricRoundTrip(t *testing.T) {
+	p := &securityPolicy{uri: SecurityPolicyBasic256Sha256}
+	keys := p.deriveKeys([]byte("server nonce"), []byte("client nonce"))
+	plain := bytes.Repeat([]byte("0123456789abcdef"), 4)
+	encrypted, err := symmetricEncrypt(keys, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := symmetricDecrypt(keys, encrypted)
+	if err != nil || !bytes.Equal(decrypted, plain) {
+		t.Fatalf("decrypted %x, err %v", decrypted, err)
+	}
+	if _, err = symmetricEncrypt(keys, plain[:15]); err == nil {
+		t.Fatal("expected error for partial block")
+	}
+	signature := symmetricSign(keys, plain)
+	if err = symmetricVerify(keys, plain, signature); err != nil {
+		t.Fatal(err)
+	}
+	plain[0] ^= 1
+	if err = symmetricVerify(keys, plain, signature); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("got %v, want %v", err, ErrSignatureInvalid)
+	}
+}
+
+func TestRsaOaepRoundTrip(t *testing.T) {
+	cert := newTestCertificate(t, "oaep", nil, false)
+	for _, length := range []int{0, 1, 213, 214, 500} {
+		plain := bytes.Repeat([]byte{0x5A}, length)
+		encrypted, err := RsaOaepEncrypt(&cert.key.PublicKey, plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(encrypted)%256 != 0 {
+			t.Fatalf("length %d: cipher length %d", length, len(encrypted))
+		}
+		decrypted, err := RsaOaepDecrypt(cert.key, encrypted)
+		if err != nil || !bytes.Equal(decrypted, plain) {
+			t.Fatalf("length %d: decrypted %d bytes, err %v", length, len(decrypted), err)
+		}
+	}
+}
+
+func TestParseThumbprint(t *testing.T) {
+	sum := sha1.Sum([]byte("certificate"))
+	lower := hex.EncodeToString(sum[:])
+	var colons, spaces []string
+	for i := 0; i < len(lower); i += 2 {
+		colons = append(colons, strings.ToUpper(lower[i:i+2]))
+		spaces = append(spaces, lower[i:i+2])
+	}
+	tests := []struct {
+		name string
+		s    string
+		err  error
+	}{
+		{"lower", lower, nil},
+		{"colons", strings.Join(colons, ":"), nil},
+		{"spaces", strings.Join(spaces, " "), nil},
+		{"too short", lower[:38], ErrThumbprintInvalid},
+		{"not hex", "zz" + lower[2:], ErrThumbprintInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ParseThumbprint(tt.s)
+			if err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err == nil && !bytes.Equal(b, sum[:]) {
+				t.Fatalf("got %x, want %x", b, sum)
+			}
+		})
+	}
+}
+
+func TestVerifyServerCertificate(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil, true)
+	server := newTestCertificate(t, "server", ca, false)
+	other := newTestCertificate(t, "other", nil, false)
+	thumbprint := sha1.Sum(server.der)
+	otherThumbprint := sha1.Sum(other.der)
+	chain := append(append([]byte{}, server.der...), ca.der...)
+
+	tests := []struct {
+		name   string
+		config *ClientConfig
+		cert   []byte
+		err    error
+	}{
+		{"nothing trusted", &ClientConfig{}, server.der, ErrServerCertificateUntrusted},
+		{"thumbprint", &ClientConfig{ServerThumbprint: thumbprint[:]}, server.der, nil},
+		{"thumbprint of chain leaf", &ClientConfig{ServerThumbprint: thumbprint[:]}, chain, nil},
+		{"other thumbprint", &ClientConfig{ServerThumbprint: otherThumbprint[:]}, server.der, ErrServerCertificateUntrusted},
+		{"pinned certificate", &ClientConfig{TrustedCertificates: [][]byte{other.der, server.der}}, server.der, nil},
+		{"issued by trusted ca", &ClientConfig{TrustedCertificates: [][]byte{ca.der}}, server.der, nil},
+		{"other certificate", &ClientConfig{TrustedCertificates: [][]byte{other.der}}, server.der, ErrServerCertificateUntrusted},
+		{"self signed not pinned", &ClientConfig{TrustedCertificates: [][]byte{ca.der}}, other.der, ErrServerCertificateUntrusted},
+		{"garbage", &ClientConfig{TrustedCertificates: [][]byte{ca.der}}, []byte{1, 2, 3}, ErrServerCertificateUntrusted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.verifyServerCertificate(tt.cert); err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyServerSignature(t *testing.T) {
+	server := newTestCertificate(t, "server", nil, false)
+	other := newTestCertificate(t, "other", nil, false)
+	clientCert, clientNonce := []byte("client certificate"), bytes.Repeat([]byte{7}, 32)
+	sign := func(key *rsa.PrivateKey, b []byte) []byte {
+		signature, err := RsaSha256Sign(key, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+	signed := append(append([]byte{}, clientCert...), clientNonce...)
+	tests := []struct {
+		name      string
+		signature SignatureData
+		err       error
+	}{
+		{"valid", SignatureData{Algorithm: AlgorithmRsaSha256, Signature: sign(server.key, signed)}, nil},
+		{"missing", SignatureData{}, ErrSignatureInvalid},
+		{"wrong algorithm", SignatureData{Algorithm: AlgorithmRsaOaep, Signature: sign(server.key, signed)}, ErrSignatureInvalid},
+		{"other key", SignatureData{Algorithm: AlgorithmRsaSha256, Signature: sign(other.key, signed)}, ErrSignatureInvalid},
+		{"server nonce signed", SignatureData{Algorithm: AlgorithmRsaSha256, Signature: sign(server.key, append(append([]byte{}, clientCert...), 8))}, ErrSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyServerSignature(server.der, clientCert, clientNonce, &tt.signature); err != tt.err {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,466 @@
+package ua
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"k8s.io/klog/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+/**
+进程内的OPC UA服务器, 用于测试与联调
+端点: None, 配置证书与私钥时增加Basic256Sha256的Sign与SignAndEncrypt
+用户: 匿名、证书, 配置Users时可使用用户名密码, 有证书时密码须按Basic256Sha256加密
+地址空间只有Objects文件夹下的对象与变量, 引用均为正向的层级引用
+订阅在每个发布周期检查监控节点的值是否变化, 无变化时按保活次数返回空通知
+安全通道与分块复用客户端的secureChannel, local为服务器, remote为客户端
+*/
+
+const (
+	serverTimeout = 10 * time.Second
+	// minPublishingInterval 订阅发布周期的下限
+	minPublishingInterval = 10 * time.Millisecond
+	defaultKeepAliveCount = 10
+
+	referenceOrganizes    uint32 = 35
+	referenceHasComponent uint32 = 47
+	baseObjectType        uint32 = 58
+)
+
+// ServerNode 地址空间中的对象或变量
+type ServerNode struct {
+	NodeId      NodeId
+	BrowseName  string
+	NodeClass   NodeClass
+	DataType    TypeId
+	AccessLevel byte
+
+	value    *Variant
+	version  uint64
+	children []*ServerNode
+}
+
+type Server struct {
+	// Certificate 服务器证书(DER), 与PrivateKey均为空时只提供None端点
+	Certificate []byte
+	PrivateKey  *rsa.PrivateKey
+	// Users 用户名 => 密码
+	Users map[string]string
+
+	mu       sync.Mutex
+	nodes    map[string]*ServerNode
+	sessions map[string]*serverSession
+	channels map[*serverChannel]struct{}
+	listener net.Listener
+	lastId   uint32
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type serverChannel struct {
+	*secureChannel
+	// nextTokenId 续订后的令牌, 客户端开始使用后服务器才切换发送密钥
+	nextTokenId uint32
+	nextKeys    *symmetricKeys
+}
+
+func NewServer(cert []byte, key *rsa.PrivateKey) *Server {
+	objects := &ServerNode{NodeId: NewNumericNodeId(0, ObjectsFolder), BrowseName: "Objects", NodeClass: NodeClassObject}
+	return &Server{
+		Certificate: cert,
+		PrivateKey:  key,
+		Users:       make(map[string]string),
+		nodes:       map[string]*ServerNode{objects.NodeId.String(): objects},
+		sessions:    make(map[string]*serverSession),
+		channels:    make(map[*serverChannel]struct{}),
+	}
+}
+
+func (s *Server) nextId() uint32 {
+	s.lastId++
+	return s.lastId
+}
+
+func (s *Server) secure() bool {
+	return len(s.Certificate) > 0 && s.PrivateKey != nil
+}
+
+// AddObject 在parent下添加对象节点
+func (s *Server) AddObject(parent, id NodeId, browseName string) error {
+	return s.addNode(parent, &ServerNode{NodeId: id, BrowseName: browseName, NodeClass: NodeClassObject})
+}
+
+// AddVariable 在parent下添加变量节点, 数据类型为初始值的内置类型
+func (s *Server) AddVariable(parent, id NodeId, browseName string, value *Variant, accessLevel byte) error {
+	return s.addNode(parent, &ServerNode{NodeId: id, BrowseName: browseName, NodeClass: NodeClassVariable,
+		DataType: value.Type, AccessLevel: accessLevel, value: value, version: 1})
+}
+
+func (s *Server) addNode(parent NodeId, node *ServerNode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.nodes[parent.String()]
+	if !ok || p.NodeClass != NodeClassObject {
+		return StatusBadNodeIdUnknown
+	}
+	if _, exist := s.nodes[node.NodeId.String()]; exist || node.NodeId.IsNull() {
+		return StatusBadNodeIdInvalid
+	}
+	s.nodes[node.NodeId.String()] = node
+	p.children = append(p.children, node)
+	return nil
+}
+
+// SetValue 修改变量的值, 订阅在下一个发布周期上报
+func (s *Server) SetValue(id NodeId, value *Variant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.nodes[id.String()]
+	if !ok || node.NodeClass != NodeClassVariable {
+		return StatusBadNodeIdUnknown
+	}
+	node.value = value
+	node.version++
+	return nil
+}
+
+// Value 变量的当前值, 节点不存在时返回nil
+func (s *Server) Value(id NodeId) *Variant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if node, ok := s.nodes[id.String()]; ok {
+		return node.value
+	}
+	return nil
+}
+
+// Serve 接受连接直到listener关闭
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close 关闭listener与全部连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for sc := range s.channels {
+		sc.close(ErrChannelClosed)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	sc := &serverChannel{secureChannel: &secureChannel{
+		conn:       conn,
+		timeout:    serverTimeout,
+		seqNum:     1,
+		remoteKeys: make(map[uint32]*symmetricKeys),
+		pending:    make(map[uint32]chan *response),
+		closed:     make(chan struct{}),
+	}}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	s.channels[sc] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		sc.close(ErrChannelClosed)
+		s.mu.Lock()
+		delete(s.channels, sc)
+		// 会话不跨连接保留
+		for token, session := range s.sessions {
+			if session.channel == sc {
+				delete(s.sessions, token)
+			}
+		}
+		s.mu.Unlock()
+	}()
+
+	if err := sc.acknowledge(); err != nil {
+		klog.V(4).InfoS("Opcua server handshake failed", "remote", conn.RemoteAddr().String(), "err", err)
+		return
+	}
+	partial := make(map[uint32][]byte)
+	for {
+		chunk, err := sc.readChunk()
+		if err != nil {
+			return
+		}
+		var requestId uint32
+		var body []byte
+		switch string(chunk[:3]) {
+		case "OPN":
+			requestId, body, err = s.openAsymmetric(sc, chunk)
+		case "MSG":
+			if sc.policy == nil {
+				err = StatusBadTcpSecureChannelUnknown
+				break
+			}
+			requestId, body, err = sc.openSymmetric(chunk)
+			if err == nil {
+				sc.switchToken(binary.LittleEndian.Uint32(chunk[secureHeaderLength:]))
+			}
+		case "CLO":
+			return
+		default:
+			err = ErrMessageType
+		}
+		if err != nil {
+			sc.writeError(err)
+			return
+		}
+		switch chunk[3] {
+		case chunkIntermediate:
+			partial[requestId] = append(partial[requestId], body...)
+			if len(partial[requestId]) > defaultMaxMessageSize {
+				sc.writeError(ErrMessageTooLarge)
+				return
+			}
+			continue
+		case chunkAbort:
+			delete(partial, requestId)
+			continue
+		}
+		message := append(partial[requestId], body...)
+		delete(partial, requestId)
+		if string(chunk[:3]) == "OPN" {
+			err = s.openChannel(sc, requestId, message)
+		} else {
+			err = s.handle(sc, requestId, message)
+		}
+		if err != nil {
+			sc.writeError(err)
+			return
+		}
+	}
+}
+
+// acknowledge 读取HEL, 发送时遵守客户端的接收缓冲区
+func (sc *serverChannel) acknowledge() error {
+	_ = sc.conn.SetReadDeadline(time.Now().Add(sc.timeout))
+	defer func() {
+		_ = sc.conn.SetReadDeadline(time.Time{})
+	}()
+	chunk, err := sc.readChunk()
+	if err != nil {
+		return err
+	}
+	if string(chunk[:3]) != "HEL" {
+		return ErrMessageType
+	}
+	d := NewDecoder(chunk[messageHeaderLength:])
+	d.UInt32() // ProtocolVersion
+	sc.remote.ReceiveBufferSize = d.UInt32()
+	sc.remote.SendBufferSize = d.UInt32()
+	sc.remote.MaxMessageSize = d.UInt32()
+	sc.remote.MaxChunkCount = d.UInt32()
+	sc.endpoint = d.String()
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if sc.remote.ReceiveBufferSize < 8192 {
+		return ErrDecodeInvalid
+	}
+	if sc.remote.ReceiveBufferSize > defaultBufferSize {
+		sc.remote.ReceiveBufferSize = defaultBufferSize
+	}
+	e := NewEncoder()
+	e.Write([]byte("ACKF"))
+	e.UInt32(0)
+	e.UInt32(0) // ProtocolVersion
+	e.UInt32(defaultBufferSize)
+	e.UInt32(defaultBufferSize)
+	e.UInt32(defaultMaxMessageSize)
+	e.UInt32(0) // MaxChunkCount 不限制
+	b := e.Bytes()
+	putUint32(b, 4, uint32(len(b)))
+	_, err = sc.conn.Write(b)
+	return err
+}
+
+// writeError 发送ERR后由调用方关闭连接
+func (sc *serverChannel) writeError(err error) {
+	var status StatusCode
+	switch {
+	case errors.As(err, &status):
+	case errors.Is(err, ErrSignatureInvalid):
+		status = StatusBadSecurityChecksFailed
+	case errors.Is(err, ErrMessageTooLarge):
+		status = StatusBadTcpMessageTooLarge
+	default:
+		status = StatusBadCommunicationError
+	}
+	e := NewEncoder()
+	e.Write([]byte("ERRF"))
+	e.UInt32(0)
+	e.UInt32(uint32(status))
+	e.String(err.Error())
+	b := e.Bytes()
+	putUint32(b, 4, uint32(len(b)))
+	sc.writeMu.Lock()
+	_ = sc.conn.SetWriteDeadline(time.Now().Add(sc.timeout))
+	_, _ = sc.conn.Write(b)
+	sc.writeMu.Unlock()
+}
+
+// openAsymmetric 第一个OPN按发送方证书建立安全策略, 续订时策略不变
+func (s *Server) openAsymmetric(sc *serverChannel, chunk []byte) (uint32, []byte, error) {
+	d := NewDecoder(chunk[secureHeaderLength:])
+	uri := d.String()
+	senderCert := d.ByteString()
+	thumbprint := d.ByteString()
+	if d.Err() != nil {
+		return 0, nil, d.Err()
+	}
+	if sc.policy == nil {
+		var err error
+		switch {
+		case uri == SecurityPolicyNone:
+			sc.policy, err = newSecurityPolicy(uri, MessageSecurityModeNone, nil, nil, nil)
+		case s.secure():
+			// 模式在请求体中, 先按Sign解密, 收到请求后再设置
+			sc.policy, err = newSecurityPolicy(uri, MessageSecurityModeSign, s.Certificate, s.PrivateKey, senderCert)
+		default:
+			err = StatusBadSecurityPolicyRejected
+		}
+		if err != nil {
+			if !errors.As(err, new(StatusCode)) {
+				err = StatusBadSecurityPolicyRejected
+			}
+			return 0, nil, err
+		}
+	} else if uri != sc.policy.uri {
+		return 0, nil, StatusBadSecurityPolicyRejected
+	}
+	if sc.policy.secure() {
+		sum := sha1.Sum(firstCertificate(s.Certificate))
+		if !bytes.Equal(thumbprint, sum[:]) || !bytes.Equal(senderCert, sc.policy.remoteCert) {
+			return 0, nil, StatusBadCertificateInvalid
+		}
+	}
+	return sc.secureChannel.openAsymmetric(chunk)
+}
+
+func firstCertificate(der []byte) []byte {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil || len(certs) == 0 {
+		return der
+	}
+	return certs[0].Raw
+}
+
+// openChannel 签发或续订令牌, 续订的令牌在客户端使用后生效
+func (s *Server) openChannel(sc *serverChannel, requestId uint32, message []byte) error {
+	d := NewDecoder(message)
+	if !isNumericNodeId(d.NodeId(), IdOpenSecureChannelRequest) {
+		return StatusBadServiceUnsupported
+	}
+	req := &OpenSecureChannelRequest{}
+	req.Decode(d)
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if (req.SecurityMode == MessageSecurityModeNone) == sc.policy.secure() || req.SecurityMode > MessageSecurityModeSignAndEncrypt {
+		return StatusBadSecurityPolicyRejected
+	}
+	serverNonce, err := sc.policy.nonce()
+	if err != nil {
+		return err
+	}
+	lifetime := req.RequestedLifetime
+	if lifetime == 0 {
+		lifetime = uint32(defaultChannelLifetime / time.Millisecond)
+	}
+
+	s.mu.Lock()
+	tokenId := s.nextId()
+	s.mu.Unlock()
+	sc.writeMu.Lock()
+	if req.RequestType == SecurityTokenIssue {
+		if sc.channelId != 0 {
+			sc.writeMu.Unlock()
+			return StatusBadSecureChannelIdInvalid
+		}
+		s.mu.Lock()
+		sc.channelId = s.nextId()
+		s.mu.Unlock()
+		sc.policy.mode = req.SecurityMode
+	}
+	if sc.policy.secure() {
+		sc.mu.Lock()
+		sc.remoteKeys[tokenId] = sc.policy.deriveKeys(serverNonce, req.ClientNonce)
+		sc.mu.Unlock()
+	}
+	localKeys := sc.policy.deriveKeys(req.ClientNonce, serverNonce)
+	if req.RequestType == SecurityTokenIssue {
+		sc.tokenId, sc.localKeys = tokenId, localKeys
+	} else {
+		sc.nextTokenId, sc.nextKeys = tokenId, localKeys
+	}
+	sc.writeMu.Unlock()
+
+	resp := &OpenSecureChannelResponse{
+		SecurityToken: ChannelSecurityToken{ChannelId: sc.channelId, TokenId: tokenId, CreatedAt: time.Now(), RevisedLifetime: lifetime},
+		ServerNonce:   serverNonce,
+	}
+	resp.RequestHandle = req.RequestHandle
+	return sc.reply("OPN", requestId, resp)
+}
+
+// switchToken 客户端使用续订的令牌后, 服务器发送也改用新令牌
+func (sc *serverChannel) switchToken(tokenId uint32) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	if sc.nextKeys != nil && tokenId == sc.nextTokenId {
+		sc.tokenId, sc.localKeys = sc.nextTokenId, sc.nextKeys
+		sc.nextKeys = nil
+	}
+}
+
+type serverResponse interface {
+	Encodable
+	TypeId() uint32
+	Header() *ResponseHeader
+}
+
+func (sc *serverChannel) reply(messageType string, requestId uint32, resp serverResponse) error {
+	resp.Header().Timestamp = time.Now()
+	e := NewEncoder()
+	e.NodeId(NewNumericNodeId(0, resp.TypeId()))
+	resp.Encode(e)
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	return sc.write(messageType, requestId, e.Bytes())
+}
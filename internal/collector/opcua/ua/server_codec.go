@@ -0,0 +1,365 @@
+package ua
+
+/**
+进程内服务器使用的编解码, 请求只解码, 响应只编码
+与services.go中客户端的编解码对应
+*/
+
+func (h *RequestHeader) Decode(d *Decoder) {
+	h.AuthenticationToken = d.NodeId()
+	h.Timestamp = d.DateTime()
+	h.RequestHandle = d.UInt32()
+	d.UInt32()     // ReturnDiagnostics
+	_ = d.String() // AuditEntryId
+	h.TimeoutHint = d.UInt32()
+	d.ExtensionObject()
+}
+
+func (h *ResponseHeader) Encode(e *Encoder) {
+	e.DateTime(h.Timestamp)
+	e.UInt32(h.RequestHandle)
+	e.UInt32(uint32(h.ServiceResult))
+	e.Byte(0)          // ServiceDiagnostics
+	e.StringArray(nil) // StringTable
+	e.ExtensionObject(ExtensionObject{})
+}
+
+func (r *ServiceFault) Encode(e *Encoder) { r.ResponseHeader.Encode(e) }
+
+func (u *UserTokenPolicy) Encode(e *Encoder) {
+	e.String(u.PolicyId)
+	e.Int32(int32(u.TokenType))
+	e.String("") // IssuedTokenType
+	e.String("") // IssuerEndpointUrl
+	e.String(u.SecurityPolicyUri)
+}
+
+func (ep *EndpointDescription) Encode(e *Encoder) {
+	e.String(ep.EndpointUrl)
+	ep.Server.Encode(e)
+	e.ByteString(ep.ServerCertificate)
+	e.Int32(int32(ep.SecurityMode))
+	e.String(ep.SecurityPolicyUri)
+	e.Int32(int32(len(ep.UserIdentityTokens)))
+	for _, token := range ep.UserIdentityTokens {
+		token.Encode(e)
+	}
+	e.String(ep.TransportProfileUri)
+	e.Byte(ep.SecurityLevel)
+}
+
+func encodeEndpoints(e *Encoder, endpoints []*EndpointDescription) {
+	e.Int32(int32(len(endpoints)))
+	for _, ep := range endpoints {
+		ep.Encode(e)
+	}
+}
+
+func (r *GetEndpointsRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.EndpointUrl = d.String()
+	d.StringArray()
+	d.StringArray()
+}
+
+func (r *GetEndpointsResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	encodeEndpoints(e, r.Endpoints)
+}
+
+func (r *OpenSecureChannelRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	d.UInt32() // ClientProtocolVersion
+	r.RequestType = SecurityTokenRequestType(d.Int32())
+	r.SecurityMode = MessageSecurityMode(d.Int32())
+	r.ClientNonce = d.ByteString()
+	r.RequestedLifetime = d.UInt32()
+}
+
+func (r *OpenSecureChannelResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.UInt32(0) // ServerProtocolVersion
+	e.UInt32(r.SecurityToken.ChannelId)
+	e.UInt32(r.SecurityToken.TokenId)
+	e.DateTime(r.SecurityToken.CreatedAt)
+	e.UInt32(r.SecurityToken.RevisedLifetime)
+	e.ByteString(r.ServerNonce)
+}
+
+func (r *CreateSessionRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.ClientDescription.Decode(d)
+	_ = d.String() // ServerUri
+	r.EndpointUrl = d.String()
+	r.SessionName = d.String()
+	r.ClientNonce = d.ByteString()
+	r.ClientCertificate = d.ByteString()
+	r.RequestedSessionTimeout = d.Double()
+	d.UInt32() // MaxResponseMessageSize
+}
+
+func (r *CreateSessionResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.NodeId(r.SessionId)
+	e.NodeId(r.AuthenticationToken)
+	e.Double(r.RevisedSessionTimeout)
+	e.ByteString(r.ServerNonce)
+	e.ByteString(r.ServerCertificate)
+	encodeEndpoints(e, r.ServerEndpoints)
+	e.Int32(-1) // ServerSoftwareCertificates
+	r.ServerSignature.Encode(e)
+	e.UInt32(0) // MaxRequestMessageSize 不限制
+}
+
+func (t *AnonymousIdentityToken) Decode(d *Decoder) {
+	t.PolicyId = d.String()
+}
+
+func (t *UserNameIdentityToken) Decode(d *Decoder) {
+	t.PolicyId = d.String()
+	t.UserName = d.String()
+	t.Password = d.ByteString()
+	t.EncryptionAlgorithm = d.String()
+}
+
+func (t *X509IdentityToken) Decode(d *Decoder) {
+	t.PolicyId = d.String()
+	t.CertificateData = d.ByteString()
+}
+
+func (r *ActivateSessionRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.ClientSignature.Decode(d)
+	for i, n := 0, d.ArrayLength(); i < n; i++ {
+		// ClientSoftwareCertificates
+		d.ByteString()
+		d.ByteString()
+	}
+	d.StringArray() // LocaleIds
+	r.UserIdentityToken = d.ExtensionObject()
+	r.UserTokenSignature.Decode(d)
+}
+
+func (r *ActivateSessionResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.ByteString(r.ServerNonce)
+	encodeStatusCodes(e, r.Results)
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func (r *CloseSessionRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.DeleteSubscriptions = d.Boolean()
+}
+
+func (r *CloseSessionResponse) Encode(e *Encoder) { r.ResponseHeader.Encode(e) }
+
+func (r *ReadValueId) Decode(d *Decoder) {
+	r.NodeId = d.NodeId()
+	r.AttributeId = AttributeId(d.UInt32())
+	_ = d.String()    // IndexRange
+	d.QualifiedName() // DataEncoding
+}
+
+func (r *ReadRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.MaxAge = d.Double()
+	r.TimestampsToReturn = TimestampsToReturn(d.Int32())
+	r.NodesToRead = make([]*ReadValueId, d.ArrayLength())
+	for i := range r.NodesToRead {
+		r.NodesToRead[i] = &ReadValueId{}
+		r.NodesToRead[i].Decode(d)
+	}
+}
+
+func (r *ReadResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.Int32(int32(len(r.Results)))
+	for _, result := range r.Results {
+		e.DataValue(result)
+	}
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func (r *WriteRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.NodesToWrite = make([]*WriteValue, d.ArrayLength())
+	for i := range r.NodesToWrite {
+		node := &WriteValue{NodeId: d.NodeId(), AttributeId: AttributeId(d.UInt32())}
+		_ = d.String() // IndexRange
+		node.Value = d.DataValue()
+		r.NodesToWrite[i] = node
+	}
+}
+
+func (r *WriteResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	encodeStatusCodes(e, r.Results)
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func (r *BrowseRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	// View
+	d.NodeId()
+	d.DateTime()
+	d.UInt32()
+	r.RequestedMaxReferencesPerNode = d.UInt32()
+	r.NodesToBrowse = make([]*BrowseDescription, d.ArrayLength())
+	for i := range r.NodesToBrowse {
+		r.NodesToBrowse[i] = &BrowseDescription{
+			NodeId:          d.NodeId(),
+			BrowseDirection: BrowseDirection(d.Int32()),
+			ReferenceTypeId: d.NodeId(),
+			IncludeSubtypes: d.Boolean(),
+			NodeClassMask:   d.UInt32(),
+			ResultMask:      d.UInt32(),
+		}
+	}
+}
+
+func encodeBrowseResults(e *Encoder, results []*BrowseResult) {
+	e.Int32(int32(len(results)))
+	for _, result := range results {
+		e.UInt32(uint32(result.StatusCode))
+		e.ByteString(result.ContinuationPoint)
+		e.Int32(int32(len(result.References)))
+		for _, ref := range result.References {
+			e.NodeId(ref.ReferenceTypeId)
+			e.Boolean(ref.IsForward)
+			e.ExpandedNodeId(ref.NodeId)
+			e.QualifiedName(ref.BrowseName)
+			e.LocalizedText(ref.DisplayName)
+			e.Int32(int32(ref.NodeClass))
+			e.ExpandedNodeId(ref.TypeDefinition)
+		}
+	}
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func (r *BrowseResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	encodeBrowseResults(e, r.Results)
+}
+
+func (r *BrowseNextRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.ReleaseContinuationPoints = d.Boolean()
+	r.ContinuationPoints = make([][]byte, d.ArrayLength())
+	for i := range r.ContinuationPoints {
+		r.ContinuationPoints[i] = d.ByteString()
+	}
+}
+
+func (r *BrowseNextResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	encodeBrowseResults(e, r.Results)
+}
+
+func (r *CreateSubscriptionRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.RequestedPublishingInterval = d.Double()
+	r.RequestedLifetimeCount = d.UInt32()
+	r.RequestedMaxKeepAliveCount = d.UInt32()
+	r.MaxNotificationsPerPublish = d.UInt32()
+	r.PublishingEnabled = d.Boolean()
+	r.Priority = d.Byte()
+}
+
+func (r *CreateSubscriptionResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.UInt32(r.SubscriptionId)
+	e.Double(r.RevisedPublishingInterval)
+	e.UInt32(r.RevisedLifetimeCount)
+	e.UInt32(r.RevisedMaxKeepAliveCount)
+}
+
+func (r *CreateMonitoredItemsRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.SubscriptionId = d.UInt32()
+	r.TimestampsToReturn = TimestampsToReturn(d.Int32())
+	r.ItemsToCreate = make([]*MonitoredItemCreateRequest, d.ArrayLength())
+	for i := range r.ItemsToCreate {
+		item := &MonitoredItemCreateRequest{}
+		item.ItemToMonitor.Decode(d)
+		item.MonitoringMode = MonitoringMode(d.Int32())
+		item.ClientHandle = d.UInt32()
+		item.SamplingInterval = d.Double()
+		d.ExtensionObject() // Filter
+		item.QueueSize = d.UInt32()
+		item.DiscardOldest = d.Boolean()
+		r.ItemsToCreate[i] = item
+	}
+}
+
+func (r *CreateMonitoredItemsResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.Int32(int32(len(r.Results)))
+	for _, result := range r.Results {
+		e.UInt32(uint32(result.StatusCode))
+		e.UInt32(result.MonitoredItemId)
+		e.Double(result.RevisedSamplingInterval)
+		e.UInt32(result.RevisedQueueSize)
+		e.ExtensionObject(ExtensionObject{}) // FilterResult
+	}
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func (r *PublishRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.SubscriptionAcknowledgements = make([]*SubscriptionAcknowledgement, d.ArrayLength())
+	for i := range r.SubscriptionAcknowledgements {
+		r.SubscriptionAcknowledgements[i] = &SubscriptionAcknowledgement{SubscriptionId: d.UInt32(), SequenceNumber: d.UInt32()}
+	}
+}
+
+// Encode 数据变化与状态变化分别编码为一个通知, 均为空时是保活消息
+func (r *PublishResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	e.UInt32(r.SubscriptionId)
+	e.UInt32Array(nil) // AvailableSequenceNumbers
+	e.Boolean(r.MoreNotifications)
+	e.UInt32(r.SequenceNumber)
+	e.DateTime(r.PublishTime)
+	notifications := make([]ExtensionObject, 0, 2)
+	if len(r.DataChanges) > 0 {
+		ne := NewEncoder()
+		ne.Int32(int32(len(r.DataChanges)))
+		for _, change := range r.DataChanges {
+			ne.UInt32(change.ClientHandle)
+			ne.DataValue(change.Value)
+		}
+		ne.Int32(-1) // DiagnosticInfos
+		notifications = append(notifications, ExtensionObject{TypeId: NewNumericNodeId(0, IdDataChangeNotification), Body: ne.Bytes()})
+	}
+	if r.StatusChange != nil {
+		ne := NewEncoder()
+		ne.UInt32(uint32(*r.StatusChange))
+		ne.Byte(0) // DiagnosticInfo
+		notifications = append(notifications, ExtensionObject{TypeId: NewNumericNodeId(0, IdStatusChangeNotification), Body: ne.Bytes()})
+	}
+	e.Int32(int32(len(notifications)))
+	for _, notification := range notifications {
+		e.ExtensionObject(notification)
+	}
+	encodeStatusCodes(e, r.Results)
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func (r *DeleteSubscriptionsRequest) Decode(d *Decoder) {
+	r.RequestHeader.Decode(d)
+	r.SubscriptionIds = d.UInt32Array()
+}
+
+func (r *DeleteSubscriptionsResponse) Encode(e *Encoder) {
+	r.ResponseHeader.Encode(e)
+	encodeStatusCodes(e, r.Results)
+	e.Int32(-1) // DiagnosticInfos
+}
+
+func encodeStatusCodes(e *Encoder, codes []StatusCode) {
+	e.Int32(int32(len(codes)))
+	for _, code := range codes {
+		e.UInt32(uint32(code))
+	}
+}
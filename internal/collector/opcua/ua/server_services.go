@@ -0,0 +1,620 @@
+package ua
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+/**
+进程内服务器的服务
+会话绑定创建它的安全通道, 除GetEndpoints与会话服务外均需已激活的会话
+Publish等待值变化或保活到期, 在单独的协程中响应, 不阻塞同一通道上的其它请求
+*/
+
+const (
+	policyIdAnonymous   = "anonymous"
+	policyIdUserName    = "username"
+	policyIdCertificate = "certificate"
+)
+
+type serverSession struct {
+	id        NodeId
+	channel   *serverChannel
+	nonce     []byte
+	activated bool
+	// clientCert 创建会话时的客户端证书, 安全通道下用于验证客户端签名
+	clientCert    []byte
+	subscriptions map[uint32]*serverSubscription
+	continuations map[string]*continuation
+}
+
+type continuation struct {
+	references []*ReferenceDescription
+	max        int
+}
+
+type serverSubscription struct {
+	id        uint32
+	interval  time.Duration
+	keepAlive uint32
+	seqNum    uint32
+	items     []*serverMonitoredItem
+}
+
+type serverMonitoredItem struct {
+	id           uint32
+	clientHandle uint32
+	node         *ServerNode
+	// version 已上报的值版本, 新建时为0使第一次Publish上报当前值
+	version uint64
+}
+
+type serverRequest interface {
+	Header() *RequestHeader
+	Decode(d *Decoder)
+}
+
+func isNumericNodeId(n NodeId, id uint32) bool {
+	return n.Namespace == 0 && n.Type == IdTypeNumeric && n.Numeric == id
+}
+
+func newServerRequest(typeId NodeId) serverRequest {
+	if typeId.Namespace != 0 || typeId.Type != IdTypeNumeric {
+		return nil
+	}
+	switch typeId.Numeric {
+	case IdGetEndpointsRequest:
+		return &GetEndpointsRequest{}
+	case IdCreateSessionRequest:
+		return &CreateSessionRequest{}
+	case IdActivateSessionRequest:
+		return &ActivateSessionRequest{}
+	case IdCloseSessionRequest:
+		return &CloseSessionRequest{}
+	case IdReadRequest:
+		return &ReadRequest{}
+	case IdWriteRequest:
+		return &WriteRequest{}
+	case IdBrowseRequest:
+		return &BrowseRequest{}
+	case IdBrowseNextRequest:
+		return &BrowseNextRequest{}
+	case IdCreateSubscriptionRequest:
+		return &CreateSubscriptionRequest{}
+	case IdCreateMonitoredItemsRequest:
+		return &CreateMonitoredItemsRequest{}
+	case IdPublishRequest:
+		return &PublishRequest{}
+	case IdDeleteSubscriptionsRequest:
+		return &DeleteSubscriptionsRequest{}
+	}
+	return nil
+}
+
+// handle 服务错误以ServiceFault响应, 返回的error会关闭连接
+func (s *Server) handle(sc *serverChannel, requestId uint32, message []byte) error {
+	d := NewDecoder(message)
+	req := newServerRequest(d.NodeId())
+	if req == nil {
+		return sc.reply("MSG", requestId, &ServiceFault{ResponseHeader{ServiceResult: StatusBadServiceUnsupported}})
+	}
+	req.Decode(d)
+	if d.Err() != nil {
+		return sc.reply("MSG", requestId, &ServiceFault{ResponseHeader{ServiceResult: StatusBadDecodingError}})
+	}
+	if publish, ok := req.(*PublishRequest); ok {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			resp := s.publish(sc, publish)
+			if resp == nil {
+				return
+			}
+			resp.Header().RequestHandle = publish.RequestHandle
+			if err := sc.reply("MSG", requestId, resp); err != nil {
+				sc.close(err)
+			}
+		}()
+		return nil
+	}
+	resp := s.service(sc, req)
+	resp.Header().RequestHandle = req.Header().RequestHandle
+	return sc.reply("MSG", requestId, resp)
+}
+
+func (s *Server) service(sc *serverChannel, req serverRequest) serverResponse {
+	switch r := req.(type) {
+	case *GetEndpointsRequest:
+		return &GetEndpointsResponse{Endpoints: s.endpoints(r.EndpointUrl)}
+	case *CreateSessionRequest:
+		return s.createSession(sc, r)
+	case *ActivateSessionRequest:
+		return s.activateSession(sc, r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, status := s.session(sc, req.Header())
+	if status.IsBad() {
+		return &ServiceFault{ResponseHeader{ServiceResult: status}}
+	}
+	switch r := req.(type) {
+	case *CloseSessionRequest:
+		delete(s.sessions, req.Header().AuthenticationToken.String())
+		return &CloseSessionResponse{}
+	case *ReadRequest:
+		return s.read(r)
+	case *WriteRequest:
+		return s.write(r)
+	case *BrowseRequest:
+		return s.browse(session, r)
+	case *BrowseNextRequest:
+		return s.browseNext(session, r)
+	case *CreateSubscriptionRequest:
+		return s.createSubscription(session, r)
+	case *CreateMonitoredItemsRequest:
+		return s.createMonitoredItems(session, r)
+	case *DeleteSubscriptionsRequest:
+		resp := &DeleteSubscriptionsResponse{Results: make([]StatusCode, len(r.SubscriptionIds))}
+		for i, id := range r.SubscriptionIds {
+			if _, ok := session.subscriptions[id]; !ok {
+				resp.Results[i] = StatusBadSubscriptionIdInvalid
+				continue
+			}
+			delete(session.subscriptions, id)
+		}
+		return resp
+	}
+	return &ServiceFault{ResponseHeader{ServiceResult: StatusBadServiceUnsupported}}
+}
+
+// session 调用方持有s.mu
+func (s *Server) session(sc *serverChannel, header *RequestHeader) (*serverSession, StatusCode) {
+	session, ok := s.sessions[header.AuthenticationToken.String()]
+	switch {
+	case !ok:
+		return nil, StatusBadSessionIdInvalid
+	case session.channel != sc:
+		return nil, StatusBadSecureChannelIdInvalid
+	case !session.activated:
+		return nil, StatusBadSessionNotActivated
+	}
+	return session, StatusGood
+}
+
+func (s *Server) endpoints(url string) []*EndpointDescription {
+	server := ApplicationDescription{
+		ApplicationUri:  "urn:harnsplatform:server",
+		ProductUri:      "urn:harnsplatform:server",
+		ApplicationName: LocalizedText{Text: "harnsplatform server"},
+		DiscoveryUrls:   []string{url},
+	}
+	userPolicy := SecurityPolicyNone
+	if s.secure() {
+		userPolicy = SecurityPolicyBasic256Sha256
+	}
+	tokens := []*UserTokenPolicy{
+		{PolicyId: policyIdAnonymous, TokenType: UserTokenTypeAnonymous},
+		{PolicyId: policyIdCertificate, TokenType: UserTokenTypeCertificate, SecurityPolicyUri: userPolicy},
+	}
+	if len(s.Users) > 0 {
+		tokens = append(tokens, &UserTokenPolicy{PolicyId: policyIdUserName, TokenType: UserTokenTypeUserName, SecurityPolicyUri: userPolicy})
+	}
+	endpoints := []*EndpointDescription{{
+		EndpointUrl:        url,
+		Server:             server,
+		SecurityMode:       MessageSecurityModeNone,
+		SecurityPolicyUri:  SecurityPolicyNone,
+		UserIdentityTokens: tokens,
+	}}
+	if !s.secure() {
+		return endpoints
+	}
+	for _, mode := range []MessageSecurityMode{MessageSecurityModeSign, MessageSecurityModeSignAndEncrypt} {
+		endpoints = append(endpoints, &EndpointDescription{
+			EndpointUrl:        url,
+			Server:             server,
+			ServerCertificate:  s.Certificate,
+			SecurityMode:       mode,
+			SecurityPolicyUri:  SecurityPolicyBasic256Sha256,
+			UserIdentityTokens: tokens,
+			SecurityLevel:      byte(mode),
+		})
+	}
+	return endpoints
+}
+
+// createSession 安全通道下以服务器私钥对 客户端证书 + 客户端Nonce 签名
+func (s *Server) createSession(sc *serverChannel, req *CreateSessionRequest) serverResponse {
+	nonce, err := Nonce(basic256Sha256NonceLength)
+	if err != nil {
+		return &ServiceFault{ResponseHeader{ServiceResult: StatusBadInternalError}}
+	}
+	resp := &CreateSessionResponse{
+		RevisedSessionTimeout: req.RequestedSessionTimeout,
+		ServerNonce:           nonce,
+		ServerCertificate:     s.Certificate,
+		ServerEndpoints:       s.endpoints(req.EndpointUrl),
+	}
+	if sc.policy.secure() {
+		if len(req.ClientCertificate) == 0 || !bytes.Equal(firstCertificate(req.ClientCertificate), firstCertificate(sc.policy.remoteCert)) {
+			return &ServiceFault{ResponseHeader{ServiceResult: StatusBadCertificateInvalid}}
+		}
+		signature, err := RsaSha256Sign(s.PrivateKey, append(append([]byte{}, req.ClientCertificate...), req.ClientNonce...))
+		if err != nil {
+			return &ServiceFault{ResponseHeader{ServiceResult: StatusBadInternalError}}
+		}
+		resp.ServerSignature = SignatureData{Algorithm: AlgorithmRsaSha256, Signature: signature}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp.SessionId = NewNumericNodeId(1, s.nextId())
+	resp.AuthenticationToken = NewNumericNodeId(1, s.nextId())
+	s.sessions[resp.AuthenticationToken.String()] = &serverSession{
+		id:            resp.SessionId,
+		channel:       sc,
+		nonce:         nonce,
+		clientCert:    req.ClientCertificate,
+		subscriptions: make(map[uint32]*serverSubscription),
+		continuations: make(map[string]*continuation),
+	}
+	return resp
+}
+
+func (s *Server) activateSession(sc *serverChannel, req *ActivateSessionRequest) serverResponse {
+	s.mu.Lock()
+	session, ok := s.sessions[req.AuthenticationToken.String()]
+	var signed, clientCert []byte
+	if ok {
+		signed = append(append([]byte{}, s.Certificate...), session.nonce...)
+		clientCert = session.clientCert
+	}
+	s.mu.Unlock()
+	if !ok || session.channel != sc {
+		return &ServiceFault{ResponseHeader{ServiceResult: StatusBadSessionIdInvalid}}
+	}
+	if sc.policy.secure() {
+		if err := verifyRsaSha256(clientCert, signed, &req.ClientSignature); err != nil {
+			return &ServiceFault{ResponseHeader{ServiceResult: StatusBadApplicationSignatureInvalid}}
+		}
+	}
+	if status := s.verifyIdentity(req, signed); status.IsBad() {
+		return &ServiceFault{ResponseHeader{ServiceResult: status}}
+	}
+	nonce, err := Nonce(basic256Sha256NonceLength)
+	if err != nil {
+		return &ServiceFault{ResponseHeader{ServiceResult: StatusBadInternalError}}
+	}
+	s.mu.Lock()
+	session.nonce = nonce
+	session.activated = true
+	s.mu.Unlock()
+	return &ActivateSessionResponse{ServerNonce: nonce}
+}
+
+// verifyIdentity 用户名令牌的密码按 长度 + 密码 + 服务器Nonce 加密, 证书令牌按服务器证书 + 服务器Nonce签名
+func (s *Server) verifyIdentity(req *ActivateSessionRequest, signed []byte) StatusCode {
+	nonce := signed[len(signed)-basic256Sha256NonceLength:]
+	token := req.UserIdentityToken
+	d := NewDecoder(token.Body)
+	switch {
+	case isNumericNodeId(token.TypeId, IdAnonymousIdentityToken):
+		identity := &AnonymousIdentityToken{}
+		identity.Decode(d)
+		if d.Err() != nil || identity.PolicyId != policyIdAnonymous {
+			return StatusBadIdentityTokenInvalid
+		}
+		return StatusGood
+	case isNumericNodeId(token.TypeId, IdUserNameIdentityToken):
+		identity := &UserNameIdentityToken{}
+		identity.Decode(d)
+		if d.Err() != nil || identity.PolicyId != policyIdUserName {
+			return StatusBadIdentityTokenInvalid
+		}
+		password := identity.Password
+		if s.secure() {
+			if identity.EncryptionAlgorithm != AlgorithmRsaOaep {
+				return StatusBadIdentityTokenInvalid
+			}
+			plain, err := RsaOaepDecrypt(s.PrivateKey, identity.Password)
+			if err != nil || len(plain) < 4 {
+				return StatusBadIdentityTokenInvalid
+			}
+			length := int(binary.LittleEndian.Uint32(plain))
+			plain = plain[4:]
+			if length != len(plain) || length < len(nonce) || !bytes.Equal(plain[length-len(nonce):], nonce) {
+				return StatusBadIdentityTokenInvalid
+			}
+			password = plain[:length-len(nonce)]
+		}
+		if expected, ok := s.Users[identity.UserName]; !ok || expected != string(password) {
+			return StatusBadUserAccessDenied
+		}
+		return StatusGood
+	case isNumericNodeId(token.TypeId, IdX509IdentityToken):
+		identity := &X509IdentityToken{}
+		identity.Decode(d)
+		if d.Err() != nil || identity.PolicyId != policyIdCertificate {
+			return StatusBadIdentityTokenInvalid
+		}
+		if err := verifyRsaSha256(identity.CertificateData, signed, &req.UserTokenSignature); err != nil {
+			return StatusBadUserSignatureInvalid
+		}
+		return StatusGood
+	}
+	return StatusBadIdentityTokenInvalid
+}
+
+func verifyRsaSha256(cert, b []byte, signature *SignatureData) error {
+	key, err := publicKey(cert)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	if signature.Algorithm != AlgorithmRsaSha256 || rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature.Signature) != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// read 调用方持有s.mu
+func (s *Server) read(req *ReadRequest) serverResponse {
+	resp := &ReadResponse{Results: make([]*DataValue, len(req.NodesToRead))}
+	now := time.Now()
+	for i, rv := range req.NodesToRead {
+		node, ok := s.nodes[rv.NodeId.String()]
+		if !ok {
+			resp.Results[i] = &DataValue{Status: StatusBadNodeIdUnknown}
+			continue
+		}
+		var value interface{}
+		switch rv.AttributeId {
+		case AttributeNodeId:
+			value = node.NodeId
+		case AttributeNodeClass:
+			value = int32(node.NodeClass)
+		case AttributeBrowseName:
+			value = QualifiedName{Namespace: node.NodeId.Namespace, Name: node.BrowseName}
+		case AttributeDisplayName:
+			value = LocalizedText{Text: node.BrowseName}
+		}
+		if node.NodeClass == NodeClassVariable {
+			switch rv.AttributeId {
+			case AttributeValue:
+				if node.AccessLevel&AccessLevelCurrentRead == 0 {
+					resp.Results[i] = &DataValue{Status: StatusBadNotReadable}
+					continue
+				}
+				resp.Results[i] = &DataValue{Value: node.value, SourceTimestamp: now, ServerTimestamp: now}
+				continue
+			case AttributeDataType:
+				value = node.DataType.DataType()
+			case AttributeValueRank:
+				value = int32(-1)
+			case AttributeAccessLevel:
+				value = node.AccessLevel
+			}
+		}
+		if value == nil {
+			resp.Results[i] = &DataValue{Status: StatusBadAttributeIdInvalid}
+			continue
+		}
+		variant, _ := NewVariant(value)
+		resp.Results[i] = &DataValue{Value: variant}
+	}
+	return resp
+}
+
+// write 只能写入变量的值, 类型须与节点的数据类型一致
+func (s *Server) write(req *WriteRequest) serverResponse {
+	resp := &WriteResponse{Results: make([]StatusCode, len(req.NodesToWrite))}
+	for i, wv := range req.NodesToWrite {
+		node, ok := s.nodes[wv.NodeId.String()]
+		switch {
+		case !ok:
+			resp.Results[i] = StatusBadNodeIdUnknown
+		case wv.AttributeId != AttributeValue || node.NodeClass != NodeClassVariable:
+			resp.Results[i] = StatusBadNotWritable
+		case node.AccessLevel&AccessLevelCurrentWrite == 0:
+			resp.Results[i] = StatusBadNotWritable
+		case wv.Value == nil || wv.Value.Value == nil || wv.Value.Value.Type != node.DataType || wv.Value.Value.Array:
+			resp.Results[i] = StatusBadTypeMismatch
+		default:
+			node.value = wv.Value.Value
+			node.version++
+		}
+	}
+	return resp
+}
+
+func (s *Server) browse(session *serverSession, req *BrowseRequest) serverResponse {
+	resp := &BrowseResponse{Results: make([]*BrowseResult, len(req.NodesToBrowse))}
+	for i, description := range req.NodesToBrowse {
+		node, ok := s.nodes[description.NodeId.String()]
+		if !ok {
+			resp.Results[i] = &BrowseResult{StatusCode: StatusBadNodeIdUnknown}
+			continue
+		}
+		references := make([]*ReferenceDescription, 0, len(node.children))
+		if description.BrowseDirection != BrowseDirectionInverse {
+			for _, child := range node.children {
+				if description.NodeClassMask != 0 && description.NodeClassMask&uint32(child.NodeClass) == 0 {
+					continue
+				}
+				references = append(references, reference(node, child))
+			}
+		}
+		resp.Results[i] = s.nextReferences(session, &continuation{references: references, max: int(req.RequestedMaxReferencesPerNode)})
+	}
+	return resp
+}
+
+func reference(parent, child *ServerNode) *ReferenceDescription {
+	ref := &ReferenceDescription{
+		ReferenceTypeId: NewNumericNodeId(0, referenceHasComponent),
+		IsForward:       true,
+		NodeId:          ExpandedNodeId{NodeId: child.NodeId},
+		BrowseName:      QualifiedName{Namespace: child.NodeId.Namespace, Name: child.BrowseName},
+		DisplayName:     LocalizedText{Text: child.BrowseName},
+		NodeClass:       child.NodeClass,
+		TypeDefinition:  ExpandedNodeId{NodeId: NewNumericNodeId(0, BaseDataVariableType)},
+	}
+	if child.NodeClass == NodeClassObject {
+		ref.TypeDefinition = ExpandedNodeId{NodeId: NewNumericNodeId(0, baseObjectType)}
+	}
+	if isNumericNodeId(parent.NodeId, ObjectsFolder) {
+		ref.ReferenceTypeId = NewNumericNodeId(0, referenceOrganizes)
+	}
+	return ref
+}
+
+// nextReferences 超过每个节点的引用数量上限时保存剩余引用, 通过BrowseNext继续
+func (s *Server) nextReferences(session *serverSession, c *continuation) *BrowseResult {
+	result := &BrowseResult{References: c.references}
+	if c.max <= 0 || len(c.references) <= c.max {
+		return result
+	}
+	result.References = c.references[:c.max]
+	point := make([]byte, 4)
+	binary.LittleEndian.PutUint32(point, s.nextId())
+	result.ContinuationPoint = point
+	session.continuations[string(point)] = &continuation{references: c.references[c.max:], max: c.max}
+	return result
+}
+
+func (s *Server) browseNext(session *serverSession, req *BrowseNextRequest) serverResponse {
+	resp := &BrowseNextResponse{Results: make([]*BrowseResult, len(req.ContinuationPoints))}
+	for i, point := range req.ContinuationPoints {
+		c, ok := session.continuations[string(point)]
+		delete(session.continuations, string(point))
+		switch {
+		case !ok:
+			resp.Results[i] = &BrowseResult{StatusCode: StatusBadContinuationPointInvalid}
+		case req.ReleaseContinuationPoints:
+			resp.Results[i] = &BrowseResult{}
+		default:
+			resp.Results[i] = s.nextReferences(session, c)
+		}
+	}
+	return resp
+}
+
+func (s *Server) createSubscription(session *serverSession, req *CreateSubscriptionRequest) serverResponse {
+	interval := time.Duration(req.RequestedPublishingInterval * float64(time.Millisecond))
+	if interval < minPublishingInterval {
+		interval = minPublishingInterval
+	}
+	keepAlive := req.RequestedMaxKeepAliveCount
+	if keepAlive == 0 {
+		keepAlive = defaultKeepAliveCount
+	}
+	lifetime := req.RequestedLifetimeCount
+	if lifetime < keepAlive*3 {
+		lifetime = keepAlive * 3
+	}
+	sub := &serverSubscription{id: s.nextId(), interval: interval, keepAlive: keepAlive, seqNum: 1}
+	session.subscriptions[sub.id] = sub
+	return &CreateSubscriptionResponse{
+		SubscriptionId:            sub.id,
+		RevisedPublishingInterval: float64(interval) / float64(time.Millisecond),
+		RevisedLifetimeCount:      lifetime,
+		RevisedMaxKeepAliveCount:  keepAlive,
+	}
+}
+
+func (s *Server) createMonitoredItems(session *serverSession, req *CreateMonitoredItemsRequest) serverResponse {
+	sub, ok := session.subscriptions[req.SubscriptionId]
+	if !ok {
+		return &ServiceFault{ResponseHeader{ServiceResult: StatusBadSubscriptionIdInvalid}}
+	}
+	resp := &CreateMonitoredItemsResponse{Results: make([]*MonitoredItemCreateResult, len(req.ItemsToCreate))}
+	for i, item := range req.ItemsToCreate {
+		node, ok := s.nodes[item.ItemToMonitor.NodeId.String()]
+		switch {
+		case !ok:
+			resp.Results[i] = &MonitoredItemCreateResult{StatusCode: StatusBadNodeIdUnknown}
+		case item.ItemToMonitor.AttributeId != AttributeValue || node.NodeClass != NodeClassVariable:
+			resp.Results[i] = &MonitoredItemCreateResult{StatusCode: StatusBadAttributeIdInvalid}
+		default:
+			monitored := &serverMonitoredItem{id: s.nextId(), clientHandle: item.ClientHandle, node: node}
+			sub.items = append(sub.items, monitored)
+			resp.Results[i] = &MonitoredItemCreateResult{
+				MonitoredItemId:         monitored.id,
+				RevisedSamplingInterval: float64(sub.interval) / float64(time.Millisecond),
+				RevisedQueueSize:        1,
+			}
+		}
+	}
+	return resp
+}
+
+// publish 每个发布周期检查一次, 有值变化或保活到期时响应, 通道关闭时返回nil
+func (s *Server) publish(sc *serverChannel, req *PublishRequest) serverResponse {
+	var idle uint32
+	for {
+		s.mu.Lock()
+		session, status := s.session(sc, req.Header())
+		if status.IsBad() {
+			s.mu.Unlock()
+			return &ServiceFault{ResponseHeader{ServiceResult: status}}
+		}
+		sub := session.firstSubscription()
+		if sub == nil {
+			s.mu.Unlock()
+			return &ServiceFault{ResponseHeader{ServiceResult: StatusBadNoSubscription}}
+		}
+		changes := sub.changes()
+		if len(changes) > 0 || idle >= sub.keepAlive {
+			resp := &PublishResponse{SubscriptionId: sub.id, SequenceNumber: sub.seqNum, PublishTime: time.Now(), DataChanges: changes}
+			if len(changes) > 0 {
+				sub.seqNum++
+			}
+			s.mu.Unlock()
+			return resp
+		}
+		idle++
+		interval := sub.interval
+		s.mu.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-sc.closed:
+			return nil
+		}
+	}
+}
+
+// firstSubscription 多个订阅时按编号顺序, 只检查第一个
+func (session *serverSession) firstSubscription() *serverSubscription {
+	ids := make([]uint32, 0, len(session.subscriptions))
+	for id := range session.subscriptions {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return session.subscriptions[ids[0]]
+}
+
+// changes 值版本变化的监控项, 调用方持有s.mu
+func (sub *serverSubscription) changes() []*MonitoredItemNotification {
+	changes := make([]*MonitoredItemNotification, 0)
+	now := time.Now()
+	for _, item := range sub.items {
+		if item.version == item.node.version {
+			continue
+		}
+		item.version = item.node.version
+		changes = append(changes, &MonitoredItemNotification{
+			ClientHandle: item.clientHandle,
+			Value:        &DataValue{Value: item.node.value, SourceTimestamp: now, ServerTimestamp: now},
+		})
+	}
+	return changes
+}
@@ -0,0 +1,330 @@
+package ua
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+var (
+	testSpeed   = NewStringNodeId(2, "Line1.Speed")
+	testRunning = NewStringNodeId(2, "Line1.Running")
+	testName    = NewStringNodeId(2, "Line1.Name")
+	testLine    = NewStringNodeId(2, "Line1")
+)
+
+// startTestServer Objects/Line1 下有三个变量, Name只读
+func startTestServer(t *testing.T, cert *testCertificate) (*Server, string) {
+	t.Helper()
+	var s *Server
+	if cert != nil {
+		s = NewServer(cert.der, cert.key)
+	} else {
+		s = NewServer(nil, nil)
+	}
+	s.Users["operator"] = "secret"
+	objects := NewNumericNodeId(0, ObjectsFolder)
+	rw := AccessLevelCurrentRead | AccessLevelCurrentWrite
+	for _, err := range []error{
+		s.AddObject(objects, testLine, "Line1"),
+		s.AddVariable(testLine, testSpeed, "Speed", &Variant{Type: TypeDouble, Value: 12.5}, rw),
+		s.AddVariable(testLine, testRunning, "Running", &Variant{Type: TypeBoolean, Value: true}, rw),
+		s.AddVariable(testLine, testName, "Name", &Variant{Type: TypeString, Value: "line"}, AccessLevelCurrentRead),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return s, "opc.tcp://" + l.Addr().String()
+}
+
+func dialTestServer(t *testing.T, config *ClientConfig) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, config)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close(context.Background()) })
+	return c
+}
+
+func readValue(t *testing.T, c *Client, id NodeId) *DataValue {
+	t.Helper()
+	values, err := c.Read(context.Background(), []*ReadValueId{{NodeId: id, AttributeId: AttributeValue}})
+	if err != nil {
+		t.Fatalf("read %s: %v", id.String(), err)
+	}
+	return values[0]
+}
+
+func TestServerRead(t *testing.T) {
+	_, endpoint := startTestServer(t, nil)
+	c := dialTestServer(t, &ClientConfig{Endpoint: endpoint})
+
+	values, err := c.Read(context.Background(), []*ReadValueId{
+		{NodeId: testSpeed, AttributeId: AttributeValue},
+		{NodeId: testSpeed, AttributeId: AttributeDataType},
+		{NodeId: testName, AttributeId: AttributeAccessLevel},
+		{NodeId: testLine, AttributeId: AttributeNodeClass},
+		{NodeId: testLine, AttributeId: AttributeValue},
+		{NodeId: NewStringNodeId(2, "Missing"), AttributeId: AttributeValue},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values[0].Value; v == nil || v.Type != TypeDouble || v.Value != 12.5 {
+		t.Errorf("value: got %#v", v)
+	}
+	if v := values[1].Value; v == nil || v.Type != TypeNodeId || v.Value.(NodeId).Numeric != uint32(TypeDouble) {
+		t.Errorf("data type: got %#v", v)
+	}
+	if v := values[2].Value; v == nil || v.Value != AccessLevelCurrentRead {
+		t.Errorf("access level: got %#v", v)
+	}
+	if v := values[3].Value; v == nil || v.Value != int32(NodeClassObject) {
+		t.Errorf("node class: got %#v", v)
+	}
+	if values[4].Status != StatusBadAttributeIdInvalid {
+		t.Errorf("object value: got %v", values[4].Status)
+	}
+	if values[5].Status != StatusBadNodeIdUnknown {
+		t.Errorf("missing node: got %v", values[5].Status)
+	}
+}
+
+func TestServerWrite(t *testing.T) {
+	s, endpoint := startTestServer(t, nil)
+	c := dialTestServer(t, &ClientConfig{Endpoint: endpoint})
+
+	results, err := c.Write(context.Background(), []*WriteValue{
+		{NodeId: testSpeed, AttributeId: AttributeValue, Value: &DataValue{Value: &Variant{Type: TypeDouble, Value: 30.0}}},
+		{NodeId: testRunning, AttributeId: AttributeValue, Value: &DataValue{Value: &Variant{Type: TypeInt32, Value: int32(1)}}},
+		{NodeId: testName, AttributeId: AttributeValue, Value: &DataValue{Value: &Variant{Type: TypeString, Value: "x"}}},
+		{NodeId: NewStringNodeId(2, "Missing"), AttributeId: AttributeValue, Value: &DataValue{Value: &Variant{Type: TypeDouble, Value: 1.0}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StatusCode{StatusGood, StatusBadTypeMismatch, StatusBadNotWritable, StatusBadNodeIdUnknown}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d: got %v, want %v", i, results[i], want[i])
+		}
+	}
+	if v := readValue(t, c, testSpeed).Value; v.Value != 30.0 {
+		t.Errorf("read back %v", v.Value)
+	}
+	if v := s.Value(testSpeed); v.Value != 30.0 {
+		t.Errorf("server value %v", v.Value)
+	}
+}
+
+func TestServerBrowse(t *testing.T) {
+	_, endpoint := startTestServer(t, nil)
+	c := dialTestServer(t, &ClientConfig{Endpoint: endpoint})
+
+	results, err := c.Browse(context.Background(), []*BrowseDescription{
+		{NodeId: NewNumericNodeId(0, ObjectsFolder), BrowseDirection: BrowseDirectionForward},
+		{NodeId: testLine, BrowseDirection: BrowseDirectionForward},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := results[0].References; len(refs) != 1 || refs[0].NodeId.NodeId.String() != testLine.String() || refs[0].NodeClass != NodeClassObject {
+		t.Fatalf("objects references: %#v", refs)
+	}
+	names := make([]string, 0)
+	for _, ref := range results[1].References {
+		names = append(names, ref.BrowseName.Name)
+	}
+	if len(names) != 3 || names[0] != "Speed" || names[1] != "Running" || names[2] != "Name" {
+		t.Fatalf("line references: %v", names)
+	}
+
+	// 每次最多返回两个引用, 剩余的通过BrowseNext取得
+	resp := &BrowseResponse{}
+	req := &BrowseRequest{RequestedMaxReferencesPerNode: 2, NodesToBrowse: []*BrowseDescription{{NodeId: testLine}}}
+	if err = c.call(context.Background(), req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results[0].References) != 2 || len(resp.Results[0].ContinuationPoint) == 0 {
+		t.Fatalf("first page: %d references, continuation %x", len(resp.Results[0].References), resp.Results[0].ContinuationPoint)
+	}
+	next := &BrowseNextResponse{}
+	if err = c.call(context.Background(), &BrowseNextRequest{ContinuationPoints: [][]byte{resp.Results[0].ContinuationPoint}}, next); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Results[0].References) != 1 || len(next.Results[0].ContinuationPoint) != 0 {
+		t.Fatalf("second page: %d references, continuation %x", len(next.Results[0].References), next.Results[0].ContinuationPoint)
+	}
+	if err = c.call(context.Background(), &BrowseNextRequest{ContinuationPoints: [][]byte{resp.Results[0].ContinuationPoint}}, next); err != nil {
+		t.Fatal(err)
+	}
+	if next.Results[0].StatusCode != StatusBadContinuationPointInvalid {
+		t.Fatalf("released continuation point: got %v", next.Results[0].StatusCode)
+	}
+}
+
+func TestServerSubscription(t *testing.T) {
+	s, endpoint := startTestServer(t, nil)
+	c := dialTestServer(t, &ClientConfig{Endpoint: endpoint})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sub, err := c.CreateSubscription(ctx, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := c.CreateMonitoredItems(ctx, sub.SubscriptionId, []*MonitoredItemCreateRequest{
+		{ItemToMonitor: ReadValueId{NodeId: testSpeed, AttributeId: AttributeValue}, MonitoringMode: MonitoringModeReporting, ClientHandle: 1, SamplingInterval: -1, QueueSize: 1},
+		{ItemToMonitor: ReadValueId{NodeId: testRunning, AttributeId: AttributeValue}, MonitoringMode: MonitoringModeReporting, ClientHandle: 2, SamplingInterval: -1, QueueSize: 1},
+		{ItemToMonitor: ReadValueId{NodeId: NewStringNodeId(2, "Missing"), AttributeId: AttributeValue}, ClientHandle: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items[0].StatusCode.IsBad() || items[1].StatusCode.IsBad() || items[2].StatusCode != StatusBadNodeIdUnknown {
+		t.Fatalf("monitored items: %v %v %v", items[0].StatusCode, items[1].StatusCode, items[2].StatusCode)
+	}
+
+	// 第一次Publish上报所有监控项的当前值
+	resp, err := c.Publish(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.SubscriptionId != sub.SubscriptionId || len(resp.DataChanges) != 2 {
+		t.Fatalf("initial publish: %#v", resp)
+	}
+	acks := []*SubscriptionAcknowledgement{{SubscriptionId: sub.SubscriptionId, SequenceNumber: resp.SequenceNumber}}
+
+	// 无变化时按保活次数返回空通知
+	if resp, err = c.Publish(ctx, acks); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Notifications != 0 || len(resp.DataChanges) != 0 {
+		t.Fatalf("keepalive: %#v", resp)
+	}
+
+	if err = s.SetValue(testSpeed, &Variant{Type: TypeDouble, Value: 99.0}); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = c.Publish(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.DataChanges) != 1 || resp.DataChanges[0].ClientHandle != 1 || resp.DataChanges[0].Value.Value.Value != 99.0 {
+		t.Fatalf("data change: %#v", resp.DataChanges)
+	}
+
+	if err = c.DeleteSubscriptions(ctx, []uint32{sub.SubscriptionId}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Publish(ctx, nil); err != StatusBadNoSubscription {
+		t.Fatalf("publish without subscription: got %v", err)
+	}
+}
+
+func TestServerSecure(t *testing.T) {
+	serverCert := newTestCertificate(t, "server", nil, false)
+	clientCert := newTestCertificate(t, "client", nil, false)
+	_, endpoint := startTestServer(t, serverCert)
+	thumbprint := sha1.Sum(serverCert.der)
+
+	tests := []struct {
+		name     string
+		mode     MessageSecurityMode
+		auth     UserTokenType
+		password string
+	}{
+		{"sign anonymous", MessageSecurityModeSign, UserTokenTypeAnonymous, ""},
+		{"sign and encrypt anonymous", MessageSecurityModeSignAndEncrypt, UserTokenTypeAnonymous, ""},
+		{"sign and encrypt username", MessageSecurityModeSignAndEncrypt, UserTokenTypeUserName, "secret"},
+		{"sign certificate", MessageSecurityModeSign, UserTokenTypeCertificate, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dialTestServer(t, &ClientConfig{
+				Endpoint:            endpoint,
+				SecurityPolicy:      SecurityPolicyBasic256Sha256,
+				SecurityMode:        tt.mode,
+				Certificate:         clientCert.der,
+				PrivateKey:          clientCert.key,
+				TrustedCertificates: [][]byte{serverCert.der},
+				AuthMode:            tt.auth,
+				Username:            "operator",
+				Password:            tt.password,
+			})
+			// 大于一个分块的响应
+			nodes := make([]*ReadValueId, 4000)
+			for i := range nodes {
+				nodes[i] = &ReadValueId{NodeId: testName, AttributeId: AttributeValue}
+			}
+			values, err := c.Read(context.Background(), nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := values[len(values)-1].Value; v == nil || v.Value != "line" {
+				t.Fatalf("got %#v", v)
+			}
+		})
+	}
+
+	// 用户名令牌也可在None通道上按服务器证书加密
+	t.Run("none channel encrypted password", func(t *testing.T) {
+		c := dialTestServer(t, &ClientConfig{Endpoint: endpoint, ServerThumbprint: thumbprint[:], AuthMode: UserTokenTypeUserName, Username: "operator", Password: "secret"})
+		if v := readValue(t, c, testSpeed).Value; v.Value != 12.5 {
+			t.Fatalf("got %v", v.Value)
+		}
+	})
+}
+
+func TestServerRejected(t *testing.T) {
+	serverCert := newTestCertificate(t, "server", nil, false)
+	clientCert := newTestCertificate(t, "client", nil, false)
+	other := newTestCertificate(t, "other", nil, false)
+	_, endpoint := startTestServer(t, serverCert)
+	otherThumbprint := sha1.Sum(other.der)
+
+	tests := []struct {
+		name   string
+		config *ClientConfig
+		err    error
+	}{
+		{"untrusted thumbprint", &ClientConfig{ServerThumbprint: otherThumbprint[:]}, ErrServerCertificateUntrusted},
+		{"untrusted certificate", &ClientConfig{TrustedCertificates: [][]byte{other.der}}, ErrServerCertificateUntrusted},
+		{"wrong password", &ClientConfig{TrustedCertificates: [][]byte{serverCert.der}, AuthMode: UserTokenTypeUserName, Username: "operator", Password: "wrong"}, StatusBadUserAccessDenied},
+		{"unknown user", &ClientConfig{TrustedCertificates: [][]byte{serverCert.der}, AuthMode: UserTokenTypeUserName, Username: "guest", Password: "secret"}, StatusBadUserAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Endpoint = endpoint
+			tt.config.SecurityPolicy = SecurityPolicyBasic256Sha256
+			tt.config.SecurityMode = MessageSecurityModeSignAndEncrypt
+			tt.config.Certificate = clientCert.der
+			tt.config.PrivateKey = clientCert.key
+			_, err := Dial(context.Background(), tt.config)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	t.Run("endpoint not found", func(t *testing.T) {
+		_, plain := startTestServer(t, nil)
+		_, err := Dial(context.Background(), &ClientConfig{Endpoint: plain, SecurityPolicy: SecurityPolicyBasic256Sha256,
+			SecurityMode: MessageSecurityModeSign, Certificate: clientCert.der, PrivateKey: clientCert.key})
+		if err != ErrEndpointNotFound {
+			t.Fatalf("got %v, want %v", err, ErrEndpointNotFound)
+		}
+	})
+}
@@ -0,0 +1,788 @@
+package ua
+
+import (
+	"time"
+)
+
+/**
+服务请求与响应 (Part 4)
+只实现采集所需的服务, 请求只编码, 响应只解码
+消息体为 编码节点编号(NodeId) + 结构体
+*/
+
+type RequestHeader struct {
+	AuthenticationToken NodeId
+	Timestamp           time.Time
+	RequestHandle       uint32
+	TimeoutHint         uint32 // 毫秒
+}
+
+func (h *RequestHeader) Encode(e *Encoder) {
+	e.NodeId(h.AuthenticationToken)
+	e.DateTime(h.Timestamp)
+	e.UInt32(h.RequestHandle)
+	e.UInt32(0) // ReturnDiagnostics
+	e.String("")
+	e.UInt32(h.TimeoutHint)
+	e.ExtensionObject(ExtensionObject{})
+}
+
+type ResponseHeader struct {
+	Timestamp     time.Time
+	RequestHandle uint32
+	ServiceResult StatusCode
+}
+
+func (h *ResponseHeader) Decode(d *Decoder) {
+	h.Timestamp = d.DateTime()
+	h.RequestHandle = d.UInt32()
+	h.ServiceResult = StatusCode(d.UInt32())
+	d.DiagnosticInfo()
+	d.StringArray()
+	d.ExtensionObject()
+}
+
+type Request interface {
+	Encodable
+	TypeId() uint32
+	Header() *RequestHeader
+}
+
+type Response interface {
+	TypeId() uint32
+	Header() *ResponseHeader
+	Decode(d *Decoder)
+}
+
+type ServiceFault struct {
+	ResponseHeader
+}
+
+func (r *ServiceFault) TypeId() uint32          { return IdServiceFault }
+func (r *ServiceFault) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *ServiceFault) Decode(d *Decoder)       { r.ResponseHeader.Decode(d) }
+
+type ApplicationDescription struct {
+	ApplicationUri  string
+	ProductUri      string
+	ApplicationName LocalizedText
+	ApplicationType int32 // 0 Server 1 Client
+	DiscoveryUrls   []string
+}
+
+func (a *ApplicationDescription) Encode(e *Encoder) {
+	e.String(a.ApplicationUri)
+	e.String(a.ProductUri)
+	e.LocalizedText(a.ApplicationName)
+	e.Int32(a.ApplicationType)
+	e.String("") // GatewayServerUri
+	e.String("") // DiscoveryProfileUri
+	e.StringArray(a.DiscoveryUrls)
+}
+
+func (a *ApplicationDescription) Decode(d *Decoder) {
+	a.ApplicationUri = d.String()
+	a.ProductUri = d.String()
+	a.ApplicationName = d.LocalizedText()
+	a.ApplicationType = d.Int32()
+	_ = d.String()
+	_ = d.String()
+	a.DiscoveryUrls = d.StringArray()
+}
+
+type UserTokenPolicy struct {
+	PolicyId          string
+	TokenType         UserTokenType
+	SecurityPolicyUri string
+}
+
+func (u *UserTokenPolicy) Decode(d *Decoder) {
+	u.PolicyId = d.String()
+	u.TokenType = UserTokenType(d.Int32())
+	_ = d.String() // IssuedTokenType
+	_ = d.String() // IssuerEndpointUrl
+	u.SecurityPolicyUri = d.String()
+}
+
+type EndpointDescription struct {
+	EndpointUrl         string
+	Server              ApplicationDescription
+	ServerCertificate   []byte
+	SecurityMode        MessageSecurityMode
+	SecurityPolicyUri   string
+	UserIdentityTokens  []*UserTokenPolicy
+	TransportProfileUri string
+	SecurityLevel       byte
+}
+
+func (ep *EndpointDescription) Decode(d *Decoder) {
+	ep.EndpointUrl = d.String()
+	ep.Server.Decode(d)
+	ep.ServerCertificate = d.ByteString()
+	ep.SecurityMode = MessageSecurityMode(d.Int32())
+	ep.SecurityPolicyUri = d.String()
+	n := d.ArrayLength()
+	ep.UserIdentityTokens = make([]*UserTokenPolicy, n)
+	for i := range ep.UserIdentityTokens {
+		ep.UserIdentityTokens[i] = &UserTokenPolicy{}
+		ep.UserIdentityTokens[i].Decode(d)
+	}
+	ep.TransportProfileUri = d.String()
+	ep.SecurityLevel = d.Byte()
+}
+
+func decodeEndpoints(d *Decoder) []*EndpointDescription {
+	n := d.ArrayLength()
+	endpoints := make([]*EndpointDescription, n)
+	for i := range endpoints {
+		endpoints[i] = &EndpointDescription{}
+		endpoints[i].Decode(d)
+	}
+	return endpoints
+}
+
+type GetEndpointsRequest struct {
+	RequestHeader
+	EndpointUrl string
+}
+
+func (r *GetEndpointsRequest) TypeId() uint32         { return IdGetEndpointsRequest }
+func (r *GetEndpointsRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *GetEndpointsRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.String(r.EndpointUrl)
+	e.StringArray(nil) // LocaleIds
+	e.StringArray(nil) // ProfileUris
+}
+
+type GetEndpointsResponse struct {
+	ResponseHeader
+	Endpoints []*EndpointDescription
+}
+
+func (r *GetEndpointsResponse) TypeId() uint32          { return IdGetEndpointsResponse }
+func (r *GetEndpointsResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *GetEndpointsResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Endpoints = decodeEndpoints(d)
+}
+
+type SecurityTokenRequestType int32
+
+const (
+	SecurityTokenIssue SecurityTokenRequestType = iota
+	SecurityTokenRenew
+)
+
+type OpenSecureChannelRequest struct {
+	RequestHeader
+	RequestType       SecurityTokenRequestType
+	SecurityMode      MessageSecurityMode
+	ClientNonce       []byte
+	RequestedLifetime uint32 // 毫秒
+}
+
+func (r *OpenSecureChannelRequest) TypeId() uint32         { return IdOpenSecureChannelRequest }
+func (r *OpenSecureChannelRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *OpenSecureChannelRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.UInt32(0) // ClientProtocolVersion
+	e.Int32(int32(r.RequestType))
+	e.Int32(int32(r.SecurityMode))
+	e.ByteString(r.ClientNonce)
+	e.UInt32(r.RequestedLifetime)
+}
+
+type ChannelSecurityToken struct {
+	ChannelId       uint32
+	TokenId         uint32
+	CreatedAt       time.Time
+	RevisedLifetime uint32
+}
+
+type OpenSecureChannelResponse struct {
+	ResponseHeader
+	SecurityToken ChannelSecurityToken
+	ServerNonce   []byte
+}
+
+func (r *OpenSecureChannelResponse) TypeId() uint32          { return IdOpenSecureChannelResponse }
+func (r *OpenSecureChannelResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *OpenSecureChannelResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	d.UInt32() // ServerProtocolVersion
+	r.SecurityToken.ChannelId = d.UInt32()
+	r.SecurityToken.TokenId = d.UInt32()
+	r.SecurityToken.CreatedAt = d.DateTime()
+	r.SecurityToken.RevisedLifetime = d.UInt32()
+	r.ServerNonce = d.ByteString()
+}
+
+type CloseSecureChannelRequest struct {
+	RequestHeader
+}
+
+func (r *CloseSecureChannelRequest) TypeId() uint32         { return IdCloseSecureChannelRequest }
+func (r *CloseSecureChannelRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *CloseSecureChannelRequest) Encode(e *Encoder)      { r.RequestHeader.Encode(e) }
+
+type SignatureData struct {
+	Algorithm string
+	Signature []byte
+}
+
+func (s *SignatureData) Encode(e *Encoder) {
+	e.String(s.Algorithm)
+	e.ByteString(s.Signature)
+}
+
+func (s *SignatureData) Decode(d *Decoder) {
+	s.Algorithm = d.String()
+	s.Signature = d.ByteString()
+}
+
+type CreateSessionRequest struct {
+	RequestHeader
+	ClientDescription       ApplicationDescription
+	EndpointUrl             string
+	SessionName             string
+	ClientNonce             []byte
+	ClientCertificate       []byte
+	RequestedSessionTimeout float64 // 毫秒
+}
+
+func (r *CreateSessionRequest) TypeId() uint32         { return IdCreateSessionRequest }
+func (r *CreateSessionRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *CreateSessionRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	r.ClientDescription.Encode(e)
+	e.String("") // ServerUri
+	e.String(r.EndpointUrl)
+	e.String(r.SessionName)
+	e.ByteString(r.ClientNonce)
+	e.ByteString(r.ClientCertificate)
+	e.Double(r.RequestedSessionTimeout)
+	e.UInt32(0) // MaxResponseMessageSize 不限制
+}
+
+type CreateSessionResponse struct {
+	ResponseHeader
+	SessionId             NodeId
+	AuthenticationToken   NodeId
+	RevisedSessionTimeout float64
+	ServerNonce           []byte
+	ServerCertificate     []byte
+	ServerEndpoints       []*EndpointDescription
+	ServerSignature       SignatureData
+}
+
+func (r *CreateSessionResponse) TypeId() uint32          { return IdCreateSessionResponse }
+func (r *CreateSessionResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *CreateSessionResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.SessionId = d.NodeId()
+	r.AuthenticationToken = d.NodeId()
+	r.RevisedSessionTimeout = d.Double()
+	r.ServerNonce = d.ByteString()
+	r.ServerCertificate = d.ByteString()
+	r.ServerEndpoints = decodeEndpoints(d)
+	for i, n := 0, d.ArrayLength(); i < n; i++ {
+		// ServerSoftwareCertificates
+		d.ByteString()
+		d.ByteString()
+	}
+	r.ServerSignature.Decode(d)
+	d.UInt32() // MaxRequestMessageSize
+}
+
+type AnonymousIdentityToken struct {
+	PolicyId string
+}
+
+func (t *AnonymousIdentityToken) Encode(e *Encoder) {
+	e.String(t.PolicyId)
+}
+
+type UserNameIdentityToken struct {
+	PolicyId            string
+	UserName            string
+	Password            []byte
+	EncryptionAlgorithm string
+}
+
+func (t *UserNameIdentityToken) Encode(e *Encoder) {
+	e.String(t.PolicyId)
+	e.String(t.UserName)
+	e.ByteString(t.Password)
+	e.String(t.EncryptionAlgorithm)
+}
+
+type X509IdentityToken struct {
+	PolicyId        string
+	CertificateData []byte
+}
+
+func (t *X509IdentityToken) Encode(e *Encoder) {
+	e.String(t.PolicyId)
+	e.ByteString(t.CertificateData)
+}
+
+type ActivateSessionRequest struct {
+	RequestHeader
+	ClientSignature    SignatureData
+	UserIdentityToken  ExtensionObject
+	UserTokenSignature SignatureData
+}
+
+func (r *ActivateSessionRequest) TypeId() uint32         { return IdActivateSessionRequest }
+func (r *ActivateSessionRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *ActivateSessionRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	r.ClientSignature.Encode(e)
+	e.Int32(-1)        // ClientSoftwareCertificates
+	e.StringArray(nil) // LocaleIds
+	e.ExtensionObject(r.UserIdentityToken)
+	r.UserTokenSignature.Encode(e)
+}
+
+type ActivateSessionResponse struct {
+	ResponseHeader
+	ServerNonce []byte
+	Results     []StatusCode
+}
+
+func (r *ActivateSessionResponse) TypeId() uint32          { return IdActivateSessionResponse }
+func (r *ActivateSessionResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *ActivateSessionResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.ServerNonce = d.ByteString()
+	r.Results = d.StatusCodeArray()
+	d.DiagnosticInfoArray()
+}
+
+type CloseSessionRequest struct {
+	RequestHeader
+	DeleteSubscriptions bool
+}
+
+func (r *CloseSessionRequest) TypeId() uint32         { return IdCloseSessionRequest }
+func (r *CloseSessionRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *CloseSessionRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.Boolean(r.DeleteSubscriptions)
+}
+
+type CloseSessionResponse struct {
+	ResponseHeader
+}
+
+func (r *CloseSessionResponse) TypeId() uint32          { return IdCloseSessionResponse }
+func (r *CloseSessionResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *CloseSessionResponse) Decode(d *Decoder)       { r.ResponseHeader.Decode(d) }
+
+type ReadValueId struct {
+	NodeId      NodeId
+	AttributeId AttributeId
+}
+
+func (r *ReadValueId) Encode(e *Encoder) {
+	e.NodeId(r.NodeId)
+	e.UInt32(uint32(r.AttributeId))
+	e.String("")                     // IndexRange
+	e.QualifiedName(QualifiedName{}) // DataEncoding
+}
+
+type ReadRequest struct {
+	RequestHeader
+	MaxAge             float64
+	TimestampsToReturn TimestampsToReturn
+	NodesToRead        []*ReadValueId
+}
+
+func (r *ReadRequest) TypeId() uint32         { return IdReadRequest }
+func (r *ReadRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *ReadRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.Double(r.MaxAge)
+	e.Int32(int32(r.TimestampsToReturn))
+	e.Int32(int32(len(r.NodesToRead)))
+	for _, node := range r.NodesToRead {
+		node.Encode(e)
+	}
+}
+
+type ReadResponse struct {
+	ResponseHeader
+	Results []*DataValue
+}
+
+func (r *ReadResponse) TypeId() uint32          { return IdReadResponse }
+func (r *ReadResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *ReadResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Results = make([]*DataValue, d.ArrayLength())
+	for i := range r.Results {
+		r.Results[i] = d.DataValue()
+	}
+	d.DiagnosticInfoArray()
+}
+
+type WriteValue struct {
+	NodeId      NodeId
+	AttributeId AttributeId
+	Value       *DataValue
+}
+
+type WriteRequest struct {
+	RequestHeader
+	NodesToWrite []*WriteValue
+}
+
+func (r *WriteRequest) TypeId() uint32         { return IdWriteRequest }
+func (r *WriteRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *WriteRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.Int32(int32(len(r.NodesToWrite)))
+	for _, node := range r.NodesToWrite {
+		e.NodeId(node.NodeId)
+		e.UInt32(uint32(node.AttributeId))
+		e.String("") // IndexRange
+		e.DataValue(node.Value)
+	}
+}
+
+type WriteResponse struct {
+	ResponseHeader
+	Results []StatusCode
+}
+
+func (r *WriteResponse) TypeId() uint32          { return IdWriteResponse }
+func (r *WriteResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *WriteResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Results = d.StatusCodeArray()
+	d.DiagnosticInfoArray()
+}
+
+type BrowseDescription struct {
+	NodeId          NodeId
+	BrowseDirection BrowseDirection
+	ReferenceTypeId NodeId
+	IncludeSubtypes bool
+	NodeClassMask   uint32
+	ResultMask      uint32
+}
+
+type BrowseRequest struct {
+	RequestHeader
+	RequestedMaxReferencesPerNode uint32
+	NodesToBrowse                 []*BrowseDescription
+}
+
+func (r *BrowseRequest) TypeId() uint32         { return IdBrowseRequest }
+func (r *BrowseRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *BrowseRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	// View 为空表示整个地址空间
+	e.NodeId(NodeId{})
+	e.DateTime(time.Time{})
+	e.UInt32(0)
+	e.UInt32(r.RequestedMaxReferencesPerNode)
+	e.Int32(int32(len(r.NodesToBrowse)))
+	for _, node := range r.NodesToBrowse {
+		e.NodeId(node.NodeId)
+		e.Int32(int32(node.BrowseDirection))
+		e.NodeId(node.ReferenceTypeId)
+		e.Boolean(node.IncludeSubtypes)
+		e.UInt32(node.NodeClassMask)
+		e.UInt32(node.ResultMask)
+	}
+}
+
+type ReferenceDescription struct {
+	ReferenceTypeId NodeId
+	IsForward       bool
+	NodeId          ExpandedNodeId
+	BrowseName      QualifiedName
+	DisplayName     LocalizedText
+	NodeClass       NodeClass
+	TypeDefinition  ExpandedNodeId
+}
+
+type BrowseResult struct {
+	StatusCode        StatusCode
+	ContinuationPoint []byte
+	References        []*ReferenceDescription
+}
+
+func decodeBrowseResults(d *Decoder) []*BrowseResult {
+	results := make([]*BrowseResult, d.ArrayLength())
+	for i := range results {
+		result := &BrowseResult{
+			StatusCode:        StatusCode(d.UInt32()),
+			ContinuationPoint: d.ByteString(),
+		}
+		result.References = make([]*ReferenceDescription, d.ArrayLength())
+		for j := range result.References {
+			result.References[j] = &ReferenceDescription{
+				ReferenceTypeId: d.NodeId(),
+				IsForward:       d.Boolean(),
+				NodeId:          d.ExpandedNodeId(),
+				BrowseName:      d.QualifiedName(),
+				DisplayName:     d.LocalizedText(),
+				NodeClass:       NodeClass(d.Int32()),
+				TypeDefinition:  d.ExpandedNodeId(),
+			}
+		}
+		results[i] = result
+	}
+	return results
+}
+
+type BrowseResponse struct {
+	ResponseHeader
+	Results []*BrowseResult
+}
+
+func (r *BrowseResponse) TypeId() uint32          { return IdBrowseResponse }
+func (r *BrowseResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *BrowseResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Results = decodeBrowseResults(d)
+	d.DiagnosticInfoArray()
+}
+
+type BrowseNextRequest struct {
+	RequestHeader
+	ReleaseContinuationPoints bool
+	ContinuationPoints        [][]byte
+}
+
+func (r *BrowseNextRequest) TypeId() uint32         { return IdBrowseNextRequest }
+func (r *BrowseNextRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *BrowseNextRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.Boolean(r.ReleaseContinuationPoints)
+	e.Int32(int32(len(r.ContinuationPoints)))
+	for _, cp := range r.ContinuationPoints {
+		e.ByteString(cp)
+	}
+}
+
+type BrowseNextResponse struct {
+	ResponseHeader
+	Results []*BrowseResult
+}
+
+func (r *BrowseNextResponse) TypeId() uint32          { return IdBrowseNextResponse }
+func (r *BrowseNextResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *BrowseNextResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Results = decodeBrowseResults(d)
+	d.DiagnosticInfoArray()
+}
+
+type CreateSubscriptionRequest struct {
+	RequestHeader
+	RequestedPublishingInterval float64 // 毫秒
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	PublishingEnabled           bool
+	Priority                    byte
+}
+
+func (r *CreateSubscriptionRequest) TypeId() uint32         { return IdCreateSubscriptionRequest }
+func (r *CreateSubscriptionRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *CreateSubscriptionRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.Double(r.RequestedPublishingInterval)
+	e.UInt32(r.RequestedLifetimeCount)
+	e.UInt32(r.RequestedMaxKeepAliveCount)
+	e.UInt32(r.MaxNotificationsPerPublish)
+	e.Boolean(r.PublishingEnabled)
+	e.Byte(r.Priority)
+}
+
+type CreateSubscriptionResponse struct {
+	ResponseHeader
+	SubscriptionId            uint32
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+func (r *CreateSubscriptionResponse) TypeId() uint32          { return IdCreateSubscriptionResponse }
+func (r *CreateSubscriptionResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *CreateSubscriptionResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.SubscriptionId = d.UInt32()
+	r.RevisedPublishingInterval = d.Double()
+	r.RevisedLifetimeCount = d.UInt32()
+	r.RevisedMaxKeepAliveCount = d.UInt32()
+}
+
+type MonitoredItemCreateRequest struct {
+	ItemToMonitor    ReadValueId
+	MonitoringMode   MonitoringMode
+	ClientHandle     uint32
+	SamplingInterval float64 // 毫秒, -1表示与发布周期相同
+	QueueSize        uint32
+	DiscardOldest    bool
+}
+
+type CreateMonitoredItemsRequest struct {
+	RequestHeader
+	SubscriptionId     uint32
+	TimestampsToReturn TimestampsToReturn
+	ItemsToCreate      []*MonitoredItemCreateRequest
+}
+
+func (r *CreateMonitoredItemsRequest) TypeId() uint32         { return IdCreateMonitoredItemsRequest }
+func (r *CreateMonitoredItemsRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *CreateMonitoredItemsRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.UInt32(r.SubscriptionId)
+	e.Int32(int32(r.TimestampsToReturn))
+	e.Int32(int32(len(r.ItemsToCreate)))
+	for _, item := range r.ItemsToCreate {
+		item.ItemToMonitor.Encode(e)
+		e.Int32(int32(item.MonitoringMode))
+		e.UInt32(item.ClientHandle)
+		e.Double(item.SamplingInterval)
+		e.ExtensionObject(ExtensionObject{}) // Filter 默认按值变化上报
+		e.UInt32(item.QueueSize)
+		e.Boolean(item.DiscardOldest)
+	}
+}
+
+type MonitoredItemCreateResult struct {
+	StatusCode              StatusCode
+	MonitoredItemId         uint32
+	RevisedSamplingInterval float64
+	RevisedQueueSize        uint32
+}
+
+type CreateMonitoredItemsResponse struct {
+	ResponseHeader
+	Results []*MonitoredItemCreateResult
+}
+
+func (r *CreateMonitoredItemsResponse) TypeId() uint32          { return IdCreateMonitoredItemsResponse }
+func (r *CreateMonitoredItemsResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *CreateMonitoredItemsResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Results = make([]*MonitoredItemCreateResult, d.ArrayLength())
+	for i := range r.Results {
+		r.Results[i] = &MonitoredItemCreateResult{
+			StatusCode:              StatusCode(d.UInt32()),
+			MonitoredItemId:         d.UInt32(),
+			RevisedSamplingInterval: d.Double(),
+			RevisedQueueSize:        d.UInt32(),
+		}
+		d.ExtensionObject() // FilterResult
+	}
+	d.DiagnosticInfoArray()
+}
+
+type SubscriptionAcknowledgement struct {
+	SubscriptionId uint32
+	SequenceNumber uint32
+}
+
+type PublishRequest struct {
+	RequestHeader
+	SubscriptionAcknowledgements []*SubscriptionAcknowledgement
+}
+
+func (r *PublishRequest) TypeId() uint32         { return IdPublishRequest }
+func (r *PublishRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *PublishRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.Int32(int32(len(r.SubscriptionAcknowledgements)))
+	for _, ack := range r.SubscriptionAcknowledgements {
+		e.UInt32(ack.SubscriptionId)
+		e.UInt32(ack.SequenceNumber)
+	}
+}
+
+type MonitoredItemNotification struct {
+	ClientHandle uint32
+	Value        *DataValue
+}
+
+type PublishResponse struct {
+	ResponseHeader
+	SubscriptionId    uint32
+	MoreNotifications bool
+	SequenceNumber    uint32
+	PublishTime       time.Time
+	// Notifications 通知个数, 为0时是保活消息, 序列号无需确认
+	Notifications int
+	// DataChanges 通知中的数据变化, 其它类型的通知忽略
+	DataChanges []*MonitoredItemNotification
+	// StatusChange 订阅状态变化, 例如订阅超时被服务器删除
+	StatusChange *StatusCode
+	Results      []StatusCode
+}
+
+func (r *PublishResponse) TypeId() uint32          { return IdPublishResponse }
+func (r *PublishResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *PublishResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.SubscriptionId = d.UInt32()
+	d.UInt32Array() // AvailableSequenceNumbers
+	r.MoreNotifications = d.Boolean()
+	r.SequenceNumber = d.UInt32()
+	r.PublishTime = d.DateTime()
+	r.Notifications = d.ArrayLength()
+	for i := 0; i < r.Notifications; i++ {
+		x := d.ExtensionObject()
+		if x.TypeId.Namespace != 0 || x.TypeId.Type != IdTypeNumeric {
+			continue
+		}
+		switch x.TypeId.Numeric {
+		case IdDataChangeNotification:
+			nd := NewDecoder(x.Body)
+			for j, m := 0, nd.ArrayLength(); j < m; j++ {
+				r.DataChanges = append(r.DataChanges, &MonitoredItemNotification{
+					ClientHandle: nd.UInt32(),
+					Value:        nd.DataValue(),
+				})
+			}
+			if nd.Err() != nil && d.err == nil {
+				d.err = nd.Err()
+			}
+		case IdStatusChangeNotification:
+			status := StatusCode(NewDecoder(x.Body).UInt32())
+			r.StatusChange = &status
+		}
+	}
+	r.Results = d.StatusCodeArray()
+	d.DiagnosticInfoArray()
+}
+
+type DeleteSubscriptionsRequest struct {
+	RequestHeader
+	SubscriptionIds []uint32
+}
+
+func (r *DeleteSubscriptionsRequest) TypeId() uint32         { return IdDeleteSubscriptionsRequest }
+func (r *DeleteSubscriptionsRequest) Header() *RequestHeader { return &r.RequestHeader }
+func (r *DeleteSubscriptionsRequest) Encode(e *Encoder) {
+	r.RequestHeader.Encode(e)
+	e.UInt32Array(r.SubscriptionIds)
+}
+
+type DeleteSubscriptionsResponse struct {
+	ResponseHeader
+	Results []StatusCode
+}
+
+func (r *DeleteSubscriptionsResponse) TypeId() uint32          { return IdDeleteSubscriptionsResponse }
+func (r *DeleteSubscriptionsResponse) Header() *ResponseHeader { return &r.ResponseHeader }
+func (r *DeleteSubscriptionsResponse) Decode(d *Decoder) {
+	r.ResponseHeader.Decode(d)
+	r.Results = d.StatusCodeArray()
+	d.DiagnosticInfoArray()
+}
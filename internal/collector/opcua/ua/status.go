@@ -0,0 +1,129 @@
+package ua
+
+import "fmt"
+
+// StatusCode 高两位 00 Good 01 Uncertain 10 Bad
+type StatusCode uint32
+
+const (
+	StatusGood      StatusCode = 0x00000000
+	StatusUncertain StatusCode = 0x40000000
+	StatusBad       StatusCode = 0x80000000
+
+	StatusBadUnexpectedError             StatusCode = 0x80010000
+	StatusBadInternalError               StatusCode = 0x80020000
+	StatusBadCommunicationError          StatusCode = 0x80050000
+	StatusBadEncodingError               StatusCode = 0x80060000
+	StatusBadDecodingError               StatusCode = 0x80070000
+	StatusBadTimeout                     StatusCode = 0x800A0000
+	StatusBadServiceUnsupported          StatusCode = 0x800B0000
+	StatusBadShutdown                    StatusCode = 0x800C0000
+	StatusBadNothingToDo                 StatusCode = 0x800F0000
+	StatusBadTooManyOperations           StatusCode = 0x80100000
+	StatusBadCertificateInvalid          StatusCode = 0x80120000
+	StatusBadSecurityChecksFailed        StatusCode = 0x80130000
+	StatusBadUserAccessDenied            StatusCode = 0x801F0000
+	StatusBadIdentityTokenInvalid        StatusCode = 0x80200000
+	StatusBadIdentityTokenRejected       StatusCode = 0x80210000
+	StatusBadSecureChannelIdInvalid      StatusCode = 0x80220000
+	StatusBadSessionIdInvalid            StatusCode = 0x80250000
+	StatusBadSessionClosed               StatusCode = 0x80260000
+	StatusBadSessionNotActivated         StatusCode = 0x80270000
+	StatusBadSubscriptionIdInvalid       StatusCode = 0x80280000
+	StatusBadWaitingForInitialData       StatusCode = 0x80320000
+	StatusBadNodeIdInvalid               StatusCode = 0x80330000
+	StatusBadNodeIdUnknown               StatusCode = 0x80340000
+	StatusBadAttributeIdInvalid          StatusCode = 0x80350000
+	StatusBadNotReadable                 StatusCode = 0x803A0000
+	StatusBadNotWritable                 StatusCode = 0x803B0000
+	StatusBadOutOfRange                  StatusCode = 0x803C0000
+	StatusBadNotSupported                StatusCode = 0x803D0000
+	StatusBadMonitoredItemIdInvalid      StatusCode = 0x80420000
+	StatusBadMonitoredItemFilterInvalid  StatusCode = 0x80430000
+	StatusBadContinuationPointInvalid    StatusCode = 0x804A0000
+	StatusBadNoContinuationPoints        StatusCode = 0x804B0000
+	StatusBadSecurityPolicyRejected      StatusCode = 0x80550000
+	StatusBadTypeMismatch                StatusCode = 0x80740000
+	StatusBadTooManyPublishRequests      StatusCode = 0x80780000
+	StatusBadNoSubscription              StatusCode = 0x80790000
+	StatusBadTcpMessageTypeInvalid       StatusCode = 0x807E0000
+	StatusBadTcpSecureChannelUnknown     StatusCode = 0x807F0000
+	StatusBadTcpMessageTooLarge          StatusCode = 0x80800000
+	StatusBadTcpEndpointUrlInvalid       StatusCode = 0x80830000
+	StatusBadSecureChannelClosed         StatusCode = 0x80860000
+	StatusBadSecureChannelTokenUnknown   StatusCode = 0x80870000
+	StatusBadInvalidArgument             StatusCode = 0x80AB0000
+	StatusBadConnectionClosed            StatusCode = 0x80AE0000
+	StatusBadInvalidState                StatusCode = 0x80AF0000
+	StatusBadUserSignatureInvalid        StatusCode = 0x80570000
+	StatusBadApplicationSignatureInvalid StatusCode = 0x80580000
+)
+
+var StatusCodeToString = map[StatusCode]string{
+	StatusGood:                           "Good",
+	StatusUncertain:                      "Uncertain",
+	StatusBad:                            "Bad",
+	StatusBadUnexpectedError:             "BadUnexpectedError",
+	StatusBadInternalError:               "BadInternalError",
+	StatusBadCommunicationError:          "BadCommunicationError",
+	StatusBadEncodingError:               "BadEncodingError",
+	StatusBadDecodingError:               "BadDecodingError",
+	StatusBadTimeout:                     "BadTimeout",
+	StatusBadServiceUnsupported:          "BadServiceUnsupported",
+	StatusBadShutdown:                    "BadShutdown",
+	StatusBadNothingToDo:                 "BadNothingToDo",
+	StatusBadTooManyOperations:           "BadTooManyOperations",
+	StatusBadCertificateInvalid:          "BadCertificateInvalid",
+	StatusBadSecurityChecksFailed:        "BadSecurityChecksFailed",
+	StatusBadUserAccessDenied:            "BadUserAccessDenied",
+	StatusBadIdentityTokenInvalid:        "BadIdentityTokenInvalid",
+	StatusBadIdentityTokenRejected:       "BadIdentityTokenRejected",
+	StatusBadSecureChannelIdInvalid:      "BadSecureChannelIdInvalid",
+	StatusBadSessionIdInvalid:            "BadSessionIdInvalid",
+	StatusBadSessionClosed:               "BadSessionClosed",
+	StatusBadSessionNotActivated:         "BadSessionNotActivated",
+	StatusBadSubscriptionIdInvalid:       "BadSubscriptionIdInvalid",
+	StatusBadWaitingForInitialData:       "BadWaitingForInitialData",
+	StatusBadNodeIdInvalid:               "BadNodeIdInvalid",
+	StatusBadNodeIdUnknown:               "BadNodeIdUnknown",
+	StatusBadAttributeIdInvalid:          "BadAttributeIdInvalid",
+	StatusBadNotReadable:                 "BadNotReadable",
+	StatusBadNotWritable:                 "BadNotWritable",
+	StatusBadOutOfRange:                  "BadOutOfRange",
+	StatusBadNotSupported:                "BadNotSupported",
+	StatusBadMonitoredItemIdInvalid:      "BadMonitoredItemIdInvalid",
+	StatusBadMonitoredItemFilterInvalid:  "BadMonitoredItemFilterInvalid",
+	StatusBadContinuationPointInvalid:    "BadContinuationPointInvalid",
+	StatusBadNoContinuationPoints:        "BadNoContinuationPoints",
+	StatusBadSecurityPolicyRejected:      "BadSecurityPolicyRejected",
+	StatusBadTypeMismatch:                "BadTypeMismatch",
+	StatusBadTooManyPublishRequests:      "BadTooManyPublishRequests",
+	StatusBadNoSubscription:              "BadNoSubscription",
+	StatusBadTcpMessageTypeInvalid:       "BadTcpMessageTypeInvalid",
+	StatusBadTcpSecureChannelUnknown:     "BadTcpSecureChannelUnknown",
+	StatusBadTcpMessageTooLarge:          "BadTcpMessageTooLarge",
+	StatusBadTcpEndpointUrlInvalid:       "BadTcpEndpointUrlInvalid",
+	StatusBadSecureChannelClosed:         "BadSecureChannelClosed",
+	StatusBadSecureChannelTokenUnknown:   "BadSecureChannelTokenUnknown",
+	StatusBadInvalidArgument:             "BadInvalidArgument",
+	StatusBadConnectionClosed:            "BadConnectionClosed",
+	StatusBadInvalidState:                "BadInvalidState",
+	StatusBadUserSignatureInvalid:        "BadUserSignatureInvalid",
+	StatusBadApplicationSignatureInvalid: "BadApplicationSignatureInvalid",
+}
+
+func (s StatusCode) IsGood() bool {
+	return s&0xC0000000 == 0
+}
+
+func (s StatusCode) IsBad() bool {
+	return s&0x80000000 != 0
+}
+
+// Error 低16位为附加信息, 按高16位查找名称
+func (s StatusCode) Error() string {
+	if name, ok := StatusCodeToString[s&0xFFFF0000]; ok {
+		return fmt.Sprintf("opcua status %s (0x%08X)", name, uint32(s))
+	}
+	return fmt.Sprintf("opcua status 0x%08X", uint32(s))
+}
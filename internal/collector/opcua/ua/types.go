@@ -0,0 +1,447 @@
+package ua
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Guid struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+func (g Guid) String() string {
+	return fmt.Sprintf("%08X-%04X-%04X-%04X-%012X", g.Data1, g.Data2, g.Data3, g.Data4[:2], g.Data4[2:])
+}
+
+// ParseGuid 解析 XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX
+func ParseGuid(s string) (Guid, error) {
+	var g Guid
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return g, ErrNodeIdInvalid
+	}
+	d1, err1 := strconv.ParseUint(parts[0], 16, 32)
+	d2, err2 := strconv.ParseUint(parts[1], 16, 16)
+	d3, err3 := strconv.ParseUint(parts[2], 16, 16)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return g, ErrNodeIdInvalid
+	}
+	g.Data1, g.Data2, g.Data3 = uint32(d1), uint16(d2), uint16(d3)
+	tail := parts[3] + parts[4]
+	for i := 0; i < 8; i++ {
+		b, err := strconv.ParseUint(tail[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return g, ErrNodeIdInvalid
+		}
+		g.Data4[i] = byte(b)
+	}
+	return g, nil
+}
+
+type IdType byte
+
+const (
+	IdTypeNumeric IdType = iota
+	IdTypeString
+	IdTypeGuid
+	IdTypeOpaque
+)
+
+// NodeId 节点标识, Value按IdType分别为uint32、string、Guid、[]byte
+type NodeId struct {
+	Namespace uint16
+	Type      IdType
+	Numeric   uint32
+	Str       string
+	Guid      Guid
+	Opaque    []byte
+}
+
+func NewNumericNodeId(namespace uint16, id uint32) NodeId {
+	return NodeId{Namespace: namespace, Type: IdTypeNumeric, Numeric: id}
+}
+
+func NewStringNodeId(namespace uint16, id string) NodeId {
+	return NodeId{Namespace: namespace, Type: IdTypeString, Str: id}
+}
+
+func (n NodeId) IsNull() bool {
+	return n.Namespace == 0 && n.Type == IdTypeNumeric && n.Numeric == 0
+}
+
+// String 按 ns=2;s=Name 格式输出, 命名空间为0时省略
+func (n NodeId) String() string {
+	var prefix string
+	if n.Namespace != 0 {
+		prefix = fmt.Sprintf("ns=%d;", n.Namespace)
+	}
+	switch n.Type {
+	case IdTypeString:
+		return prefix + "s=" + n.Str
+	case IdTypeGuid:
+		return prefix + "g=" + n.Guid.String()
+	case IdTypeOpaque:
+		return prefix + "b=" + base64.StdEncoding.EncodeToString(n.Opaque)
+	default:
+		return prefix + "i=" + strconv.FormatUint(uint64(n.Numeric), 10)
+	}
+}
+
+// ParseNodeId 解析 ns=<命名空间>;<i|s|g|b>=<标识>, 省略ns时命名空间为0
+func ParseNodeId(s string) (NodeId, error) {
+	var n NodeId
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "ns=") {
+		i := strings.IndexByte(s, ';')
+		if i < 0 {
+			return n, ErrNodeIdInvalid
+		}
+		ns, err := strconv.ParseUint(s[3:i], 10, 16)
+		if err != nil {
+			return n, ErrNodeIdInvalid
+		}
+		n.Namespace = uint16(ns)
+		s = s[i+1:]
+	}
+	if len(s) < 3 || s[1] != '=' {
+		return n, ErrNodeIdInvalid
+	}
+	id := s[2:]
+	switch s[0] {
+	case 'i':
+		v, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return n, ErrNodeIdInvalid
+		}
+		n.Type, n.Numeric = IdTypeNumeric, uint32(v)
+	case 's':
+		n.Type, n.Str = IdTypeString, id
+	case 'g':
+		g, err := ParseGuid(id)
+		if err != nil {
+			return n, err
+		}
+		n.Type, n.Guid = IdTypeGuid, g
+	case 'b':
+		b, err := base64.StdEncoding.DecodeString(id)
+		if err != nil {
+			return n, ErrNodeIdInvalid
+		}
+		n.Type, n.Opaque = IdTypeOpaque, b
+	default:
+		return n, ErrNodeIdInvalid
+	}
+	return n, nil
+}
+
+// NodeId编码 (Part 6 5.2.2.9)
+const (
+	nodeIdTwoByte    byte = 0x00
+	nodeIdFourByte   byte = 0x01
+	nodeIdNumeric    byte = 0x02
+	nodeIdString     byte = 0x03
+	nodeIdGuid       byte = 0x04
+	nodeIdByteString byte = 0x05
+
+	expandedNamespaceUri byte = 0x80
+	expandedServerIndex  byte = 0x40
+)
+
+func (e *Encoder) NodeId(n NodeId) {
+	e.nodeId(n, 0)
+}
+
+func (e *Encoder) nodeId(n NodeId, flags byte) {
+	switch n.Type {
+	case IdTypeString:
+		e.Byte(nodeIdString | flags)
+		e.UInt16(n.Namespace)
+		e.String(n.Str)
+	case IdTypeGuid:
+		e.Byte(nodeIdGuid | flags)
+		e.UInt16(n.Namespace)
+		e.Guid(n.Guid)
+	case IdTypeOpaque:
+		e.Byte(nodeIdByteString | flags)
+		e.UInt16(n.Namespace)
+		e.ByteString(n.Opaque)
+	default:
+		switch {
+		case n.Namespace == 0 && n.Numeric <= 0xFF:
+			e.Byte(nodeIdTwoByte | flags)
+			e.Byte(byte(n.Numeric))
+		case n.Namespace <= 0xFF && n.Numeric <= 0xFFFF:
+			e.Byte(nodeIdFourByte | flags)
+			e.Byte(byte(n.Namespace))
+			e.UInt16(uint16(n.Numeric))
+		default:
+			e.Byte(nodeIdNumeric | flags)
+			e.UInt16(n.Namespace)
+			e.UInt32(n.Numeric)
+		}
+	}
+}
+
+func (d *Decoder) NodeId() NodeId {
+	n, _ := d.nodeId()
+	return n
+}
+
+func (d *Decoder) nodeId() (NodeId, byte) {
+	var n NodeId
+	flags := d.Byte()
+	switch flags & 0x0F {
+	case nodeIdTwoByte:
+		n.Numeric = uint32(d.Byte())
+	case nodeIdFourByte:
+		n.Namespace = uint16(d.Byte())
+		n.Numeric = uint32(d.UInt16())
+	case nodeIdNumeric:
+		n.Namespace = d.UInt16()
+		n.Numeric = d.UInt32()
+	case nodeIdString:
+		n.Namespace = d.UInt16()
+		n.Type, n.Str = IdTypeString, d.String()
+	case nodeIdGuid:
+		n.Namespace = d.UInt16()
+		n.Type, n.Guid = IdTypeGuid, d.Guid()
+	case nodeIdByteString:
+		n.Namespace = d.UInt16()
+		n.Type, n.Opaque = IdTypeOpaque, d.ByteString()
+	default:
+		if d.err == nil {
+			d.err = ErrDecodeInvalid
+		}
+	}
+	return n, flags & 0xF0
+}
+
+// ExpandedNodeId 可指向其它服务器的节点
+type ExpandedNodeId struct {
+	NodeId       NodeId
+	NamespaceUri string
+	ServerIndex  uint32
+}
+
+func (e *Encoder) ExpandedNodeId(n ExpandedNodeId) {
+	var flags byte
+	if len(n.NamespaceUri) > 0 {
+		flags |= expandedNamespaceUri
+	}
+	if n.ServerIndex > 0 {
+		flags |= expandedServerIndex
+	}
+	e.nodeId(n.NodeId, flags)
+	if flags&expandedNamespaceUri != 0 {
+		e.String(n.NamespaceUri)
+	}
+	if flags&expandedServerIndex != 0 {
+		e.UInt32(n.ServerIndex)
+	}
+}
+
+func (d *Decoder) ExpandedNodeId() ExpandedNodeId {
+	var n ExpandedNodeId
+	var flags byte
+	n.NodeId, flags = d.nodeId()
+	if flags&expandedNamespaceUri != 0 {
+		n.NamespaceUri = d.String()
+	}
+	if flags&expandedServerIndex != 0 {
+		n.ServerIndex = d.UInt32()
+	}
+	return n
+}
+
+type QualifiedName struct {
+	Namespace uint16
+	Name      string
+}
+
+func (q QualifiedName) String() string {
+	if q.Namespace == 0 {
+		return q.Name
+	}
+	return fmt.Sprintf("%d:%s", q.Namespace, q.Name)
+}
+
+func (e *Encoder) QualifiedName(q QualifiedName) {
+	e.UInt16(q.Namespace)
+	e.String(q.Name)
+}
+
+func (d *Decoder) QualifiedName() QualifiedName {
+	return QualifiedName{Namespace: d.UInt16(), Name: d.String()}
+}
+
+type LocalizedText struct {
+	Locale string
+	Text   string
+}
+
+func (e *Encoder) LocalizedText(l LocalizedText) {
+	var mask byte
+	if len(l.Locale) > 0 {
+		mask |= 0x01
+	}
+	if len(l.Text) > 0 {
+		mask |= 0x02
+	}
+	e.Byte(mask)
+	if mask&0x01 != 0 {
+		e.String(l.Locale)
+	}
+	if mask&0x02 != 0 {
+		e.String(l.Text)
+	}
+}
+
+func (d *Decoder) LocalizedText() LocalizedText {
+	var l LocalizedText
+	mask := d.Byte()
+	if mask&0x01 != 0 {
+		l.Locale = d.String()
+	}
+	if mask&0x02 != 0 {
+		l.Text = d.String()
+	}
+	return l
+}
+
+// ExtensionObject 结构体按TypeId编码后作为字节串, Body为空表示无内容
+type ExtensionObject struct {
+	TypeId NodeId
+	Body   []byte
+}
+
+func (e *Encoder) ExtensionObject(x ExtensionObject) {
+	e.NodeId(x.TypeId)
+	if x.Body == nil {
+		e.Byte(0x00)
+		return
+	}
+	e.Byte(0x01)
+	e.ByteString(x.Body)
+}
+
+func (d *Decoder) ExtensionObject() ExtensionObject {
+	var x ExtensionObject
+	x.TypeId = d.NodeId()
+	switch d.Byte() {
+	case 0x00:
+	case 0x01, 0x02:
+		x.Body = d.ByteString()
+	default:
+		if d.err == nil {
+			d.err = ErrDecodeInvalid
+		}
+	}
+	return x
+}
+
+// NewExtensionObject 编码结构体为ExtensionObject
+func NewExtensionObject(typeId uint32, body Encodable) ExtensionObject {
+	e := NewEncoder()
+	body.Encode(e)
+	return ExtensionObject{TypeId: NewNumericNodeId(0, typeId), Body: e.Bytes()}
+}
+
+// DataValue 属性值及状态与时间戳
+type DataValue struct {
+	Value           *Variant
+	Status          StatusCode
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+const (
+	dataValueValue             byte = 0x01
+	dataValueStatus            byte = 0x02
+	dataValueSourceTimestamp   byte = 0x04
+	dataValueServerTimestamp   byte = 0x08
+	dataValueSourcePicoseconds byte = 0x10
+	dataValueServerPicoseconds byte = 0x20
+)
+
+func (e *Encoder) DataValue(v *DataValue) {
+	var mask byte
+	if v.Value != nil {
+		mask |= dataValueValue
+	}
+	if v.Status != StatusGood {
+		mask |= dataValueStatus
+	}
+	if !v.SourceTimestamp.IsZero() {
+		mask |= dataValueSourceTimestamp
+	}
+	if !v.ServerTimestamp.IsZero() {
+		mask |= dataValueServerTimestamp
+	}
+	e.Byte(mask)
+	if mask&dataValueValue != 0 {
+		e.Variant(v.Value)
+	}
+	if mask&dataValueStatus != 0 {
+		e.UInt32(uint32(v.Status))
+	}
+	if mask&dataValueSourceTimestamp != 0 {
+		e.DateTime(v.SourceTimestamp)
+	}
+	if mask&dataValueServerTimestamp != 0 {
+		e.DateTime(v.ServerTimestamp)
+	}
+}
+
+func (d *Decoder) DataValue() *DataValue {
+	v := &DataValue{}
+	mask := d.Byte()
+	if mask&dataValueValue != 0 {
+		v.Value = d.Variant()
+	}
+	if mask&dataValueStatus != 0 {
+		v.Status = StatusCode(d.UInt32())
+	}
+	if mask&dataValueSourceTimestamp != 0 {
+		v.SourceTimestamp = d.DateTime()
+	}
+	if mask&dataValueSourcePicoseconds != 0 {
+		d.UInt16()
+	}
+	if mask&dataValueServerTimestamp != 0 {
+		v.ServerTimestamp = d.DateTime()
+	}
+	if mask&dataValueServerPicoseconds != 0 {
+		d.UInt16()
+	}
+	return v
+}
+
+// DiagnosticInfo 只解码用于跳过, 不保留内容
+func (d *Decoder) DiagnosticInfo() {
+	mask := d.Byte()
+	for _, bit := range []byte{0x01, 0x02, 0x08, 0x04} {
+		if mask&bit != 0 {
+			d.Int32()
+		}
+	}
+	if mask&0x10 != 0 {
+		_ = d.String()
+	}
+	if mask&0x20 != 0 {
+		d.UInt32()
+	}
+	if mask&0x40 != 0 {
+		d.DiagnosticInfo()
+	}
+}
+
+// Encodable 可按OPC UA Binary编码的结构体
+type Encodable interface {
+	Encode(e *Encoder)
+}
@@ -0,0 +1,299 @@
+package ua
+
+import (
+	"time"
+)
+
+// TypeId Variant的内置类型 (Part 6 5.1.2)
+type TypeId byte
+
+const (
+	TypeNull TypeId = iota
+	TypeBoolean
+	TypeSByte
+	TypeByte
+	TypeInt16
+	TypeUInt16
+	TypeInt32
+	TypeUInt32
+	TypeInt64
+	TypeUInt64
+	TypeFloat
+	TypeDouble
+	TypeString
+	TypeDateTime
+	TypeGuid
+	TypeByteString
+	TypeXmlElement
+	TypeNodeId
+	TypeExpandedNodeId
+	TypeStatusCode
+	TypeQualifiedName
+	TypeLocalizedText
+	TypeExtensionObject
+	TypeDataValue
+	TypeVariant
+	TypeDiagnosticInfo
+)
+
+var TypeIdToString = map[TypeId]string{
+	TypeNull:            "null",
+	TypeBoolean:         "boolean",
+	TypeSByte:           "sbyte",
+	TypeByte:            "byte",
+	TypeInt16:           "int16",
+	TypeUInt16:          "uint16",
+	TypeInt32:           "int32",
+	TypeUInt32:          "uint32",
+	TypeInt64:           "int64",
+	TypeUInt64:          "uint64",
+	TypeFloat:           "float",
+	TypeDouble:          "double",
+	TypeString:          "string",
+	TypeDateTime:        "dateTime",
+	TypeGuid:            "guid",
+	TypeByteString:      "byteString",
+	TypeXmlElement:      "xmlElement",
+	TypeNodeId:          "nodeId",
+	TypeExpandedNodeId:  "expandedNodeId",
+	TypeStatusCode:      "statusCode",
+	TypeQualifiedName:   "qualifiedName",
+	TypeLocalizedText:   "localizedText",
+	TypeExtensionObject: "extensionObject",
+	TypeDataValue:       "dataValue",
+	TypeVariant:         "variant",
+	TypeDiagnosticInfo:  "diagnosticInfo",
+}
+
+// DataType 内置类型对应的DataType节点, 命名空间0下编号与TypeId相同
+func (t TypeId) DataType() NodeId {
+	return NewNumericNodeId(0, uint32(t))
+}
+
+const (
+	variantArray      byte = 0x80
+	variantDimensions byte = 0x40
+)
+
+// Variant 标量时Value为对应的Go类型, 数组时Value为[]interface{}, 多维数组按行展开
+type Variant struct {
+	Type  TypeId
+	Array bool
+	Value interface{}
+}
+
+// NewVariant 按Go类型推断内置类型, 不支持的类型返回ErrVariantType
+func NewVariant(v interface{}) (*Variant, error) {
+	t, ok := typeOf(v)
+	if !ok {
+		return nil, ErrVariantType
+	}
+	return &Variant{Type: t, Value: v}, nil
+}
+
+func typeOf(v interface{}) (TypeId, bool) {
+	switch v.(type) {
+	case nil:
+		return TypeNull, true
+	case bool:
+		return TypeBoolean, true
+	case int8:
+		return TypeSByte, true
+	case uint8:
+		return TypeByte, true
+	case int16:
+		return TypeInt16, true
+	case uint16:
+		return TypeUInt16, true
+	case int32:
+		return TypeInt32, true
+	case uint32:
+		return TypeUInt32, true
+	case int64:
+		return TypeInt64, true
+	case uint64:
+		return TypeUInt64, true
+	case float32:
+		return TypeFloat, true
+	case float64:
+		return TypeDouble, true
+	case string:
+		return TypeString, true
+	case time.Time:
+		return TypeDateTime, true
+	case Guid:
+		return TypeGuid, true
+	case []byte:
+		return TypeByteString, true
+	case NodeId:
+		return TypeNodeId, true
+	case ExpandedNodeId:
+		return TypeExpandedNodeId, true
+	case StatusCode:
+		return TypeStatusCode, true
+	case QualifiedName:
+		return TypeQualifiedName, true
+	case LocalizedText:
+		return TypeLocalizedText, true
+	case ExtensionObject:
+		return TypeExtensionObject, true
+	}
+	return TypeNull, false
+}
+
+func (e *Encoder) Variant(v *Variant) {
+	if v == nil || v.Type == TypeNull {
+		e.Byte(0)
+		return
+	}
+	if !v.Array {
+		e.Byte(byte(v.Type))
+		e.scalar(v.Type, v.Value)
+		return
+	}
+	values, _ := v.Value.([]interface{})
+	e.Byte(byte(v.Type) | variantArray)
+	e.Int32(int32(len(values)))
+	for _, value := range values {
+		e.scalar(v.Type, value)
+	}
+}
+
+func (e *Encoder) scalar(t TypeId, v interface{}) {
+	switch t {
+	case TypeBoolean:
+		e.Boolean(v.(bool))
+	case TypeSByte:
+		e.SByte(v.(int8))
+	case TypeByte:
+		e.Byte(v.(uint8))
+	case TypeInt16:
+		e.Int16(v.(int16))
+	case TypeUInt16:
+		e.UInt16(v.(uint16))
+	case TypeInt32:
+		e.Int32(v.(int32))
+	case TypeUInt32:
+		e.UInt32(v.(uint32))
+	case TypeInt64:
+		e.Int64(v.(int64))
+	case TypeUInt64:
+		e.UInt64(v.(uint64))
+	case TypeFloat:
+		e.Float(v.(float32))
+	case TypeDouble:
+		e.Double(v.(float64))
+	case TypeString, TypeXmlElement:
+		e.String(v.(string))
+	case TypeDateTime:
+		e.DateTime(v.(time.Time))
+	case TypeGuid:
+		e.Guid(v.(Guid))
+	case TypeByteString:
+		e.ByteString(v.([]byte))
+	case TypeNodeId:
+		e.NodeId(v.(NodeId))
+	case TypeExpandedNodeId:
+		e.ExpandedNodeId(v.(ExpandedNodeId))
+	case TypeStatusCode:
+		e.UInt32(uint32(v.(StatusCode)))
+	case TypeQualifiedName:
+		e.QualifiedName(v.(QualifiedName))
+	case TypeLocalizedText:
+		e.LocalizedText(v.(LocalizedText))
+	case TypeExtensionObject:
+		e.ExtensionObject(v.(ExtensionObject))
+	case TypeDataValue:
+		e.DataValue(v.(*DataValue))
+	case TypeVariant:
+		e.Variant(v.(*Variant))
+	default:
+		// DiagnosticInfo 不作为值写入
+		e.Byte(0)
+	}
+}
+
+func (d *Decoder) Variant() *Variant {
+	mask := d.Byte()
+	v := &Variant{Type: TypeId(mask & 0x3F)}
+	if v.Type > TypeDiagnosticInfo {
+		if d.err == nil {
+			d.err = ErrDecodeInvalid
+		}
+		return v
+	}
+	if mask&variantArray == 0 {
+		v.Value = d.scalar(v.Type)
+		return v
+	}
+	v.Array = true
+	n := d.ArrayLength()
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = d.scalar(v.Type)
+	}
+	v.Value = values
+	if mask&variantDimensions != 0 {
+		// 多维数组的维度, 值已按行展开
+		for i, dims := 0, d.ArrayLength(); i < dims; i++ {
+			d.Int32()
+		}
+	}
+	return v
+}
+
+func (d *Decoder) scalar(t TypeId) interface{} {
+	switch t {
+	case TypeNull:
+		return nil
+	case TypeBoolean:
+		return d.Boolean()
+	case TypeSByte:
+		return d.SByte()
+	case TypeByte:
+		return d.Byte()
+	case TypeInt16:
+		return d.Int16()
+	case TypeUInt16:
+		return d.UInt16()
+	case TypeInt32:
+		return d.Int32()
+	case TypeUInt32:
+		return d.UInt32()
+	case TypeInt64:
+		return d.Int64()
+	case TypeUInt64:
+		return d.UInt64()
+	case TypeFloat:
+		return d.Float()
+	case TypeDouble:
+		return d.Double()
+	case TypeString, TypeXmlElement:
+		return d.String()
+	case TypeDateTime:
+		return d.DateTime()
+	case TypeGuid:
+		return d.Guid()
+	case TypeByteString:
+		return d.ByteString()
+	case TypeNodeId:
+		return d.NodeId()
+	case TypeExpandedNodeId:
+		return d.ExpandedNodeId()
+	case TypeStatusCode:
+		return StatusCode(d.UInt32())
+	case TypeQualifiedName:
+		return d.QualifiedName()
+	case TypeLocalizedText:
+		return d.LocalizedText()
+	case TypeExtensionObject:
+		return d.ExtensionObject()
+	case TypeDataValue:
+		return d.DataValue()
+	case TypeVariant:
+		return d.Variant()
+	case TypeDiagnosticInfo:
+		d.DiagnosticInfo()
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/rest/runtime"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+	"net/url"
+	"strings"
+)
+
+/**
+写入
+写入地址包含{name}时, 每个变量向替换变量名后的地址单独请求, 默认请求体为JSON编码的值 例如 23.5
+否则全部变量在一个请求中写入, 默认请求体为JSON对象 例如 {"setpoint":23.5,"enable":true}
+配置了写入请求体模板时按模板渲染, 响应状态码为2xx视为下发成功
+*/
+
+// DeliverAction 返回每个变量的下发结果,存在失败时error为按变量名汇总的collector.MultiError
+func (broker *HttpBroker) DeliverAction(ctx context.Context, obj map[string]interface{}) ([]*collector.ActionResult, error) {
+	results := make([]*collector.ActionResult, 0, len(obj))
+	values := make(map[string]interface{}, len(obj))
+	actionResults := make([]*collector.ActionResult, 0, len(obj))
+
+	for name, value := range obj {
+		result := &collector.ActionResult{Name: name, Value: value}
+		results = append(results, result)
+
+		vv, exist := broker.Device.GetVariable(name)
+		if !exist {
+			result.Err = runtime.ErrVariableNotFound
+			continue
+		}
+		variable := vv.(*runtime.Variable)
+		if variable.AccessMode != common.AccessModeReadWrite {
+			result.Err = runtime.ErrVariableReadOnly
+			continue
+		}
+		converted, err := runtime.ActionValue(variable.DataType, value)
+		if err != nil {
+			klog.V(3).InfoS("Failed to convert action value", "variableName", variable.Name, "dataType", variable.DataType, "error", err)
+			result.Err = err
+			continue
+		}
+		result.Value = converted
+		values[name] = converted
+		actionResults = append(actionResults, result)
+	}
+
+	if strings.Contains(broker.Device.WriteUrl, runtime.WriteVariablePlaceholder) {
+		for _, result := range actionResults {
+			data := runtime.NewTemplateData()
+			data.Name, data.Value, data.Values = result.Name, result.Value, values
+			writeUrl := strings.ReplaceAll(broker.Device.WriteUrl, runtime.WriteVariablePlaceholder, url.PathEscape(result.Name))
+			result.Err = broker.write(ctx, writeUrl, data, result.Value)
+		}
+	} else if len(actionResults) > 0 {
+		data := runtime.NewTemplateData()
+		data.Values = values
+		err := broker.write(ctx, broker.Device.WriteUrl, data, values)
+		for _, result := range actionResults {
+			result.Err = err
+		}
+	}
+
+	setActionResultStatus(results)
+	return results, collector.NewActionMultiError(results)
+}
+
+// write 渲染请求体并发送写入请求, 未配置模板时请求体为JSON编码的value
+func (broker *HttpBroker) write(ctx context.Context, ref string, data *runtime.TemplateData, value interface{}) error {
+	writeUrl, err := ResolveUrl(broker.Device.Url, ref)
+	if err != nil {
+		return runtime.ErrWriteUrlInvalid
+	}
+	var body []byte
+	if broker.Device.WriteBody != nil {
+		body, err = runtime.Render(broker.Device.WriteBody, data)
+	} else {
+		body, err = json.Marshal(value)
+	}
+	if err != nil {
+		return runtime.ErrActionValueInvalid
+	}
+	if _, err = broker.do(ctx, broker.Device.WriteMethod, writeUrl, body); err != nil {
+		klog.V(2).InfoS("Failed to write Http endpoint", "error", err, "deviceId", broker.Device.ID, "url", writeUrl)
+		return err
+	}
+	return nil
+}
+
+func setActionResultStatus(results []*collector.ActionResult) {
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			result.Status = collector.ActionSuccess
+		case errors.Is(result.Err, runtime.ErrRequestTimeout):
+			result.Status = collector.ActionTimeout
+		default:
+			result.Status = collector.ActionFailed
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/rest/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/jsonpath"
+	"net/url"
+	"strings"
+)
+
+// MappingError 单个点位映射的校验错误
+type MappingError struct {
+	Name     string
+	Endpoint string
+	Err      error
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("mapping %s(%s): %v", e.Name, e.Endpoint, e.Err)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertDevice 将持久化的agents及其mappings转换为运行时设备
+func ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (collector.Device, error) {
+	details, err := DecodeAgentDetails(agents.AgentDetails)
+	if err != nil {
+		return nil, err
+	}
+	address, err := DecodeAgentAddress(agents.Address)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := ConvertVariables(mappings)
+	if err != nil {
+		return nil, err
+	}
+	// 已通过DecodeAgentDetails校验
+	body, _ := runtime.ParseTemplate("body", details.Body)
+	writeBody, _ := runtime.ParseTemplate("writeBody", details.WriteBody)
+
+	device := &runtime.HttpDevice{
+		DeviceMeta: collector.DeviceMeta{
+			ObjectMeta: collector.ObjectMeta{
+				Name:    agents.Name,
+				ID:      agents.Id,
+				Version: agents.Version,
+				ModTime: agents.UpdatedTime,
+			},
+			DeviceType:  agents.AgentType,
+			DeviceModel: common.HTTP,
+		},
+		CollectorCycle:     agents.CollectorCycle,
+		OverrunPolicy:      collector.StringToOverrunPolicy[details.OverrunPolicy],
+		Url:                address.Url,
+		Method:             details.Method,
+		Headers:            details.Headers,
+		AuthMode:           runtime.StringToAuthMode[details.AuthMode],
+		Username:           details.Username,
+		Password:           details.Password,
+		Token:              details.Token,
+		ContentType:        details.ContentType,
+		Body:               body,
+		WriteUrl:           details.WriteUrl,
+		WriteMethod:        details.WriteMethod,
+		WriteBody:          writeBody,
+		CaFile:             details.CaFile,
+		InsecureSkipVerify: details.InsecureSkipVerify,
+		Timeout:            details.Timeout,
+		Variables:          variables,
+	}
+	if len(device.Method) == 0 {
+		device.Method = runtime.DefaultMethod
+	}
+	if len(device.ContentType) == 0 {
+		device.ContentType = runtime.DefaultContentType
+	}
+	if len(device.WriteUrl) == 0 {
+		device.WriteUrl = device.Url
+	}
+	if len(device.WriteMethod) == 0 {
+		device.WriteMethod = runtime.DefaultWriteMethod
+	}
+	if device.Timeout == 0 {
+		device.Timeout = runtime.DefaultTimeout
+	}
+	for _, variable := range variables {
+		if variable.Url, err = ResolveUrl(device.Url, variable.Endpoint); err != nil {
+			return nil, &MappingError{Name: variable.Name, Endpoint: variable.Endpoint, Err: runtime.ErrVariableEndpointInvalid}
+		}
+	}
+	return device, nil
+}
+
+// ResolveUrl 以base为基准解析相对地址, ref为空时返回base
+// 例如 base http://host/api/v1/status, ref /metrics => http://host/metrics, ref alarms => http://host/api/v1/alarms
+func ResolveUrl(base, ref string) (string, error) {
+	if len(ref) == 0 {
+		return base, nil
+	}
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refUrl, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return baseUrl.ResolveReference(refUrl).String(), nil
+}
+
+// ConvertVariables 转换全部mappings,返回每个非法mapping的错误
+func ConvertVariables(mappings []*biz.Mapping) ([]*runtime.Variable, error) {
+	variables := make([]*runtime.Variable, 0, len(mappings))
+	errs := make([]error, 0)
+	names := make(map[string]struct{}, len(mappings))
+	for _, mapping := range mappings {
+		if _, exist := names[mapping.Name]; exist {
+			errs = append(errs, &MappingError{Name: mapping.Name, Endpoint: mappingEndpoint(mapping), Err: runtime.ErrVariableNameDuplicate})
+			continue
+		}
+		names[mapping.Name] = struct{}{}
+
+		variable, err := ConvertVariable(mapping)
+		if err != nil {
+			errs = append(errs, &MappingError{Name: mapping.Name, Endpoint: mappingEndpoint(mapping), Err: err})
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return variables, nil
+}
+
+// mappingEndpoint 相对请求地址, endpoint为空时使用variable
+func mappingEndpoint(mapping *biz.Mapping) string {
+	if endpoint := strings.TrimSpace(mapping.Endpoint); len(endpoint) > 0 {
+		return endpoint
+	}
+	return strings.TrimSpace(mapping.Variable)
+}
+
+// ConvertVariable 将单个mapping转换为运行时变量, endpoint为相对请求地址, jsonPath为取值路径
+func ConvertVariable(mapping *biz.Mapping) (*runtime.Variable, error) {
+	if len(mapping.Name) == 0 {
+		return nil, runtime.ErrVariableNameEmpty
+	}
+	endpoint := mappingEndpoint(mapping)
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, runtime.ErrVariableEndpointInvalid
+	}
+	dataType, ok := common.StringToDataType[mapping.DataType]
+	if !ok {
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+	switch dataType {
+	case common.BCD16, common.BCD32, common.BCD64:
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+
+	accessMode := common.AccessModeReadOnly
+	if len(mapping.AccessMode) > 0 {
+		if accessMode, ok = common.StringToReadWriteProperty[mapping.AccessMode]; !ok {
+			return nil, runtime.ErrAccessModeInvalid
+		}
+	}
+
+	variable := &runtime.Variable{
+		DataType:   dataType,
+		Name:       mapping.Name,
+		Endpoint:   endpoint,
+		JsonPath:   strings.TrimSpace(mapping.JsonPath),
+		AccessMode: accessMode,
+	}
+	if len(variable.JsonPath) > 0 {
+		path, err := jsonpath.Compile(variable.JsonPath)
+		if err != nil {
+			return nil, runtime.ErrVariableJsonPathInvalid
+		}
+		variable.Path = path
+	}
+	if len(mapping.DefaultValue) > 0 {
+		variable.DefaultValue = mapping.DefaultValue
+	}
+	return variable, nil
+}
+
+// DecodeAgentDetails agentDetails JSONMap => HttpAgentDetails
+func DecodeAgentDetails(jm biz.JSONMap) (*biz.HttpAgentDetails, error) {
+	details := &biz.HttpAgentDetails{}
+	if err := decodeJSONMap(jm, details); err != nil {
+		return nil, runtime.ErrAgentDetailsInvalid
+	}
+	details.Method = strings.ToUpper(details.Method)
+	details.WriteMethod = strings.ToUpper(details.WriteMethod)
+	switch details.Method {
+	case "", "GET", "POST", "PUT", "PATCH":
+	default:
+		return nil, runtime.ErrMethodInvalid
+	}
+	switch details.WriteMethod {
+	case "", "POST", "PUT", "PATCH":
+	default:
+		return nil, runtime.ErrMethodInvalid
+	}
+	authMode, ok := runtime.StringToAuthMode[details.AuthMode]
+	if len(details.AuthMode) > 0 && !ok {
+		return nil, runtime.ErrAuthModeInvalid
+	}
+	if (authMode == runtime.AuthBasic && len(details.Username) == 0) || (authMode == runtime.AuthBearer && len(details.Token) == 0) {
+		return nil, runtime.ErrCredentialsRequired
+	}
+	if _, err := runtime.ParseTemplate("body", details.Body); err != nil {
+		return nil, err
+	}
+	if _, err := runtime.ParseTemplate("writeBody", details.WriteBody); err != nil {
+		return nil, err
+	}
+	if len(details.WriteUrl) > 0 {
+		if _, err := url.Parse(strings.ReplaceAll(details.WriteUrl, runtime.WriteVariablePlaceholder, "name")); err != nil {
+			return nil, runtime.ErrWriteUrlInvalid
+		}
+	}
+	if _, ok := collector.StringToOverrunPolicy[details.OverrunPolicy]; len(details.OverrunPolicy) > 0 && !ok {
+		return nil, runtime.ErrOverrunPolicyInvalid
+	}
+	return details, nil
+}
+
+// DecodeAgentAddress address JSONMap => HttpAgentAddress
+func DecodeAgentAddress(jm biz.JSONMap) (*biz.HttpAgentAddress, error) {
+	address := &biz.HttpAgentAddress{}
+	if err := decodeJSONMap(jm, address); err != nil {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	address.Url = strings.TrimSpace(address.Url)
+	u, err := url.Parse(address.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	return address, nil
+}
+
+// decodeJSONMap JSONMap从数据库读出后嵌套对象为map,通过json往返转换为结构体
+func decodeJSONMap(jm biz.JSONMap, v interface{}) error {
+	bytes, err := json.Marshal(jm)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
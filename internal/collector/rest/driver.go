@@ -0,0 +1,23 @@
+package rest
+
+import (
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+)
+
+// init 注册http协议驱动
+func init() {
+	collector.Register(&collector.DriverBase{
+		AgentsManager: &AgentsManager{},
+		Type:          common.HTTP,
+		Caps: collector.DriverCapabilities{
+			Write: true,
+		},
+		NewAgentsFunc: func() pb.Agents {
+			return &pb.HttpAgent{}
+		},
+		ConvertDeviceFunc: ConvertDevice,
+		NewBrokerFunc:     NewBroker,
+	})
+}
@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/rest/runtime"
+	"io"
+	"k8s.io/klog/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+/**
+http 采集
+按采集周期轮询, 点位按请求地址分组, 每个地址每个周期请求一次, 按JsonPath从响应中取值
+请求携带配置的请求头与认证信息, 配置了请求体模板时每次请求重新渲染
+地址请求失败时该地址的变量均返回错误, 不影响其他地址
+*/
+
+var _ collector.Broker = (*HttpBroker)(nil)
+
+type HttpBroker struct {
+	Device     *runtime.HttpDevice
+	Client     *http.Client
+	Scheduler  *collector.Scheduler
+	Endpoints  []*runtime.Endpoint
+	ExitCh     chan struct{}
+	VariableCh chan *collector.ParseVariableResult
+
+	wg sync.WaitGroup
+}
+
+func NewBroker(d collector.Device) (collector.Broker, chan *collector.ParseVariableResult, error) {
+	device, ok := d.(*runtime.HttpDevice)
+	if !ok {
+		klog.V(2).InfoS("Unsupported device,type not Http")
+		return nil, nil, collector.ErrDeviceType
+	}
+	if len(device.Variables) == 0 {
+		klog.V(2).InfoS("Unnecessary to collect from Http device.Because of the variables is empty", "deviceId", device.ID)
+		return nil, nil, collector.ErrDeviceEmptyVariable
+	}
+	client, err := NewHttpClient(device)
+	if err != nil {
+		klog.V(2).InfoS("Failed to load Http CA certificate", "error", err, "deviceId", device.ID)
+		return nil, nil, err
+	}
+
+	broker := &HttpBroker{
+		Device:     device,
+		Client:     client,
+		Scheduler:  collector.NewScheduler(device.CollectorCycle, device.OverrunPolicy),
+		Endpoints:  device.Endpoints(),
+		ExitCh:     make(chan struct{}, 0),
+		VariableCh: make(chan *collector.ParseVariableResult, 1),
+	}
+	return broker, broker.VariableCh, nil
+}
+
+// NewHttpClient 设备的http客户端, 配置了CA文件时使用该CA校验服务端证书
+func NewHttpClient(device *runtime.HttpDevice) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(device.CaFile) > 0 || device.InsecureSkipVerify {
+		config := &tls.Config{InsecureSkipVerify: device.InsecureSkipVerify}
+		if len(device.CaFile) > 0 {
+			ca, err := os.ReadFile(device.CaFile)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, runtime.ErrAgentDetailsInvalid
+			}
+		}
+		transport.TLSClientConfig = config
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(device.Timeout) * time.Millisecond,
+	}, nil
+}
+
+func (broker *HttpBroker) Collect(ctx context.Context) {
+	broker.wg.Add(1)
+	go func() {
+		defer broker.wg.Done()
+		broker.Scheduler.Run(ctx, broker.ExitCh, func() bool {
+			return broker.poll(ctx)
+		})
+	}()
+}
+
+func (broker *HttpBroker) Destroy(ctx context.Context) {
+	close(broker.ExitCh)
+	broker.wg.Wait()
+	broker.Client.CloseIdleConnections()
+	close(broker.VariableCh)
+}
+
+func (broker *HttpBroker) exited() bool {
+	select {
+	case <-broker.ExitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// send 退出后丢弃结果
+func (broker *HttpBroker) send(pvr *collector.ParseVariableResult) {
+	select {
+	case broker.VariableCh <- pvr:
+	case <-broker.ExitCh:
+	}
+}
+
+// poll 请求全部地址
+func (broker *HttpBroker) poll(ctx context.Context) bool {
+	if broker.exited() {
+		return false
+	}
+	rvs := make([]collector.VariableValue, 0, len(broker.Device.Variables))
+	errs := make([]error, 0)
+	for _, endpoint := range broker.Endpoints {
+		body, err := broker.fetch(ctx, endpoint.Url)
+		if err != nil {
+			klog.V(2).InfoS("Failed to poll Http endpoint", "error", err, "deviceId", broker.Device.ID, "url", endpoint.Url)
+			errs = append(errs, err)
+			continue
+		}
+		// 非JSON响应只能用于未配置JsonPath的变量
+		document, _ := runtime.DecodeResponse(body)
+		for _, v := range endpoint.Variables {
+			value, err := runtime.ExtractValue(v, document, body)
+			if err != nil {
+				errs = append(errs, &MappingError{Name: v.Name, Endpoint: endpoint.Url, Err: err})
+				continue
+			}
+			variable := *v
+			variable.Value = value
+			rvs = append(rvs, &variable)
+		}
+	}
+	if len(errs) == 0 {
+		errs = nil
+	}
+	broker.send(&collector.ParseVariableResult{VariableSlice: rvs, Err: errs})
+	return true
+}
+
+// fetch 发送轮询请求, 返回2xx响应的响应体
+func (broker *HttpBroker) fetch(ctx context.Context, url string) ([]byte, error) {
+	var body []byte
+	if broker.Device.Body != nil {
+		var err error
+		if body, err = runtime.Render(broker.Device.Body, runtime.NewTemplateData()); err != nil {
+			return nil, err
+		}
+	}
+	return broker.do(ctx, broker.Device.Method, url, body)
+}
+
+// do 发送请求, 携带请求头与认证信息
+func (broker *HttpBroker) do(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range broker.Device.Headers {
+		request.Header.Set(key, value)
+	}
+	if body != nil && len(request.Header.Get("Content-Type")) == 0 {
+		request.Header.Set("Content-Type", broker.Device.ContentType)
+	}
+	switch broker.Device.AuthMode {
+	case runtime.AuthBasic:
+		request.SetBasicAuth(broker.Device.Username, broker.Device.Password)
+	case runtime.AuthBearer:
+		request.Header.Set("Authorization", "Bearer "+broker.Device.Token)
+	}
+
+	response, err := broker.Client.Do(request)
+	if err != nil {
+		var timeout interface{ Timeout() bool }
+		if errors.As(err, &timeout) && timeout.Timeout() {
+			return nil, runtime.ErrRequestTimeout
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, runtime.MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > runtime.MaxResponseSize {
+		return nil, runtime.ErrResponseTooLarge
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, &runtime.StatusError{Url: url, StatusCode: response.StatusCode}
+	}
+	return data, nil
+}
@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/rest/runtime"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// 每个请求地址每个周期请求一次, 按JsonPath取值, 请求失败的地址不影响其他地址
+func TestPoll(t *testing.T) {
+	var mux sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		requests[r.URL.Path]++
+		mux.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/status":
+			_, _ = w.Write([]byte(`{"data":{"temperature":23.5,"humidity":40},"alarms":[{"code":7}],"counter.total":18446744073709551615}`))
+		case "/api/level":
+			_, _ = w.Write([]byte(" 1.5\n"))
+		case "/api/mode":
+			_, _ = w.Write([]byte("auto mode"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	device, err := ConvertDevice(&biz.Agents{
+		Address:      biz.JSONMap{"url": server.URL + "/api/status"},
+		AgentDetails: biz.JSONMap{"authMode": "bearer", "token": "secret"},
+	}, []*biz.Mapping{
+		{Name: "temperature", JsonPath: "$.data.temperature", DataType: "float64"},
+		{Name: "alarm", JsonPath: "alarms[0].code", DataType: "int32"},
+		{Name: "total", JsonPath: "$['counter.total']", DataType: "uint64"},
+		{Name: "data", JsonPath: "$.data", DataType: "string"},
+		{Name: "pressure", JsonPath: "$.data.pressure", DataType: "float64"},
+		{Name: "humidity", JsonPath: "$.data.humidity", DataType: "int8"},
+		{Name: "level", Endpoint: "level", DataType: "float32"},
+		{Name: "levelPath", Endpoint: "level", JsonPath: "$.value", DataType: "float32"},
+		{Name: "mode", Endpoint: "mode", DataType: "string"},
+		{Name: "modePath", Endpoint: "mode", JsonPath: "$.value", DataType: "string"},
+		{Name: "metrics", Endpoint: "/metrics", JsonPath: "$.value", DataType: "float64"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker, ch, err := NewBroker(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb := broker.(*HttpBroker)
+	defer hb.Destroy(context.Background())
+
+	if !hb.poll(context.Background()) {
+		t.Fatal("poll stopped")
+	}
+	pvr := <-ch
+	values := make(map[string]interface{})
+	for _, vv := range pvr.VariableSlice {
+		values[vv.GetVariableName()] = vv.GetValue()
+	}
+	want := map[string]interface{}{
+		"temperature": 23.5,
+		"alarm":       int32(7),
+		"total":       uint64(18446744073709551615),
+		"data":        `{"humidity":40,"temperature":23.5}`,
+		"humidity":    int8(40),
+		"level":       float32(1.5),
+		"mode":        "auto mode",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("got values %v, want %v", values, want)
+	}
+
+	errs := make(map[string]error)
+	var statusErr *runtime.StatusError
+	for _, err := range pvr.Err {
+		var me *MappingError
+		switch {
+		case errors.As(err, &me):
+			errs[me.Name] = me.Err
+		case errors.As(err, &statusErr):
+		default:
+			t.Fatalf("got error %v", err)
+		}
+	}
+	wantErrs := map[string]error{
+		"pressure":  runtime.ErrValueNotFound,
+		"levelPath": runtime.ErrValueNotFound,
+		"modePath":  runtime.ErrResponseInvalid,
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Fatalf("got errors %v, want %v", errs, wantErrs)
+	}
+	if statusErr == nil || statusErr.StatusCode != http.StatusInternalServerError || statusErr.Url != server.URL+"/metrics" {
+		t.Fatalf("got status error %v", statusErr)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if wantRequests := map[string]int{"/api/status": 1, "/api/level": 1, "/api/mode": 1, "/metrics": 1}; !reflect.DeepEqual(requests, wantRequests) {
+		t.Fatalf("got requests %v, want %v", requests, wantRequests)
+	}
+}
+
+func TestResolveUrl(t *testing.T) {
+	tests := []struct {
+		base string
+		ref  string
+		want string
+	}{
+		{"http://host/api/v1/status", "", "http://host/api/v1/status"},
+		{"http://host/api/v1/status", "/metrics", "http://host/metrics"},
+		{"http://host/api/v1/status", "alarms", "http://host/api/v1/alarms"},
+		{"http://host/api/v1/status", "alarms?id=1", "http://host/api/v1/alarms?id=1"},
+		{"http://host/api/v1/status", "http://other/x", "http://other/x"},
+	}
+	for _, tt := range tests {
+		if got, err := ResolveUrl(tt.base, tt.ref); err != nil || got != tt.want {
+			t.Errorf("%s %s: got %s, %v, want %s", tt.base, tt.ref, got, err, tt.want)
+		}
+	}
+}
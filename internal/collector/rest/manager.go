@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"github.com/imdario/mergo"
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/rest/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/errors"
+)
+
+type AgentsManager struct {
+}
+
+// PollPlan 每个采集周期的请求及从各请求响应中取值的变量
+type PollPlan struct {
+	Method    string          `json:"method"`
+	Endpoints []*EndpointPlan `json:"endpoints"`
+}
+
+type EndpointPlan struct {
+	Url       string   `json:"url"`
+	Variables []string `json:"variables"`
+}
+
+func (m *AgentsManager) ValidateMappings(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) error {
+	if _, err := ConvertDevice(agents, mappings); err != nil {
+		return errors.GenerateMappingsInvalidError(err.Error())
+	}
+	return nil
+}
+
+func (m *AgentsManager) GetFramePlan(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) (interface{}, error) {
+	d, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	device := d.(*runtime.HttpDevice)
+	plan := &PollPlan{Method: device.Method, Endpoints: make([]*EndpointPlan, 0)}
+	for _, endpoint := range device.Endpoints() {
+		ep := &EndpointPlan{Url: endpoint.Url, Variables: make([]string, 0, len(endpoint.Variables))}
+		for _, variable := range endpoint.Variables {
+			ep.Variables = append(ep.Variables, variable.Name)
+		}
+		plan.Endpoints = append(plan.Endpoints, ep)
+	}
+	return plan, nil
+}
+
+func (m *AgentsManager) CreateAgents(ctx context.Context, agents pb.Agents) (*biz.Agents, error) {
+	httpAgents, ok := agents.(*pb.HttpAgent)
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(agents.GetAgentType())
+	}
+	bz := &biz.Agents{
+		Name:             httpAgents.Name,
+		AgentType:        common.HTTP,
+		Description:      httpAgents.Description,
+		CollectorCycle:   httpAgents.CollectorCycle,
+		VariableInterval: httpAgents.VariableInterval,
+		Broker:           httpAgents.Broker,
+	}
+
+	adv := map[string]interface{}{}
+	if err := mergo.Map(&adv, httpAgents.AgentDetails); err != nil {
+		return nil, err
+	}
+	bz.AgentDetails = adv
+
+	av := map[string]interface{}{}
+	if err := mergo.Map(&av, httpAgents.Address); err != nil {
+		return nil, err
+	}
+	bz.Address = av
+
+	if _, err := DecodeAgentDetails(bz.AgentDetails); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	if _, err := DecodeAgentAddress(bz.Address); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+
+	return bz, nil
+}
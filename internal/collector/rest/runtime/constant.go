@@ -0,0 +1,72 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrVariableNameEmpty = errors.New("http variable name empty")
+var ErrVariableNameDuplicate = errors.New("http variable name duplicate")
+var ErrVariableEndpointInvalid = errors.New("http variable endpoint invalid")
+var ErrVariableJsonPathInvalid = errors.New("http variable json path invalid")
+var ErrDataTypeUnsupported = errors.New("http variable data type unsupported")
+var ErrAccessModeInvalid = errors.New("http variable access mode invalid")
+var ErrAgentDetailsInvalid = errors.New("http agent details invalid")
+var ErrAgentAddressInvalid = errors.New("http agent address invalid, eg: http://host:8080/api/status")
+var ErrMethodInvalid = errors.New("http method invalid")
+var ErrAuthModeInvalid = errors.New("http auth mode invalid")
+var ErrCredentialsRequired = errors.New("http username required for basic auth, token required for bearer auth")
+var ErrBodyTemplateInvalid = errors.New("http body template invalid")
+var ErrWriteUrlInvalid = errors.New("http write url invalid")
+var ErrOverrunPolicyInvalid = errors.New("http overrun policy invalid")
+var ErrVariableNotFound = errors.New("http variable not found")
+var ErrVariableReadOnly = errors.New("http variable read only")
+var ErrActionValueInvalid = errors.New("http action value invalid")
+var ErrResponseInvalid = errors.New("http response is not valid json")
+var ErrResponseTooLarge = errors.New("http response too large")
+var ErrValueNotFound = errors.New("http response has no value at json path")
+var ErrValueInvalid = errors.New("http response value can not convert to data type")
+var ErrRequestTimeout = errors.New("http request timeout")
+
+// AuthMode 认证方式
+type AuthMode byte
+
+const (
+	AuthNone AuthMode = iota
+	AuthBasic
+	AuthBearer
+)
+
+var AuthModeToString = map[AuthMode]string{
+	AuthNone:   "none",
+	AuthBasic:  "basic",
+	AuthBearer: "bearer",
+}
+
+var StringToAuthMode = map[string]AuthMode{
+	"none":   AuthNone,
+	"basic":  AuthBasic,
+	"bearer": AuthBearer,
+}
+
+const (
+	// WriteVariablePlaceholder 写入地址中的变量名占位符, 存在时每个变量单独请求
+	WriteVariablePlaceholder = "{name}"
+	// MaxResponseSize 读取响应的最大字节数
+	MaxResponseSize = 10 << 20
+
+	DefaultMethod      = "GET"
+	DefaultWriteMethod = "POST"
+	DefaultContentType = "application/json"
+	DefaultTimeout     = 5000
+)
+
+// StatusError 响应状态码不是2xx
+type StatusError struct {
+	Url        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http request %s failed with status %d", e.Url, e.StatusCode)
+}
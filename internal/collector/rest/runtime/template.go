@@ -0,0 +1,49 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+	"time"
+)
+
+/**
+请求体模板 text/template
+轮询 {"since":{{.Timestamp}}}
+写入 {"target":"{{.Name}}","value":{{json .Value}}}  {{json .Values}}
+json 函数输出JSON编码的值
+*/
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseTemplate 解析请求体模板, 为空时返回nil
+func ParseTemplate(name, text string) (*template.Template, error) {
+	if len(text) == 0 {
+		return nil, nil
+	}
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, ErrBodyTemplateInvalid
+	}
+	return t, nil
+}
+
+// NewTemplateData 当前时间的模板数据
+func NewTemplateData() *TemplateData {
+	now := time.Now()
+	return &TemplateData{Timestamp: now.UnixMilli(), Now: now.Format(time.RFC3339)}
+}
+
+// Render 渲染请求体
+func Render(t *template.Template, data *TemplateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
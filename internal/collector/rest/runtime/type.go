@@ -0,0 +1,122 @@
+package runtime
+
+import (
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/jsonpath"
+	"text/template"
+)
+
+var _ collector.Device = (*HttpDevice)(nil)
+var _ collector.VariableValue = (*Variable)(nil)
+
+type Variable struct {
+	DataType     common.DataType   `json:"dataType"`               // bool、int8、uint8、int16、uint16、int32、uint32、int64、uint64、float32、float64、number、string
+	Name         string            `json:"name"`                   // 变量名称
+	Endpoint     string            `json:"endpoint,omitempty"`     // 相对请求地址, 为空时使用agent地址
+	Url          string            `json:"url"`                    // 解析后的请求地址
+	JsonPath     string            `json:"jsonPath,omitempty"`     // 从响应中取值的路径, 为空时使用整个响应
+	Path         *jsonpath.Path    `json:"-"`                      // 解析后的路径
+	DefaultValue interface{}       `json:"defaultValue,omitempty"` // 默认值
+	Value        interface{}       `json:"value,omitempty"`        // 值
+	AccessMode   common.AccessMode `json:"accessMode"`             // 读写属性
+}
+
+func (v *Variable) GetVariableAccessMode() common.AccessMode {
+	return v.AccessMode
+}
+
+func (v *Variable) SetValue(value interface{}) {
+	v.Value = value
+}
+
+func (v *Variable) GetValue() interface{} {
+	return v.Value
+}
+
+func (v *Variable) GetVariableName() string {
+	return v.Name
+}
+
+func (v *Variable) SetVariableName(name string) {
+	v.Name = name
+}
+
+type HttpDevice struct {
+	collector.DeviceMeta
+	CollectorCycle     uint                    `json:"collectorCycle"`                    // 轮询周期 毫秒
+	OverrunPolicy      collector.OverrunPolicy `json:"overrunPolicy"`                     // 轮询超过周期时的处理策略
+	Url                string                  `json:"url"`                               // 轮询地址
+	Method             string                  `json:"method"`                            // 轮询请求方法
+	Headers            map[string]string       `json:"headers,omitempty"`                 // 请求头
+	AuthMode           AuthMode                `json:"-"`                                 // 认证方式
+	Username           string                  `json:"username,omitempty"`                // basic认证用户名
+	Password           string                  `json:"-"`                                 // basic认证密码
+	Token              string                  `json:"-"`                                 // bearer认证令牌
+	ContentType        string                  `json:"contentType"`                       // 请求体类型
+	Body               *template.Template      `json:"-"`                                 // 轮询请求体模板, 为空时不携带请求体
+	WriteUrl           string                  `json:"writeUrl"`                          // 写入地址
+	WriteMethod        string                  `json:"writeMethod"`                       // 写入请求方法
+	WriteBody          *template.Template      `json:"-"`                                 // 写入请求体模板, 为空时为JSON编码的值
+	CaFile             string                  `json:"caFile,omitempty"`                  // 校验服务端证书的CA文件
+	InsecureSkipVerify bool                    `json:"insecureSkipVerify,omitempty"`      // 不校验服务端证书
+	Timeout            uint                    `json:"timeout"`                           // 请求超时 毫秒
+	Variables          []*Variable             `json:"variables" binding:"required,dive"` // 自定义变量
+	VariablesMap       map[string]*Variable    `json:"-"`                                 // 自定义变量Map
+}
+
+func (m *HttpDevice) IndexDevice() {
+	m.VariablesMap = make(map[string]*Variable)
+	for _, variable := range m.Variables {
+		m.VariablesMap[variable.Name] = variable
+	}
+}
+
+func (m *HttpDevice) GetVariable(key string) (rv collector.VariableValue, exist bool) {
+	if v, isExist := m.VariablesMap[key]; isExist {
+		rv = v
+		exist = isExist
+	}
+	return
+}
+
+func (m *HttpDevice) GetVariables() []collector.VariableValue {
+	rvs := make([]collector.VariableValue, 0)
+
+	for _, variable := range m.Variables {
+		rvs = append(rvs, variable)
+	}
+
+	return rvs
+}
+
+// Endpoint 一个请求地址及从其响应中取值的变量
+type Endpoint struct {
+	Url       string      `json:"url"`
+	Variables []*Variable `json:"-"`
+}
+
+// Endpoints 按请求地址分组, 保持点位顺序
+func (m *HttpDevice) Endpoints() []*Endpoint {
+	endpoints := make([]*Endpoint, 0)
+	urlEndpoints := make(map[string]*Endpoint)
+	for _, variable := range m.Variables {
+		endpoint, exist := urlEndpoints[variable.Url]
+		if !exist {
+			endpoint = &Endpoint{Url: variable.Url, Variables: make([]*Variable, 0)}
+			urlEndpoints[variable.Url] = endpoint
+			endpoints = append(endpoints, endpoint)
+		}
+		endpoint.Variables = append(endpoint.Variables, variable)
+	}
+	return endpoints
+}
+
+// TemplateData 请求体模板的数据
+type TemplateData struct {
+	Timestamp int64                  // 毫秒时间戳
+	Now       string                 // RFC3339时间
+	Name      string                 // 写入的变量名, 单独请求时有效
+	Value     interface{}            // 写入的值, 单独请求时有效
+	Values    map[string]interface{} // 本次写入的全部变量
+}
@@ -0,0 +1,125 @@
+package s7
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/s7/runtime"
+	"harnsplatform/internal/common"
+	"k8s.io/klog/v2"
+)
+
+/**
+写入
+布尔变量按位写入, 其他变量按字节写入
+写入项按顺序装入写请求, 请求 = 请求头(10) + 功能码(1) + 变量数(1) + 变量描述(12) * n + 数据(返回码(1) + 传输类型(1) + 长度(2) + 数据 + 补齐) * n
+请求不超过协商的PDU长度, 且每个请求不超过20个写入项, 响应中每个写入项返回独立的返回码
+*/
+
+// DeliverAction 返回每个变量的下发结果,存在失败时error为按变量名汇总的collector.MultiError
+func (broker *S7Broker) DeliverAction(ctx context.Context, obj map[string]interface{}) ([]*collector.ActionResult, error) {
+	results := make([]*collector.ActionResult, 0, len(obj))
+	items := make([]*runtime.WriteItem, 0, len(obj))
+	itemResults := make([]*collector.ActionResult, 0, len(obj))
+
+	for name, value := range obj {
+		result := &collector.ActionResult{Name: name, Value: value}
+		results = append(results, result)
+
+		vv, exist := broker.Device.GetVariable(name)
+		if !exist {
+			result.Err = runtime.ErrVariableNotFound
+			continue
+		}
+		variable := vv.(*runtime.Variable)
+		if variable.AccessMode != common.AccessModeReadWrite {
+			result.Err = runtime.ErrVariableReadOnly
+			continue
+		}
+		data, err := runtime.EncodeValue(variable, value)
+		if err != nil {
+			klog.V(3).InfoS("Failed to convert action value", "variableName", variable.Name, "dataType", variable.DataType, "error", err)
+			result.Err = err
+			continue
+		}
+		items = append(items, &runtime.WriteItem{
+			Area:     variable.Location.Area,
+			DBNumber: variable.Location.DBNumber,
+			Start:    variable.Location.Start,
+			Bit:      variable.Location.Bit,
+			BitWrite: variable.DataType == common.BOOL,
+			Data:     data,
+		})
+		itemResults = append(itemResults, result)
+	}
+	if len(items) == 0 {
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	client, _, err := broker.available(ctx)
+	if err != nil {
+		klog.V(2).InfoS("Failed to reconnect S7 plc", "error", err, "deviceId", broker.Device.ID)
+		for _, result := range itemResults {
+			result.Err = err
+		}
+		setActionResultStatus(results)
+		return results, collector.NewActionMultiError(results)
+	}
+
+	for _, request := range PlanWrites(items, client.PduSize()) {
+		requestItems := make([]*runtime.WriteItem, 0, len(request))
+		for _, i := range request {
+			requestItems = append(requestItems, items[i])
+		}
+		errs, err := client.WriteItems(requestItems)
+		if err != nil {
+			klog.V(2).InfoS("Failed to write S7 plc", "error", err, "deviceId", broker.Device.ID)
+		}
+		for j, i := range request {
+			if err != nil {
+				itemResults[i].Err = err
+			} else {
+				itemResults[i].Err = errs[j]
+			}
+		}
+	}
+
+	setActionResultStatus(results)
+	return results, collector.NewActionMultiError(results)
+}
+
+// PlanWrites 按PDU长度将写入项分组, 返回每个写请求中写入项的下标
+func PlanWrites(items []*runtime.WriteItem, pduSize uint) [][]int {
+	requests := make([][]int, 0)
+	request := make([]int, 0)
+	length := uint(runtime.RequestOverhead)
+	for i, item := range items {
+		dataLength := uint(len(item.Data))
+		itemLength := runtime.RequestItemLength + runtime.DataItemHeaderLength + dataLength + dataLength%2
+		if len(request) > 0 && (len(request) >= runtime.MaxItemsPerRequest || length+itemLength > pduSize) {
+			requests = append(requests, request)
+			request = make([]int, 0)
+			length = runtime.RequestOverhead
+		}
+		request = append(request, i)
+		length += itemLength
+	}
+	if len(request) > 0 {
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+func setActionResultStatus(results []*collector.ActionResult) {
+	for _, result := range results {
+		switch {
+		case result.Err == nil:
+			result.Status = collector.ActionSuccess
+		case errors.Is(result.Err, runtime.ErrS7Timeout):
+			result.Status = collector.ActionTimeout
+		default:
+			result.Status = collector.ActionFailed
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package s7
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/s7/runtime"
+	"harnsplatform/internal/common"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultMaxGap 合并读取时允许跨越的最大未映射字节数
+	DefaultMaxGap = 32
+	// DefaultTimeout 请求超时 毫秒
+	DefaultTimeout = 3000
+)
+
+// WidthDataTypes 地址宽度可以使用的数据类型, 8字节类型使用字节地址表示起始字节
+var WidthDataTypes = map[runtime.Width][]common.DataType{
+	runtime.WidthBit:    {common.BOOL},
+	runtime.WidthByte:   {common.INT8, common.UINT8, common.INT64, common.UINT64, common.FLOAT64},
+	runtime.WidthWord:   {common.INT16, common.UINT16},
+	runtime.WidthDWord:  {common.INT32, common.UINT32, common.FLOAT32},
+	runtime.WidthString: {common.STRING},
+}
+
+// MappingError 单个点位映射的校验错误
+type MappingError struct {
+	Name     string
+	Variable string
+	Err      error
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("mapping %s(%s): %v", e.Name, e.Variable, e.Err)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertDevice 将持久化的agents及其mappings转换为运行时设备
+func ConvertDevice(agents *biz.Agents, mappings []*biz.Mapping) (collector.Device, error) {
+	details, err := DecodeAgentDetails(agents.AgentDetails)
+	if err != nil {
+		return nil, err
+	}
+	address, err := DecodeAgentAddress(agents.Address)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := ConvertVariables(mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	device := &runtime.S7Device{
+		DeviceMeta: collector.DeviceMeta{
+			ObjectMeta: collector.ObjectMeta{
+				Name:    agents.Name,
+				ID:      agents.Id,
+				Version: agents.Version,
+				ModTime: agents.UpdatedTime,
+			},
+			DeviceType:  agents.AgentType,
+			DeviceModel: common.S7,
+		},
+		CollectorCycle: agents.CollectorCycle,
+		OverrunPolicy:  collector.StringToOverrunPolicy[details.OverrunPolicy],
+		Host:           address.Host,
+		Port:           address.Port,
+		Rack:           details.Rack,
+		Slot:           details.Slot,
+		LocalTSAP:      runtime.DefaultLocalTSAP,
+		PduSize:        details.PduSize,
+		MaxGap:         DefaultMaxGap,
+		Timeout:        details.Timeout,
+		Variables:      variables,
+	}
+	if device.Port == 0 {
+		device.Port = runtime.DefaultPort
+	}
+	if device.PduSize == 0 {
+		device.PduSize = runtime.DefaultPduSize
+	}
+	if details.MaxGap != nil {
+		device.MaxGap = *details.MaxGap
+	}
+	if device.Timeout == 0 {
+		device.Timeout = DefaultTimeout
+	}
+	// TSAP已通过DecodeAgentDetails校验
+	if len(details.LocalTSAP) > 0 {
+		device.LocalTSAP, _ = parseTSAP(details.LocalTSAP)
+	}
+	if len(details.RemoteTSAP) > 0 {
+		device.RemoteTSAP, _ = parseTSAP(details.RemoteTSAP)
+	} else {
+		device.RemoteTSAP = runtime.RemoteTSAP(connectionType(details), details.Rack, details.Slot)
+	}
+	return device, nil
+}
+
+func connectionType(details *biz.S7AgentDetails) runtime.ConnectionType {
+	if len(details.ConnectionType) == 0 {
+		return runtime.ConnectionTypePG
+	}
+	return runtime.StringToConnectionType[details.ConnectionType]
+}
+
+// parseTSAP 4位十六进制 例如 0102
+func parseTSAP(s string) (uint16, error) {
+	if len(s) != 4 {
+		return 0, runtime.ErrTsapInvalid
+	}
+	v, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, runtime.ErrTsapInvalid
+	}
+	return uint16(v), nil
+}
+
+// ConvertVariables 转换全部mappings,返回每个非法mapping的错误
+func ConvertVariables(mappings []*biz.Mapping) ([]*runtime.Variable, error) {
+	variables := make([]*runtime.Variable, 0, len(mappings))
+	errs := make([]error, 0)
+	names := make(map[string]struct{}, len(mappings))
+	for _, mapping := range mappings {
+		if _, exist := names[mapping.Name]; exist {
+			errs = append(errs, &MappingError{Name: mapping.Name, Variable: mapping.Variable, Err: runtime.ErrVariableNameDuplicate})
+			continue
+		}
+		names[mapping.Name] = struct{}{}
+
+		variable, err := ConvertVariable(mapping)
+		if err != nil {
+			errs = append(errs, &MappingError{Name: mapping.Name, Variable: mapping.Variable, Err: err})
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return variables, nil
+}
+
+// ConvertVariable 将单个mapping转换为运行时变量, 数据类型须与地址宽度一致
+func ConvertVariable(mapping *biz.Mapping) (*runtime.Variable, error) {
+	if len(mapping.Name) == 0 {
+		return nil, runtime.ErrVariableNameEmpty
+	}
+	location, err := runtime.ParseAddress(mapping.Variable)
+	if err != nil {
+		return nil, err
+	}
+	dataType, ok := common.StringToDataType[mapping.DataType]
+	if !ok {
+		return nil, runtime.ErrDataTypeUnsupported
+	}
+	if !matchWidth(location.Width, dataType) {
+		return nil, runtime.ErrDataTypeAddressMismatch
+	}
+
+	accessMode := common.AccessModeReadOnly
+	if len(mapping.AccessMode) > 0 {
+		if accessMode, ok = common.StringToReadWriteProperty[mapping.AccessMode]; !ok {
+			return nil, runtime.ErrAccessModeInvalid
+		}
+	}
+
+	variable := &runtime.Variable{
+		DataType:   dataType,
+		Name:       mapping.Name,
+		Address:    location.String(),
+		Location:   location,
+		AccessMode: accessMode,
+	}
+	if len(mapping.DefaultValue) > 0 {
+		variable.DefaultValue = mapping.DefaultValue
+	}
+	return variable, nil
+}
+
+func matchWidth(width runtime.Width, dataType common.DataType) bool {
+	for _, dt := range WidthDataTypes[width] {
+		if dt == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeAgentDetails agentDetails JSONMap => S7AgentDetails
+func DecodeAgentDetails(jm biz.JSONMap) (*biz.S7AgentDetails, error) {
+	details := &biz.S7AgentDetails{}
+	if err := decodeJSONMap(jm, details); err != nil {
+		return nil, runtime.ErrAgentDetailsInvalid
+	}
+	if details.Rack > 7 || details.Slot > 31 {
+		return nil, runtime.ErrRackSlotInvalid
+	}
+	if _, ok := runtime.StringToConnectionType[details.ConnectionType]; len(details.ConnectionType) > 0 && !ok {
+		return nil, runtime.ErrConnectionTypeInvalid
+	}
+	for _, tsap := range []string{details.LocalTSAP, details.RemoteTSAP} {
+		if _, err := parseTSAP(tsap); len(tsap) > 0 && err != nil {
+			return nil, err
+		}
+	}
+	if details.PduSize != 0 && (details.PduSize < runtime.MinPduSize || details.PduSize > runtime.DefaultPduSize) {
+		return nil, runtime.ErrPduSizeOutOfRange
+	}
+	if _, ok := collector.StringToOverrunPolicy[details.OverrunPolicy]; len(details.OverrunPolicy) > 0 && !ok {
+		return nil, runtime.ErrOverrunPolicyInvalid
+	}
+	return details, nil
+}
+
+// DecodeAgentAddress address JSONMap => S7AgentAddress
+func DecodeAgentAddress(jm biz.JSONMap) (*biz.S7AgentAddress, error) {
+	address := &biz.S7AgentAddress{}
+	if err := decodeJSONMap(jm, address); err != nil {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	address.Host = strings.TrimSpace(address.Host)
+	if len(address.Host) == 0 || address.Port < 0 || address.Port > 65535 {
+		return nil, runtime.ErrAgentAddressInvalid
+	}
+	return address, nil
+}
+
+// decodeJSONMap JSONMap从数据库读出后嵌套对象为map,通过json往返转换为结构体
+func decodeJSONMap(jm biz.JSONMap, v interface{}) error {
+	bytes, err := json.Marshal(jm)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
@@ -0,0 +1,24 @@
+package s7
+
+import (
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+)
+
+// init 注册s7协议驱动
+func init() {
+	collector.Register(&collector.DriverBase{
+		AgentsManager: &AgentsManager{},
+		Type:          common.S7,
+		Caps: collector.DriverCapabilities{
+			Write:     true,
+			FramePlan: true,
+		},
+		NewAgentsFunc: func() pb.Agents {
+			return &pb.S7Agent{}
+		},
+		ConvertDeviceFunc: ConvertDevice,
+		NewBrokerFunc:     NewBroker,
+	})
+}
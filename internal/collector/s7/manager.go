@@ -0,0 +1,67 @@
+package s7
+
+import (
+	"context"
+	"github.com/imdario/mergo"
+	pb "harnsplatform/api/modelmanager/v1"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/s7/runtime"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/errors"
+)
+
+type AgentsManager struct {
+}
+
+func (m *AgentsManager) ValidateMappings(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) error {
+	if _, err := ConvertDevice(agents, mappings); err != nil {
+		return errors.GenerateMappingsInvalidError(err.Error())
+	}
+	return nil
+}
+
+// GetFramePlan 按配置的PDU长度规划读请求, 实际采集使用与PLC协商后的PDU长度
+func (m *AgentsManager) GetFramePlan(ctx context.Context, agents *biz.Agents, mappings []*biz.Mapping) (interface{}, error) {
+	d, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	device := d.(*runtime.S7Device)
+	return PlanReads(device, device.PduSize), nil
+}
+
+func (m *AgentsManager) CreateAgents(ctx context.Context, agents pb.Agents) (*biz.Agents, error) {
+	s7Agents, ok := agents.(*pb.S7Agent)
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(agents.GetAgentType())
+	}
+	bz := &biz.Agents{
+		Name:             s7Agents.Name,
+		AgentType:        common.S7,
+		Description:      s7Agents.Description,
+		CollectorCycle:   s7Agents.CollectorCycle,
+		VariableInterval: s7Agents.VariableInterval,
+		Broker:           s7Agents.Broker,
+	}
+
+	adv := map[string]interface{}{}
+	if err := mergo.Map(&adv, s7Agents.AgentDetails); err != nil {
+		return nil, err
+	}
+	bz.AgentDetails = adv
+
+	av := map[string]interface{}{}
+	if err := mergo.Map(&av, s7Agents.Address); err != nil {
+		return nil, err
+	}
+	bz.Address = av
+
+	if _, err := DecodeAgentDetails(bz.AgentDetails); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+	if _, err := DecodeAgentAddress(bz.Address); err != nil {
+		return nil, errors.GenerateAgentsInvalidError(err.Error())
+	}
+
+	return bz, nil
+}
@@ -0,0 +1,162 @@
+package s7
+
+import (
+	"harnsplatform/internal/collector/s7/runtime"
+	"sort"
+)
+
+/**
+读取规划
+1. 按存储区与DB号分组并按起始字节排序, 间隔不超过maxGap且合并后不超过单个变量最大长度时合并为一个读取块
+2. 超过单个变量最大长度的块(例如PDU较小时的长字符串)拆分为多个读取项, 读取后重新拼接
+3. 读取项按顺序装入读请求, 请求与响应均不超过协商的PDU长度, 且每个请求不超过20个读取项
+*/
+
+// ReadPlan 一个采集周期的全部读请求
+type ReadPlan struct {
+	PduSize  uint               `json:"pduSize"`
+	Blocks   []*ReadBlock       `json:"blocks"`
+	Requests []*ReadRequestPlan `json:"requests"`
+}
+
+// ReadBlock 连续读取的一段字节及其变量
+type ReadBlock struct {
+	runtime.ReadItem
+	VariableNames []string                 `json:"variables"`
+	Variables     []*runtime.VariableParse `json:"-"`
+}
+
+// ReadRequestPlan 一个读请求中的读取项
+type ReadRequestPlan struct {
+	Items          []*ReadSegment `json:"items"`
+	RequestLength  uint           `json:"requestLength"`
+	ResponseLength uint           `json:"responseLength"`
+}
+
+// ReadSegment 读取块中的一段, Offset为在块中的偏移
+type ReadSegment struct {
+	runtime.ReadItem
+	Block  int  `json:"block"`
+	Offset uint `json:"offset"`
+}
+
+// maxItemLength 单个读取项最多返回的字节数, 为偶数避免补齐字节
+func maxItemLength(pduSize uint) uint {
+	return (pduSize - runtime.ReadResponseOverhead - runtime.DataItemHeaderLength) &^ 1
+}
+
+// PlanReads 按PDU长度规划读请求
+func PlanReads(device *runtime.S7Device, pduSize uint) *ReadPlan {
+	plan := &ReadPlan{
+		PduSize:  pduSize,
+		Blocks:   planBlocks(device, maxItemLength(pduSize)),
+		Requests: make([]*ReadRequestPlan, 0),
+	}
+
+	var request *ReadRequestPlan
+	for i, block := range plan.Blocks {
+		for offset := uint(0); offset < block.Length; offset += maxItemLength(pduSize) {
+			length := block.Length - offset
+			if length > maxItemLength(pduSize) {
+				length = maxItemLength(pduSize)
+			}
+			segment := &ReadSegment{
+				ReadItem: runtime.ReadItem{Area: block.Area, DBNumber: block.DBNumber, Start: block.Start + offset, Length: length},
+				Block:    i,
+				Offset:   offset,
+			}
+			// 非最后一个读取项的奇数长度数据补齐一个字节, 按补齐计算
+			responseLength := runtime.DataItemHeaderLength + length + length%2
+			if request != nil && (len(request.Items) >= runtime.MaxItemsPerRequest ||
+				request.RequestLength+runtime.RequestItemLength > pduSize ||
+				request.ResponseLength+responseLength > pduSize) {
+				request = nil
+			}
+			if request == nil {
+				request = &ReadRequestPlan{
+					Items:          make([]*ReadSegment, 0),
+					RequestLength:  runtime.RequestOverhead,
+					ResponseLength: runtime.ReadResponseOverhead,
+				}
+				plan.Requests = append(plan.Requests, request)
+			}
+			request.Items = append(request.Items, segment)
+			request.RequestLength += runtime.RequestItemLength
+			request.ResponseLength += responseLength
+		}
+	}
+	return plan
+}
+
+type areaKey struct {
+	area     runtime.Area
+	dbNumber uint16
+}
+
+func planBlocks(device *runtime.S7Device, limit uint) []*ReadBlock {
+	areaVariableMap := make(map[areaKey][]*runtime.Variable)
+	keys := make([]areaKey, 0)
+	for _, variable := range device.Variables {
+		key := areaKey{area: variable.Location.Area, dbNumber: variable.Location.DBNumber}
+		if _, exist := areaVariableMap[key]; !exist {
+			keys = append(keys, key)
+		}
+		areaVariableMap[key] = append(areaVariableMap[key], variable)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].area != keys[j].area {
+			return keys[i].area < keys[j].area
+		}
+		return keys[i].dbNumber < keys[j].dbNumber
+	})
+
+	blocks := make([]*ReadBlock, 0)
+	for _, key := range keys {
+		variables := areaVariableMap[key]
+		sort.Stable(runtime.VariableSlice(variables))
+
+		var start, end uint // 当前块覆盖的字节[start, end)
+		group := make([]*runtime.Variable, 0)
+		flush := func() {
+			if len(group) == 0 {
+				return
+			}
+			block := &ReadBlock{
+				ReadItem:      runtime.ReadItem{Area: key.area, DBNumber: key.dbNumber, Start: start, Length: end - start},
+				VariableNames: make([]string, 0, len(group)),
+				Variables:     make([]*runtime.VariableParse, 0, len(group)),
+			}
+			for _, variable := range group {
+				block.VariableNames = append(block.VariableNames, variable.Name)
+				block.Variables = append(block.Variables, &runtime.VariableParse{
+					Variable: variable,
+					Start:    variable.Location.Start - start,
+				})
+			}
+			blocks = append(blocks, block)
+			group = make([]*runtime.Variable, 0)
+		}
+
+		for _, variable := range variables {
+			variableStart := variable.Location.Start
+			variableEnd := variableStart + variable.Size()
+			if len(group) > 0 {
+				mergedEnd := end
+				if variableEnd > mergedEnd {
+					mergedEnd = variableEnd
+				}
+				if variableStart > end+device.MaxGap || mergedEnd-start > limit {
+					flush()
+				}
+			}
+			if len(group) == 0 {
+				start, end = variableStart, variableEnd
+			} else if variableEnd > end {
+				end = variableEnd
+			}
+			group = append(group, variable)
+		}
+		flush()
+	}
+	return blocks
+}
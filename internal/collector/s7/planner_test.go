@@ -0,0 +1,261 @@
+package s7
+
+import (
+	"fmt"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/s7/runtime"
+	"reflect"
+	"testing"
+)
+
+func newTestDevice(t *testing.T, maxGap uint, mappings []*biz.Mapping) *runtime.S7Device {
+	t.Helper()
+	variables, err := ConvertVariables(mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &runtime.S7Device{MaxGap: maxGap, Variables: variables}
+}
+
+func blockString(block *ReadBlock) string {
+	area := runtime.AreaToString[block.Area]
+	if block.Area == runtime.AreaDB {
+		area = fmt.Sprintf("DB%d", block.DBNumber)
+	}
+	return fmt.Sprintf("%s[%d,%d)%v", area, block.Start, block.Start+block.Length, block.VariableNames)
+}
+
+// checkPlan 每个请求不超过PDU长度与变量数, 读取项按顺序覆盖每个块
+func checkPlan(t *testing.T, plan *ReadPlan) {
+	t.Helper()
+	covered := make([]uint, len(plan.Blocks))
+	for i, request := range plan.Requests {
+		if len(request.Items) == 0 || len(request.Items) > runtime.MaxItemsPerRequest {
+			t.Errorf("request %d: %d items", i, len(request.Items))
+		}
+		if request.RequestLength > plan.PduSize || request.ResponseLength > plan.PduSize {
+			t.Errorf("request %d: request %d response %d exceeds pdu %d", i, request.RequestLength, request.ResponseLength, plan.PduSize)
+		}
+		for _, segment := range request.Items {
+			block := plan.Blocks[segment.Block]
+			if segment.Offset != covered[segment.Block] || segment.Start != block.Start+segment.Offset {
+				t.Errorf("request %d: segment %+v out of order", i, segment)
+			}
+			if segment.Length > maxItemLength(plan.PduSize) {
+				t.Errorf("request %d: segment length %d", i, segment.Length)
+			}
+			covered[segment.Block] += segment.Length
+		}
+	}
+	for i, block := range plan.Blocks {
+		if covered[i] != block.Length {
+			t.Errorf("block %s: covered %d bytes", blockString(block), covered[i])
+		}
+	}
+}
+
+func TestPlanBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxGap   uint
+		mappings []*biz.Mapping
+		blocks   []string
+	}{
+		{
+			name:   "merge within gap",
+			maxGap: 2,
+			mappings: []*biz.Mapping{
+				{Name: "b", Variable: "DB1.DBD6", DataType: "float32"},
+				{Name: "a", Variable: "DB1.DBW2", DataType: "int16"},
+			},
+			blocks: []string{"DB1[2,10)[a b]"},
+		},
+		{
+			name:   "split beyond gap",
+			maxGap: 1,
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "DB1.DBW0", DataType: "int16"},
+				{Name: "b", Variable: "DB1.DBW4", DataType: "int16"},
+			},
+			blocks: []string{"DB1[0,2)[a]", "DB1[4,6)[b]"},
+		},
+		{
+			name: "adjacent without gap",
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "DB1.DBW0", DataType: "int16"},
+				{Name: "b", Variable: "DB1.DBW2", DataType: "uint16"},
+			},
+			blocks: []string{"DB1[0,4)[a b]"},
+		},
+		{
+			name: "bits in one byte",
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "DB1.DBX0.1", DataType: "bool"},
+				{Name: "b", Variable: "DB1.DBX0.0", DataType: "bool"},
+				{Name: "c", Variable: "DB1.DBB0", DataType: "uint8"},
+			},
+			blocks: []string{"DB1[0,1)[a b c]"},
+		},
+		{
+			name: "overlapping variables",
+			mappings: []*biz.Mapping{
+				{Name: "whole", Variable: "DB1.DBB0", DataType: "float64"},
+				{Name: "low", Variable: "DB1.DBD4", DataType: "uint32"},
+			},
+			blocks: []string{"DB1[0,8)[whole low]"},
+		},
+		{
+			name:   "areas and data blocks",
+			maxGap: 32,
+			mappings: []*biz.Mapping{
+				{Name: "db2", Variable: "DB2.DBW0", DataType: "int16"},
+				{Name: "m", Variable: "MW0", DataType: "int16"},
+				{Name: "db1", Variable: "DB1.DBW0", DataType: "int16"},
+				{Name: "q", Variable: "Q0.0", DataType: "bool"},
+				{Name: "i", Variable: "IB0", DataType: "uint8"},
+			},
+			blocks: []string{"I[0,1)[i]", "Q[0,1)[q]", "M[0,2)[m]", "DB1[0,2)[db1]", "DB2[0,2)[db2]"},
+		},
+		{
+			name:   "string",
+			maxGap: 32,
+			mappings: []*biz.Mapping{
+				{Name: "name", Variable: "DB1.STRING10.20", DataType: "string"},
+				{Name: "count", Variable: "DB1.DBW40", DataType: "int16"},
+			},
+			blocks: []string{"DB1[10,42)[name count]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := PlanReads(newTestDevice(t, tt.maxGap, tt.mappings), runtime.DefaultPduSize)
+			blocks := make([]string, 0, len(plan.Blocks))
+			for _, block := range plan.Blocks {
+				blocks = append(blocks, blockString(block))
+			}
+			if !reflect.DeepEqual(blocks, tt.blocks) {
+				t.Fatalf("got %v, want %v", blocks, tt.blocks)
+			}
+			checkPlan(t, plan)
+		})
+	}
+}
+
+func TestPlanReadsPdu(t *testing.T) {
+	many := make([]*biz.Mapping, 0, 25)
+	for i := 0; i < 25; i++ {
+		many = append(many, &biz.Mapping{Name: fmt.Sprintf("v%d", i), Variable: fmt.Sprintf("DB1.DBW%d", i*100), DataType: "int16"})
+	}
+	wide := make([]*biz.Mapping, 0, 8)
+	for i := 0; i < 8; i++ {
+		wide = append(wide, &biz.Mapping{Name: fmt.Sprintf("s%d", i), Variable: fmt.Sprintf("DB%d.STRING0.50", i+1), DataType: "string"})
+	}
+	tests := []struct {
+		name     string
+		pduSize  uint
+		mappings []*biz.Mapping
+		blocks   int
+		requests []int // 每个请求的读取项数
+	}{
+		{
+			// 256字节超过单个读取项的222字节, 拆分后两段的响应超过PDU长度
+			name:     "long string split",
+			pduSize:  240,
+			mappings: []*biz.Mapping{{Name: "text", Variable: "DB1.STRING0.254", DataType: "string"}},
+			blocks:   1,
+			requests: []int{1, 1},
+		},
+		{
+			name:     "long string in large pdu",
+			pduSize:  960,
+			mappings: []*biz.Mapping{{Name: "text", Variable: "DB1.STRING0.254", DataType: "string"}},
+			blocks:   1,
+			requests: []int{1},
+		},
+		{
+			// 请求长度 12 + 12 * 19 = 240
+			name:     "request length",
+			pduSize:  240,
+			mappings: many,
+			blocks:   25,
+			requests: []int{19, 6},
+		},
+		{
+			name:     "items per request",
+			pduSize:  960,
+			mappings: many,
+			blocks:   25,
+			requests: []int{20, 5},
+		},
+		{
+			// 每项响应 4 + 52, 14 + 56 * 4 = 238
+			name:     "response length",
+			pduSize:  240,
+			mappings: wide,
+			blocks:   8,
+			requests: []int{4, 4},
+		},
+		{
+			// 合并后超过单个读取项的长度时不合并
+			name:    "block limit",
+			pduSize: 240,
+			mappings: []*biz.Mapping{
+				{Name: "a", Variable: "DB1.STRING0.150", DataType: "string"},
+				{Name: "b", Variable: "DB1.STRING160.100", DataType: "string"},
+			},
+			blocks:   2,
+			requests: []int{1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := PlanReads(newTestDevice(t, DefaultMaxGap, tt.mappings), tt.pduSize)
+			if len(plan.Blocks) != tt.blocks {
+				t.Fatalf("got %d blocks, want %d", len(plan.Blocks), tt.blocks)
+			}
+			requests := make([]int, 0, len(plan.Requests))
+			for _, request := range plan.Requests {
+				requests = append(requests, len(request.Items))
+			}
+			if !reflect.DeepEqual(requests, tt.requests) {
+				t.Fatalf("got requests %v, want %v", requests, tt.requests)
+			}
+			checkPlan(t, plan)
+		})
+	}
+}
+
+func TestPlanWrites(t *testing.T) {
+	items := func(n, length int, bit bool) []*runtime.WriteItem {
+		result := make([]*runtime.WriteItem, 0, n)
+		for i := 0; i < n; i++ {
+			result = append(result, &runtime.WriteItem{Area: runtime.AreaDB, DBNumber: 1, Start: uint(i * length), BitWrite: bit, Data: make([]byte, length)})
+		}
+		return result
+	}
+	tests := []struct {
+		name     string
+		items    []*runtime.WriteItem
+		pduSize  uint
+		requests [][]int
+	}{
+		{"empty", nil, 240, [][]int{}},
+		{"items per request", items(25, 1, true), 960, [][]int{
+			{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+			{20, 21, 22, 23, 24},
+		}},
+		// 每项 12 + 4 + 1 + 补齐1 = 18, 12 + 18 * 12 = 228
+		{"bits by pdu", items(13, 1, true), 240, [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, {12}}},
+		// 每项 12 + 4 + 200 = 216
+		{"large data", items(3, 200, false), 240, [][]int{{0}, {1}, {2}}},
+		// 单个写入项超过PDU长度时仍单独发送, 由PLC返回错误
+		{"item exceeds pdu", items(1, 300, false), 240, [][]int{{0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if requests := PlanWrites(tt.items, tt.pduSize); !reflect.DeepEqual(requests, tt.requests) {
+				t.Fatalf("got %v, want %v", requests, tt.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package plc
+
+import (
+	"harnsplatform/internal/collector/s7/runtime"
+	"time"
+)
+
+// Config 模拟器配置
+type Config struct {
+	Listen     string             `mapstructure:"listen,omitempty"`   // 监听地址 例如 0.0.0.0:102
+	PduSize    uint               `mapstructure:"pduSize,omitempty"`  // 支持的最大PDU长度, 默认480
+	Rack       *int               `mapstructure:"rack,omitempty"`     // 校验远端TSAP的机架号, 为空时不校验
+	Slot       *int               `mapstructure:"slot,omitempty"`     // 校验远端TSAP的槽号, 为空时不校验
+	Inputs     int                `mapstructure:"inputs,omitempty"`   // I区字节数
+	Outputs    int                `mapstructure:"outputs,omitempty"`  // Q区字节数
+	Merkers    int                `mapstructure:"merkers,omitempty"`  // M区字节数
+	Interval   time.Duration      `mapstructure:"interval,omitempty"` // 计数器刷新间隔, 默认1s
+	DataBlocks []*DataBlockConfig `mapstructure:"dataBlocks,omitempty"`
+	Counters   []string           `mapstructure:"counters,omitempty"` // 每个刷新周期加一的地址 例如 DB1.DBW0
+}
+
+type DataBlockConfig struct {
+	Number int `mapstructure:"number"`
+	Size   int `mapstructure:"size"`
+}
+
+// PLC 按配置创建存储区与计数器
+func (c *Config) PLC() (*PLC, error) {
+	dataBlocks := make(map[uint16]int, len(c.DataBlocks))
+	for _, db := range c.DataBlocks {
+		if db.Number < 1 || db.Number > 65535 {
+			return nil, ErrDataBlockInvalid
+		}
+		dataBlocks[uint16(db.Number)] = db.Size
+	}
+	p := NewPLC(c.Inputs, c.Outputs, c.Merkers, dataBlocks)
+	for _, counter := range c.Counters {
+		address, err := runtime.ParseAddress(counter)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.AddCounter(address); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Server 按配置创建服务
+func (c *Config) Server(p *PLC) *Server {
+	rack, slot := -1, -1
+	if c.Rack != nil {
+		rack = *c.Rack
+	}
+	if c.Slot != nil {
+		slot = *c.Slot
+	}
+	return NewServer(p, c.PduSize, rack, slot)
+}
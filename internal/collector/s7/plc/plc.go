@@ -0,0 +1,158 @@
+package plc
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/collector/s7/runtime"
+	"harnsplatform/internal/utils/binutils"
+	"sync"
+	"time"
+)
+
+/**
+S7 PLC模拟器的存储区
+I Q M 为固定长度的字节数组, DB按编号配置长度
+按变量地址配置计数器, 每个刷新周期加一, 用于验证采集值变化
+*/
+
+var ErrDataBlockInvalid = errors.New("data block number must be between 1 and 65535")
+var ErrCounterInvalid = errors.New("counter address width must be B, W or D")
+
+type PLC struct {
+	mu         sync.RWMutex
+	areas      map[runtime.Area][]byte
+	dataBlocks map[uint16][]byte
+	counters   []*runtime.Address
+}
+
+func NewPLC(inputs, outputs, merkers int, dataBlocks map[uint16]int) *PLC {
+	p := &PLC{
+		areas: map[runtime.Area][]byte{
+			runtime.AreaInputs:  make([]byte, inputs),
+			runtime.AreaOutputs: make([]byte, outputs),
+			runtime.AreaMerkers: make([]byte, merkers),
+		},
+		dataBlocks: make(map[uint16][]byte, len(dataBlocks)),
+	}
+	for number, size := range dataBlocks {
+		p.dataBlocks[number] = make([]byte, size)
+	}
+	return p
+}
+
+// AddCounter 地址处的无符号数每个刷新周期加一
+func (p *PLC) AddCounter(address *runtime.Address) error {
+	switch address.Width {
+	case runtime.WidthByte, runtime.WidthWord, runtime.WidthDWord:
+	default:
+		return ErrCounterInvalid
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counters = append(p.counters, address)
+	return nil
+}
+
+// Run 按刷新周期更新计数器
+func (p *PLC) Run(ctx context.Context, interval time.Duration) {
+	if len(p.counters) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.tick()
+		}
+	}
+}
+
+func (p *PLC) tick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, counter := range p.counters {
+		memory, rc := p.memory(counter.Area, counter.DBNumber)
+		if rc != runtime.ReturnCodeSuccess {
+			continue
+		}
+		start := int(counter.Start)
+		switch counter.Width {
+		case runtime.WidthByte:
+			if start < len(memory) {
+				memory[start]++
+			}
+		case runtime.WidthWord:
+			if start+2 <= len(memory) {
+				binutils.WriteUint16BigEndian(memory[start:], binutils.ParseUint16BigEndian(memory[start:])+1)
+			}
+		case runtime.WidthDWord:
+			if start+4 <= len(memory) {
+				copy(memory[start:], binutils.Uint32ToBytesBigEndian(binutils.ParseUint32BigEndian(memory[start:])+1))
+			}
+		}
+	}
+}
+
+func (p *PLC) memory(area runtime.Area, dbNumber uint16) ([]byte, runtime.ReturnCode) {
+	if area == runtime.AreaDB {
+		if memory, ok := p.dataBlocks[dbNumber]; ok {
+			return memory, runtime.ReturnCodeSuccess
+		}
+		return nil, runtime.ReturnCodeObjectNotExist
+	}
+	if memory, ok := p.areas[area]; ok {
+		return memory, runtime.ReturnCodeSuccess
+	}
+	return nil, runtime.ReturnCodeObjectNotExist
+}
+
+// Read 读取字节, 存储区或DB不存在返回0x0A, 超出范围返回0x05
+func (p *PLC) Read(area runtime.Area, dbNumber uint16, start, length int) ([]byte, runtime.ReturnCode) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	memory, rc := p.memory(area, dbNumber)
+	if rc != runtime.ReturnCodeSuccess {
+		return nil, rc
+	}
+	if start+length > len(memory) {
+		return nil, runtime.ReturnCodeAddressOutOfRange
+	}
+	return binutils.Dup(memory[start : start+length]), runtime.ReturnCodeSuccess
+}
+
+// Write 写入字节
+func (p *PLC) Write(area runtime.Area, dbNumber uint16, start int, data []byte) runtime.ReturnCode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	memory, rc := p.memory(area, dbNumber)
+	if rc != runtime.ReturnCodeSuccess {
+		return rc
+	}
+	if start+len(data) > len(memory) {
+		return runtime.ReturnCodeAddressOutOfRange
+	}
+	copy(memory[start:], data)
+	return runtime.ReturnCodeSuccess
+}
+
+// WriteBit 写入一位
+func (p *PLC) WriteBit(area runtime.Area, dbNumber uint16, start int, bit uint8, value bool) runtime.ReturnCode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	memory, rc := p.memory(area, dbNumber)
+	if rc != runtime.ReturnCodeSuccess {
+		return rc
+	}
+	if start >= len(memory) || bit > 7 {
+		return runtime.ReturnCodeAddressOutOfRange
+	}
+	if value {
+		memory[start] |= 1 << bit
+	} else {
+		memory[start] &^= 1 << bit
+	}
+	return runtime.ReturnCodeSuccess
+}
@@ -0,0 +1,294 @@
+package plc
+
+import (
+	"harnsplatform/internal/collector/s7/runtime"
+	"harnsplatform/internal/utils/binutils"
+	"io"
+	"k8s.io/klog/v2"
+	"net"
+)
+
+/**
+S7 PLC模拟服务 ISO-on-TCP
+COTP CR 校验远端TSAP中的机架号与槽号(未配置时接受任意TSAP), 返回CC
+Setup Communication 协商PDU长度为请求值与配置值中的较小值
+Read Var / Write Var 支持字节与位传输, 响应超过协商的PDU长度时返回错误类别
+其他功能返回ack并携带错误类别
+*/
+
+const (
+	// errorClassApplication 应用关系错误, 用于不支持的功能
+	errorClassApplication byte = 0x81
+	// errorClassParameter 参数错误, 用于请求或响应超过PDU长度
+	errorClassParameter byte = 0x85
+	// errorCodeNotSupported 不支持的服务
+	errorCodeNotSupported byte = 0x04
+	// errorCodeLength PDU长度错误
+	errorCodeLength byte = 0x00
+)
+
+type Server struct {
+	PLC     *PLC
+	PduSize uint
+	// Rack Slot 小于0时不校验
+	Rack int
+	Slot int
+}
+
+func NewServer(plc *PLC, pduSize uint, rack, slot int) *Server {
+	return &Server{PLC: plc, PduSize: pduSize, Rack: rack, Slot: slot}
+}
+
+// Serve 在listener上提供S7服务
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		klog.V(4).InfoS("S7 plc simulator accepted connection", "remote", conn.RemoteAddr())
+		go func() {
+			defer conn.Close()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	tpdu, err := readTpkt(conn)
+	if err != nil {
+		return
+	}
+	response, ok := s.connectConfirm(tpdu)
+	if !ok {
+		klog.V(3).InfoS("S7 plc simulator refused connection", "remote", conn.RemoteAddr())
+		return
+	}
+	if _, err = conn.Write(tpkt(response)); err != nil {
+		return
+	}
+
+	pduSize := s.PduSize
+	pdu := make([]byte, 0)
+	for {
+		tpdu, err = readTpkt(conn)
+		if err != nil {
+			return
+		}
+		if len(tpdu) < runtime.CotpDataHeaderLength || tpdu[1] != runtime.CotpData {
+			klog.V(3).InfoS("S7 plc simulator received invalid cotp frame")
+			return
+		}
+		pdu = append(pdu, tpdu[runtime.CotpDataHeaderLength:]...)
+		if tpdu[2]&0x80 == 0 {
+			continue
+		}
+		request := pdu
+		pdu = make([]byte, 0)
+
+		if len(request) < runtime.JobHeaderLength || request[0] != runtime.ProtocolId || request[1] != runtime.RosctrJob {
+			klog.V(3).InfoS("S7 plc simulator received invalid pdu")
+			return
+		}
+		ref := binutils.ParseUint16BigEndian(request[4:])
+		paramLength := int(binutils.ParseUint16BigEndian(request[6:]))
+		dataLength := int(binutils.ParseUint16BigEndian(request[8:]))
+		if runtime.JobHeaderLength+paramLength+dataLength > len(request) || paramLength == 0 {
+			klog.V(3).InfoS("S7 plc simulator received invalid pdu length")
+			return
+		}
+		param := request[runtime.JobHeaderLength : runtime.JobHeaderLength+paramLength]
+		data := request[runtime.JobHeaderLength+paramLength : runtime.JobHeaderLength+paramLength+dataLength]
+
+		var reply []byte
+		switch {
+		case param[0] == runtime.FunctionSetupCommunication && len(param) >= 8:
+			if requested := uint(binutils.ParseUint16BigEndian(param[6:])); requested < pduSize {
+				pduSize = requested
+			}
+			negotiated := binutils.Dup(param[:8])
+			binutils.WriteUint16BigEndian(negotiated[6:], uint16(pduSize))
+			reply = ackData(ref, negotiated, nil)
+		case len(request) > int(pduSize):
+			reply = ack(ref, errorClassParameter, errorCodeLength)
+		case param[0] == runtime.FunctionReadVar:
+			reply = s.readVar(ref, param, pduSize)
+		case param[0] == runtime.FunctionWriteVar:
+			reply = s.writeVar(ref, param, data)
+		default:
+			reply = ack(ref, errorClassApplication, errorCodeNotSupported)
+		}
+		if _, err = conn.Write(tpkt(append([]byte{0x02, runtime.CotpData, 0x80}, reply...))); err != nil {
+			return
+		}
+	}
+}
+
+// connectConfirm 校验连接请求中的远端TSAP, 返回连接确认
+func (s *Server) connectConfirm(tpdu []byte) ([]byte, bool) {
+	if len(tpdu) < 7 || tpdu[1]&0xF0 != runtime.CotpConnectRequest {
+		return nil, false
+	}
+	var localTSAP, remoteTSAP []byte
+	for i := 7; i+2 <= len(tpdu); {
+		code, length := tpdu[i], int(tpdu[i+1])
+		if i+2+length > len(tpdu) {
+			return nil, false
+		}
+		switch code {
+		case 0xC1:
+			localTSAP = tpdu[i+2 : i+2+length]
+		case 0xC2:
+			remoteTSAP = tpdu[i+2 : i+2+length]
+		}
+		i += 2 + length
+	}
+	if len(localTSAP) != 2 || len(remoteTSAP) != 2 {
+		return nil, false
+	}
+	// 远端TSAP低字节 机架号(3位) 槽号(5位)
+	if s.Rack >= 0 && int(remoteTSAP[1]>>5) != s.Rack {
+		return nil, false
+	}
+	if s.Slot >= 0 && int(remoteTSAP[1]&0x1F) != s.Slot {
+		return nil, false
+	}
+	return []byte{
+		0x11, runtime.CotpConnectConfirm, tpdu[4], tpdu[5], 0x00, 0x01, 0x00,
+		0xC0, 0x01, 0x0A,
+		0xC1, 0x02, localTSAP[0], localTSAP[1],
+		0xC2, 0x02, remoteTSAP[0], remoteTSAP[1],
+	}, true
+}
+
+// itemSpec 解析变量描述, 返回传输类型 数量 DB号 存储区 位地址
+func itemSpec(spec []byte) (byte, int, uint16, runtime.Area, int, bool) {
+	if len(spec) < runtime.RequestItemLength || spec[0] != 0x12 || spec[1] != 0x0A || spec[2] != 0x10 {
+		return 0, 0, 0, 0, 0, false
+	}
+	bitOffset := int(spec[9])<<16 | int(spec[10])<<8 | int(spec[11])
+	return spec[3], int(binutils.ParseUint16BigEndian(spec[4:])), binutils.ParseUint16BigEndian(spec[6:]), runtime.Area(spec[8]), bitOffset, true
+}
+
+func (s *Server) readVar(ref uint16, param []byte, pduSize uint) []byte {
+	if len(param) < 2 || len(param) < 2+int(param[1])*runtime.RequestItemLength {
+		return ack(ref, errorClassParameter, errorCodeLength)
+	}
+	count := int(param[1])
+	data := make([]byte, 0)
+	for i := 0; i < count; i++ {
+		if len(data)%2 == 1 {
+			data = append(data, 0x00)
+		}
+		transportSize, length, dbNumber, area, bitOffset, ok := itemSpec(param[2+i*runtime.RequestItemLength:])
+		if !ok {
+			data = append(data, byte(runtime.ReturnCodeDataTypeUnsupported), 0x00, 0x00, 0x00)
+			continue
+		}
+		switch transportSize {
+		case runtime.TransportSizeBit:
+			value, rc := s.PLC.Read(area, dbNumber, bitOffset>>3, 1)
+			if rc != runtime.ReturnCodeSuccess {
+				data = append(data, byte(rc), 0x00, 0x00, 0x00)
+				continue
+			}
+			data = append(data, byte(rc), runtime.DataTransportSizeBit, 0x00, 0x01, value[0]>>(bitOffset&0x07)&0x01)
+		case runtime.TransportSizeByte:
+			if bitOffset&0x07 != 0 {
+				data = append(data, byte(runtime.ReturnCodeAddressOutOfRange), 0x00, 0x00, 0x00)
+				continue
+			}
+			value, rc := s.PLC.Read(area, dbNumber, bitOffset>>3, length)
+			if rc != runtime.ReturnCodeSuccess {
+				data = append(data, byte(rc), 0x00, 0x00, 0x00)
+				continue
+			}
+			header := []byte{byte(rc), runtime.DataTransportSizeByte, 0x00, 0x00}
+			binutils.WriteUint16BigEndian(header[2:], uint16(length*8))
+			data = append(data, header...)
+			data = append(data, value...)
+		default:
+			data = append(data, byte(runtime.ReturnCodeDataTypeUnsupported), 0x00, 0x00, 0x00)
+		}
+	}
+	if runtime.ReadResponseOverhead+len(data) > int(pduSize) {
+		klog.V(3).InfoS("S7 plc simulator read response exceeds pdu size", "length", runtime.ReadResponseOverhead+len(data), "pduSize", pduSize)
+		return ack(ref, errorClassParameter, errorCodeLength)
+	}
+	return ackData(ref, []byte{runtime.FunctionReadVar, byte(count)}, data)
+}
+
+func (s *Server) writeVar(ref uint16, param, data []byte) []byte {
+	if len(param) < 2 || len(param) < 2+int(param[1])*runtime.RequestItemLength {
+		return ack(ref, errorClassParameter, errorCodeLength)
+	}
+	count := int(param[1])
+	rcs := make([]byte, count)
+	offset := 0
+	for i := 0; i < count; i++ {
+		if offset%2 == 1 {
+			offset++
+		}
+		if offset+runtime.DataItemHeaderLength > len(data) {
+			return ack(ref, errorClassParameter, errorCodeLength)
+		}
+		transportSize := data[offset+1]
+		length := int(binutils.ParseUint16BigEndian(data[offset+2:]))
+		switch transportSize {
+		case runtime.DataTransportSizeBit, runtime.DataTransportSizeByte, runtime.DataTransportSizeInteger:
+			length = (length + 7) / 8
+		}
+		offset += runtime.DataItemHeaderLength
+		if offset+length > len(data) {
+			return ack(ref, errorClassParameter, errorCodeLength)
+		}
+		value := data[offset : offset+length]
+		offset += length
+
+		itemTransportSize, itemLength, dbNumber, area, bitOffset, ok := itemSpec(param[2+i*runtime.RequestItemLength:])
+		switch {
+		case !ok:
+			rcs[i] = byte(runtime.ReturnCodeDataTypeUnsupported)
+		case itemTransportSize == runtime.TransportSizeBit && length == 1:
+			rcs[i] = byte(s.PLC.WriteBit(area, dbNumber, bitOffset>>3, uint8(bitOffset&0x07), value[0]&0x01 == 0x01))
+		case itemTransportSize == runtime.TransportSizeByte && length == itemLength && bitOffset&0x07 == 0:
+			rcs[i] = byte(s.PLC.Write(area, dbNumber, bitOffset>>3, value))
+		default:
+			rcs[i] = byte(runtime.ReturnCodeDataTypeInconsistent)
+		}
+	}
+	return ackData(ref, []byte{runtime.FunctionWriteVar, byte(count)}, rcs)
+}
+
+func ackData(ref uint16, param, data []byte) []byte {
+	pdu := []byte{runtime.ProtocolId, runtime.RosctrAckData, 0x00, 0x00, byte(ref >> 8), byte(ref),
+		byte(len(param) >> 8), byte(len(param)), byte(len(data) >> 8), byte(len(data)), 0x00, 0x00}
+	pdu = append(pdu, param...)
+	return append(pdu, data...)
+}
+
+// ack 不携带参数与数据的错误响应
+func ack(ref uint16, class, code byte) []byte {
+	return []byte{runtime.ProtocolId, runtime.RosctrAck, 0x00, 0x00, byte(ref >> 8), byte(ref), 0x00, 0x00, 0x00, 0x00, class, code}
+}
+
+func tpkt(tpdu []byte) []byte {
+	length := runtime.TpktHeaderLength + len(tpdu)
+	return append([]byte{0x03, 0x00, byte(length >> 8), byte(length)}, tpdu...)
+}
+
+func readTpkt(r io.Reader) ([]byte, error) {
+	header := make([]byte, runtime.TpktHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binutils.ParseUint16BigEndian(header[2:]))
+	if header[0] != 0x03 || length <= runtime.TpktHeaderLength {
+		return nil, runtime.ErrTpktInvalid
+	}
+	tpdu := make([]byte, length-runtime.TpktHeaderLength)
+	if _, err := io.ReadFull(r, tpdu); err != nil {
+		return nil, err
+	}
+	return tpdu, nil
+}
@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+变量地址
+数据块   DB10.DBX0.1  DB10.DBB2  DB10.DBW4  DB10.DBD6  DB10.STRING10.20(起始字节10, 最大20个字符)
+位存储区 M0.1  MX0.1  MB1  MW2  MD4
+输入输出 I0.0  IB0  IW2  ID4  Q0.0  QB0  QW2  QD4, 兼容 E/A
+*/
+
+// Width 地址宽度
+type Width byte
+
+const (
+	WidthBit    Width = 'X'
+	WidthByte   Width = 'B'
+	WidthWord   Width = 'W'
+	WidthDWord  Width = 'D'
+	WidthString Width = 'S'
+)
+
+var (
+	dbAddressRegexp     = regexp.MustCompile(`^DB(\d+)\.DB([XBWD])(\d+)(?:\.(\d+))?$`)
+	dbStringRegexp      = regexp.MustCompile(`^DB(\d+)\.STRING(\d+)\.(\d+)$`)
+	memoryAddressRegexp = regexp.MustCompile(`^([IEQAM])([XBWD])?(\d+)(?:\.(\d+))?$`)
+)
+
+type Address struct {
+	Area         Area   `json:"area"`
+	DBNumber     uint16 `json:"dbNumber,omitempty"`
+	Start        uint   `json:"start"` // 起始字节
+	Bit          uint8  `json:"bit,omitempty"`
+	Width        Width  `json:"width"`
+	StringLength uint   `json:"stringLength,omitempty"` // 字符串最大字符数
+}
+
+// ParseAddress 解析变量地址, 不区分大小写
+func ParseAddress(s string) (*Address, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if m := dbStringRegexp.FindStringSubmatch(s); m != nil {
+		db, err := parseUint(m[1], 1, 65535)
+		if err != nil {
+			return nil, err
+		}
+		start, err := parseUint(m[2], 0, 65535)
+		if err != nil {
+			return nil, err
+		}
+		length, err := parseUint(m[3], 1, MaxStringLength)
+		if err != nil {
+			return nil, err
+		}
+		return &Address{Area: AreaDB, DBNumber: uint16(db), Start: start, Width: WidthString, StringLength: length}, nil
+	}
+
+	var area Area
+	var db uint
+	var width, startString, bitString string
+	if m := dbAddressRegexp.FindStringSubmatch(s); m != nil {
+		n, err := parseUint(m[1], 1, 65535)
+		if err != nil {
+			return nil, err
+		}
+		area, db, width, startString, bitString = AreaDB, n, m[2], m[3], m[4]
+	} else if m = memoryAddressRegexp.FindStringSubmatch(s); m != nil {
+		area, width, startString, bitString = StringToArea[m[1]], m[2], m[3], m[4]
+		// M0.1 省略了X
+		if width == "" && bitString != "" {
+			width = string(WidthBit)
+		}
+	} else {
+		return nil, ErrVariableAddressInvalid
+	}
+
+	if width == "" {
+		return nil, ErrVariableAddressInvalid
+	}
+	start, err := parseUint(startString, 0, 65535)
+	if err != nil {
+		return nil, err
+	}
+	address := &Address{Area: area, DBNumber: uint16(db), Start: start, Width: Width(width[0])}
+	if address.Width == WidthBit {
+		if bitString == "" {
+			return nil, ErrVariableAddressInvalid
+		}
+		bit, err := parseUint(bitString, 0, 7)
+		if err != nil {
+			return nil, err
+		}
+		address.Bit = uint8(bit)
+	} else if bitString != "" {
+		return nil, ErrVariableAddressInvalid
+	}
+	return address, nil
+}
+
+func parseUint(s string, min, max uint) (uint, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || uint(v) < min || uint(v) > max {
+		return 0, ErrVariableAddressInvalid
+	}
+	return uint(v), nil
+}
+
+// BitOffset 请求中的地址, 字节 * 8 + 位
+func (a *Address) BitOffset() uint32 {
+	return uint32(a.Start)*8 + uint32(a.Bit)
+}
+
+func (a *Address) String() string {
+	prefix := AreaToString[a.Area]
+	if a.Area == AreaDB {
+		prefix = fmt.Sprintf("DB%d.DB", a.DBNumber)
+	}
+	switch a.Width {
+	case WidthBit:
+		return fmt.Sprintf("%sX%d.%d", prefix, a.Start, a.Bit)
+	case WidthString:
+		return fmt.Sprintf("DB%d.STRING%d.%d", a.DBNumber, a.Start, a.StringLength)
+	}
+	return fmt.Sprintf("%s%c%d", prefix, a.Width, a.Start)
+}
@@ -0,0 +1,95 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    *Address
+		text    string
+	}{
+		{"DB10.DBX0.1", &Address{Area: AreaDB, DBNumber: 10, Start: 0, Bit: 1, Width: WidthBit}, "DB10.DBX0.1"},
+		{"db10.dbb2", &Address{Area: AreaDB, DBNumber: 10, Start: 2, Width: WidthByte}, "DB10.DBB2"},
+		{" DB1.DBW4 ", &Address{Area: AreaDB, DBNumber: 1, Start: 4, Width: WidthWord}, "DB1.DBW4"},
+		{"DB65535.DBD65535", &Address{Area: AreaDB, DBNumber: 65535, Start: 65535, Width: WidthDWord}, "DB65535.DBD65535"},
+		{"DB10.STRING10.20", &Address{Area: AreaDB, DBNumber: 10, Start: 10, Width: WidthString, StringLength: 20}, "DB10.STRING10.20"},
+		{"M0.1", &Address{Area: AreaMerkers, Start: 0, Bit: 1, Width: WidthBit}, "MX0.1"},
+		{"MX3.7", &Address{Area: AreaMerkers, Start: 3, Bit: 7, Width: WidthBit}, "MX3.7"},
+		{"MB1", &Address{Area: AreaMerkers, Start: 1, Width: WidthByte}, "MB1"},
+		{"MW2", &Address{Area: AreaMerkers, Start: 2, Width: WidthWord}, "MW2"},
+		{"MD4", &Address{Area: AreaMerkers, Start: 4, Width: WidthDWord}, "MD4"},
+		{"I0.0", &Address{Area: AreaInputs, Start: 0, Bit: 0, Width: WidthBit}, "IX0.0"},
+		{"IW2", &Address{Area: AreaInputs, Start: 2, Width: WidthWord}, "IW2"},
+		{"E1.2", &Address{Area: AreaInputs, Start: 1, Bit: 2, Width: WidthBit}, "IX1.2"},
+		{"Q0.0", &Address{Area: AreaOutputs, Start: 0, Width: WidthBit}, "QX0.0"},
+		{"QD4", &Address{Area: AreaOutputs, Start: 4, Width: WidthDWord}, "QD4"},
+		{"AB3", &Address{Area: AreaOutputs, Start: 3, Width: WidthByte}, "QB3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got, err := ParseAddress(tt.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.text {
+				t.Fatalf("String() = %s, want %s", got.String(), tt.text)
+			}
+			// String的结果可以再次解析
+			again, err := ParseAddress(got.String())
+			if err != nil || !reflect.DeepEqual(again, got) {
+				t.Fatalf("reparse %s: %+v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	for _, address := range []string{
+		"",
+		"DB0.DBW0",        // DB号从1开始
+		"DB65536.DBW0",    // DB号超出范围
+		"DB1.DBX0",        // 位地址缺少位号
+		"DB1.DBX0.8",      // 位号超出范围
+		"DB1.DBW0.1",      // 字地址不能带位号
+		"DB1.DBW65536",    // 起始字节超出范围
+		"DB1.STRING0.0",   // 字符串长度从1开始
+		"DB1.STRING0.255", // 超过最大字符数
+		"DB1.DBS0",        // 字符串须指定长度
+		"M1",              // 缺少宽度
+		"MW1.2",           // 字地址不能带位号
+		"T1",              // 不支持的存储区
+		"DB1.DBW-1",
+		"DB1.DBW1x",
+	} {
+		if _, err := ParseAddress(address); err != ErrVariableAddressInvalid {
+			t.Errorf("ParseAddress(%q) = %v, want %v", address, err, ErrVariableAddressInvalid)
+		}
+	}
+}
+
+func TestAddressBitOffset(t *testing.T) {
+	tests := []struct {
+		address string
+		offset  uint32
+	}{
+		{"DB1.DBX0.0", 0},
+		{"DB1.DBX2.5", 21},
+		{"MW10", 80},
+		{"DB1.DBD65535", 524280},
+	}
+	for _, tt := range tests {
+		address, err := ParseAddress(tt.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address.BitOffset() != tt.offset {
+			t.Errorf("%s: got %d, want %d", tt.address, address.BitOffset(), tt.offset)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/utils/binutils"
+	"io"
+	"k8s.io/klog/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+/**
+S7comm over ISO-on-TCP (RFC1006)
+TPKT(4) + COTP(3) + S7 PDU
+建立连接: COTP CR/CC 携带本地与远端TSAP, 之后以Setup Communication协商PDU长度
+S7 PDU: 请求头(10) + 参数 + 数据, ack data响应头(12) 多出错误类别与错误码
+读请求参数: 功能码(1) + 变量数(1) + 变量描述(12) * n
+读响应数据: 返回码(1) + 传输类型(1) + 长度(2) + 数据, 非最后一个变量的奇数长度数据补齐一个字节
+写请求数据与读响应数据格式相同, 写响应数据为每个变量的返回码
+*/
+
+// Client 单个TCP连接, 请求按顺序执行
+type Client struct {
+	Address    string
+	LocalTSAP  uint16
+	RemoteTSAP uint16
+	Timeout    time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pduRef  uint16
+	pduSize uint
+}
+
+// RemoteTSAP 连接类型(1) + 机架号(3位) 槽号(5位)
+func RemoteTSAP(connectionType ConnectionType, rack, slot uint) uint16 {
+	return uint16(connectionType)<<8 | uint16(rack<<5|slot)
+}
+
+// Dial 建立连接并协商PDU长度
+func Dial(ctx context.Context, address string, localTSAP, remoteTSAP uint16, pduSize uint, timeout time.Duration) (*Client, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		Address:    address,
+		LocalTSAP:  localTSAP,
+		RemoteTSAP: remoteTSAP,
+		Timeout:    timeout,
+		conn:       conn,
+	}
+	if err = c.connectCotp(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err = c.setupCommunication(pduSize); err != nil {
+		c.Close()
+		return nil, err
+	}
+	klog.V(4).InfoS("Connected s7 plc", "address", address, "remoteTsap", remoteTSAP, "pduSize", c.pduSize)
+	return c, nil
+}
+
+// PduSize 协商后的PDU长度
+func (c *Client) PduSize() uint {
+	return c.pduSize
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// Available 连接出错后关闭, 需要重新建立
+func (c *Client) Available() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+func (c *Client) connectCotp() error {
+	request := []byte{
+		0x03, 0x00, 0x00, 0x16, // TPKT
+		0x11, CotpConnectRequest, 0x00, 0x00, 0x00, 0x01, 0x00, // 目标引用 源引用 类别0
+		0xC0, 0x01, 0x0A, // TPDU长度 1024
+		0xC1, 0x02, byte(c.LocalTSAP >> 8), byte(c.LocalTSAP),
+		0xC2, 0x02, byte(c.RemoteTSAP >> 8), byte(c.RemoteTSAP),
+	}
+	_ = c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err := c.conn.Write(request); err != nil {
+		return err
+	}
+	tpdu, err := readTpkt(c.conn)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return ErrCotpConnectRefused
+		}
+		return err
+	}
+	if len(tpdu) < 2 || tpdu[1]&0xF0 != CotpConnectConfirm {
+		return ErrCotpConnectRefused
+	}
+	return nil
+}
+
+func (c *Client) setupCommunication(pduSize uint) error {
+	param := []byte{FunctionSetupCommunication, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00}
+	binutils.WriteUint16BigEndian(param[6:], uint16(pduSize))
+	params, _, err := c.exchange(param, nil)
+	if err != nil {
+		return err
+	}
+	if len(params) < 8 || params[0] != FunctionSetupCommunication {
+		return ErrPduInvalid
+	}
+	c.pduSize = uint(binutils.ParseUint16BigEndian(params[6:]))
+	if c.pduSize < MinPduSize {
+		return ErrPduSizeInvalid
+	}
+	return nil
+}
+
+// ReadItems 读取多个变量, 返回每个变量的数据与错误, error为通讯错误
+func (c *Client) ReadItems(items []*ReadItem) ([][]byte, []error, error) {
+	param := make([]byte, 2, 2+len(items)*RequestItemLength)
+	param[0], param[1] = FunctionReadVar, byte(len(items))
+	for _, item := range items {
+		param = append(param, itemSpec(TransportSizeByte, item.Length, item.DBNumber, item.Area, uint32(item.Start)*8)...)
+	}
+	params, data, err := c.exchange(param, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(params) < 2 || params[0] != FunctionReadVar {
+		return nil, nil, ErrPduInvalid
+	}
+	if int(params[1]) != len(items) {
+		return nil, nil, ErrItemCountMismatch
+	}
+
+	values := make([][]byte, len(items))
+	errs := make([]error, len(items))
+	offset := 0
+	for i := range items {
+		if offset+DataItemHeaderLength > len(data) {
+			return nil, nil, ErrPduInvalid
+		}
+		rc := ReturnCode(data[offset])
+		length := dataLength(data[offset+1], binutils.ParseUint16BigEndian(data[offset+2:]))
+		offset += DataItemHeaderLength
+		if rc != ReturnCodeSuccess {
+			errs[i] = rc
+			continue
+		}
+		if offset+length > len(data) {
+			return nil, nil, ErrPduInvalid
+		}
+		if uint(length) != items[i].Length {
+			errs[i] = ReturnCodeDataTypeInconsistent
+		} else {
+			values[i] = binutils.Dup(data[offset : offset+length])
+		}
+		offset += length
+		if length%2 == 1 && i < len(items)-1 {
+			offset++
+		}
+	}
+	return values, errs, nil
+}
+
+// WriteItems 写入多个变量, 返回每个变量的错误, error为通讯错误
+func (c *Client) WriteItems(items []*WriteItem) ([]error, error) {
+	param := make([]byte, 2, 2+len(items)*RequestItemLength)
+	param[0], param[1] = FunctionWriteVar, byte(len(items))
+	data := make([]byte, 0)
+	for i, item := range items {
+		if item.BitWrite {
+			param = append(param, itemSpec(TransportSizeBit, 1, item.DBNumber, item.Area, uint32(item.Start)*8+uint32(item.Bit))...)
+			data = append(data, 0x00, DataTransportSizeBit, 0x00, 0x01, item.Data[0])
+		} else {
+			param = append(param, itemSpec(TransportSizeByte, uint(len(item.Data)), item.DBNumber, item.Area, uint32(item.Start)*8)...)
+			data = append(data, 0x00, DataTransportSizeByte, 0x00, 0x00)
+			binutils.WriteUint16BigEndian(data[len(data)-2:], uint16(len(item.Data)*8))
+			data = append(data, item.Data...)
+		}
+		if len(data)%2 == 1 && i < len(items)-1 {
+			data = append(data, 0x00)
+		}
+	}
+	params, rcs, err := c.exchange(param, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) < 2 || params[0] != FunctionWriteVar {
+		return nil, ErrPduInvalid
+	}
+	if int(params[1]) != len(items) || len(rcs) < len(items) {
+		return nil, ErrItemCountMismatch
+	}
+	errs := make([]error, len(items))
+	for i := range items {
+		if rc := ReturnCode(rcs[i]); rc != ReturnCodeSuccess {
+			errs[i] = rc
+		}
+	}
+	return errs, nil
+}
+
+// itemSpec 变量描述 规范(1) + 长度(1) + 语法(1) + 传输类型(1) + 数量(2) + DB号(2) + 存储区(1) + 位地址(3)
+func itemSpec(transportSize byte, length uint, dbNumber uint16, area Area, bitOffset uint32) []byte {
+	spec := []byte{0x12, 0x0A, 0x10, transportSize, 0, 0, 0, 0, byte(area), 0, 0, 0}
+	binutils.WriteUint16BigEndian(spec[4:], uint16(length))
+	binutils.WriteUint16BigEndian(spec[6:], dbNumber)
+	binutils.WriteUint24BigEndian(spec[9:], bitOffset)
+	return spec
+}
+
+// dataLength 数据的字节数, 传输类型为位或字节时长度以位计
+func dataLength(transportSize byte, length uint16) int {
+	switch transportSize {
+	case DataTransportSizeBit:
+		return (int(length) + 7) / 8
+	case DataTransportSizeByte, DataTransportSizeInteger:
+		return int(length) / 8
+	}
+	return int(length)
+}
+
+// exchange 发送job请求, 返回ack data响应的参数与数据
+func (c *Client) exchange(param, data []byte) ([]byte, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil, nil, ErrS7BadConn
+	}
+	c.pduRef++
+	ref := c.pduRef
+
+	length := TpktHeaderLength + CotpDataHeaderLength + JobHeaderLength + len(param) + len(data)
+	request := make([]byte, 0, length)
+	request = append(request, 0x03, 0x00, byte(length>>8), byte(length))
+	request = append(request, 0x02, CotpData, 0x80)
+	request = append(request, ProtocolId, RosctrJob, 0x00, 0x00, byte(ref>>8), byte(ref),
+		byte(len(param)>>8), byte(len(param)), byte(len(data)>>8), byte(len(data)))
+	request = append(request, param...)
+	request = append(request, data...)
+
+	_ = c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	if _, err := c.conn.Write(request); err != nil {
+		return nil, nil, c.fail(err)
+	}
+	pdu := make([]byte, 0)
+	for {
+		tpdu, err := readTpkt(c.conn)
+		if err != nil {
+			return nil, nil, c.fail(err)
+		}
+		// 数据TPDU 长度(1) + DT(1) + 编号及结束标记(1)
+		if len(tpdu) < CotpDataHeaderLength || tpdu[1] != CotpData {
+			return nil, nil, c.fail(ErrCotpInvalid)
+		}
+		pdu = append(pdu, tpdu[CotpDataHeaderLength:]...)
+		if tpdu[2]&0x80 != 0 {
+			break
+		}
+	}
+	_ = c.conn.SetDeadline(time.Time{})
+
+	// 不支持的请求以ack返回错误类别
+	if len(pdu) < AckDataHeaderLength || pdu[0] != ProtocolId || (pdu[1] != RosctrAck && pdu[1] != RosctrAckData) {
+		return nil, nil, ErrPduInvalid
+	}
+	if binutils.ParseUint16BigEndian(pdu[4:]) != ref {
+		return nil, nil, c.fail(ErrPduRefMismatch)
+	}
+	if pdu[10] != 0 || pdu[11] != 0 {
+		return nil, nil, &PduError{Class: pdu[10], Code: pdu[11]}
+	}
+	if pdu[1] != RosctrAckData {
+		return nil, nil, ErrPduInvalid
+	}
+	paramLength := int(binutils.ParseUint16BigEndian(pdu[6:]))
+	dataLength := int(binutils.ParseUint16BigEndian(pdu[8:]))
+	if AckDataHeaderLength+paramLength+dataLength > len(pdu) {
+		return nil, nil, ErrPduInvalid
+	}
+	params := pdu[AckDataHeaderLength : AckDataHeaderLength+paramLength]
+	return params, pdu[AckDataHeaderLength+paramLength : AckDataHeaderLength+paramLength+dataLength], nil
+}
+
+// fail 通讯错误后连接中可能残留响应, 关闭连接由调用方重连
+func (c *Client) fail(err error) error {
+	klog.V(3).InfoS("Failed to ask s7 plc", "address", c.Address, "error", err)
+	_ = c.conn.Close()
+	c.conn = nil
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return ErrS7Timeout
+	}
+	return ErrS7BadConn
+}
+
+// readTpkt 读取一个TPKT, 返回其中的TPDU
+func readTpkt(r io.Reader) ([]byte, error) {
+	header := make([]byte, TpktHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(binutils.ParseUint16BigEndian(header[2:]))
+	if header[0] != 0x03 || length <= TpktHeaderLength {
+		return nil, ErrTpktInvalid
+	}
+	tpdu := make([]byte, length-TpktHeaderLength)
+	if _, err := io.ReadFull(r, tpdu); err != nil {
+		return nil, err
+	}
+	return tpdu, nil
+}
@@ -0,0 +1,183 @@
+package runtime_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"harnsplatform/internal/collector/s7/plc"
+	"harnsplatform/internal/collector/s7/runtime"
+	"net"
+	"testing"
+	"time"
+)
+
+// startTestPlc 机架0槽号1, 协商的PDU长度为240
+func startTestPlc(t *testing.T) (*plc.PLC, string) {
+	t.Helper()
+	p := plc.NewPLC(16, 16, 64, map[uint16]int{1: 100})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go plc.NewServer(p, 240, 0, 1).Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return p, l.Addr().String()
+}
+
+func dialTestPlc(t *testing.T, address string) *runtime.Client {
+	t.Helper()
+	c, err := runtime.Dial(context.Background(), address, runtime.DefaultLocalTSAP,
+		runtime.RemoteTSAP(runtime.ConnectionTypePG, 0, 1), runtime.DefaultPduSize, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestDial(t *testing.T) {
+	_, address := startTestPlc(t)
+	c := dialTestPlc(t, address)
+	if c.PduSize() != 240 {
+		t.Fatalf("negotiated pdu size %d", c.PduSize())
+	}
+
+	// 槽号不匹配时PLC拒绝连接
+	_, err := runtime.Dial(context.Background(), address, runtime.DefaultLocalTSAP,
+		runtime.RemoteTSAP(runtime.ConnectionTypePG, 0, 2), runtime.DefaultPduSize, time.Second)
+	if err != runtime.ErrCotpConnectRefused {
+		t.Fatalf("got %v, want %v", err, runtime.ErrCotpConnectRefused)
+	}
+}
+
+func TestRemoteTSAP(t *testing.T) {
+	tests := []struct {
+		connectionType runtime.ConnectionType
+		rack, slot     uint
+		tsap           uint16
+	}{
+		{runtime.ConnectionTypePG, 0, 2, 0x0102},
+		{runtime.ConnectionTypeOP, 0, 1, 0x0201},
+		{runtime.ConnectionTypeBasic, 1, 3, 0x0323},
+	}
+	for _, tt := range tests {
+		if tsap := runtime.RemoteTSAP(tt.connectionType, tt.rack, tt.slot); tsap != tt.tsap {
+			t.Errorf("got %04X, want %04X", tsap, tt.tsap)
+		}
+	}
+}
+
+// 非最后一个读取项的奇数长度数据后有一个补齐字节
+func TestReadItems(t *testing.T) {
+	p, address := startTestPlc(t)
+	c := dialTestPlc(t, address)
+	p.Write(runtime.AreaDB, 1, 0, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12})
+	p.Write(runtime.AreaMerkers, 0, 5, []byte{0xAA})
+	p.Write(runtime.AreaInputs, 0, 2, []byte{0x12, 0x34})
+	p.Write(runtime.AreaOutputs, 0, 0, []byte{0x55, 0x66, 0x77})
+
+	items := []*runtime.ReadItem{
+		{Area: runtime.AreaDB, DBNumber: 1, Start: 1, Length: 3},
+		{Area: runtime.AreaMerkers, Start: 5, Length: 1},
+		{Area: runtime.AreaDB, DBNumber: 2, Start: 0, Length: 2},
+		{Area: runtime.AreaInputs, Start: 2, Length: 2},
+		{Area: runtime.AreaDB, DBNumber: 1, Start: 98, Length: 5},
+		{Area: runtime.AreaOutputs, Start: 0, Length: 3},
+	}
+	values, errs, err := c.ReadItems(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		value []byte
+		err   error
+	}{
+		{[]byte{2, 3, 4}, nil},
+		{[]byte{0xAA}, nil},
+		{nil, runtime.ReturnCodeObjectNotExist},
+		{[]byte{0x12, 0x34}, nil},
+		{nil, runtime.ReturnCodeAddressOutOfRange},
+		{[]byte{0x55, 0x66, 0x77}, nil},
+	}
+	for i := range want {
+		if errs[i] != want[i].err || !bytes.Equal(values[i], want[i].value) {
+			t.Errorf("item %d: got %x, %v, want %x, %v", i, values[i], errs[i], want[i].value, want[i].err)
+		}
+	}
+}
+
+func TestReadItemsExceedPdu(t *testing.T) {
+	_, address := startTestPlc(t)
+	c := dialTestPlc(t, address)
+	_, _, err := c.ReadItems([]*runtime.ReadItem{{Area: runtime.AreaDB, DBNumber: 1, Start: 0, Length: 100}, {Area: runtime.AreaDB, DBNumber: 1, Start: 0, Length: 100}, {Area: runtime.AreaDB, DBNumber: 1, Start: 0, Length: 100}})
+	var pduErr *runtime.PduError
+	if !errors.As(err, &pduErr) {
+		t.Fatalf("got %v, want PduError", err)
+	}
+	// 错误类别不关闭连接
+	if !c.Available() {
+		t.Fatal("connection closed after pdu error")
+	}
+	if _, _, err = c.ReadItems([]*runtime.ReadItem{{Area: runtime.AreaDB, DBNumber: 1, Start: 0, Length: 2}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteItems(t *testing.T) {
+	p, address := startTestPlc(t)
+	c := dialTestPlc(t, address)
+	p.Write(runtime.AreaMerkers, 0, 0, []byte{0x01})
+	p.Write(runtime.AreaDB, 1, 40, []byte{0xFF})
+
+	items := []*runtime.WriteItem{
+		{Area: runtime.AreaDB, DBNumber: 1, Start: 20, Data: []byte{1, 2, 3}},
+		{Area: runtime.AreaMerkers, Start: 0, Bit: 3, BitWrite: true, Data: []byte{1}},
+		{Area: runtime.AreaDB, DBNumber: 1, Start: 30, Data: []byte{9}},
+		{Area: runtime.AreaDB, DBNumber: 3, Start: 0, Data: []byte{1, 2}},
+		{Area: runtime.AreaOutputs, Start: 4, Data: []byte{0x12, 0x34}},
+		{Area: runtime.AreaDB, DBNumber: 1, Start: 40, Bit: 0, BitWrite: true, Data: []byte{0}},
+		{Area: runtime.AreaDB, DBNumber: 1, Start: 99, Data: []byte{1, 2}},
+	}
+	errs, err := c.WriteItems(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantErrs := []error{nil, nil, nil, runtime.ReturnCodeObjectNotExist, nil, nil, runtime.ReturnCodeAddressOutOfRange}
+	for i := range wantErrs {
+		if errs[i] != wantErrs[i] {
+			t.Errorf("item %d: got %v, want %v", i, errs[i], wantErrs[i])
+		}
+	}
+
+	tests := []struct {
+		area     runtime.Area
+		dbNumber uint16
+		start    int
+		want     []byte
+	}{
+		{runtime.AreaDB, 1, 20, []byte{1, 2, 3}},
+		{runtime.AreaMerkers, 0, 0, []byte{0x09}},
+		{runtime.AreaDB, 1, 30, []byte{9}},
+		{runtime.AreaOutputs, 0, 4, []byte{0x12, 0x34}},
+		{runtime.AreaDB, 1, 40, []byte{0xFE}},
+		{runtime.AreaDB, 1, 99, []byte{0}},
+	}
+	for _, tt := range tests {
+		got, rc := p.Read(tt.area, tt.dbNumber, tt.start, len(tt.want))
+		if rc != runtime.ReturnCodeSuccess || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s%d[%d]: got %x, %v, want %x", runtime.AreaToString[tt.area], tt.dbNumber, tt.start, got, rc, tt.want)
+		}
+	}
+}
+
+func TestClientClosed(t *testing.T) {
+	_, address := startTestPlc(t)
+	c := dialTestPlc(t, address)
+	c.Close()
+	if c.Available() {
+		t.Fatal("closed client available")
+	}
+	if _, _, err := c.ReadItems([]*runtime.ReadItem{{Area: runtime.AreaDB, DBNumber: 1, Length: 2}}); err != runtime.ErrS7BadConn {
+		t.Fatalf("got %v, want %v", err, runtime.ErrS7BadConn)
+	}
+}
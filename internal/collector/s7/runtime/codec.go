@@ -0,0 +1,172 @@
+package runtime
+
+import (
+	"encoding/json"
+	"harnsplatform/internal/common"
+	"harnsplatform/internal/utils/binutils"
+	"math"
+	"strconv"
+)
+
+/**
+数值均为大端
+STRING: 最大长度(1) + 实际长度(1) + 字符
+*/
+
+// ParseValue 按变量的数据类型解析读取到的字节
+func ParseValue(variable *Variable, data []byte) interface{} {
+	switch variable.DataType {
+	case common.BOOL:
+		return data[0]>>variable.Location.Bit&0x01 == 0x01
+	case common.INT8:
+		return int8(data[0])
+	case common.UINT8:
+		return data[0]
+	case common.INT16:
+		return int16(binutils.ParseUint16BigEndian(data))
+	case common.UINT16:
+		return binutils.ParseUint16BigEndian(data)
+	case common.INT32:
+		return int32(binutils.ParseUint32BigEndian(data))
+	case common.UINT32:
+		return binutils.ParseUint32BigEndian(data)
+	case common.FLOAT32:
+		return binutils.ParseFloat32BigEndian(data)
+	case common.INT64:
+		return int64(binutils.ParseUint64BigEndian(data))
+	case common.UINT64:
+		return binutils.ParseUint64BigEndian(data)
+	case common.FLOAT64:
+		return binutils.ParseFloat64BigEndian(data)
+	case common.STRING:
+		length := uint(data[1])
+		if length > variable.Location.StringLength {
+			length = variable.Location.StringLength
+		}
+		return string(data[2 : 2+length])
+	}
+	return nil
+}
+
+// EncodeValue 按变量的数据类型编码下发值, 布尔为0或1
+func EncodeValue(variable *Variable, value interface{}) ([]byte, error) {
+	switch variable.DataType {
+	case common.BOOL:
+		switch v := value.(type) {
+		case bool:
+			return []byte{boolByte(v)}, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, ErrActionValueInvalid
+			}
+			return []byte{boolByte(b)}, nil
+		}
+		f, ok := toFloat64(value)
+		if !ok {
+			return nil, ErrActionValueInvalid
+		}
+		return []byte{boolByte(f != 0)}, nil
+	case common.STRING:
+		s, ok := value.(string)
+		if !ok || uint(len(s)) > variable.Location.StringLength {
+			return nil, ErrActionValueInvalid
+		}
+		return append([]byte{byte(variable.Location.StringLength), byte(len(s))}, s...), nil
+	}
+
+	f, ok := toFloat64(value)
+	if !ok {
+		return nil, ErrActionValueInvalid
+	}
+	integer := f == math.Trunc(f)
+	inRange := func(min, max float64) bool {
+		return integer && f >= min && f <= max
+	}
+	switch variable.DataType {
+	case common.INT8:
+		if inRange(math.MinInt8, math.MaxInt8) {
+			return []byte{byte(int8(f))}, nil
+		}
+	case common.UINT8:
+		if inRange(0, math.MaxUint8) {
+			return []byte{byte(f)}, nil
+		}
+	case common.INT16:
+		if inRange(math.MinInt16, math.MaxInt16) {
+			return binutils.Uint16ToBytesBigEndian(uint16(int16(f))), nil
+		}
+	case common.UINT16:
+		if inRange(0, math.MaxUint16) {
+			return binutils.Uint16ToBytesBigEndian(uint16(f)), nil
+		}
+	case common.INT32:
+		if inRange(math.MinInt32, math.MaxInt32) {
+			return binutils.Uint32ToBytesBigEndian(uint32(int32(f))), nil
+		}
+	case common.UINT32:
+		if inRange(0, math.MaxUint32) {
+			return binutils.Uint32ToBytesBigEndian(uint32(f)), nil
+		}
+	case common.INT64:
+		if inRange(math.MinInt64, math.MaxInt64) {
+			return binutils.Uint64ToBytesBigEndian(uint64(int64(f))), nil
+		}
+	case common.UINT64:
+		if inRange(0, math.MaxUint64) {
+			return binutils.Uint64ToBytesBigEndian(uint64(f)), nil
+		}
+	case common.FLOAT32:
+		if math.Abs(f) <= math.MaxFloat32 {
+			return binutils.Float32ToBytesBigEndian(float32(f)), nil
+		}
+	case common.FLOAT64:
+		return binutils.Float64ToBytesBigEndian(f), nil
+	default:
+		return nil, ErrDataTypeUnsupported
+	}
+	return nil, ErrActionValueInvalid
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 0x01
+	}
+	return 0x00
+}
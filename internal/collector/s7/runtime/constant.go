@@ -0,0 +1,180 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrS7BadConn = errors.New("bad s7 connection")
+var ErrS7Timeout = errors.New("s7 request timeout")
+var ErrTpktInvalid = errors.New("s7 tpkt frame invalid")
+var ErrCotpConnectRefused = errors.New("s7 cotp connection refused, check rack slot or tsap")
+var ErrCotpInvalid = errors.New("s7 cotp frame invalid")
+var ErrPduInvalid = errors.New("s7 pdu invalid")
+var ErrPduRefMismatch = errors.New("s7 pdu reference not match")
+var ErrPduSizeInvalid = errors.New("s7 negotiated pdu size invalid")
+var ErrItemCountMismatch = errors.New("s7 response item count not match")
+var ErrVariableNameEmpty = errors.New("s7 variable name empty")
+var ErrVariableNameDuplicate = errors.New("s7 variable name duplicate")
+var ErrVariableAddressInvalid = errors.New("s7 variable address invalid")
+var ErrDataTypeUnsupported = errors.New("s7 variable data type unsupported")
+var ErrDataTypeAddressMismatch = errors.New("s7 variable data type does not match address width")
+var ErrAccessModeInvalid = errors.New("s7 variable access mode invalid")
+var ErrAgentDetailsInvalid = errors.New("s7 agent details invalid")
+var ErrAgentAddressInvalid = errors.New("s7 agent address invalid")
+var ErrConnectionTypeInvalid = errors.New("s7 connection type invalid")
+var ErrTsapInvalid = errors.New("s7 tsap must be 4 hex digits, eg: 0102")
+var ErrRackSlotInvalid = errors.New("s7 rack must be between 0 and 7, slot between 0 and 31")
+var ErrPduSizeOutOfRange = errors.New("s7 pdu size must be between 240 and 960")
+var ErrOverrunPolicyInvalid = errors.New("s7 overrun policy invalid")
+var ErrVariableNotFound = errors.New("s7 variable not found")
+var ErrVariableReadOnly = errors.New("s7 variable read only")
+var ErrActionValueInvalid = errors.New("s7 action value invalid")
+
+// Area 存储区
+type Area byte
+
+const (
+	AreaInputs  Area = 0x81 // I 过程映像输入
+	AreaOutputs Area = 0x82 // Q 过程映像输出
+	AreaMerkers Area = 0x83 // M 位存储区
+	AreaDB      Area = 0x84 // DB 数据块
+)
+
+var AreaToString = map[Area]string{
+	AreaInputs:  "I",
+	AreaOutputs: "Q",
+	AreaMerkers: "M",
+	AreaDB:      "DB",
+}
+
+// StringToArea 兼容德文助记符 E(Eingang) A(Ausgang)
+var StringToArea = map[string]Area{
+	"I":  AreaInputs,
+	"E":  AreaInputs,
+	"Q":  AreaOutputs,
+	"A":  AreaOutputs,
+	"M":  AreaMerkers,
+	"DB": AreaDB,
+}
+
+// ConnectionType 连接资源类型, 为远端TSAP的高字节
+type ConnectionType byte
+
+const (
+	ConnectionTypePG    ConnectionType = 0x01
+	ConnectionTypeOP    ConnectionType = 0x02
+	ConnectionTypeBasic ConnectionType = 0x03
+)
+
+var ConnectionTypeToString = map[ConnectionType]string{
+	ConnectionTypePG:    "pg",
+	ConnectionTypeOP:    "op",
+	ConnectionTypeBasic: "basic",
+}
+
+var StringToConnectionType = map[string]ConnectionType{
+	"pg":    ConnectionTypePG,
+	"op":    ConnectionTypeOP,
+	"basic": ConnectionTypeBasic,
+}
+
+const (
+	DefaultPort = 102
+	// DefaultLocalTSAP 本地TSAP
+	DefaultLocalTSAP uint16 = 0x0100
+	// DefaultPduSize 建立通讯时请求的PDU长度, 实际长度由PLC协商, S7-300通常为240, S7-1200/1500为480或960
+	DefaultPduSize = 960
+	MinPduSize     = 240
+	// MaxItemsPerRequest 单个读写请求的最大变量数
+	MaxItemsPerRequest = 20
+	// MaxStringLength S7 STRING的最大字符数
+	MaxStringLength = 254
+)
+
+const (
+	// TpktHeaderLength 版本(1) + 保留(1) + 长度(2)
+	TpktHeaderLength = 4
+	// CotpDataHeaderLength 长度(1) + DT(1) + TPDU编号及结束标记(1)
+	CotpDataHeaderLength = 3
+	// JobHeaderLength 协议号(1) + ROSCTR(1) + 保留(2) + PDU编号(2) + 参数长度(2) + 数据长度(2)
+	JobHeaderLength = 10
+	// AckDataHeaderLength 在请求头之后增加 错误类别(1) + 错误码(1)
+	AckDataHeaderLength = 12
+	// RequestItemLength 读写请求参数中每个变量的描述
+	RequestItemLength = 12
+	// DataItemHeaderLength 返回码(1) + 传输类型(1) + 长度(2)
+	DataItemHeaderLength = 4
+	// ReadResponseOverhead 读响应头 + 功能码(1) + 变量数(1)
+	ReadResponseOverhead = AckDataHeaderLength + 2
+	// RequestOverhead 读写请求头 + 功能码(1) + 变量数(1)
+	RequestOverhead = JobHeaderLength + 2
+)
+
+const (
+	ProtocolId byte = 0x32
+
+	RosctrJob      byte = 0x01
+	RosctrAck      byte = 0x02
+	RosctrAckData  byte = 0x03
+	RosctrUserData byte = 0x07
+
+	FunctionSetupCommunication byte = 0xF0
+	FunctionReadVar            byte = 0x04
+	FunctionWriteVar           byte = 0x05
+
+	CotpConnectRequest byte = 0xE0
+	CotpConnectConfirm byte = 0xD0
+	CotpData           byte = 0xF0
+
+	// 请求中的传输类型
+	TransportSizeBit  byte = 0x01
+	TransportSizeByte byte = 0x02
+	// 数据中的传输类型, 0x03按位计长度, 0x04/0x05按位计长度的字节数据, 0x09按字节计长度
+	DataTransportSizeBit     byte = 0x03
+	DataTransportSizeByte    byte = 0x04
+	DataTransportSizeInteger byte = 0x05
+	DataTransportSizeOctet   byte = 0x09
+)
+
+// ReturnCode 读写变量的返回码
+type ReturnCode byte
+
+const (
+	ReturnCodeReserved             ReturnCode = 0x00
+	ReturnCodeHardwareFault        ReturnCode = 0x01
+	ReturnCodeAccessDenied         ReturnCode = 0x03
+	ReturnCodeAddressOutOfRange    ReturnCode = 0x05
+	ReturnCodeDataTypeUnsupported  ReturnCode = 0x06
+	ReturnCodeDataTypeInconsistent ReturnCode = 0x07
+	ReturnCodeObjectNotExist       ReturnCode = 0x0A
+	ReturnCodeSuccess              ReturnCode = 0xFF
+)
+
+var ReturnCodeToString = map[ReturnCode]string{
+	ReturnCodeReserved:             "reserved",
+	ReturnCodeHardwareFault:        "hardware fault",
+	ReturnCodeAccessDenied:         "access denied",
+	ReturnCodeAddressOutOfRange:    "address out of range",
+	ReturnCodeDataTypeUnsupported:  "data type not supported",
+	ReturnCodeDataTypeInconsistent: "data type inconsistent",
+	ReturnCodeObjectNotExist:       "object does not exist",
+	ReturnCodeSuccess:              "success",
+}
+
+func (rc ReturnCode) Error() string {
+	if s, ok := ReturnCodeToString[rc]; ok {
+		return fmt.Sprintf("s7 item error: %s", s)
+	}
+	return fmt.Sprintf("s7 item error: unknown return code 0x%02X", byte(rc))
+}
+
+// PduError ack data报文头中的错误类别与错误码
+type PduError struct {
+	Class byte
+	Code  byte
+}
+
+func (e *PduError) Error() string {
+	return fmt.Sprintf("s7 pdu error: class 0x%02X code 0x%02X", e.Class, e.Code)
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"encoding/json"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/common"
+)
+
+var _ collector.Device = (*S7Device)(nil)
+var _ collector.VariableValue = (*Variable)(nil)
+
+type Variable struct {
+	DataType     common.DataType   `json:"dataType"`               // bool、int8、uint8、int16、uint16、int32、uint32、float32、int64、uint64、float64、string
+	Name         string            `json:"name"`                   // 变量名称
+	Address      string            `json:"address"`                // 变量地址 例如 DB10.DBD4
+	Location     *Address          `json:"-"`                      // 解析后的地址
+	DefaultValue interface{}       `json:"defaultValue,omitempty"` // 默认值
+	Value        interface{}       `json:"value,omitempty"`        // 值
+	AccessMode   common.AccessMode `json:"accessMode"`             // 读写属性
+}
+
+// Size 变量占用的字节数, 字符串包含最大长度与实际长度两个字节
+func (v *Variable) Size() uint {
+	switch v.DataType {
+	case common.BOOL, common.INT8, common.UINT8:
+		return 1
+	case common.INT16, common.UINT16:
+		return 2
+	case common.INT32, common.UINT32, common.FLOAT32:
+		return 4
+	case common.INT64, common.UINT64, common.FLOAT64:
+		return 8
+	case common.STRING:
+		return v.Location.StringLength + 2
+	}
+	return 0
+}
+
+func (v *Variable) GetVariableAccessMode() common.AccessMode {
+	return v.AccessMode
+}
+
+func (v *Variable) SetValue(value interface{}) {
+	v.Value = value
+}
+
+func (v *Variable) GetValue() interface{} {
+	return v.Value
+}
+
+func (v *Variable) GetVariableName() string {
+	return v.Name
+}
+
+func (v *Variable) SetVariableName(name string) {
+	v.Name = name
+}
+
+type S7Device struct {
+	collector.DeviceMeta
+	CollectorCycle uint                    `json:"collectorCycle"`                    // 采集周期 毫秒
+	OverrunPolicy  collector.OverrunPolicy `json:"overrunPolicy"`                     // 采集超过周期时的处理策略
+	Host           string                  `json:"host"`                              // PLC地址
+	Port           int                     `json:"port"`                              // 端口号 默认102
+	Rack           uint                    `json:"rack"`                              // 机架号
+	Slot           uint                    `json:"slot"`                              // 槽号
+	LocalTSAP      uint16                  `json:"localTsap"`                         // 本地TSAP
+	RemoteTSAP     uint16                  `json:"remoteTsap"`                        // 远端TSAP, 未配置时由连接类型与机架槽号计算
+	PduSize        uint                    `json:"pduSize"`                           // 请求的PDU长度
+	MaxGap         uint                    `json:"maxGap"`                            // 合并读取时允许跨越的最大未映射字节数
+	Timeout        uint                    `json:"timeout"`                           // 请求超时 毫秒
+	Variables      []*Variable             `json:"variables" binding:"required,dive"` // 自定义变量
+	VariablesMap   map[string]*Variable    `json:"-"`                                 // 自定义变量Map
+}
+
+func (m *S7Device) IndexDevice() {
+	m.VariablesMap = make(map[string]*Variable)
+	for _, variable := range m.Variables {
+		m.VariablesMap[variable.Name] = variable
+	}
+}
+
+func (m *S7Device) GetVariable(key string) (rv collector.VariableValue, exist bool) {
+	if v, isExist := m.VariablesMap[key]; isExist {
+		rv = v
+		exist = isExist
+	}
+	return
+}
+
+func (m *S7Device) GetVariables() []collector.VariableValue {
+	rvs := make([]collector.VariableValue, 0)
+
+	for _, variable := range m.Variables {
+		rvs = append(rvs, variable)
+	}
+
+	return rvs
+}
+
+func (a Area) MarshalJSON() ([]byte, error) {
+	return json.Marshal(AreaToString[a])
+}
+
+func (w Width) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(w))
+}
+
+// ReadItem 读请求中的一个变量, 对应一段连续的字节
+type ReadItem struct {
+	Area     Area   `json:"area"`
+	DBNumber uint16 `json:"dbNumber,omitempty"`
+	Start    uint   `json:"start"`
+	Length   uint   `json:"length"`
+}
+
+type VariableSlice []*Variable
+
+func (vs VariableSlice) Len() int {
+	return len(vs)
+}
+
+func (vs VariableSlice) Less(i, j int) bool {
+	return vs[i].Location.Start < vs[j].Location.Start
+}
+
+func (vs VariableSlice) Swap(i, j int) {
+	vs[i], vs[j] = vs[j], vs[i]
+}
+
+type VariableParse struct {
+	Variable *Variable
+	Start    uint // 读取块中数据[]byte开始位置
+}
+
+// WriteItem 写请求中的一个变量, 位写入时Bit有效且Data为一个字节
+type WriteItem struct {
+	Area     Area
+	DBNumber uint16
+	Start    uint
+	Bit      uint8
+	BitWrite bool
+	Data     []byte
+}
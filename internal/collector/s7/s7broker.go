@@ -0,0 +1,220 @@
+package s7
+
+import (
+	"context"
+	"fmt"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/s7/runtime"
+	"k8s.io/klog/v2"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+s7 采集
+按协商后的PDU长度规划读请求, 每个采集周期顺序执行全部读请求, 读取块拼接完整后解析其中的变量
+读取块中任一读取项失败时, 块内变量均返回错误, 不影响其他块
+通讯错误后关闭连接, 下一个采集周期重新建立连接, 协商的PDU长度变化时重新规划
+*/
+
+var _ collector.Broker = (*S7Broker)(nil)
+
+type S7Broker struct {
+	Device     *runtime.S7Device
+	Scheduler  *collector.Scheduler
+	ExitCh     chan struct{}
+	VariableCh chan *collector.ParseVariableResult
+
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	client *runtime.Client
+	plan   *ReadPlan
+}
+
+func NewBroker(d collector.Device) (collector.Broker, chan *collector.ParseVariableResult, error) {
+	device, ok := d.(*runtime.S7Device)
+	if !ok {
+		klog.V(2).InfoS("Unsupported device,type not S7")
+		return nil, nil, collector.ErrDeviceType
+	}
+	if len(device.Variables) == 0 {
+		klog.V(2).InfoS("Unnecessary to collect from S7 device.Because of the variables is empty", "deviceId", device.ID)
+		return nil, nil, collector.ErrDeviceEmptyVariable
+	}
+
+	broker := &S7Broker{
+		Device:     device,
+		Scheduler:  collector.NewScheduler(device.CollectorCycle, device.OverrunPolicy),
+		ExitCh:     make(chan struct{}, 0),
+		VariableCh: make(chan *collector.ParseVariableResult, 1),
+	}
+	if _, err := broker.connect(context.Background()); err != nil {
+		klog.V(2).InfoS("Failed to connect S7 plc", "error", err, "deviceId", device.ID, "host", device.Host)
+		return nil, nil, collector.ErrConnectDevice
+	}
+	return broker, broker.VariableCh, nil
+}
+
+// connect 建立连接, 协商的PDU长度与当前规划不同时重新规划
+func (broker *S7Broker) connect(ctx context.Context) (*runtime.Client, error) {
+	device := broker.Device
+	timeout := time.Duration(device.Timeout) * time.Millisecond
+	address := net.JoinHostPort(device.Host, strconv.Itoa(device.Port))
+	client, err := runtime.Dial(ctx, address, device.LocalTSAP, device.RemoteTSAP, device.PduSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	broker.client = client
+	if broker.plan == nil || broker.plan.PduSize != client.PduSize() {
+		broker.plan = PlanReads(device, client.PduSize())
+		klog.V(4).InfoS("Planned s7 read requests", "deviceId", device.ID, "pduSize", client.PduSize(),
+			"blocks", len(broker.plan.Blocks), "requests", len(broker.plan.Requests))
+	}
+	return client, nil
+}
+
+// available 当前可用的连接, 连接已关闭时重新建立
+func (broker *S7Broker) available(ctx context.Context) (*runtime.Client, *ReadPlan, error) {
+	broker.mu.Lock()
+	client, plan := broker.client, broker.plan
+	broker.mu.Unlock()
+	if client != nil && client.Available() {
+		return client, plan, nil
+	}
+	client, err := broker.connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	return client, broker.plan, nil
+}
+
+func (broker *S7Broker) Collect(ctx context.Context) {
+	broker.wg.Add(1)
+	go func() {
+		defer broker.wg.Done()
+		broker.Scheduler.Run(ctx, broker.ExitCh, func() bool {
+			return broker.poll(ctx)
+		})
+	}()
+}
+
+func (broker *S7Broker) Destroy(ctx context.Context) {
+	close(broker.ExitCh)
+	broker.wg.Wait()
+	broker.mu.Lock()
+	if broker.client != nil {
+		broker.client.Close()
+		broker.client = nil
+	}
+	broker.mu.Unlock()
+	close(broker.VariableCh)
+}
+
+func (broker *S7Broker) exited() bool {
+	select {
+	case <-broker.ExitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// send 退出后丢弃结果
+func (broker *S7Broker) send(pvr *collector.ParseVariableResult) {
+	select {
+	case broker.VariableCh <- pvr:
+	case <-broker.ExitCh:
+	}
+}
+
+// poll 执行一个采集周期的全部读请求
+func (broker *S7Broker) poll(ctx context.Context) bool {
+	if broker.exited() {
+		return false
+	}
+	client, plan, err := broker.available(ctx)
+	if err != nil {
+		klog.V(2).InfoS("Failed to reconnect S7 plc", "error", err, "deviceId", broker.Device.ID)
+		broker.send(&collector.ParseVariableResult{Err: []error{err}})
+		return true
+	}
+
+	buffers := make([][]byte, len(plan.Blocks))
+	blockErrs := make([]error, len(plan.Blocks))
+	for i, block := range plan.Blocks {
+		buffers[i] = make([]byte, block.Length)
+	}
+	for _, request := range plan.Requests {
+		items := make([]*runtime.ReadItem, 0, len(request.Items))
+		for _, segment := range request.Items {
+			items = append(items, &segment.ReadItem)
+		}
+		values, errs, err := client.ReadItems(items)
+		for i, segment := range request.Items {
+			switch {
+			case err != nil:
+				blockErrs[segment.Block] = err
+			case errs[i] != nil:
+				blockErrs[segment.Block] = errs[i]
+			default:
+				copy(buffers[segment.Block][segment.Offset:], values[i])
+			}
+		}
+		if err != nil {
+			klog.V(2).InfoS("Failed to read S7 plc", "error", err, "deviceId", broker.Device.ID)
+			// 连接已关闭, 剩余读请求的块同样失败
+			if !client.Available() {
+				for i := range blockErrs {
+					if blockErrs[i] == nil {
+						blockErrs[i] = err
+					}
+				}
+				break
+			}
+		}
+	}
+
+	rvs := make([]collector.VariableValue, 0, len(broker.Device.Variables))
+	errs := make([]error, 0)
+	for i, block := range plan.Blocks {
+		if blockErrs[i] != nil {
+			errs = append(errs, &BlockError{ReadItem: block.ReadItem, Variables: block.VariableNames, Err: blockErrs[i]})
+			continue
+		}
+		for _, vp := range block.Variables {
+			variable := *vp.Variable
+			variable.Value = runtime.ParseValue(vp.Variable, buffers[i][vp.Start:vp.Start+vp.Variable.Size()])
+			rvs = append(rvs, &variable)
+		}
+	}
+	if len(errs) == 0 {
+		errs = nil
+	}
+	broker.send(&collector.ParseVariableResult{VariableSlice: rvs, Err: errs})
+	return true
+}
+
+// BlockError 读取块失败, 块内全部变量没有值
+type BlockError struct {
+	runtime.ReadItem
+	Variables []string
+	Err       error
+}
+
+func (e *BlockError) Error() string {
+	area := runtime.AreaToString[e.Area]
+	if e.Area == runtime.AreaDB {
+		area = fmt.Sprintf("DB%d", e.DBNumber)
+	}
+	return fmt.Sprintf("read %s[%d,%d) variables %v: %v", area, e.Start, e.Start+e.Length, e.Variables, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,252 @@
+package s7
+
+import (
+	"context"
+	"errors"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/s7/plc"
+	"harnsplatform/internal/collector/s7/runtime"
+	"harnsplatform/internal/utils/binutils"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTestPlc 机架0槽号1, 协商的PDU长度为240, 长字符串需要拆分读取
+func startTestPlc(t *testing.T) (*plc.PLC, int) {
+	t.Helper()
+	p := plc.NewPLC(8, 8, 16, map[uint16]int{1: 300})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go plc.NewServer(p, 240, 0, 1).Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return p, l.Addr().(*net.TCPAddr).Port
+}
+
+func newTestMappings() []*biz.Mapping {
+	return []*biz.Mapping{
+		{Name: "running", Variable: "DB1.DBX0.0", DataType: "bool", AccessMode: "rw"},
+		{Name: "alarm", Variable: "DB1.DBX0.3", DataType: "bool", AccessMode: "rw"},
+		{Name: "speed", Variable: "DB1.DBD2", DataType: "float32", AccessMode: "rw"},
+		{Name: "count", Variable: "DB1.DBW6", DataType: "int16", AccessMode: "rw"},
+		{Name: "total", Variable: "DB1.DBB8", DataType: "float64", AccessMode: "rw"},
+		{Name: "name", Variable: "DB1.STRING20.254", DataType: "string", AccessMode: "rw"},
+		{Name: "flag", Variable: "M0.1", DataType: "bool", AccessMode: "rw"},
+		{Name: "level", Variable: "MW2", DataType: "uint16"},
+		{Name: "input", Variable: "IB1", DataType: "uint8"},
+		{Name: "output", Variable: "QD0", DataType: "uint32", AccessMode: "rw"},
+	}
+}
+
+func newTestBroker(t *testing.T, port int, mappings []*biz.Mapping) *S7Broker {
+	t.Helper()
+	agents := &biz.Agents{
+		Name:           "plc",
+		AgentType:      "s7",
+		CollectorCycle: 20,
+		AgentDetails:   biz.JSONMap{"rack": 0, "slot": 1, "timeout": 1000},
+		Address:        biz.JSONMap{"host": "127.0.0.1", "port": port},
+	}
+	device, err := ConvertDevice(agents, mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.IndexDevice()
+	broker, _, err := NewBroker(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return broker.(*S7Broker)
+}
+
+// waitValues 接收采集结果直到done返回true
+func waitValues(t *testing.T, ch chan *collector.ParseVariableResult, done func(values map[string]interface{}, errs []error) bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case pvr := <-ch:
+			values := make(map[string]interface{})
+			for _, v := range pvr.VariableSlice {
+				values[v.GetVariableName()] = v.GetValue()
+			}
+			if done(values, pvr.Err) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for values")
+		}
+	}
+}
+
+func TestBrokerCollect(t *testing.T) {
+	p, port := startTestPlc(t)
+	p.Write(runtime.AreaDB, 1, 0, []byte{0x09})
+	p.Write(runtime.AreaDB, 1, 2, binutils.Float32ToBytesBigEndian(12.5))
+	p.Write(runtime.AreaDB, 1, 6, binutils.Uint16ToBytesBigEndian(uint16(0xFFFE)))
+	p.Write(runtime.AreaDB, 1, 8, binutils.Float64ToBytesBigEndian(-1.25))
+	name := strings.Repeat("x", 230) + "END"
+	p.Write(runtime.AreaDB, 1, 20, append([]byte{254, byte(len(name))}, name...))
+	p.Write(runtime.AreaMerkers, 0, 0, []byte{0x02, 0x00, 0x01, 0x02})
+	p.Write(runtime.AreaInputs, 0, 1, []byte{0x7F})
+	p.Write(runtime.AreaOutputs, 0, 0, binutils.Uint32ToBytesBigEndian(70000))
+
+	mappings := append(newTestMappings(), &biz.Mapping{Name: "missing", Variable: "DB9.DBW0", DataType: "int16"})
+	broker := newTestBroker(t, port, mappings)
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+
+	want := map[string]interface{}{
+		"running": true,
+		"alarm":   true,
+		"speed":   float32(12.5),
+		"count":   int16(-2),
+		"total":   -1.25,
+		"name":    name,
+		"flag":    true,
+		"level":   uint16(0x0102),
+		"input":   uint8(0x7F),
+		"output":  uint32(70000),
+	}
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		for key, value := range want {
+			if values[key] != value {
+				t.Errorf("%s: got %v, want %v", key, values[key], value)
+			}
+		}
+		// 不存在的DB只影响所在的读取块
+		var blockErr *BlockError
+		if len(errs) != 1 || !errors.As(errs[0], &blockErr) || !errors.Is(errs[0], runtime.ReturnCodeObjectNotExist) ||
+			len(blockErr.Variables) != 1 || blockErr.Variables[0] != "missing" {
+			t.Errorf("got errors %v", errs)
+		}
+		return true
+	})
+	if plan := broker.plan; plan.PduSize != 240 || len(plan.Requests) < 2 {
+		t.Fatalf("plan pdu %d, %d requests", plan.PduSize, len(plan.Requests))
+	}
+
+	p.Write(runtime.AreaDB, 1, 6, binutils.Uint16ToBytesBigEndian(300))
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return values["count"] == int16(300)
+	})
+}
+
+func TestBrokerDeliverAction(t *testing.T) {
+	p, port := startTestPlc(t)
+	p.Write(runtime.AreaDB, 1, 0, []byte{0xF0})
+	broker := newTestBroker(t, port, newTestMappings())
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+
+	name := strings.Repeat("y", 200)
+	results, err := broker.DeliverAction(context.Background(), map[string]interface{}{
+		"running": true,
+		"alarm":   "false",
+		"speed":   3.5,
+		"count":   -100,
+		"total":   "2.5",
+		"name":    name,
+		"flag":    1,
+		"output":  4000000000,
+		"level":   1,
+		"unknown": 1,
+	})
+	var multiErr *collector.MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Fatalf("got %v", err)
+	}
+	for _, result := range results {
+		var want error
+		switch result.Name {
+		case "level":
+			want = runtime.ErrVariableReadOnly
+		case "unknown":
+			want = runtime.ErrVariableNotFound
+		}
+		if result.Err != want {
+			t.Errorf("%s: got %v, want %v", result.Name, result.Err, want)
+		}
+	}
+
+	// 位写入不影响同一字节的其他位
+	memory := []struct {
+		area  runtime.Area
+		db    uint16
+		start int
+		want  []byte
+	}{
+		{runtime.AreaDB, 1, 0, []byte{0xF1}},
+		{runtime.AreaDB, 1, 2, binutils.Float32ToBytesBigEndian(3.5)},
+		{runtime.AreaDB, 1, 6, binutils.Uint16ToBytesBigEndian(uint16(0xFF9C))},
+		{runtime.AreaDB, 1, 8, binutils.Float64ToBytesBigEndian(2.5)},
+		{runtime.AreaDB, 1, 20, append([]byte{254, 200}, name...)},
+		{runtime.AreaMerkers, 0, 0, []byte{0x02}},
+		{runtime.AreaOutputs, 0, 0, binutils.Uint32ToBytesBigEndian(4000000000)},
+	}
+	for _, m := range memory {
+		got, _ := p.Read(m.area, m.db, m.start, len(m.want))
+		if string(got) != string(m.want) {
+			t.Errorf("%s%d[%d]: got %x, want %x", runtime.AreaToString[m.area], m.db, m.start, got, m.want)
+		}
+	}
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return values["count"] == int16(-100) && values["name"] == name && values["running"] == true && values["alarm"] == false
+	})
+
+	// 超出数据类型范围或过长的值不下发
+	results, err = broker.DeliverAction(context.Background(), map[string]interface{}{
+		"count": 40000,
+		"name":  strings.Repeat("z", 255),
+		"flag":  "on",
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, result := range results {
+		if !errors.Is(result.Err, runtime.ErrActionValueInvalid) || result.Status != collector.ActionFailed {
+			t.Errorf("%s: got %v, status %s", result.Name, result.Err, result.Status)
+		}
+	}
+	if got, _ := p.Read(runtime.AreaDB, 1, 6, 2); binutils.ParseUint16BigEndian(got) != 0xFF9C {
+		t.Errorf("count changed to %x", got)
+	}
+}
+
+func TestBrokerReconnect(t *testing.T) {
+	p := plc.NewPLC(8, 8, 16, map[uint16]int{1: 300})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := plc.NewServer(p, 240, 0, 1)
+	go server.Serve(l)
+	port := l.Addr().(*net.TCPAddr).Port
+	broker := newTestBroker(t, port, []*biz.Mapping{{Name: "count", Variable: "DB1.DBW6", DataType: "int16"}})
+
+	// 关闭监听与已建立的连接, 采集返回通讯错误
+	l.Close()
+	broker.mu.Lock()
+	broker.client.Close()
+	broker.mu.Unlock()
+	broker.Collect(context.Background())
+	defer broker.Destroy(context.Background())
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return len(errs) > 0 && len(values) == 0
+	})
+
+	// 恢复监听后重新建立连接
+	if l, err = net.Listen("tcp", l.Addr().String()); err != nil {
+		t.Skipf("listen again: %v", err)
+	}
+	defer l.Close()
+	go server.Serve(l)
+	p.Write(runtime.AreaDB, 1, 6, binutils.Uint16ToBytesBigEndian(5))
+	waitValues(t, broker.VariableCh, func(values map[string]interface{}, errs []error) bool {
+		return values["count"] == int16(5)
+	})
+}
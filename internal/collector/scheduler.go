@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"k8s.io/klog/v2"
+	"sync/atomic"
+	"time"
+)
+
+/**
+周期调度
+以首次执行时间为基准, 第k次执行的计划时间为 start + k × period, 不受单次执行耗时影响
+单次执行超过周期时按超时策略处理:
+skip    丢弃错过的周期, 在下一个周期边界执行
+catchUp 立即补执行错过的周期, 积压超过MaxCatchUp个周期时丢弃多余部分
+*/
+
+type OverrunPolicy byte
+
+const (
+	OverrunSkip OverrunPolicy = iota
+	OverrunCatchUp
+)
+
+var OverrunPolicyToString = map[OverrunPolicy]string{
+	OverrunSkip:    "skip",
+	OverrunCatchUp: "catchUp",
+}
+
+var StringToOverrunPolicy = map[string]OverrunPolicy{
+	"skip":    OverrunSkip,
+	"catchUp": OverrunCatchUp,
+}
+
+const (
+	// DefaultCollectorCycle 未配置采集周期时的默认值 毫秒
+	DefaultCollectorCycle = 1000
+	// MaxCatchUp catchUp策略下最多补执行的周期数
+	MaxCatchUp = 10
+)
+
+type Scheduler struct {
+	Period   time.Duration
+	Policy   OverrunPolicy
+	overruns uint64
+	skipped  uint64
+}
+
+// NewScheduler 按毫秒周期创建调度器, 周期为0时使用默认周期
+func NewScheduler(cycle uint, policy OverrunPolicy) *Scheduler {
+	if cycle == 0 {
+		cycle = DefaultCollectorCycle
+	}
+	return &Scheduler{
+		Period: time.Duration(cycle) * time.Millisecond,
+		Policy: policy,
+	}
+}
+
+// Overruns 执行耗时超过周期的次数
+func (s *Scheduler) Overruns() uint64 {
+	return atomic.LoadUint64(&s.overruns)
+}
+
+// Skipped 被丢弃的周期数
+func (s *Scheduler) Skipped() uint64 {
+	return atomic.LoadUint64(&s.skipped)
+}
+
+// Run 按周期执行task直到task返回false、exitCh收到信号或ctx结束
+func (s *Scheduler) Run(ctx context.Context, exitCh <-chan struct{}, task func() bool) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-exitCh:
+			return
+		case <-timer.C:
+		}
+
+		if !task() {
+			return
+		}
+
+		next = next.Add(s.Period)
+		now := time.Now()
+		if now.Before(next) {
+			timer.Reset(next.Sub(now))
+			continue
+		}
+
+		// 执行超时
+		atomic.AddUint64(&s.overruns, 1)
+		missed := uint64(now.Sub(next)/s.Period) + 1
+		switch s.Policy {
+		case OverrunCatchUp:
+			if missed > MaxCatchUp {
+				drop := missed - MaxCatchUp
+				next = next.Add(time.Duration(drop) * s.Period)
+				atomic.AddUint64(&s.skipped, drop)
+			}
+			timer.Reset(0)
+		default:
+			next = next.Add(time.Duration(missed) * s.Period)
+			atomic.AddUint64(&s.skipped, missed)
+			timer.Reset(next.Sub(now))
+		}
+		klog.V(4).InfoS("Collector cycle overrun", "period", s.Period, "missed", missed, "policy", OverrunPolicyToString[s.Policy])
+	}
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// runScheduler 执行n次task后返回每次执行相对首次执行的时间
+func runScheduler(t *testing.T, s *Scheduler, n int, task func(i int)) []time.Duration {
+	t.Helper()
+	times := make([]time.Duration, 0, n)
+	var start time.Time
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(context.Background(), nil, func() bool {
+			if len(times) == 0 {
+				start = time.Now()
+			}
+			times = append(times, time.Since(start))
+			task(len(times) - 1)
+			return len(times) < n
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+	return times
+}
+
+func TestNewScheduler(t *testing.T) {
+	if s := NewScheduler(0, OverrunSkip); s.Period != DefaultCollectorCycle*time.Millisecond {
+		t.Fatalf("got period %v", s.Period)
+	}
+	if s := NewScheduler(250, OverrunCatchUp); s.Period != 250*time.Millisecond || s.Policy != OverrunCatchUp {
+		t.Fatalf("got %+v", s)
+	}
+}
+
+// 计划时间以首次执行为基准, 执行耗时不累积
+func TestSchedulerNoDrift(t *testing.T) {
+	s := NewScheduler(20, OverrunSkip)
+	times := runScheduler(t, s, 10, func(int) { time.Sleep(10 * time.Millisecond) })
+	// 每次执行耗时累积时为270ms
+	if last := times[len(times)-1]; last < 180*time.Millisecond || last >= 240*time.Millisecond {
+		t.Fatalf("10th run at %v, want 180ms", last)
+	}
+	if s.Overruns() != 0 || s.Skipped() != 0 {
+		t.Fatalf("overruns %d skipped %d", s.Overruns(), s.Skipped())
+	}
+}
+
+// skip 丢弃错过的周期, 在下一个周期边界执行
+func TestSchedulerSkip(t *testing.T) {
+	s := NewScheduler(40, OverrunSkip)
+	times := runScheduler(t, s, 3, func(i int) {
+		if i == 0 {
+			time.Sleep(140 * time.Millisecond)
+		}
+	})
+	// 第一次执行在140ms结束, 错过40ms 80ms 120ms三个周期
+	if times[1] < 160*time.Millisecond || times[1] >= 195*time.Millisecond {
+		t.Fatalf("2nd run at %v, want 160ms", times[1])
+	}
+	if d := times[2] - times[1]; d < 25*time.Millisecond || d >= 55*time.Millisecond {
+		t.Fatalf("3rd run %v after 2nd, want 40ms", d)
+	}
+	if s.Overruns() != 1 || s.Skipped() != 3 {
+		t.Fatalf("overruns %d skipped %d, want 1 3", s.Overruns(), s.Skipped())
+	}
+}
+
+// catchUp 立即补执行错过的周期
+func TestSchedulerCatchUp(t *testing.T) {
+	s := NewScheduler(40, OverrunCatchUp)
+	times := runScheduler(t, s, 5, func(i int) {
+		if i == 0 {
+			time.Sleep(140 * time.Millisecond)
+		}
+	})
+	// 40ms 80ms 120ms三个周期在140ms立即补执行, 之后回到160ms的周期边界
+	for i := 1; i <= 3; i++ {
+		if times[i] >= 155*time.Millisecond {
+			t.Fatalf("catch-up run %d at %v", i, times[i])
+		}
+	}
+	if times[4] < 160*time.Millisecond || times[4] >= 195*time.Millisecond {
+		t.Fatalf("5th run at %v, want 160ms", times[4])
+	}
+	if s.Overruns() != 3 || s.Skipped() != 0 {
+		t.Fatalf("overruns %d skipped %d, want 3 0", s.Overruns(), s.Skipped())
+	}
+}
+
+// catchUp 积压超过MaxCatchUp个周期时丢弃多余部分
+func TestSchedulerCatchUpLimit(t *testing.T) {
+	s := NewScheduler(10, OverrunCatchUp)
+	times := runScheduler(t, s, MaxCatchUp+3, func(i int) {
+		if i == 0 {
+			time.Sleep(250 * time.Millisecond)
+		}
+	})
+	// 错过约25个周期, 补执行MaxCatchUp个
+	if skipped := s.Skipped(); skipped < 14 || skipped > 17 {
+		t.Fatalf("skipped %d, want about 15", skipped)
+	}
+	for i := 1; i <= MaxCatchUp; i++ {
+		if d := times[i] - times[1]; d >= 8*time.Millisecond {
+			t.Fatalf("catch-up run %d %v after the first", i, d)
+		}
+	}
+	if d := times[MaxCatchUp+2] - times[1]; d < 5*time.Millisecond {
+		t.Fatalf("ran %d times without waiting", MaxCatchUp+2)
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(cancel context.CancelFunc, exitCh chan struct{})
+	}{
+		{"context", func(cancel context.CancelFunc, _ chan struct{}) { cancel() }},
+		{"exit channel", func(_ context.CancelFunc, exitCh chan struct{}) { close(exitCh) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			exitCh := make(chan struct{})
+			ran := make(chan struct{}, 1)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				NewScheduler(1000, OverrunSkip).Run(ctx, exitCh, func() bool {
+					ran <- struct{}{}
+					return true
+				})
+			}()
+			<-ran
+			tt.stop(cancel, exitCh)
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("scheduler did not stop")
+			}
+		})
+	}
+}
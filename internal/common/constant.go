@@ -12,3 +12,15 @@ const VERSION = "version"
 
 // MODBUS protocol
 const MODBUS = "modbus"
+
+// OPCUA protocol
+const OPCUA = "opcUa"
+
+// S7 protocol
+const S7 = "s7"
+
+// MQTT protocol
+const MQTT = "mqtt"
+
+// HTTP protocol
+const HTTP = "http"
@@ -17,6 +17,13 @@ const (
 	UINT16
 	NUMBER
 	STRING
+	UINT32
+	UINT64
+	INT8
+	UINT8
+	BCD16 // 4位BCD码
+	BCD32 // 8位BCD码
+	BCD64 // 16位BCD码
 )
 
 var DataTypeToString = map[DataType]string{
@@ -29,6 +36,13 @@ var DataTypeToString = map[DataType]string{
 	UINT16:  "uint16",
 	NUMBER:  "number",
 	STRING:  "string",
+	UINT32:  "uint32",
+	UINT64:  "uint64",
+	INT8:    "int8",
+	UINT8:   "uint8",
+	BCD16:   "bcd16",
+	BCD32:   "bcd32",
+	BCD64:   "bcd64",
 }
 
 var StringToDataType = map[string]DataType{
@@ -41,6 +55,13 @@ var StringToDataType = map[string]DataType{
 	"uint16":  UINT16,
 	"number":  NUMBER,
 	"string":  STRING,
+	"uint32":  UINT32,
+	"uint64":  UINT64,
+	"int8":    INT8,
+	"uint8":   UINT8,
+	"bcd16":   BCD16,
+	"bcd32":   BCD32,
+	"bcd64":   BCD64,
 }
 
 var DataTypeWord = map[DataType]uint{
@@ -52,7 +73,14 @@ var DataTypeWord = map[DataType]uint{
 	INT64:   4,
 	UINT16:  1,
 	NUMBER:  1,
-	STRING:  1,
+	STRING:  1, // 字符串实际长度由变量数量决定
+	UINT32:  2,
+	UINT64:  4,
+	INT8:    1,
+	UINT8:   1,
+	BCD16:   1,
+	BCD32:   2,
+	BCD64:   4,
 }
 
 func (dt DataType) MarshalJSON() ([]byte, error) {
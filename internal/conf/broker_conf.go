@@ -1,5 +1,9 @@
 package conf
 
+import (
+	"time"
+)
+
 type BrokerBootstrap struct {
 	Config *BrokerConfig   `mapstructure:"config,omitempty"`
 	Server *Server         `mapstructure:"server,omitempty"`
@@ -33,6 +37,56 @@ func (x *InfluxDb) GetToken() string {
 type BrokerConfig struct {
 	TimeSeriesStore TimeSeriesStorePeriod `mapstructure:"timeSeriesStore,omitempty"`
 	Sink            Sink                  `mapstructure:"sink,omitempty"`
+	Gateway         *ModbusGateway        `mapstructure:"gateway,omitempty"`
+	ModelManager    *ModelManagerClient   `mapstructure:"modelManager,omitempty"`
+}
+
+// ModelManagerClient 模型管理服务地址, 采集管理从该服务获取agents与物模型
+type ModelManagerClient struct {
+	Endpoint string        `mapstructure:"endpoint,omitempty"` // 例如 127.0.0.1:8000
+	Timeout  time.Duration `mapstructure:"timeout,omitempty"`
+}
+
+func (x *ModelManagerClient) GetEndpoint() string {
+	if x != nil {
+		return x.Endpoint
+	}
+	return ""
+}
+
+func (x *ModelManagerClient) GetTimeout() time.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return 0
+}
+
+// ModbusGateway 北向modbus tcp网关, 将采集值映射到虚拟寄存器表
+type ModbusGateway struct {
+	Flag   bool            `mapstructure:"flag,omitempty"`
+	Addr   string          `mapstructure:"addr,omitempty"`  // 监听地址 例如 0.0.0.0:502
+	Slave  uint8           `mapstructure:"slave,omitempty"` // 下位机号
+	Points []*GatewayPoint `mapstructure:"points,omitempty"`
+}
+
+// GatewayPoint 虚拟寄存器表中的一个点位, 由设备变量或物模型属性提供数据
+type GatewayPoint struct {
+	DeviceId     string `mapstructure:"deviceId,omitempty"`
+	Variable     string `mapstructure:"variable,omitempty"`
+	ThingId      string `mapstructure:"thingId,omitempty"`
+	Property     string `mapstructure:"property,omitempty"`
+	Table        string `mapstructure:"table"`                  // coil discreteInput holdingRegister inputRegister
+	Address      int    `mapstructure:"address"`                // 从0开始的地址
+	DataType     string `mapstructure:"dataType,omitempty"`     // 寄存器的数据类型, 默认float32, 可写的bool变量需配置为bool
+	MemoryLayout string `mapstructure:"memoryLayout,omitempty"` // 默认ABCD
+	Writable     bool   `mapstructure:"writable,omitempty"`     // 写入时转发到源设备, 仅coil与holdingRegister
+}
+
+func (x *ModbusGateway) GetFlag() bool {
+	if x != nil {
+		return x.Flag
+	}
+	return false
 }
 
 type TimeSeriesStorePeriod struct {
@@ -13,17 +13,27 @@ const (
 	ErrorReason_RESOURCE_MISMATCH   ErrorReason = 2
 	ErrorReason_RESOURCE_NOT_FOUND  ErrorReason = 4
 	ErrorReason_AGENTS_UNSUPPORTED  ErrorReason = 5
+	ErrorReason_AGENTS_INVALID      ErrorReason = 6
+	ErrorReason_MAPPINGS_INVALID    ErrorReason = 7
+	ErrorReason_DISCOVERY_FAILED    ErrorReason = 8
+	ErrorReason_DEVICE_UNAVAILABLE  ErrorReason = 9
+	ErrorReason_DIAGNOSTICS_FAILED  ErrorReason = 10
 )
 
 // Enum value maps for ErrorReason.
 var (
 	ErrorReasonName = map[int32]string{
-		0: "GREETER_UNSPECIFIED",
-		1: "USER_NOT_FOUND",
-		2: "RESOURCE_MISMATCH",
-		3: "RESOURCE_PRECONDITION_REQUIRED",
-		4: "RESOURCE_NOT_FOUND",
-		5: "AGENTS_UNSUPPORTED",
+		0:  "GREETER_UNSPECIFIED",
+		1:  "USER_NOT_FOUND",
+		2:  "RESOURCE_MISMATCH",
+		3:  "RESOURCE_PRECONDITION_REQUIRED",
+		4:  "RESOURCE_NOT_FOUND",
+		5:  "AGENTS_UNSUPPORTED",
+		6:  "AGENTS_INVALID",
+		7:  "MAPPINGS_INVALID",
+		8:  "DISCOVERY_FAILED",
+		9:  "DEVICE_UNAVAILABLE",
+		10: "DIAGNOSTICS_FAILED",
 	}
 	ErrorReasonValue = map[string]int32{
 		"GREETER_UNSPECIFIED":            0,
@@ -32,6 +42,11 @@ var (
 		"RESOURCE_PRECONDITION_REQUIRED": 3,
 		"RESOURCE_NOT_FOUND":             4,
 		"AGENTS_UNSUPPORTED":             5,
+		"AGENTS_INVALID":                 6,
+		"MAPPINGS_INVALID":               7,
+		"DISCOVERY_FAILED":               8,
+		"DEVICE_UNAVAILABLE":             9,
+		"DIAGNOSTICS_FAILED":             10,
 	}
 )
 
@@ -60,3 +75,23 @@ func GenerateResourceNotFoundError(resourceName string) error {
 func GenerateAgentsUnsupportedError(agentType string) error {
 	return errors.New(400, ErrorReason_AGENTS_UNSUPPORTED.String(), fmt.Sprintf("unsupported agent type %s.", agentType))
 }
+
+func GenerateAgentsInvalidError(reason string) error {
+	return errors.New(400, ErrorReason_AGENTS_INVALID.String(), fmt.Sprintf("invalid agents: %s.", reason))
+}
+
+func GenerateMappingsInvalidError(reason string) error {
+	return errors.New(400, ErrorReason_MAPPINGS_INVALID.String(), fmt.Sprintf("invalid mappings: %s.", reason))
+}
+
+func GenerateDiscoveryFailedError(reason string) error {
+	return errors.New(502, ErrorReason_DISCOVERY_FAILED.String(), fmt.Sprintf("failed to discover: %s.", reason))
+}
+
+func GenerateDeviceUnavailableError(reason string) error {
+	return errors.New(409, ErrorReason_DEVICE_UNAVAILABLE.String(), fmt.Sprintf("device unavailable: %s.", reason))
+}
+
+func GenerateDiagnosticsFailedError(reason string) error {
+	return errors.New(502, ErrorReason_DIAGNOSTICS_FAILED.String(), fmt.Sprintf("failed to diagnose: %s.", reason))
+}
@@ -18,7 +18,8 @@ import (
 )
 
 // NewHTTPServer new an HTTP server.
-func NewHTTPServer(c *conf.Server, thingTypes *service.ThingTypesService, things *service.ThingsService, agents *service.AgentsService, logger *log.Helper) *http.Server {
+// 物模型与agents由模型管理服务提供, broker只提供采集相关接口
+func NewHTTPServer(c *conf.Server, discovery *service.DiscoveryService, diagnostics *service.DiagnosticsService, logger *log.Helper) *http.Server {
 	var opts = []http.ServerOption{
 		http.Middleware(
 			recovery.Recovery(),
@@ -37,9 +38,8 @@ func NewHTTPServer(c *conf.Server, thingTypes *service.ThingTypesService, things
 
 	// auth
 
-	v1.RegisterThingTypesHTTPServer(srv, thingTypes)
-	v1.RegisterThingsHTTPServer(srv, things)
-	v1.RegisterAgentsHTTPServer(srv, agents)
+	v1.RegisterDiscoveryHTTPServer(srv, discovery)
+	v1.RegisterDiagnosticsHTTPServer(srv, diagnostics)
 	return srv
 }
 
@@ -7,6 +7,7 @@ import (
 	pb "harnsplatform/api/modelmanager/v1"
 	"harnsplatform/internal/biz"
 	"harnsplatform/internal/collector"
+	"harnsplatform/internal/errors"
 	randutil "harnsplatform/internal/utils"
 	"strconv"
 	"time"
@@ -28,8 +29,11 @@ func NewAgentsService(au *biz.AgentsUsecase, logger *log.Helper) *AgentsService
 
 // CreateAgents Validate in this
 func (s *AgentsService) CreateAgents(ctx context.Context, req pb.Agents) (*biz.Agents, error) {
-	manager := collector.AgentsManagers[req.GetAgentType()]
-	agents, err := manager.CreateAgents(ctx, req)
+	driver, ok := collector.GetDriver(req.GetAgentType())
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(req.GetAgentType())
+	}
+	agents, err := driver.CreateAgents(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -54,8 +58,11 @@ func (s *AgentsService) GetAgentsById(ctx context.Context, req *biz.Meta) (*biz.
 }
 
 func (s *AgentsService) UpdateAgentsById(ctx context.Context, req pb.Agents) (*biz.Agents, error) {
-	manager := collector.AgentsManagers[req.GetAgentType()]
-	agents, err := manager.CreateAgents(ctx, req)
+	driver, ok := collector.GetDriver(req.GetAgentType())
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(req.GetAgentType())
+	}
+	agents, err := driver.CreateAgents(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -95,7 +102,33 @@ func (s *AgentsService) GetAgents(ctx context.Context, req *biz.AgentsQuery) (*b
 }
 
 func (s *AgentsService) CreateAgentsMappings(ctx context.Context, req []*biz.Mapping) ([]*biz.Mapping, error) {
-	// manager := collector.AgentsManagers[req.GetAgentType()]
+	agentsMappings := make(map[string][]*biz.Mapping, 0)
+	for _, mapping := range req {
+		agentsMappings[mapping.AgentId] = append(agentsMappings[mapping.AgentId], mapping)
+	}
+	for agentsId, mappings := range agentsMappings {
+		agents, err := s.au.GetAgentsById(ctx, agentsId)
+		if err != nil {
+			return nil, err
+		}
+		driver, ok := collector.GetDriver(agents.AgentType)
+		if !ok {
+			return nil, errors.GenerateAgentsUnsupportedError(agents.AgentType)
+		}
+		// 与已保存的mappings一起校验, 避免名称或地址重复导致设备无法转换
+		pr, err := s.au.GetMappingsByAgentsId(ctx, &biz.MappingsQuery{
+			AgentId:           agents.Id,
+			PaginationRequest: &biz.PaginationRequest{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		exists, _ := pr.Items.([]*biz.Mapping)
+		if err := driver.ValidateMappings(ctx, agents, append(exists, mappings...)); err != nil {
+			return nil, err
+		}
+	}
+
 	for _, mapping := range req {
 		mapping.Id = ulid.MustNewDefault(time.Now()).String()
 		mapping.Version = strconv.FormatUint(randutil.Uint64n(), 10)
@@ -108,6 +141,32 @@ func (s *AgentsService) CreateAgentsMappings(ctx context.Context, req []*biz.Map
 	return pr, nil
 }
 
+// GetAgentsFramePlan 调试用, 返回agents当前映射计算出的采集报文规划
+func (s *AgentsService) GetAgentsFramePlan(ctx context.Context, req *biz.Meta) (interface{}, error) {
+	agents, err := s.au.GetAgentsById(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	driver, ok := collector.GetDriver(agents.AgentType)
+	if !ok {
+		return nil, errors.GenerateAgentsUnsupportedError(agents.AgentType)
+	}
+	pr, err := s.au.GetMappingsByAgentsId(ctx, &biz.MappingsQuery{
+		AgentId:           agents.Id,
+		PaginationRequest: &biz.PaginationRequest{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mappings, _ := pr.Items.([]*biz.Mapping)
+	return driver.GetFramePlan(ctx, agents, mappings)
+}
+
+// ListAgentTypes 返回已注册驱动的agent类型及支持的能力
+func (s *AgentsService) ListAgentTypes(ctx context.Context) (interface{}, error) {
+	return collector.ListDrivers(), nil
+}
+
 func (s *AgentsService) DeleteAgentsMappingsById(ctx context.Context, req *biz.Meta) (*biz.Mapping, error) {
 	id, err := s.au.DeleteAgentsMappingsById(ctx, req.GetId(), req.GetVersion())
 	if err != nil {
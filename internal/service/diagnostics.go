@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kratos/kratos/v2/log"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector"
+	"harnsplatform/internal/collector/modbus/runtime"
+	"harnsplatform/internal/errors"
+	"os"
+)
+
+// DiagnosticsService 设备通讯统计与诊断
+type DiagnosticsService struct {
+	cm  *collector.Manager
+	log *log.Helper
+}
+
+func NewDiagnosticsService(cm *collector.Manager, logger *log.Helper) *DiagnosticsService {
+	return &DiagnosticsService{
+		cm:  cm,
+		log: logger,
+	}
+}
+
+func (s *DiagnosticsService) GetStatistics(ctx context.Context, req *biz.Meta) (interface{}, error) {
+	statistics, err := s.cm.GetStatistics(req.Id)
+	if err != nil {
+		s.log.Errorf("Failed to get statistics of device %s. err description:%s", req.Id, err)
+		return nil, deviceError(err)
+	}
+	return statistics, nil
+}
+
+func (s *DiagnosticsService) ResetStatistics(ctx context.Context, req *biz.Meta) (interface{}, error) {
+	if err := s.cm.ResetStatistics(req.Id); err != nil {
+		s.log.Errorf("Failed to reset statistics of device %s. err description:%s", req.Id, err)
+		return nil, deviceError(err)
+	}
+	return s.cm.GetStatistics(req.Id)
+}
+
+// Diagnose 诊断请求与采集共用连接, 设备不支持的功能码在结果中标记为不支持
+func (s *DiagnosticsService) Diagnose(ctx context.Context, req *biz.Meta) (interface{}, error) {
+	result, err := s.cm.Diagnose(ctx, req.Id)
+	if err != nil {
+		s.log.Errorf("Failed to diagnose device %s. err description:%s", req.Id, err)
+		switch err {
+		case os.ErrNotExist, collector.ErrDeviceNotCollecting, collector.ErrDiagnosticsUnsupported:
+			return nil, deviceError(err)
+		default:
+			return nil, errors.GenerateDiagnosticsFailedError(err.Error())
+		}
+	}
+	return result, nil
+}
+
+// ListSerialBuses 进程内共享的RS-485总线及其统计
+func (s *DiagnosticsService) ListSerialBuses(ctx context.Context) (interface{}, error) {
+	return runtime.SerialBuses.Stats(), nil
+}
+
+func deviceError(err error) error {
+	if err == os.ErrNotExist {
+		return errors.GenerateResourceNotFoundError("device")
+	}
+	return errors.GenerateDeviceUnavailableError(err.Error())
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"github.com/go-kratos/kratos/v2/log"
+	"harnsplatform/internal/biz"
+	"harnsplatform/internal/collector/modbus"
+	"harnsplatform/internal/collector/opcua"
+)
+
+// DiscoveryService 扫描下位机的地址空间, 生成草稿点位
+type DiscoveryService struct {
+	modbus *modbus.AgentsManager
+	opcUa  *opcua.AgentsManager
+	log    *log.Helper
+}
+
+func NewDiscoveryService(logger *log.Helper) *DiscoveryService {
+	return &DiscoveryService{
+		modbus: &modbus.AgentsManager{},
+		opcUa:  &opcua.AgentsManager{},
+		log:    logger,
+	}
+}
+
+// DiscoverModbus 扫描耗时与地址段大小、请求上限相关, 受http超时限制
+func (s *DiscoveryService) DiscoverModbus(ctx context.Context, req *biz.ModbusDiscoveryOptions) (interface{}, error) {
+	result, err := s.modbus.Discover(ctx, req)
+	if err != nil {
+		s.log.Errorf("Failed to discover modbus slave %d. err description:%s", req.Slave, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// DiscoverOpcUa 浏览耗时与节点数量上限相关, 受http超时限制
+func (s *DiscoveryService) DiscoverOpcUa(ctx context.Context, req *biz.OpcUaBrowseOptions) (interface{}, error) {
+	result, err := s.opcUa.Browse(ctx, req)
+	if err != nil {
+		s.log.Errorf("Failed to browse opcUa server %v. err description:%s", req.Address["endpoint"], err)
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package jsonpath
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/**
+JSONPath 子集, 用于从JSON文档中取出单个值
+$                 根
+.name             对象成员
+['name'] ["name"] 对象成员, 成员名可包含 . 与 [
+[n]               数组下标, 负数从末尾计
+例如 $.data.sensors[0].temperature  $['a.b'][-1]
+省略开头的$时按$.处理, 例如 data.temperature
+*/
+
+var ErrPathEmpty = errors.New("jsonpath empty")
+var ErrPathInvalid = errors.New("jsonpath invalid")
+
+type segment struct {
+	key   string
+	index int
+	array bool
+}
+
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+// Compile 解析路径
+func Compile(path string) (*Path, error) {
+	s := strings.TrimSpace(path)
+	if len(s) == 0 {
+		return nil, ErrPathEmpty
+	}
+	switch {
+	case s[0] == '$':
+		s = s[1:]
+	case s[0] == '[':
+	default:
+		s = "." + s
+	}
+
+	p := &Path{raw: path, segments: make([]segment, 0)}
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, ErrPathInvalid
+			}
+			p.segments = append(p.segments, segment{key: s[:end]})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, ErrPathInvalid
+			}
+			inner := s[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') {
+				// 引号内的成员名可能包含 ], 按结束引号查找
+				closing := strings.Index(s[2:], string(inner[0])+"]")
+				if closing < 0 {
+					return nil, ErrPathInvalid
+				}
+				p.segments = append(p.segments, segment{key: s[2 : 2+closing]})
+				s = s[2+closing+2:]
+				continue
+			}
+			index, err := strconv.Atoi(strings.TrimSpace(inner))
+			if err != nil {
+				return nil, ErrPathInvalid
+			}
+			p.segments = append(p.segments, segment{index: index, array: true})
+			s = s[end+1:]
+		default:
+			return nil, ErrPathInvalid
+		}
+	}
+	return p, nil
+}
+
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Get 取出路径对应的值, 文档为encoding/json解码得到的map[string]interface{}与[]interface{}
+func (p *Path) Get(document interface{}) (interface{}, bool) {
+	current := document
+	for _, seg := range p.segments {
+		if seg.array {
+			array, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			index := seg.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, false
+			}
+			current = array[index]
+			continue
+		}
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[seg.key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,19 @@
+package utils
+
+// CheckLrcsum Modbus ASCII 纵向冗余校验,所有字节求和后取补码
+func CheckLrcsum(bytes []uint8) uint8 {
+	var sum uint8 = 0
+	for _, b := range bytes {
+		sum += b
+	}
+	return uint8(-int8(sum))
+}
+
+// ValidateLrcsum 校验末尾字节为前面数据的LRC
+func ValidateLrcsum(bytes []uint8) bool {
+	n := len(bytes)
+	if n < 2 {
+		return false
+	}
+	return CheckLrcsum(bytes[:n-1]) == bytes[n-1]
+}